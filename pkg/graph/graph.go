@@ -0,0 +1,167 @@
+// Package graph holds the road network routes are found over: nodes at
+// planar coordinates joined by directed edges
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+var (
+	// ErrDuplicateNode is returned by AddNode for an id already in the graph
+	ErrDuplicateNode = errors.New("graph: duplicate node")
+	// ErrMissingNode is wrapped by AddEdge errors for an endpoint that isn't in
+	// the graph
+	ErrMissingNode = errors.New("graph: missing node")
+	// ErrInvalidEdge is wrapped by AddEdge errors for a Length or TravelTime
+	// that is negative, NaN or infinite
+	ErrInvalidEdge = errors.New("graph: invalid edge")
+)
+
+// NodeID identifies a node, such as an OSM node id
+type NodeID int64
+
+// Edge is a one-way road from From to To. Length is in the graph's
+// coordinate units and TravelTime in whatever unit routing weighs by; Meta
+// is carried along for the caller, such as a road name or class. A two-way
+// road is two edges.
+type Edge struct {
+	From, To   NodeID
+	Length     float64
+	TravelTime float64
+	Meta       interface{}
+}
+
+// Graph is a directed road network. Each node's outgoing edges are kept in
+// an adjacency list, and its coordinates in a spatial.QuadTree so a point
+// can be matched to nearby nodes. It is safe for concurrent use.
+type Graph struct {
+	mu    sync.RWMutex
+	nodes map[NodeID]*node
+	edges int
+	index *spatial.QuadTree
+}
+
+type node struct {
+	point spatial.Point
+	out   []Edge
+}
+
+// New returns an empty graph whose nodes must lie within bounds. opts
+// configure the node index, as for spatial.NewQuadTree, whose errors it
+// returns.
+func New(bounds spatial.Bounds, opts ...spatial.Option) (*Graph, error) {
+	index, err := spatial.NewQuadTree(bounds, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Graph{nodes: make(map[NodeID]*node), index: index}, nil
+}
+
+// AddNode adds node id at p. It returns ErrDuplicateNode if id is already
+// in the graph, and spatial.ErrInvalidPoint or spatial.ErrOutOfBounds if p
+// is not finite or lies outside the graph's bounds.
+func (g *Graph) AddNode(id NodeID, p spatial.Point) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, exists := g.nodes[id]; exists {
+		return ErrDuplicateNode
+	}
+	p = spatial.Point{X: p.X, Y: p.Y, Data: id}
+	if err := g.index.InsertWithID(indexKey(id), p); err != nil {
+		return err
+	}
+	g.nodes[id] = &node{point: p}
+	return nil
+}
+
+// indexKey is the node index's id for node id
+func indexKey(id NodeID) string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// AddEdge adds e to the outgoing edges of e.From. Both endpoints must
+// already be in the graph. Parallel edges and loops are allowed.
+func (g *Graph) AddEdge(e Edge) error {
+	if !validWeight(e.Length) || !validWeight(e.TravelTime) {
+		return fmt.Errorf("%w: %d -> %d: length %v, travel time %v", ErrInvalidEdge, e.From, e.To, e.Length, e.TravelTime)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	from, ok := g.nodes[e.From]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrMissingNode, e.From)
+	}
+	if _, ok := g.nodes[e.To]; !ok {
+		return fmt.Errorf("%w: %d", ErrMissingNode, e.To)
+	}
+	from.out = append(from.out, e)
+	g.edges++
+	return nil
+}
+
+func validWeight(w float64) bool {
+	return w >= 0 && !math.IsInf(w, 1)
+}
+
+// Neighbors returns the edges leaving id, in the order they were added. It
+// returns nil if id has none or isn't in the graph.
+func (g *Graph) Neighbors(id NodeID) []Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	n, ok := g.nodes[id]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(n.out)
+}
+
+// Node returns the coordinates of node id, and false if it isn't in the
+// graph
+func (g *Graph) Node(id NodeID) (spatial.Point, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	n, ok := g.nodes[id]
+	if !ok {
+		return spatial.Point{}, false
+	}
+	return spatial.Point{X: n.point.X, Y: n.point.Y}, true
+}
+
+// NodeCount returns how many nodes the graph has
+func (g *Graph) NodeCount() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.nodes)
+}
+
+// EdgeCount returns how many edges the graph has
+func (g *Graph) EdgeCount() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.edges
+}
+
+// Index returns the spatial index of the graph's node coordinates. Each
+// point's Data is its NodeID. It stays current as nodes are added, and must
+// only be read: changing it directly leaves it out of step with the graph.
+func (g *Graph) Index() *spatial.QuadTree {
+	return g.index
+}
+
+// NearestNodes returns the ids of the k nodes closest to p, nearest first
+// and then in the order they were added
+func (g *Graph) NearestNodes(p spatial.Point, k int) []NodeID {
+	found := g.index.KNearest(p, k)
+	ids := make([]NodeID, len(found))
+	for i, q := range found {
+		ids[i] = q.Data.(NodeID)
+	}
+	return ids
+}
@@ -0,0 +1,126 @@
+package graph_test
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/graph"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/graph/graphtest"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+var bounds1000 = spatial.Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+
+func TestAddEdgeValidation(t *testing.T) {
+	g, err := graph.New(bounds1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddNode(1, spatial.Point{X: 10, Y: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddNode(2, spatial.Point{X: 20, Y: 10}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		e    graph.Edge
+		want error
+	}{
+		{graph.Edge{From: 1, To: 3, Length: 1}, graph.ErrMissingNode},
+		{graph.Edge{From: 3, To: 1, Length: 1}, graph.ErrMissingNode},
+		{graph.Edge{From: 1, To: 2, Length: -1}, graph.ErrInvalidEdge},
+		{graph.Edge{From: 1, To: 2, Length: math.NaN()}, graph.ErrInvalidEdge},
+		{graph.Edge{From: 1, To: 2, Length: 1, TravelTime: math.Inf(1)}, graph.ErrInvalidEdge},
+	} {
+		if err := g.AddEdge(tc.e); !errors.Is(err, tc.want) {
+			t.Errorf("%+v: expected %v, got %v", tc.e, tc.want, err)
+		}
+	}
+	if g.EdgeCount() != 0 || g.Neighbors(1) != nil {
+		t.Fatal("expected rejected edges to leave the graph unchanged")
+	}
+
+	road := graph.Edge{From: 1, To: 2, Length: 10, TravelTime: 1, Meta: "Main St"}
+	if err := g.AddEdge(road); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Neighbors(1); len(got) != 1 || got[0] != road {
+		t.Errorf("expected %+v, got %+v", road, got)
+	}
+	if got := g.Neighbors(2); got != nil {
+		t.Errorf("expected edges to be one-way, got %+v", got)
+	}
+	g.Neighbors(1)[0].To = 99
+	if got := g.Neighbors(1); got[0].To != 2 {
+		t.Error("expected Neighbors to return a copy")
+	}
+}
+
+func TestAddNodeErrors(t *testing.T) {
+	g, _ := graph.New(bounds1000)
+	if err := g.AddNode(1, spatial.Point{X: 1, Y: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		id   graph.NodeID
+		p    spatial.Point
+		want error
+	}{
+		{1, spatial.Point{X: 2, Y: 2}, graph.ErrDuplicateNode},
+		{2, spatial.Point{X: -1, Y: 2}, spatial.ErrOutOfBounds},
+		{3, spatial.Point{X: math.NaN(), Y: 2}, spatial.ErrInvalidPoint},
+	} {
+		if err := g.AddNode(tc.id, tc.p); !errors.Is(err, tc.want) {
+			t.Errorf("node %d at %v: expected %v, got %v", tc.id, tc.p, tc.want, err)
+		}
+	}
+	if g.NodeCount() != 1 || g.Index().Size() != 1 {
+		t.Errorf("expected only the first node, got %d nodes and %d indexed", g.NodeCount(), g.Index().Size())
+	}
+	if p, ok := g.Node(1); !ok || p.X != 1 || p.Y != 1 {
+		t.Errorf("expected node 1 at 1, 1, got %v, %v", p, ok)
+	}
+	if _, err := graph.New(spatial.Bounds{Width: 1}); !errors.Is(err, spatial.ErrInvalidBounds) {
+		t.Errorf("expected ErrInvalidBounds, got %v", err)
+	}
+}
+
+// TestGrid tests the synthetic grid's shape and that its nodes are indexed
+// where they sit
+func TestGrid(t *testing.T) {
+	const rows, cols, spacing = 4, 5, 100.0
+	g := graphtest.Grid(rows, cols, spacing, 10)
+	if g.NodeCount() != rows*cols || g.EdgeCount() != 2*(rows*(cols-1)+cols*(rows-1)) {
+		t.Fatalf("expected %d nodes and %d edges, got %d and %d", rows*cols, 2*(rows*(cols-1)+cols*(rows-1)), g.NodeCount(), g.EdgeCount())
+	}
+	// Node 6 is row 1, column 1: a crossroads
+	var to []graph.NodeID
+	for _, e := range g.Neighbors(6) {
+		if e.From != 6 || e.Length != spacing || e.TravelTime != 10 {
+			t.Errorf("unexpected edge %+v", e)
+		}
+		to = append(to, e.To)
+	}
+	slices.Sort(to)
+	if want := []graph.NodeID{1, 5, 7, 11}; !slices.Equal(to, want) {
+		t.Errorf("expected neighbours %v, got %v", want, to)
+	}
+	if n := len(g.Neighbors(0)); n != 2 {
+		t.Errorf("expected a corner to have 2 edges, got %d", n)
+	}
+
+	if got := g.NearestNodes(spatial.Point{X: 140, Y: 260}, 2); !slices.Equal(got, []graph.NodeID{16, 11}) {
+		t.Errorf("expected nodes 16 and 11 nearest, got %v", got)
+	}
+	for _, p := range g.Index().Search(spatial.Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}) {
+		id := p.Data.(graph.NodeID)
+		if want, _ := g.Node(id); want.X != p.X || want.Y != p.Y {
+			t.Errorf("node %d indexed at %v, expected %v", id, p, want)
+		}
+	}
+	if g := graphtest.Grid(1, 3, 1, 1); g.NodeCount() != 3 || g.EdgeCount() != 4 {
+		t.Errorf("expected a single row to build, got %d nodes and %d edges", g.NodeCount(), g.EdgeCount())
+	}
+}
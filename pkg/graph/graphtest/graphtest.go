@@ -0,0 +1,55 @@
+// Package graphtest builds synthetic road networks for testing and
+// benchmarking routing
+package graphtest
+
+import (
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/graph"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// Grid builds a city block layout of rows x cols nodes, spacing apart, with
+// a two-way road between each node and its neighbours across and up.
+// Node r*cols+c sits at (c*spacing, r*spacing), and every edge has Length
+// spacing and TravelTime spacing/speed. It panics unless rows, cols,
+// spacing and speed are positive.
+func Grid(rows, cols int, spacing, speed float64) *graph.Graph {
+	if rows < 1 || cols < 1 || !(spacing > 0) || !(speed > 0) {
+		panic("graphtest: Grid needs positive rows, cols, spacing and speed")
+	}
+	// At least one spacing each way, so a single row or column has an area
+	bounds := spatial.Bounds{
+		Width:  float64(max(cols-1, 1)) * spacing,
+		Height: float64(max(rows-1, 1)) * spacing,
+	}
+	g, err := graph.New(bounds)
+	if err != nil {
+		panic(err)
+	}
+	id := func(r, c int) graph.NodeID { return graph.NodeID(r*cols + c) }
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if err := g.AddNode(id(r, c), spatial.Point{X: float64(c) * spacing, Y: float64(r) * spacing}); err != nil {
+				panic(err)
+			}
+		}
+	}
+	road := func(a, b graph.NodeID) {
+		for _, e := range []graph.Edge{{From: a, To: b}, {From: b, To: a}} {
+			e.Length, e.TravelTime = spacing, spacing/speed
+			if err := g.AddEdge(e); err != nil {
+				panic(err)
+			}
+		}
+	}
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if c+1 < cols {
+				road(id(r, c), id(r, c+1))
+			}
+			if r+1 < rows {
+				road(id(r, c), id(r+1, c))
+			}
+		}
+	}
+	return g
+}
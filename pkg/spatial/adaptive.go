@@ -0,0 +1,149 @@
+package spatial
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Touches a region needs before Compact retunes it, and how lopsided reads
+// and writes must be to count as query-heavy or churn-heavy
+const (
+	adaptMinTouches = 64
+	adaptSkew       = 4
+)
+
+// WithAdaptiveCapacity lets Compact retune leaf capacity per subtree,
+// between min and max, instead of rebuilding with one fixed capacity. Every
+// leaf counts the searches that scan it and the writes that land in it; on
+// Compact, query-heavy regions double their capacity, merging subtrees that
+// then fit in one leaf, and churn-heavy regions halve it, splitting leaves
+// that no longer fit. The counts start over after every Compact. The
+// starting capacity, from WithCapacity, is clamped into [min, max].
+func WithAdaptiveCapacity(min, max int) Option {
+	return func(qt *QuadTree) {
+		qt.adaptMin = min
+		qt.adaptMax = max
+		qt.Root.adaptive = true
+		qt.Root.touches = newTouches(true)
+	}
+}
+
+// touches counts the searches that scanned a leaf and the writes that landed
+// in it since the last Compact. The counts live outside the Node because a
+// node shared with a snapshot is still searched, and counted, through the
+// snapshot while a writer copies it; copying the Node must not read them.
+type touches struct {
+	reads  atomic.Uint32 // Searches run under the read lock, so this is atomic
+	writes uint32        // Written under the leaf's write lock
+}
+
+// newTouches returns fresh counts for an adaptive node, nil otherwise
+func newTouches(adaptive bool) *touches {
+	if !adaptive {
+		return nil
+	}
+	return new(touches)
+}
+
+// copy returns counts starting from t's, for a copy of its node
+func (t *touches) copy() *touches {
+	if t == nil {
+		return nil
+	}
+	c := &touches{writes: t.writes}
+	c.reads.Store(t.reads.Load())
+	return c
+}
+
+// forgetWrites clears the write count, for points moved rather than written
+func (t *touches) forgetWrites() {
+	if t != nil {
+		t.writes = 0
+	}
+}
+
+// touchRead counts a search scanning leaf n
+func (n *Node) touchRead() {
+	if n.touches != nil {
+		n.touches.reads.Add(1)
+	}
+}
+
+// touchWrite counts a write landing in leaf n. Callers hold n's write lock.
+func (n *Node) touchWrite() {
+	if n.touches != nil {
+		n.touches.writes++
+	}
+}
+
+// adaptDirection is +1 for a query-heavy region, -1 for a churn-heavy one
+// and 0 for a balanced one
+func adaptDirection(reads, writes uint64) int {
+	switch {
+	case reads >= adaptSkew*writes:
+		return 1
+	case writes >= adaptSkew*reads:
+		return -1
+	}
+	return 0
+}
+
+// saturate32 narrows a touch total for storage in a node's counters
+func saturate32(v uint64) uint32 {
+	return uint32(min(v, math.MaxUint32))
+}
+
+// sumTouches stores the touch totals of n's subtree in every internal node's
+// counters, which are otherwise unused, and returns n's totals. n must be
+// writable.
+func (n *Node) sumTouches() (reads, writes uint64) {
+	if n.Children[0] == nil {
+		return uint64(n.touches.reads.Load()), uint64(n.touches.writes)
+	}
+	for i := 0; i < 4; i++ {
+		r, w := n.writableChild(i).sumTouches()
+		reads += r
+		writes += w
+	}
+	n.touches.reads.Store(saturate32(reads))
+	n.touches.writes = saturate32(writes)
+	return reads, writes
+}
+
+// adapt retunes the capacity of n's subtree and resets its touch counts,
+// which sumTouches must already have totalled. A subtree with too few
+// touches of its own follows dir, the direction decided for its nearest
+// well-sampled ancestor, so sparse leaves in a busy region still move.
+// Leaves that no longer fit are split, and a subtree that fits in one leaf
+// at its children's largest capacity is merged. n must be writable.
+func (n *Node) adapt(lo, hi, dir int) {
+	reads, writes := uint64(n.touches.reads.Swap(0)), uint64(n.touches.writes)
+	n.touches.writes = 0
+	if reads+writes >= adaptMinTouches {
+		dir = adaptDirection(reads, writes)
+	}
+
+	if n.Children[0] == nil {
+		switch dir {
+		case 1:
+			n.Capacity *= 2
+		case -1:
+			n.Capacity /= 2
+		}
+		n.Capacity = min(max(n.Capacity, lo), hi)
+		if n.splits(len(n.Points)) {
+			n.SubDivide()
+		}
+		return
+	}
+
+	n.Capacity = lo
+	for i := 0; i < 4; i++ {
+		c := n.writableChild(i)
+		c.adapt(lo, hi, dir)
+		n.Capacity = max(n.Capacity, c.Capacity)
+	}
+	if !n.splits(n.count) {
+		n.collapse()
+	}
+}
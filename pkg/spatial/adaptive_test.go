@@ -0,0 +1,129 @@
+package spatial
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// leafCapacities returns the capacity of every leaf whose bounds lie inside area
+func leafCapacities(n *Node, area Bounds, dst map[int]int) {
+	if n.Children[0] != nil {
+		for _, c := range n.Children {
+			leafCapacities(c, area, dst)
+		}
+		return
+	}
+	if area.containsBounds(n.Bounds) {
+		dst[n.Capacity]++
+	}
+}
+
+// adaptiveWorkload fills qt with a dense downtown cluster in the NW quadrant
+// and sparse suburbs in the SE quadrant, and returns a function running one
+// round of small downtown queries and suburban insert/remove churn
+func adaptiveWorkload(qt *QuadTree, seed int64) func() {
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < 20000; i++ {
+		qt.Insert(Point{X: 100 + rng.NormFloat64()*40, Y: 100 + rng.NormFloat64()*40})
+	}
+	for i := 0; i < 2000; i++ {
+		qt.Insert(Point{X: 500 + rng.Float64()*500, Y: 500 + rng.Float64()*500})
+	}
+	buf := make([]Point, 0, 64)
+	return func() {
+		for i := 0; i < 20; i++ {
+			x, y := 60+rng.Float64()*80, 60+rng.Float64()*80
+			buf = qt.SearchAppend(Bounds{X: x, Y: y, Width: 4, Height: 4}, buf[:0])
+		}
+		for i := 0; i < 20; i++ {
+			p := Point{X: 500 + rng.Float64()*500, Y: 500 + rng.Float64()*500}
+			qt.Insert(p)
+			qt.Remove(p)
+		}
+	}
+}
+
+// TestAdaptiveCapacityFollowsWorkload tests that Compact grows queried leaves and shrinks churned ones
+func TestAdaptiveCapacityFollowsWorkload(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8), WithAdaptiveCapacity(2, 128))
+	round := adaptiveWorkload(qt, 1)
+	downtown := Bounds{X: 0, Y: 0, Width: 500, Height: 500}
+	suburbs := Bounds{X: 500, Y: 500, Width: 500, Height: 500}
+	want := qt.Search(qt.Root.Bounds)
+
+	for pass := 0; pass < 5; pass++ {
+		for i := 0; i < 50; i++ {
+			round()
+		}
+		qt.Compact()
+		checkValid(t, qt)
+	}
+
+	dense, sparse := map[int]int{}, map[int]int{}
+	leafCapacities(qt.Root, downtown, dense)
+	leafCapacities(qt.Root, suburbs, sparse)
+	if dense[128] == 0 {
+		t.Errorf("Expected queried downtown leaves to reach capacity 128, got %v", dense)
+	}
+	if sparse[2] == 0 {
+		t.Errorf("Expected churned suburban leaves to reach capacity 2, got %v", sparse)
+	}
+	if got := qt.Search(qt.Root.Bounds); len(got) != len(want) {
+		t.Errorf("Compact changed the point count from %d to %d", len(want), len(got))
+	}
+}
+
+// TestAdaptiveCapacityOptions tests the capacity range validation and clamping
+func TestAdaptiveCapacityOptions(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	for _, r := range [][2]int{{0, 8}, {8, 4}} {
+		if _, err := NewQuadTree(bounds, WithAdaptiveCapacity(r[0], r[1])); !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("WithAdaptiveCapacity(%d, %d): expected ErrInvalidCapacity, got %v", r[0], r[1], err)
+		}
+	}
+	if qt := mustNewQuadTree(bounds, WithCapacity(100), WithAdaptiveCapacity(2, 16)); qt.Root.Capacity != 16 {
+		t.Errorf("Expected the starting capacity to be clamped to 16, got %d", qt.Root.Capacity)
+	}
+
+	// Without the option Compact keeps the fixed capacity
+	qt := mustNewQuadTree(bounds, WithCapacity(4))
+	for i := 0; i < 100; i++ {
+		qt.Insert(Point{X: float64(i), Y: float64(i)})
+		qt.Search(bounds)
+	}
+	qt.Compact()
+	caps := map[int]int{}
+	leafCapacities(qt.Root, bounds, caps)
+	if len(caps) != 1 || caps[4] == 0 {
+		t.Errorf("Expected every leaf to keep capacity 4, got %v", caps)
+	}
+}
+
+// benchmarkAdaptive runs the downtown/suburbs workload, compacting every 100 rounds
+func benchmarkAdaptive(b *testing.B, opts ...Option) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, opts...)
+	round := adaptiveWorkload(qt, 1)
+	for i := 0; i < 300; i++ {
+		round()
+		if i%100 == 99 {
+			qt.Compact()
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		round()
+		if i%100 == 99 {
+			qt.Compact()
+		}
+	}
+}
+
+func BenchmarkAdaptiveWorkloadFixed2(b *testing.B)   { benchmarkAdaptive(b, WithCapacity(2)) }
+func BenchmarkAdaptiveWorkloadFixed8(b *testing.B)   { benchmarkAdaptive(b, WithCapacity(8)) }
+func BenchmarkAdaptiveWorkloadFixed32(b *testing.B)  { benchmarkAdaptive(b, WithCapacity(32)) }
+func BenchmarkAdaptiveWorkloadFixed64(b *testing.B)  { benchmarkAdaptive(b, WithCapacity(64)) }
+func BenchmarkAdaptiveWorkloadFixed128(b *testing.B) { benchmarkAdaptive(b, WithCapacity(128)) }
+func BenchmarkAdaptiveWorkloadAdaptive(b *testing.B) {
+	benchmarkAdaptive(b, WithCapacity(8), WithAdaptiveCapacity(2, 128))
+}
@@ -0,0 +1,38 @@
+package spatial
+
+import "sync"
+
+// distancePool recycles the distance-ranking scratch space of KNearest
+var distancePool = sync.Pool{New: func() interface{} { return new([]PointWithDistance) }}
+
+// SearchAppend is Search appending into dst, so a caller repeating queries
+// can reuse one buffer. It returns the extended slice, as append does, so a
+// nil dst comes back nil when nothing matches.
+func (qt *QuadTree) SearchAppend(area Bounds, dst []Point) []Point {
+	if view := qt.view(); view != nil {
+		view.searchLive(area, qt.edges, &dst)
+		return dst
+	}
+	held := qt.rlockArea(area)
+	defer qt.runlockArea(held)
+	qt.searchCached(area, qt.edges, &dst)
+	return dst
+}
+
+// KNearestAppend is KNearest appending into dst. The candidates are gathered
+// in dst's spare capacity, so a reused buffer that has grown to hold them
+// makes repeated queries allocation free. Geographic trees still allocate.
+func (qt *QuadTree) KNearestAppend(target Point, k int, dst []Point) []Point {
+	if k <= 0 {
+		return dst
+	}
+	if qt.geo && qt.metric == nil {
+		return append(dst, qt.kNearestGeoPoint(target, k)...)
+	}
+	if view := qt.view(); view != nil {
+		return view.kNearestAppend(target, k, dst)
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.kNearestAppend(target, k, dst)
+}
@@ -0,0 +1,63 @@
+package spatial
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSearchAppendKeepsPrefix tests that the append variants add the same points as the allocating queries after dst's contents
+func TestSearchAppendKeepsPrefix(t *testing.T) {
+	qt := newRandomTree(2000, 1)
+	area := Bounds{X: 100, Y: 100, Width: 200, Height: 200}
+	prefix := []Point{{X: -1, Y: -1, Data: -1}}
+
+	got := qt.SearchAppend(area, append([]Point(nil), prefix...))
+	if !reflect.DeepEqual(got[:1], prefix) || !reflect.DeepEqual(got[1:], qt.Search(area)) {
+		t.Errorf("SearchAppend returned %d points, want the prefix and %d", len(got), len(qt.Search(area)))
+	}
+
+	target := Point{X: 500, Y: 500}
+	got = qt.KNearestAppend(target, 7, append([]Point(nil), prefix...))
+	if !reflect.DeepEqual(got[:1], prefix) || !reflect.DeepEqual(got[1:], qt.KNearest(target, 7)) {
+		t.Errorf("KNearestAppend = %v, want the prefix and %v", got, qt.KNearest(target, 7))
+	}
+	if got := qt.KNearestAppend(target, 0, prefix); len(got) != 1 {
+		t.Errorf("KNearestAppend with k=0 returned %d points", len(got))
+	}
+}
+
+// TestQueryAppendAllocations tests that repeated queries into a reused buffer do not allocate
+func TestQueryAppendAllocations(t *testing.T) {
+	qt := newRandomTree(20000, 1)
+	area := Bounds{X: 100, Y: 100, Width: 100, Height: 100}
+	target := Point{X: 500, Y: 500}
+	buf := make([]Point, 0, 1024)
+
+	if allocs := testing.AllocsPerRun(100, func() { buf = qt.SearchAppend(area, buf[:0]) }); allocs != 0 {
+		t.Errorf("SearchAppend allocated %v times per query", allocs)
+	}
+	// The ranking scratch comes from a sync.Pool, which the race detector
+	// makes drop entries at random
+	if allocs := testing.AllocsPerRun(100, func() { buf = qt.KNearestAppend(target, 16, buf[:0]) }); allocs > 1 {
+		t.Errorf("KNearestAppend allocated %v times per query", allocs)
+	}
+
+	lockFree := mustNewQuadTree(qt.Root.Bounds, WithLockFreeReads())
+	for _, p := range qt.Search(qt.Root.Bounds) {
+		lockFree.Insert(p)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { buf = lockFree.SearchAppend(area, buf[:0]) }); allocs != 0 {
+		t.Errorf("lock-free SearchAppend allocated %v times per query", allocs)
+	}
+}
+
+func BenchmarkSearchAppend(b *testing.B) {
+	qt := newRandomTree(200000, 1)
+	area := Bounds{X: 100, Y: 100, Width: 100, Height: 100}
+	buf := make([]Point, 0, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = qt.SearchAppend(area, buf[:0])
+	}
+}
@@ -0,0 +1,140 @@
+package spatial
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// DefaultArenaBlock is the number of points in each arena block when
+// WithArena is given 0
+const DefaultArenaBlock = 16384
+
+// arenaClasses is the number of leaf size classes, powers of two from 1 to
+// 1<<(arenaClasses-1). Larger leaves are allocated on the heap as usual.
+const arenaClasses = 11
+
+// WithArena carves leaf storage out of large blocks of blockPoints points
+// instead of allocating every leaf's arrays separately. Leaves get power-of-two
+// capacities, and the arrays of split, merged and released leaves are
+// recycled by size, so a tree under sustained churn stops fragmenting the heap
+// with small arrays. Clear and Compact start over with fresh blocks and let the
+// old ones be collected. Queries still return copies, so results never share
+// memory with the arena; only Node.Points itself does, and it is reused as
+// soon as the leaf changes. Nodes shared with a snapshot are never recycled.
+func WithArena(blockPoints int) Option {
+	return func(qt *QuadTree) {
+		if blockPoints == 0 {
+			blockPoints = DefaultArenaBlock
+		}
+		qt.Root.arena = newArena(blockPoints)
+	}
+}
+
+// arena hands out leaf arrays. Quadrant writers allocate concurrently, so its
+// own mutex guards it.
+type arena struct {
+	mu     sync.Mutex
+	block  int
+	points []Point // Unused tail of the current block
+	free   [arenaClasses][][]Point
+	blocks int // Blocks carved so far
+}
+
+func newArena(block int) *arena {
+	return &arena{block: block}
+}
+
+// fresh returns an empty arena with the same block size, for a rebuilt tree
+// whose old blocks should be collected. Safe on a nil arena.
+func (a *arena) fresh() *arena {
+	if a == nil {
+		return nil
+	}
+	return newArena(a.block)
+}
+
+// sizeClass returns the class holding c points, or false if c is too large
+// for the arena
+func (a *arena) sizeClass(c int) (int, bool) {
+	class := bits.Len(uint(max(c, 1) - 1))
+	return class, class < arenaClasses && 1<<class <= a.block
+}
+
+// alloc returns an empty leaf array with room for at least c points. Safe on
+// a nil arena, which allocates exactly c on the heap.
+func (a *arena) alloc(c int) []Point {
+	class, ok := 0, false
+	if a != nil {
+		class, ok = a.sizeClass(c)
+	}
+	if !ok {
+		return make([]Point, 0, c)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if free := a.free[class]; len(free) > 0 {
+		points := free[len(free)-1]
+		a.free[class] = free[:len(free)-1]
+		return points
+	}
+	size := 1 << class
+	if len(a.points) < size {
+		a.points = make([]Point, a.block)
+		a.blocks++
+	}
+	points := a.points[:0:size]
+	a.points = a.points[size:]
+	return points
+}
+
+// release takes back a leaf's array for reuse, clearing the points so they
+// hold no references. Arrays that did not come from alloc, such as ones grown
+// by append, are left to the garbage collector. Safe on a nil arena.
+func (a *arena) release(points []Point) {
+	if a == nil {
+		return
+	}
+	size := cap(points)
+	class, ok := a.sizeClass(size)
+	if !ok || size != 1<<class {
+		return
+	}
+	clear(points)
+	a.mu.Lock()
+	a.free[class] = append(a.free[class], points[:0])
+	a.mu.Unlock()
+}
+
+// spareBytes is the room the arena holds but no leaf uses
+func (a *arena) spareBytes() int64 {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	spare := int64(len(a.points)) * pointBytes
+	for class, free := range a.free {
+		spare += int64(len(free)) * (1 << class) * pointBytes
+	}
+	return spare
+}
+
+// useArray makes points the leaf's storage, handing its previous array back
+// to the arena
+func (n *Node) useArray(points []Point) {
+	n.arena.release(n.Points)
+	n.Points = points
+}
+
+// grow moves the leaf's points into an array with room for c points
+func (n *Node) grow(c int) {
+	n.useArray(append(n.arena.alloc(c), n.Points...))
+}
+
+// dropArray hands the leaf's array back to the arena, leaving it without
+// storage. Without an arena the array is simply dropped.
+func (n *Node) dropArray() {
+	n.arena.release(n.Points)
+	n.Points = nil
+}
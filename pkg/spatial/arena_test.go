@@ -0,0 +1,158 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestArenaMatchesHeap replays random churn against an arena tree and a heap
+// twin and checks they store the same points and stay valid
+func TestArenaMatchesHeap(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+	withArena := mustNewQuadTree(bounds, WithCapacity(3), WithArena(64))
+	plain := mustNewQuadTree(bounds, WithCapacity(3))
+	rng := rand.New(rand.NewSource(1))
+	var snaps []*QuadTree
+
+	for step := 0; step < 20000; step++ {
+		id := fmt.Sprintf("v%d", rng.Intn(500))
+		x, y := rng.Float64()*1000, rng.Float64()*1000
+		switch op := rng.Intn(100); {
+		case op < 40:
+			withArena.Upsert(id, Point{X: x, Y: y})
+			plain.Upsert(id, Point{X: x, Y: y})
+		case op < 60:
+			withArena.Move(id, x, y)
+			plain.Move(id, x, y)
+		case op < 95:
+			withArena.RemoveByID(id)
+			plain.RemoveByID(id)
+		case op < 97:
+			// Co-located points overflow leaves at the depth cap, growing them
+			for i := 0; i < 40; i++ {
+				withArena.Insert(Point{X: 500, Y: 500})
+				plain.Insert(Point{X: 500, Y: 500})
+			}
+		case op < 98:
+			snaps = append(snaps, withArena.Snapshot())
+		case op < 99:
+			withArena.Compact()
+			plain.Compact()
+		default:
+			if step%10 == 0 {
+				withArena.Clear()
+				plain.Clear()
+			}
+		}
+
+		if step%500 != 0 {
+			continue
+		}
+		if errs := withArena.Validate(); len(errs) > 0 {
+			t.Fatalf("Step %d: %v", step, errs[0])
+		}
+		if got, want := withArena.Search(bounds), plain.Search(bounds); !samePoints(got, want) {
+			t.Fatalf("Step %d: arena tree holds %d points, want %d", step, len(got), len(want))
+		}
+	}
+	if len(snaps) == 0 {
+		t.Fatal("Expected some snapshots")
+	}
+}
+
+// TestArenaResultsAreCopies tests that neither query results nor snapshots
+// see leaf arrays being recycled by later writes
+func TestArenaResultsAreCopies(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	qt := mustNewQuadTree(bounds, WithCapacity(2), WithArena(16))
+	for i := 0; i < 50; i++ {
+		qt.InsertWithID(fmt.Sprint(i), Point{X: float64(i * 2), Y: float64(i * 2), Data: i})
+	}
+	results := qt.Search(bounds)
+	kept := append([]Point(nil), results...)
+	snap := qt.Snapshot()
+	fromSnap := snap.Search(bounds)
+
+	// Splits, merges and moves recycle every array the tree had
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		qt.Move(fmt.Sprint(rng.Intn(50)), rng.Float64()*100, rng.Float64()*100)
+	}
+	qt.Clear()
+	for i := 0; i < 50; i++ {
+		qt.Insert(Point{X: rng.Float64() * 100, Y: rng.Float64() * 100, Data: "new"})
+	}
+
+	if !samePoints(results, kept) {
+		t.Error("Expected earlier results to be unaffected by later writes")
+	}
+	if got := snap.Search(bounds); !samePoints(got, fromSnap) {
+		t.Error("Expected the snapshot to be unaffected by later writes")
+	}
+}
+
+// TestArenaRecycles tests that steady churn runs on recycled arrays instead
+// of carving new blocks, and that Clear starts over
+func TestArenaRecycles(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4), WithArena(256))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		qt.InsertWithID(fmt.Sprint(i), Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+	warm := qt.Root.arena.blocks
+	for i := 0; i < 50000; i++ {
+		id := fmt.Sprint(rng.Intn(2000))
+		qt.RemoveByID(id)
+		qt.InsertWithID(id, Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+	if got := qt.Root.arena.blocks; got > warm+warm/4 {
+		t.Errorf("Expected churn to reuse arrays, blocks grew from %d to %d", warm, got)
+	}
+
+	qt.Clear()
+	if got := qt.Root.arena.blocks; got != 0 {
+		t.Errorf("Expected Clear to drop every block, %d left", got)
+	}
+	if _, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 1, Height: 1}, WithArena(-1)); !errors.Is(err, ErrInvalidArena) {
+		t.Errorf("Expected ErrInvalidArena, got %v", err)
+	}
+}
+
+func BenchmarkChurnArena(b *testing.B) { benchmarkChurn(b, WithArena(0)) }
+func BenchmarkChurnHeap(b *testing.B)  { benchmarkChurn(b) }
+
+// benchmarkChurn keeps 200k couriers moving far enough to change leaves,
+// reporting the heap objects the tree leaves behind and the GC pause time
+// per operation
+func benchmarkChurn(b *testing.B, opts ...Option) {
+	const n = 200000
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, append(opts, WithCapacity(8))...)
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+		qt.InsertWithID(ids[i], Point{X: rng.Float64() * 10000, Y: rng.Float64() * 10000})
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id := ids[rng.Intn(n)]
+		qt.RemoveByID(id)
+		qt.InsertWithID(id, Point{X: rng.Float64() * 10000, Y: rng.Float64() * 10000})
+	}
+	b.StopTimer()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.HeapObjects)/1e6, "Mobjects")
+	b.ReportMetric(float64(after.HeapInuse)/(1<<20), "heap-MB")
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/elapsed.Seconds(), "gc/s")
+	runtime.KeepAlive(qt)
+}
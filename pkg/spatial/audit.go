@@ -0,0 +1,142 @@
+package spatial
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAuditBuffer is how many audit entries may wait for the sink before
+// new ones are dropped, unless WithAuditBuffer says otherwise.
+const DefaultAuditBuffer = 1024
+
+// AuditOp names the kind of mutation an AuditEntry records
+type AuditOp string
+
+const (
+	AuditInsert AuditOp = "insert"
+	AuditRemove AuditOp = "remove"
+	AuditMove   AuditOp = "move"
+)
+
+// AuditEntry records one point mutation. Before is nil for inserts and After
+// is nil for removals. ID is empty for points stored without one.
+type AuditEntry struct {
+	Time   time.Time   `json:"time"`
+	Op     AuditOp     `json:"op"`
+	ID     string      `json:"id,omitempty"`
+	Before *AuditCoord `json:"before,omitempty"`
+	After  *AuditCoord `json:"after,omitempty"`
+}
+
+// AuditCoord is a point's position in an AuditEntry
+type AuditCoord struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Sink receives audit entries. Write is only ever called from the tree's
+// audit goroutine, one entry at a time, in mutation order.
+type Sink interface {
+	Write(entry AuditEntry) error
+}
+
+// WithAuditSink records every insert, removal and move in sink. Entries are
+// stamped with the tree's clock under the write lock and handed to sink on a
+// separate goroutine, so a slow sink never stalls writers. When more than the
+// audit buffer's worth of entries are waiting, new entries are dropped rather
+// than blocking the writer; DroppedAuditEntries counts them. Close flushes the
+// entries already queued.
+func WithAuditSink(sink Sink) Option {
+	return func(qt *QuadTree) {
+		qt.auditSink = sink
+	}
+}
+
+// WithAuditBuffer sets how many audit entries may be queued for the sink
+func WithAuditBuffer(n int) Option {
+	return func(qt *QuadTree) {
+		qt.auditBuffer = n
+	}
+}
+
+// auditQueue carries entries from writers to the sink goroutine. Entries are
+// only emitted under the tree's write lock, which also guards closed.
+type auditQueue struct {
+	sink    Sink
+	entries chan AuditEntry
+	done    chan struct{}
+	closed  bool
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+func newAuditQueue(sink Sink, buffer int) *auditQueue {
+	a := &auditQueue{
+		sink:    sink,
+		entries: make(chan AuditEntry, buffer),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *auditQueue) run() {
+	defer close(a.done)
+	for e := range a.entries {
+		if a.sink.Write(e) != nil {
+			a.failed.Add(1)
+		}
+	}
+}
+
+// emit queues an entry for the move from before to after without blocking;
+// safe on a nil queue
+func (a *auditQueue) emit(qt *QuadTree, op AuditOp, before, after *Point) {
+	if a == nil || a.closed {
+		return
+	}
+	e := AuditEntry{Time: qt.clock(), Op: op}
+	if before != nil {
+		e.Before = &AuditCoord{X: before.X, Y: before.Y}
+		if before.loc != nil {
+			e.ID = before.loc.id
+		}
+	}
+	if after != nil {
+		e.After = &AuditCoord{X: after.X, Y: after.Y}
+		if after.loc != nil {
+			e.ID = after.loc.id
+		}
+	}
+	select {
+	case a.entries <- e:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// close stops accepting entries. Callers must hold the tree's write lock.
+func (a *auditQueue) close() {
+	if a == nil || a.closed {
+		return
+	}
+	a.closed = true
+	close(a.entries)
+}
+
+// DroppedAuditEntries returns how many audit entries were dropped because the
+// audit buffer was full.
+func (qt *QuadTree) DroppedAuditEntries() uint64 {
+	if qt.audit == nil {
+		return 0
+	}
+	return qt.audit.dropped.Load()
+}
+
+// FailedAuditEntries returns how many audit entries the sink returned an error for
+func (qt *QuadTree) FailedAuditEntries() uint64 {
+	if qt.audit == nil {
+		return 0
+	}
+	return qt.audit.failed.Load()
+}
@@ -0,0 +1,152 @@
+package spatial
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAuditRecordsMutations tests the entries written for inserts, moves and removals
+func TestAuditRecordsMutations(t *testing.T) {
+	clock := newFakeClock()
+	ring := NewRingSink(10)
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithClock(clock.Now), WithAuditSink(ring))
+
+	qt.Insert(Point{X: 1, Y: 2})
+	clock.Advance(time.Second)
+	qt.InsertWithID("driver-7", Point{X: 10, Y: 10})
+	qt.UpdateByID("driver-7", Point{X: 20, Y: 30})
+	qt.RemoveByID("driver-7")
+	qt.Insert(Point{X: 500, Y: 500})
+	qt.Close()
+
+	got := ring.Entries()
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 entries, got %d: %v", len(got), got)
+	}
+	if got[0].Op != AuditInsert || got[0].Before != nil || *got[0].After != (AuditCoord{X: 1, Y: 2}) || got[0].ID != "" {
+		t.Errorf("Unexpected insert entry %+v", got[0])
+	}
+	if !got[1].Time.Equal(clock.Now()) || got[0].Time.Equal(got[1].Time) {
+		t.Errorf("Expected entries stamped with the tree's clock, got %v and %v", got[0].Time, got[1].Time)
+	}
+	if got[2].Op != AuditMove || got[2].ID != "driver-7" || *got[2].Before != (AuditCoord{X: 10, Y: 10}) || *got[2].After != (AuditCoord{X: 20, Y: 30}) {
+		t.Errorf("Unexpected move entry %+v", got[2])
+	}
+	if got[3].Op != AuditRemove || got[3].ID != "driver-7" || got[3].After != nil {
+		t.Errorf("Unexpected remove entry %+v", got[3])
+	}
+}
+
+// blockingSink holds every Write until release is closed
+type blockingSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	written int
+}
+
+func (s *blockingSink) Write(AuditEntry) error {
+	<-s.release
+	s.mu.Lock()
+	s.written++
+	s.mu.Unlock()
+	return nil
+}
+
+// TestAuditDropsWhenFull tests that a stalled sink never blocks writers and overflow is counted
+func TestAuditDropsWhenFull(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithAuditSink(sink), WithAuditBuffer(4))
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 20; i++ {
+			qt.Insert(Point{X: float64(i), Y: float64(i)})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Inserts blocked on a stalled sink")
+	}
+
+	// One entry may already be held by the sink goroutine, the buffer holds 4 more
+	if dropped := qt.DroppedAuditEntries(); dropped != 15 && dropped != 16 {
+		t.Errorf("Expected 15 or 16 dropped entries, got %d", dropped)
+	}
+	close(sink.release)
+	qt.Close()
+	if sink.written+int(qt.DroppedAuditEntries()) != 20 {
+		t.Errorf("Expected written and dropped to add up to 20, got %d and %d", sink.written, qt.DroppedAuditEntries())
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Write(AuditEntry) error { return errors.New("disk full") }
+
+// TestAuditCountsSinkFailures tests that sink errors are counted
+func TestAuditCountsSinkFailures(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithAuditSink(failingSink{}))
+	qt.Insert(Point{X: 1, Y: 1})
+	qt.Insert(Point{X: 2, Y: 2})
+	qt.Close()
+	if qt.FailedAuditEntries() != 2 {
+		t.Errorf("Expected 2 failed entries, got %d", qt.FailedAuditEntries())
+	}
+}
+
+// TestJSONLSink tests that the file sink appends one JSON object per line
+func TestJSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := OpenJSONLSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithAuditSink(sink))
+	qt.InsertWithID("a", Point{X: 1, Y: 1})
+	qt.Apply([]Op{
+		{Kind: OpUpsert, ID: "a", Point: Point{X: 2, Y: 2}},
+		{Kind: OpInsert, Point: Point{X: 3, Y: 3}},
+	})
+	qt.Close()
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var ops []AuditOp
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("Line %q is not an entry: %v", scanner.Text(), err)
+		}
+		ops = append(ops, e.Op)
+	}
+	if len(ops) != 3 || ops[0] != AuditInsert || ops[1] != AuditMove || ops[2] != AuditInsert {
+		t.Errorf("Expected insert, move, insert, got %v", ops)
+	}
+}
+
+// TestRingSinkKeepsLatest tests that the ring overwrites its oldest entries
+func TestRingSinkKeepsLatest(t *testing.T) {
+	ring := NewRingSink(3)
+	for i := 0; i < 5; i++ {
+		ring.Write(AuditEntry{ID: string(rune('a' + i))})
+	}
+	got := ring.Entries()
+	if len(got) != 3 || got[0].ID != "c" || got[1].ID != "d" || got[2].ID != "e" {
+		t.Errorf("Expected c, d, e, got %v", got)
+	}
+}
@@ -0,0 +1,83 @@
+package spatial
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// JSONLSink writes each audit entry as one line of JSON
+type JSONLSink struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLSink returns a sink writing JSON lines to w
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{w: w, enc: json.NewEncoder(w)}
+}
+
+// OpenJSONLSink returns a sink appending JSON lines to the file at path,
+// creating it if needed. Close closes the file.
+func OpenJSONLSink(path string) (*JSONLSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLSink(f), nil
+}
+
+func (s *JSONLSink) Write(entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(entry)
+}
+
+// Close closes the underlying writer if it is an io.Closer. Close the tree
+// first so no entries are still queued for the sink.
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// RingSink keeps the most recent audit entries in memory
+type RingSink struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	next    int // Slot the next entry goes into once the ring is full
+}
+
+// NewRingSink returns a sink keeping the last n entries
+func NewRingSink(n int) *RingSink {
+	return &RingSink{entries: make([]AuditEntry, 0, n)}
+}
+
+func (s *RingSink) Write(entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cap(s.entries) == 0 {
+		return nil
+	}
+	if len(s.entries) < cap(s.entries) {
+		s.entries = append(s.entries, entry)
+		return nil
+	}
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % len(s.entries)
+	return nil
+}
+
+// Entries returns the retained entries, oldest first
+func (s *RingSink) Entries() []AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]AuditEntry, 0, len(s.entries))
+	out = append(out, s.entries[s.next:]...)
+	return append(out, s.entries[:s.next]...)
+}
@@ -0,0 +1,55 @@
+package spatial
+
+// OpKind selects what an Op does
+type OpKind uint8
+
+const (
+	// OpInsert inserts Point, like InsertE
+	OpInsert OpKind = iota
+	// OpRemove removes Point, like RemoveE
+	OpRemove
+	// OpUpdate moves Old to Point, like UpdateE
+	OpUpdate
+	// OpUpsert stores Point under ID, like Upsert
+	OpUpsert
+)
+
+// Op is one mutation in a batch passed to Apply
+type Op struct {
+	Kind  OpKind
+	Point Point  // Point to insert, remove or upsert, or the destination of an update
+	Old   Point  // Point to move, used by OpUpdate
+	ID    string // Key used by OpUpsert
+}
+
+// Apply runs batch in order under a single acquisition of the write lock, so
+// readers see either none of the batch or all of it. errs is index-aligned
+// with batch and holds what the matching single call would have returned;
+// a failed op is skipped and does not undo the ops before it. Unknown kinds
+// get ErrUnknownOp.
+func (qt *QuadTree) Apply(batch []Op) (errs []error) {
+	errs = make([]error, len(batch))
+	qt.Lock.Lock()
+	defer qt.unlock()
+	// The whole batch is one version
+	if !qt.readOnly {
+		qt.beginVersion()
+	}
+	qt.batch = true
+	defer func() { qt.batch = false }()
+	for i, op := range batch {
+		switch op.Kind {
+		case OpInsert:
+			errs[i] = qt.insertPoint(op.Point)
+		case OpRemove:
+			_, errs[i] = qt.take(op.Point)
+		case OpUpdate:
+			errs[i] = qt.update(op.Old, op.Point)
+		case OpUpsert:
+			_, errs[i] = qt.upsert(op.ID, op.Point)
+		default:
+			errs[i] = ErrUnknownOp
+		}
+	}
+	return errs
+}
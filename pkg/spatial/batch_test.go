@@ -0,0 +1,133 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestApplyErrorsIndexAligned tests that each op's error lands at its index
+func TestApplyErrorsIndexAligned(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+	qt.Insert(Point{X: 10, Y: 10, Data: "a"})
+
+	errs := qt.Apply([]Op{
+		{Kind: OpInsert, Point: Point{X: 20, Y: 20, Data: "b"}},
+		{Kind: OpInsert, Point: Point{X: 200, Y: 20}},
+		{Kind: OpRemove, Point: Point{X: 10, Y: 10, Data: "a"}},
+		{Kind: OpRemove, Point: Point{X: 10, Y: 10, Data: "a"}},
+		{Kind: OpUpdate, Old: Point{X: 20, Y: 20, Data: "b"}, Point: Point{X: 30, Y: 30, Data: "b"}},
+		{Kind: OpUpsert, ID: "c", Point: Point{X: 40, Y: 40}},
+		{Kind: OpUpsert, ID: "c", Point: Point{X: 50, Y: 50}},
+		{Kind: OpKind(99)},
+	})
+
+	want := []error{nil, ErrOutOfBounds, nil, ErrNotFound, nil, nil, nil, ErrUnknownOp}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %d", len(want), len(errs))
+	}
+	for i := range want {
+		if !errors.Is(errs[i], want[i]) {
+			t.Errorf("Op %d: expected %v, got %v", i, want[i], errs[i])
+		}
+	}
+
+	if qt.Size() != 2 {
+		t.Errorf("Expected 2 points, got %d", qt.Size())
+	}
+	if p, ok := qt.GetByID("c"); !ok || p.X != 50 {
+		t.Errorf("Expected c at (50, 50), got %v, %v", p, ok)
+	}
+	if len(qt.Search(Bounds{X: 30, Y: 30, Width: 0, Height: 0})) != 1 {
+		t.Error("Expected the updated point at (30, 30)")
+	}
+	checkValid(t, qt)
+}
+
+// TestApplyReadOnly tests that every op on a snapshot fails with ErrReadOnly
+func TestApplyReadOnly(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	snap := qt.Snapshot()
+
+	for i, err := range snap.Apply([]Op{
+		{Kind: OpInsert, Point: Point{X: 1, Y: 1}},
+		{Kind: OpUpsert, ID: "a", Point: Point{X: 1, Y: 1}},
+	}) {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Op %d: expected ErrReadOnly, got %v", i, err)
+		}
+	}
+}
+
+// TestApplyAtomicForReaders tests that readers never see part of a batch
+func TestApplyAtomicForReaders(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4))
+	const n = 500
+	west := Bounds{X: 0, Y: 0, Width: 499, Height: 1000}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for tick := 0; tick < 20; tick++ {
+			// Every tick moves the whole fleet to the other half
+			x := 100.0
+			if tick%2 == 1 {
+				x = 800
+			}
+			batch := make([]Op, n)
+			for i := range batch {
+				batch[i] = Op{Kind: OpUpsert, ID: fmt.Sprintf("v%d", i), Point: Point{X: x + float64(i%100), Y: float64(i)}}
+			}
+			qt.Apply(batch)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if got := len(qt.Search(west)); got != 0 && got != n {
+				t.Errorf("Reader saw %d of %d points in the west half", got, n)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	checkValid(t, qt)
+}
+
+func benchmarkUpsertOps(n int) []Op {
+	ops := make([]Op, n)
+	for i := range ops {
+		ops[i] = Op{Kind: OpUpsert, ID: fmt.Sprintf("v%d", i), Point: Point{X: float64(i % 1000), Y: float64(i / 10)}}
+	}
+	return ops
+}
+
+func BenchmarkUpsertSingle10k(b *testing.B) {
+	ops := benchmarkUpsertOps(10000)
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, op := range ops {
+			qt.Upsert(op.ID, op.Point)
+		}
+	}
+}
+
+func BenchmarkApplyUpsert10k(b *testing.B) {
+	ops := benchmarkUpsertOps(10000)
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt.Apply(ops)
+	}
+}
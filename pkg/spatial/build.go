@@ -0,0 +1,51 @@
+package spatial
+
+import "fmt"
+
+// BuildQuadTree bulk-loads points into a new tree covering bounds. The batch
+// is split into quadrants in one pass (see InsertAll), so the shape depends
+// only on the point set, not its order, and sorted input doesn't produce the
+// skewed subdivisions of an Insert loop. Sequence numbers follow input order.
+//
+// Points that can't be stored are reported in a *RejectedPointsError; the
+// returned tree still holds every other point.
+func BuildQuadTree(bounds Bounds, capacity int, points []Point, opts ...Option) (*QuadTree, error) {
+	qt, err := NewQuadTree(bounds, append([]Option{WithCapacity(capacity)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	if _, rejected := qt.InsertAll(points); len(rejected) > 0 {
+		return qt, &RejectedPointsError{Points: rejected}
+	}
+	return qt, nil
+}
+
+// RejectedPointsError lists the points a bulk load could not store. It
+// matches ErrOutOfBounds and/or ErrInvalidPoint with errors.Is, depending on
+// why points were rejected.
+type RejectedPointsError struct {
+	Points []Point
+}
+
+func (e *RejectedPointsError) Error() string {
+	return fmt.Sprintf("spatial: %d points rejected", len(e.Points))
+}
+
+func (e *RejectedPointsError) Unwrap() []error {
+	var invalid, outside bool
+	for _, p := range e.Points {
+		if validCoordinates(p) {
+			outside = true
+		} else {
+			invalid = true
+		}
+	}
+	var errs []error
+	if outside {
+		errs = append(errs, ErrOutOfBounds)
+	}
+	if invalid {
+		errs = append(errs, ErrInvalidPoint)
+	}
+	return errs
+}
@@ -0,0 +1,117 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// shape lists every node's bounds and point count in depth-first order
+func shape(n *Node, dst *[]string) {
+	*dst = append(*dst, fmt.Sprintf("%v:%d:%d", n.Bounds, len(n.Points), n.count))
+	if n.Children[0] != nil {
+		for _, c := range n.Children {
+			shape(c, dst)
+		}
+	}
+}
+
+// TestBuildQuadTreeOrderIndependent tests that shuffled and sorted input build the same tree
+func TestBuildQuadTreeOrderIndependent(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+	points := benchmarkPoints(5000)
+	for i := range points {
+		points[i].X /= 10
+		points[i].Y /= 10
+	}
+	sorted := append([]Point(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].X < sorted[j].X })
+
+	a, err := BuildQuadTree(bounds, 8, points)
+	if err != nil {
+		t.Fatalf("BuildQuadTree failed: %v", err)
+	}
+	b, _ := BuildQuadTree(bounds, 8, sorted)
+
+	var shapeA, shapeB []string
+	shape(a.Root, &shapeA)
+	shape(b.Root, &shapeB)
+	if !reflect.DeepEqual(shapeA, shapeB) {
+		t.Error("Input order changed the tree's shape")
+	}
+	if !Equal(a, b) || a.Size() != len(points) {
+		t.Errorf("Expected both trees to hold the same %d points", len(points))
+	}
+}
+
+// TestBuildQuadTreeRejected tests that unstorable points are reported with typed errors
+func TestBuildQuadTreeRejected(t *testing.T) {
+	points := []Point{{X: 10, Y: 10}, {X: 200, Y: 10}, {X: math.NaN(), Y: 1}, {X: 50, Y: 50}}
+	qt, err := BuildQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, 4, points)
+
+	var rejected *RejectedPointsError
+	if !errors.As(err, &rejected) || len(rejected.Points) != 2 {
+		t.Fatalf("Expected 2 rejected points, got %v", err)
+	}
+	if !errors.Is(err, ErrOutOfBounds) || !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("Expected the error to match both reasons, got %v", err)
+	}
+	if qt == nil || qt.Size() != 2 {
+		t.Error("Accepted points should still be loaded")
+	}
+
+	if _, err := BuildQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, 0, points); !errors.Is(err, ErrInvalidCapacity) {
+		t.Errorf("Expected ErrInvalidCapacity, got %v", err)
+	}
+}
+
+// sortedBenchmarkPoints returns n points sorted by X, the worst case for an Insert loop
+func sortedBenchmarkPoints(n int) []Point {
+	points := benchmarkPoints(n)
+	sort.Slice(points, func(i, j int) bool { return points[i].X < points[j].X })
+	return points
+}
+
+func BenchmarkBuildQuadTree1M(b *testing.B) {
+	points := sortedBenchmarkPoints(1000000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, 10, points)
+	}
+}
+
+func BenchmarkInsertLoop1M(b *testing.B) {
+	points := sortedBenchmarkPoints(1000000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, WithCapacity(10))
+		for _, p := range points {
+			qt.Insert(p)
+		}
+	}
+}
+
+func benchmarkKNearest(b *testing.B, qt *QuadTree) {
+	rng := rand.New(rand.NewSource(9))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt.KNearest(Point{X: rng.Float64() * 10000, Y: rng.Float64() * 10000}, 10)
+	}
+}
+
+func BenchmarkKNearestBuilt1M(b *testing.B) {
+	qt, _ := BuildQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, 10, sortedBenchmarkPoints(1000000))
+	benchmarkKNearest(b, qt)
+}
+
+func BenchmarkKNearestInserted1M(b *testing.B) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, WithCapacity(10))
+	for _, p := range sortedBenchmarkPoints(1000000) {
+		qt.Insert(p)
+	}
+	benchmarkKNearest(b, qt)
+}
@@ -0,0 +1,262 @@
+package spatial
+
+import (
+	"math"
+	"sort"
+)
+
+// InsertAll inserts a batch of points in one pass over the tree instead of
+// descending from the root for every point. Points outside the root bounds,
+// with invalid coordinates, or past the WithMaxPoints limit are returned in
+// rejected, in batch order.
+func (qt *QuadTree) InsertAll(points []Point) (inserted int, rejected []Point) {
+	inserted, rejections := qt.insertAll(points)
+	for _, r := range rejections {
+		rejected = append(rejected, points[r.idx])
+	}
+	return inserted, rejected
+}
+
+// bulkRejection is a batch point insertAll did not store, and why
+type bulkRejection struct {
+	idx int
+	err error
+}
+
+// insertAll is InsertAll reporting rejected points by batch index, in batch
+// order, with the error a single insert would have returned
+func (qt *QuadTree) insertAll(points []Point) (inserted int, rejected []bulkRejection) {
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.own() {
+		for i := range points {
+			rejected = append(rejected, bulkRejection{idx: i, err: ErrReadOnly})
+		}
+		return 0, rejected
+	}
+
+	// Rather than partitioning the batch at every level, compute each point's
+	// quadrant path once, radix-sort by it, and hand every subtree the
+	// contiguous run of keys that routes into it.
+	if qt.autoExpand {
+		// Grow the root up front, since the quadrant paths are relative to it
+		for _, p := range points {
+			qt.place(p)
+		}
+	}
+	load := &bulkLoad{
+		points:    points,
+		assignSeq: true,
+		baseSeq:   qt.nextSeq,
+	}
+	keys, rejected := qt.Root.sortedKeys(load)
+	if qt.maxPoints > 0 && qt.size+len(keys) > qt.maxPoints {
+		keys, rejected = limitKeys(keys, rejected, qt.maxPoints-qt.size)
+	}
+	qt.Root.insertSorted(load, keys, 0)
+	if len(keys) > 0 {
+		qt.changed()
+	}
+	if qt.observing(insertEvent) {
+		// Report in batch order rather than tree order
+		sort.Slice(keys, func(i, j int) bool { return keys[i].idx < keys[j].idx })
+		for _, k := range keys {
+			qt.inserted(load.point(k.idx))
+		}
+	}
+	// Sequence numbers follow input order, as if the points had been inserted one by one
+	qt.nextSeq += uint64(len(points))
+	qt.size += len(keys)
+	sort.Slice(rejected, func(i, j int) bool { return rejected[i].idx < rejected[j].idx })
+	return len(keys), rejected
+}
+
+// limitKeys keeps the keys of the first room points in batch order, moving
+// the rest into rejected with ErrTreeFull.
+func limitKeys(keys []bulkKey, rejected []bulkRejection, room int) ([]bulkKey, []bulkRejection) {
+	if room < 0 {
+		room = 0
+	}
+	if room >= len(keys) {
+		return keys, rejected
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].idx < keys[j].idx })
+	for _, k := range keys[room:] {
+		rejected = append(rejected, bulkRejection{idx: int(k.idx), err: ErrTreeFull})
+	}
+	keys = keys[:room]
+	// insertSorted needs the keys back in quadrant order
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].code < keys[j].code })
+	return keys, rejected
+}
+
+type bulkLoad struct {
+	points    []Point
+	assignSeq bool   // Give each point a fresh sequence number, false to keep existing identity
+	baseSeq   uint64 // Sequence number before the first point in the batch
+	levels    int    // Quadrant digits encoded in each key
+}
+
+// point copies the batch point at idx, assigning its sequence number if needed
+func (l *bulkLoad) point(idx int32) Point {
+	p := l.points[idx]
+	if l.assignSeq {
+		p.seq = l.baseSeq + uint64(idx) + 1
+		p.loc = nil
+		p.expires = 0
+	}
+	return p
+}
+
+// sortedKeys encodes the quadrant path of every batch point inside n and sorts
+// the keys, rejecting the points that fall outside or are invalid.
+func (n *Node) sortedKeys(load *bulkLoad) (keys []bulkKey, rejected []bulkRejection) {
+	load.levels = bulkLevels(len(load.points), n.Capacity, n.maxDepth())
+	keys = make([]bulkKey, 0, len(load.points))
+	for i, p := range load.points {
+		if !n.Bounds.Contains(p) {
+			err := ErrOutOfBounds
+			if !validCoordinates(p) {
+				err = ErrInvalidPoint
+			}
+			rejected = append(rejected, bulkRejection{idx: i, err: err})
+			continue
+		}
+		keys = append(keys, bulkKey{code: n.Bounds.quadrantPath(p, load.levels), idx: int32(i)})
+	}
+	radixSortKeys(keys, 2*load.levels)
+	return keys, rejected
+}
+
+// maxBulkLevels is how many quadrant digits fit in a bulkKey code
+const maxBulkLevels = 32
+
+// bulkLevels picks how many quadrant digits to encode: enough for a uniform
+// batch to reach leaves, plus slack for clustering. Deeper subtrees fall back
+// to per-point insertion.
+func bulkLevels(n, capacity, maxDepth int) int {
+	if capacity < 1 {
+		capacity = 1
+	}
+	levels := 2
+	for cells := 1; cells*capacity < n; cells *= 4 {
+		levels++
+	}
+	if levels > maxDepth {
+		levels = maxDepth
+	}
+	if levels > maxBulkLevels {
+		levels = maxBulkLevels
+	}
+	return levels
+}
+
+type bulkKey struct {
+	code uint64 // Two bits per level, most significant digit is the root's quadrant
+	idx  int32  // Index into the batch
+}
+
+// quadrantPath encodes the quadrant chosen at each of the first levels levels
+// below b, using the same halving arithmetic as SubDivide and quadrant so the
+// code always agrees with how the tree routes the point.
+func (b Bounds) quadrantPath(p Point, levels int) uint64 {
+	var code uint64
+	for d := 0; d < levels; d++ {
+		w := b.Width / 2
+		h := b.Height / 2
+		// Branch-free on purpose: on random input the quadrant branches
+		// mispredict constantly, so the next origin is picked with a bit mask.
+		midX := b.X + w
+		midY := b.Y + h
+		qx := b2u(p.X >= midX)
+		qy := b2u(p.Y >= midY)
+		b = Bounds{
+			X:      selectFloat(qx, b.X, midX),
+			Y:      selectFloat(qy, b.Y, midY),
+			Width:  w,
+			Height: h,
+		}
+		code = code<<2 | qx | qy<<1
+	}
+	return code
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// selectFloat returns a when bit is 0 and b when bit is 1
+func selectFloat(bit uint64, a, b float64) float64 {
+	mask := -bit
+	ab := math.Float64bits(a)
+	return math.Float64frombits(ab ^ ((ab ^ math.Float64bits(b)) & mask))
+}
+
+// radixSortKeys sorts keys by the low bits of their code, 8 bits per pass
+func radixSortKeys(keys []bulkKey, bits int) {
+	buf := make([]bulkKey, len(keys))
+	src, dst := keys, buf
+	for shift := 0; shift < bits; shift += 8 {
+		var counts [257]int
+		for _, k := range src {
+			counts[(k.code>>uint(shift))&0xff+1]++
+		}
+		for i := 1; i < len(counts); i++ {
+			counts[i] += counts[i-1]
+		}
+		for _, k := range src {
+			b := (k.code >> uint(shift)) & 0xff
+			dst[counts[b]] = k
+			counts[b]++
+		}
+		src, dst = dst, src
+	}
+	if len(keys) > 0 && &src[0] != &keys[0] {
+		copy(keys, src)
+	}
+}
+
+// insertSorted places the points referenced by keys, all inside n and sorted
+// by quadrant path. level is n's depth relative to where the codes start.
+func (n *Node) insertSorted(load *bulkLoad, keys []bulkKey, level int) {
+	if len(keys) == 0 {
+		return
+	}
+	if level >= load.levels {
+		// Codes are exhausted, so the rest of this subtree goes point by point
+		for _, k := range keys {
+			n.insert(load.point(k.idx))
+		}
+		return
+	}
+	n.count += len(keys)
+	if n.Children[0] == nil {
+		if !n.splits(len(n.Points) + len(keys)) {
+			if n.Points == nil {
+				n.reserve(len(keys))
+			}
+			for _, k := range keys {
+				p := load.point(k.idx)
+				if p.loc != nil {
+					p.loc.leaf = n
+				}
+				n.addPoint(p)
+			}
+			return
+		}
+		n.SubDivide()
+	}
+
+	shift := uint(2 * (load.levels - 1 - level))
+	start := 0
+	for q := 0; q < 4; q++ {
+		end := start + sort.Search(len(keys)-start, func(i int) bool {
+			return int((keys[start+i].code>>shift)&3) > q
+		})
+		n.writableChild(q).insertSorted(load, keys[start:end], level+1)
+		start = end
+	}
+}
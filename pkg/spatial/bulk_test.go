@@ -0,0 +1,154 @@
+package spatial
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestInsertAllMatchesSingleInserts tests that a bulk load stores the same points as individual inserts
+func TestInsertAllMatchesSingleInserts(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+	bulk := mustNewQuadTree(bounds, WithCapacity(4))
+	single := mustNewQuadTree(bounds, WithCapacity(4))
+
+	rng := rand.New(rand.NewSource(7))
+	points := make([]Point, 5000)
+	for i := range points {
+		points[i] = Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Data: i}
+	}
+
+	inserted, rejected := bulk.InsertAll(points)
+	if inserted != len(points) || len(rejected) != 0 {
+		t.Fatalf("Expected %d inserted and none rejected, got %d and %d", len(points), inserted, len(rejected))
+	}
+	for _, p := range points {
+		single.Insert(p)
+	}
+
+	for i := 0; i < 100; i++ {
+		area := Bounds{X: rng.Float64() * 900, Y: rng.Float64() * 900, Width: 100, Height: 100}
+		if a, b := len(bulk.Search(area)), len(single.Search(area)); a != b {
+			t.Fatalf("Search %v: bulk tree found %d, single-insert tree found %d", area, a, b)
+		}
+	}
+
+	// The caller's slice is left untouched
+	for i, p := range points {
+		if p.Data != i {
+			t.Fatal("InsertAll reordered the input slice")
+		}
+	}
+}
+
+// TestInsertAllRejectsOutOfBounds tests that out-of-bounds points are reported back
+func TestInsertAllRejectsOutOfBounds(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+
+	points := []Point{
+		{X: 10, Y: 10, Data: "in1"},
+		{X: 150, Y: 10, Data: "out1"},
+		{X: 50, Y: 50, Data: "in2"},
+		{X: -1, Y: 50, Data: "out2"},
+		{X: 100, Y: 100, Data: "in3"},
+	}
+
+	inserted, rejected := qt.InsertAll(points)
+	if inserted != 3 {
+		t.Errorf("Expected 3 inserted, got %d", inserted)
+	}
+	if len(rejected) != 2 || rejected[0].Data != "out1" || rejected[1].Data != "out2" {
+		t.Errorf("Expected out1 and out2 rejected, got %v", rejected)
+	}
+	if len(qt.Search(qt.Root.Bounds)) != 3 {
+		t.Error("Expected 3 points in the tree")
+	}
+}
+
+// TestInsertAllEmptyAndAllRejected tests batches that insert nothing
+func TestInsertAllEmptyAndAllRejected(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+
+	if inserted, rejected := qt.InsertAll(nil); inserted != 0 || len(rejected) != 0 {
+		t.Errorf("Expected nothing inserted or rejected, got %d and %d", inserted, len(rejected))
+	}
+	if inserted, rejected := qt.InsertAll([]Point{{X: -5, Y: -5}}); inserted != 0 || len(rejected) != 1 {
+		t.Errorf("Expected 1 rejected, got %d inserted and %d rejected", inserted, len(rejected))
+	}
+	if len(qt.Search(qt.Root.Bounds)) != 0 {
+		t.Error("Tree should still be empty")
+	}
+}
+
+// TestInsertAllIntoPopulatedTree tests bulk loading on top of existing points
+func TestInsertAllIntoPopulatedTree(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(3))
+	qt.Insert(Point{X: 5, Y: 5})
+	qt.Insert(Point{X: 95, Y: 95})
+
+	batch := make([]Point, 0)
+	for i := 0; i < 50; i++ {
+		batch = append(batch, Point{X: float64(i * 2), Y: float64(100 - i*2), Data: i})
+	}
+	qt.InsertAll(batch)
+
+	if len(qt.Search(qt.Root.Bounds)) != 52 {
+		t.Errorf("Expected 52 points, got %d", len(qt.Search(qt.Root.Bounds)))
+	}
+	for _, p := range batch {
+		if !qt.Remove(p) {
+			t.Errorf("Failed to remove bulk-loaded point %v", p.Data)
+		}
+	}
+}
+
+// TestInsertAllColocated tests bulk loading identical points against the depth cap
+func TestInsertAllColocated(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+
+	batch := make([]Point, 100)
+	for i := range batch {
+		batch[i] = Point{X: 33, Y: 33, Data: i}
+	}
+	inserted, _ := qt.InsertAll(batch)
+
+	if inserted != 100 || len(qt.Search(qt.Root.Bounds)) != 100 {
+		t.Errorf("Expected 100 co-located points, got %d", len(qt.Search(qt.Root.Bounds)))
+	}
+
+	// Sequence numbers follow input order, so the first batch point is removed first
+	qt.Remove(Point{X: 33, Y: 33})
+	for _, r := range qt.Search(qt.Root.Bounds) {
+		if r.Data == 0 {
+			t.Error("Expected the first batch point to be removed first")
+		}
+	}
+}
+
+func benchmarkPoints(n int) []Point {
+	rng := rand.New(rand.NewSource(1))
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = Point{X: rng.Float64() * 10000, Y: rng.Float64() * 10000}
+	}
+	return points
+}
+
+func BenchmarkInsertLoop500k(b *testing.B) {
+	points := benchmarkPoints(500000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, WithCapacity(10))
+		for _, p := range points {
+			qt.Insert(p)
+		}
+	}
+}
+
+func BenchmarkInsertAll500k(b *testing.B) {
+	points := benchmarkPoints(500000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, WithCapacity(10))
+		qt.InsertAll(points)
+	}
+}
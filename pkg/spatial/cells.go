@@ -0,0 +1,259 @@
+package spatial
+
+import (
+	"math/bits"
+	"slices"
+	"strconv"
+)
+
+// MaxCellLevel is the deepest level a CellID can name, cells 2^30 times
+// smaller than the root on each side
+const MaxCellLevel = 30
+
+// CellID names a cell of the quadtree grid over a root Bounds: the quadrant
+// taken at each level below the root, the digits QuadTree routes points by
+// (bit 0 set for the east half, bit 1 for the north half). The digits sit in
+// the high bits, followed by a single 1 bit and zeros, so sorting IDs puts
+// cells in Z-order with every cell's descendants in a contiguous range
+// after its first child. The zero CellID is invalid.
+type CellID uint64
+
+// rootCell is the CellID of the root, level 0
+const rootCell = CellID(1) << (2 * MaxCellLevel)
+
+// cellOf returns the CellID for the quadrant digits path at level
+func cellOf(path uint64, level int) CellID {
+	shift := 2 * (MaxCellLevel - level)
+	return CellID(path<<(shift+1) | 1<<shift)
+}
+
+// Valid reports whether c names a cell
+func (c CellID) Valid() bool {
+	return c != 0 && c <= rootCell<<1 && bits.TrailingZeros64(uint64(c))%2 == 0
+}
+
+// Level returns how many levels below the root c is
+func (c CellID) Level() int {
+	return MaxCellLevel - bits.TrailingZeros64(uint64(c))/2
+}
+
+// path returns c's quadrant digits, two bits per level
+func (c CellID) path() uint64 {
+	return uint64(c) >> (2*(MaxCellLevel-c.Level()) + 1)
+}
+
+// Parent returns the cell c is a quadrant of. The root is its own parent.
+func (c CellID) Parent() CellID {
+	if c.Level() == 0 {
+		return c
+	}
+	return cellOf(c.path()>>2, c.Level()-1)
+}
+
+// Children returns c's quadrants in digit order: south-west, south-east,
+// north-west, north-east. A cell at MaxCellLevel has none.
+func (c CellID) Children() []CellID {
+	level := c.Level()
+	if level == MaxCellLevel {
+		return nil
+	}
+	children := make([]CellID, 4)
+	for q := range children {
+		children[q] = cellOf(c.path()<<2|uint64(q), level+1)
+	}
+	return children
+}
+
+// Contains reports whether other is c or one of its descendants
+func (c CellID) Contains(other CellID) bool {
+	if other.Level() < c.Level() {
+		return false
+	}
+	return other.path()>>(2*(other.Level()-c.Level())) == c.path()
+}
+
+// String returns c's level and quadrant digits from the root, such as
+// "3/013"; the root is "0/"
+func (c CellID) String() string {
+	level := c.Level()
+	digits := make([]byte, level)
+	for i := range digits {
+		digits[i] = byte('0' + c.path()>>(2*(level-1-i))&3)
+	}
+	return strconv.Itoa(level) + "/" + string(digits)
+}
+
+// Cells is the grid of quadtree cells over Root, halved the way a QuadTree
+// over the same bounds subdivides, so a cell's bounds are the bounds of the
+// node at its path. Use a fixed Root for IDs that stay meaningful across
+// trees and processes, such as shard keys.
+type Cells struct {
+	Root Bounds
+}
+
+// Cells returns the grid over the tree's root bounds. A tree built
+// WithAutoExpand changes its root as it grows, and with it the grid.
+func (qt *QuadTree) Cells() Cells {
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	return Cells{Root: qt.Root.Bounds}
+}
+
+// CellBounds returns the bounds of c, or zero Bounds for an invalid c
+func (g Cells) CellBounds(c CellID) Bounds {
+	if !c.Valid() {
+		return Bounds{}
+	}
+	b := g.Root
+	path := c.path()
+	for l := c.Level() - 1; l >= 0; l-- {
+		b = quadrantBounds(b, path>>(2*l)&3)
+	}
+	return b
+}
+
+// quadrantBounds returns quadrant q of b, halved as quadrantPath halves it
+func quadrantBounds(b Bounds, q uint64) Bounds {
+	w, h := b.Width/2, b.Height/2
+	x, y := b.X, b.Y
+	if q&1 == 1 {
+		x = b.X + w
+	}
+	if q&2 == 2 {
+		y = b.Y + h
+	}
+	return Bounds{X: x, Y: y, Width: w, Height: h}
+}
+
+// CellContaining returns the cell at level, clamped to [0, MaxCellLevel],
+// that a QuadTree over Root would route p to. A p outside Root gets the
+// cell on the edge of the grid nearest it.
+func (g Cells) CellContaining(p Point, level int) CellID {
+	level = max(0, min(level, MaxCellLevel))
+	return cellOf(g.Root.quadrantPath(p, level), level)
+}
+
+// Cover returns at most maxCells cells, sorted, whose union contains the
+// part of area inside Root, and nothing if area misses Root. It starts
+// from the smallest cell containing area and splits the largest cell that
+// area only partly fills, lowest ID first among equals, as long as the
+// cells that area reaches into fit in maxCells and are no deeper than
+// maxLevel. Four siblings left in the covering are merged back into their
+// parent, so no covering holds more cells than it needs. maxCells below 1
+// is taken as 1 and maxLevel is clamped to [0, MaxCellLevel].
+func (g Cells) Cover(area Bounds, maxCells, maxLevel int) []CellID {
+	maxCells = max(1, maxCells)
+	maxLevel = max(0, min(maxLevel, MaxCellLevel))
+	if !g.Root.Intersects(area) {
+		return nil
+	}
+	area = intersection(area, g.Root)
+
+	// Descend while area needs only one child
+	start := rootCell
+	for start.Level() < maxLevel {
+		children := g.needed(start, area)
+		if len(children) != 1 {
+			break
+		}
+		start = children[0]
+	}
+
+	cells := []CellID{start}
+	frozen := make(map[CellID]bool) // Cells whose split wouldn't fit
+	for {
+		slices.SortFunc(cells, func(a, b CellID) int {
+			if a.Level() != b.Level() {
+				return a.Level() - b.Level()
+			}
+			return compareCells(a, b)
+		})
+		split := false
+		for i, c := range cells {
+			if frozen[c] || c.Level() >= maxLevel || area.containsBounds(g.CellBounds(c)) {
+				continue
+			}
+			children := g.needed(c, area)
+			if len(cells)-1+len(children) > maxCells {
+				frozen[c] = true
+				continue
+			}
+			cells = append(slices.Delete(cells, i, i+1), children...)
+			split = true
+			break
+		}
+		if !split {
+			break
+		}
+	}
+	return normalizeCells(cells)
+}
+
+// needed returns the children of c that area reaches into. Along an axis
+// where area has extent, touching a child's edge isn't reaching into it:
+// the points on that edge are also in the neighbor area does reach into.
+func (g Cells) needed(c CellID, area Bounds) []CellID {
+	var children []CellID
+	for _, child := range c.Children() {
+		b := g.CellBounds(child)
+		if overlaps(b.X, b.Width, area.X, area.Width) && overlaps(b.Y, b.Height, area.Y, area.Height) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// overlaps reports whether the closed interval [a, a+aw] meets [b, b+bw],
+// by more than a shared endpoint if bw is positive
+func overlaps(a, aw, b, bw float64) bool {
+	if bw > 0 {
+		return a < b+bw && b < a+aw
+	}
+	return a <= b && b <= a+aw
+}
+
+func intersection(a, b Bounds) Bounds {
+	x, y := max(a.X, b.X), max(a.Y, b.Y)
+	return Bounds{
+		X: x, Y: y,
+		Width:  min(a.X+a.Width, b.X+b.Width) - x,
+		Height: min(a.Y+a.Height, b.Y+b.Height) - y,
+	}
+}
+
+func compareCells(a, b CellID) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// normalizeCells replaces every complete set of four siblings by their
+// parent, repeatedly, and returns the cells sorted by ID
+func normalizeCells(cells []CellID) []CellID {
+	for {
+		slices.Sort(cells)
+		merged := cells[:0]
+		changed := false
+		for i := 0; i < len(cells); i++ {
+			c := cells[i]
+			if c.Level() > 0 && i+3 < len(cells) {
+				siblings := c.Parent().Children()
+				if slices.Equal(cells[i:i+4], siblings) {
+					merged = append(merged, c.Parent())
+					i += 3
+					changed = true
+					continue
+				}
+			}
+			merged = append(merged, c)
+		}
+		cells = merged
+		if !changed {
+			return cells
+		}
+	}
+}
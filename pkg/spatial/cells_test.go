@@ -0,0 +1,173 @@
+package spatial
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestCellIDs tests CellContaining against the tree's own routing and the
+// parent, child and ordering relations of IDs
+func TestCellIDs(t *testing.T) {
+	root := Bounds{X: -180, Y: -90, Width: 360, Height: 180}
+	g := Cells{Root: root}
+	if id := g.CellContaining(Point{X: 10, Y: 10}, 0); id != rootCell || g.CellBounds(id) != root || id.String() != "0/" {
+		t.Fatalf("expected level 0 to be the root, got %v", id)
+	}
+	if id := g.CellContaining(Point{X: 10, Y: -10}, 3); id.String() != "3/122" {
+		t.Errorf("expected 3/100, got %v", id)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		p := Point{X: root.X + rng.Float64()*root.Width, Y: root.Y + rng.Float64()*root.Height}
+		level := rng.Intn(MaxCellLevel + 1)
+		id := g.CellContaining(p, level)
+		if !id.Valid() || id.Level() != level {
+			t.Fatalf("%v at level %d: got %v", p, level, id)
+		}
+		if !g.CellBounds(id).Contains(p) {
+			t.Fatalf("%v not in %v's bounds %v", p, id, g.CellBounds(id))
+		}
+		if level > 0 {
+			parent := id.Parent()
+			if parent != g.CellContaining(p, level-1) || !parent.Contains(id) || id.Contains(parent) {
+				t.Fatalf("%v: bad parent %v", id, parent)
+			}
+			if !slices.Contains(parent.Children(), id) {
+				t.Fatalf("%v not among %v's children", id, parent)
+			}
+			if c := parent.Children(); !(c[0] < parent && parent < c[3]) {
+				t.Fatalf("%v sorts outside its children %v", parent, c)
+			}
+		}
+	}
+
+	// Cell bounds match the tree's nodes
+	qt := mustNewQuadTree(root, WithCapacity(1))
+	for i := 0; i < 200; i++ {
+		qt.Insert(Point{X: root.X + rng.Float64()*root.Width, Y: root.Y + rng.Float64()*root.Height})
+	}
+	var walk func(n *Node, id CellID)
+	walk = func(n *Node, id CellID) {
+		if n.Bounds != g.CellBounds(id) {
+			t.Fatalf("node at %v has bounds %v, cell has %v", id, n.Bounds, g.CellBounds(id))
+		}
+		if n.Children[0] == nil {
+			return
+		}
+		for q, child := range id.Children() {
+			walk(n.Children[q], child)
+		}
+	}
+	walk(qt.Root, rootCell)
+}
+
+// TestCover tests that coverings of random areas contain them, respect
+// maxCells and maxLevel, don't overlap and are deterministic
+func TestCover(t *testing.T) {
+	root := Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+	g := Cells{Root: root}
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 3000; i++ {
+		area := Bounds{X: rng.Float64()*1100 - 50, Y: rng.Float64()*1100 - 50}
+		switch i % 4 {
+		case 0: // Degenerate: a point or a line
+		case 1:
+			area.Width = rng.Float64() * 5
+		default:
+			area.Width, area.Height = rng.Float64()*600, rng.Float64()*600
+		}
+		if i%3 == 0 { // On cell edges
+			area.X, area.Y = float64(rng.Intn(9))*125, float64(rng.Intn(9))*125
+		}
+		maxCells, maxLevel := 1+rng.Intn(20), rng.Intn(14)
+		cells := g.Cover(area, maxCells, maxLevel)
+		if !root.Intersects(area) {
+			if cells != nil {
+				t.Fatalf("%v misses the root, got %v", area, cells)
+			}
+			continue
+		}
+		if len(cells) == 0 || len(cells) > maxCells {
+			t.Fatalf("%v with maxCells %d: got %d cells", area, maxCells, len(cells))
+		}
+		if !slices.IsSorted(cells) {
+			t.Fatalf("%v: cells not sorted: %v", area, cells)
+		}
+		for j, c := range cells {
+			if c.Level() > maxLevel {
+				t.Fatalf("%v with maxLevel %d: got %v", area, maxLevel, c)
+			}
+			if j > 0 && cells[j-1].Contains(c) {
+				t.Fatalf("%v: %v overlaps %v", area, cells[j-1], c)
+			}
+		}
+		if again := g.Cover(area, maxCells, maxLevel); !slices.Equal(cells, again) {
+			t.Fatalf("%v: covered as %v then %v", area, cells, again)
+		}
+
+		// Every point of the area inside the root is in some cell: the
+		// clipped area's corners, edges and a sample of its inside
+		clip := intersection(area, root)
+		covered := func(p Point) bool {
+			for _, c := range cells {
+				if g.CellBounds(c).Contains(p) {
+					return true
+				}
+			}
+			return false
+		}
+		for k := 0; k < 64; k++ {
+			fx, fy := rng.Float64(), rng.Float64()
+			if k < 4 {
+				fx, fy = float64(k&1), float64(k>>1)
+			} else if k < 8 {
+				fx = float64(k & 1)
+			}
+			p := Point{X: clip.X + fx*clip.Width, Y: clip.Y + fy*clip.Height}
+			if !covered(p) {
+				t.Fatalf("%v (maxCells %d, maxLevel %d): %v not in any of %v", area, maxCells, maxLevel, p, cells)
+			}
+		}
+	}
+}
+
+// TestCoverPrefersLargeCells tests that an area matching cells exactly is
+// covered by those cells, merged where it can be
+func TestCoverPrefersLargeCells(t *testing.T) {
+	g := Cells{Root: Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}}
+	for _, tc := range []struct {
+		area Bounds
+		want []string
+	}{
+		{Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, []string{"0/"}},
+		{Bounds{X: 500, Y: 0, Width: 500, Height: 500}, []string{"1/1"}},
+		{Bounds{X: 0, Y: 0, Width: 500, Height: 1000}, []string{"1/0", "1/2"}},
+		{Bounds{X: 250, Y: 250, Width: 250, Height: 250}, []string{"2/03"}},
+		{Bounds{X: 250, Y: 500, Width: 125, Height: 125}, []string{"3/210"}},
+	} {
+		cells := g.Cover(tc.area, 8, 12)
+		var got []string
+		for _, c := range cells {
+			got = append(got, c.String())
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%v: expected %v, got %v", tc.area, tc.want, got)
+		}
+	}
+
+	// A tight budget gets one cell, a generous one hugs the area
+	area := Bounds{X: 400, Y: 400, Width: 200, Height: 200}
+	if cells := g.Cover(area, 1, 12); len(cells) != 1 || cells[0] != rootCell {
+		t.Errorf("expected the root for one cell, got %v", cells)
+	}
+	var total float64
+	for _, c := range g.Cover(area, 64, 12) {
+		b := g.CellBounds(c)
+		total += b.Width * b.Height
+	}
+	if total > 2*area.Width*area.Height {
+		t.Errorf("64 cells cover %v of an area of %v", total, area.Width*area.Height)
+	}
+}
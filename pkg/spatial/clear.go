@@ -0,0 +1,52 @@
+package spatial
+
+// Clear drops every point and child node, keeping the root node with its
+// bounds, capacity and depth limit. Handles and IDs from before the Clear
+// stop resolving. Sequence numbers keep counting up so that points returned
+// by earlier queries can never match a record inserted afterwards.
+func (qt *QuadTree) Clear() {
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.own() {
+		return
+	}
+	if qt.observing(removeEvent) {
+		var points []Point
+		qt.Root.collectPoints(&points)
+		for _, p := range points {
+			qt.removed(p)
+		}
+	}
+	if qt.size > 0 {
+		qt.changed()
+	}
+	qt.qcache.purge()
+	qt.Root.detachLocations()
+	qt.Root.releaseChildren()
+	qt.Root.Children = [4]*Node{}
+	qt.Root.resetPoints()
+	if qt.Root.arena != nil {
+		// Let the old blocks go, which the root's arrays would otherwise pin
+		qt.Root.Points = nil
+		qt.Root.arena = qt.Root.arena.fresh()
+	}
+	qt.Root.count = 0
+	qt.ids = nil
+	qt.size = 0
+	qt.rebuildFilter()
+}
+
+// detachLocations marks every tracked point in the subtree as removed
+func (n *Node) detachLocations() {
+	if n.Children[0] != nil {
+		for i := 0; i < 4; i++ {
+			n.Children[i].detachLocations()
+		}
+		return
+	}
+	for _, p := range n.Points {
+		if p.loc != nil {
+			p.loc.leaf = nil
+		}
+	}
+}
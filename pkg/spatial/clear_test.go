@@ -0,0 +1,67 @@
+package spatial
+
+import "testing"
+
+// TestClearBehavesLikeFreshTree tests that queries after Clear match a newly built tree
+func TestClearBehavesLikeFreshTree(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	qt := mustNewQuadTree(bounds, WithCapacity(2), WithMaxDepth(5))
+	root := qt.Root
+
+	for i := 0; i < 50; i++ {
+		qt.Insert(Point{X: float64(i * 2), Y: float64(100 - i*2), Data: i})
+	}
+	qt.Clear()
+
+	if qt.Root != root {
+		t.Error("Clear should keep the existing root node")
+	}
+	if qt.Root.Bounds != bounds || qt.Root.Capacity != 2 || qt.Root.MaxDepth != 5 {
+		t.Errorf("Clear should keep root configuration, got %+v", qt.Root)
+	}
+	if qt.Root.Children[0] != nil {
+		t.Error("Clear should drop children")
+	}
+	if results := qt.Search(bounds); results == nil || len(results) != 0 {
+		t.Errorf("Expected empty non-nil Search result, got %v", results)
+	}
+	if results := qt.KNearest(Point{X: 50, Y: 50}, 3); len(results) != 0 {
+		t.Errorf("Expected no KNearest results, got %v", results)
+	}
+
+	// The tree is fully usable afterwards
+	for i := 0; i < 10; i++ {
+		qt.Insert(Point{X: float64(i * 10), Y: float64(i * 10), Data: i})
+	}
+	if len(qt.Search(bounds)) != 10 {
+		t.Errorf("Expected 10 points after refilling, got %d", len(qt.Search(bounds)))
+	}
+}
+
+// TestClearInvalidatesHandlesAndIDs tests that stale handles and IDs don't touch the cleared tree
+func TestClearInvalidatesHandlesAndIDs(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	e := qt.InsertEntry(Point{X: 10, Y: 10})
+	qt.InsertWithID("a", Point{X: 20, Y: 20})
+	stale := qt.Search(qt.Root.Bounds)
+	qt.Clear()
+
+	if qt.RemoveEntry(e) {
+		t.Error("Handle from before Clear should be inert")
+	}
+	if qt.RemoveByID("a") {
+		t.Error("ID from before Clear should be gone")
+	}
+	if err := qt.InsertWithID("a", Point{X: 20, Y: 20}); err != nil {
+		t.Errorf("ID should be reusable after Clear, got %v", err)
+	}
+
+	// A point returned before Clear can't remove a new record at the same coordinates
+	qt.Insert(Point{X: 10, Y: 10, Data: "new"})
+	for _, p := range stale {
+		if p.X == 10 && qt.Remove(p) {
+			t.Error("Stale point should not match a record inserted after Clear")
+		}
+	}
+}
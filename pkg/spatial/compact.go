@@ -0,0 +1,52 @@
+package spatial
+
+// Compact rebuilds the tree from its live points using the bulk-load path,
+// dropping lopsided structure and oversized leaf slices left behind by churn.
+// Bounds, capacity and depth limit are kept, as are IDs, handles and the
+// identity of every point. The write lock is held throughout, so concurrent
+// inserts wait rather than being lost and readers never see a partial tree.
+// It returns the tree's shape before and after the rebuild.
+//
+// With WithAdaptiveCapacity, Compact retunes capacities in place instead.
+func (qt *QuadTree) Compact() (before, after TreeStats) {
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.own() {
+		before = qt.Root.stats()
+		return before, before
+	}
+
+	// Rebuilt leaves hand back points in a different order
+	qt.qcache.purge()
+	// The points stay put, so this only clears saturated counters and resizes
+	qt.rebuildFilter()
+	old := qt.Root
+	before = old.stats()
+	if qt.adaptMax > 0 {
+		old.sumTouches()
+		old.adapt(qt.adaptMin, qt.adaptMax, 0)
+		return before, old.stats()
+	}
+
+	live := make([]Point, 0, old.count)
+	old.collectPoints(&live)
+
+	root := &Node{
+		Bounds:   old.Bounds,
+		Capacity: old.Capacity,
+		MaxDepth: old.MaxDepth,
+		pool:     old.pool,
+		arena:    old.arena.fresh(),
+		gen:      old.gen,
+		hooks:    old.hooks,
+		policy:   old.policy,
+	}
+	load := &bulkLoad{points: live}
+	keys, _ := root.sortedKeys(load)
+	root.insertSorted(load, keys, 0)
+	qt.Root = root
+	old.release()
+
+	after = root.stats()
+	return before, after
+}
@@ -0,0 +1,121 @@
+package spatial
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// TestCompactPreservesContents tests that Compact keeps every point and query result
+func TestCompactPreservesContents(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4))
+	rng := rand.New(rand.NewSource(11))
+
+	// Churn: cluster points in one corner, then move them all across the map
+	points := make([]Point, 500)
+	for i := range points {
+		points[i] = Point{X: rng.Float64() * 50, Y: rng.Float64() * 50, Data: i}
+		qt.Insert(points[i])
+	}
+	for i := range points {
+		moved := Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Data: i}
+		qt.Update(points[i], moved)
+		points[i] = moved
+	}
+
+	areas := make([]Bounds, 50)
+	counts := make([]int, 50)
+	for i := range areas {
+		areas[i] = Bounds{X: rng.Float64() * 900, Y: rng.Float64() * 900, Width: 100, Height: 100}
+		counts[i] = len(qt.Search(areas[i]))
+	}
+
+	before, after := qt.Compact()
+
+	if before.Points != 500 || after.Points != 500 {
+		t.Errorf("Expected 500 points before and after, got %d and %d", before.Points, after.Points)
+	}
+	if after.Nodes > before.Nodes {
+		t.Errorf("Compact should not grow the tree: %d nodes before, %d after", before.Nodes, after.Nodes)
+	}
+	if qt.Size() != 500 {
+		t.Errorf("Expected Size 500, got %d", qt.Size())
+	}
+	for i, area := range areas {
+		if got := len(qt.Search(area)); got != counts[i] {
+			t.Errorf("Search %v: %d before Compact, %d after", area, counts[i], got)
+		}
+	}
+	for _, p := range points {
+		if !qt.Remove(p) {
+			t.Fatalf("Failed to remove %v after Compact", p.Data)
+		}
+	}
+}
+
+// TestCompactKeepsIdentity tests that IDs, handles and queried points still resolve after Compact
+func TestCompactKeepsIdentity(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+
+	qt.InsertWithID("a", Point{X: 10, Y: 10, Data: "a"})
+	e := qt.InsertEntry(Point{X: 20, Y: 20, Data: "e"})
+	for i := 0; i < 10; i++ {
+		qt.Insert(Point{X: 50, Y: 50, Data: i})
+	}
+	var seventh Point
+	for _, p := range qt.Search(qt.Root.Bounds) {
+		if p.Data == 7 {
+			seventh = p
+		}
+	}
+
+	qt.Compact()
+
+	if p, ok := qt.GetByID("a"); !ok || p.Data != "a" {
+		t.Errorf("ID lost after Compact: %v %v", p, ok)
+	}
+	if p, ok := e.Point(); !ok || p.Data != "e" {
+		t.Errorf("Handle lost after Compact: %v %v", p, ok)
+	}
+	if !qt.MoveEntry(e, 80, 80) || qt.UpdateByID("a", Point{X: 90, Y: 90}) != nil {
+		t.Error("Tracked points should still be movable after Compact")
+	}
+	if !qt.Remove(seventh) {
+		t.Fatal("Queried point should still be removable after Compact")
+	}
+	for _, p := range qt.Search(qt.Root.Bounds) {
+		if p.Data == 7 {
+			t.Error("Removing the queried point removed the wrong record")
+		}
+	}
+}
+
+// TestCompactConcurrentInserts tests that inserts racing with Compact are not lost
+func TestCompactConcurrentInserts(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4))
+	for i := 0; i < 1000; i++ {
+		qt.Insert(Point{X: float64(i), Y: float64(i)})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			qt.Insert(Point{X: float64(i), Y: float64(999 - i)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			qt.Compact()
+		}
+	}()
+	wg.Wait()
+
+	if got := len(qt.Search(qt.Root.Bounds)); got != 1500 || qt.Size() != 1500 {
+		t.Errorf("Expected 1500 points, Search found %d and Size is %d", got, qt.Size())
+	}
+
+	checkValid(t, qt)
+}
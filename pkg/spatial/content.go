@@ -0,0 +1,114 @@
+package spatial
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+)
+
+// contentHash hashes a multiset of records: it adds their SHA-256 digests as
+// 256-bit integers, so the order records are added in doesn't matter, and
+// digests the sum with the record count.
+type contentHash struct {
+	sum [sha256.Size]byte
+	n   uint64
+}
+
+func (h *contentHash) add(record []byte) {
+	d := sha256.Sum256(record)
+	var carry uint16
+	for i := range h.sum {
+		s := uint16(h.sum[i]) + uint16(d[i]) + carry
+		h.sum[i] = byte(s)
+		carry = s >> 8
+	}
+	h.n++
+}
+
+func (h *contentHash) digest() [sha256.Size]byte {
+	return sha256.Sum256(binary.LittleEndian.AppendUint64(h.sum[:len(h.sum):len(h.sum)], h.n))
+}
+
+// ContentHash returns a hex SHA-256 hash of the tree's unexpired points,
+// each taken as its WriteTo record: coordinates, id, Data and expiry. It
+// depends only on which points the tree holds, not on how it subdivided or
+// the order they were inserted in, so a replica that loaded a snapshot can
+// compare its hash with the primary's. WriteTo embeds the same hash. Data
+// must encode the same way every time under the tree's DataCodec; gob, the
+// default, does not for maps. If some Data can't be encoded, ContentHash
+// returns "".
+func (qt *QuadTree) ContentHash() string {
+	qt.rlockAll()
+	defer qt.runlockAll()
+
+	points := make([]Point, 0, qt.Root.count)
+	qt.Root.collectPoints(&points)
+	qt.dropExpired(&points, 0)
+	codec := qt.dataCodec()
+	var content contentHash
+	var record []byte
+	for _, p := range points {
+		id, hasID := qt.pointID(p)
+		var err error
+		if record, err = appendRecord(record[:0], p, id, hasID, codec); err != nil {
+			return ""
+		}
+		content.add(record)
+	}
+	digest := content.digest()
+	return hex.EncodeToString(digest[:])
+}
+
+// VerifySnapshot checks a snapshot without building a tree: either a WriteTo
+// encoding or a SaveToFile file, compressed or not. It checks the structure
+// of every record, the content hash and the checksums, and returns an error
+// wrapping ErrCorruptEncoding for the first problem found. Data is not
+// decoded, so Data the DataCodec would reject is not caught. Encodings
+// written before content hashes were added are checked by checksum only,
+// and one in a newer format returns a *FormatError.
+func VerifySnapshot(r io.Reader) error {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	if magic, _ := br.Peek(len(fileMagic)); string(magic) == fileMagic {
+		_, in, err := openSnapshot(br)
+		if err != nil {
+			return err
+		}
+		if err := verifyEncoding(in); err != nil {
+			return err
+		}
+		return trailing(in, br)
+	}
+	return verifyEncoding(br)
+}
+
+// verifyEncoding reads one WriteTo encoding from r, checking it as Load
+// would but without decoding Data or inserting anything
+func verifyEncoding(r *bufio.Reader) error {
+	d := &decoder{r: r, crc: crc32.NewIEEE()}
+	magic := d.bytes(len(encodingMagic), "magic")
+	if d.err == nil && string(magic) != encodingMagic {
+		return corrupt("not a tree encoding")
+	}
+	h := readEncodingHeader(d)
+	if d.err != nil {
+		return d.err
+	}
+	return readRecords(d, h, func(i uint64, record []byte) error {
+		if _, _, _, err := parseRecord(record, skipData{}); err != nil {
+			return corrupt("record %d: %v", i, err)
+		}
+		return nil
+	})
+}
+
+// skipData is a DataCodec that leaves Data undecoded
+type skipData struct{}
+
+func (skipData) EncodeData(any) ([]byte, error) { return nil, nil }
+func (skipData) DecodeData([]byte) (any, error) { return nil, nil }
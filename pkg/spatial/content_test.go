@@ -0,0 +1,120 @@
+package spatial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestContentHash tests that the hash depends on the points only, not on
+// insertion order or subdivision, and that WriteTo embeds it
+func TestContentHash(t *testing.T) {
+	points := make([]Point, 500)
+	rng := rand.New(rand.NewSource(1))
+	for i := range points {
+		points[i] = Point{X: rng.Float64() * 100, Y: rng.Float64() * 100, Data: i % 7}
+	}
+	points[1] = points[0] // Duplicates count twice
+
+	build := func(order []int, opts ...Option) *QuadTree {
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, opts...)
+		for _, i := range order {
+			if i%5 == 0 {
+				qt.InsertWithID(string(rune('a'+i%26))+string(rune('0'+i/26)), points[i])
+			} else {
+				qt.Insert(points[i])
+			}
+		}
+		return qt
+	}
+	a := build(rng.Perm(len(points)), WithCapacity(2))
+	b := build(rng.Perm(len(points)), WithCapacity(64))
+	// c reaches the same points after detours through other states
+	c := build(rng.Perm(len(points)), WithCapacity(8))
+	c.Insert(Point{X: 1, Y: 1, Data: "temporary"})
+	c.Remove(Point{X: 1, Y: 1})
+	if a.ContentHash() != b.ContentHash() || a.ContentHash() != c.ContentHash() {
+		t.Fatal("Expected trees holding the same points to hash the same")
+	}
+	if len(a.ContentHash()) != 64 {
+		t.Errorf("Expected a hex SHA-256, got %q", a.ContentHash())
+	}
+
+	b.Remove(points[0])
+	if a.ContentHash() == b.ContentHash() {
+		t.Error("Expected removing a duplicate to change the hash")
+	}
+	c.Update(points[2], Point{X: points[2].X, Y: points[2].Y, Data: "changed"})
+	if a.ContentHash() == c.ContentHash() {
+		t.Error("Expected changing Data to change the hash")
+	}
+
+	data := encodeTree(t, a)
+	embedded := hex.EncodeToString(data[len(data)-36 : len(data)-4])
+	if embedded != a.ContentHash() {
+		t.Errorf("Expected WriteTo to embed %s, got %s", a.ContentHash(), embedded)
+	}
+	loaded, err := Load(bytes.NewReader(data))
+	if err != nil || loaded.ContentHash() != a.ContentHash() {
+		t.Errorf("Expected the loaded tree to hash the same, got %v", err)
+	}
+}
+
+// TestVerifySnapshot tests verifying encodings and files, including damage
+// only the content hash catches and encodings from before it
+func TestVerifySnapshot(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.InsertWithID("a", Point{X: 1, Y: 1, Data: "van"})
+	qt.Insert(Point{X: 2, Y: 2, Data: 42})
+	data := encodeTree(t, qt)
+	if err := VerifySnapshot(bytes.NewReader(data)); err != nil {
+		t.Fatalf("VerifySnapshot: %v", err)
+	}
+
+	// Change a coordinate and fix up the CRC, so only the content hash catches it
+	forged := append([]byte(nil), data...)
+	forged[len(forged)-40] ^= 1
+	binary.LittleEndian.PutUint32(forged[len(forged)-4:], crc32.ChecksumIEEE(forged[:len(forged)-4]))
+	err := VerifySnapshot(bytes.NewReader(forged))
+	if !errors.Is(err, ErrCorruptEncoding) || !strings.Contains(err.Error(), "content hash") {
+		t.Errorf("Expected a content hash mismatch, got %v", err)
+	}
+	if _, err := Load(bytes.NewReader(forged)); !errors.Is(err, ErrCorruptEncoding) {
+		t.Errorf("Expected Load to reject it too, got %v", err)
+	}
+	for n := 0; n < len(data); n++ {
+		if err := VerifySnapshot(bytes.NewReader(data[:n])); !errors.Is(err, ErrCorruptEncoding) {
+			t.Fatalf("Truncated to %d bytes: expected ErrCorruptEncoding, got %v", n, err)
+		}
+	}
+
+	// Format 1 has no content hash
+	v1 := append([]byte(nil), data[:len(data)-36]...)
+	binary.LittleEndian.PutUint16(v1[len(encodingMagic):], 1)
+	v1 = binary.LittleEndian.AppendUint32(v1, crc32.ChecksumIEEE(v1))
+	if err := VerifySnapshot(bytes.NewReader(v1)); err != nil {
+		t.Errorf("Expected a format 1 encoding to verify, got %v", err)
+	}
+	if loaded, err := Load(bytes.NewReader(v1)); err != nil || loaded.ContentHash() != qt.ContentHash() {
+		t.Errorf("Expected a format 1 encoding to load, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "tree.snap")
+	for _, c := range []Compression{CompressNone, CompressGzip} {
+		qt.SaveToFileCompressed(path, c)
+		file, _ := os.ReadFile(path)
+		if err := VerifySnapshot(bytes.NewReader(file)); err != nil {
+			t.Errorf("Compression %d: %v", c, err)
+		}
+		if err := VerifySnapshot(bytes.NewReader(append(file, 0))); !errors.Is(err, ErrCorruptEncoding) {
+			t.Errorf("Compression %d: expected trailing data to be rejected, got %v", c, err)
+		}
+	}
+}
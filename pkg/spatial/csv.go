@@ -0,0 +1,306 @@
+package spatial
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// DefaultCSVBatch is the number of rows LoadCSV inserts at a time when
+	// CSVSpec.Batch is 0
+	DefaultCSVBatch = 4096
+	// DefaultCSVMaxErrors is the number of row errors after which LoadCSV
+	// stops when CSVSpec.MaxErrors is 0
+	DefaultCSVMaxErrors = 100
+)
+
+// CSVColumn picks a column by header name or by index. The zero value picks
+// no column.
+type CSVColumn struct {
+	name  string
+	index int
+	set   bool
+}
+
+// CSVName picks the column whose header is name. It needs CSVSpec.Header.
+func CSVName(name string) CSVColumn {
+	return CSVColumn{name: name, set: true}
+}
+
+// CSVIndex picks the column at index i, counting from 0
+func CSVIndex(i int) CSVColumn {
+	return CSVColumn{index: i, set: true}
+}
+
+func (c CSVColumn) String() string {
+	if c.name != "" {
+		return strconv.Quote(c.name)
+	}
+	return strconv.Itoa(c.index)
+}
+
+// CSVSpec describes how LoadCSV reads rows into points
+type CSVSpec struct {
+	X, Y CSVColumn // Required, longitude and latitude for geo trees
+	ID   CSVColumn // Optional, stores each row under its id with InsertWithID
+
+	Header bool // The first row names the columns rather than holding a point
+	// PackData stores the other columns of each row as Data, a
+	// map[string]string keyed by header name, or by index without a header
+	PackData bool
+
+	Comma     rune // Field delimiter, ',' if 0
+	Batch     int  // Rows inserted at a time, DefaultCSVBatch if 0
+	MaxErrors int  // Row errors after which loading stops, DefaultCSVMaxErrors if 0, no limit if negative
+}
+
+// RowError reports a CSV row LoadCSV could not insert. Line is the row's line
+// in the input, counting from 1, or 0 for errors not about one row, and
+// Column the column at fault, if any.
+type RowError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e RowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("spatial: CSV line %d, column %s: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("spatial: CSV line %d: %v", e.Line, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// LoadCSV streams rows from r into qt as spec describes and returns how many
+// were inserted. Rows are inserted a batch at a time: through the bulk path
+// of InsertAll, or under one lock per batch when they have ids, so each
+// batch is a single version. A row that can't be read or inserted (a bad
+// float, a missing column, a point out of bounds, a duplicate id) is skipped
+// and reported as a RowError matching the underlying error, such as
+// strconv.ErrSyntax, ErrMissingColumn, ErrOutOfBounds or ErrDuplicateID.
+// Loading stops after spec.MaxErrors errors, though the batch in progress
+// may add a few more; a spec that doesn't fit the input, or a read error,
+// stops it at once.
+func LoadCSV(r io.Reader, spec CSVSpec, qt *QuadTree) (int, []RowError) {
+	l := &csvLoader{spec: spec, qt: qt, maxErrors: spec.MaxErrors, batch: spec.Batch}
+	if l.batch <= 0 {
+		l.batch = DefaultCSVBatch
+	}
+	if l.maxErrors == 0 {
+		l.maxErrors = DefaultCSVMaxErrors
+	}
+	if !spec.X.set || !spec.Y.set {
+		return 0, []RowError{{Err: fmt.Errorf("%w: X and Y columns are required", ErrInvalidCSVSpec)}}
+	}
+	if !spec.Header && (spec.X.name != "" || spec.Y.name != "" || spec.ID.name != "") {
+		return 0, []RowError{{Err: fmt.Errorf("%w: columns picked by name need a header", ErrInvalidCSVSpec)}}
+	}
+
+	cr := csv.NewReader(r)
+	if spec.Comma != 0 {
+		cr.Comma = spec.Comma
+	}
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	for !l.stopped() {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			l.fail(RowError{Line: parseErr.StartLine, Err: err})
+			continue
+		}
+		if err != nil {
+			l.fail(RowError{Err: err})
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if spec.Header && l.header == nil {
+			l.header = append([]string(nil), record...)
+			if err := l.resolve(); err != nil {
+				l.fail(RowError{Line: line, Err: err})
+				break
+			}
+			continue
+		}
+		l.row(record, line)
+	}
+	l.flush()
+	return l.inserted, l.errs
+}
+
+// csvLoader holds LoadCSV's progress
+type csvLoader struct {
+	spec      CSVSpec
+	qt        *QuadTree
+	batch     int
+	maxErrors int
+
+	header   []string // Column names, nil until the header row is read
+	x, y, id int      // Resolved column indexes, id -1 without an id column
+	resolved bool
+	points   []Point  // Pending batch
+	ids      []string // Ids of the pending batch, when the spec has an ID column
+	lines    []int    // Line of each pending point
+	inserted int
+	errs     []RowError
+	aborted  bool
+}
+
+func (l *csvLoader) stopped() bool {
+	return l.aborted || (l.maxErrors > 0 && len(l.errs) >= l.maxErrors)
+}
+
+func (l *csvLoader) fail(e RowError) {
+	l.errs = append(l.errs, e)
+}
+
+// resolve turns the spec's columns into indexes
+func (l *csvLoader) resolve() error {
+	index := func(c CSVColumn) (int, error) {
+		if !c.set {
+			return -1, nil
+		}
+		if c.name == "" {
+			if c.index < 0 {
+				return 0, fmt.Errorf("%w: column index %d", ErrInvalidCSVSpec, c.index)
+			}
+			return c.index, nil
+		}
+		for i, name := range l.header {
+			if name == c.name {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("%w: no column named %q", ErrInvalidCSVSpec, c.name)
+	}
+	var err error
+	if l.x, err = index(l.spec.X); err != nil {
+		return err
+	}
+	if l.y, err = index(l.spec.Y); err != nil {
+		return err
+	}
+	if l.id, err = index(l.spec.ID); err != nil {
+		return err
+	}
+	l.resolved = true
+	return nil
+}
+
+// row parses one record into the pending batch
+func (l *csvLoader) row(record []string, line int) {
+	if !l.resolved {
+		if err := l.resolve(); err != nil {
+			l.fail(RowError{Line: line, Err: err})
+			l.aborted = true
+			return
+		}
+	}
+	field := func(i int, c CSVColumn) (string, bool) {
+		if i >= len(record) {
+			l.fail(RowError{Line: line, Column: c.String(), Err: ErrMissingColumn})
+			return "", false
+		}
+		return record[i], true
+	}
+	coord := func(i int, c CSVColumn) (float64, bool) {
+		s, ok := field(i, c)
+		if !ok {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			l.fail(RowError{Line: line, Column: c.String(), Err: err})
+			return 0, false
+		}
+		return v, true
+	}
+
+	x, ok := coord(l.x, l.spec.X)
+	if !ok {
+		return
+	}
+	y, ok := coord(l.y, l.spec.Y)
+	if !ok {
+		return
+	}
+	p := Point{X: x, Y: y}
+	if l.id >= 0 {
+		id, ok := field(l.id, l.spec.ID)
+		if !ok {
+			return
+		}
+		l.ids = append(l.ids, id)
+	}
+	if l.spec.PackData {
+		p.Data = l.pack(record)
+	}
+	l.points = append(l.points, p)
+	l.lines = append(l.lines, line)
+	if len(l.points) >= l.batch {
+		l.flush()
+	}
+}
+
+// pack returns the record's columns other than X, Y and ID
+func (l *csvLoader) pack(record []string) map[string]string {
+	data := make(map[string]string, len(record))
+	for i, v := range record {
+		if i == l.x || i == l.y || i == l.id {
+			continue
+		}
+		key := strconv.Itoa(i)
+		if i < len(l.header) {
+			key = l.header[i]
+		}
+		data[key] = v
+	}
+	return data
+}
+
+// flush inserts the pending batch
+func (l *csvLoader) flush() {
+	if len(l.points) == 0 {
+		return
+	}
+	if l.id < 0 {
+		inserted, rejected := l.qt.insertAll(l.points)
+		l.inserted += inserted
+		for _, r := range rejected {
+			l.fail(RowError{Line: l.lines[r.idx], Err: r.err})
+		}
+	} else {
+		l.insertIDs()
+	}
+	clear(l.points)
+	l.points, l.ids, l.lines = l.points[:0], l.ids[:0], l.lines[:0]
+}
+
+// insertIDs stores the pending batch under its ids, as one version
+func (l *csvLoader) insertIDs() {
+	qt := l.qt
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.readOnly {
+		qt.beginVersion()
+	}
+	qt.batch = true
+	defer func() { qt.batch = false }()
+	for i, p := range l.points {
+		if err := qt.insertRecord(p, l.ids[i], true); err != nil {
+			l.fail(RowError{Line: l.lines[i], Err: err})
+			continue
+		}
+		l.inserted++
+	}
+}
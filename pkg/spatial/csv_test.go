@@ -0,0 +1,127 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const stopsCSV = `stop,lon,lat,window,driver
+a,1.5,2.5,am,kim
+b,-3,4,pm,lee
+c,abc,1,am,kim
+d,5
+e,500,5,am,lee
+a,6,6,pm,kim
+f,7,-8,am,lee
+`
+
+// TestLoadCSVHeader tests a header file with named columns, ids and packed
+// Data, and that bad rows are reported by line without stopping the load
+func TestLoadCSVHeader(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: -10, Y: -10, Width: 20, Height: 20})
+	spec := CSVSpec{X: CSVName("lon"), Y: CSVName("lat"), ID: CSVName("stop"), Header: true, PackData: true}
+	inserted, errs := LoadCSV(strings.NewReader(stopsCSV), spec, qt)
+	if inserted != 3 || len(errs) != 4 {
+		t.Fatalf("Expected 3 inserted and 4 errors, got %d and %v", inserted, errs)
+	}
+	for i, want := range []struct {
+		line   int
+		column string
+		err    error
+	}{{4, `"lon"`, strconv.ErrSyntax}, {5, `"lat"`, ErrMissingColumn}, {6, "", ErrOutOfBounds}, {7, "", ErrDuplicateID}} {
+		if errs[i].Line != want.line || errs[i].Column != want.column || !errors.Is(errs[i], want.err) {
+			t.Errorf("Error %d: expected line %d, column %s, %v, got %v", i, want.line, want.column, want.err, errs[i])
+		}
+	}
+
+	p, ok := qt.GetByID("b")
+	if !ok || p.X != -3 || p.Y != 4 {
+		t.Fatalf("Expected b at (-3, 4), got %+v %v", p, ok)
+	}
+	data := p.Data.(map[string]string)
+	if len(data) != 2 || data["window"] != "pm" || data["driver"] != "lee" {
+		t.Errorf("Expected the other columns packed into Data, got %v", data)
+	}
+	if p, _ := qt.GetByID("a"); p.X != 1.5 {
+		t.Errorf("Expected the first a to be kept, got %+v", p)
+	}
+}
+
+// TestLoadCSVNoHeader tests a headerless file with columns picked by index,
+// a custom delimiter, and Data keyed by index through the bulk path
+func TestLoadCSVNoHeader(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	input := "depot;10;20\nhub;30;40\nNaN-row;NaN;1\n"
+	spec := CSVSpec{X: CSVIndex(1), Y: CSVIndex(2), PackData: true, Comma: ';'}
+	inserted, errs := LoadCSV(strings.NewReader(input), spec, qt)
+	if inserted != 2 || len(errs) != 1 || errs[0].Line != 3 || !errors.Is(errs[0], ErrInvalidPoint) {
+		t.Fatalf("Expected 2 inserted and the NaN row rejected, got %d and %v", inserted, errs)
+	}
+	got := qt.Search(Bounds{X: 25, Y: 35, Width: 10, Height: 10})
+	if len(got) != 1 || got[0].Data.(map[string]string)["0"] != "hub" {
+		t.Errorf("Expected hub keyed by index, got %+v", got)
+	}
+}
+
+// TestLoadCSVBatches tests that rows are inserted a batch at a time, each as
+// one version, with or without ids
+func TestLoadCSVBatches(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "v%d,%d,%d\n", i, i, i)
+	}
+	for _, spec := range []CSVSpec{
+		{X: CSVIndex(1), Y: CSVIndex(2), Batch: 30},
+		{X: CSVIndex(1), Y: CSVIndex(2), ID: CSVIndex(0), Batch: 30},
+	} {
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+		before := qt.Version()
+		if inserted, errs := LoadCSV(strings.NewReader(b.String()), spec, qt); inserted != 100 || len(errs) != 0 {
+			t.Fatalf("Expected 100 inserted, got %d and %v", inserted, errs)
+		}
+		if got := qt.Version() - before; got != 4 {
+			t.Errorf("Expected 4 batches to make 4 versions, got %d", got)
+		}
+		if errs := qt.Validate(); len(errs) > 0 {
+			t.Fatal(errs[0])
+		}
+	}
+}
+
+// TestLoadCSVErrorLimit tests that loading stops once MaxErrors errors have
+// been collected, keeping the rows before
+func TestLoadCSVErrorLimit(t *testing.T) {
+	input := "1,1\n2,2\nx,1\nx,2\nx,3\n3,3\nx,4\n"
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10, Height: 10})
+	inserted, errs := LoadCSV(strings.NewReader(input), CSVSpec{X: CSVIndex(0), Y: CSVIndex(1), MaxErrors: 2}, qt)
+	if inserted != 2 || len(errs) != 2 || errs[1].Line != 4 {
+		t.Errorf("Expected to stop at line 4 with 2 rows inserted, got %d and %v", inserted, errs)
+	}
+	qt = mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10, Height: 10})
+	inserted, errs = LoadCSV(strings.NewReader(input), CSVSpec{X: CSVIndex(0), Y: CSVIndex(1), MaxErrors: -1}, qt)
+	if inserted != 3 || len(errs) != 4 {
+		t.Errorf("Expected no limit to read every row, got %d and %v", inserted, errs)
+	}
+}
+
+// TestLoadCSVSpecErrors tests specs that don't fit the input
+func TestLoadCSVSpecErrors(t *testing.T) {
+	for _, c := range []struct {
+		input string
+		spec  CSVSpec
+	}{
+		{"lon,lat\n1,2\n", CSVSpec{X: CSVName("lon"), Header: true}},
+		{"1,2\n", CSVSpec{X: CSVName("lon"), Y: CSVName("lat")}},
+		{"lon,lat\n1,2\n", CSVSpec{X: CSVName("lon"), Y: CSVName("latitude"), Header: true}},
+		{"1,2\n", CSVSpec{X: CSVIndex(-1), Y: CSVIndex(1)}},
+	} {
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10, Height: 10})
+		inserted, errs := LoadCSV(strings.NewReader(c.input), c.spec, qt)
+		if inserted != 0 || len(errs) != 1 || !errors.Is(errs[0], ErrInvalidCSVSpec) {
+			t.Errorf("Expected ErrInvalidCSVSpec for %+v, got %d and %v", c.spec, inserted, errs)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package spatial
+
+// WithDataKey maintains a secondary index from a key derived from each point
+// to the points carrying it, for FindByKey. key returning false leaves the
+// point out of the index. Keys need not be unique. Points placed by calling
+// Node methods on Root directly are not indexed, and snapshots have no index.
+func WithDataKey(key func(Point) (string, bool)) Option {
+	return func(qt *QuadTree) {
+		qt.dataKey = key
+	}
+}
+
+// FindByKey returns the live points whose key is key, in insertion order
+func (qt *QuadTree) FindByKey(key string) []Point {
+	qt.rlockMeta()
+	defer qt.runlockMeta()
+	if !qt.filter.mayContain(qt.filter.keyPrint(key)) {
+		return []Point{}
+	}
+	points := qt.byKey[key]
+	now := qt.clock().UnixNano()
+	results := make([]Point, 0, len(points))
+	for _, p := range points {
+		if !qt.expiring || !expired(p, now) {
+			results = append(results, p)
+		}
+	}
+	return results
+}
+
+// index adds p to the data-key index. Callers must hold the write lock.
+func (qt *QuadTree) index(p Point) {
+	if qt.dataKey == nil {
+		return
+	}
+	key, ok := qt.dataKey(p)
+	if !ok {
+		return
+	}
+	if qt.byKey == nil {
+		qt.byKey = make(map[string][]Point)
+	}
+	if _, exists := qt.byKey[key]; !exists {
+		qt.filter.add(qt.filter.keyPrint(key))
+	}
+	qt.byKey[key] = append(qt.byKey[key], p)
+}
+
+// unindex drops p, matched by its sequence number, from the data-key index
+func (qt *QuadTree) unindex(p Point) {
+	if qt.dataKey == nil {
+		return
+	}
+	key, ok := qt.dataKey(p)
+	if !ok {
+		return
+	}
+	points := qt.byKey[key]
+	for i := range points {
+		if points[i].seq == p.seq {
+			points = append(points[:i], points[i+1:]...)
+			break
+		}
+	}
+	if len(points) == 0 {
+		delete(qt.byKey, key)
+		qt.filter.remove(qt.filter.keyPrint(key))
+		return
+	}
+	qt.byKey[key] = points
+}
+
+// reindex replaces from with to in the data-key index, keeping its place when
+// the key is unchanged
+func (qt *QuadTree) reindex(from, to Point) {
+	if qt.dataKey == nil {
+		return
+	}
+	oldKey, hadKey := qt.dataKey(from)
+	newKey, hasKey := qt.dataKey(to)
+	if hadKey && hasKey && oldKey == newKey {
+		points := qt.byKey[newKey]
+		for i := range points {
+			if points[i].seq == from.seq {
+				points[i] = to
+				return
+			}
+		}
+	}
+	qt.unindex(from)
+	qt.index(to)
+}
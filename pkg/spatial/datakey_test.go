@@ -0,0 +1,123 @@
+package spatial
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type order struct {
+	ID   string
+	Stop int
+}
+
+// orderKey indexes points carrying an order, leaving everything else out
+func orderKey(p Point) (string, bool) {
+	o, ok := p.Data.(order)
+	return o.ID, ok
+}
+
+// TestFindByKey tests lookups of unique, shared, unindexed and missing keys
+func TestFindByKey(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithDataKey(orderKey))
+	qt.Insert(Point{X: 10, Y: 10, Data: order{ID: "12345", Stop: 1}})
+	qt.Insert(Point{X: 90, Y: 90, Data: order{ID: "12345", Stop: 2}})
+	qt.Insert(Point{X: 50, Y: 50, Data: order{ID: "777"}})
+	qt.Insert(Point{X: 20, Y: 80, Data: "depot"})
+
+	got := qt.FindByKey("12345")
+	if len(got) != 2 || got[0].Data.(order).Stop != 1 || got[1].Data.(order).Stop != 2 {
+		t.Errorf("Expected both stops of 12345 in insertion order, got %v", got)
+	}
+	if got := qt.FindByKey("777"); len(got) != 1 || got[0].X != 50 {
+		t.Errorf("Expected order 777 at (50, 50), got %v", got)
+	}
+	if got := qt.FindByKey("missing"); len(got) != 0 {
+		t.Errorf("Expected no points for a missing key, got %v", got)
+	}
+	if len(qt.byKey) != 2 {
+		t.Errorf("Expected only points with an order to be indexed, got %d keys", len(qt.byKey))
+	}
+}
+
+// TestFindByKeyFollowsMutations tests the index through subdivision, merges, moves and removals
+func TestFindByKeyFollowsMutations(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1), WithDataKey(orderKey))
+	for i := 0; i < 40; i++ {
+		qt.Insert(Point{X: float64(i * 2), Y: float64(i * 2), Data: order{ID: fmt.Sprint(i % 4), Stop: i}})
+	}
+	if len(qt.FindByKey("0")) != 10 {
+		t.Fatalf("Expected 10 points for key 0, got %d", len(qt.FindByKey("0")))
+	}
+
+	// Move a point within its leaf and across the tree, then change its key
+	qt.Update(Point{X: 0, Y: 0, Data: order{ID: "0", Stop: 0}}, Point{X: 0, Y: 1, Data: order{ID: "0", Stop: 0}})
+	qt.Update(Point{X: 0, Y: 1, Data: order{ID: "0", Stop: 0}}, Point{X: 99, Y: 1, Data: order{ID: "0", Stop: 0}})
+	if got := qt.FindByKey("0"); got[0].X != 99 || got[0].Y != 1 {
+		t.Errorf("Expected moved point to keep its place at (99, 1), got %v", got[0])
+	}
+	qt.Update(Point{X: 99, Y: 1, Data: order{ID: "0", Stop: 0}}, Point{X: 99, Y: 1, Data: order{ID: "new"}})
+	if len(qt.FindByKey("0")) != 9 || len(qt.FindByKey("new")) != 1 {
+		t.Errorf("Expected re-keyed point to move keys, got %d and %d", len(qt.FindByKey("0")), len(qt.FindByKey("new")))
+	}
+
+	// Removing most points merges the tree back down
+	for i := 0; i < 40; i++ {
+		if i%4 != 1 {
+			qt.Remove(Point{X: float64(i * 2), Y: float64(i * 2), Data: order{ID: fmt.Sprint(i % 4), Stop: i}})
+		}
+	}
+	if len(qt.FindByKey("0")) != 0 || len(qt.FindByKey("2")) != 0 {
+		t.Error("Expected removed keys to be gone")
+	}
+	if got := qt.FindByKey("1"); len(got) != 10 {
+		t.Errorf("Expected 10 points for key 1, got %d", len(got))
+	}
+	for _, p := range qt.FindByKey("1") {
+		if len(qt.Search(Bounds{X: p.X, Y: p.Y})) != 1 {
+			t.Errorf("Indexed point %v is not in the tree", p)
+		}
+	}
+	checkValid(t, qt)
+}
+
+// TestFindByKeyIDsAndBatches tests the index through ID, bulk and clear paths
+func TestFindByKeyIDsAndBatches(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithDataKey(orderKey))
+	qt.InsertAll([]Point{
+		{X: 1, Y: 1, Data: order{ID: "a"}},
+		{X: 2, Y: 2, Data: order{ID: "a"}},
+		{X: 3, Y: 3, Data: order{ID: "b"}},
+	})
+	qt.Upsert("courier", Point{X: 4, Y: 4, Data: order{ID: "b"}})
+	qt.Upsert("courier", Point{X: 80, Y: 80, Data: order{ID: "b"}})
+	if got := qt.FindByKey("b"); len(got) != 2 || got[1].X != 80 {
+		t.Errorf("Expected upserted point at (80, 80), got %v", got)
+	}
+	qt.RemoveByID("courier")
+	if len(qt.FindByKey("b")) != 1 || len(qt.FindByKey("a")) != 2 {
+		t.Errorf("Unexpected index after RemoveByID: %v", qt.byKey)
+	}
+
+	qt.Clear()
+	if len(qt.FindByKey("a")) != 0 || len(qt.byKey) != 0 {
+		t.Errorf("Expected Clear to empty the index, got %v", qt.byKey)
+	}
+}
+
+// TestFindByKeySkipsExpired tests that expired points are filtered and then swept from the index
+func TestFindByKeySkipsExpired(t *testing.T) {
+	clock := newFakeClock()
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithClock(clock.Now), WithDataKey(orderKey))
+	qt.InsertWithTTL(Point{X: 1, Y: 1, Data: order{ID: "a"}}, time.Second)
+	qt.Insert(Point{X: 2, Y: 2, Data: order{ID: "a"}})
+
+	clock.Advance(2 * time.Second)
+	if got := qt.FindByKey("a"); len(got) != 1 || got[0].X != 2 {
+		t.Errorf("Expected only the live point, got %v", got)
+	}
+	qt.RemoveExpired()
+	if len(qt.byKey["a"]) != 1 {
+		t.Errorf("Expected RemoveExpired to drop the expired point from the index, got %v", qt.byKey["a"])
+	}
+}
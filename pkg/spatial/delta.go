@@ -0,0 +1,252 @@
+package spatial
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+)
+
+// A delta is laid out as:
+//
+//	magic "SPQD", format version (uint16), base version, base point count
+//	and target version (uint64s), record count (uvarint)
+//	records: op (byte), record length (uvarint), WriteTo record
+//	CRC-32 (IEEE) of everything before it (uint32)
+//
+// All integers are little-endian. Moves come first, then removes, then
+// inserts.
+const (
+	deltaMagic   = "SPQD"
+	deltaVersion = 1
+
+	deltaInsert = 1
+	deltaRemove = 2
+	deltaMove   = 3
+)
+
+// DeltaBaseError reports a delta that doesn't fit the tree ApplyDelta was
+// given: it was cut from another version, or from another tree at the same
+// version. Load a full snapshot instead. It matches ErrDeltaBase.
+type DeltaBaseError struct {
+	Base    uint64 // Version the delta was cut from
+	Version uint64 // Version of the tree
+	Reason  string
+}
+
+func (e *DeltaBaseError) Error() string {
+	return fmt.Sprintf("spatial: delta from version %d doesn't apply to version %d: %s", e.Base, e.Version, e.Reason)
+}
+
+func (e *DeltaBaseError) Unwrap() error {
+	return ErrDeltaBase
+}
+
+// deltaOp is one change in a delta
+type deltaOp struct {
+	op    byte
+	point Point
+	id    string
+	hasID bool
+}
+
+// SaveDelta writes the changes from sinceVersion to the current version:
+// points removed, points stored under an id whose position or Data changed,
+// and points inserted. Applied with ApplyDelta to a tree at sinceVersion,
+// it brings that tree to the current version. sinceVersion must be the
+// current version or one retained by WithVersionHistory; otherwise the
+// *VersionError from At is returned, and a full snapshot is needed. The
+// versions are compared with Diff, so the work is proportional to the
+// regions that changed, not the tree; like Diff, it doesn't see a change
+// to a point's expiry alone.
+func (qt *QuadTree) SaveDelta(w io.Writer, sinceVersion uint64) error {
+	current := qt.Snapshot()
+	base, err := qt.At(sinceVersion)
+	if err != nil {
+		return err
+	}
+	removed, added := Diff(base.tree, current)
+
+	// A point stored under an id on both sides has moved or changed Data
+	replaced := make(map[string]bool)
+	for _, p := range removed {
+		if id, ok := p.ID(); ok {
+			replaced[id] = false
+		}
+	}
+	var ops, inserts []deltaOp
+	for _, p := range added {
+		id, hasID := p.ID()
+		if _, ok := replaced[id]; hasID && ok {
+			replaced[id] = true
+			ops = append(ops, deltaOp{op: deltaMove, point: p, id: id, hasID: true})
+		} else {
+			inserts = append(inserts, deltaOp{op: deltaInsert, point: p, id: id, hasID: hasID})
+		}
+	}
+	for _, p := range removed {
+		if id, hasID := p.ID(); !hasID || !replaced[id] {
+			ops = append(ops, deltaOp{op: deltaRemove, point: p, id: id, hasID: hasID})
+		}
+	}
+	ops = append(ops, inserts...)
+
+	bw := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(bw, crc)
+	buf := append([]byte(deltaMagic), 0, 0)
+	binary.LittleEndian.PutUint16(buf[len(deltaMagic):], deltaVersion)
+	buf = binary.LittleEndian.AppendUint64(buf, base.tree.version)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(base.tree.size))
+	buf = binary.LittleEndian.AppendUint64(buf, current.version)
+	buf = binary.AppendUvarint(buf, uint64(len(ops)))
+	if _, err := out.Write(buf); err != nil {
+		return err
+	}
+	codec := current.dataCodec()
+	var record []byte
+	for i, op := range ops {
+		if record, err = appendRecord(record[:0], op.point, op.id, op.hasID, codec); err != nil {
+			return fmt.Errorf("spatial: encoding Data of change %d: %w", i, err)
+		}
+		buf = append(buf[:0], op.op)
+		buf = binary.AppendUvarint(buf, uint64(len(record)))
+		if _, err := out.Write(buf); err != nil {
+			return err
+		}
+		if _, err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	if _, err := bw.Write(binary.LittleEndian.AppendUint32(buf[:0], crc.Sum32())); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ApplyDelta reads a delta SaveDelta wrote and applies it as one write,
+// leaving the tree at the delta's version. The whole delta is read and
+// checked before anything changes. A delta cut from a version other than
+// the tree's returns a *DeltaBaseError and changes nothing; so does one
+// whose base held a different number of points. A change that doesn't
+// apply, which means the tree isn't the one the delta was cut from, also
+// returns a *DeltaBaseError, but leaves the tree part way: reload it from a
+// full snapshot. Malformed input returns an error wrapping
+// ErrCorruptEncoding. Like loading, the changes are not logged to the
+// write-ahead log; save a snapshot after applying deltas to a tree that
+// keeps one.
+func (qt *QuadTree) ApplyDelta(r io.Reader) error {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := &decoder{r: br, crc: crc32.NewIEEE()}
+	magic := d.bytes(len(deltaMagic), "magic")
+	if d.err == nil && string(magic) != deltaMagic {
+		return corrupt("not a delta")
+	}
+	if version := binary.LittleEndian.Uint16(d.bytes(2, "version")); d.err == nil && version > deltaVersion {
+		return &FormatError{Kind: "delta", Format: version, Supported: deltaVersion}
+	} else if d.err == nil && version != deltaVersion {
+		return corrupt("unsupported delta version %d", version)
+	}
+	base := binary.LittleEndian.Uint64(d.bytes(8, "base version"))
+	baseSize := binary.LittleEndian.Uint64(d.bytes(8, "base size"))
+	target := binary.LittleEndian.Uint64(d.bytes(8, "target version"))
+	count := d.uvarint("change count")
+	if d.err != nil {
+		return d.err
+	}
+
+	codec := qt.dataCodec()
+	var ops []deltaOp
+	var record []byte
+	for i := uint64(0); i < count; i++ {
+		op := d.byte("op")
+		n := d.uvarint("record length")
+		if d.err == nil && n > maxRecordBytes {
+			return corrupt("change %d claims %d bytes", i, n)
+		}
+		record = d.into(record[:0], int(n), "record")
+		if d.err != nil {
+			return fmt.Errorf("%w (change %d of %d)", d.err, i, count)
+		}
+		if op < deltaInsert || op > deltaMove {
+			return corrupt("change %d: unknown op %d", i, op)
+		}
+		p, id, hasID, err := parseRecord(record, codec)
+		if err != nil {
+			return corrupt("change %d: %v", i, err)
+		}
+		ops = append(ops, deltaOp{op: op, point: p, id: id, hasID: hasID})
+	}
+	want := d.crc.Sum32()
+	if got := binary.LittleEndian.Uint32(d.raw(4, "checksum")); d.err == nil && got != want {
+		return corrupt("checksum mismatch")
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if qt.readOnly {
+		return ErrReadOnly
+	}
+	if qt.version != base {
+		return &DeltaBaseError{Base: base, Version: qt.version, Reason: fmt.Sprintf("cut from version %d", base)}
+	}
+	if uint64(qt.size) != baseSize {
+		return &DeltaBaseError{Base: base, Version: qt.version, Reason: fmt.Sprintf("base held %d points, the tree %d", baseSize, qt.size)}
+	}
+	qt.beginVersion()
+	qt.batch = true
+	defer func() { qt.batch = false }()
+	w := qt.wal
+	qt.wal = nil
+	defer func() { qt.wal = w }()
+
+	for i, op := range ops {
+		if err := qt.applyDeltaOp(op); err != nil {
+			return &DeltaBaseError{Base: base, Version: qt.version, Reason: fmt.Sprintf("change %d: %v", i, err)}
+		}
+	}
+	qt.version = target
+	return nil
+}
+
+// applyDeltaOp applies one change. Callers must hold the write lock.
+func (qt *QuadTree) applyDeltaOp(op deltaOp) error {
+	if !qt.own() {
+		return ErrReadOnly
+	}
+	switch {
+	case op.op == deltaInsert:
+		return qt.insertRecord(op.point, op.id, op.hasID)
+	case op.hasID:
+		loc := qt.ids[op.id]
+		if loc == nil {
+			return ErrNotFound
+		}
+		if op.op == deltaMove {
+			return qt.replaceLoc(loc, op.point)
+		}
+		qt.takeLoc(loc)
+		return nil
+	case op.op == deltaRemove:
+		// Of the points at these coordinates, remove one with the same Data
+		// and no id, as Diff matched it
+		candidates := make([]Point, 0)
+		qt.Root.SearchTree(Bounds{X: op.point.X, Y: op.point.Y}, &candidates)
+		for _, p := range candidates {
+			if _, hasID := p.ID(); p.X == op.point.X && p.Y == op.point.Y && !hasID && reflect.DeepEqual(p.Data, op.point.Data) {
+				_, err := qt.take(p)
+				return err
+			}
+		}
+		return ErrNotFound
+	}
+	return fmt.Errorf("move of a point without an id")
+}
@@ -0,0 +1,87 @@
+package spatial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// TestDeltaChain tests that a full snapshot plus a chain of deltas restores
+// the tree, and that a delta only applies to the version it was cut from
+func TestDeltaChain(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	path := filepath.Join(t.TempDir(), "drivers.snap")
+	qt := mustNewQuadTree(bounds, WithCapacity(4), WithVersionHistory(16))
+	for i := 0; i < 40; i++ {
+		qt.InsertWithID(string(rune('a'+i%26))+string(rune('0'+i/26)), Point{X: float64(i * 2), Y: float64(i), Data: i})
+	}
+	qt.Insert(Point{X: 5, Y: 5, Data: "depot"})
+	qt.Insert(Point{X: 5, Y: 5, Data: "depot"})
+	if err := qt.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each step mutates the tree and cuts a delta from the previous version
+	steps := []func(){
+		func() {
+			qt.Move("a0", 90, 90)
+			qt.RemoveByID("b0")
+			qt.InsertWithID("new", Point{X: 1, Y: 99, Data: "new"})
+		},
+		func() {
+			qt.UpdateByID("c0", Point{X: 4, Y: 2, Data: "updated"})
+			qt.Remove(Point{X: 5, Y: 5})
+			qt.Insert(Point{X: 5, Y: 5, Data: "dock"})
+		},
+		func() {
+			qt.RemoveByID("new")
+			qt.InsertWithID("b0", Point{X: 50, Y: 50})
+			for i := 0; i < 10; i++ {
+				qt.Insert(Point{X: 70, Y: float64(i)})
+			}
+		},
+	}
+	var deltas [][]byte
+	for _, step := range steps {
+		since := qt.Version()
+		step()
+		var buf bytes.Buffer
+		if err := qt.SaveDelta(&buf, since); err != nil {
+			t.Fatal(err)
+		}
+		deltas = append(deltas, buf.Bytes())
+	}
+
+	restored, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.ApplyDelta(bytes.NewReader(deltas[1])); !errors.Is(err, ErrDeltaBase) {
+		t.Fatalf("Expected a delta out of order rejected, got %v", err)
+	}
+	for i, delta := range deltas {
+		if err := restored.ApplyDelta(bytes.NewReader(delta)); err != nil {
+			t.Fatalf("Delta %d: %v", i, err)
+		}
+	}
+	if restored.ContentHash() != qt.ContentHash() || restored.Version() != qt.Version() {
+		t.Errorf("Expected the restored tree to match at version %d, got version %d", qt.Version(), restored.Version())
+	}
+	if errs := restored.Validate(); len(errs) != 0 {
+		t.Error(errs)
+	}
+	if err := restored.ApplyDelta(bytes.NewReader(deltas[2])); !errors.Is(err, ErrDeltaBase) {
+		t.Errorf("Expected a delta applied twice rejected, got %v", err)
+	}
+
+	if err := qt.SaveDelta(io.Discard, 1); !errors.Is(err, ErrVersionEvicted) {
+		t.Errorf("Expected an evicted base version, got %v", err)
+	}
+	damaged := bytes.Clone(deltas[0])
+	damaged[len(damaged)/2] ^= 0xff
+	if err := restored.ApplyDelta(bytes.NewReader(damaged)); !errors.Is(err, ErrCorruptEncoding) {
+		t.Errorf("Expected a damaged delta rejected, got %v", err)
+	}
+}
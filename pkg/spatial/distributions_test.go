@@ -0,0 +1,103 @@
+package spatial_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/spatialtest"
+)
+
+// The grid benchmarks in package spatial build unrealistically balanced
+// trees. These run the same operations on each spatialtest distribution,
+// with the grid alongside as the baseline.
+
+var distBounds = spatial.Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}
+
+var distributions = []struct {
+	name   string
+	points func(n int) []spatial.Point
+}{
+	{"Grid", func(n int) []spatial.Point { return spatialtest.Grid(distBounds, n) }},
+	{"Uniform", func(n int) []spatial.Point { return spatialtest.Uniform(1, distBounds, n) }},
+	{"Clusters", func(n int) []spatial.Point { return spatialtest.Clusters(1, distBounds, n, 8, 150) }},
+	{"PowerLaw", func(n int) []spatial.Point { return spatialtest.PowerLaw(1, distBounds, n, 1.6) }},
+}
+
+// forEachDistribution runs bench once per distribution with n points
+func forEachDistribution(b *testing.B, n int, bench func(b *testing.B, points []spatial.Point)) {
+	for _, d := range distributions {
+		points := d.points(n)
+		b.Run(d.name, func(b *testing.B) { bench(b, points) })
+	}
+}
+
+func newDistTree(b *testing.B, points []spatial.Point) *spatial.QuadTree {
+	qt, err := spatialtest.Build(distBounds, 10, points)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return qt
+}
+
+func BenchmarkDistributionInsert(b *testing.B) {
+	forEachDistribution(b, 10000, func(b *testing.B, points []spatial.Point) {
+		qt, _ := spatial.NewQuadTree(distBounds, spatial.WithCapacity(10))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			qt.Insert(points[i%len(points)])
+		}
+	})
+}
+
+func BenchmarkDistributionBuild(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		for i := 0; i < b.N; i++ {
+			newDistTree(b, points)
+		}
+	})
+}
+
+// Queries are centred on stored points, so dense regions are queried as
+// often as they are populated
+func BenchmarkDistributionSearch(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		qt := newDistTree(b, points)
+		dst := make([]spatial.Point, 0, 1024)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p := points[(i*7919)%len(points)]
+			dst = qt.SearchAppend(spatial.Bounds{X: p.X - 50, Y: p.Y - 50, Width: 100, Height: 100}, dst[:0])
+		}
+	})
+}
+
+func BenchmarkDistributionKNearest(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		qt := newDistTree(b, points)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			qt.KNearest(points[(i*7919)%len(points)], 10)
+		}
+	})
+}
+
+// BenchmarkUpdateRouteTrace replays GPS-like vehicle moves, the Update
+// workload of a live fleet, for several step sizes
+func BenchmarkUpdateRouteTrace(b *testing.B) {
+	for _, step := range []float64{5, 50, 500} {
+		b.Run(fmt.Sprintf("step%v", step), func(b *testing.B) {
+			trace := spatialtest.RouteTrace(1, distBounds, 10000, 100000, step)
+			qt, _ := spatial.NewQuadTree(distBounds, spatial.WithCapacity(10))
+			pos := trace.Play(qt, nil, 0, 0)
+			b.ResetTimer()
+			// Replaying from the start after the last move teleports each
+			// vehicle once, which is noise at this trace length
+			for done := 0; done < b.N; {
+				n := min(b.N-done, len(trace.Moves))
+				pos = trace.Play(qt, pos, 0, n)
+				done += n
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package spatial
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// ExportDOT writes the tree's structure as a Graphviz digraph: one box per
+// node labeled with its bounds and point count, an edge to each child, and
+// leaves filled by how full they are, from white when empty through green
+// and yellow to orange at capacity and red past it. Below maxDepth, each
+// subtree is drawn as one summary node with its node and point counts; a
+// negative maxDepth draws the whole tree. Node IDs are the quadrant path
+// from the root, so the same tree always gives the same output and two runs
+// can be diffed.
+func (qt *QuadTree) ExportDOT(w io.Writer, maxDepth int) error {
+	qt.rlockAll()
+	defer qt.runlockAll()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph quadtree {\n")
+	bw.WriteString("\tnode [shape=box, style=filled, fillcolor=white, fontname=monospace];\n")
+	qt.Root.writeDOT(bw, "n", 0, maxDepth)
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// writeDOT writes n, as node id at depth, and its subtree
+func (n *Node) writeDOT(w *bufio.Writer, id string, depth, maxDepth int) {
+	b := n.Bounds
+	label := fmt.Sprintf("[%g,%g %gx%g]\\n%d points", b.X, b.Y, b.Width, b.Height, n.count)
+	if n.Children[0] == nil {
+		fmt.Fprintf(w, "\t%s [label=\"%s\", fillcolor=%s];\n", id, label, n.fullness())
+		return
+	}
+	fmt.Fprintf(w, "\t%s [label=\"%s\"];\n", id, label)
+	if maxDepth >= 0 && depth >= maxDepth {
+		nodes := n.stats().Nodes - 1
+		fmt.Fprintf(w, "\t%s_more [label=\"%d more nodes\", shape=note, fillcolor=lightgrey];\n", id, nodes)
+		fmt.Fprintf(w, "\t%s -> %s_more [style=dashed];\n", id, id)
+		return
+	}
+	for i, child := range n.Children {
+		childID := fmt.Sprintf("%s%d", id, i)
+		child.writeDOT(w, childID, depth+1, maxDepth)
+		fmt.Fprintf(w, "\t%s -> %s;\n", id, childID)
+	}
+}
+
+// fullness returns the fill color for a leaf holding len(n.Points) points
+func (n *Node) fullness() string {
+	count := len(n.Points)
+	switch {
+	case count == 0:
+		return "white"
+	case count > n.Capacity:
+		return "red"
+	case count == n.Capacity:
+		return "orange"
+	case 2*count >= n.Capacity:
+		return "yellow"
+	}
+	return "palegreen"
+}
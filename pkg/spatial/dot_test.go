@@ -0,0 +1,100 @@
+package spatial
+
+import (
+	"bytes"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	dotNode = regexp.MustCompile(`^(\w+) \[(\w+="[^"]*"|\w+=\w+)(, (\w+="[^"]*"|\w+=\w+))*\];$`)
+	dotEdge = regexp.MustCompile(`^(\w+) -> (\w+)( \[style=dashed\])?;$`)
+)
+
+// parseDOT checks that out is one digraph of node and edge statements, with
+// every node declared once before its edges, and returns the node IDs and
+// parent of each node
+func parseDOT(t *testing.T, out string) (nodes []string, parent map[string]string) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) < 3 || lines[0] != "digraph quadtree {" || lines[len(lines)-1] != "}" {
+		t.Fatalf("Expected one digraph, got:\n%s", out)
+	}
+	declared := make(map[string]bool)
+	parent = make(map[string]string)
+	for _, line := range lines[2 : len(lines)-1] {
+		line = strings.TrimPrefix(line, "\t")
+		if m := dotNode.FindStringSubmatch(line); m != nil {
+			if declared[m[1]] {
+				t.Fatalf("Node %s declared twice", m[1])
+			}
+			declared[m[1]] = true
+			nodes = append(nodes, m[1])
+		} else if m := dotEdge.FindStringSubmatch(line); m != nil {
+			if !declared[m[1]] || !declared[m[2]] || parent[m[2]] != "" {
+				t.Fatalf("Bad edge %q", line)
+			}
+			parent[m[2]] = m[1]
+		} else {
+			t.Fatalf("Not a node or edge statement: %q", line)
+		}
+	}
+	for _, id := range nodes[1:] {
+		if parent[id] == "" {
+			t.Errorf("Node %s is not connected", id)
+		}
+	}
+	return nodes, parent
+}
+
+// TestExportDOT tests that the DOT output is well formed, has a node for
+// every tree node, and is the same on every run
+func TestExportDOT(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4), WithMaxDepth(6))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		// Most points in one corner, so depth varies across the tree
+		qt.Insert(Point{X: rng.Float64() * 100 * rng.Float64() * rng.Float64(), Y: rng.Float64() * 100})
+	}
+	for i := 0; i < 10; i++ {
+		qt.Insert(Point{X: 0.1, Y: 0.1})
+	}
+
+	var buf bytes.Buffer
+	if err := qt.ExportDOT(&buf, -1); err != nil {
+		t.Fatal(err)
+	}
+	nodes, _ := parseDOT(t, buf.String())
+	stats := qt.Stats()
+	if len(nodes) != stats.Nodes {
+		t.Errorf("Expected %d nodes, got %d", stats.Nodes, len(nodes))
+	}
+	if !strings.Contains(buf.String(), "fillcolor=red") || !strings.Contains(buf.String(), `n [label="[0,0 100x100]\n310 points"]`) {
+		t.Errorf("Expected the root label and an overfull leaf:\n%s", buf.String())
+	}
+	var again bytes.Buffer
+	qt.ExportDOT(&again, -1)
+	if again.String() != buf.String() {
+		t.Error("Expected the same output on every run")
+	}
+
+	buf.Reset()
+	qt.ExportDOT(&buf, 2)
+	nodes, parent := parseDOT(t, buf.String())
+	shown, summarized := 0, 0
+	for _, id := range nodes {
+		if strings.HasSuffix(id, "_more") {
+			summarized++
+			continue
+		}
+		shown++
+		if depth := len(id) - 1; depth > 2 {
+			t.Errorf("Node %s is below the depth limit", id)
+		}
+	}
+	if summarized == 0 || shown+summarized != len(parent)+1 {
+		t.Errorf("Expected summary nodes below depth 2, got %d of %d", summarized, len(nodes))
+	}
+}
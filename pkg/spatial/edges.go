@@ -0,0 +1,48 @@
+package spatial
+
+// Edges selects whether a rectangle's max edges (right and bottom) are part of it
+type Edges uint8
+
+const (
+	// InclusiveEdges counts points on any edge as inside. It is the default.
+	InclusiveEdges Edges = iota
+	// HalfOpenEdges counts points on the min edges but not the max edges, so
+	// adjacent rectangles tiling a region never both contain a point.
+	HalfOpenEdges
+)
+
+// ContainsWith is Contains with the given edge semantics
+func (b Bounds) ContainsWith(point Point, edges Edges) bool {
+	return b.containsXY(point.X, point.Y, edges)
+}
+
+// containsXY is ContainsWith for bare coordinates
+func (b Bounds) containsXY(x, y float64, edges Edges) bool {
+	if edges == HalfOpenEdges {
+		return x >= b.X && x < b.X+b.Width &&
+			y >= b.Y && y < b.Y+b.Height
+	}
+	return x >= b.X && x <= b.X+b.Width &&
+		y <= b.Y+b.Height && y >= b.Y
+}
+
+// WithSearchEdges sets the edge semantics Search uses for its query area
+func WithSearchEdges(edges Edges) Option {
+	return func(qt *QuadTree) {
+		qt.edges = edges
+	}
+}
+
+// SearchWith is Search with the given edge semantics for area, whatever the
+// tree's default.
+func (qt *QuadTree) SearchWith(area Bounds, edges Edges) []Point {
+	results := make([]Point, 0)
+	if view := qt.view(); view != nil {
+		view.searchLive(area, edges, &results)
+		return results
+	}
+	held := qt.rlockArea(area)
+	defer qt.runlockArea(held)
+	qt.searchCached(area, edges, &results)
+	return results
+}
@@ -0,0 +1,84 @@
+package spatial
+
+import (
+	"testing"
+)
+
+// TestBoundsContainsWith tests both edge modes on each edge
+func TestBoundsContainsWith(t *testing.T) {
+	b := Bounds{X: 0, Y: 0, Width: 10, Height: 10}
+	tests := []struct {
+		point     Point
+		inclusive bool
+		halfOpen  bool
+	}{
+		{Point{X: 5, Y: 5}, true, true},
+		{Point{X: 0, Y: 0}, true, true},
+		{Point{X: 10, Y: 5}, true, false},
+		{Point{X: 5, Y: 10}, true, false},
+		{Point{X: 10, Y: 10}, true, false},
+		{Point{X: -0.1, Y: 5}, false, false},
+	}
+	for _, tt := range tests {
+		if got := b.ContainsWith(tt.point, InclusiveEdges); got != tt.inclusive {
+			t.Errorf("Inclusive %v: expected %v", tt.point, tt.inclusive)
+		}
+		if got := b.ContainsWith(tt.point, HalfOpenEdges); got != tt.halfOpen {
+			t.Errorf("Half-open %v: expected %v", tt.point, tt.halfOpen)
+		}
+	}
+}
+
+// TestHalfOpenTilesCountOnce tests that adjacent half-open tiles partition the points
+func TestHalfOpenTilesCountOnce(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(3))
+
+	// Every point on a 5-unit grid, so many lie on tile edges and corners
+	total := 0
+	for x := 0; x < 100; x += 5 {
+		for y := 0; y < 100; y += 5 {
+			qt.Insert(Point{X: float64(x), Y: float64(y), Data: [2]int{x, y}})
+			total++
+		}
+	}
+
+	seen := make(map[[2]int]int)
+	inclusive := 0
+	for tx := 0; tx < 100; tx += 25 {
+		for ty := 0; ty < 100; ty += 25 {
+			tile := Bounds{X: float64(tx), Y: float64(ty), Width: 25, Height: 25}
+			for _, p := range qt.SearchWith(tile, HalfOpenEdges) {
+				seen[p.Data.([2]int)]++
+			}
+			inclusive += len(qt.Search(tile))
+		}
+	}
+
+	if len(seen) != total {
+		t.Errorf("Expected every one of %d points in some tile, got %d", total, len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("Point %v counted %d times", key, n)
+		}
+	}
+	if inclusive <= total {
+		t.Errorf("Inclusive tiles should double-count edge points, got %d for %d points", inclusive, total)
+	}
+}
+
+// TestWithSearchEdges tests the tree-level default for Search
+func TestWithSearchEdges(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithSearchEdges(HalfOpenEdges))
+	qt.Insert(Point{X: 50, Y: 50})
+
+	if len(qt.Search(Bounds{X: 25, Y: 25, Width: 25, Height: 25})) != 0 {
+		t.Error("Half-open Search should exclude the max edge")
+	}
+	if len(qt.Search(Bounds{X: 50, Y: 50, Width: 25, Height: 25})) != 1 {
+		t.Error("Half-open Search should include the min edge")
+	}
+	if len(qt.SearchWith(Bounds{X: 25, Y: 25, Width: 25, Height: 25}, InclusiveEdges)) != 1 {
+		t.Error("SearchWith should override the tree default")
+	}
+}
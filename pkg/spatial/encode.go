@@ -0,0 +1,544 @@
+package spatial
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+)
+
+// The binary encoding written by WriteTo and read by Load is, in order:
+//
+//	magic "SPQT", format version (uint16)
+//	bounds (4 float64), capacity, max depth, max points (uvarints),
+//	match epsilon (float64), search edges (byte), flags (byte)
+//	point count (uvarint)
+//	one record per point: its length (uvarint), then x, y (float64),
+//	  record flags (byte), then if flagged: id and Data (each a uvarint
+//	  length and bytes) and expiry (int64 Unix nanoseconds)
+//	content hash of the records (32 bytes), see ContentHash
+//	CRC-32 (IEEE) of everything before it (uint32)
+//
+// Fixed-width numbers are little-endian. Records are in insertion order.
+// Older formats are upgraded as they are read, see migrations.
+const (
+	encodingMagic   = "SPQT"
+	encodingVersion = 2
+
+	// Tree flags
+	encodeGeo        = 1 << 0
+	encodeAutoExpand = 1 << 1
+
+	// Record flags
+	recordID      = 1 << 0
+	recordData    = 1 << 1
+	recordExpires = 1 << 2
+
+	// maxRecordBytes bounds a record's length, so corrupt input cannot make
+	// Load allocate without limit
+	maxRecordBytes = 64 << 20
+)
+
+// DataCodec turns Point.Data into bytes and back for WriteTo and Load
+type DataCodec interface {
+	EncodeData(data any) ([]byte, error)
+	DecodeData(b []byte) (any, error)
+}
+
+// GobCodec is the default DataCodec. Data of a type gob does not know as an
+// interface value, which is anything but the basic types, must be
+// registered with gob.Register on both sides.
+type GobCodec struct{}
+
+func (GobCodec) EncodeData(data any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&data)
+	return buf.Bytes(), err
+}
+
+func (GobCodec) DecodeData(b []byte) (any, error) {
+	var data any
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data)
+	return data, err
+}
+
+// WithDataCodec sets how WriteTo encodes Point.Data and Load decodes it,
+// GobCodec if unset
+func WithDataCodec(c DataCodec) Option {
+	return func(qt *QuadTree) {
+		qt.codec = c
+	}
+}
+
+func (qt *QuadTree) dataCodec() DataCodec {
+	if qt.codec == nil {
+		return GobCodec{}
+	}
+	return qt.codec
+}
+
+// WriteTo writes the tree's unexpired points, with their ids, Data and expiry, and
+// the settings Load needs to rebuild it: bounds, capacity, depth limit,
+// point limit, match epsilon, search edges, geo coordinates and auto
+// expansion. Hooks, indexes and other settings made of functions are not
+// written; pass them to Load again. Handles from InsertEntry are written as
+// plain points. It holds the read lock throughout, so the encoding is one
+// consistent version of the tree. The encoding ends with the tree's
+// ContentHash, which Load and VerifySnapshot check.
+func (qt *QuadTree) WriteTo(w io.Writer) (int64, error) {
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.writeTo(w)
+}
+
+// writeTo is WriteTo for callers that hold the read locks
+func (qt *QuadTree) writeTo(w io.Writer) (int64, error) {
+	points := make([]Point, 0, qt.Root.count)
+	qt.Root.collectPoints(&points)
+	if qt.expiring {
+		qt.dropExpired(&points, 0)
+	}
+	slices.SortFunc(points, func(a, b Point) int { return cmp.Compare(a.seq, b.seq) })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(bw, crc)
+
+	root := qt.Root
+	buf := append([]byte(encodingMagic), 0, 0)
+	binary.LittleEndian.PutUint16(buf[len(encodingMagic):], encodingVersion)
+	for _, v := range []float64{root.Bounds.X, root.Bounds.Y, root.Bounds.Width, root.Bounds.Height} {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	buf = binary.AppendUvarint(buf, uint64(root.Capacity))
+	buf = binary.AppendUvarint(buf, uint64(root.MaxDepth))
+	buf = binary.AppendUvarint(buf, uint64(qt.maxPoints))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(qt.matchEps))
+	var flags byte
+	if qt.geo {
+		flags |= encodeGeo
+	}
+	if qt.autoExpand {
+		flags |= encodeAutoExpand
+	}
+	buf = append(buf, byte(qt.edges), flags)
+	buf = binary.AppendUvarint(buf, uint64(len(points)))
+	if _, err := out.Write(buf); err != nil {
+		return cw.n, err
+	}
+
+	codec := qt.dataCodec()
+	var content contentHash
+	var record []byte
+	for i, p := range points {
+		id, hasID := qt.pointID(p)
+		var err error
+		if record, err = appendRecord(record[:0], p, id, hasID, codec); err != nil {
+			return cw.n, fmt.Errorf("spatial: encoding Data of point %d: %w", i, err)
+		}
+		content.add(record)
+		buf = binary.AppendUvarint(buf[:0], uint64(len(record)))
+		if _, err := out.Write(buf); err != nil {
+			return cw.n, err
+		}
+		if _, err := out.Write(record); err != nil {
+			return cw.n, err
+		}
+	}
+
+	digest := content.digest()
+	if _, err := out.Write(digest[:]); err != nil {
+		return cw.n, err
+	}
+	if _, err := bw.Write(binary.LittleEndian.AppendUint32(buf[:0], crc.Sum32())); err != nil {
+		return cw.n, err
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// pointID returns the id p is stored under, if any. Callers must hold the lock.
+func (qt *QuadTree) pointID(p Point) (string, bool) {
+	if p.loc == nil || qt.ids[p.loc.id] != p.loc {
+		return "", false
+	}
+	return p.loc.id, true
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// Load rebuilds a tree written by WriteTo. The encoded settings come first
+// and opts are applied after them, so opts can override them and add what
+// WriteTo does not record, such as hooks or a DataCodec. Points keep their
+// ids, Data, expiry and relative insertion order. Input that is truncated,
+// fails its checksum or is otherwise malformed returns an error wrapping
+// ErrCorruptEncoding. Encodings in older formats are upgraded as they are
+// read; one in a newer format returns a *FormatError. Load reads ahead of
+// the encoding unless r is a *bufio.Reader.
+func Load(r io.Reader, opts ...Option) (*QuadTree, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := &decoder{r: br, crc: crc32.NewIEEE()}
+
+	magic := d.bytes(len(encodingMagic), "magic")
+	if d.err == nil && string(magic) != encodingMagic {
+		return nil, corrupt("not a tree encoding")
+	}
+	h := readEncodingHeader(d)
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	base := []Option{WithCapacity(h.capacity), WithMaxDepth(h.maxDepth), WithMaxPoints(h.maxPoints), WithMatchEpsilon(h.eps), WithSearchEdges(h.edges)}
+	if h.flags&encodeGeo != 0 {
+		base = append(base, WithGeoCoordinates())
+	}
+	if h.flags&encodeAutoExpand != 0 {
+		base = append(base, WithAutoExpand())
+	}
+	qt, err := NewQuadTree(h.bounds, append(base, opts...)...)
+	if err != nil {
+		return nil, corrupt("header: %v", err)
+	}
+	if err := qt.load(d, h); err != nil {
+		qt.Close()
+		return nil, err
+	}
+	return qt, nil
+}
+
+// encodingHeader is the header of an encoding, after the magic
+type encodingHeader struct {
+	version                       uint16
+	bounds                        Bounds
+	capacity, maxDepth, maxPoints int
+	eps                           float64
+	edges                         Edges
+	flags                         byte
+	count                         uint64
+
+	unhashed bool                           // No content hash follows the records
+	steps    []func([]byte) ([]byte, error) // Record rewrites from older formats
+}
+
+// readEncodingHeader reads the header after the magic, leaving any error in d
+func readEncodingHeader(d *decoder) (h encodingHeader) {
+	h.version = binary.LittleEndian.Uint16(d.bytes(2, "version"))
+	if d.err == nil && h.version == 0 {
+		d.err = corrupt("unsupported version %d", h.version)
+		return h
+	}
+	if d.err == nil && h.version > encodingVersion {
+		d.err = &FormatError{Kind: "tree encoding", Format: h.version, Supported: encodingVersion}
+		return h
+	}
+	h.bounds.X, h.bounds.Y = d.float("bounds"), d.float("bounds")
+	h.bounds.Width, h.bounds.Height = d.float("bounds"), d.float("bounds")
+	h.capacity, h.maxDepth, h.maxPoints = d.int("capacity"), d.int("max depth"), d.int("max points")
+	h.eps = d.float("match epsilon")
+	h.edges, h.flags = Edges(d.byte("edges")), d.byte("flags")
+	h.count = d.uvarint("point count")
+	if d.err == nil {
+		h.steps, d.err = migrate(&h)
+	}
+	return h
+}
+
+// load inserts the records from d as one version
+func (qt *QuadTree) load(d *decoder, h encodingHeader) error {
+	qt.Lock.Lock()
+	defer qt.unlock()
+	qt.beginVersion()
+	qt.batch = true
+	defer func() { qt.batch = false }()
+	// The loaded points are the starting state, not writes to log
+	w := qt.wal
+	qt.wal = nil
+	defer func() { qt.wal = w }()
+
+	codec := qt.dataCodec()
+	return readRecords(d, h, func(i uint64, record []byte) error {
+		p, id, hasID, err := parseRecord(record, codec)
+		if err != nil {
+			return corrupt("record %d: %v", i, err)
+		}
+		if err := qt.insertRecord(p, id, hasID); err != nil {
+			return corrupt("record %d: %v", i, err)
+		}
+		return nil
+	})
+}
+
+// readRecords passes each record from d to visit, upgraded to the current
+// format, then checks the content hash, if the format has one, and the
+// checksum
+func readRecords(d *decoder, h encodingHeader, visit func(i uint64, record []byte) error) error {
+	var content contentHash
+	var record []byte
+	for i := uint64(0); i < h.count; i++ {
+		n := d.uvarint("record length")
+		if d.err == nil && n > maxRecordBytes {
+			return corrupt("record %d claims %d bytes", i, n)
+		}
+		record = d.into(record[:0], int(n), "record")
+		if d.err != nil {
+			return fmt.Errorf("%w (record %d of %d)", d.err, i, h.count)
+		}
+		content.add(record)
+		upgraded := record
+		for _, step := range h.steps {
+			var err error
+			if upgraded, err = step(upgraded); err != nil {
+				return corrupt("record %d: migrating: %v", i, err)
+			}
+		}
+		if err := visit(i, upgraded); err != nil {
+			return err
+		}
+	}
+
+	if !h.unhashed {
+		want := content.digest()
+		if got := d.bytes(len(want), "content hash"); d.err == nil && !bytes.Equal(got, want[:]) {
+			return corrupt("content hash mismatch")
+		}
+	}
+	want := d.crc.Sum32()
+	got := binary.LittleEndian.Uint32(d.raw(4, "checksum"))
+	if d.err != nil {
+		return d.err
+	}
+	if got != want {
+		return corrupt("checksum mismatch")
+	}
+	return nil
+}
+
+// insertRecord stores p, under id if hasID. A point without an id keeps its
+// expiry. Callers must hold the write lock.
+func (qt *QuadTree) insertRecord(p Point, id string, hasID bool) error {
+	if err := qt.admit(p); err != nil {
+		return err
+	}
+	if hasID {
+		if _, exists := qt.ids[id]; exists {
+			return ErrDuplicateID
+		}
+		qt.insertID(id, p)
+		return nil
+	}
+	qt.nextSeq++
+	p.seq = qt.nextSeq
+	qt.Root.insert(p)
+	qt.size++
+	if p.expires != 0 {
+		qt.expiring = true
+	}
+	qt.inserted(p)
+	return nil
+}
+
+// appendRecord appends the body of p's record, without its length, to b
+func appendRecord(b []byte, p Point, id string, hasID bool, codec DataCodec) ([]byte, error) {
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(p.X))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(p.Y))
+	var flags byte
+	if hasID {
+		flags |= recordID
+	}
+	if p.Data != nil {
+		flags |= recordData
+	}
+	if p.expires != 0 {
+		flags |= recordExpires
+	}
+	b = append(b, flags)
+	if hasID {
+		b = binary.AppendUvarint(b, uint64(len(id)))
+		b = append(b, id...)
+	}
+	if p.Data != nil {
+		data, err := codec.EncodeData(p.Data)
+		if err != nil {
+			return b, err
+		}
+		b = binary.AppendUvarint(b, uint64(len(data)))
+		b = append(b, data...)
+	}
+	if p.expires != 0 {
+		b = binary.LittleEndian.AppendUint64(b, uint64(p.expires))
+	}
+	return b, nil
+}
+
+// parseRecord decodes one record's body
+func parseRecord(b []byte, codec DataCodec) (p Point, id string, hasID bool, err error) {
+	if len(b) < 17 {
+		return p, "", false, fmt.Errorf("%d bytes is too short", len(b))
+	}
+	p.X = math.Float64frombits(binary.LittleEndian.Uint64(b))
+	p.Y = math.Float64frombits(binary.LittleEndian.Uint64(b[8:]))
+	flags := b[16]
+	b = b[17:]
+	if flags&^(recordID|recordData|recordExpires) != 0 {
+		return p, "", false, fmt.Errorf("unknown flags %#x", flags)
+	}
+	field := func(name string) ([]byte, error) {
+		n, size := binary.Uvarint(b)
+		if size <= 0 || n > uint64(len(b)-size) {
+			return nil, fmt.Errorf("%s overruns the record", name)
+		}
+		v := b[size : size+int(n)]
+		b = b[size+int(n):]
+		return v, nil
+	}
+	if flags&recordID != 0 {
+		v, err := field("id")
+		if err != nil {
+			return p, "", false, err
+		}
+		id, hasID = string(v), true
+	}
+	if flags&recordData != 0 {
+		v, err := field("Data")
+		if err != nil {
+			return p, "", false, err
+		}
+		if p.Data, err = codec.DecodeData(v); err != nil {
+			return p, "", false, fmt.Errorf("decoding Data: %v", err)
+		}
+	}
+	if flags&recordExpires != 0 {
+		if len(b) < 8 {
+			return p, "", false, fmt.Errorf("expiry overruns the record")
+		}
+		p.expires = int64(binary.LittleEndian.Uint64(b))
+		b = b[8:]
+	}
+	if len(b) != 0 {
+		return p, "", false, fmt.Errorf("%d stray bytes", len(b))
+	}
+	return p, id, hasID, nil
+}
+
+// corrupt returns an error wrapping ErrCorruptEncoding
+func corrupt(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrCorruptEncoding, fmt.Sprintf(format, args...))
+}
+
+// decoder reads the encoding, feeding everything but the checksum to crc.
+// The first error sticks and later reads return zero values.
+type decoder struct {
+	r       *bufio.Reader
+	crc     hash.Hash32
+	err     error
+	readErr error // Last error from ReadByte, to tell it from varint overflow
+	buf     [sha256.Size]byte
+}
+
+// raw reads n bytes without adding them to the checksum
+func (d *decoder) raw(n int, what string) []byte {
+	b := d.buf[:n]
+	if d.err != nil {
+		return b
+	}
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.fail(err, what)
+	}
+	return b
+}
+
+func (d *decoder) bytes(n int, what string) []byte {
+	b := d.raw(n, what)
+	if d.err == nil {
+		d.crc.Write(b)
+	}
+	return b
+}
+
+// into appends n bytes to dst, reading in chunks so a corrupt length fails
+// at the end of the input rather than allocating it all up front
+func (d *decoder) into(dst []byte, n int, what string) []byte {
+	for n > 0 && d.err == nil {
+		chunk := min(n, 64<<10)
+		start := len(dst)
+		dst = slices.Grow(dst, chunk)[:start+chunk]
+		if _, err := io.ReadFull(d.r, dst[start:]); err != nil {
+			d.fail(err, what)
+			return dst
+		}
+		d.crc.Write(dst[start:])
+		n -= chunk
+	}
+	return dst
+}
+
+func (d *decoder) byte(what string) byte {
+	return d.bytes(1, what)[0]
+}
+
+func (d *decoder) float(what string) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(d.bytes(8, what)))
+}
+
+func (d *decoder) uvarint(what string) uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d)
+	switch {
+	case d.readErr != nil:
+		d.fail(d.readErr, what)
+	case err != nil:
+		d.err = corrupt("%s overflows", what)
+	}
+	return v
+}
+
+// int reads a uvarint that must fit in an int32
+func (d *decoder) int(what string) int {
+	v := d.uvarint(what)
+	if d.err == nil && v > math.MaxInt32 {
+		d.err = corrupt("%s %d is out of range", what, v)
+	}
+	return int(v)
+}
+
+func (d *decoder) fail(err error, what string) {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		d.err = corrupt("truncated in %s", what)
+		return
+	}
+	d.err = fmt.Errorf("spatial: reading %s: %w", what, err)
+}
+
+// ReadByte reads one byte into the checksum, for binary.ReadUvarint
+func (d *decoder) ReadByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		d.readErr = err
+		return 0, err
+	}
+	d.crc.Write([]byte{b})
+	return b, nil
+}
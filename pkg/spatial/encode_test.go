@@ -0,0 +1,219 @@
+package spatial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// encodeTree returns qt's encoding, failing the test on error
+func encodeTree(t testing.TB, qt *QuadTree) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := qt.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo reported %d bytes, wrote %d", n, buf.Len())
+	}
+	return buf.Bytes()
+}
+
+// inOrder returns every stored point sorted by insertion order
+func inOrder(qt *QuadTree) []Point {
+	points := qt.Search(qt.Root.Bounds)
+	sort.Slice(points, func(i, j int) bool { return points[i].seq < points[j].seq })
+	return points
+}
+
+// TestEncodeRoundTrip tests that Load rebuilds what WriteTo wrote, including
+// duplicates, negative coordinates, ids, Data, expiry and leaves overflowing
+// at the depth cap
+func TestEncodeRoundTrip(t *testing.T) {
+	clock := newFakeClock()
+	qt := mustNewQuadTree(Bounds{X: -100, Y: -50, Width: 200, Height: 100},
+		WithCapacity(2), WithMaxDepth(3), WithMatchEpsilon(0.5), WithSearchEdges(HalfOpenEdges), WithClock(clock.Now))
+	for i := 0; i < 20; i++ {
+		qt.Insert(Point{X: -7.25, Y: -3.5, Data: i}) // Far more than a depth-3 leaf holds
+	}
+	qt.Insert(Point{X: -7.25, Y: -3.5}) // A duplicate without Data
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		qt.InsertWithID("v"+strconv.Itoa(i), Point{X: rng.Float64()*200 - 100, Y: rng.Float64()*100 - 50, Data: "courier"})
+	}
+	for i := 0; i < 200; i += 3 {
+		qt.RemoveByID("v" + strconv.Itoa(i)) // Leave gaps in the sequence
+	}
+	qt.InsertWithTTL(Point{X: 1, Y: 1, Data: "soon"}, time.Minute)
+	qt.InsertWithTTL(Point{X: 2, Y: 2, Data: "gone"}, time.Second)
+	qt.InsertEntry(Point{X: 3, Y: 3, Data: 3.5})
+	clock.Advance(2 * time.Second)
+
+	loaded, err := Load(bytes.NewReader(encodeTree(t, qt)), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if errs := loaded.Validate(); len(errs) > 0 {
+		t.Fatalf("Loaded tree is invalid: %v", errs[0])
+	}
+	want, got := inOrder(qt), inOrder(loaded)
+	if len(got) != len(want) {
+		t.Fatalf("Loaded %d points, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].X != want[i].X || got[i].Y != want[i].Y || got[i].Data != want[i].Data || got[i].expires != want[i].expires {
+			t.Fatalf("Point %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	for i := 1; i < 200; i++ {
+		id := "v" + strconv.Itoa(i)
+		p, ok := qt.GetByID(id)
+		lp, lok := loaded.GetByID(id)
+		if ok != lok || p.X != lp.X || p.Y != lp.Y {
+			t.Fatalf("GetByID(%s): got %+v %v, want %+v %v", id, lp, lok, p, ok)
+		}
+	}
+	if loaded.Size() != len(want) || loaded.Root.Bounds != qt.Root.Bounds || loaded.Root.Capacity != 2 ||
+		loaded.Root.MaxDepth != 3 || loaded.matchEps != 0.5 || loaded.edges != HalfOpenEdges {
+		t.Error("Expected the tree's settings to survive the round trip")
+	}
+
+	clock.Advance(time.Hour)
+	if got := len(loaded.Search(Bounds{X: 0, Y: 0, Width: 5, Height: 5})); got != 1 {
+		t.Errorf("Expected the TTL point to expire after loading, %d points left", got)
+	}
+	if qt.Insert(Point{X: 1000, Y: 0}) || loaded.Insert(Point{X: 1000, Y: 0}) {
+		t.Error("Expected both trees to reject out-of-bounds points")
+	}
+}
+
+// TestEncodeOverrides tests that options passed to Load apply after the
+// encoded ones, and that auto-expanded bounds are kept
+func TestEncodeOverrides(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10, Height: 10}, WithAutoExpand(), WithMaxPoints(5))
+	qt.Insert(Point{X: 35, Y: 35})
+	loaded, err := Load(bytes.NewReader(encodeTree(t, qt)), WithMaxPoints(0))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.Root.Bounds != qt.Root.Bounds || !loaded.autoExpand || loaded.maxPoints != 0 {
+		t.Errorf("Expected grown bounds, auto expansion and no limit, got %+v %v %d",
+			loaded.Root.Bounds, loaded.autoExpand, loaded.maxPoints)
+	}
+
+	empty := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1, Height: 1})
+	if loaded, err := Load(bytes.NewReader(encodeTree(t, empty))); err != nil || loaded.Size() != 0 {
+		t.Errorf("Expected an empty tree to round-trip, got %v", err)
+	}
+}
+
+// upperCodec stores strings upper-cased, to show the codec is used both ways
+type upperCodec struct{}
+
+func (upperCodec) EncodeData(data any) ([]byte, error) {
+	s, ok := data.(string)
+	if !ok {
+		return nil, fmt.Errorf("unsupported %T", data)
+	}
+	return bytes.ToUpper([]byte(s)), nil
+}
+
+func (upperCodec) DecodeData(b []byte) (any, error) { return string(b), nil }
+
+// TestEncodeCodec tests WithDataCodec on both sides and that codec errors
+// are returned
+func TestEncodeCodec(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10, Height: 10}, WithDataCodec(upperCodec{}))
+	qt.InsertWithID("a", Point{X: 1, Y: 1, Data: "depot"})
+	loaded, err := Load(bytes.NewReader(encodeTree(t, qt)), WithDataCodec(upperCodec{}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if p, _ := loaded.GetByID("a"); p.Data != "DEPOT" {
+		t.Errorf("Expected the codec to encode Data, got %v", p.Data)
+	}
+
+	qt.Insert(Point{X: 2, Y: 2, Data: 7})
+	if _, err := qt.WriteTo(&bytes.Buffer{}); err == nil {
+		t.Error("Expected WriteTo to return the codec's error")
+	}
+	// The default codec cannot decode what upperCodec wrote
+	qt.Remove(Point{X: 2, Y: 2})
+	if _, err := Load(bytes.NewReader(encodeTree(t, qt))); !errors.Is(err, ErrCorruptEncoding) {
+		t.Errorf("Expected ErrCorruptEncoding for undecodable Data, got %v", err)
+	}
+}
+
+// TestEncodeCorrupt tests that every truncation and every flipped bit of an
+// encoding is reported as ErrCorruptEncoding rather than loaded or panicking
+func TestEncodeCorrupt(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: -10, Y: -10, Width: 20, Height: 20}, WithCapacity(2))
+	qt.InsertWithID("a", Point{X: -1, Y: 2, Data: "x"})
+	qt.Insert(Point{X: 3, Y: -4})
+	qt.Insert(Point{X: 3, Y: -4, Data: 9})
+	data := encodeTree(t, qt)
+
+	for n := 0; n < len(data); n++ {
+		if _, err := Load(bytes.NewReader(data[:n])); !errors.Is(err, ErrCorruptEncoding) {
+			t.Fatalf("Truncated to %d of %d bytes: expected ErrCorruptEncoding, got %v", n, len(data), err)
+		}
+	}
+	flipped := make([]byte, len(data))
+	for bit := 0; bit < 8*len(data); bit++ {
+		copy(flipped, data)
+		flipped[bit/8] ^= 1 << (bit % 8)
+		want := ErrCorruptEncoding
+		if binary.LittleEndian.Uint16(flipped[len(encodingMagic):]) > encodingVersion {
+			want = ErrNewerFormat
+		}
+		if _, err := Load(bytes.NewReader(flipped)); !errors.Is(err, want) {
+			t.Fatalf("Bit %d flipped: expected %v, got %v", bit, want, err)
+		}
+	}
+
+	// A huge record length must fail without allocating it
+	huge := append([]byte(nil), data[:len(data)-4]...)
+	if _, err := Load(bytes.NewReader(append(huge[:len(huge)-1], 0xff, 0xff, 0xff, 0xff, 0x0f))); !errors.Is(err, ErrCorruptEncoding) {
+		t.Errorf("Expected ErrCorruptEncoding for a huge record, got %v", err)
+	}
+	if _, err := Load(bytes.NewReader([]byte("GIF89a"))); !errors.Is(err, ErrCorruptEncoding) {
+		t.Errorf("Expected ErrCorruptEncoding for foreign input, got %v", err)
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	qt := benchmarkEncodeTree()
+	var buf bytes.Buffer
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		qt.WriteTo(&buf)
+	}
+	b.ReportMetric(float64(buf.Len())/float64(qt.Size()), "bytes/point")
+}
+
+func BenchmarkLoad(b *testing.B) {
+	data := encodeTree(b, benchmarkEncodeTree())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Load(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkEncodeTree() *QuadTree {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, WithCapacity(10))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		qt.InsertWithID(strconv.Itoa(i), Point{X: rng.Float64() * 10000, Y: rng.Float64() * 10000, Data: i})
+	}
+	return qt
+}
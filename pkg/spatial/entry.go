@@ -0,0 +1,75 @@
+package spatial
+
+// Entry is an opaque handle to a point inserted with InsertEntry. It tracks
+// the leaf holding the point, so RemoveEntry and MoveEntry skip the tree
+// search. A handle whose point has been removed is inert.
+type Entry struct {
+	tree *QuadTree
+	loc  *location
+}
+
+// InsertEntry inserts p and returns a handle to it, or nil if p is out of bounds
+func (qt *QuadTree) InsertEntry(p Point) *Entry {
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if qt.admit(p) != nil {
+		return nil
+	}
+	loc := &location{}
+	qt.nextSeq++
+	p.seq = qt.nextSeq
+	p.loc = loc
+	p.expires = 0
+	qt.Root.insert(p)
+	qt.size++
+	qt.inserted(p)
+	return &Entry{tree: qt, loc: loc}
+}
+
+// RemoveEntry removes the handle's point. It returns false if the handle is
+// nil, belongs to another tree, or its point was already removed.
+func (qt *QuadTree) RemoveEntry(e *Entry) bool {
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.ownsEntry(e) || !qt.own() {
+		return false
+	}
+	qt.ownLoc(e.loc)
+	removed := e.loc.leaf.removeLoc(e.loc, nil)
+	qt.dropLoc(e.loc)
+	qt.size--
+	qt.removed(removed)
+	return true
+}
+
+// MoveEntry moves the handle's point to (newX, newY), keeping its Data
+func (qt *QuadTree) MoveEntry(e *Entry, newX, newY float64) bool {
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.ownsEntry(e) || !qt.own() {
+		return false
+	}
+	if qt.place(Point{X: newX, Y: newY}) != nil {
+		return false
+	}
+	from, _ := e.loc.point()
+	p := from
+	p.X = newX
+	p.Y = newY
+	qt.relocate(from, p)
+	return true
+}
+
+// Point returns the handle's current point, or false if it has been removed
+func (e *Entry) Point() (Point, bool) {
+	if e == nil {
+		return Point{}, false
+	}
+	e.tree.rlockAll()
+	defer e.tree.runlockAll()
+	return e.loc.point()
+}
+
+func (qt *QuadTree) ownsEntry(e *Entry) bool {
+	return e != nil && e.tree == qt && e.loc.leaf != nil
+}
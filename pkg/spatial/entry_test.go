@@ -0,0 +1,144 @@
+package spatial
+
+import "testing"
+
+// TestEntryRemove tests removing a point through its handle
+func TestEntryRemove(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	e := qt.InsertEntry(Point{X: 10, Y: 10, Data: "a"})
+	if e == nil {
+		t.Fatal("InsertEntry returned nil")
+	}
+	qt.Insert(Point{X: 10, Y: 10, Data: "b"})
+
+	if !qt.RemoveEntry(e) {
+		t.Fatal("RemoveEntry failed")
+	}
+	results := qt.Search(qt.Root.Bounds)
+	if len(results) != 1 || results[0].Data != "b" {
+		t.Errorf("Expected only the co-located point b to remain, got %v", results)
+	}
+
+	// A stale handle is inert
+	if qt.RemoveEntry(e) {
+		t.Error("RemoveEntry on a removed handle should fail")
+	}
+	if qt.MoveEntry(e, 50, 50) {
+		t.Error("MoveEntry on a removed handle should fail")
+	}
+	if _, ok := e.Point(); ok {
+		t.Error("Point on a removed handle should report false")
+	}
+}
+
+// TestEntryOutOfBounds tests that an out-of-bounds insert yields no handle
+func TestEntryOutOfBounds(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	if e := qt.InsertEntry(Point{X: 150, Y: 10}); e != nil {
+		t.Error("InsertEntry outside bounds should return nil")
+	}
+	if qt.RemoveEntry(nil) {
+		t.Error("RemoveEntry(nil) should fail")
+	}
+}
+
+// TestEntrySurvivesSubdivision tests that handles follow their points into new children
+func TestEntrySurvivesSubdivision(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+
+	entries := make([]*Entry, 0)
+	for i := 0; i < 30; i++ {
+		entries = append(entries, qt.InsertEntry(Point{X: float64(i*31%100) + 0.5, Y: float64(i*17%100) + 0.5, Data: i}))
+	}
+
+	for i, e := range entries {
+		p, ok := e.Point()
+		if !ok || p.Data != i {
+			t.Fatalf("Handle %d lost its point: %v %v", i, p, ok)
+		}
+	}
+
+	for i := 0; i < 30; i += 3 {
+		if !qt.RemoveEntry(entries[i]) {
+			t.Errorf("RemoveEntry %d failed", i)
+		}
+	}
+	if len(qt.Search(qt.Root.Bounds)) != 20 {
+		t.Errorf("Expected 20 points, got %d", len(qt.Search(qt.Root.Bounds)))
+	}
+}
+
+// TestEntryMove tests moving a point through its handle
+func TestEntryMove(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+
+	e := qt.InsertEntry(Point{X: 10, Y: 10, Data: "mover"})
+	qt.Insert(Point{X: 90, Y: 90, Data: "other"})
+
+	if !qt.MoveEntry(e, 60, 70) {
+		t.Fatal("MoveEntry failed")
+	}
+	if qt.MoveEntry(e, 160, 70) {
+		t.Error("MoveEntry out of bounds should fail")
+	}
+
+	p, ok := e.Point()
+	if !ok || p.X != 60 || p.Y != 70 || p.Data != "mover" {
+		t.Errorf("Expected moved point with Data preserved, got %v", p)
+	}
+	if len(qt.Search(Bounds{X: 0, Y: 0, Width: 20, Height: 20})) != 0 {
+		t.Error("Old position should be empty")
+	}
+}
+
+// TestEntryForeignTree tests that a handle cannot be used on a different tree
+func TestEntryForeignTree(t *testing.T) {
+	qt1 := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+	qt2 := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	e := qt1.InsertEntry(Point{X: 10, Y: 10})
+	if qt2.RemoveEntry(e) {
+		t.Error("RemoveEntry with a foreign handle should fail")
+	}
+	if len(qt1.Search(qt1.Root.Bounds)) != 1 {
+		t.Error("Original tree should be untouched")
+	}
+}
+
+func BenchmarkRemoveEntry(b *testing.B) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, WithCapacity(10))
+
+	// Pre-populate
+	entries := make([]*Entry, b.N)
+	for i := 0; i < b.N; i++ {
+		x := float64(i%100) * 100
+		y := float64((i/100)%100) * 100
+		entries[i] = qt.InsertEntry(Point{X: x, Y: y, Data: nil})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt.RemoveEntry(entries[i])
+	}
+}
+
+func BenchmarkMoveEntry(b *testing.B) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, WithCapacity(10))
+
+	// Pre-populate
+	entries := make([]*Entry, b.N)
+	for i := 0; i < b.N; i++ {
+		x := float64(i%100) * 100
+		y := float64((i/100)%100) * 100
+		entries[i] = qt.InsertEntry(Point{X: x, Y: y, Data: nil})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newX := float64((i+5000)%100) * 100
+		newY := float64(((i+5000)/100)%100) * 100
+		qt.MoveEntry(entries[i], newX, newY)
+	}
+}
@@ -0,0 +1,46 @@
+package spatial
+
+import "math"
+
+// WithMatchEpsilon lets Remove, Take and Update match a stored point whose
+// coordinates each differ from the query by at most eps, so values that lost
+// precision on a round trip still find their record. When several points
+// qualify the closest wins, then the earliest inserted. The default of 0
+// matches exactly.
+func WithMatchEpsilon(eps float64) Option {
+	return func(qt *QuadTree) {
+		qt.matchEps = eps
+	}
+}
+
+// resolve maps a query point onto the stored point it matches within the
+// tree's epsilon, so the exact removal path can take over. With no epsilon,
+// or for a point carrying a sequence number from a query, it returns point
+// unchanged. Callers must hold the lock.
+func (qt *QuadTree) resolve(point Point) (Point, bool) {
+	if qt.matchEps == 0 || point.seq != 0 {
+		return point, true
+	}
+	eps := qt.matchEps
+	// Searching the box rather than routing by quadrant also reaches points
+	// stored just across a split line
+	candidates := make([]Point, 0)
+	qt.Root.SearchTree(Bounds{X: point.X - eps, Y: point.Y - eps, Width: 2 * eps, Height: 2 * eps}, &candidates)
+
+	best := -1
+	bestDist := 0.0
+	for i, p := range candidates {
+		if math.Abs(p.X-point.X) > eps || math.Abs(p.Y-point.Y) > eps {
+			continue
+		}
+		d := DistanceSquared(point, p)
+		if best == -1 || d < bestDist || (d == bestDist && p.seq < candidates[best].seq) {
+			best = i
+			bestDist = d
+		}
+	}
+	if best == -1 {
+		return Point{}, false
+	}
+	return candidates[best], true
+}
@@ -0,0 +1,75 @@
+package spatial
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestMatchEpsilonRemove tests removal with coordinates that lost precision
+func TestMatchEpsilonRemove(t *testing.T) {
+	exact := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	loose := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithMatchEpsilon(1e-9))
+	stored := Point{X: 12.345678901234, Y: 56.789012345678, Data: "d"}
+	exact.Insert(stored)
+	loose.Insert(stored)
+
+	query := Point{X: stored.X + 3e-12, Y: stored.Y - 4e-12}
+	if exact.Remove(query) {
+		t.Error("Without an epsilon the perturbed point should not match")
+	}
+	p, ok := loose.Take(query)
+	if !ok || p.Data != "d" || p.X != stored.X {
+		t.Errorf("Expected the stored point back, got %v, %v", p, ok)
+	}
+	if loose.Remove(query) {
+		t.Error("The point should only be removed once")
+	}
+}
+
+// TestMatchEpsilonAcrossSplitLine tests matching a point stored on the other side of a split
+func TestMatchEpsilonAcrossSplitLine(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1), WithMatchEpsilon(1e-6))
+	qt.Insert(Point{X: 50 - 1e-9, Y: 50 - 1e-9, Data: "nw"})
+	qt.Insert(Point{X: 80, Y: 80, Data: "se"})
+	if qt.Root.Children[0] == nil {
+		t.Fatal("Tree should be subdivided")
+	}
+
+	// The query routes to the south-east child, the point lives in the north-west one
+	if p, ok := qt.Take(Point{X: 50, Y: 50}); !ok || p.Data != "nw" {
+		t.Errorf("Expected the north-west point, got %v, %v", p, ok)
+	}
+
+	// A point on the root's edge matches a query just outside it
+	qt.Insert(Point{X: 100, Y: 100, Data: "corner"})
+	if p, ok := qt.Take(Point{X: 100 + 1e-8, Y: 100}); !ok || p.Data != "corner" {
+		t.Errorf("Expected the corner point, got %v, %v", p, ok)
+	}
+}
+
+// TestMatchEpsilonUpdate tests that Update resolves the old point and picks the closest match
+func TestMatchEpsilonUpdate(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithMatchEpsilon(0.1))
+	qt.Insert(Point{X: 10, Y: 10, Data: "far"})
+	qt.Insert(Point{X: 10.05, Y: 10, Data: "near"})
+
+	if err := qt.UpdateE(Point{X: 10.04, Y: 10}, Point{X: 70, Y: 70, Data: "near"}); err != nil {
+		t.Fatalf("UpdateE failed: %v", err)
+	}
+	if got := qt.Search(Bounds{X: 0, Y: 0, Width: 20, Height: 20}); len(got) != 1 || got[0].Data != "far" {
+		t.Errorf("Expected only the far point left, got %v", got)
+	}
+	if err := qt.UpdateE(Point{X: 30, Y: 30}, Point{X: 1, Y: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestMatchEpsilonValidation tests that NewQuadTree rejects unusable epsilons
+func TestMatchEpsilonValidation(t *testing.T) {
+	for _, eps := range []float64{-1, math.NaN(), math.Inf(1)} {
+		if _, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 1, Height: 1}, WithMatchEpsilon(eps)); !errors.Is(err, ErrInvalidEpsilon) {
+			t.Errorf("WithMatchEpsilon(%v): expected ErrInvalidEpsilon, got %v", eps, err)
+		}
+	}
+}
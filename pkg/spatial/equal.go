@@ -0,0 +1,148 @@
+package spatial
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Equal reports whether a and b store the same multiset of points, compared by
+// coordinates, ID and Data. Internal structure is ignored, so trees built by
+// different insertion histories compare equal. A nil tree is empty.
+func Equal(a, b *QuadTree) bool {
+	onlyA, onlyB := Diff(a, b)
+	return len(onlyA) == 0 && len(onlyB) == 0
+}
+
+// Diff returns the points stored in a but not b and those stored in b but not
+// a, with multiset semantics: three co-located copies in a against one in b
+// leave two in onlyA. Subtrees that cover the same region are compared pairwise,
+// and subtrees shared with a snapshot are skipped outright, so only the
+// regions that actually differ are ever collected. Both slices are empty,
+// never nil, when there is no difference.
+func Diff(a, b *QuadTree) (onlyA, onlyB []Point) {
+	onlyA, onlyB = make([]Point, 0), make([]Point, 0)
+	if a == b {
+		return onlyA, onlyB
+	}
+	unlock := rlockPair(a, b)
+	defer unlock()
+
+	switch {
+	case a == nil || a.Root == nil:
+		if b != nil && b.Root != nil {
+			b.Root.collectPoints(&onlyB)
+		}
+	case b == nil || b.Root == nil:
+		a.Root.collectPoints(&onlyA)
+	default:
+		diffNodes(a.Root, b.Root, &onlyA, &onlyB)
+	}
+	return onlyA, onlyB
+}
+
+// rlockPair read-locks both trees in a fixed order, so two Diffs with swapped
+// arguments cannot deadlock against a waiting writer.
+func rlockPair(a, b *QuadTree) (unlock func()) {
+	if a != nil && b != nil && reflect.ValueOf(a).Pointer() > reflect.ValueOf(b).Pointer() {
+		a, b = b, a
+	}
+	for _, qt := range []*QuadTree{a, b} {
+		if qt != nil {
+			qt.rlockAll()
+		}
+	}
+	return func() {
+		for _, qt := range []*QuadTree{b, a} {
+			if qt != nil {
+				qt.runlockAll()
+			}
+		}
+	}
+}
+
+func diffNodes(x, y *Node, onlyA, onlyB *[]Point) {
+	if x == y {
+		return
+	}
+	if x.Bounds == y.Bounds && x.Children[0] != nil && y.Children[0] != nil {
+		for i := 0; i < 4; i++ {
+			diffNodes(x.Children[i], y.Children[i], onlyA, onlyB)
+		}
+		return
+	}
+	var as, bs []Point
+	x.collectPoints(&as)
+	y.collectPoints(&bs)
+	diffPoints(as, bs, onlyA, onlyB)
+}
+
+// pointID returns the ID a point was inserted with, or "" if it has none
+func pointID(p Point) string {
+	if p.loc == nil {
+		return ""
+	}
+	return p.loc.id
+}
+
+func lessPoint(p, q Point) bool {
+	if p.X != q.X {
+		return p.X < q.X
+	}
+	if p.Y != q.Y {
+		return p.Y < q.Y
+	}
+	return pointID(p) < pointID(q)
+}
+
+// diffPoints matches two multisets of points. Points are sorted by coordinates
+// and ID, then matched on Data within each run of equal keys, since Data need
+// not be comparable with ==.
+func diffPoints(as, bs []Point, onlyA, onlyB *[]Point) {
+	sort.Slice(as, func(i, j int) bool { return lessPoint(as[i], as[j]) })
+	sort.Slice(bs, func(i, j int) bool { return lessPoint(bs[i], bs[j]) })
+
+	i, j := 0, 0
+	for i < len(as) || j < len(bs) {
+		switch {
+		case j == len(bs) || (i < len(as) && lessPoint(as[i], bs[j])):
+			*onlyA = append(*onlyA, as[i])
+			i++
+		case i == len(as) || lessPoint(bs[j], as[i]):
+			*onlyB = append(*onlyB, bs[j])
+			j++
+		default:
+			// Same coordinates and ID: match the two runs on Data
+			ei, ej := i, j
+			for ei < len(as) && !lessPoint(as[i], as[ei]) {
+				ei++
+			}
+			for ej < len(bs) && !lessPoint(bs[j], bs[ej]) {
+				ej++
+			}
+			matchRuns(as[i:ei], bs[j:ej], onlyA, onlyB)
+			i, j = ei, ej
+		}
+	}
+}
+
+func matchRuns(as, bs []Point, onlyA, onlyB *[]Point) {
+	used := make([]bool, len(bs))
+	for _, p := range as {
+		found := false
+		for k, q := range bs {
+			if !used[k] && reflect.DeepEqual(p.Data, q.Data) {
+				used[k] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			*onlyA = append(*onlyA, p)
+		}
+	}
+	for k, q := range bs {
+		if !used[k] {
+			*onlyB = append(*onlyB, q)
+		}
+	}
+}
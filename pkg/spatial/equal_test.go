@@ -0,0 +1,93 @@
+package spatial
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestEqualIgnoresStructure tests that insertion order and capacity don't affect equality
+func TestEqualIgnoresStructure(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+	a := mustNewQuadTree(bounds, WithCapacity(2))
+	b := mustNewQuadTree(bounds, WithCapacity(16))
+
+	rng := rand.New(rand.NewSource(3))
+	points := make([]Point, 500)
+	for i := range points {
+		points[i] = Point{X: float64(rng.Intn(1000)), Y: float64(rng.Intn(1000)), Data: map[string]int{"n": i}}
+	}
+	for i := range points {
+		a.Insert(points[i])
+		b.Insert(points[len(points)-1-i])
+	}
+
+	if !Equal(a, b) {
+		onlyA, onlyB := Diff(a, b)
+		t.Fatalf("Expected equal trees, got %d only in a and %d only in b", len(onlyA), len(onlyB))
+	}
+	if !Equal(a, a) || !Equal(nil, mustNewQuadTree(bounds)) {
+		t.Error("A tree should equal itself and an empty tree should equal nil")
+	}
+}
+
+// TestDiffMultiset tests that co-located duplicates are counted
+func TestDiffMultiset(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	a := mustNewQuadTree(bounds, WithCapacity(1))
+	b := mustNewQuadTree(bounds, WithCapacity(1))
+
+	for i := 0; i < 3; i++ {
+		a.Insert(Point{X: 5, Y: 5, Data: "dup"})
+	}
+	b.Insert(Point{X: 5, Y: 5, Data: "dup"})
+	a.Insert(Point{X: 60, Y: 60, Data: "x"})
+	b.Insert(Point{X: 60, Y: 60, Data: "y"})
+	b.Insert(Point{X: 90, Y: 10})
+
+	onlyA, onlyB := Diff(a, b)
+	if len(onlyA) != 3 || len(onlyB) != 2 {
+		t.Fatalf("Expected 3 only in a and 2 only in b, got %v and %v", onlyA, onlyB)
+	}
+	dups := 0
+	for _, p := range onlyA {
+		if p.Data == "dup" {
+			dups++
+		}
+	}
+	if dups != 2 {
+		t.Errorf("Expected 2 surplus duplicates in a, got %d", dups)
+	}
+}
+
+// TestDiffComparesIDs tests that the same point under different IDs differs
+func TestDiffComparesIDs(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	a := mustNewQuadTree(bounds)
+	b := mustNewQuadTree(bounds)
+	a.InsertWithID("d1", Point{X: 10, Y: 10})
+	b.InsertWithID("d2", Point{X: 10, Y: 10})
+
+	onlyA, onlyB := Diff(a, b)
+	if len(onlyA) != 1 || len(onlyB) != 1 {
+		t.Errorf("Expected one point on each side, got %v and %v", onlyA, onlyB)
+	}
+}
+
+// TestDiffAgainstSnapshot tests replica verification against a snapshot of the primary
+func TestDiffAgainstSnapshot(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4))
+	for i := 0; i < 200; i++ {
+		qt.InsertWithID(fmt.Sprintf("d%d", i), Point{X: float64(i * 5), Y: float64(i * 3 % 1000)})
+	}
+	snap := qt.Snapshot()
+	if !Equal(qt, snap) {
+		t.Fatal("A fresh snapshot should equal the live tree")
+	}
+
+	qt.Move("d10", 999, 1)
+	onlyLive, onlySnap := Diff(qt, snap)
+	if len(onlyLive) != 1 || len(onlySnap) != 1 || onlyLive[0].X != 999 || onlySnap[0].X != 50 {
+		t.Errorf("Expected the moved point on each side, got %v and %v", onlyLive, onlySnap)
+	}
+}
@@ -0,0 +1,83 @@
+package spatial
+
+import "errors"
+
+var (
+	// ErrOutOfBounds is returned when a point lies outside the tree's root bounds
+	ErrOutOfBounds = errors.New("spatial: point out of bounds")
+	// ErrNotFound is returned when no point matches the given ID or coordinates
+	ErrNotFound = errors.New("spatial: point not found")
+	// ErrDuplicateID is returned by InsertWithID when the ID is already in the tree
+	ErrDuplicateID = errors.New("spatial: duplicate id")
+	// ErrInvalidPoint is returned when a point has a NaN or infinite coordinate
+	ErrInvalidPoint = errors.New("spatial: invalid point")
+	// ErrInvalidCoordinate is the same value as ErrInvalidPoint
+	ErrInvalidCoordinate = ErrInvalidPoint
+	// ErrInvalidGeoPoint is returned for a latitude outside [-90, 90] or a longitude
+	// outside [-180, 180]
+	ErrInvalidGeoPoint = errors.New("spatial: invalid geo point")
+	// ErrInvalidGeohash is returned by DecodeGeohash for a hash that is empty, too long
+	// or has a character outside the geohash alphabet
+	ErrInvalidGeohash = errors.New("spatial: invalid geohash")
+	// ErrInvalidLength is returned by ParseLength for text that isn't a non-negative
+	// number with a unit it knows
+	ErrInvalidLength = errors.New("spatial: invalid length")
+	// ErrImplausibleJump is matched by a *JumpError for an update moving an ID faster
+	// than its JumpFilter allows
+	ErrImplausibleJump = errors.New("spatial: implausible jump")
+	// ErrInvalidJumpFilter is returned by NewJumpFilter for a MaxSpeed that isn't positive
+	// or a negative Tolerance
+	ErrInvalidJumpFilter = errors.New("spatial: invalid jump filter")
+	// ErrInvalidBounds is returned when bounds have a NaN, infinite or negative field
+	ErrInvalidBounds = errors.New("spatial: invalid bounds")
+	// ErrInvalidCapacity is returned by NewQuadTree when the leaf capacity is not positive,
+	// and by NewRTree when the node capacity is less than 4
+	ErrInvalidCapacity = errors.New("spatial: invalid capacity")
+	// ErrInvalidMaxDepth is returned by NewQuadTree when the max depth is negative
+	ErrInvalidMaxDepth = errors.New("spatial: invalid max depth")
+	// ErrInvalidEpsilon is returned by NewQuadTree when the match epsilon is negative or NaN
+	ErrInvalidEpsilon = errors.New("spatial: invalid match epsilon")
+	// ErrInvalidCacheSize is returned by NewQuadTree when WithQueryCache is given a non-positive size
+	ErrInvalidCacheSize = errors.New("spatial: invalid query cache size")
+	// ErrInvalidFilter is returned by NewQuadTree when WithExistenceFilter is given a non-positive
+	// size or a false-positive rate outside (0, 1)
+	ErrInvalidFilter = errors.New("spatial: invalid existence filter")
+	// ErrInvalidArena is returned by NewQuadTree when WithArena is given a negative block size
+	ErrInvalidArena = errors.New("spatial: invalid arena block size")
+	// ErrTreeFull is returned when inserting into a tree that holds WithMaxPoints points
+	ErrTreeFull = errors.New("spatial: tree full")
+	// ErrReadOnly is returned when mutating a snapshot
+	ErrReadOnly = errors.New("spatial: tree is read-only")
+	// ErrUnknownOp is returned by Apply for an Op whose Kind is not recognised
+	ErrUnknownOp = errors.New("spatial: unknown op")
+	// ErrVersionEvicted is matched by a *VersionError for a version no longer retained
+	ErrVersionEvicted = errors.New("spatial: version evicted")
+	// ErrCorruptTree wraps every violation reported by QuadTree.Validate
+	ErrCorruptTree = errors.New("spatial: corrupt tree")
+	// ErrNotPointFeature is matched by a *FeatureError for a GeoJSON feature whose geometry is not a Point
+	ErrNotPointFeature = errors.New("spatial: not a point feature")
+	// ErrInvalidCSVSpec is matched by the RowError LoadCSV returns for a CSVSpec that doesn't fit the input
+	ErrInvalidCSVSpec = errors.New("spatial: invalid CSV spec")
+	// ErrMissingColumn is matched by a RowError for a CSV row too short to hold a column
+	ErrMissingColumn = errors.New("spatial: missing column")
+	// ErrInvalidWAL is returned by NewQuadTree when WithWAL is given no directory,
+	// a negative segment size, or a sync policy without its count or interval
+	ErrInvalidWAL = errors.New("spatial: invalid WAL config")
+	// ErrUnknownCompression is matched by a *CompressionError for a snapshot compression
+	// this version does not support
+	ErrUnknownCompression = errors.New("spatial: unknown compression")
+	// ErrDeltaBase is matched by a *DeltaBaseError for a delta cut from a version
+	// other than the tree's; load a full snapshot instead
+	ErrDeltaBase = errors.New("spatial: delta does not apply to this version")
+	// ErrNewerFormat is matched by a *FormatError for input written in a format newer
+	// than this version reads
+	ErrNewerFormat = errors.New("spatial: newer format")
+	// ErrCorruptEncoding is wrapped by every error Load returns for malformed input
+	ErrCorruptEncoding = errors.New("spatial: corrupt encoding")
+	// ErrInvalidCellSize is returned by NewGridIndex for a cell size that is not positive
+	// and finite, or that would divide the bounds into more than MaxGridCells cells
+	ErrInvalidCellSize = errors.New("spatial: invalid cell size")
+	// ErrInvalidLooseness is returned by NewLooseQuadTree for a looseness that is less
+	// than 1 or not finite
+	ErrInvalidLooseness = errors.New("spatial: invalid looseness")
+)
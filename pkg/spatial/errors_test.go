@@ -0,0 +1,87 @@
+package spatial
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestMutationErrors pins the error returned by every failure path of the E variants
+func TestMutationErrors(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	qt := mustNewQuadTree(bounds, WithCapacity(2), WithMaxPoints(3))
+	if err := qt.InsertE(Point{X: 10, Y: 10}); err != nil {
+		t.Fatalf("InsertE failed: %v", err)
+	}
+	qt.InsertE(Point{X: 20, Y: 20})
+	snap := qt.Snapshot()
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"insert NaN", qt.InsertE(Point{X: math.NaN(), Y: 1}), ErrInvalidPoint},
+		{"insert Inf", qt.InsertE(Point{X: 1, Y: math.Inf(1)}), ErrInvalidPoint},
+		{"insert outside", qt.InsertE(Point{X: 101, Y: 1}), ErrOutOfBounds},
+		{"insert into snapshot", snap.InsertE(Point{X: 1, Y: 1}), ErrReadOnly},
+		{"remove missing", qt.RemoveE(Point{X: 50, Y: 50}), ErrNotFound},
+		{"remove outside", qt.RemoveE(Point{X: 150, Y: 50}), ErrNotFound},
+		{"remove from snapshot", snap.RemoveE(Point{X: 10, Y: 10}), ErrReadOnly},
+		{"update missing", qt.UpdateE(Point{X: 50, Y: 50}, Point{X: 60, Y: 60}), ErrNotFound},
+		{"update to NaN", qt.UpdateE(Point{X: 10, Y: 10}, Point{X: math.NaN(), Y: 1}), ErrInvalidPoint},
+		{"update outside", qt.UpdateE(Point{X: 10, Y: 10}, Point{X: 1, Y: -1}), ErrOutOfBounds},
+		{"update snapshot", snap.UpdateE(Point{X: 10, Y: 10}, Point{X: 5, Y: 5}), ErrReadOnly},
+		{"insert third", qt.InsertE(Point{X: 30, Y: 30}), nil},
+		{"insert when full", qt.InsertE(Point{X: 40, Y: 40}), ErrTreeFull},
+		{"insert ID when full", qt.InsertWithID("d", Point{X: 40, Y: 40}), ErrTreeFull},
+		{"update when full", qt.UpdateE(Point{X: 30, Y: 30}, Point{X: 35, Y: 35}), nil},
+		{"remove", qt.RemoveE(Point{X: 35, Y: 35}), nil},
+		{"insert after remove", qt.InsertE(Point{X: 40, Y: 40}), nil},
+	}
+
+	for _, tt := range tests {
+		if tt.want == nil && tt.err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, tt.err)
+			continue
+		}
+		if tt.want != nil && !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, tt.err)
+		}
+	}
+	if qt.Size() != 3 || snap.Size() != 2 {
+		t.Errorf("Expected sizes 3 and 2, got %d and %d", qt.Size(), snap.Size())
+	}
+}
+
+// TestBoolWrappersMatchErrors tests that the bool APIs agree with their E variants
+func TestBoolWrappersMatchErrors(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	if !qt.Insert(Point{X: 1, Y: 1}) || qt.Insert(Point{X: 101, Y: 1}) {
+		t.Error("Insert should succeed in bounds and fail outside")
+	}
+	if !qt.Update(Point{X: 1, Y: 1}, Point{X: 2, Y: 2}) || qt.Update(Point{X: 1, Y: 1}, Point{X: 3, Y: 3}) {
+		t.Error("Update should succeed once and then miss")
+	}
+	if !qt.Remove(Point{X: 2, Y: 2}) || qt.Remove(Point{X: 2, Y: 2}) {
+		t.Error("Remove should succeed once and then miss")
+	}
+}
+
+// TestInsertAllRespectsMaxPoints tests that a batch stops at the point limit in input order
+func TestInsertAllRespectsMaxPoints(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithMaxPoints(5))
+	qt.Insert(Point{X: 1, Y: 1})
+
+	batch := []Point{{X: 90, Y: 90, Data: 0}, {X: 200, Y: 0, Data: "out"}, {X: 10, Y: 10, Data: 1},
+		{X: 50, Y: 50, Data: 2}, {X: 20, Y: 80, Data: 3}, {X: 5, Y: 5, Data: 4}, {X: 6, Y: 6, Data: 5}}
+	inserted, rejected := qt.InsertAll(batch)
+	if inserted != 4 || len(rejected) != 3 {
+		t.Fatalf("Expected 4 inserted and 3 rejected, got %d and %d", inserted, len(rejected))
+	}
+	for _, p := range qt.Search(qt.Root.Bounds) {
+		if n, ok := p.Data.(int); ok && n >= 4 {
+			t.Errorf("Point %v past the limit was inserted", p.Data)
+		}
+	}
+}
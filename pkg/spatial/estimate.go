@@ -0,0 +1,41 @@
+package spatial
+
+// reserve makes room for n more points in *dst, allocating exactly that
+// much when the spare capacity falls short. Search calls it on reaching a
+// node its area fully contains, whose point count is known up front.
+func reserve(dst *[]Point, n int) {
+	if cap(*dst)-len(*dst) >= n {
+		return
+	}
+	grown := make([]Point, len(*dst), len(*dst)+n)
+	copy(grown, *dst)
+	*dst = grown
+}
+
+// EstimateCount returns an upper bound on how many points Search(area)
+// returns, for sizing a SearchAppend buffer. It adds up the counts of the
+// nodes area reaches without scanning any leaf, so it costs a fraction of
+// the search. Leaves area only partly covers count in full, as do expired
+// points not yet removed.
+func (qt *QuadTree) EstimateCount(area Bounds) int {
+	if view := qt.view(); view != nil {
+		return view.Root.estimateCount(area)
+	}
+	held := qt.rlockArea(area)
+	defer qt.runlockArea(held)
+	return qt.Root.estimateCount(area)
+}
+
+func (n *Node) estimateCount(area Bounds) int {
+	if n == nil || !n.Bounds.Intersects(area) {
+		return 0
+	}
+	if n.Children[0] == nil || area.containsBounds(n.Bounds) {
+		return n.count
+	}
+	total := 0
+	for _, c := range n.Children {
+		total += c.estimateCount(area)
+	}
+	return total
+}
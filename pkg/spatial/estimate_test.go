@@ -0,0 +1,48 @@
+package spatial
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestEstimateCountBoundsSearch tests that the estimate never undercounts and
+// is exact for areas made of whole nodes
+func TestEstimateCountBoundsSearch(t *testing.T) {
+	qt := newRandomTree(5000, 1)
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		area := Bounds{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Width: rng.Float64() * 300, Height: rng.Float64() * 300}
+		if est, got := qt.EstimateCount(area), len(qt.Search(area)); est < got {
+			t.Fatalf("EstimateCount(%v) = %d, but Search found %d", area, est, got)
+		}
+	}
+	if est := qt.EstimateCount(qt.Root.Bounds); est != 5000 {
+		t.Errorf("Expected the whole tree to estimate 5000, got %d", est)
+	}
+	if est := qt.EstimateCount(Bounds{X: 2000, Y: 2000, Width: 10, Height: 10}); est != 0 {
+		t.Errorf("Expected an area outside the tree to estimate 0, got %d", est)
+	}
+}
+
+// TestSearchContainedRegionAllocatesOnce tests that Search reserves a fully
+// contained node's count up front instead of growing the result
+func TestSearchContainedRegionAllocatesOnce(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		qt.Insert(Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+
+	for _, area := range []Bounds{
+		qt.Root.Bounds,
+		{X: -10, Y: -10, Width: 2000, Height: 2000},
+		{X: 0, Y: 0, Width: 500, Height: 500}, // Exactly one root quadrant
+	} {
+		if allocs := testing.AllocsPerRun(20, func() { qt.Search(area) }); allocs != 1 {
+			t.Errorf("Search(%v) allocated %v times, want 1", area, allocs)
+		}
+		if got := qt.Search(area); cap(got) != len(got) {
+			t.Errorf("Search(%v) reserved %d for %d points", area, cap(got), len(got))
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package spatial
+
+// WithAutoExpand lets the root grow to take in points outside its bounds
+// instead of rejecting them.
+func WithAutoExpand() Option {
+	return func(qt *QuadTree) {
+		qt.autoExpand = true
+	}
+}
+
+// place checks that p can be stored, growing the root first when
+// auto-expansion is on. Callers must hold the write lock.
+func (qt *QuadTree) place(p Point) error {
+	if !validCoordinates(p) {
+		return ErrInvalidCoordinate
+	}
+	if qt.Root.Bounds.Contains(p) {
+		return nil
+	}
+	// Doubling a zero-area root never reaches anything
+	if !qt.autoExpand || qt.Root.Bounds.Width <= 0 || qt.Root.Bounds.Height <= 0 {
+		return ErrOutOfBounds
+	}
+	for !qt.Root.Bounds.Contains(p) {
+		qt.expandToward(p)
+	}
+	return nil
+}
+
+// fits returns the error place would return for p, without growing the
+// root or needing the write lock
+func (qt *QuadTree) fits(p Point) error {
+	if !validCoordinates(p) {
+		return ErrInvalidPoint
+	}
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	b := qt.Root.Bounds
+	if b.Contains(p) || (qt.autoExpand && b.Width > 0 && b.Height > 0) {
+		return nil
+	}
+	return ErrOutOfBounds
+}
+
+// admit checks that a new point may be added: the tree is writable, p is
+// valid, there is room under WithMaxPoints, and p fits (growing the root if
+// needed). Callers must hold the write lock.
+func (qt *QuadTree) admit(p Point) error {
+	if !qt.own() {
+		return ErrReadOnly
+	}
+	if !validCoordinates(p) {
+		return ErrInvalidPoint
+	}
+	if qt.full() {
+		return ErrTreeFull
+	}
+	return qt.place(p)
+}
+
+// full reports whether the tree has reached its WithMaxPoints limit
+func (qt *QuadTree) full() bool {
+	return qt.maxPoints > 0 && qt.size >= qt.maxPoints
+}
+
+// expandToward replaces the root with one twice the size, extended in the
+// direction of p, and hangs the old root in the matching quadrant, one level
+// deeper and with MaxDepth raised to match. Readers only ever see the tree
+// under the lock, so the swap is never observed half done.
+func (qt *QuadTree) expandToward(p Point) {
+	old := qt.Root
+	b := old.Bounds
+	grown := Bounds{X: b.X, Y: b.Y, Width: b.Width * 2, Height: b.Height * 2}
+	quadrant := 0
+	if p.X < b.X {
+		grown.X -= b.Width
+		quadrant |= 1
+	}
+	if p.Y < b.Y {
+		grown.Y -= b.Height
+		quadrant |= 2
+	}
+
+	root := &Node{
+		Bounds:   grown,
+		Capacity: old.Capacity,
+		MaxDepth: old.maxDepth() + 1,
+		count:    old.count,
+		pool:     old.pool,
+		arena:    old.arena,
+		gen:      old.gen,
+		hooks:    old.hooks,
+		policy:   old.policy,
+		adaptive: old.adaptive,
+		touches:  newTouches(old.adaptive),
+	}
+	root.SubDivide()
+	root.Children[quadrant].release()
+	root.Children[quadrant] = old
+	old.parent = root
+	old.shiftDepth(1)
+	qt.Root = root
+}
+
+// shiftDepth adds delta to the depth and max depth of every node in the
+// subtree, so its leaves may still split down to the same cell size
+func (n *Node) shiftDepth(delta int) {
+	n.Depth += delta
+	n.MaxDepth = n.maxDepth() + delta
+	if n.Children[0] != nil {
+		for i := 0; i < 4; i++ {
+			n.writableChild(i).shiftDepth(delta)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package spatial
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+// checkDepths verifies parent/child depth and parent links throughout the tree
+func checkDepths(t *testing.T, n *Node, depth int) {
+	t.Helper()
+	if n.Depth != depth {
+		t.Errorf("Node %+v has depth %d, expected %d", n.Bounds, n.Depth, depth)
+	}
+	if n.Children[0] == nil {
+		return
+	}
+	for _, c := range n.Children {
+		if c.parent != n {
+			t.Errorf("Child %+v has the wrong parent", c.Bounds)
+		}
+		checkDepths(t, c, depth+1)
+	}
+}
+
+// TestAutoExpandEachDirection tests growing the root toward points on every side
+func TestAutoExpandEachDirection(t *testing.T) {
+	targets := []Point{
+		{X: 150, Y: 50, Data: "east"},
+		{X: -50, Y: 50, Data: "west"},
+		{X: 50, Y: 150, Data: "south"},
+		{X: 50, Y: -50, Data: "north"},
+		{X: -50, Y: -50, Data: "north-west"},
+	}
+
+	for _, target := range targets {
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithAutoExpand())
+		original := []Point{{X: 10, Y: 10}, {X: 90, Y: 10}, {X: 10, Y: 90}, {X: 90, Y: 90}}
+		for _, p := range original {
+			qt.Insert(p)
+		}
+
+		if !qt.Insert(target) {
+			t.Errorf("%v: Insert should expand the root", target.Data)
+			continue
+		}
+		if !qt.Root.Bounds.Contains(target) {
+			t.Errorf("%v: root %+v does not contain the point", target.Data, qt.Root.Bounds)
+		}
+		if qt.Root.Bounds.Width != 200 || qt.Root.Bounds.Height != 200 {
+			t.Errorf("%v: expected a single doubling, got %+v", target.Data, qt.Root.Bounds)
+		}
+		if len(qt.Search(qt.Root.Bounds)) != 5 {
+			t.Errorf("%v: expected 5 points after expansion, got %d", target.Data, len(qt.Search(qt.Root.Bounds)))
+		}
+		for _, p := range original {
+			if !qt.Remove(p) {
+				t.Errorf("%v: original point %v should still be removable", target.Data, p)
+			}
+		}
+		checkDepths(t, qt.Root, 0)
+	}
+}
+
+// TestAutoExpandFarPoint tests repeated doubling until the point is contained
+func TestAutoExpandFarPoint(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4), WithAutoExpand())
+	qt.Insert(Point{X: 50, Y: 50, Data: "home"})
+
+	far := Point{X: -100000, Y: 250000, Data: "far"}
+	if !qt.Insert(far) {
+		t.Fatal("Insert of far point failed")
+	}
+	if !qt.Root.Bounds.Contains(far) {
+		t.Fatalf("Root %+v does not contain far point", qt.Root.Bounds)
+	}
+
+	results := qt.KNearest(Point{X: 50, Y: 50}, 1)
+	if len(results) != 1 || results[0].Data != "home" {
+		t.Errorf("Expected original point to stay findable, got %v", results)
+	}
+	if qt.Size() != 2 {
+		t.Errorf("Expected Size 2, got %d", qt.Size())
+	}
+	checkDepths(t, qt.Root, 0)
+}
+
+// TestAutoExpandPastMaxDepth tests that expansion raises MaxDepth along with
+// the depth of the old root, so a tree split down to MaxDepth stays valid and
+// its leaves can still split as far as before
+func TestAutoExpandPastMaxDepth(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 64, Height: 64}, WithCapacity(1), WithMaxDepth(3), WithAutoExpand())
+	for i := 0; i < 8; i++ {
+		qt.Insert(Point{X: float64(i) + 0.5, Y: float64(i) + 0.5})
+	}
+	if s := qt.Stats(); s.MaxDepth != 3 {
+		t.Fatalf("Expected leaves at max depth 3 before expanding, got %d", s.MaxDepth)
+	}
+
+	if !qt.Insert(Point{X: -500, Y: 500}) {
+		t.Fatal("Insert of far point failed")
+	}
+	if errs := qt.Validate(); len(errs) != 0 {
+		t.Fatalf("Tree invalid after expanding: %v", errs)
+	}
+	levels := qt.Root.MaxDepth - 3
+	if levels < 1 {
+		t.Fatalf("Expected MaxDepth raised above 3, got %d", qt.Root.MaxDepth)
+	}
+
+	// Cells of the original finest size still split, and no finer
+	for i := 0; i < 8; i++ {
+		qt.Insert(Point{X: float64(i) + 0.25, Y: float64(i) + 0.25})
+	}
+	if errs := qt.Validate(); len(errs) != 0 {
+		t.Errorf("Tree invalid after inserting below the old root: %v", errs)
+	}
+	if s := qt.Stats(); s.MaxDepth != 3+levels || s.DeepestLeaf.Width != 8 {
+		t.Errorf("Expected the deepest leaves 8 wide at depth %d, got %v at %d", 3+levels, s.DeepestLeaf, s.MaxDepth)
+	}
+	checkDepths(t, qt.Root, 0)
+}
+
+// TestAutoExpandDisabledByDefault tests that trees without the option still reject out-of-bounds points
+func TestAutoExpandDisabledByDefault(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	if qt.Insert(Point{X: 150, Y: 50}) {
+		t.Error("Insert should fail without auto-expansion")
+	}
+	if qt.Root.Bounds.Width != 100 {
+		t.Error("Root should not grow without auto-expansion")
+	}
+}
+
+// TestAutoExpandRejectsNonFinite tests that NaN and Inf can't trigger endless expansion
+func TestAutoExpandRejectsNonFinite(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4), WithAutoExpand())
+
+	for _, p := range []Point{{X: math.NaN(), Y: 1}, {X: math.Inf(1), Y: 1}, {X: 1, Y: math.Inf(-1)}} {
+		if qt.Insert(p) {
+			t.Errorf("Insert(%v) should fail", p)
+		}
+	}
+}
+
+// TestAutoExpandOtherPaths tests expansion through the ID, handle, update and bulk APIs
+func TestAutoExpandOtherPaths(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithAutoExpand())
+
+	if err := qt.InsertWithID("a", Point{X: 120, Y: 10}); err != nil {
+		t.Errorf("InsertWithID should expand, got %v", err)
+	}
+	e := qt.InsertEntry(Point{X: -30, Y: 10})
+	if e == nil {
+		t.Fatal("InsertEntry should expand")
+	}
+	if !qt.MoveEntry(e, -300, -300) {
+		t.Error("MoveEntry should expand")
+	}
+	if err := qt.UpdateByID("a", Point{X: 900, Y: 900}); err != nil {
+		t.Errorf("UpdateByID should expand, got %v", err)
+	}
+	inserted, rejected := qt.InsertAll([]Point{{X: 5000, Y: 5}, {X: -5000, Y: 5}, {X: math.NaN(), Y: 0}})
+	if inserted != 2 || len(rejected) != 1 {
+		t.Errorf("Expected 2 inserted and 1 rejected, got %d and %d", inserted, len(rejected))
+	}
+	if got := len(qt.Search(qt.Root.Bounds)); got != 4 || qt.Size() != 4 {
+		t.Errorf("Expected 4 points, Search found %d and Size is %d", got, qt.Size())
+	}
+	checkDepths(t, qt.Root, 0)
+}
+
+// TestAutoExpandConcurrentReaders tests expansion while searches are running
+func TestAutoExpandConcurrentReaders(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10, Height: 10}, WithCapacity(4), WithAutoExpand())
+	qt.Insert(Point{X: 5, Y: 5, Data: "origin"})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 200; i++ {
+			qt.Insert(Point{X: float64(i * 50), Y: float64(-i * 30)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if len(qt.Search(Bounds{X: 0, Y: 0, Width: 10, Height: 10})) != 1 {
+				t.Error("Origin point should always be visible")
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	checkValid(t, qt)
+}
@@ -0,0 +1,270 @@
+package spatial
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// A snapshot file is a small header followed by the WriteTo encoding,
+// compressed as the header says:
+//
+//	magic "SPQF", file format version (uint16), tree version (uint64),
+//	compression (byte), CRC-32 (IEEE) of the header bytes before it (uint32)
+//
+// Format 1 had no compression byte and is still read.
+const (
+	fileMagic        = "SPQF"
+	fileVersion      = 2
+	fileHeaderSize   = len(fileMagic) + 2 + 8 + 1 + 4
+	fileHeaderSizeV1 = fileHeaderSize - 1
+)
+
+// Compression selects how SaveToFileCompressed compresses a snapshot
+type Compression uint8
+
+const (
+	// CompressNone stores the encoding as is, as SaveToFile does
+	CompressNone Compression = iota
+	// CompressGzip compresses the encoding with gzip
+	CompressGzip
+)
+
+// CompressionError is returned by LoadFromFile for a snapshot compressed in a
+// way this version can't read. It matches ErrUnknownCompression.
+type CompressionError struct {
+	Compression Compression
+}
+
+func (e *CompressionError) Error() string {
+	return fmt.Sprintf("spatial: unknown snapshot compression %d", e.Compression)
+}
+
+func (e *CompressionError) Unwrap() error {
+	return ErrUnknownCompression
+}
+
+// SaveToFile writes the tree to path, as WriteTo does, in a way a crash can't
+// corrupt: it writes and syncs a temporary file in the same directory, moves
+// the current file, if any, to path+".prev", then renames the temporary file
+// into place. LoadFromFile falls back to the .prev file, so some complete
+// snapshot survives a crash at any point. The file records the tree's
+// Version, which LastSavedVersion then reports. With WithWAL, the log starts a
+// new segment and drops the ones the replaced snapshot already covered.
+func (qt *QuadTree) SaveToFile(path string) error {
+	return qt.saveFile(path, CompressNone)
+}
+
+// SaveToFileCompressed is SaveToFile compressing the snapshot with c as it
+// is written, so the encoding is never held in memory. LoadFromFile reads
+// the compression from the file.
+func (qt *QuadTree) SaveToFileCompressed(path string, c Compression) error {
+	if c > CompressGzip {
+		return &CompressionError{Compression: c}
+	}
+	return qt.saveFile(path, c)
+}
+
+func (qt *QuadTree) saveFile(path string, c Compression) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	version, err := qt.writeFile(tmp, c)
+	if err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path, path+".prev"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	prev := qt.lastSaved.Swap(version)
+	qt.wal.checkpoint(version, prev)
+	return nil
+}
+
+// writeFile writes the header and encoding under one read lock, returning
+// the version written
+func (qt *QuadTree) writeFile(w io.Writer, c Compression) (uint64, error) {
+	qt.rlockAll()
+	defer qt.runlockAll()
+
+	header := append([]byte(fileMagic), 0, 0)
+	binary.LittleEndian.PutUint16(header[len(fileMagic):], fileVersion)
+	header = binary.LittleEndian.AppendUint64(header, qt.version)
+	header = append(header, byte(c))
+	header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(header))
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+	if c == CompressNone {
+		_, err := qt.writeTo(w)
+		return qt.version, err
+	}
+	gz := gzip.NewWriter(w)
+	if _, err := qt.writeTo(gz); err != nil {
+		return 0, err
+	}
+	return qt.version, gz.Close()
+}
+
+// syncDir makes a rename in dir durable. Not every platform can sync a
+// directory, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// LoadFromFile reads a tree saved by SaveToFile, applying opts as Load does.
+// If path is missing or corrupt, it loads path+".prev" instead, the snapshot
+// SaveToFile replaced, and returns the error for path only when both fail;
+// corruption matches ErrCorruptEncoding. Compressed and uncompressed files
+// are both read; a compression this version doesn't know returns a
+// *CompressionError, and a newer file format a *FormatError. Files in older
+// formats are upgraded as they are read. The loaded tree continues from the
+// saved Version, and LastSavedVersion reports it, so the caller knows which
+// mutations to replay.
+func LoadFromFile(path string, opts ...Option) (*QuadTree, error) {
+	qt, err := loadFile(path, opts)
+	if err == nil {
+		return qt, nil
+	}
+	if prev, prevErr := loadFile(path+".prev", opts); prevErr == nil {
+		return prev, nil
+	}
+	return nil, err
+}
+
+func loadFile(path string, opts []Option) (*QuadTree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	version, in, err := openSnapshot(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	qt, err := Load(in, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := trailing(in, r); err != nil {
+		qt.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	qt.Lock.Lock()
+	qt.version = version
+	qt.unlock()
+	qt.lastSaved.Store(version)
+	return qt, nil
+}
+
+// openSnapshot reads a snapshot file's header from r, returning the tree
+// version it records and a reader of the encoding after it
+func openSnapshot(r *bufio.Reader) (version uint64, in *bufio.Reader, err error) {
+	header := make([]byte, fileHeaderSize)
+	if _, err := io.ReadFull(r, header[:len(fileMagic)+2]); err != nil {
+		return 0, nil, corrupt("truncated in file header")
+	}
+	if string(header[:len(fileMagic)]) != fileMagic {
+		return 0, nil, corrupt("not a snapshot file")
+	}
+	format := binary.LittleEndian.Uint16(header[len(fileMagic):])
+	switch format {
+	case 1:
+		header = header[:fileHeaderSizeV1]
+	case fileVersion:
+	case 0:
+		return 0, nil, corrupt("unsupported file version %d", format)
+	default:
+		return 0, nil, &FormatError{Kind: "snapshot file", Format: format, Supported: fileVersion}
+	}
+	if _, err := io.ReadFull(r, header[len(fileMagic)+2:]); err != nil {
+		return 0, nil, corrupt("truncated in file header")
+	}
+	body := header[:len(header)-4]
+	if binary.LittleEndian.Uint32(header[len(body):]) != crc32.ChecksumIEEE(body) {
+		return 0, nil, corrupt("file header checksum mismatch")
+	}
+	version = binary.LittleEndian.Uint64(header[len(fileMagic)+2:])
+	compression := CompressNone
+	if format > 1 {
+		compression = Compression(header[len(fileMagic)+2+8])
+	}
+
+	switch compression {
+	case CompressNone:
+		return version, r, nil
+	case CompressGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, nil, corrupt("gzip: %v", err)
+		}
+		gz.Multistream(false)
+		return version, bufio.NewReader(corruptReader{gz}), nil
+	}
+	return 0, nil, &CompressionError{Compression: compression}
+}
+
+// trailing checks that in, and the file r under it, are both at their end
+func trailing(in, r *bufio.Reader) error {
+	for _, br := range []*bufio.Reader{in, r} {
+		switch _, err := br.ReadByte(); {
+		case err == nil:
+			return corrupt("data after the snapshot")
+		case err == io.ErrUnexpectedEOF:
+			return corrupt("truncated after the snapshot")
+		case err != io.EOF:
+			return err
+		}
+	}
+	return nil
+}
+
+// corruptReader reports a decompressor's errors as corruption. Truncation
+// stays io.ErrUnexpectedEOF, which the decoder reports itself.
+type corruptReader struct {
+	r io.Reader
+}
+
+func (c corruptReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		err = corrupt("%v", err)
+	}
+	return n, err
+}
+
+// LastSavedVersion returns the Version written by the last SaveToFile, or
+// read by LoadFromFile, and 0 if neither has happened. Mutations after it
+// are not on disk.
+func (qt *QuadTree) LastSavedVersion() uint64 {
+	return qt.lastSaved.Load()
+}
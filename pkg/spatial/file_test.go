@@ -0,0 +1,211 @@
+package spatial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestSaveToFile tests a save and load round trip and the saved version
+func TestSaveToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drivers.snap")
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithLockFreeReads())
+	qt.InsertWithID("a", Point{X: 1, Y: 2, Data: "van"})
+	qt.Insert(Point{X: 3, Y: 4})
+	if qt.LastSavedVersion() != 0 {
+		t.Fatal("Expected no saved version before the first save")
+	}
+	if err := qt.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile: %v", err)
+	}
+	saved := qt.Version()
+	if qt.LastSavedVersion() != saved {
+		t.Errorf("Expected LastSavedVersion %d, got %d", saved, qt.LastSavedVersion())
+	}
+	qt.Move("a", 5, 5)
+
+	loaded, err := LoadFromFile(path, WithLockFreeReads())
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if p, ok := loaded.GetByID("a"); !ok || p.X != 1 || p.Data != "van" || loaded.Size() != 2 {
+		t.Errorf("Expected the saved contents, got %+v and %d points", p, loaded.Size())
+	}
+	if loaded.Version() != saved || loaded.LastSavedVersion() != saved {
+		t.Errorf("Expected the loaded tree at version %d, got %d and %d", saved, loaded.Version(), loaded.LastSavedVersion())
+	}
+	loaded.Move("a", 5, 5)
+	if loaded.Version() != saved+1 {
+		t.Errorf("Expected versions to continue from %d, got %d", saved, loaded.Version())
+	}
+
+	matches, _ := filepath.Glob(path + ".tmp-*")
+	if len(matches) != 0 {
+		t.Errorf("Expected no temporary files left, got %v", matches)
+	}
+}
+
+// TestLoadFromFileCorrupt tests that a truncated or damaged file fails to
+// load cleanly, and that the previous snapshot is used when there is one
+func TestLoadFromFileCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "drivers.snap")
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.InsertWithID("a", Point{X: 1, Y: 1})
+	if err := qt.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	first := qt.Version()
+	qt.InsertWithID("b", Point{X: 2, Y: 2})
+	if err := qt.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash mid-write leaves the latest file truncated
+	for _, n := range []int{0, 5, fileHeaderSize, len(data) / 2, len(data) - 1} {
+		if err := os.WriteFile(path, data[:n], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadFromFile(path)
+		if err != nil {
+			t.Fatalf("Truncated to %d bytes: expected the previous snapshot, got %v", n, err)
+		}
+		if _, ok := loaded.GetByID("b"); ok || loaded.LastSavedVersion() != first {
+			t.Errorf("Truncated to %d bytes: expected the previous snapshot at version %d", n, first)
+		}
+	}
+
+	// Without a previous snapshot, loading fails cleanly
+	os.Remove(path + ".prev")
+	for _, damage := range []func([]byte) []byte{
+		func(b []byte) []byte { return b[:len(b)/2] },
+		func(b []byte) []byte { b[6]++; return b }, // Version in the file header
+		func(b []byte) []byte { return append(b, 0) },
+	} {
+		os.WriteFile(path, damage(append([]byte(nil), data...)), 0o644)
+		if _, err := LoadFromFile(path); !errors.Is(err, ErrCorruptEncoding) {
+			t.Errorf("Expected ErrCorruptEncoding, got %v", err)
+		}
+	}
+	if _, err := LoadFromFile(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", err)
+	}
+}
+
+// TestSaveToFileCompressed tests gzip round trips, reading format 1 files,
+// and rejection of unknown compressions and damaged compressed data
+func TestSaveToFileCompressed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "drivers.snap")
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	for i := 0; i < 1000; i++ {
+		qt.InsertWithID(fmt.Sprint(i), Point{X: float64(i % 100), Y: float64(i / 10), Data: "van"})
+	}
+	want := walContents(qt)
+	if err := qt.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := os.Stat(path)
+	if err := qt.SaveToFileCompressed(path, CompressGzip); err != nil {
+		t.Fatal(err)
+	}
+	compressed, _ := os.Stat(path)
+	if compressed.Size() >= plain.Size()/2 {
+		t.Errorf("Expected gzip to at least halve %d bytes, got %d", plain.Size(), compressed.Size())
+	}
+	loaded, err := LoadFromFile(path)
+	if err != nil || !slices.Equal(walContents(loaded), want) || loaded.LastSavedVersion() != qt.Version() {
+		t.Fatalf("Expected the compressed snapshot back, got %v", err)
+	}
+
+	// Format 1: no compression byte
+	var v1 bytes.Buffer
+	header := binary.LittleEndian.AppendUint16([]byte(fileMagic), 1)
+	header = binary.LittleEndian.AppendUint64(header, 7)
+	v1.Write(binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(header)))
+	qt.WriteTo(&v1)
+	os.WriteFile(path, v1.Bytes(), 0o644)
+	if loaded, err := LoadFromFile(path); err != nil || loaded.Size() != 1000 || loaded.Version() != 7 {
+		t.Errorf("Expected a format 1 file to load, got %v", err)
+	}
+
+	os.Remove(path + ".prev")
+	if err := qt.SaveToFileCompressed(path, 9); !errors.Is(err, ErrUnknownCompression) {
+		t.Errorf("Expected saving with compression 9 to fail, got %v", err)
+	}
+	qt.SaveToFileCompressed(path, CompressGzip)
+	os.Remove(path + ".prev")
+	data, _ := os.ReadFile(path)
+	unknown := append([]byte(nil), data...)
+	unknown[fileHeaderSize-5] = 9
+	binary.LittleEndian.PutUint32(unknown[fileHeaderSize-4:], crc32.ChecksumIEEE(unknown[:fileHeaderSize-4]))
+	os.WriteFile(path, unknown, 0o644)
+	var cerr *CompressionError
+	if _, err := LoadFromFile(path); !errors.As(err, &cerr) || cerr.Compression != 9 || !errors.Is(err, ErrUnknownCompression) {
+		t.Errorf("Expected a *CompressionError for compression 9, got %v", err)
+	}
+	for _, damage := range []func([]byte) []byte{
+		func(b []byte) []byte { return b[:fileHeaderSize+5] },
+		func(b []byte) []byte { return b[:len(b)-1] },
+		func(b []byte) []byte { b[len(b)/2] ^= 0xff; return b },
+		func(b []byte) []byte { b[len(b)-8] ^= 1; return b }, // gzip's CRC-32
+		func(b []byte) []byte { return append(b, 0) },
+	} {
+		os.WriteFile(path, damage(append([]byte(nil), data...)), 0o644)
+		if _, err := LoadFromFile(path); !errors.Is(err, ErrCorruptEncoding) {
+			t.Errorf("Expected ErrCorruptEncoding, got %v", err)
+		}
+	}
+}
+
+// BenchmarkSaveToFile compares snapshot size and save time with and without
+// compression
+func BenchmarkSaveToFile(b *testing.B) {
+	qt := benchmarkEncodeTree()
+	for _, c := range []struct {
+		name        string
+		compression Compression
+	}{{"none", CompressNone}, {"gzip", CompressGzip}} {
+		b.Run(c.name, func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "tree.snap")
+			for i := 0; i < b.N; i++ {
+				if err := qt.SaveToFileCompressed(path, c.compression); err != nil {
+					b.Fatal(err)
+				}
+			}
+			info, _ := os.Stat(path)
+			b.ReportMetric(float64(info.Size()), "file-bytes")
+		})
+	}
+}
+
+func BenchmarkLoadFromFile(b *testing.B) {
+	qt := benchmarkEncodeTree()
+	for _, c := range []struct {
+		name        string
+		compression Compression
+	}{{"none", CompressNone}, {"gzip", CompressGzip}} {
+		b.Run(c.name, func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "tree.snap")
+			if err := qt.SaveToFileCompressed(path, c.compression); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := LoadFromFile(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
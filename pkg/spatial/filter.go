@@ -0,0 +1,186 @@
+package spatial
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+)
+
+// WithExistenceFilter keeps a counting Bloom filter alongside the tree so that
+// Contains and FindByKey answer most misses without looking anything up. It
+// holds a fingerprint of every tracked point's id and coordinates and of every
+// data key, and is sized for expected entries at falsePositiveRate; past that
+// the rate climbs. A hit is always confirmed against the tree, so the filter
+// only ever costs a wasted lookup, never a wrong answer. Removals decrement
+// the counters; a counter that saturates stays put until Compact rebuilds the
+// filter, sized for the larger of expected and the entries then present.
+func WithExistenceFilter(expected int, falsePositiveRate float64) Option {
+	return func(qt *QuadTree) {
+		qt.filter = &existenceFilter{} // Sized by NewQuadTree once validated
+		qt.filterExpected = expected
+		qt.filterRate = falsePositiveRate
+	}
+}
+
+// Contains reports whether the point stored under id lies exactly at (x, y),
+// ignoring any match epsilon. Points placed with InsertEntry have no id and
+// never match.
+func (qt *QuadTree) Contains(id string, x, y float64) bool {
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	qt.meta.RLock()
+	maybe := qt.filter.mayContain(qt.filter.idPrint(id, x, y))
+	qt.meta.RUnlock()
+	if !maybe {
+		return false
+	}
+	loc, q, ok := qt.lockLoc(id, false)
+	if !ok {
+		return false
+	}
+	defer qt.quads[q].RUnlock()
+	p, ok := loc.point()
+	return ok && p.X == x && p.Y == y
+}
+
+// existenceFilter is a counting Bloom filter over point and key fingerprints.
+// It is guarded like the ID index: written under meta or the exclusive root
+// lock, read under meta.
+type existenceFilter struct {
+	seed     maphash.Seed
+	counters []uint8
+	hashes   int
+}
+
+// newExistenceFilter sizes a filter for n entries at false-positive rate p
+func newExistenceFilter(n int, p float64) *existenceFilter {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := max(1, int(math.Round(m/float64(n)*math.Ln2)))
+	return &existenceFilter{seed: maphash.MakeSeed(), counters: make([]uint8, int(m)), hashes: k}
+}
+
+// Fingerprints are tagged so that an id and a data key never collide by design
+const (
+	idTag  = 'i'
+	keyTag = 'k'
+)
+
+// idPrint fingerprints id and its coordinates. Negative zero is folded into
+// zero, since both compare equal. Safe on a nil filter.
+func (f *existenceFilter) idPrint(id string, x, y float64) uint64 {
+	if f == nil {
+		return 0
+	}
+	var h maphash.Hash
+	h.SetSeed(f.seed)
+	h.WriteByte(idTag)
+	h.WriteString(id)
+	var coords [16]byte
+	binary.LittleEndian.PutUint64(coords[:8], math.Float64bits(canonicalZero(x)))
+	binary.LittleEndian.PutUint64(coords[8:], math.Float64bits(canonicalZero(y)))
+	h.Write(coords[:])
+	return h.Sum64()
+}
+
+func canonicalZero(v float64) float64 {
+	if v == 0 {
+		return 0
+	}
+	return v
+}
+
+// keyPrint fingerprints a data key. Safe on a nil filter.
+func (f *existenceFilter) keyPrint(key string) uint64 {
+	if f == nil {
+		return 0
+	}
+	var h maphash.Hash
+	h.SetSeed(f.seed)
+	h.WriteByte(keyTag)
+	h.WriteString(key)
+	return h.Sum64()
+}
+
+// slot returns the counter for the i-th probe of fp, by double hashing
+func (f *existenceFilter) slot(fp uint64, i int) *uint8 {
+	h1, h2 := uint32(fp), uint32(fp>>32)|1
+	return &f.counters[uint64(h1+uint32(i)*h2)%uint64(len(f.counters))]
+}
+
+// mayContain reports false only if fp was never added; a nil filter always
+// reports true
+func (f *existenceFilter) mayContain(fp uint64) bool {
+	if f == nil {
+		return true
+	}
+	for i := 0; i < f.hashes; i++ {
+		if *f.slot(fp, i) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add counts fp in. Saturated counters stay saturated.
+func (f *existenceFilter) add(fp uint64) {
+	if f == nil {
+		return
+	}
+	for i := 0; i < f.hashes; i++ {
+		if c := f.slot(fp, i); *c < math.MaxUint8 {
+			*c++
+		}
+	}
+}
+
+// remove counts fp out. A saturated counter may hold more entries than it
+// can count, so it is never decremented.
+func (f *existenceFilter) remove(fp uint64) {
+	if f == nil {
+		return
+	}
+	for i := 0; i < f.hashes; i++ {
+		if c := f.slot(fp, i); *c > 0 && *c < math.MaxUint8 {
+			*c--
+		}
+	}
+}
+
+// addPoint and removePoint count a tracked point's id and coordinates in and
+// out. Untracked points are skipped.
+func (f *existenceFilter) addPoint(p Point) {
+	if p.loc != nil {
+		f.add(f.idPrint(p.loc.id, p.X, p.Y))
+	}
+}
+
+func (f *existenceFilter) removePoint(p Point) {
+	if p.loc != nil {
+		f.remove(f.idPrint(p.loc.id, p.X, p.Y))
+	}
+}
+
+// rebuildFilter replaces the filter with one holding exactly the tracked
+// points and data keys now present, clearing saturated counters and growing
+// it if the tree has outgrown the expected size. Callers must hold the write lock.
+func (qt *QuadTree) rebuildFilter() {
+	if qt.filter == nil {
+		return
+	}
+	var points []Point
+	qt.Root.collectPoints(&points)
+	entries := len(qt.byKey)
+	for _, p := range points {
+		if p.loc != nil {
+			entries++
+		}
+	}
+	qt.filter = newExistenceFilter(max(qt.filterExpected, entries), qt.filterRate)
+	for _, p := range points {
+		qt.filter.addPoint(p)
+	}
+	// The filter holds each key once, while the key has points
+	for key := range qt.byKey {
+		qt.filter.add(qt.filter.keyPrint(key))
+	}
+}
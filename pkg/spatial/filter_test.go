@@ -0,0 +1,192 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// TestContains tests Contains with and without a filter
+func TestContains(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithExistenceFilter(100, 0.01)}} {
+		qt := mustNewQuadTree(Bounds{X: -10, Y: -10, Width: 20, Height: 20}, opts...)
+		qt.InsertWithID("a", Point{X: 0, Y: 3})
+		qt.InsertWithID("b", Point{X: 1, Y: 1})
+		qt.InsertEntry(Point{X: 2, Y: 2})
+
+		if !qt.Contains("a", 0, 3) || !qt.Contains("a", math.Copysign(0, -1), 3) {
+			t.Error("Expected a to be found at (0, 3) and (-0, 3)")
+		}
+		if qt.Contains("a", 1, 1) || qt.Contains("b", 1, 1.5) || qt.Contains("c", 1, 1) || qt.Contains("", 2, 2) {
+			t.Error("Expected wrong ids, coordinates and untracked points to miss")
+		}
+		qt.Move("b", 4, 4)
+		if qt.Contains("b", 1, 1) || !qt.Contains("b", 4, 4) {
+			t.Error("Expected Contains to follow a Move")
+		}
+		qt.RemoveByID("a")
+		if qt.Contains("a", 0, 3) {
+			t.Error("Expected a removed id to miss")
+		}
+	}
+}
+
+// TestExistenceFilterNeverMisses churns a tree through a filter far too small
+// for it, so entries share counters, and checks every stored id and key is
+// still found
+func TestExistenceFilterNeverMisses(t *testing.T) {
+	keyOf := func(p Point) (string, bool) {
+		s, ok := p.Data.(string)
+		return s, ok
+	}
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+		WithCapacity(4), WithDataKey(keyOf), WithExistenceFilter(16, 0.1))
+	live := make(map[string]Point)
+	keys := make(map[string]int)
+	entries := make([]*Entry, 0)
+	rng := rand.New(rand.NewSource(1))
+	randomPoint := func() Point {
+		// A coarse grid so moves land on earlier coordinates
+		return Point{X: float64(rng.Intn(50)) * 2, Y: float64(rng.Intn(50)) * 2, Data: strconv.Itoa(rng.Intn(40))}
+	}
+	forget := func(id string) {
+		if keys[live[id].Data.(string)]--; keys[live[id].Data.(string)] == 0 {
+			delete(keys, live[id].Data.(string))
+		}
+		delete(live, id)
+	}
+
+	for step := 0; step < 20000; step++ {
+		id := fmt.Sprintf("v%d", rng.Intn(1000))
+		switch op := rng.Intn(19); {
+		case op < 6:
+			p := randomPoint()
+			if _, exists := live[id]; exists {
+				forget(id)
+			}
+			qt.Upsert(id, p)
+			live[id] = p
+			keys[p.Data.(string)]++
+		case op < 10:
+			if p, exists := live[id]; exists {
+				p.X, p.Y = randomPoint().X, randomPoint().Y
+				qt.Move(id, p.X, p.Y)
+				live[id] = p
+			}
+		case op < 14:
+			if _, exists := live[id]; exists {
+				qt.RemoveByID(id)
+				forget(id)
+			}
+		case op < 17:
+			entries = append(entries, qt.InsertEntry(Point{X: randomPoint().X, Y: randomPoint().Y}))
+		case op < 18:
+			if len(entries) > 0 {
+				qt.RemoveEntry(entries[len(entries)-1])
+				entries = entries[:len(entries)-1]
+			}
+		case step%5000 == 4999:
+			qt.Clear()
+			live = make(map[string]Point)
+			keys = make(map[string]int)
+			entries = entries[:0]
+		case step%1000 == 999:
+			qt.Compact()
+		}
+
+		if step%100 != 0 {
+			continue
+		}
+		for id, p := range live {
+			if !qt.Contains(id, p.X, p.Y) {
+				t.Fatalf("Step %d: false negative for %s at (%v, %v)", step, id, p.X, p.Y)
+			}
+		}
+		for key, n := range keys {
+			if got := len(qt.FindByKey(key)); got != n {
+				t.Fatalf("Step %d: FindByKey(%q) found %d points, want %d", step, key, got, n)
+			}
+		}
+	}
+}
+
+// TestExistenceFilterSaturation tests that a saturated counter keeps the
+// entries it lost count of
+func TestExistenceFilterSaturation(t *testing.T) {
+	f := newExistenceFilter(1, 0.5)
+	for i := 0; i < 600; i++ {
+		f.add(f.keyPrint(strconv.Itoa(i)))
+	}
+	if !slices.Contains(f.counters, math.MaxUint8) {
+		t.Fatalf("Expected a saturated counter, got %v", f.counters)
+	}
+	for i := 1; i < 600; i++ {
+		f.remove(f.keyPrint(strconv.Itoa(i)))
+	}
+	if !f.mayContain(f.keyPrint("0")) {
+		t.Error("Expected the remaining key to survive saturation")
+	}
+}
+
+// TestExistenceFilterRate tests that the filter turns away about as many
+// misses as configured, and that Compact grows a filter that has been outgrown
+func TestExistenceFilterRate(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithExistenceFilter(10000, 0.01))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		qt.InsertWithID(strconv.Itoa(i), Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+	rate := func() float64 {
+		positives := 0
+		for i := 0; i < 100000; i++ {
+			// Known ids at the wrong place, the common idempotency miss
+			if qt.filter.mayContain(qt.filter.idPrint(strconv.Itoa(i%10000), rng.Float64()*1000, rng.Float64()*1000)) {
+				positives++
+			}
+		}
+		return float64(positives) / 100000
+	}
+	if got := rate(); got > 0.02 {
+		t.Errorf("Expected a false-positive rate near 1%%, got %.3f", got)
+	}
+
+	for i := 10000; i < 40000; i++ {
+		qt.InsertWithID(strconv.Itoa(i), Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+	outgrown := rate()
+	qt.Compact()
+	if got := rate(); got > 0.02 || got >= outgrown {
+		t.Errorf("Expected Compact to bring the rate from %.3f back near 1%%, got %.3f", outgrown, got)
+	}
+
+	for _, opt := range []Option{WithExistenceFilter(0, 0.01), WithExistenceFilter(10, 0), WithExistenceFilter(10, 1), WithExistenceFilter(10, math.NaN())} {
+		if _, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 1, Height: 1}, opt); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter, got %v", err)
+		}
+	}
+}
+
+func BenchmarkContainsMissFiltered(b *testing.B) {
+	benchmarkContainsMiss(b, WithExistenceFilter(100000, 0.01))
+}
+func BenchmarkContainsMissUnfiltered(b *testing.B) { benchmarkContainsMiss(b) }
+
+// benchmarkContainsMiss asks about stored ids at positions they are not at,
+// as a replayed ingestion batch with fresh coordinates does
+func benchmarkContainsMiss(b *testing.B, opts ...Option) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, append(opts, WithCapacity(10))...)
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]string, 100000)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+		qt.InsertWithID(ids[i], Point{X: rng.Float64() * 10000, Y: rng.Float64() * 10000})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt.Contains(ids[i%len(ids)], float64(i%10000), 1)
+	}
+}
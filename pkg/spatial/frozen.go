@@ -0,0 +1,395 @@
+package spatial
+
+import (
+	"container/heap"
+	"math"
+)
+
+// FrozenTree is an immutable copy of a QuadTree laid out in flat arrays. It
+// has no lock and no mutating methods, so any number of goroutines may query
+// it concurrently.
+type FrozenTree struct {
+	nodes  []frozenNode // nodes[0] is the root; the four children of a node are adjacent
+	xs, ys []float64    // Coordinates of every point; every subtree's points are contiguous
+	points []Point      // The points themselves, nil when served from a snapshot file
+	file   *frozenFile  // The snapshot file served from, see OpenSnapshot
+	geo    bool
+	metric Metric    // Copied from WithMetric, nil for the default ranking
+	codec  DataCodec // Copied from WithDataCodec, for SaveSnapshot
+}
+
+type frozenNode struct {
+	bounds     Bounds
+	children   int32 // Index of the first child, or -1 for a leaf
+	start, end int32 // Range of the subtree's points
+}
+
+// Freeze returns an immutable copy of the tree. Points that have already
+// expired are left out, and TTLs are not applied afterwards.
+func (qt *QuadTree) Freeze() *FrozenTree {
+	qt.rlockAll()
+	defer qt.runlockAll()
+
+	f := &FrozenTree{
+		nodes:  make([]frozenNode, 1),
+		xs:     make([]float64, 0, qt.Root.count),
+		ys:     make([]float64, 0, qt.Root.count),
+		points: make([]Point, 0, qt.Root.count),
+		geo:    qt.geo,
+		metric: qt.metric,
+		codec:  qt.codec,
+	}
+	now := int64(0)
+	if qt.expiring {
+		now = qt.clock().UnixNano()
+	}
+	f.freeze(qt.Root, 0, now)
+	return f
+}
+
+// freeze copies n into f.nodes[idx], appending its points depth first
+func (f *FrozenTree) freeze(n *Node, idx int32, now int64) {
+	start := int32(len(f.points))
+	children := int32(-1)
+	if n.Children[0] != nil {
+		children = int32(len(f.nodes))
+		f.nodes = append(f.nodes, make([]frozenNode, 4)...)
+		for i, c := range n.Children {
+			f.freeze(c, children+int32(i), now)
+		}
+	} else {
+		for _, p := range n.Points {
+			if now == 0 || !expired(p, now) {
+				f.points = append(f.points, p)
+				f.xs = append(f.xs, p.X)
+				f.ys = append(f.ys, p.Y)
+			}
+		}
+	}
+	f.nodes[idx] = frozenNode{bounds: n.Bounds, children: children, start: start, end: int32(len(f.points))}
+}
+
+// point returns point i
+func (f *FrozenTree) point(i int32) Point {
+	if f.points != nil {
+		return f.points[i]
+	}
+	return f.file.point(i, f.xs[i], f.ys[i])
+}
+
+// probe returns point i for testing against a query: the point itself in
+// memory, but only its coordinates from a snapshot file, so points that
+// don't match are never decoded
+func (f *FrozenTree) probe(i int32) Point {
+	if f.points != nil {
+		return f.points[i]
+	}
+	return Point{X: f.xs[i], Y: f.ys[i]}
+}
+
+// Bounds returns the area covered by the tree
+func (f *FrozenTree) Bounds() Bounds {
+	return f.nodes[0].bounds
+}
+
+// Count returns how many points the tree holds
+func (f *FrozenTree) Count() int {
+	return len(f.xs)
+}
+
+// ForEach calls fn for every point until fn returns false
+func (f *FrozenTree) ForEach(fn func(Point) bool) {
+	for i := range f.xs {
+		if !fn(f.point(int32(i))) {
+			return
+		}
+	}
+}
+
+// Search returns every point inside area, in the same order as QuadTree.Search
+func (f *FrozenTree) Search(area Bounds) []Point {
+	results := make([]Point, 0)
+	f.search(area, &results)
+	return results
+}
+
+func (f *FrozenTree) search(area Bounds, results *[]Point) {
+	var stack [64]int32
+	todo := append(stack[:0], 0)
+	for len(todo) > 0 {
+		n := &f.nodes[todo[len(todo)-1]]
+		todo = todo[:len(todo)-1]
+		if !n.bounds.Intersects(area) {
+			continue
+		}
+		if area.containsBounds(n.bounds) {
+			if f.points != nil {
+				*results = append(*results, f.points[n.start:n.end]...)
+				continue
+			}
+			for i := n.start; i < n.end; i++ {
+				*results = append(*results, f.point(i))
+			}
+			continue
+		}
+		if n.children >= 0 {
+			// Pushed in reverse so children are visited in quadrant order
+			for i := int32(3); i >= 0; i-- {
+				todo = append(todo, n.children+i)
+			}
+			continue
+		}
+		for i := n.start; i < n.end; i++ {
+			if area.Contains(Point{X: f.xs[i], Y: f.ys[i]}) {
+				*results = append(*results, f.point(i))
+			}
+		}
+	}
+}
+
+// containsBounds reports whether other lies entirely inside b
+func (b Bounds) containsBounds(other Bounds) bool {
+	return other.X >= b.X && other.Y >= b.Y &&
+		other.X+other.Width <= b.X+b.Width &&
+		other.Y+other.Height <= b.Y+b.Height
+}
+
+// SearchRadius returns every point within radius of center, measured by the
+// tree's WithMetric if it had one. Otherwise, for a tree built with
+// WithGeoCoordinates the radius is in meters along the Earth's surface, and
+// for any other it is planar.
+func (f *FrozenTree) SearchRadius(center Point, radius float64) []Point {
+	results := make([]Point, 0)
+	if radius < 0 {
+		return results
+	}
+	if f.metric != nil {
+		f.withinMetric(0, center, radius, &results)
+		return results
+	}
+	for _, pd := range f.withinRadius(center, radius) {
+		results = append(results, pd.Point)
+	}
+	return results
+}
+
+// withinMetric appends the points of node idx's subtree within radius of
+// center under f.metric
+func (f *FrozenTree) withinMetric(idx int32, center Point, radius float64, results *[]Point) {
+	n := &f.nodes[idx]
+	if f.metric.MinDistToBounds(center, n.bounds) > radius {
+		return
+	}
+	if n.children >= 0 {
+		for i := int32(0); i < 4; i++ {
+			f.withinMetric(n.children+i, center, radius, results)
+		}
+		return
+	}
+	for i := n.start; i < n.end; i++ {
+		if f.metric.Distance(center, f.probe(i)) <= radius {
+			*results = append(*results, f.point(i))
+		}
+	}
+}
+
+func (f *FrozenTree) withinRadius(center Point, radius float64) []PointWithDistance {
+	boxes := []Bounds{{X: center.X - radius, Y: center.Y - radius, Width: radius * 2, Height: radius * 2}}
+	if f.geo {
+		boxes = BoundingBoxForRadius(FromPoint(center, nil), radius).Split()
+	}
+	if f.file != nil {
+		// Measure coordinates first, decoding only the points in range
+		var found []PointWithDistance
+		for _, box := range boxes {
+			f.searchCoords(box, func(i int32) {
+				if d, ok := f.within(center, Point{X: f.xs[i], Y: f.ys[i]}, radius); ok {
+					found = append(found, PointWithDistance{Point: f.point(i), Distance: d})
+				}
+			})
+		}
+		return found
+	}
+	candidates := make([]Point, 0)
+	for _, box := range boxes {
+		f.search(box, &candidates)
+	}
+
+	found := make([]PointWithDistance, 0, len(candidates))
+	for _, p := range candidates {
+		if d, ok := f.within(center, p, radius); ok {
+			found = append(found, PointWithDistance{Point: p, Distance: d})
+		}
+	}
+	return found
+}
+
+// within returns the distance from center to p, and whether it is within
+// radius
+func (f *FrozenTree) within(center, p Point, radius float64) (float64, bool) {
+	if f.geo {
+		d := haversine(center, p)
+		return d, d <= radius
+	}
+	d2 := DistanceSquared(center, p)
+	return math.Sqrt(d2), withinSquared(d2, radius, radius*radius)
+}
+
+// searchCoords calls visit with the index of every point inside area
+func (f *FrozenTree) searchCoords(area Bounds, visit func(i int32)) {
+	var stack [64]int32
+	todo := append(stack[:0], 0)
+	for len(todo) > 0 {
+		n := &f.nodes[todo[len(todo)-1]]
+		todo = todo[:len(todo)-1]
+		if !n.bounds.Intersects(area) {
+			continue
+		}
+		if n.children >= 0 && !area.containsBounds(n.bounds) {
+			for i := int32(3); i >= 0; i-- {
+				todo = append(todo, n.children+i)
+			}
+			continue
+		}
+		for i := n.start; i < n.end; i++ {
+			if area.Contains(Point{X: f.xs[i], Y: f.ys[i]}) {
+				visit(i)
+			}
+		}
+	}
+}
+
+// KNearest returns the k points closest to target, nearest first, ranked the
+// same way as QuadTree.KNearest.
+func (f *FrozenTree) KNearest(target Point, k int) []Point {
+	if k <= 0 || len(f.xs) == 0 {
+		return make([]Point, 0)
+	}
+	var best []PointWithDistance
+	switch {
+	case f.metric != nil:
+		best = f.kNearestMetric(target, k)
+	case f.geo:
+		best = f.kNearestGeo(target, k)
+	default:
+		best = f.kNearestPlanar(target, k)
+	}
+	results := make([]Point, len(best))
+	for i, pd := range best {
+		results[i] = pd.Point
+	}
+	return results
+}
+
+// kNearestGeo grows a metric circle until it holds k points, like KNearestGeo
+func (f *FrozenTree) kNearestGeo(target Point, k int) []PointWithDistance {
+	maxRadius := math.Pi * EarthRadiusMeters
+	radius := 1000.0
+	var found []PointWithDistance
+	for {
+		found = f.withinRadius(target, radius)
+		if len(found) >= k || radius >= maxRadius {
+			break
+		}
+		radius = math.Min(radius*2, maxRadius)
+	}
+	sortByDistance(found)
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found
+}
+
+// kNearestPlanar visits nodes nearest first and stops once no unvisited node
+// can hold anything closer than the current kth point. Distances are compared
+// squared, so the returned Distance fields hold squared distances.
+func (f *FrozenTree) kNearestPlanar(target Point, k int) []PointWithDistance {
+	best := make([]PointWithDistance, 0, k+1)
+	queue := nodeQueue{{idx: 0, dist: minDistanceSquared(f.nodes[0].bounds, target)}}
+	for queue.Len() > 0 {
+		item := heap.Pop(&queue).(nodeDistance)
+		// Ties are kept, so equally distant points still rank by insertion order
+		if len(best) == k && item.dist > best[k-1].Distance {
+			break
+		}
+		n := &f.nodes[item.idx]
+		if n.children >= 0 {
+			for i := int32(0); i < 4; i++ {
+				c := n.children + i
+				heap.Push(&queue, nodeDistance{idx: c, dist: minDistanceSquared(f.nodes[c].bounds, target)})
+			}
+			continue
+		}
+		for i := n.start; i < n.end; i++ {
+			d := DistanceSquared(target, f.probe(i))
+			if len(best) == k && d > best[k-1].Distance {
+				continue
+			}
+			best = append(best, PointWithDistance{Point: f.point(i), Distance: d})
+			sortByDistance(best)
+			if len(best) > k {
+				best = best[:k]
+			}
+		}
+	}
+	return best
+}
+
+// kNearestMetric is kNearestPlanar measuring with f.metric
+func (f *FrozenTree) kNearestMetric(target Point, k int) []PointWithDistance {
+	best := make([]PointWithDistance, 0, k+1)
+	queue := nodeQueue{{idx: 0, dist: f.metric.MinDistToBounds(target, f.nodes[0].bounds)}}
+	for queue.Len() > 0 {
+		item := heap.Pop(&queue).(nodeDistance)
+		if len(best) == k && item.dist > best[k-1].Distance {
+			break
+		}
+		n := &f.nodes[item.idx]
+		if n.children >= 0 {
+			for i := int32(0); i < 4; i++ {
+				c := n.children + i
+				heap.Push(&queue, nodeDistance{idx: c, dist: f.metric.MinDistToBounds(target, f.nodes[c].bounds)})
+			}
+			continue
+		}
+		for i := n.start; i < n.end; i++ {
+			d := f.metric.Distance(target, f.probe(i))
+			if len(best) == k && d > best[k-1].Distance {
+				continue
+			}
+			best = append(best, PointWithDistance{Point: f.point(i), Distance: d})
+			sortByDistance(best)
+			if len(best) > k {
+				best = best[:k]
+			}
+		}
+	}
+	return best
+}
+
+// minDistanceSquared is the squared planar distance from p to the nearest
+// point of b
+func minDistanceSquared(b Bounds, p Point) float64 {
+	dx := math.Max(0, math.Max(b.X-p.X, p.X-(b.X+b.Width)))
+	dy := math.Max(0, math.Max(b.Y-p.Y, p.Y-(b.Y+b.Height)))
+	return dx*dx + dy*dy
+}
+
+type nodeDistance struct {
+	idx  int32
+	dist float64
+}
+
+// nodeQueue is a min-heap of nodes by distance
+type nodeQueue []nodeDistance
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(nodeDistance)) }
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
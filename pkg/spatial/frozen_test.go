@@ -0,0 +1,147 @@
+package spatial
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newRandomTree(n int, seed int64) *QuadTree {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8))
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < n; i++ {
+		// Coarse coordinates so that ties and co-located points occur
+		qt.Insert(Point{X: float64(rng.Intn(1000)), Y: float64(rng.Intn(1000)), Data: i})
+	}
+	return qt
+}
+
+// bruteKNearest ranks every point in qt by distance, ties by insertion order
+func bruteKNearest(qt *QuadTree, target Point, k int) []Point {
+	all := qt.Search(qt.Root.Bounds)
+	ranked := make([]PointWithDistance, len(all))
+	for i, p := range all {
+		ranked[i] = PointWithDistance{Point: p, Distance: Distance(target, p)}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Distance != ranked[j].Distance {
+			return ranked[i].Distance < ranked[j].Distance
+		}
+		return ranked[i].Point.seq < ranked[j].Point.seq
+	})
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+	results := make([]Point, len(ranked))
+	for i, pd := range ranked {
+		results[i] = pd.Point
+	}
+	return results
+}
+
+// TestFrozenMatchesMutable tests that a frozen tree answers exactly like its source
+func TestFrozenMatchesMutable(t *testing.T) {
+	qt := newRandomTree(5000, 1)
+	f := qt.Freeze()
+	if f.Count() != qt.Size() {
+		t.Fatalf("Expected %d points, got %d", qt.Size(), f.Count())
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		area := Bounds{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Width: rng.Float64() * 400, Height: rng.Float64() * 400}
+		if got, want := f.Search(area), qt.Search(area); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Search %v: expected %d points, got %d", area, len(want), len(got))
+		}
+
+		target := Point{X: rng.Float64() * 1200, Y: rng.Float64() * 1200}
+		k := 1 + rng.Intn(30)
+		if got, want := f.KNearest(target, k), bruteKNearest(qt, target, k); !reflect.DeepEqual(got, want) {
+			t.Fatalf("KNearest(%v, %d) differs:\nwant %v\ngot  %v", target, k, want, got)
+		}
+	}
+}
+
+// TestFrozenSearchRadius tests planar radius queries against a brute force scan
+func TestFrozenSearchRadius(t *testing.T) {
+	f := newRandomTree(2000, 3).Freeze()
+	center := Point{X: 500, Y: 500}
+
+	want := 0
+	f.ForEach(func(p Point) bool {
+		if Distance(center, p) <= 120 {
+			want++
+		}
+		return true
+	})
+	if got := f.SearchRadius(center, 120); len(got) != want {
+		t.Errorf("Expected %d points within the radius, got %d", want, len(got))
+	}
+	if got := f.SearchRadius(center, -1); len(got) != 0 {
+		t.Error("A negative radius should match nothing")
+	}
+}
+
+// TestFrozenGeo tests that a frozen geo tree keeps great-circle ranking
+func TestFrozenGeo(t *testing.T) {
+	qt := newGeoTree()
+	qt.Insert(Point{X: 10, Y: 80, Data: "east"})
+	qt.Insert(Point{X: 0, Y: 77, Data: "south"})
+	f := qt.Freeze()
+
+	if got := f.KNearest(Point{X: 0, Y: 80}, 1); len(got) != 1 || got[0].Data != "east" {
+		t.Errorf("Expected east point, got %v", got)
+	}
+	if got := f.SearchRadius(Point{X: 0, Y: 80}, 200000); len(got) != 1 || got[0].Data != "east" {
+		t.Errorf("Expected only the east point within 200km, got %v", got)
+	}
+}
+
+// TestFrozenIndependentOfSource tests that later mutations don't reach the frozen copy
+func TestFrozenIndependentOfSource(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.Insert(Point{X: 10, Y: 10})
+	f := qt.Freeze()
+	qt.Insert(Point{X: 20, Y: 20})
+	qt.Remove(Point{X: 10, Y: 10})
+
+	if got := f.Search(f.Bounds()); len(got) != 1 || got[0].X != 10 {
+		t.Errorf("Frozen tree changed: %v", got)
+	}
+	seen := 0
+	f.ForEach(func(Point) bool { seen++; return false })
+	if seen != 1 {
+		t.Error("ForEach should stop when fn returns false")
+	}
+}
+
+// TestFrozenEmpty tests queries on a frozen empty tree
+func TestFrozenEmpty(t *testing.T) {
+	f := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}).Freeze()
+	if f.Count() != 0 || len(f.Search(f.Bounds())) != 0 || len(f.KNearest(Point{X: 1, Y: 1}, 3)) != 0 {
+		t.Error("Empty frozen tree should return no points")
+	}
+}
+
+func benchmarkQueries(b *testing.B, search func(Bounds) []Point) {
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			search(Bounds{X: rng.Float64() * 900, Y: rng.Float64() * 900, Width: 100, Height: 100})
+		}
+	})
+}
+
+func BenchmarkSearchParallelMutable(b *testing.B) {
+	qt := newRandomTree(200000, 1)
+	b.ResetTimer()
+	benchmarkQueries(b, qt.Search)
+}
+
+func BenchmarkSearchParallelFrozen(b *testing.B) {
+	f := newRandomTree(200000, 1).Freeze()
+	b.ResetTimer()
+	benchmarkQueries(b, f.Search)
+}
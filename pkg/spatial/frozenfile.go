@@ -0,0 +1,347 @@
+package spatial
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// A frozen snapshot file holds a FrozenTree's arrays as they sit in memory,
+// so OpenSnapshot can map the file and use them in place:
+//
+//	header: magic "SPQZ", format version (uint16), flags (byte), padding
+//	  (byte), node count, point count, record bytes (uint64s), CRC-32 (IEEE)
+//	  of the header bytes before it (uint32), padding (4 bytes)
+//	nodes: bounds (4 float64), first child, start, end (int32s), padding
+//	  (4 bytes), in FrozenTree order, so each subtree's points are one
+//	  range of the Morton-ordered point arrays
+//	xs, ys: the point coordinates (float64s)
+//	records: per point, its sequence number (uvarint), record flags (byte),
+//	  then if flagged Data (uvarint length and bytes) and expiry (int64),
+//	  padded to a multiple of 8 bytes
+//	offsets: where each record starts, and where the last ends (uint64s)
+//
+// Everything is little-endian and every section starts on an 8-byte boundary.
+const (
+	frozenMagic      = "SPQZ"
+	frozenVersion    = 1
+	frozenHeaderSize = 40
+	frozenNodeSize   = 48
+
+	frozenGeo = 1 << 0
+)
+
+// The node section is used as a []frozenNode directly
+var _ [frozenNodeSize]byte = [unsafe.Sizeof(frozenNode{})]byte{}
+
+// nativeLittleEndian reports whether the file's arrays can be used in place
+var nativeLittleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// frozenFile is the mapped file behind a FrozenTree from OpenSnapshot
+type frozenFile struct {
+	data    []byte // The whole file, mapped
+	records []byte
+	offsets []uint64
+	codec   DataCodec
+}
+
+// point builds point i, at x, y, decoding its record. Data the codec can't
+// decode is left nil.
+func (ff *frozenFile) point(i int32, x, y float64) Point {
+	p := Point{X: x, Y: y}
+	start, end := ff.offsets[i], ff.offsets[i+1]
+	if start > end || end > uint64(len(ff.records)) {
+		return p
+	}
+	b := ff.records[start:end]
+	seq, n := binary.Uvarint(b)
+	if n <= 0 || n >= len(b) {
+		return p
+	}
+	p.seq = seq
+	flags := b[n]
+	b = b[n+1:]
+	if flags&recordData != 0 {
+		size, n := binary.Uvarint(b)
+		if n <= 0 || size > uint64(len(b)-n) {
+			return p
+		}
+		p.Data, _ = ff.codec.DecodeData(b[n : n+int(size)])
+		b = b[n+int(size):]
+	}
+	if flags&recordExpires != 0 && len(b) >= 8 {
+		p.expires = int64(binary.LittleEndian.Uint64(b))
+	}
+	return p
+}
+
+// SaveSnapshot writes the tree to path in the layout OpenSnapshot serves
+// queries from without loading it. Like SaveToFile it writes a temporary
+// file and renames it into place. Data is encoded by the DataCodec of the
+// tree that was frozen.
+func (f *FrozenTree) SaveSnapshot(path string) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(tmp)
+	w.Write(make([]byte, frozenHeaderSize)) // Written last, once the sizes are known
+	var buf []byte
+	for _, n := range f.nodes {
+		buf = buf[:0]
+		for _, v := range []float64{n.bounds.X, n.bounds.Y, n.bounds.Width, n.bounds.Height} {
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		}
+		for _, v := range []int32{n.children, n.start, n.end, 0} {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+		}
+		w.Write(buf)
+	}
+	for _, coords := range [][]float64{f.xs, f.ys} {
+		for _, v := range coords {
+			w.Write(binary.LittleEndian.AppendUint64(buf[:0], math.Float64bits(v)))
+		}
+	}
+
+	codec := f.codec
+	if codec == nil {
+		codec = GobCodec{}
+	}
+	offsets := make([]uint64, 0, len(f.xs)+1)
+	var size uint64
+	for i := range f.xs {
+		offsets = append(offsets, size)
+		p := f.point(int32(i))
+		buf = binary.AppendUvarint(buf[:0], p.seq)
+		var flags byte
+		if p.Data != nil {
+			flags |= recordData
+		}
+		if p.expires != 0 {
+			flags |= recordExpires
+		}
+		buf = append(buf, flags)
+		if p.Data != nil {
+			data, err := codec.EncodeData(p.Data)
+			if err != nil {
+				return fmt.Errorf("spatial: encoding Data of point %d: %w", i, err)
+			}
+			buf = binary.AppendUvarint(buf, uint64(len(data)))
+			buf = append(buf, data...)
+		}
+		if p.expires != 0 {
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(p.expires))
+		}
+		w.Write(buf)
+		size += uint64(len(buf))
+	}
+	offsets = append(offsets, size)
+	padded := (size + 7) &^ 7
+	w.Write(make([]byte, padded-size))
+	for _, off := range offsets {
+		w.Write(binary.LittleEndian.AppendUint64(buf[:0], off))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	header := append([]byte(frozenMagic), 0, 0)
+	binary.LittleEndian.PutUint16(header[len(frozenMagic):], frozenVersion)
+	var flags byte
+	if f.geo {
+		flags |= frozenGeo
+	}
+	header = append(header, flags, 0)
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(f.nodes)))
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(f.xs)))
+	header = binary.LittleEndian.AppendUint64(header, padded)
+	header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(header))
+	header = append(header, 0, 0, 0, 0)
+	if _, err := tmp.WriteAt(header, 0); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// OpenSnapshot maps a file written by SaveSnapshot and serves queries from
+// it in place: opening reads the header, the nodes and the record offsets,
+// but no coordinates or Data, and a point's Data is decoded only when a
+// query returns the point. Of opts, only WithDataCodec and WithMetric apply.
+// Close unmaps the file.
+//
+// Opening checks the header checksum, the file size and every index the
+// queries follow, so a damaged file fails here rather than in a query. The
+// coordinates and records are not checksummed: damage to them can make
+// queries return wrong points, or points without their Data, but not panic.
+// On platforms without mmap, the file is read into memory instead.
+func OpenSnapshot(path string, opts ...Option) (*FrozenTree, error) {
+	cfg := &QuadTree{Root: &Node{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < frozenHeaderSize {
+		return nil, fmt.Errorf("%s: %w", path, corrupt("truncated in header"))
+	}
+	data, err := mapFile(file, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	f, err := openFrozen(data, cfg)
+	if err != nil {
+		unmapFile(data)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// openFrozen checks the header of a mapped file and lays the tree's arrays
+// over it
+func openFrozen(data []byte, cfg *QuadTree) (*FrozenTree, error) {
+	header := data[:frozenHeaderSize]
+	body := header[:frozenHeaderSize-8]
+	switch {
+	case string(header[:len(frozenMagic)]) != frozenMagic:
+		return nil, corrupt("not a frozen snapshot")
+	case binary.LittleEndian.Uint16(header[len(frozenMagic):]) > frozenVersion:
+		return nil, &FormatError{Kind: "frozen snapshot", Format: binary.LittleEndian.Uint16(header[len(frozenMagic):]), Supported: frozenVersion}
+	case binary.LittleEndian.Uint32(header[len(body):]) != crc32.ChecksumIEEE(body):
+		return nil, corrupt("header checksum mismatch")
+	case binary.LittleEndian.Uint16(header[len(frozenMagic):]) != frozenVersion:
+		return nil, corrupt("unsupported version %d", binary.LittleEndian.Uint16(header[len(frozenMagic):]))
+	}
+	flags := header[6]
+	nodes := binary.LittleEndian.Uint64(header[8:])
+	points := binary.LittleEndian.Uint64(header[16:])
+	records := binary.LittleEndian.Uint64(header[24:])
+	// Bound the counts before multiplying, so the size check can't overflow
+	if nodes == 0 || nodes > math.MaxInt32 || points > math.MaxInt32 || records > uint64(len(data)) {
+		return nil, corrupt("sizes out of range")
+	}
+	size := frozenHeaderSize + nodes*frozenNodeSize + points*16 + records + (points+1)*8
+	if size != uint64(len(data)) {
+		return nil, corrupt("%d bytes, the header describes %d", len(data), size)
+	}
+
+	off := uint64(frozenHeaderSize)
+	section := func(n uint64) []byte {
+		b := data[off : off+n]
+		off += n
+		return b
+	}
+	f := &FrozenTree{
+		nodes:  viewSlice[frozenNode](section(nodes*frozenNodeSize), int(nodes)),
+		xs:     viewSlice[float64](section(points*8), int(points)),
+		ys:     viewSlice[float64](section(points*8), int(points)),
+		geo:    flags&frozenGeo != 0,
+		metric: cfg.metric,
+		codec:  cfg.dataCodec(),
+	}
+	f.file = &frozenFile{data: data, records: section(records), codec: f.codec}
+	f.file.offsets = viewSlice[uint64](section((points+1)*8), int(points)+1)
+	if root := f.nodes[0]; root.start != 0 || root.end != int32(points) {
+		return nil, corrupt("root covers points %d to %d of %d", root.start, root.end, points)
+	}
+	if err := f.checkIndexes(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// checkIndexes bounds-checks every index a query follows, so a damaged file
+// fails to open rather than panicking a later query. Each node's children
+// must come after it, within the node array and claimed by no other node,
+// and its points must lie within its parent's; record offsets must ascend
+// within the records section.
+func (f *FrozenTree) checkIndexes() error {
+	claimed := make([]bool, len(f.nodes))
+	for i, n := range f.nodes {
+		if n.start < 0 || n.start > n.end || int(n.end) > len(f.xs) {
+			return corrupt("node %d covers points %d to %d of %d", i, n.start, n.end, len(f.xs))
+		}
+		if n.children == -1 {
+			continue
+		}
+		if n.children <= int32(i) || int(n.children) > len(f.nodes)-4 {
+			return corrupt("node %d has children at %d of %d nodes", i, n.children, len(f.nodes))
+		}
+		for c := n.children; c < n.children+4; c++ {
+			if claimed[c] {
+				return corrupt("node %d is a child of more than one node", c)
+			}
+			claimed[c] = true
+			if child := f.nodes[c]; child.start < n.start || child.end > n.end {
+				return corrupt("node %d covers points %d to %d, outside its parent's %d to %d", c, child.start, child.end, n.start, n.end)
+			}
+		}
+	}
+	offsets := f.file.offsets
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1] {
+			return corrupt("record %d starts at %d, after it ends at %d", i-1, offsets[i-1], offsets[i])
+		}
+	}
+	if end := offsets[len(offsets)-1]; end > uint64(len(f.file.records)) {
+		return corrupt("records end at %d of %d bytes", end, len(f.file.records))
+	}
+	return nil
+}
+
+// viewSlice returns b as n values of T, in place when the platform is
+// little-endian and copied otherwise. T must be made of little-endian
+// fixed-size numbers only.
+func viewSlice[T any](b []byte, n int) []T {
+	if n == 0 {
+		return nil
+	}
+	if nativeLittleEndian {
+		return unsafe.Slice((*T)(unsafe.Pointer(&b[0])), n)
+	}
+	out := make([]T, n)
+	binary.Read(bytes.NewReader(b), binary.LittleEndian, out)
+	return out
+}
+
+// Close releases the snapshot file a tree from OpenSnapshot is served from.
+// The tree must not be used afterwards, nor while Close runs. Close is a
+// no-op for a tree from Freeze.
+func (f *FrozenTree) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	data := f.file.data
+	f.nodes, f.xs, f.ys, f.file = nil, nil, nil, nil
+	return unmapFile(data)
+}
@@ -0,0 +1,279 @@
+package spatial
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openSnapshotOf saves f and opens the file, closing it when the test ends
+func openSnapshotOf(t testing.TB, f *FrozenTree, opts ...Option) *FrozenTree {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tree.spqz")
+	if err := f.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	opened, err := OpenSnapshot(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { opened.Close() })
+	return opened
+}
+
+// TestOpenSnapshotMatchesFreeze tests that a tree served from its snapshot
+// file answers exactly like the tree that wrote it, Data and ties included
+func TestOpenSnapshotMatchesFreeze(t *testing.T) {
+	for name, tc := range map[string]struct {
+		qt   *QuadTree
+		opts []Option
+	}{
+		"planar":    {qt: newRandomTree(3000, 1)},
+		"manhattan": {qt: newRandomTree(3000, 2), opts: []Option{WithMetric(Manhattan{})}},
+		"geo":       {qt: newGeoTree()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			qt := tc.qt
+			if qt.geo {
+				rng := rand.New(rand.NewSource(3))
+				for i := 0; i < 2000; i++ {
+					qt.Insert(Point{X: float64(rng.Intn(360) - 180), Y: float64(rng.Intn(180) - 90), Data: i})
+				}
+			}
+			for _, opt := range tc.opts {
+				opt(qt)
+			}
+			f := qt.Freeze()
+			opened := openSnapshotOf(t, f, tc.opts...)
+			if opened.Count() != f.Count() || opened.Bounds() != f.Bounds() {
+				t.Fatalf("Expected %d points in %v, got %d in %v", f.Count(), f.Bounds(), opened.Count(), opened.Bounds())
+			}
+
+			b := f.Bounds()
+			rng := rand.New(rand.NewSource(4))
+			for i := 0; i < 100; i++ {
+				area := Bounds{X: b.X + rng.Float64()*b.Width, Y: b.Y + rng.Float64()*b.Height, Width: rng.Float64() * b.Width / 3, Height: rng.Float64() * b.Height / 3}
+				if got, want := opened.Search(area), f.Search(area); !reflect.DeepEqual(got, want) {
+					t.Fatalf("Search %v: expected %d points, got %d", area, len(want), len(got))
+				}
+				target := Point{X: b.X + rng.Float64()*b.Width, Y: b.Y + rng.Float64()*b.Height}
+				k := 1 + rng.Intn(30)
+				if got, want := opened.KNearest(target, k), f.KNearest(target, k); !reflect.DeepEqual(got, want) {
+					t.Fatalf("KNearest(%v, %d) differs:\nwant %v\ngot  %v", target, k, want, got)
+				}
+				radius := rng.Float64() * b.Width / 10
+				if qt.geo {
+					radius *= 20000
+				}
+				if got, want := opened.SearchRadius(target, radius), f.SearchRadius(target, radius); !reflect.DeepEqual(got, want) {
+					t.Fatalf("SearchRadius(%v, %v): expected %d points, got %d", target, radius, len(want), len(got))
+				}
+			}
+		})
+	}
+}
+
+// TestOpenSnapshotRoundTrip tests that saving an opened snapshot reproduces
+// the file, with expiries, IDs dropped to plain points, and an empty tree
+func TestOpenSnapshotRoundTrip(t *testing.T) {
+	now := time.Unix(1000, 0)
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithClock(func() time.Time { return now }))
+	qt.InsertWithID("van", Point{X: 1, Y: 1, Data: "van"})
+	qt.InsertWithTTL(Point{X: 2, Y: 2}, time.Minute)
+	opened := openSnapshotOf(t, qt.Freeze())
+	got := opened.Search(opened.Bounds())
+	if len(got) != 2 || got[0].Data != "van" || got[1].expires != now.Add(time.Minute).UnixNano() {
+		t.Errorf("Unexpected points %v", got)
+	}
+
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	qt.Freeze().SaveSnapshot(first)
+	if err := opened.SaveSnapshot(second); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := os.ReadFile(first)
+	b, _ := os.ReadFile(second)
+	if string(a) != string(b) {
+		t.Error("Expected an opened snapshot to save the same file")
+	}
+
+	empty := openSnapshotOf(t, mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}).Freeze())
+	if empty.Count() != 0 || len(empty.Search(empty.Bounds())) != 0 || len(empty.KNearest(Point{X: 1, Y: 1}, 3)) != 0 {
+		t.Error("Empty snapshot should return no points")
+	}
+	if err := empty.Close(); err != nil || empty.Close() != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+// TestOpenSnapshotDamaged tests that damage to the header or the file's size
+// is caught on open
+func TestOpenSnapshotDamaged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree.spqz")
+	if err := newRandomTree(100, 1).Freeze().SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	cases := map[string][]byte{
+		"truncated header": data[:frozenHeaderSize-1],
+		"truncated body":   data[:len(data)-8],
+		"trailing data":    append(append([]byte(nil), data...), 0, 0, 0, 0, 0, 0, 0, 0),
+		"flipped count":    append([]byte(nil), data...),
+		"wrong magic":      append([]byte("SPQF"), data[4:]...),
+	}
+	cases["flipped count"][16] ^= 1
+	for name, b := range cases {
+		os.WriteFile(path, b, 0o644)
+		if _, err := OpenSnapshot(path); !errors.Is(err, ErrCorruptEncoding) {
+			t.Errorf("%s: expected ErrCorruptEncoding, got %v", name, err)
+		}
+	}
+
+	// A consistent header that claims more points than the root holds
+	forged := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint64(forged[16:], 0)
+	size := uint64(len(forged)) - frozenHeaderSize - binary.LittleEndian.Uint64(forged[8:])*frozenNodeSize - 8
+	binary.LittleEndian.PutUint64(forged[24:], size)
+	binary.LittleEndian.PutUint32(forged[32:], crc32.ChecksumIEEE(forged[:32]))
+	os.WriteFile(path, forged, 0o644)
+	if _, err := OpenSnapshot(path); !errors.Is(err, ErrCorruptEncoding) {
+		t.Errorf("Expected the root's range to be checked, got %v", err)
+	}
+
+	// Damage past the header, which its checksum doesn't cover
+	nodes := int(binary.LittleEndian.Uint64(data[8:]))
+	points := int(binary.LittleEndian.Uint64(data[16:]))
+	node := func(i, field int) int { return frozenHeaderSize + i*frozenNodeSize + 32 + field*4 }
+	offsets := len(data) - (points+1)*8
+	for name, damage := range map[string]func(b []byte){
+		"child past the end":  func(b []byte) { binary.LittleEndian.PutUint32(b[node(0, 0):], uint32(nodes-2)) },
+		"child before parent": func(b []byte) { binary.LittleEndian.PutUint32(b[node(1, 0):], 0) },
+		"negative child":      func(b []byte) { binary.LittleEndian.PutUint32(b[node(0, 0):], math.MaxUint32-1) },
+		"shared child": func(b []byte) {
+			copy(b[node(2, 0):node(2, 1)], b[node(1, 0):node(1, 1)])
+		},
+		"range past the end": func(b []byte) { binary.LittleEndian.PutUint32(b[node(nodes-1, 2):], uint32(points+1)) },
+		"range outside parent": func(b []byte) {
+			// Widen the first child of a node whose points don't start at 0
+			for i := 1; i < nodes; i++ {
+				children := int32(binary.LittleEndian.Uint32(b[node(i, 0):]))
+				if children >= 0 && binary.LittleEndian.Uint32(b[node(i, 1):]) > 0 {
+					binary.LittleEndian.PutUint32(b[node(int(children), 1):], 0)
+					return
+				}
+			}
+			t.Fatal("No node to damage")
+		},
+		"reversed range":      func(b []byte) { binary.LittleEndian.PutUint32(b[node(nodes-1, 1):], uint32(points)) },
+		"offset past records": func(b []byte) { binary.LittleEndian.PutUint64(b[len(b)-8:], math.MaxUint64) },
+		"descending offsets":  func(b []byte) { binary.LittleEndian.PutUint64(b[offsets+8:], math.MaxUint32) },
+	} {
+		b := append([]byte(nil), data...)
+		damage(b)
+		os.WriteFile(path, b, 0o644)
+		if _, err := OpenSnapshot(path); !errors.Is(err, ErrCorruptEncoding) {
+			t.Errorf("%s: expected ErrCorruptEncoding, got %v", name, err)
+		}
+	}
+}
+
+// FuzzOpenSnapshot tests that a snapshot file damaged past its header,
+// which its checksum doesn't cover, either fails to open or answers queries
+// without panicking. Each input overwrites 4 bytes of a valid file, so the
+// damage keeps the size the header describes and reaches the nodes,
+// coordinates, records and offsets.
+func FuzzOpenSnapshot(f *testing.F) {
+	dir := f.TempDir()
+	var files [][]byte
+	for i, qt := range []*QuadTree{newRandomTree(0, 1), newRandomTree(60, 1), newGeoTree()} {
+		path := filepath.Join(dir, fmt.Sprint(i))
+		if err := qt.Freeze().SaveSnapshot(path); err != nil {
+			f.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		files = append(files, data)
+	}
+	f.Add(uint8(1), uint32(frozenHeaderSize+32), uint32(0))
+	f.Add(uint8(1), uint32(frozenHeaderSize+frozenNodeSize+40), uint32(1000))
+	f.Add(uint8(2), uint32(0), uint32(math.MaxUint32))
+
+	f.Fuzz(func(t *testing.T, file uint8, at, value uint32) {
+		data := append([]byte(nil), files[int(file)%len(files)]...)
+		pos := frozenHeaderSize + int(at)%(len(data)-frozenHeaderSize-3)
+		binary.LittleEndian.PutUint32(data[pos:], value)
+		path := filepath.Join(t.TempDir(), "tree.spqz")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		tree, err := OpenSnapshot(path)
+		if err != nil {
+			return
+		}
+		defer tree.Close()
+		b := tree.Bounds()
+		center := Point{X: b.X + b.Width/2, Y: b.Y + b.Height/2}
+		tree.Search(b)
+		tree.Search(Bounds{X: b.X, Y: b.Y, Width: b.Width / 3, Height: b.Height / 3})
+		tree.SearchRadius(center, b.Width/4)
+		tree.KNearest(center, 5)
+		tree.ForEach(func(Point) bool { return true })
+	})
+}
+
+// BenchmarkOpenSnapshot measures opening a snapshot and answering a first
+// query, against loading the same tree with LoadFromFile
+func BenchmarkOpenSnapshot(b *testing.B) {
+	qt := benchmarkEncodeTree()
+	dir := b.TempDir()
+	frozen, loaded := filepath.Join(dir, "tree.spqz"), filepath.Join(dir, "tree.snap")
+	if err := qt.Freeze().SaveSnapshot(frozen); err != nil {
+		b.Fatal(err)
+	}
+	if err := qt.SaveToFile(loaded); err != nil {
+		b.Fatal(err)
+	}
+	area := Bounds{X: 5000, Y: 5000, Width: 100, Height: 100}
+
+	b.Run("open", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			f, err := OpenSnapshot(frozen)
+			if err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	})
+	b.Run("open+query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			f, err := OpenSnapshot(frozen)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(f.Search(area)) == 0 {
+				b.Fatal("Expected points")
+			}
+			f.Close()
+		}
+	})
+	b.Run("load+query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			qt, err := LoadFromFile(loaded)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(qt.Search(area)) == 0 {
+				b.Fatal("Expected points")
+			}
+		}
+	})
+}
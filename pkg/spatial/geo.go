@@ -0,0 +1,166 @@
+package spatial
+
+import "math"
+
+// EarthRadiusMeters is the mean Earth radius used for great-circle distances
+const EarthRadiusMeters = 6371008.8
+
+// WithGeoCoordinates treats X as longitude and Y as latitude (degrees), so that
+// KNearest ranks by great-circle distance instead of planar distance.
+func WithGeoCoordinates() Option {
+	return func(qt *QuadTree) {
+		qt.geo = true
+	}
+}
+
+// HaversineDistance returns the great-circle distance in meters between a
+// and b on a sphere of EarthRadiusMeters. The Earth's flattening puts it
+// within 0.5% of the true ellipsoidal distance.
+func HaversineDistance(a, b GeoPoint) float64 {
+	return haversine(a.ToPoint(nil), b.ToPoint(nil))
+}
+
+// haversine is HaversineDistance for points whose X is longitude and Y is
+// latitude in degrees
+func haversine(p1, p2 Point) float64 {
+	lat1 := p1.Y * math.Pi / 180
+	lat2 := p2.Y * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (p2.X - p1.X) * math.Pi / 180
+
+	sinLat := math.Sin(dLat / 2)
+	sinLon := math.Sin(dLon / 2)
+	a := sinLat*sinLat + math.Cos(lat1)*math.Cos(lat2)*sinLon*sinLon
+	if a > 1 {
+		a = 1
+	}
+	return 2 * EarthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// BoundingBoxForRadius returns the smallest latitude/longitude box holding
+// every point within meters of center, widened a hair so rounding never
+// leaves one out. Its longitude half-width is asin(sin(r)/cos(lat)) for an
+// angular radius r, wider than r toward the poles. A circle reaching a pole
+// spans every longitude, and one crossing the antimeridian gives a box that
+// crosses it too; Split turns the box into one or two ordinary Bounds.
+// A negative radius is taken as zero.
+func BoundingBoxForRadius(center GeoPoint, meters float64) GeoBounds {
+	r := math.Max(meters, 0) / EarthRadiusMeters * (1 + 1e-9) // Angular radius
+	dLat := r * 180 / math.Pi
+	box := GeoBounds{South: math.Max(center.Lat-dLat, -90), West: -180, North: math.Min(center.Lat+dLat, 90), East: 180}
+	if box.South == -90 || box.North == 90 {
+		return box
+	}
+	sinLon := math.Sin(r) / math.Cos(center.Lat*math.Pi/180)
+	if sinLon >= 1 {
+		return box
+	}
+	dLon := math.Asin(sinLon)*180/math.Pi + 1e-9
+	box.West = math.Remainder(center.Lon-dLon, 360)
+	box.East = math.Remainder(center.Lon+dLon, 360)
+	return box
+}
+
+// searchRadiusGeo collects points within meters of center. Callers must hold the lock.
+func (qt *QuadTree) searchRadiusGeo(center Point, meters float64) []PointWithDistance {
+	candidates := make([]Point, 0)
+	for _, box := range BoundingBoxForRadius(FromPoint(center, nil), meters).Split() {
+		qt.searchLive(box, InclusiveEdges, &candidates)
+	}
+
+	results := make([]PointWithDistance, 0, len(candidates))
+	for _, p := range candidates {
+		d := haversine(center, p)
+		if d <= meters {
+			results = append(results, PointWithDistance{Point: p, Distance: d})
+		}
+	}
+	return results
+}
+
+// SearchRadiusGeo returns every point within meters of center, measured along
+// the Earth's surface, for a tree whose points have longitude in X and
+// latitude in Y. Under WithProjection it searches the plane instead, as
+// SearchWithin does, converting meters at center's scale.
+func (qt *QuadTree) SearchRadiusGeo(center GeoPoint, meters float64) []Point {
+	if qt.proj != nil {
+		return qt.SearchWithin(qt.proj.Project(center), Meters(meters))
+	}
+	p := center.ToPoint(nil)
+	if view := qt.view(); view != nil {
+		return view.searchRadiusGeoPoints(p, meters)
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.searchRadiusGeoPoints(p, meters)
+}
+
+// searchRadiusGeoPoints is SearchRadiusGeo for callers that hold the lock
+func (qt *QuadTree) searchRadiusGeoPoints(center Point, meters float64) []Point {
+	if qt.Root == nil || meters < 0 {
+		return make([]Point, 0)
+	}
+
+	found := qt.searchRadiusGeo(center, meters)
+	results := make([]Point, len(found))
+	for i, pd := range found {
+		results[i] = pd.Point
+	}
+	return results
+}
+
+// KNearestGeo returns the k points closest to target by great-circle
+// distance, nearest first, for a tree whose points have longitude in X and
+// latitude in Y. Under WithProjection it ranks by distance on the plane, as
+// KNearest does.
+func (qt *QuadTree) KNearestGeo(target GeoPoint, k int) []Point {
+	if qt.proj != nil {
+		return qt.KNearest(qt.proj.Project(target), k)
+	}
+	return qt.kNearestGeoPoint(target.ToPoint(nil), k)
+}
+
+// kNearestGeoPoint is KNearestGeo for a target in the tree's coordinates
+func (qt *QuadTree) kNearestGeoPoint(target Point, k int) []Point {
+	if k <= 0 {
+		return make([]Point, 0)
+	}
+
+	if view := qt.view(); view != nil {
+		return view.kNearestGeo(target, k)
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.kNearestGeo(target, k)
+}
+
+// kNearestGeo is KNearestGeo for callers that hold the lock
+func (qt *QuadTree) kNearestGeo(target Point, k int) []Point {
+	if qt.Root == nil {
+		return make([]Point, 0)
+	}
+
+	// Grow a metric circle until it holds k points; everything inside the
+	// circle is closer than anything outside it, so the top k are exact.
+	maxRadius := math.Pi * EarthRadiusMeters
+	searchRadius := 1000.0
+	var found []PointWithDistance
+	for {
+		found = qt.searchRadiusGeo(target, searchRadius)
+		if len(found) >= k || searchRadius >= maxRadius {
+			break
+		}
+		searchRadius = math.Min(searchRadius*2, maxRadius)
+	}
+
+	sortByDistance(found)
+	if len(found) > k {
+		found = found[:k]
+	}
+
+	results := make([]Point, len(found))
+	for i, pd := range found {
+		results[i] = pd.Point
+	}
+	return results
+}
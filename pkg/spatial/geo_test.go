@@ -0,0 +1,247 @@
+package spatial
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func newGeoTree() *QuadTree {
+	return mustNewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180}, WithCapacity(4), WithGeoCoordinates())
+}
+
+// TestHaversineDistanceKnownPairs checks city pairs against their published
+// great-circle distances
+func TestHaversineDistanceKnownPairs(t *testing.T) {
+	london := GeoPoint{Lat: 51.5074, Lon: -0.1278}
+	paris := GeoPoint{Lat: 48.8566, Lon: 2.3522}
+	for _, tc := range []struct {
+		name   string
+		a, b   GeoPoint
+		meters float64
+	}{
+		{"London-Paris", london, paris, 343500},
+		{"New York-Los Angeles", GeoPoint{Lat: 40.7128, Lon: -74.006}, GeoPoint{Lat: 34.0522, Lon: -118.2437}, 3936000},
+		{"Sydney-Auckland", GeoPoint{Lat: -33.8688, Lon: 151.2093}, GeoPoint{Lat: -36.8485, Lon: 174.7633}, 2156000},
+		{"Bengaluru-Mumbai", GeoPoint{Lat: 12.9716, Lon: 77.5946}, GeoPoint{Lat: 19.076, Lon: 72.8777}, 845000},
+		{"Quito-Singapore", GeoPoint{Lat: -0.1807, Lon: -78.4678}, GeoPoint{Lat: 1.3521, Lon: 103.8198}, 19770000},
+	} {
+		if d := HaversineDistance(tc.a, tc.b); math.Abs(d-tc.meters) > tc.meters*0.005 {
+			t.Errorf("%s: expected ~%.0fkm, got %.0fm", tc.name, tc.meters/1000, d)
+		}
+	}
+	if HaversineDistance(paris, london) != HaversineDistance(london, paris) {
+		t.Error("HaversineDistance should be symmetric")
+	}
+	if HaversineDistance(london, london) != 0 {
+		t.Error("Distance from a point to itself should be 0")
+	}
+}
+
+// TestKNearestGeoHighLatitude tests a case where planar and great-circle ordering disagree
+func TestKNearestGeoHighLatitude(t *testing.T) {
+	qt := newGeoTree()
+	target := Point{X: 0, Y: 80}
+
+	// 10 degrees of longitude at 80N is ~190km; 3 degrees of latitude is ~333km
+	east := Point{X: 10, Y: 80, Data: "east"}
+	south := Point{X: 0, Y: 77, Data: "south"}
+	qt.Insert(east)
+	qt.Insert(south)
+
+	if Distance(target, south) >= Distance(target, east) {
+		t.Fatal("Test setup: planar distance should prefer the southern point")
+	}
+
+	results := qt.KNearestGeo(FromPoint(target, nil), 1)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].Data != "east" {
+		t.Errorf("Expected east point to be nearest by great-circle distance, got %v", results[0].Data)
+	}
+
+	// KNearest follows the tree's coordinate mode
+	results = qt.KNearest(target, 1)
+	if len(results) != 1 || results[0].Data != "east" {
+		t.Errorf("KNearest on a geo tree should rank by great-circle distance, got %v", results)
+	}
+}
+
+// TestKNearestGeoOrdering tests that results come back sorted by ground distance
+func TestKNearestGeoOrdering(t *testing.T) {
+	qt := newGeoTree()
+	target := Point{X: 10, Y: 70}
+
+	for i := 1; i <= 20; i++ {
+		qt.Insert(Point{X: 10 + float64(i)*0.5, Y: 70 + float64(i%3)*0.1, Data: i})
+	}
+
+	results := qt.KNearestGeo(FromPoint(target, nil), 5)
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if haversine(target, results[i-1]) > haversine(target, results[i]) {
+			t.Errorf("Results not sorted by distance at index %d", i)
+		}
+	}
+}
+
+// TestKNearestGeoMoreThanAvailable tests requesting more points than exist
+func TestKNearestGeoMoreThanAvailable(t *testing.T) {
+	qt := newGeoTree()
+	qt.Insert(Point{X: 0, Y: 0, Data: "a"})
+	qt.Insert(Point{X: 120, Y: -45, Data: "b"})
+
+	results := qt.KNearestGeo(GeoPoint{Lat: 40, Lon: -100}, 10)
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+}
+
+// TestSearchRadiusGeoHighLatitudeBox tests that the pruning box widens with latitude
+func TestSearchRadiusGeoHighLatitudeBox(t *testing.T) {
+	qt := newGeoTree()
+	center := Point{X: 10, Y: 60}
+
+	// ~100km due east at 60N is ~1.8 degrees of longitude, well outside a
+	// naive box built from the 0.9 degree latitude span.
+	east := Point{X: 11.79, Y: 60, Data: "east"}
+	far := Point{X: 12.5, Y: 60, Data: "far"}
+	qt.Insert(east)
+	qt.Insert(far)
+
+	if d := haversine(center, east); d > 100000 {
+		t.Fatalf("Test setup: east point should be within 100km, got %.0fm", d)
+	}
+	dLat := 100000 / EarthRadiusMeters * 180 / math.Pi
+	if naive := (Bounds{X: center.X - dLat, Y: center.Y - dLat, Width: 2 * dLat, Height: 2 * dLat}); naive.Contains(east) {
+		t.Fatal("Test setup: a box without the cos(lat) factor should miss the east point")
+	}
+
+	results := qt.SearchRadiusGeo(FromPoint(center, nil), 100000)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].Data != "east" {
+		t.Errorf("Expected east point, got %v", results[0].Data)
+	}
+}
+
+// TestGeoQueriesMatchBruteForce checks SearchRadiusGeo and KNearestGeo
+// against an exhaustive great-circle scan around 60N, where a degree of
+// longitude is half a degree of latitude on the ground
+func TestGeoQueriesMatchBruteForce(t *testing.T) {
+	qt := newGeoTree()
+	rng := rand.New(rand.NewSource(60))
+	var points []Point
+	for i := 0; i < 2000; i++ {
+		p := Point{X: 20 + rng.Float64()*10, Y: 57 + rng.Float64()*6, Data: i}
+		points = append(points, p)
+		qt.Insert(p)
+	}
+	center := GeoPoint{Lat: 60, Lon: 25}
+	for _, meters := range []float64{10000, 50000, 150000} {
+		want := 0
+		for _, p := range points {
+			if HaversineDistance(center, FromPoint(p, nil)) <= meters {
+				want++
+			}
+		}
+		if got := qt.SearchRadiusGeo(center, meters); len(got) != want {
+			t.Errorf("Within %.0fm: expected %d points, got %d", meters, want, len(got))
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return HaversineDistance(center, FromPoint(points[i], nil)) < HaversineDistance(center, FromPoint(points[j], nil))
+	})
+	for i, p := range qt.KNearestGeo(center, 20) {
+		if p.Data != points[i].Data {
+			t.Fatalf("Rank %d: expected %v, got %v", i, points[i].Data, p.Data)
+		}
+	}
+}
+
+// TestBoundingBoxForRadiusContainsCircle samples points just inside circles
+// all over the globe, near the poles and across the antimeridian, and checks
+// each lies in the box
+func TestBoundingBoxForRadiusContainsCircle(t *testing.T) {
+	rng := rand.New(rand.NewSource(1380))
+	centers := []GeoPoint{{0, 0}, {60, 10}, {-60, 179.5}, {89.5, 0}, {-89.9, 45}, {-17, -179.99}, {75, -180}}
+	for i := 0; i < 200; i++ {
+		centers = append(centers, GeoPoint{Lat: rng.Float64()*180 - 90, Lon: rng.Float64()*360 - 180})
+	}
+	for _, center := range centers {
+		for _, meters := range []float64{10, 5000, 100000, 1500000} {
+			box := BoundingBoxForRadius(center, meters)
+			if err := box.Validate(); err != nil {
+				t.Fatalf("%v, %.0fm: %v", center, meters, err)
+			}
+			for k := 0; k < 64; k++ {
+				// Destination from center at just under meters, on bearing k
+				bearing := float64(k) / 64 * 2 * math.Pi
+				d := meters * (1 - 1e-7) / EarthRadiusMeters
+				lat1, lon1 := center.Lat*math.Pi/180, center.Lon*math.Pi/180
+				lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(bearing))
+				lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+				g := GeoPoint{Lat: lat2 * 180 / math.Pi, Lon: math.Remainder(lon2*180/math.Pi, 360)}
+				if HaversineDistance(center, g) > meters {
+					continue
+				}
+				if !box.Contains(g) {
+					t.Fatalf("%v, %.0fm: %v on bearing %d is outside %+v", center, meters, g, k, box)
+				}
+			}
+		}
+	}
+}
+
+// TestBoundingBoxForRadius tests the shape of the box: its latitude factor,
+// the poles and the antimeridian
+func TestBoundingBoxForRadius(t *testing.T) {
+	// At 60N a degree of longitude is half as long as one of latitude
+	box := BoundingBoxForRadius(GeoPoint{Lat: 60, Lon: 10}, 100000)
+	dLat := 100000 / EarthRadiusMeters * 180 / math.Pi
+	if math.Abs((box.North-box.South)/2-dLat) > 1e-6 || math.Abs((box.East-box.West)/2-2*dLat) > 0.01 {
+		t.Errorf("Expected about %.3f by %.3f degrees, got %+v", dLat, 2*dLat, box)
+	}
+	if polar := BoundingBoxForRadius(GeoPoint{Lat: 89.9, Lon: 10}, 50000); polar.North != 90 || polar.West != -180 || polar.East != 180 {
+		t.Errorf("Expected a circle over the pole to span every longitude, got %+v", polar)
+	}
+	seam := BoundingBoxForRadius(GeoPoint{Lat: -17, Lon: 179.9}, 50000)
+	if !seam.CrossesAntimeridian() || len(seam.Split()) != 2 {
+		t.Errorf("Expected a box crossing the antimeridian, got %+v", seam)
+	}
+	if point := BoundingBoxForRadius(GeoPoint{Lat: 10, Lon: 10}, -5); !point.Contains(GeoPoint{Lat: 10, Lon: 10}) || point.North-point.South > 1e-6 {
+		t.Errorf("Expected a negative radius to give the center alone, got %+v", point)
+	}
+}
+
+// TestSearchRadiusGeoNearPole tests a circle that covers the pole
+func TestSearchRadiusGeoNearPole(t *testing.T) {
+	qt := newGeoTree()
+	qt.Insert(Point{X: 0, Y: 89.5, Data: "a"})
+	qt.Insert(Point{X: 180, Y: 89.5, Data: "b"})
+	qt.Insert(Point{X: 0, Y: 80, Data: "c"})
+
+	// The two points on opposite meridians are ~111km apart across the pole
+	results := qt.SearchRadiusGeo(GeoPoint{Lat: 89.9, Lon: 90}, 80000)
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results near the pole, got %d", len(results))
+	}
+}
+
+// TestPlanarTreeUnaffected checks that trees without geo mode keep planar KNearest
+func TestPlanarTreeUnaffected(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180}, WithCapacity(4))
+	qt.Insert(Point{X: 10, Y: 80, Data: "east"})
+	qt.Insert(Point{X: 0, Y: 77, Data: "south"})
+
+	results := qt.KNearest(Point{X: 0, Y: 80}, 1)
+	if len(results) != 1 || results[0].Data != "south" {
+		t.Errorf("Planar KNearest should prefer the southern point, got %v", results)
+	}
+}
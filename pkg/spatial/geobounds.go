@@ -0,0 +1,120 @@
+package spatial
+
+import "math"
+
+// GeoBounds is a box of latitudes and longitudes in degrees. West greater
+// than East means the box crosses the antimeridian: it runs east from West
+// to 180 and on from -180 to East. West -180 and East 180 span every
+// longitude.
+type GeoBounds struct {
+	South, West, North, East float64
+}
+
+// NewGeoBounds builds a GeoBounds and validates it
+func NewGeoBounds(south, west, north, east float64) (GeoBounds, error) {
+	b := GeoBounds{South: south, West: west, North: north, East: east}
+	if err := b.Validate(); err != nil {
+		return GeoBounds{}, err
+	}
+	return b, nil
+}
+
+// Validate returns ErrInvalidBounds unless South and North are latitudes
+// with South no greater than North, and West and East are longitudes
+func (b GeoBounds) Validate() error {
+	if (GeoPoint{Lat: b.South, Lon: b.West}).Validate() != nil || (GeoPoint{Lat: b.North, Lon: b.East}).Validate() != nil {
+		return ErrInvalidBounds
+	}
+	if b.South > b.North {
+		return ErrInvalidBounds
+	}
+	return nil
+}
+
+// CrossesAntimeridian reports whether b runs across longitude ±180
+func (b GeoBounds) CrossesAntimeridian() bool {
+	return b.West > b.East
+}
+
+// Contains reports whether g lies in b, edges included
+func (b GeoBounds) Contains(g GeoPoint) bool {
+	if g.Lat < b.South || g.Lat > b.North {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return g.Lon >= b.West || g.Lon <= b.East
+	}
+	return g.Lon >= b.West && g.Lon <= b.East
+}
+
+// Intersects reports whether b and other share any point, edges included
+func (b GeoBounds) Intersects(other GeoBounds) bool {
+	for _, x := range b.Split() {
+		for _, y := range other.Split() {
+			if x.Intersects(y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Split returns b as ordinary Bounds with X longitude and Y latitude: b
+// itself, or the parts either side of the antimeridian if it crosses it
+func (b GeoBounds) Split() []Bounds {
+	height := b.North - b.South
+	if !b.CrossesAntimeridian() {
+		return []Bounds{{X: b.West, Y: b.South, Width: b.East - b.West, Height: height}}
+	}
+	return []Bounds{
+		{X: b.West, Y: b.South, Width: 180 - b.West, Height: height},
+		{X: -180, Y: b.South, Width: b.East + 180, Height: height},
+	}
+}
+
+// Center returns the middle of b, halfway along its longitudes the way it
+// runs
+func (b GeoBounds) Center() GeoPoint {
+	east := b.East
+	if b.CrossesAntimeridian() {
+		east += 360
+	}
+	return GeoPoint{Lat: (b.South + b.North) / 2, Lon: math.Remainder((b.West+east)/2, 360)}
+}
+
+// project returns the rectangle of the tree's plane covering b, whose X is
+// longitude and Y latitude. Projections here map meridians and parallels to
+// vertical and horizontal lines, so the corners fix it.
+func (qt *QuadTree) project(b Bounds) Bounds {
+	if qt.proj == nil {
+		return b
+	}
+	sw := qt.proj.Project(GeoPoint{Lat: b.Y, Lon: b.X})
+	ne := qt.proj.Project(GeoPoint{Lat: b.Y + b.Height, Lon: b.X + b.Width})
+	x, y := math.Min(sw.X, ne.X), math.Min(sw.Y, ne.Y)
+	return Bounds{X: x, Y: y, Width: math.Max(sw.X, ne.X) - x, Height: math.Max(sw.Y, ne.Y) - y}
+}
+
+// SearchGeo returns the points in area, projected through the tree's
+// WithProjection. An area crossing the antimeridian is searched either side
+// of it; the parts don't overlap, so no point is returned twice. Both parts
+// are read under one lock, so they see the same version of the tree.
+func (qt *QuadTree) SearchGeo(area GeoBounds) []Point {
+	parts := area.Split()
+	for i := range parts {
+		parts[i] = qt.project(parts[i])
+	}
+	results := make([]Point, 0)
+	if view := qt.view(); view != nil {
+		for _, part := range parts {
+			view.searchLive(part, InclusiveEdges, &results)
+		}
+		return results
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	for _, part := range parts {
+		qt.searchLive(part, InclusiveEdges, &results)
+	}
+	return results
+}
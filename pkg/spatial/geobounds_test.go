@@ -0,0 +1,109 @@
+package spatial
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// TestGeoBoundsAntimeridian tests Contains, Intersects and Split for a box
+// around Fiji that crosses the antimeridian
+func TestGeoBoundsAntimeridian(t *testing.T) {
+	fiji, err := NewGeoBounds(-21, 176, -15, -178)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fiji.CrossesAntimeridian() {
+		t.Fatal("Expected the box to cross the antimeridian")
+	}
+	for _, tc := range []struct {
+		g    GeoPoint
+		want bool
+	}{
+		{GeoPoint{Lat: -18, Lon: 178.4}, true},
+		{GeoPoint{Lat: -18, Lon: 180}, true},
+		{GeoPoint{Lat: -18, Lon: -180}, true},
+		{GeoPoint{Lat: -18, Lon: -179.5}, true},
+		{GeoPoint{Lat: -18, Lon: 0}, false},
+		{GeoPoint{Lat: -18, Lon: 175}, false},
+		{GeoPoint{Lat: -18, Lon: -177}, false},
+		{GeoPoint{Lat: -22, Lon: 179}, false},
+	} {
+		if got := fiji.Contains(tc.g); got != tc.want {
+			t.Errorf("Contains(%v): expected %v, got %v", tc.g, tc.want, got)
+		}
+	}
+	if parts := fiji.Split(); len(parts) != 2 || parts[0] != (Bounds{X: 176, Y: -21, Width: 4, Height: 6}) || parts[1] != (Bounds{X: -180, Y: -21, Width: 2, Height: 6}) {
+		t.Errorf("Expected the box split at the antimeridian, got %v", parts)
+	}
+	if c := fiji.Center(); c.Lat != -18 || c.Lon != 179 {
+		t.Errorf("Expected the center at -18, 179, got %v", c)
+	}
+
+	for _, tc := range []struct {
+		other GeoBounds
+		want  bool
+	}{
+		{GeoBounds{South: -20, West: -179, North: -10, East: -170}, true},
+		{GeoBounds{South: -20, West: 170, North: -10, East: 177}, true},
+		{GeoBounds{South: -20, West: 179, North: -10, East: -179}, true},
+		{GeoBounds{South: -20, West: -170, North: -10, East: 170}, false},
+		{GeoBounds{South: -10, West: 177, North: 0, East: 179}, false},
+	} {
+		if got := fiji.Intersects(tc.other); got != tc.want || tc.other.Intersects(fiji) != tc.want {
+			t.Errorf("Intersects(%v): expected %v, got %v", tc.other, tc.want, got)
+		}
+	}
+
+	for _, b := range []GeoBounds{{South: 10, North: 0}, {South: -91, North: 0}, {West: 181}} {
+		if err := b.Validate(); !errors.Is(err, ErrInvalidBounds) {
+			t.Errorf("%v: expected ErrInvalidBounds, got %v", b, err)
+		}
+	}
+}
+
+// TestGeoQueriesAcrossAntimeridian tests searches and rankings with points
+// and queries either side of the seam
+func TestGeoQueriesAcrossAntimeridian(t *testing.T) {
+	qt := newGeoTree()
+	for _, p := range []Point{
+		{X: 179.9, Y: -17, Data: "east"},
+		{X: -179.9, Y: -17, Data: "west"},
+		{X: 178.5, Y: -17, Data: "suva side"},
+		{X: -178.5, Y: -17, Data: "tonga side"},
+		{X: 180, Y: -17.5, Data: "seam"},
+		{X: 0, Y: -17, Data: "greenwich"},
+	} {
+		qt.Insert(p)
+	}
+	names := func(points []Point) []string {
+		var out []string
+		for _, p := range points {
+			out = append(out, p.Data.(string))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	got := names(qt.SearchGeo(GeoBounds{South: -18, West: 179, North: -16, East: -179}))
+	if want := []string{"east", "seam", "west"}; !slices.Equal(got, want) {
+		t.Errorf("SearchGeo: expected %v, got %v", want, got)
+	}
+
+	// The two points closest to the seam are about 21km apart
+	if d := HaversineDistance(GeoPoint{Lat: -17, Lon: 179.9}, GeoPoint{Lat: -17, Lon: -179.9}); d < 20000 || d > 22000 {
+		t.Errorf("Expected ~21km across the seam, got %.0fm", d)
+	}
+	got = names(qt.SearchRadiusGeo(GeoPoint{Lat: -17, Lon: -179.9}, 100000))
+	if want := []string{"east", "seam", "west"}; !slices.Equal(got, want) {
+		t.Errorf("SearchRadiusGeo: expected %v, got %v", want, got)
+	}
+	nearest := qt.KNearestGeo(GeoPoint{Lat: -17, Lon: -179.95}, 4)
+	if len(nearest) != 4 || nearest[0].Data != "west" || nearest[1].Data != "east" {
+		t.Errorf("Expected west then east nearest, got %v", nearest)
+	}
+	if frozen := qt.Freeze().SearchRadius(Point{X: 179.95, Y: -17}, 30000); !slices.Equal(names(frozen), []string{"east", "west"}) {
+		t.Errorf("Frozen SearchRadius: expected east and west, got %v", names(frozen))
+	}
+}
@@ -0,0 +1,209 @@
+// Package geofence tests points against polygonal zones, concave or holed,
+// and finds the zones containing a point among many
+package geofence
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// ErrInvalidFence is wrapped by errors for rings with fewer than three
+// distinct vertices, no area, or coordinates that are NaN or infinite
+var ErrInvalidFence = errors.New("geofence: invalid fence")
+
+// Fence is a polygon: an outer ring less any holes. A point on the boundary,
+// a hole's included, is inside it. Rings may be given in either direction,
+// closed or not, and may touch themselves at a vertex.
+type Fence struct {
+	ID     string
+	rings  [][]spatial.Point // Outer ring first, each without its closing vertex
+	bounds spatial.Bounds
+}
+
+// New builds the fence id with the given outer ring and holes. Holes are
+// expected inside the outer ring and apart from each other; that is not
+// checked, and a hole outside it adds area rather than removing it.
+func New(id string, outer []spatial.Point, holes ...[]spatial.Point) (Fence, error) {
+	f := Fence{ID: id, rings: make([][]spatial.Point, 0, 1+len(holes))}
+	for i, ring := range append([][]spatial.Point{outer}, holes...) {
+		r, err := normalize(ring)
+		if err != nil {
+			if i == 0 {
+				return Fence{}, fmt.Errorf("%w: %s: outer ring: %v", ErrInvalidFence, id, err)
+			}
+			return Fence{}, fmt.Errorf("%w: %s: hole %d: %v", ErrInvalidFence, id, i-1, err)
+		}
+		f.rings = append(f.rings, r)
+	}
+	f.bounds = ringBounds(f.rings[0])
+	return f, nil
+}
+
+// normalize copies ring without repeated vertices or its closing vertex
+func normalize(ring []spatial.Point) ([]spatial.Point, error) {
+	r := make([]spatial.Point, 0, len(ring))
+	for _, p := range ring {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) {
+			return nil, fmt.Errorf("vertex %v is not finite", p)
+		}
+		if len(r) > 0 && sameXY(r[len(r)-1], p) {
+			continue
+		}
+		r = append(r, spatial.Point{X: p.X, Y: p.Y})
+	}
+	if len(r) > 1 && sameXY(r[0], r[len(r)-1]) {
+		r = r[:len(r)-1]
+	}
+	if len(r) < 3 {
+		return nil, fmt.Errorf("%d distinct vertices", len(r))
+	}
+	var area float64
+	for i, a := range r {
+		b := r[(i+1)%len(r)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	if area == 0 {
+		return nil, errors.New("no area")
+	}
+	return r, nil
+}
+
+func sameXY(a, b spatial.Point) bool {
+	return a.X == b.X && a.Y == b.Y
+}
+
+func ringBounds(ring []spatial.Point) spatial.Bounds {
+	minX, minY, maxX, maxY := ring[0].X, ring[0].Y, ring[0].X, ring[0].Y
+	for _, p := range ring[1:] {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	return spatial.Bounds{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+// Bounds returns the smallest box holding the fence
+func (f Fence) Bounds() spatial.Bounds {
+	return f.bounds
+}
+
+// Outer returns a copy of the outer ring, without its closing vertex
+func (f Fence) Outer() []spatial.Point {
+	if len(f.rings) == 0 {
+		return nil
+	}
+	return slices.Clone(f.rings[0])
+}
+
+// Holes returns a copy of the holes, each without its closing vertex
+func (f Fence) Holes() [][]spatial.Point {
+	var holes [][]spatial.Point
+	for i := 1; i < len(f.rings); i++ {
+		holes = append(holes, slices.Clone(f.rings[i]))
+	}
+	return holes
+}
+
+// Contains reports whether p is inside f or on its boundary, as
+// spatial.PolygonContains decides, after rejecting points outside its
+// bounding box.
+func (f Fence) Contains(p spatial.Point) bool {
+	return len(f.rings) > 0 && f.bounds.Contains(p) && spatial.PolygonContains(f.rings, p)
+}
+
+// Distance returns how far p is from f: zero inside it or on its boundary,
+// otherwise the distance to its nearest edge. The zero Fence is infinitely
+// far from everything.
+func (f Fence) Distance(p spatial.Point) float64 {
+	if f.Contains(p) {
+		return 0
+	}
+	d := math.Inf(1)
+	for _, ring := range f.rings {
+		for i, a := range ring {
+			d = math.Min(d, segmentDistance(a, ring[(i+1)%len(ring)], p))
+		}
+	}
+	return d
+}
+
+// segmentDistance returns the distance from p to the segment a, b
+func segmentDistance(a, b, p spatial.Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
+
+// FencesContaining returns the fences containing p, in the order given. It
+// rejects fences by their bounding boxes, which for a single query is as
+// quick as indexing them would be; to test many points against the same
+// fences, build an Index once.
+func FencesContaining(p spatial.Point, fences []Fence) []Fence {
+	var in []Fence
+	for _, f := range fences {
+		if f.Contains(p) {
+			in = append(in, f)
+		}
+	}
+	return in
+}
+
+// Index finds the fences containing a point without testing each one. It
+// indexes each fence's bounding box in a spatial.RTree and tests a point
+// only against the fences whose boxes hold it. An Index is read-only and
+// safe for concurrent use.
+type Index struct {
+	fences []Fence
+	tree   *spatial.RTree
+}
+
+// NewIndex indexes fences, which it keeps a copy of. A zero Fence, which
+// contains nothing, is kept but not indexed.
+func NewIndex(fences []Fence) *Index {
+	tree, err := spatial.NewRTree(spatial.DefaultRTreeCapacity)
+	if err != nil {
+		panic(fmt.Sprintf("geofence: indexing fences: %v", err))
+	}
+	ix := &Index{fences: slices.Clone(fences), tree: tree}
+	for i, f := range fences {
+		if len(f.rings) == 0 {
+			continue
+		}
+		// A fence's bounds are finite and have area, so the tree takes them
+		if _, err := tree.Insert(f.bounds, i); err != nil {
+			panic(fmt.Sprintf("geofence: indexing fence %s: %v", f.ID, err))
+		}
+	}
+	return ix
+}
+
+// Len returns the number of fences indexed
+func (ix *Index) Len() int {
+	return len(ix.fences)
+}
+
+// Containing returns the fences containing p, in the order NewIndex was
+// given them, as FencesContaining would
+func (ix *Index) Containing(p spatial.Point) []Fence {
+	var in []Fence
+	for _, i := range ix.containing(p) {
+		in = append(in, ix.fences[i])
+	}
+	return in
+}
+
+// containing returns the positions of the fences containing p, ascending
+func (ix *Index) containing(p spatial.Point) []int {
+	var hits []int
+	// Fences were inserted in order, so their boxes come back in order
+	for _, e := range ix.tree.SearchContaining(p) {
+		if i := e.Data().(int); ix.fences[i].Contains(p) {
+			hits = append(hits, i)
+		}
+	}
+	return hits
+}
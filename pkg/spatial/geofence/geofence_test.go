@@ -0,0 +1,203 @@
+package geofence
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+func pts(xy ...float64) []spatial.Point {
+	ring := make([]spatial.Point, 0, len(xy)/2)
+	for i := 0; i < len(xy); i += 2 {
+		ring = append(ring, spatial.Point{X: xy[i], Y: xy[i+1]})
+	}
+	return ring
+}
+
+func mustNew(t testing.TB, id string, outer []spatial.Point, holes ...[]spatial.Point) Fence {
+	t.Helper()
+	f, err := New(id, outer, holes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// TestContains tests a concave fence with a hole, including points on its
+// vertices and edges and on rays through its vertices
+func TestContains(t *testing.T) {
+	// A U open to the north, with a square hole in its base
+	u := mustNew(t, "u",
+		pts(0, 0, 10, 0, 10, 10, 7, 10, 7, 4, 3, 4, 3, 10, 0, 10, 0, 0),
+		pts(4, 1, 6, 1, 6, 3, 4, 3))
+	diamond := mustNew(t, "diamond", pts(5, 0, 10, 5, 5, 10, 0, 5))
+	for _, tc := range []struct {
+		f    Fence
+		p    spatial.Point
+		want bool
+	}{
+		{u, spatial.Point{X: 1, Y: 1}, true},
+		{u, spatial.Point{X: 1.5, Y: 8}, true},
+		{u, spatial.Point{X: 8.5, Y: 8}, true},
+		{u, spatial.Point{X: 5, Y: 8}, false}, // In the notch
+		{u, spatial.Point{X: 5, Y: 2}, false}, // In the hole
+		{u, spatial.Point{X: 11, Y: 2}, false},
+		{u, spatial.Point{X: 0, Y: 0}, true},   // Vertex
+		{u, spatial.Point{X: 7, Y: 4}, true},   // Reflex vertex
+		{u, spatial.Point{X: 5, Y: 0}, true},   // Edge
+		{u, spatial.Point{X: 5, Y: 4}, true},   // Edge of the notch
+		{u, spatial.Point{X: 3, Y: 7}, true},   // Edge of the notch
+		{u, spatial.Point{X: 4, Y: 2}, true},   // Edge of the hole
+		{u, spatial.Point{X: 6, Y: 3}, true},   // Vertex of the hole
+		{u, spatial.Point{X: 1, Y: 4}, true},   // Ray through two vertices
+		{u, spatial.Point{X: 5, Y: 10}, false}, // Ray along the top edges
+		{u, spatial.Point{X: 1, Y: 10}, true},
+		{diamond, spatial.Point{X: 2, Y: 5}, true}, // Ray through a vertex
+		{diamond, spatial.Point{X: -1, Y: 5}, false},
+		{diamond, spatial.Point{X: 11, Y: 5}, false},
+		{diamond, spatial.Point{X: 7.5, Y: 2.5}, true}, // Diagonal edge
+		{diamond, spatial.Point{X: 1, Y: 1}, false},    // In the bounding box only
+	} {
+		if got := tc.f.Contains(tc.p); got != tc.want {
+			t.Errorf("%s.Contains(%v, %v): expected %v, got %v", tc.f.ID, tc.p.X, tc.p.Y, tc.want, got)
+		}
+	}
+	for _, tc := range []struct {
+		p    spatial.Point
+		want float64
+	}{
+		{spatial.Point{X: 1, Y: 1}, 0},
+		{spatial.Point{X: 5, Y: 2}, 1}, // In the hole
+		{spatial.Point{X: 5, Y: 7}, 2}, // In the notch
+		{spatial.Point{X: 13, Y: 14}, 5},
+	} {
+		if got := u.Distance(tc.p); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("Distance(%v, %v): expected %v, got %v", tc.p.X, tc.p.Y, tc.want, got)
+		}
+	}
+	if (Fence{}).Contains(spatial.Point{}) {
+		t.Error("Expected the zero Fence to contain nothing")
+	}
+}
+
+// TestSelfTouching tests rings that meet themselves at a vertex: two
+// squares joined at a corner, and a ring pinched round an enclosed gap
+func TestSelfTouching(t *testing.T) {
+	eight := mustNew(t, "eight", pts(0, 0, 2, 0, 2, 2, 4, 2, 4, 4, 2, 4, 2, 2, 0, 2))
+	for p, want := range map[spatial.Point]bool{
+		{X: 1, Y: 1}: true, {X: 3, Y: 3}: true, {X: 3, Y: 1}: false, {X: 1, Y: 3}: false,
+		{X: 2, Y: 2}: true, {X: 2, Y: 1}: true, {X: 0, Y: 2}: true,
+	} {
+		if got := eight.Contains(p); got != want {
+			t.Errorf("eight.Contains(%v, %v): expected %v, got %v", p.X, p.Y, want, got)
+		}
+	}
+
+	// The ring runs round the square, then in from (0, 5) round a diamond
+	// and back, enclosing the diamond as a gap touching the outside at (0, 5)
+	pinched := mustNew(t, "pinched", pts(0, 0, 10, 0, 10, 10, 0, 10, 0, 5, 5, 8, 8, 5, 5, 2, 0, 5))
+	for p, want := range map[spatial.Point]bool{
+		{X: 1, Y: 1}: true, {X: 9, Y: 9}: true, {X: 5, Y: 5}: false, {X: 0, Y: 5}: true,
+		{X: 5, Y: 8}: true, {X: 1, Y: 5}: false, {X: 1, Y: 9}: true,
+	} {
+		if got := pinched.Contains(p); got != want {
+			t.Errorf("pinched.Contains(%v, %v): expected %v, got %v", p.X, p.Y, want, got)
+		}
+	}
+}
+
+// TestNew tests that rings are normalized and bad ones rejected
+func TestNew(t *testing.T) {
+	f := mustNew(t, "square", pts(0, 0, 0, 0, 4, 0, 4, 2, 4, 2, 0, 2, 0, 0))
+	if want := pts(0, 0, 4, 0, 4, 2, 0, 2); !slices.Equal(f.Outer(), want) {
+		t.Errorf("Expected repeated and closing vertices dropped, got %v", f.Outer())
+	}
+	if f.Bounds() != (spatial.Bounds{X: 0, Y: 0, Width: 4, Height: 2}) {
+		t.Errorf("Unexpected bounds %v", f.Bounds())
+	}
+	square := pts(0, 0, 4, 0, 4, 4, 0, 4)
+	for name, rings := range map[string][][]spatial.Point{
+		"empty":     {nil},
+		"two":       {pts(0, 0, 1, 1, 0, 0)},
+		"collinear": {pts(0, 0, 1, 1, 2, 2)},
+		"nan":       {pts(0, 0, math.NaN(), 1, 2, 0)},
+		"infinite":  {pts(0, 0, math.Inf(1), 1, 2, 0)},
+		"hole":      {square, pts(1, 1, 2, 2)},
+		"flat hole": {square, pts(1, 1, 2, 1, 3, 1)},
+	} {
+		if _, err := New(name, rings[0], rings[1:]...); !errors.Is(err, ErrInvalidFence) {
+			t.Errorf("%s: expected ErrInvalidFence, got %v", name, err)
+		}
+	}
+}
+
+// randomFence returns a star-shaped, usually concave fence around c
+func randomFence(rng *rand.Rand, id string, c spatial.Point, radius float64) Fence {
+	n := 3 + rng.Intn(10)
+	ring := make([]spatial.Point, n)
+	for i := range ring {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		r := radius * (0.2 + 0.8*rng.Float64())
+		ring[i] = spatial.Point{X: c.X + r*math.Cos(a), Y: c.Y + r*math.Sin(a)}
+	}
+	f, err := New(id, ring)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func ids(fences []Fence) []string {
+	var out []string
+	for _, f := range fences {
+		out = append(out, f.ID)
+	}
+	return out
+}
+
+// TestIndexMatchesScan tests that an Index finds what testing every fence
+// finds, for fences of mixed sizes, one reaching far past the others, and
+// points on vertices and outside every fence
+func TestIndexMatchesScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var fences []Fence
+	for i := 0; i < 300; i++ {
+		c := spatial.Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+		fences = append(fences, randomFence(rng, string(rune('a'+i%26))+string(rune('0'+i/26)), c, 5+rng.Float64()*60))
+	}
+	fences = append(fences, randomFence(rng, "wide", spatial.Point{X: 1000, Y: -200}, 900))
+	fences = append(fences, Fence{})
+	ix := NewIndex(fences)
+	if ix.Len() != len(fences) {
+		t.Fatalf("Expected %d fences, got %d", len(fences), ix.Len())
+	}
+
+	probes := make([]spatial.Point, 0, 5000)
+	for i := 0; i < 4000; i++ {
+		probes = append(probes, spatial.Point{X: rng.Float64()*2400 - 200, Y: rng.Float64()*2400 - 1200})
+	}
+	for _, f := range fences[:200] {
+		probes = append(probes, f.Outer()...)
+	}
+	for _, p := range probes {
+		want, got := ids(FencesContaining(p, fences)), ids(ix.Containing(p))
+		if !slices.Equal(got, want) {
+			t.Fatalf("At %v, %v: expected %v, got %v", p.X, p.Y, want, got)
+		}
+	}
+	for _, v := range fences[7].Outer() {
+		if !slices.Contains(ids(ix.Containing(v)), fences[7].ID) {
+			t.Errorf("Expected vertex %v of %s to be in it", v, fences[7].ID)
+		}
+	}
+	if got := ix.Containing(spatial.Point{X: 1000, Y: -200}); !slices.Equal(ids(got), []string{"wide"}) {
+		t.Errorf("Expected only the wide fence well outside the rest, got %v", ids(got))
+	}
+	if got := NewIndex(nil).Containing(spatial.Point{}); got != nil {
+		t.Errorf("Expected an empty index to find nothing, got %v", got)
+	}
+}
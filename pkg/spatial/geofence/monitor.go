@@ -0,0 +1,196 @@
+package geofence
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// EventKind says whether a FenceEvent is an entry or an exit
+type EventKind uint8
+
+const (
+	Enter EventKind = iota + 1
+	Exit
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Enter:
+		return "enter"
+	case Exit:
+		return "exit"
+	}
+	return "unknown"
+}
+
+// FenceEvent is an ID entering or leaving a fence
+type FenceEvent struct {
+	ID    string // The observed ID
+	Fence string // The fence's ID
+	Kind  EventKind
+	Point spatial.Point // Where the ID was observed
+	At    time.Time     // When it was observed, by MonitorConfig.Now
+}
+
+// MonitorConfig configures a FenceMonitor
+type MonitorConfig struct {
+	// TTL is how long an ID's membership is remembered after it was last
+	// observed. Forgotten IDs are swept as Observe is called, and their next
+	// observation counts as their first. Zero remembers IDs until Forget.
+	TTL time.Duration
+	// Hysteresis is how far outside a fence, in the tree's units, an ID must
+	// be observed before it exits, so jitter across the boundary doesn't
+	// exit and re-enter it. Entering takes only being inside.
+	Hysteresis float64
+	// Now replaces time.Now for timestamps and the TTL
+	Now func() time.Time
+}
+
+// FenceMonitor turns location updates into entries and exits of a fixed set
+// of fences. Each observation is stored in the tree under its ID and
+// compared with the fences the ID was last in. It is safe for concurrent
+// use; observations are handled one at a time.
+type FenceMonitor struct {
+	tree  *spatial.QuadTree
+	index *Index
+	cfg   MonitorConfig
+
+	mu        sync.Mutex // Guards members and lastSweep, and orders tree writes with them
+	members   map[string]*membership
+	lastSweep time.Time
+}
+
+// membership is the fences an ID is in, as positions in the index, ascending
+type membership struct {
+	fences []int
+	seen   time.Time
+}
+
+// NewFenceMonitor watches fences, storing observations in tree
+func NewFenceMonitor(tree *spatial.QuadTree, fences []Fence, cfg MonitorConfig) *FenceMonitor {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &FenceMonitor{
+		tree:    tree,
+		index:   NewIndex(fences),
+		cfg:     cfg,
+		members: make(map[string]*membership),
+	}
+}
+
+// Tree returns the tree observations are stored in
+func (m *FenceMonitor) Tree() *spatial.QuadTree {
+	return m.tree
+}
+
+// Observe records id at p and returns the fences it left, then the fences it
+// entered, each in the order the monitor was given them. The first
+// observation of an ID, or the first since it was forgotten, only enters.
+// An observation the tree rejects changes nothing and returns no events.
+func (m *FenceMonitor) Observe(id string, p spatial.Point) []FenceEvent {
+	events, _ := m.ObserveE(id, p)
+	return events
+}
+
+// ObserveE is Observe returning the tree's error from Upsert, such as
+// spatial.ErrOutOfBounds, for an observation it rejects
+func (m *FenceMonitor) ObserveE(id string, p spatial.Point) ([]FenceEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.tree.Upsert(id, p); err != nil {
+		return nil, err
+	}
+	now := m.cfg.Now()
+	m.sweep(now)
+
+	in := m.index.containing(p)
+	prev := m.members[id]
+	if prev == nil {
+		prev = &membership{}
+		m.members[id] = prev
+	} else if m.expired(prev, now) {
+		prev.fences = nil
+	}
+	var events []FenceEvent
+	event := func(i int, kind EventKind) {
+		events = append(events, FenceEvent{ID: id, Fence: m.index.fences[i].ID, Kind: kind, Point: p, At: now})
+	}
+	var held []int // Fences left by less than the hysteresis, so not left
+	for _, i := range prev.fences {
+		if _, found := slices.BinarySearch(in, i); found {
+			continue
+		}
+		if m.cfg.Hysteresis > 0 && m.index.fences[i].Distance(p) <= m.cfg.Hysteresis {
+			held = append(held, i)
+			continue
+		}
+		event(i, Exit)
+	}
+	if len(held) > 0 {
+		in = append(in, held...)
+		slices.Sort(in)
+	}
+	for _, i := range in {
+		if _, found := slices.BinarySearch(prev.fences, i); !found {
+			event(i, Enter)
+		}
+	}
+	prev.fences, prev.seen = in, now
+	return events, nil
+}
+
+// Fences returns the IDs of the fences id was last observed in, in the
+// order the monitor was given them, or nil if it isn't remembered
+func (m *FenceMonitor) Fences(id string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.members[id]
+	if state == nil || m.expired(state, m.cfg.Now()) {
+		return nil
+	}
+	ids := make([]string, len(state.fences))
+	for j, i := range state.fences {
+		ids[j] = m.index.fences[i].ID
+	}
+	return ids
+}
+
+// Forget drops what the monitor remembers of id, without exit events. The
+// tree is left alone. It reports whether id was remembered.
+func (m *FenceMonitor) Forget(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.members[id]
+	delete(m.members, id)
+	return ok
+}
+
+// Len returns the number of IDs remembered, including any past their TTL
+// that haven't been swept yet
+func (m *FenceMonitor) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.members)
+}
+
+func (m *FenceMonitor) expired(state *membership, now time.Time) bool {
+	return m.cfg.TTL > 0 && now.Sub(state.seen) >= m.cfg.TTL
+}
+
+// sweep forgets the IDs past their TTL, at most once per TTL so the cost is
+// spread over the observations in between. Callers must hold mu.
+func (m *FenceMonitor) sweep(now time.Time) {
+	if m.cfg.TTL <= 0 || now.Sub(m.lastSweep) < m.cfg.TTL {
+		return
+	}
+	m.lastSweep = now
+	for id, state := range m.members {
+		if m.expired(state, now) {
+			delete(m.members, id)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package geofence
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// fakeClock is a clock the test moves by hand
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newMonitor(t *testing.T, cfg MonitorConfig) (*FenceMonitor, *fakeClock) {
+	t.Helper()
+	qt, err := spatial.NewQuadTree(spatial.Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}
+	cfg.Now = clock.now
+	fences := []Fence{
+		mustNew(t, "depot", pts(10, 10, 30, 10, 30, 30, 10, 30)),
+		mustNew(t, "city", pts(0, 0, 50, 0, 50, 50, 0, 50)),
+		// Reaches past the tree, to the east
+		mustNew(t, "port", pts(90, 40, 140, 40, 140, 60, 90, 60)),
+	}
+	return NewFenceMonitor(qt, fences, cfg), clock
+}
+
+func describe(events []FenceEvent) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, fmt.Sprintf("%s %s %s", e.ID, e.Kind, e.Fence))
+	}
+	return out
+}
+
+func expectEvents(t *testing.T, got []FenceEvent, want ...string) {
+	t.Helper()
+	if fmt.Sprint(describe(got)) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, describe(got))
+	}
+}
+
+// TestMonitorEnterExit tests the events a route through nested and
+// separate fences produces
+func TestMonitorEnterExit(t *testing.T) {
+	m, clock := newMonitor(t, MonitorConfig{})
+
+	// The first observation only enters, even for nested fences
+	events := m.Observe("d1", spatial.Point{X: 20, Y: 20})
+	expectEvents(t, events, "d1 enter depot", "d1 enter city")
+	if !events[0].At.Equal(clock.t) || events[0].Point.X != 20 {
+		t.Errorf("Expected the observation's time and point, got %+v", events[0])
+	}
+	if p, ok := m.Tree().GetByID("d1"); !ok || p.X != 20 {
+		t.Errorf("Expected the observation stored in the tree, got %v, %v", p, ok)
+	}
+
+	clock.advance(time.Minute)
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 25, Y: 25}))
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 40, Y: 40}), "d1 exit depot")
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 30, Y: 20}), "d1 enter depot") // On the edge
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 95, Y: 50}), "d1 exit depot", "d1 exit city", "d1 enter port")
+	if got := m.Fences("d1"); fmt.Sprint(got) != "[port]" {
+		t.Errorf("Expected d1 in the port, got %v", got)
+	}
+
+	// A rejected observation changes nothing
+	if _, err := m.ObserveE("d1", spatial.Point{X: 120, Y: 50}); !errors.Is(err, spatial.ErrOutOfBounds) {
+		t.Errorf("Expected ErrOutOfBounds, got %v", err)
+	}
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 99, Y: 50}))
+
+	// A first observation outside every fence has no events at all
+	expectEvents(t, m.Observe("d2", spatial.Point{X: 70, Y: 70}))
+	expectEvents(t, m.Observe("d2", spatial.Point{X: 5, Y: 5}), "d2 enter city")
+}
+
+// TestMonitorHysteresis tests that jitter across a boundary within the
+// hysteresis doesn't exit, and that going past it does
+func TestMonitorHysteresis(t *testing.T) {
+	m, _ := newMonitor(t, MonitorConfig{Hysteresis: 2})
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 29, Y: 20}), "d1 enter depot", "d1 enter city")
+	for i := 0; i < 5; i++ {
+		expectEvents(t, m.Observe("d1", spatial.Point{X: 31.5, Y: 20}))
+		expectEvents(t, m.Observe("d1", spatial.Point{X: 29.5, Y: 20}))
+	}
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 32.5, Y: 20}), "d1 exit depot")
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 31, Y: 20}))
+	expectEvents(t, m.Observe("d1", spatial.Point{X: 30, Y: 20}), "d1 enter depot")
+
+	// Without hysteresis the same jitter exits and enters every time
+	plain, _ := newMonitor(t, MonitorConfig{})
+	plain.Observe("d1", spatial.Point{X: 29, Y: 20})
+	expectEvents(t, plain.Observe("d1", spatial.Point{X: 31.5, Y: 20}), "d1 exit depot")
+	expectEvents(t, plain.Observe("d1", spatial.Point{X: 29.5, Y: 20}), "d1 enter depot")
+}
+
+// TestMonitorTTL tests that idle IDs are forgotten and swept, and that the
+// next observation after that counts as the first
+func TestMonitorTTL(t *testing.T) {
+	m, clock := newMonitor(t, MonitorConfig{TTL: time.Hour})
+	for i := 0; i < 100; i++ {
+		m.Observe(fmt.Sprint("idle", i), spatial.Point{X: 20, Y: 20})
+	}
+	m.Observe("busy", spatial.Point{X: 20, Y: 20})
+	clock.advance(59 * time.Minute)
+	expectEvents(t, m.Observe("busy", spatial.Point{X: 40, Y: 40}), "busy exit depot")
+	if got := m.Fences("idle0"); fmt.Sprint(got) != "[depot city]" {
+		t.Errorf("Expected idle0 remembered before its TTL, got %v", got)
+	}
+
+	clock.advance(2 * time.Minute)
+	if got := m.Fences("idle0"); got != nil {
+		t.Errorf("Expected idle0 forgotten after its TTL, got %v", got)
+	}
+	// Observed again once forgotten, idle0 enters the fences it never left
+	expectEvents(t, m.Observe("idle0", spatial.Point{X: 20, Y: 20}), "idle0 enter depot", "idle0 enter city")
+	if m.Len() != 2 {
+		t.Errorf("Expected the other idle IDs swept, leaving 2, got %d", m.Len())
+	}
+
+	if !m.Forget("busy") || m.Forget("busy") {
+		t.Error("Expected Forget to report busy remembered once")
+	}
+	expectEvents(t, m.Observe("busy", spatial.Point{X: 40, Y: 40}), "busy enter city")
+}
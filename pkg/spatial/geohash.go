@@ -0,0 +1,127 @@
+package spatial
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// geohashAlphabet is the standard geohash base-32 alphabet, which leaves out
+// a, i, l and o
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// MaxGeohashPrecision is the longest geohash EncodeGeohash produces, 60 bits
+// or cells a few centimeters across
+const MaxGeohashPrecision = 12
+
+// EncodeGeohash returns the geohash of lat, lon with precision characters,
+// clamped to [1, MaxGeohashPrecision]. Coordinates out of range are clamped
+// to it.
+func EncodeGeohash(lat, lon float64, precision int) string {
+	precision = max(1, min(precision, MaxGeohashPrecision))
+	lat = math.Max(-90, math.Min(90, lat))
+	lon = math.Max(-180, math.Min(180, lon))
+
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	var hash strings.Builder
+	even := true // Bits alternate, longitude first
+	for hash.Len() < precision {
+		var c int
+		for bit := 0; bit < 5; bit++ {
+			r, v := &latRange, lat
+			if even {
+				r, v = &lonRange, lon
+			}
+			c <<= 1
+			if mid := (r[0] + r[1]) / 2; v >= mid {
+				c |= 1
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+		hash.WriteByte(geohashAlphabet[c])
+	}
+	return hash.String()
+}
+
+// DecodeGeohash returns the cell hash names. It is case-insensitive, and
+// returns an error wrapping ErrInvalidGeohash for an empty hash, one longer
+// than MaxGeohashPrecision or one with a character outside the alphabet.
+func DecodeGeohash(hash string) (GeoBounds, error) {
+	if hash == "" || len(hash) > MaxGeohashPrecision {
+		return GeoBounds{}, fmt.Errorf("%w: %q has %d characters", ErrInvalidGeohash, hash, len(hash))
+	}
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for i := 0; i < len(hash); i++ {
+		ch := hash[i]
+		if ch >= 'A' && ch <= 'Z' {
+			ch += 'a' - 'A'
+		}
+		c := strings.IndexByte(geohashAlphabet, ch)
+		if c < 0 {
+			return GeoBounds{}, fmt.Errorf("%w: %q at %d of %q", ErrInvalidGeohash, hash[i], i, hash)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if c>>bit&1 == 1 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return GeoBounds{South: latRange[0], West: lonRange[0], North: latRange[1], East: lonRange[1]}, nil
+}
+
+// GeohashNeighbors returns the cells of the same precision around hash, in
+// the order north, northeast, east, southeast, south, southwest, west,
+// northwest. Cells wrap across the antimeridian; beyond a pole there are
+// none, so a cell on the top or bottom row has five neighbors. An invalid
+// hash has none.
+func GeohashNeighbors(hash string) []string {
+	cell, err := DecodeGeohash(hash)
+	if err != nil {
+		return nil
+	}
+	center := cell.Center()
+	height, width := cell.North-cell.South, cell.East-cell.West
+	neighbors := make([]string, 0, 8)
+	for _, step := range [8][2]float64{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}} {
+		lat := center.Lat + step[0]*height
+		if lat > 90 || lat < -90 {
+			continue
+		}
+		lon := math.Remainder(center.Lon+step[1]*width, 360)
+		neighbors = append(neighbors, EncodeGeohash(lat, lon, len(hash)))
+	}
+	return neighbors
+}
+
+// SearchGeohash returns the points in the cell hash names, projected
+// through the tree's WithProjection as SearchGeo is. A point on the edge
+// between two cells is returned for the one EncodeGeohash puts it in, so
+// the cells of one precision share no points. An invalid hash matches
+// nothing.
+func (qt *QuadTree) SearchGeohash(hash string) []Point {
+	cell, err := DecodeGeohash(hash)
+	if err != nil {
+		return make([]Point, 0)
+	}
+	hash = strings.ToLower(hash)
+	results := qt.SearchGeo(cell)
+	in := results[:0]
+	for _, p := range results {
+		if g := FromPoint(p, qt.proj); EncodeGeohash(g.Lat, g.Lon, len(hash)) == hash {
+			in = append(in, p)
+		}
+	}
+	return in
+}
@@ -0,0 +1,123 @@
+package spatial
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// TestEncodeGeohashVectors checks published test vectors
+func TestEncodeGeohashVectors(t *testing.T) {
+	for _, tc := range []struct {
+		lat, lon  float64
+		precision int
+		want      string
+	}{
+		{42.605, -5.603, 5, "ezs42"},
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{37.8324, 112.5584, 9, "ww8p1r4t8"},
+		{-25.382708, -49.265506, 12, "6gkzwgjzn820"},
+		{51.501568, -0.141257, 8, "gcpuuz94"},
+		{0, 0, 1, "s"},
+		{-90, -180, 4, "0000"},
+		{90, 180, 4, "zzzz"},
+	} {
+		if got := EncodeGeohash(tc.lat, tc.lon, tc.precision); got != tc.want {
+			t.Errorf("EncodeGeohash(%v, %v, %d): expected %s, got %s", tc.lat, tc.lon, tc.precision, tc.want, got)
+		}
+	}
+	if got := EncodeGeohash(57.64911, 10.40744, 20); len(got) != MaxGeohashPrecision {
+		t.Errorf("Expected the precision clamped, got %s", got)
+	}
+}
+
+// TestDecodeGeohash tests cells against a published vector, round trips and
+// invalid input
+func TestDecodeGeohash(t *testing.T) {
+	cell, err := DecodeGeohash("ezs42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GeoBounds{South: 42.5830078125, West: -5.625, North: 42.626953125, East: -5.5810546875}
+	if cell != want {
+		t.Errorf("Expected %+v, got %+v", want, cell)
+	}
+	if upper, _ := DecodeGeohash("EZS42"); upper != cell {
+		t.Errorf("Expected decoding to ignore case, got %+v", upper)
+	}
+	for _, g := range []GeoPoint{{12.9716, 77.5946}, {-33.8688, 151.2093}, {40.7128, -74.006}, {-17.7, 179.99}} {
+		for precision := 1; precision <= MaxGeohashPrecision; precision++ {
+			cell, err := DecodeGeohash(EncodeGeohash(g.Lat, g.Lon, precision))
+			if err != nil || !cell.Contains(g) {
+				t.Fatalf("%v at precision %d: %+v doesn't contain it, %v", g, precision, cell, err)
+			}
+		}
+	}
+
+	for _, hash := range []string{"", "ezs4a", "ezsi2", "ezs 2", "ezs\x102", "ü", "0123456789bcd"} {
+		if _, err := DecodeGeohash(hash); !errors.Is(err, ErrInvalidGeohash) {
+			t.Errorf("DecodeGeohash(%q): expected ErrInvalidGeohash, got %v", hash, err)
+		}
+	}
+}
+
+// TestGeohashNeighbors tests a published vector and cells on the
+// antimeridian and at the pole
+func TestGeohashNeighbors(t *testing.T) {
+	want := []string{"dqcjw", "dqcjx", "dqcjr", "dqcjp", "dqcjn", "dqcjj", "dqcjm", "dqcjt"}
+	if got := GeohashNeighbors("dqcjq"); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// Cells either side of the antimeridian are neighbors
+	east := EncodeGeohash(-17, 179.99, 5)
+	west := EncodeGeohash(-17, -179.99, 5)
+	if !slices.Contains(GeohashNeighbors(east), west) || !slices.Contains(GeohashNeighbors(west), east) {
+		t.Errorf("Expected %s and %s to be neighbors across the antimeridian", east, west)
+	}
+	if n := GeohashNeighbors(EncodeGeohash(89.99, 0, 3)); len(n) != 5 {
+		t.Errorf("Expected 5 neighbors at the pole, got %v", n)
+	}
+	if GeohashNeighbors("a") != nil {
+		t.Error("Expected no neighbors of an invalid hash")
+	}
+}
+
+// TestSearchGeohash tests bucket queries, on plain and projected trees
+func TestSearchGeohash(t *testing.T) {
+	positions := []GeoPoint{
+		{42.605, -5.603}, {42.61, -5.6}, {42.59, -5.62},
+		{42.7, -5.603}, {-33.8688, 151.2093},
+	}
+	cell, _ := DecodeGeohash("ezs42")
+	edge := GeoPoint{Lat: cell.North, Lon: -5.6} // On the border with the cell north
+	positions = append(positions, edge)
+
+	merc := WebMercator{}
+	plain := newGeoTree()
+	projected := mustNewQuadTree(Bounds{X: -2.1e7, Y: -2.1e7, Width: 4.2e7, Height: 4.2e7}, WithProjection(merc))
+	for i, g := range positions {
+		plain.Insert(Point{X: g.Lon, Y: g.Lat, Data: i})
+		p := g.ToPoint(merc)
+		p.Data = i
+		projected.Insert(p)
+	}
+	for name, qt := range map[string]*QuadTree{"plain": plain, "projected": projected} {
+		var got []int
+		for _, p := range qt.SearchGeohash("ezs42") {
+			got = append(got, p.Data.(int))
+		}
+		sort.Ints(got)
+		if !slices.Equal(got, []int{0, 1, 2}) {
+			t.Errorf("%s: expected points 0 to 2 in ezs42, got %v", name, got)
+		}
+		north := GeohashNeighbors("ezs42")[0]
+		if found := qt.SearchGeohash(north); !slices.ContainsFunc(found, func(p Point) bool { return p.Data == 5 }) {
+			t.Errorf("%s: expected the edge point in %s, got %v", name, north, found)
+		}
+		if len(qt.SearchGeohash("ezs4!")) != 0 {
+			t.Errorf("%s: expected an invalid hash to match nothing", name)
+		}
+	}
+}
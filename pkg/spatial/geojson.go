@@ -0,0 +1,339 @@
+package spatial
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// GeoJSONOptions selects what ExportGeoJSONWith writes besides the points
+type GeoJSONOptions struct {
+	// Nodes adds every node intersecting the area as a Polygon feature, with
+	// properties "node" (true), "depth", "leaf" and "points" (the points in its
+	// subtree), for looking at how the tree subdivided
+	Nodes bool
+}
+
+// geoJSONFeature is one RFC 7946 Feature
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id,omitempty"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// ExportGeoJSON writes the points in area, using the tree's edge semantics,
+// as a GeoJSON FeatureCollection of Point features, X as longitude and Y as
+// latitude. A point stored under an id carries it as the feature id. Data
+// that marshals to a JSON object, such as a map or struct, becomes the
+// feature's properties; any other Data is the property "data", and no Data
+// leaves properties null. Data that cannot be marshalled returns an error
+// after part of the collection may have been written.
+func (qt *QuadTree) ExportGeoJSON(w io.Writer, area Bounds) error {
+	return qt.ExportGeoJSONWith(w, area, GeoJSONOptions{})
+}
+
+// ExportGeoJSONWith is ExportGeoJSON with the given options
+func (qt *QuadTree) ExportGeoJSONWith(w io.Writer, area Bounds, opts GeoJSONOptions) error {
+	qt.rlockAll()
+	defer qt.runlockAll()
+
+	points := make([]Point, 0)
+	qt.searchLive(area, qt.edges, &points)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`{"type":"FeatureCollection","features":[`)
+	first := true
+	write := func(f geoJSONFeature) error {
+		b, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		_, err = bw.Write(b)
+		return err
+	}
+
+	for i, p := range points {
+		properties, err := geoJSONProperties(p.Data)
+		if err != nil {
+			return fmt.Errorf("spatial: GeoJSON properties of point %d: %w", i, err)
+		}
+		id, _ := qt.pointID(p)
+		feature := geoJSONFeature{
+			Type:       "Feature",
+			ID:         id,
+			Geometry:   geoJSONGeometry{Type: "Point", Coordinates: [2]float64{p.X, p.Y}},
+			Properties: properties,
+		}
+		if err := write(feature); err != nil {
+			return err
+		}
+	}
+	if opts.Nodes {
+		var err error
+		qt.Root.walkIntersecting(area, func(n *Node) bool {
+			err = write(nodeFeature(n))
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	bw.WriteString("]}\n")
+	return bw.Flush()
+}
+
+// geoJSONProperties returns the properties member for data
+func geoJSONProperties(data any) (json.RawMessage, error) {
+	if data == nil {
+		return json.RawMessage("null"), nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		return b, nil
+	}
+	return json.Marshal(map[string]json.RawMessage{"data": b})
+}
+
+// nodeFeature returns n's bounds as a Polygon, its ring counterclockwise as
+// RFC 7946 requires of exterior rings
+func nodeFeature(n *Node) geoJSONFeature {
+	b := n.Bounds
+	ring := [][2]float64{
+		{b.X, b.Y},
+		{b.X + b.Width, b.Y},
+		{b.X + b.Width, b.Y + b.Height},
+		{b.X, b.Y + b.Height},
+		{b.X, b.Y},
+	}
+	properties, _ := json.Marshal(map[string]any{
+		"node":   true,
+		"depth":  n.Depth,
+		"leaf":   n.Children[0] == nil,
+		"points": n.count,
+	})
+	return geoJSONFeature{
+		Type:       "Feature",
+		Geometry:   geoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+		Properties: properties,
+	}
+}
+
+// walkIntersecting calls visit for every node in the subtree whose bounds
+// intersect area, parents before children, until visit returns false
+func (n *Node) walkIntersecting(area Bounds, visit func(*Node) bool) bool {
+	if !n.Bounds.Intersects(area) {
+		return true
+	}
+	if !visit(n) {
+		return false
+	}
+	if n.Children[0] != nil {
+		for i := 0; i < 4; i++ {
+			if !n.Children[i].walkIntersecting(area, visit) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// FeatureError reports a GeoJSON feature that ImportGeoJSON skipped, or the
+// error that ended the import early. Feature is the feature's index in the
+// collection, or -1 when the error is not about a single feature.
+type FeatureError struct {
+	Feature int
+	Err     error
+}
+
+func (e *FeatureError) Error() string {
+	if e.Feature < 0 {
+		return fmt.Sprintf("spatial: GeoJSON: %v", e.Err)
+	}
+	return fmt.Sprintf("spatial: GeoJSON feature %d: %v", e.Feature, e.Err)
+}
+
+func (e *FeatureError) Unwrap() error {
+	return e.Err
+}
+
+// geoJSONInput is a Feature or FeatureCollection as read by ImportGeoJSON
+type geoJSONInput struct {
+	Type     string            `json:"type"`
+	Features []json.RawMessage `json:"features"`
+	ID       any               `json:"id"`
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// ImportGeoJSON inserts the Point features of the FeatureCollection (or
+// single Feature) read from r into qt. A feature's properties become its
+// Data, as a map[string]any, or nil without properties, and a string or
+// numeric id inserts it under that id. Out-of-bounds points are rejected or
+// grow the tree as its WithAutoExpand setting says. Features that cannot be
+// inserted, such as ones with other geometries, duplicate ids or
+// out-of-bounds coordinates, are skipped and reported as *FeatureError
+// values in skipped. Malformed JSON ends the import, with the error last in
+// skipped. ImportGeoJSON reads the whole document into memory; for large
+// files use ImportGeoJSONStream.
+func ImportGeoJSON(r io.Reader, qt *QuadTree) (imported int, skipped []error) {
+	var doc geoJSONInput
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return 0, []error{&FeatureError{Feature: -1, Err: err}}
+	}
+	switch doc.Type {
+	case "FeatureCollection":
+		for i, raw := range doc.Features {
+			if err := importFeature(qt, raw); err != nil {
+				skipped = append(skipped, &FeatureError{Feature: i, Err: err})
+				continue
+			}
+			imported++
+		}
+	case "Feature":
+		if err := doc.insert(qt); err != nil {
+			return 0, []error{&FeatureError{Feature: 0, Err: err}}
+		}
+		imported = 1
+	default:
+		return 0, []error{&FeatureError{Feature: -1, Err: fmt.Errorf("type %q is not a Feature or FeatureCollection", doc.Type)}}
+	}
+	return imported, skipped
+}
+
+// ImportGeoJSONStream is ImportGeoJSON for a FeatureCollection too large to
+// hold in memory. It decodes and inserts one feature at a time, so only the
+// feature being read is held, and the collection's members may come in any
+// order. Features inserted before malformed JSON ends the import stay in qt.
+func ImportGeoJSONStream(r io.Reader, qt *QuadTree) (imported int, skipped []error) {
+	dec := json.NewDecoder(r)
+	fail := func(err error) (int, []error) {
+		return imported, append(skipped, &FeatureError{Feature: -1, Err: err})
+	}
+	if err := expectDelim(dec, '{'); err != nil {
+		return fail(err)
+	}
+	sawType := false
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return fail(err)
+		}
+		switch token {
+		case "type":
+			var kind string
+			if err := dec.Decode(&kind); err != nil {
+				return fail(err)
+			}
+			if kind != "FeatureCollection" {
+				return fail(fmt.Errorf("type %q is not a FeatureCollection", kind))
+			}
+			sawType = true
+		case "features":
+			if err := expectDelim(dec, '['); err != nil {
+				return fail(err)
+			}
+			for i := 0; dec.More(); i++ {
+				var feature geoJSONInput
+				if err := dec.Decode(&feature); err != nil {
+					if _, ok := err.(*json.UnmarshalTypeError); !ok {
+						return fail(err)
+					}
+					skipped = append(skipped, &FeatureError{Feature: i, Err: err})
+					continue
+				}
+				if err := feature.insert(qt); err != nil {
+					skipped = append(skipped, &FeatureError{Feature: i, Err: err})
+					continue
+				}
+				imported++
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return fail(err)
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return fail(err)
+	}
+	if !sawType {
+		return fail(fmt.Errorf("missing type member"))
+	}
+	return imported, skipped
+}
+
+// expectDelim reads the next token, which must be the delimiter want
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != want {
+		return fmt.Errorf("expected %v, got %v", want, token)
+	}
+	return nil
+}
+
+// importFeature decodes one raw feature and inserts it
+func importFeature(qt *QuadTree, raw json.RawMessage) error {
+	var feature geoJSONInput
+	if err := json.Unmarshal(raw, &feature); err != nil {
+		return err
+	}
+	return feature.insert(qt)
+}
+
+// insert stores the feature's point in qt
+func (f *geoJSONInput) insert(qt *QuadTree) error {
+	if f.Type != "Feature" {
+		return fmt.Errorf("type %q is not a Feature", f.Type)
+	}
+	if f.Geometry == nil {
+		return fmt.Errorf("%w: no geometry", ErrNotPointFeature)
+	}
+	if f.Geometry.Type != "Point" {
+		return fmt.Errorf("%w: %s geometry", ErrNotPointFeature, f.Geometry.Type)
+	}
+	var position []float64
+	if err := json.Unmarshal(f.Geometry.Coordinates, &position); err != nil || len(position) < 2 {
+		return fmt.Errorf("point coordinates %s are not a position", f.Geometry.Coordinates)
+	}
+	p := Point{X: position[0], Y: position[1]}
+	if f.Properties != nil {
+		p.Data = f.Properties
+	}
+	switch id := f.ID.(type) {
+	case nil:
+		return qt.InsertE(p)
+	case string:
+		return qt.InsertWithID(id, p)
+	case float64:
+		return qt.InsertWithID(strconv.FormatFloat(id, 'f', -1, 64), p)
+	default:
+		return fmt.Errorf("id %v is not a string or number", id)
+	}
+}
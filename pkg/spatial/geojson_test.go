@@ -0,0 +1,256 @@
+package spatial
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// geoJSONCollection is the structure ExportGeoJSON output must decode into
+type geoJSONCollection struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Type     string          `json:"type"`
+		ID       string          `json:"id"`
+		Geometry json.RawMessage `json:"geometry"`
+		// Properties must be present, so it is "null" rather than empty when there are none
+		Properties json.RawMessage `json:"properties"`
+	} `json:"features"`
+}
+
+func decodeGeoJSON(t *testing.T, b []byte) geoJSONCollection {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var fc geoJSONCollection
+	if err := dec.Decode(&fc); err != nil {
+		t.Fatalf("Output is not a FeatureCollection: %v\n%s", err, b)
+	}
+	if fc.Type != "FeatureCollection" || fc.Features == nil {
+		t.Fatalf("Expected a FeatureCollection with a features array, got %s", b)
+	}
+	return fc
+}
+
+type depot struct {
+	Name  string `json:"name"`
+	Docks int    `json:"docks"`
+}
+
+// TestExportGeoJSONPoints tests that points in the area become Point features
+// with their ids and Data flattened into properties
+func TestExportGeoJSONPoints(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180}, WithGeoCoordinates())
+	qt.InsertWithID("hub", Point{X: -0.1276, Y: 51.5072, Data: depot{Name: "London", Docks: 12}})
+	qt.Insert(Point{X: 2.3522, Y: 48.8566, Data: map[string]any{"city": "Paris"}})
+	qt.Insert(Point{X: 4.9041, Y: 52.3676, Data: 7})
+	qt.Insert(Point{X: 13.405, Y: 52.52})
+	qt.Insert(Point{X: 139.6917, Y: 35.6895, Data: "Tokyo"}) // Outside the area
+
+	var buf bytes.Buffer
+	if err := qt.ExportGeoJSON(&buf, Bounds{X: -10, Y: 40, Width: 30, Height: 20}); err != nil {
+		t.Fatalf("ExportGeoJSON: %v", err)
+	}
+	fc := decodeGeoJSON(t, buf.Bytes())
+	if len(fc.Features) != 4 {
+		t.Fatalf("Expected 4 features, got %d", len(fc.Features))
+	}
+
+	got := make(map[string]string)
+	for _, f := range fc.Features {
+		var geometry struct {
+			Type        string     `json:"type"`
+			Coordinates [2]float64 `json:"coordinates"`
+		}
+		if err := json.Unmarshal(f.Geometry, &geometry); err != nil || f.Type != "Feature" || geometry.Type != "Point" {
+			t.Fatalf("Expected a Point feature, got %s %s", f.Type, f.Geometry)
+		}
+		if len(f.Properties) == 0 {
+			t.Fatalf("Expected a properties member on %s", f.Geometry)
+		}
+		got[string(f.Properties)] = f.ID
+		if geometry.Coordinates == [2]float64{-0.1276, 51.5072} && f.ID != "hub" {
+			t.Errorf("Expected the id hub, got %q", f.ID)
+		}
+	}
+	for _, want := range []string{`{"name":"London","docks":12}`, `{"city":"Paris"}`, `{"data":7}`, `null`} {
+		if _, ok := got[want]; !ok {
+			t.Errorf("Expected properties %s, got %v", want, got)
+		}
+	}
+
+	qt.Insert(Point{X: 0, Y: 45, Data: make(chan int)})
+	if err := qt.ExportGeoJSON(&bytes.Buffer{}, qt.Root.Bounds); err == nil {
+		t.Error("Expected an error for Data that cannot be marshalled")
+	}
+}
+
+// TestExportGeoJSONNodes tests that node boundaries are closed,
+// counterclockwise Polygon rings covering each intersecting node
+func TestExportGeoJSONNodes(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+	for _, p := range []Point{{X: 10, Y: 10}, {X: 20, Y: 20}, {X: 80, Y: 80}, {X: 90, Y: 15}} {
+		qt.Insert(p)
+	}
+	nodes := 0
+	qt.Root.walkIntersecting(qt.Root.Bounds, func(*Node) bool { nodes++; return true })
+
+	var buf bytes.Buffer
+	if err := qt.ExportGeoJSONWith(&buf, qt.Root.Bounds, GeoJSONOptions{Nodes: true}); err != nil {
+		t.Fatalf("ExportGeoJSONWith: %v", err)
+	}
+	fc := decodeGeoJSON(t, buf.Bytes())
+	polygons := 0
+	for _, f := range fc.Features {
+		var geometry struct {
+			Type        string         `json:"type"`
+			Coordinates [][][2]float64 `json:"coordinates"`
+		}
+		if json.Unmarshal(f.Geometry, &geometry) != nil || geometry.Type != "Polygon" {
+			continue
+		}
+		polygons++
+		if len(geometry.Coordinates) != 1 {
+			t.Fatalf("Expected one ring, got %d", len(geometry.Coordinates))
+		}
+		ring := geometry.Coordinates[0]
+		if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+			t.Fatalf("Expected a closed ring of at least 4 positions, got %v", ring)
+		}
+		area := 0.0
+		for i := 0; i+1 < len(ring); i++ {
+			area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+		}
+		if area <= 0 {
+			t.Errorf("Expected a counterclockwise ring, got %v", ring)
+		}
+		var props struct {
+			Node  bool `json:"node"`
+			Depth int  `json:"depth"`
+		}
+		if json.Unmarshal(f.Properties, &props) != nil || !props.Node {
+			t.Errorf("Expected node properties, got %s", f.Properties)
+		}
+	}
+	if polygons != nodes || len(fc.Features) != nodes+4 {
+		t.Errorf("Expected %d polygons and 4 points, got %d features with %d polygons", nodes, len(fc.Features), polygons)
+	}
+}
+
+const importDoc = `{
+	"type": "FeatureCollection",
+	"bbox": [-10, -10, 10, 10],
+	"features": [
+		{"type": "Feature", "id": "stop-1", "geometry": {"type": "Point", "coordinates": [1.5, -2]}, "properties": {"seq": 1}},
+		{"type": "Feature", "id": 7, "geometry": {"type": "Point", "coordinates": [3, 4, 120]}, "properties": null},
+		{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}, "properties": {}},
+		{"type": "Feature", "geometry": null, "properties": {}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [50, 50]}, "properties": {}},
+		{"type": "Feature", "id": "stop-1", "geometry": {"type": "Point", "coordinates": [0, 0]}, "properties": {}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": ["a", "b"]}, "properties": {}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-4, 4]}, "properties": {"zone": "north"}}
+	]
+}`
+
+// TestImportGeoJSON tests that both import modes insert the Point features
+// and skip the rest with a reason, without aborting
+func TestImportGeoJSON(t *testing.T) {
+	for name, importer := range map[string]func(io.Reader, *QuadTree) (int, []error){
+		"whole": ImportGeoJSON, "stream": ImportGeoJSONStream,
+	} {
+		t.Run(name, func(t *testing.T) {
+			qt := mustNewQuadTree(Bounds{X: -10, Y: -10, Width: 20, Height: 20})
+			imported, skipped := importer(strings.NewReader(importDoc), qt)
+			if imported != 3 || len(skipped) != 5 {
+				t.Fatalf("Expected 3 imported and 5 skipped, got %d and %v", imported, skipped)
+			}
+			wantIndex := []int{2, 3, 4, 5, 6}
+			for i, err := range skipped {
+				var fe *FeatureError
+				if !errors.As(err, &fe) || fe.Feature != wantIndex[i] {
+					t.Errorf("Expected feature %d to be skipped, got %v", wantIndex[i], err)
+				}
+			}
+			for i, want := range []error{ErrNotPointFeature, ErrNotPointFeature, ErrOutOfBounds, ErrDuplicateID} {
+				if !errors.Is(skipped[i], want) {
+					t.Errorf("Expected skip %d to match %v, got %v", i, want, skipped[i])
+				}
+			}
+
+			if p, ok := qt.GetByID("stop-1"); !ok || p.X != 1.5 || p.Y != -2 || p.Data.(map[string]any)["seq"] != 1.0 {
+				t.Errorf("Expected stop-1 with its properties, got %+v %v", p, ok)
+			}
+			if p, ok := qt.GetByID("7"); !ok || p.Data != nil {
+				t.Errorf("Expected the numeric id 7 without Data, got %+v %v", p, ok)
+			}
+			if got := qt.Search(Bounds{X: -5, Y: 3, Width: 2, Height: 2}); len(got) != 1 || got[0].Data.(map[string]any)["zone"] != "north" {
+				t.Errorf("Expected the anonymous point with its properties, got %+v", got)
+			}
+		})
+	}
+}
+
+// TestImportGeoJSONAutoExpand tests that out-of-bounds features grow a tree
+// built with WithAutoExpand instead of being skipped
+func TestImportGeoJSONAutoExpand(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: -10, Y: -10, Width: 20, Height: 20}, WithAutoExpand())
+	if imported, _ := ImportGeoJSONStream(strings.NewReader(importDoc), qt); imported != 4 {
+		t.Errorf("Expected the out-of-bounds point to be imported too, got %d", imported)
+	}
+	if !qt.Root.Bounds.Contains(Point{X: 50, Y: 50}) {
+		t.Errorf("Expected the root to grow, got %+v", qt.Root.Bounds)
+	}
+}
+
+// TestImportGeoJSONMalformed tests that malformed documents end the import
+// with an error, keeping what the stream already inserted
+func TestImportGeoJSONMalformed(t *testing.T) {
+	truncated := importDoc[:strings.Index(importDoc, `{"type": "Feature", "geometry": {"type": "LineString"`)+20]
+	for _, doc := range []string{truncated, `{"type": "Feature"`, `[1, 2]`, `{"type": "Topology", "features": []}`, `{"features": []}`} {
+		qt := mustNewQuadTree(Bounds{X: -10, Y: -10, Width: 20, Height: 20})
+		if _, skipped := ImportGeoJSON(strings.NewReader(doc), qt); len(skipped) == 0 || qt.Size() != 0 {
+			t.Errorf("Expected ImportGeoJSON to reject %.30q without inserting", doc)
+		}
+		imported, skipped := ImportGeoJSONStream(strings.NewReader(doc), qt)
+		var fe *FeatureError
+		if len(skipped) == 0 || !errors.As(skipped[len(skipped)-1], &fe) || fe.Feature != -1 {
+			t.Errorf("Expected ImportGeoJSONStream to end %.30q with a document error, got %v", doc, skipped)
+		}
+		if doc == truncated && imported != 2 {
+			t.Errorf("Expected the features before the break to stay, got %d", imported)
+		}
+	}
+}
+
+// TestGeoJSONRoundTrip tests that importing an export rebuilds the points,
+// ids and map Data, streaming a collection far larger than one read buffer
+func TestGeoJSONRoundTrip(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180}, WithGeoCoordinates())
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		p := Point{X: rng.Float64()*360 - 180, Y: rng.Float64()*180 - 90, Data: map[string]any{"i": float64(i)}}
+		if i%2 == 0 {
+			qt.InsertWithID(strconv.Itoa(i), p)
+		} else {
+			qt.Insert(p)
+		}
+	}
+	r, w := io.Pipe()
+	go func() { w.CloseWithError(qt.ExportGeoJSON(w, qt.Root.Bounds)) }()
+	loaded := mustNewQuadTree(qt.Root.Bounds)
+	if imported, skipped := ImportGeoJSONStream(r, loaded); imported != 20000 || len(skipped) != 0 {
+		t.Fatalf("Expected 20000 features imported, got %d and %v", imported, skipped)
+	}
+	for i := 0; i < 20000; i += 2 {
+		p, _ := qt.GetByID(strconv.Itoa(i))
+		lp, ok := loaded.GetByID(strconv.Itoa(i))
+		if !ok || lp.X != p.X || lp.Y != p.Y || lp.Data.(map[string]any)["i"] != float64(i) {
+			t.Fatalf("Expected id %d to round-trip, got %+v", i, lp)
+		}
+	}
+}
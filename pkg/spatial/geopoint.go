@@ -0,0 +1,141 @@
+package spatial
+
+import (
+	"fmt"
+	"math"
+)
+
+// GeoPoint is a WGS84 position in degrees. Using it rather than a Point
+// keeps latitude and longitude from being swapped on the way in.
+type GeoPoint struct {
+	Lat, Lon float64
+}
+
+// NewGeoPoint returns the GeoPoint at lat, lon, or an error wrapping
+// ErrInvalidGeoPoint if either is out of range
+func NewGeoPoint(lat, lon float64) (GeoPoint, error) {
+	g := GeoPoint{Lat: lat, Lon: lon}
+	return g, g.Validate()
+}
+
+// Validate returns an error wrapping ErrInvalidGeoPoint unless Lat is in
+// [-90, 90] and Lon in [-180, 180]. NaN is out of every range.
+func (g GeoPoint) Validate() error {
+	if !(g.Lat >= -90 && g.Lat <= 90) {
+		return fmt.Errorf("%w: latitude %v outside [-90, 90]", ErrInvalidGeoPoint, g.Lat)
+	}
+	if !(g.Lon >= -180 && g.Lon <= 180) {
+		return fmt.Errorf("%w: longitude %v outside [-180, 180]", ErrInvalidGeoPoint, g.Lon)
+	}
+	return nil
+}
+
+// ToPoint projects g onto the plane, LonLat if proj is nil
+func (g GeoPoint) ToPoint(proj Projection) Point {
+	if proj == nil {
+		proj = LonLat{}
+	}
+	return proj.Project(g)
+}
+
+// FromPoint returns the position p was projected from, LonLat if proj is nil.
+// Data is not carried over.
+func FromPoint(p Point, proj Projection) GeoPoint {
+	if proj == nil {
+		proj = LonLat{}
+	}
+	return proj.Unproject(p)
+}
+
+// Projection maps positions on the Earth to the plane a tree indexes, and
+// back. Unproject(Project(g)) returns g to within 1e-9 degrees for every
+// valid g a projection covers, though a longitude of 180 may come back as
+// -180, the same meridian.
+type Projection interface {
+	Project(g GeoPoint) Point
+	Unproject(p Point) GeoPoint
+}
+
+// WithProjection sets how the tree's points were projected from positions
+// on the Earth, so the geo queries such as SearchGeo, SearchRadiusGeo and
+// KNearestGeo can find them. LonLat is assumed without it. The tree itself
+// measures on the plane, which suits a LocalProjection in meters. Like
+// hooks, it is not written by WriteTo.
+func WithProjection(proj Projection) Option {
+	return func(qt *QuadTree) {
+		qt.proj = proj
+	}
+}
+
+// LonLat puts longitude in X and latitude in Y, unscaled: the layout
+// WithGeoCoordinates and the geo queries expect.
+type LonLat struct{}
+
+func (LonLat) Project(g GeoPoint) Point {
+	return Point{X: g.Lon, Y: g.Lat}
+}
+
+func (LonLat) Unproject(p Point) GeoPoint {
+	return GeoPoint{Lat: p.Y, Lon: p.X}
+}
+
+// MaxMercatorLat is the latitude at which Web Mercator's square world ends.
+// WebMercator clamps latitudes beyond it.
+const MaxMercatorLat = 85.05112877980659
+
+// webMercatorRadius is the sphere radius EPSG:3857 is defined on
+const webMercatorRadius = 6378137.0
+
+// WebMercator is the EPSG:3857 projection web maps use, in meters from the
+// equator and the prime meridian. Distances stretch by 1/cos(lat), so it
+// suits display more than measurement. Latitudes beyond ±MaxMercatorLat
+// are clamped, so they don't round-trip.
+type WebMercator struct{}
+
+func (WebMercator) Project(g GeoPoint) Point {
+	lat := math.Max(-MaxMercatorLat, math.Min(MaxMercatorLat, g.Lat))
+	return Point{
+		X: webMercatorRadius * g.Lon * math.Pi / 180,
+		Y: webMercatorRadius * math.Log(math.Tan(math.Pi/4+lat*math.Pi/360)),
+	}
+}
+
+func (WebMercator) Unproject(p Point) GeoPoint {
+	return GeoPoint{
+		Lat: (2*math.Atan(math.Exp(p.Y/webMercatorRadius)) - math.Pi/2) * 180 / math.Pi,
+		Lon: p.X / webMercatorRadius * 180 / math.Pi,
+	}
+}
+
+// Equirectangular is a local projection in meters east and north of Origin,
+// with longitude scaled by the cosine of Origin's latitude. It is cheap and
+// close to true distance within a city or so of Origin, and the error grows
+// with distance from it. Longitudes are taken the short way round from
+// Origin, so a region spanning the antimeridian stays contiguous. Origin
+// must not be a pole.
+type Equirectangular struct {
+	Origin GeoPoint
+}
+
+// CenteredOn returns the Equirectangular projection centered on area, whose
+// X is longitude and Y latitude, such as the root bounds of a tree built
+// WithGeoCoordinates or the region a projected tree will cover
+func CenteredOn(area Bounds) Equirectangular {
+	return Equirectangular{Origin: GeoPoint{Lat: area.Y + area.Height/2, Lon: area.X + area.Width/2}}
+}
+
+func (e Equirectangular) Project(g GeoPoint) Point {
+	dLon := math.Remainder(g.Lon-e.Origin.Lon, 360)
+	return Point{
+		X: EarthRadiusMeters * dLon * math.Pi / 180 * math.Cos(e.Origin.Lat*math.Pi/180),
+		Y: EarthRadiusMeters * (g.Lat - e.Origin.Lat) * math.Pi / 180,
+	}
+}
+
+func (e Equirectangular) Unproject(p Point) GeoPoint {
+	dLon := p.X / (EarthRadiusMeters * math.Cos(e.Origin.Lat*math.Pi/180)) * 180 / math.Pi
+	return GeoPoint{
+		Lat: e.Origin.Lat + p.Y/EarthRadiusMeters*180/math.Pi,
+		Lon: math.Remainder(e.Origin.Lon+dLon, 360),
+	}
+}
@@ -0,0 +1,86 @@
+package spatial
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestGeoPointValidate tests the latitude and longitude ranges
+func TestGeoPointValidate(t *testing.T) {
+	for _, g := range []GeoPoint{{0, 0}, {90, 180}, {-90, -180}, {51.5, -0.12}} {
+		if err := g.Validate(); err != nil {
+			t.Errorf("%v: %v", g, err)
+		}
+	}
+	for _, g := range []GeoPoint{{90.1, 0}, {-91, 0}, {0, 180.5}, {0, -181}, {math.NaN(), 0}, {0, math.Inf(1)}} {
+		if err := g.Validate(); !errors.Is(err, ErrInvalidGeoPoint) {
+			t.Errorf("%v: expected ErrInvalidGeoPoint, got %v", g, err)
+		}
+	}
+	// The classic mistake: longitude passed as latitude
+	if _, err := NewGeoPoint(151.2093, -33.8688); !errors.Is(err, ErrInvalidGeoPoint) {
+		t.Errorf("Expected a swapped pair rejected, got %v", err)
+	}
+}
+
+// TestProjectionsRoundTrip tests that every projection inverts to within
+// 1e-9 degrees across both hemispheres and the antimeridian
+func TestProjectionsRoundTrip(t *testing.T) {
+	points := []GeoPoint{
+		{0, 0}, {12.9716, 77.5946}, {-33.8688, 151.2093}, {40.7128, -74.006},
+		{-54.8019, -68.303}, {64.1466, -21.9426}, {-17.7134, 178.065}, {-16.5, -179.9},
+		{85, 180}, {-85, -180}, {89.9, 10}, {-89.9, -10},
+	}
+	projections := map[string]Projection{
+		"lonlat":    LonLat{},
+		"mercator":  WebMercator{},
+		"local":     Equirectangular{Origin: GeoPoint{Lat: 12.97, Lon: 77.59}},
+		"fiji":      CenteredOn(Bounds{X: 170, Y: -25, Width: 20, Height: 15}),
+		"southwest": Equirectangular{Origin: GeoPoint{Lat: -45, Lon: -100}},
+	}
+	for name, proj := range projections {
+		for _, g := range points {
+			if _, ok := proj.(WebMercator); ok && math.Abs(g.Lat) > MaxMercatorLat {
+				continue
+			}
+			back := FromPoint(g.ToPoint(proj), proj)
+			if math.Abs(back.Lat-g.Lat) > 1e-9 || math.Abs(math.Remainder(back.Lon-g.Lon, 360)) > 1e-9 {
+				t.Errorf("%s: %v came back as %v", name, g, back)
+			}
+		}
+	}
+}
+
+// TestProjections tests known values and the local projection's scale
+func TestProjections(t *testing.T) {
+	if p := (GeoPoint{Lat: 0, Lon: 180}).ToPoint(WebMercator{}); math.Abs(p.X-20037508.342789244) > 1e-6 || p.Y != 0 {
+		t.Errorf("Expected the Web Mercator world edge, got %v", p)
+	}
+	if p := (GeoPoint{Lat: MaxMercatorLat, Lon: 0}).ToPoint(WebMercator{}); math.Abs(p.Y-20037508.342789244) > 1e-6 {
+		t.Errorf("Expected the Web Mercator world top, got %v", p)
+	}
+	if pole, edge := (GeoPoint{Lat: 90}).ToPoint(WebMercator{}), (GeoPoint{Lat: MaxMercatorLat}).ToPoint(WebMercator{}); pole != edge {
+		t.Errorf("Expected the pole clamped to %v, got %v", edge, pole)
+	}
+	if p := (GeoPoint{Lat: -33.86, Lon: 151.2}).ToPoint(nil); p.X != 151.2 || p.Y != -33.86 {
+		t.Errorf("Expected X longitude and Y latitude by default, got %v", p)
+	}
+
+	// Within a city, planar distance in the local projection is close to the
+	// great-circle distance
+	proj := Equirectangular{Origin: GeoPoint{Lat: 48.8566, Lon: 2.3522}}
+	a, b := GeoPoint{Lat: 48.8, Lon: 2.25}, GeoPoint{Lat: 48.9, Lon: 2.45}
+	planar := Distance(a.ToPoint(proj), b.ToPoint(proj))
+	great := HaversineDistance(a, b)
+	if math.Abs(planar-great) > great*0.001 {
+		t.Errorf("Expected %v m locally, got %v", great, planar)
+	}
+
+	// Across the antimeridian the short way round is taken
+	fiji := CenteredOn(Bounds{X: 170, Y: -25, Width: 20, Height: 15})
+	east, west := GeoPoint{Lat: -17, Lon: 179.9}, GeoPoint{Lat: -17, Lon: -179.9}
+	if d := Distance(east.ToPoint(fiji), west.ToPoint(fiji)); d > 30000 {
+		t.Errorf("Expected points either side of the antimeridian close together, got %v m", d)
+	}
+}
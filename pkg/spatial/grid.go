@@ -0,0 +1,288 @@
+package spatial
+
+import (
+	"math"
+	"sync"
+)
+
+// MaxGridCells is the most cells NewGridIndex will divide its bounds into
+const MaxGridCells = 1 << 24
+
+// GridIndex is a SpatialIndex over a fixed grid of square cells, each a
+// slice of the points inside it. With points spread evenly and cells sized
+// to hold a few each, it needs no tree to descend and far less memory per
+// point than a QuadTree; clustered points crowd a few cells and make it
+// slower than one. Distances are planar. It is safe for concurrent use.
+type GridIndex struct {
+	bounds     Bounds
+	cellSize   float64
+	cols, rows int
+
+	mu      sync.RWMutex // Guards everything below
+	cells   [][]Point    // Row by row from the bounds' min corner
+	size    int
+	nextSeq uint64
+}
+
+var _ SpatialIndex = (*GridIndex)(nil)
+
+// NewGridIndex returns an empty grid over bounds with cells cellSize on a
+// side, the last row and column cut short by the bounds. It returns
+// ErrInvalidBounds or ErrInvalidCellSize if either is unusable.
+func NewGridIndex(bounds Bounds, cellSize float64) (*GridIndex, error) {
+	if err := bounds.Validate(); err != nil {
+		return nil, err
+	}
+	if !(cellSize > 0) || math.IsInf(cellSize, 1) {
+		return nil, ErrInvalidCellSize
+	}
+	cols := max(math.Ceil(bounds.Width/cellSize), 1)
+	rows := max(math.Ceil(bounds.Height/cellSize), 1)
+	if cols*rows > MaxGridCells {
+		return nil, ErrInvalidCellSize
+	}
+	return &GridIndex{
+		bounds:   bounds,
+		cellSize: cellSize,
+		cols:     int(cols),
+		rows:     int(rows),
+		cells:    make([][]Point, int(cols*rows)),
+	}, nil
+}
+
+// Bounds returns the area the grid covers
+func (g *GridIndex) Bounds() Bounds {
+	return g.bounds
+}
+
+// col returns the column holding x, clamped to the grid
+func (g *GridIndex) col(x float64) int {
+	return clampCell((x-g.bounds.X)/g.cellSize, g.cols)
+}
+
+// row returns the row holding y, clamped to the grid
+func (g *GridIndex) row(y float64) int {
+	return clampCell((y-g.bounds.Y)/g.cellSize, g.rows)
+}
+
+func clampCell(f float64, n int) int {
+	switch {
+	case !(f >= 0):
+		return 0
+	case f >= float64(n):
+		return n - 1
+	}
+	return int(f)
+}
+
+// cell returns the index into cells of the cell holding p
+func (g *GridIndex) cell(p Point) int {
+	return g.row(p.Y)*g.cols + g.col(p.X)
+}
+
+// Insert stores p as a new record, reporting false if its coordinates are
+// NaN or infinite or it is outside the grid's bounds
+func (g *GridIndex) Insert(p Point) bool {
+	if !validCoordinates(p) || !g.bounds.Contains(p) {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.nextSeq++
+	p.seq = g.nextSeq
+	p.loc = nil
+	p.expires = 0
+	c := g.cell(p)
+	g.cells[c] = append(g.cells[c], p)
+	g.size++
+	return true
+}
+
+// find returns the cell and position within it of the record p refers to,
+// matched as QuadTree matches it. Callers must hold mu.
+func (g *GridIndex) find(p Point) (int, int, bool) {
+	if !validCoordinates(p) || !g.bounds.Contains(p) {
+		return 0, 0, false
+	}
+	c := g.cell(p)
+	match := -1
+	for i, q := range g.cells[c] {
+		if q.X != p.X || q.Y != p.Y {
+			continue
+		}
+		if p.seq != 0 {
+			if q.seq == p.seq {
+				return c, i, true
+			}
+			continue
+		}
+		if match == -1 || q.seq < g.cells[c][match].seq {
+			match = i
+		}
+	}
+	return c, match, match != -1
+}
+
+// drop removes the point at i in cell c. Callers must hold mu.
+func (g *GridIndex) drop(c, i int) {
+	points := g.cells[c]
+	last := len(points) - 1
+	points[i] = points[last]
+	points[last] = Point{}
+	g.cells[c] = points[:last]
+}
+
+// Remove deletes the record p refers to and reports whether it was found
+func (g *GridIndex) Remove(p Point) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, i, ok := g.find(p)
+	if !ok {
+		return false
+	}
+	g.drop(c, i)
+	g.size--
+	return true
+}
+
+// Update moves the record oldPoint refers to onto newPoint, moving it to
+// another cell if need be. It reports false, changing nothing, if no record
+// matches or newPoint can't be stored.
+func (g *GridIndex) Update(oldPoint, newPoint Point) bool {
+	if !validCoordinates(newPoint) || !g.bounds.Contains(newPoint) {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, i, ok := g.find(oldPoint)
+	if !ok {
+		return false
+	}
+	newPoint.seq = g.cells[c][i].seq
+	newPoint.loc = nil
+	newPoint.expires = 0
+	if to := g.cell(newPoint); to != c {
+		g.drop(c, i)
+		g.cells[to] = append(g.cells[to], newPoint)
+	} else {
+		g.cells[c][i] = newPoint
+	}
+	return true
+}
+
+// visit calls fn for every point in the cells overlapping area until fn
+// returns false. Callers must hold mu.
+func (g *GridIndex) visit(area Bounds, fn func(Point) bool) {
+	if area.Validate() != nil || !g.bounds.Intersects(area) {
+		return
+	}
+	c0, c1 := g.col(area.X), g.col(area.X+area.Width)
+	r0, r1 := g.row(area.Y), g.row(area.Y+area.Height)
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= c1; c++ {
+			for _, p := range g.cells[r*g.cols+c] {
+				if !fn(p) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Search returns every point inside area, edges included
+func (g *GridIndex) Search(area Bounds) []Point {
+	results := make([]Point, 0)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	g.visit(area, func(p Point) bool {
+		if area.Contains(p) {
+			results = append(results, p)
+		}
+		return true
+	})
+	return results
+}
+
+// SearchRadius returns every point within radius of center
+func (g *GridIndex) SearchRadius(center Point, radius float64) []Point {
+	results := make([]Point, 0)
+	if !(radius >= 0) || !validCoordinates(center) {
+		return results
+	}
+	r2 := radius * radius
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	g.visit(Bounds{X: center.X - radius, Y: center.Y - radius, Width: 2 * radius, Height: 2 * radius}, func(p Point) bool {
+		if withinSquared(DistanceSquared(center, p), radius, r2) {
+			results = append(results, p)
+		}
+		return true
+	})
+	return results
+}
+
+// KNearest returns the k points closest to target, nearest first, with ties
+// ranked by insertion order. It searches rings of cells outward from the
+// cell nearest target until no unsearched cell can hold a nearer point.
+func (g *GridIndex) KNearest(target Point, k int) []Point {
+	if k <= 0 || !validCoordinates(target) {
+		return make([]Point, 0)
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	k = min(k, g.size)
+	if k == 0 {
+		return make([]Point, 0)
+	}
+
+	c0, r0 := g.col(target.X), g.row(target.Y)
+	rings := max(c0, g.cols-1-c0, r0, g.rows-1-r0)
+	nearest := make(nearestHeap, 0, k) // The k nearest so far, farthest on top
+	for d := 0; d <= rings; d++ {
+		for r := r0 - d; r <= r0+d; r++ {
+			if r < 0 || r >= g.rows {
+				continue
+			}
+			step := 2 * d // Only the ring's ends on rows inside it
+			if r == r0-d || r == r0+d || d == 0 {
+				step = 1
+			}
+			for c := c0 - d; c <= c0+d; c += step {
+				if c < 0 || c >= g.cols {
+					continue
+				}
+				for _, p := range g.cells[r*g.cols+c] {
+					nearest.offer(PointWithDistance{Point: p, Distance: DistanceSquared(target, p)}, k)
+				}
+			}
+		}
+		// Cells in the next ring are at least d whole cells away, slightly
+		// less to allow for rounding in picking a point's cell
+		reach := float64(d) * g.cellSize * (1 - 1e-9)
+		if len(nearest) == k && nearest[0].Distance < reach*reach {
+			break
+		}
+	}
+	return nearest.points()
+}
+
+// Count returns how many points are stored
+func (g *GridIndex) Count() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.size
+}
+
+// ForEach calls fn for every point, cell by cell, until fn returns false.
+// It holds a read lock throughout, so fn must not modify the grid.
+func (g *GridIndex) ForEach(fn func(Point) bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	for _, points := range g.cells {
+		for _, p := range points {
+			if !fn(p) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package spatial_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/spatialtest"
+)
+
+// TestGridIndexConformance runs the SpatialIndex suite over grids of one
+// cell, cells that don't divide the bounds evenly, and fine cells
+func TestGridIndexConformance(t *testing.T) {
+	for name, size := range map[string]float64{"OneCell": 5000, "Uneven": 37, "Fine": 4} {
+		t.Run(name, func(t *testing.T) {
+			spatialtest.Conformance(t, func(b spatial.Bounds) spatial.SpatialIndex {
+				g, err := spatial.NewGridIndex(b, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return g
+			})
+		})
+	}
+}
+
+func TestNewGridIndexErrors(t *testing.T) {
+	bounds := spatial.Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	for _, size := range []float64{0, -1, math.NaN(), math.Inf(1), 1e-3} {
+		if _, err := spatial.NewGridIndex(bounds, size); !errors.Is(err, spatial.ErrInvalidCellSize) {
+			t.Errorf("cell size %v: expected ErrInvalidCellSize, got %v", size, err)
+		}
+	}
+	if _, err := spatial.NewGridIndex(spatial.Bounds{Width: -1, Height: 1}, 1); !errors.Is(err, spatial.ErrInvalidBounds) {
+		t.Errorf("expected ErrInvalidBounds, got %v", err)
+	}
+	g, err := spatial.NewGridIndex(spatial.Bounds{X: 5, Y: 5}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Insert(spatial.Point{X: 5, Y: 5}) || g.Insert(spatial.Point{X: 5, Y: 6}) {
+		t.Error("expected a zero-area grid to hold its one point only")
+	}
+}
+
+// gridCellSize suits distBounds and 100,000 points: about four per cell
+// when they are uniform
+const gridCellSize = 63
+
+func newDistGrid(b *testing.B, points []spatial.Point) *spatial.GridIndex {
+	g, err := spatial.NewGridIndex(distBounds, gridCellSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, p := range points {
+		g.Insert(p)
+	}
+	return g
+}
+
+// The head-to-head benchmarks run the same queries on a GridIndex and a
+// QuadTree over each distribution, through the SpatialIndex interface
+func forEachIndex(b *testing.B, bench func(b *testing.B, ix spatial.SpatialIndex, points []spatial.Point)) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		b.Run("Grid", func(b *testing.B) { bench(b, newDistGrid(b, points), points) })
+		b.Run("QuadTree", func(b *testing.B) { bench(b, newDistTree(b, points), points) })
+	})
+}
+
+func BenchmarkIndexSearch(b *testing.B) {
+	forEachIndex(b, func(b *testing.B, ix spatial.SpatialIndex, points []spatial.Point) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p := points[(i*7919)%len(points)]
+			ix.Search(spatial.Bounds{X: p.X - 50, Y: p.Y - 50, Width: 100, Height: 100})
+		}
+	})
+}
+
+func BenchmarkIndexKNearest(b *testing.B) {
+	forEachIndex(b, func(b *testing.B, ix spatial.SpatialIndex, points []spatial.Point) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ix.KNearest(points[(i*7919)%len(points)], 10)
+		}
+	})
+}
+
+// BenchmarkIndexUpdate moves each point a short way and back
+func BenchmarkIndexUpdate(b *testing.B) {
+	forEachIndex(b, func(b *testing.B, ix spatial.SpatialIndex, points []spatial.Point) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p := points[(i*7919)%len(points)]
+			moved := spatial.Point{X: math.Min(p.X+20, distBounds.Width), Y: p.Y}
+			ix.Update(p, moved)
+			ix.Update(moved, p)
+		}
+	})
+}
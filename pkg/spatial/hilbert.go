@@ -0,0 +1,160 @@
+package spatial
+
+import "fmt"
+
+// MaxHilbertOrder is the finest Hilbert curve HilbertXY2D and HilbertD2XY
+// handle: a 2^32 x 2^32 grid, whose indexes fill a uint64
+const MaxHilbertOrder = 32
+
+// The curve is walked one level at a time. At each level the two bits of a
+// cell's coordinates, read through the level's orientation, give the
+// cell's digit along the curve, and the cell sets the orientation of the
+// level below it. An orientation is two independent bits, because the
+// curve's only turns are a swap of x and y and a flip of both, which
+// commute: hilbertSwap and hilbertFlip.
+const (
+	hilbertSwap = 1
+	hilbertFlip = 2
+)
+
+// hilbertDigit returns the position along the curve, 0 to 3, of quadrant q
+// (qx | qy<<1) of a cell with orientation state, and the orientation of that
+// quadrant
+func hilbertDigit(state uint8, q uint64) (uint64, uint8) {
+	rx, ry := q&1, q>>1
+	if state&hilbertFlip != 0 {
+		rx, ry = rx^1, ry^1
+	}
+	if state&hilbertSwap != 0 {
+		rx, ry = ry, rx
+	}
+	if ry == 0 {
+		if rx == 1 {
+			state ^= hilbertFlip
+		}
+		state ^= hilbertSwap
+	}
+	return (3 * rx) ^ ry, state
+}
+
+// hilbertQuadrant is the inverse of hilbertDigit: the quadrant at position
+// digit along the curve in a cell with orientation state, and its
+// orientation
+func hilbertQuadrant(state uint8, digit uint64) (uint64, uint8) {
+	rx := digit >> 1
+	ry := (digit ^ rx) & 1
+	next := state
+	if ry == 0 {
+		if rx == 1 {
+			next ^= hilbertFlip
+		}
+		next ^= hilbertSwap
+	}
+	if state&hilbertSwap != 0 {
+		rx, ry = ry, rx
+	}
+	if state&hilbertFlip != 0 {
+		rx, ry = rx^1, ry^1
+	}
+	return rx | ry<<1, next
+}
+
+// hilbertFromPath converts a quadrant path of levels digits, as quadrantPath
+// makes, to the Hilbert index of the same cell
+func hilbertFromPath(path uint64, levels int) uint64 {
+	var d uint64
+	var state uint8
+	for shift := 2 * (levels - 1); shift >= 0; shift -= 2 {
+		var digit uint64
+		digit, state = hilbertDigit(state, path>>uint(shift)&3)
+		d = d<<2 | digit
+	}
+	return d
+}
+
+func checkHilbertOrder(order int) {
+	if order < 1 || order > MaxHilbertOrder {
+		panic(fmt.Sprintf("spatial: Hilbert order %d outside 1..%d", order, MaxHilbertOrder))
+	}
+}
+
+// HilbertXY2D returns the position of cell (x, y) along the Hilbert curve
+// through a 2^order x 2^order grid, starting from (0, 0) and ending at
+// (2^order-1, 0). Bits of x and y above order are ignored. It panics if
+// order is outside 1..MaxHilbertOrder.
+func HilbertXY2D(order int, x, y uint32) uint64 {
+	checkHilbertOrder(order)
+	var d uint64
+	var state uint8
+	for i := order - 1; i >= 0; i-- {
+		var digit uint64
+		digit, state = hilbertDigit(state, uint64(x>>uint(i)&1)|uint64(y>>uint(i)&1)<<1)
+		d = d<<2 | digit
+	}
+	return d
+}
+
+// HilbertD2XY returns the cell at position d along the Hilbert curve
+// through a 2^order x 2^order grid, the inverse of HilbertXY2D. Bits of d
+// above 2*order are ignored. It panics if order is outside
+// 1..MaxHilbertOrder.
+func HilbertD2XY(order int, d uint64) (x, y uint32) {
+	checkHilbertOrder(order)
+	var state uint8
+	for i := order - 1; i >= 0; i-- {
+		var q uint64
+		q, state = hilbertQuadrant(state, d>>uint(2*i)&3)
+		x = x<<1 | uint32(q&1)
+		y = y<<1 | uint32(q>>1)
+	}
+	return x, y
+}
+
+// HilbertInterval is a run of consecutive positions along a Hilbert curve,
+// First to Last inclusive
+type HilbertInterval struct {
+	First, Last uint64
+}
+
+// HilbertIntervals decomposes the cells from (minX, minY) to (maxX, maxY)
+// inclusive of a 2^order x 2^order grid into the runs of the Hilbert curve
+// through them, ascending and with adjacent runs merged. Because the curve
+// never jumps, a rectangle needs far fewer runs than along a Z-order curve.
+// It returns nil if min exceeds max on either axis, and panics if order is
+// outside 1..MaxHilbertOrder.
+func HilbertIntervals(order int, minX, minY, maxX, maxY uint32) []HilbertInterval {
+	checkHilbertOrder(order)
+	if order < 32 {
+		limit := uint32(1)<<uint(order) - 1
+		maxX, maxY = min(maxX, limit), min(maxY, limit)
+	}
+	if minX > maxX || minY > maxY {
+		return nil
+	}
+	var runs []HilbertInterval
+	hilbertIntervals(order, 0, 0, 0, 0, minX, minY, maxX, maxY, &runs)
+	return runs
+}
+
+// hilbertIntervals appends the runs through the query inside the cell at
+// (x, y), of side 2^level, whose curve positions start at d
+func hilbertIntervals(level int, state uint8, x, y uint64, d uint64, minX, minY, maxX, maxY uint32, runs *[]HilbertInterval) {
+	side := uint64(1) << uint(level)
+	if x > uint64(maxX) || y > uint64(maxY) || x+side-1 < uint64(minX) || y+side-1 < uint64(minY) {
+		return
+	}
+	if x >= uint64(minX) && y >= uint64(minY) && x+side-1 <= uint64(maxX) && y+side-1 <= uint64(maxY) {
+		last := d + (side*side - 1) // side*side overflows to 0 for the whole of order 32
+		if n := len(*runs); n > 0 && (*runs)[n-1].Last+1 == d {
+			(*runs)[n-1].Last = last
+		} else {
+			*runs = append(*runs, HilbertInterval{First: d, Last: last})
+		}
+		return
+	}
+	half := side / 2
+	for digit := uint64(0); digit < 4; digit++ {
+		q, next := hilbertQuadrant(state, digit)
+		hilbertIntervals(level-1, next, x+half*(q&1), y+half*(q>>1), d+digit*half*half, minX, minY, maxX, maxY, runs)
+	}
+}
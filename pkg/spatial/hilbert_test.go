@@ -0,0 +1,133 @@
+package spatial
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestHilbertExhaustive tests every cell at small orders: decoding each
+// position and encoding it again round-trips, the curve starts and ends
+// where documented, and each step moves to a neighbouring cell
+func TestHilbertExhaustive(t *testing.T) {
+	for order := 1; order <= 8; order++ {
+		n := uint64(1) << uint(2*order)
+		px, py := HilbertD2XY(order, 0)
+		if px != 0 || py != 0 {
+			t.Fatalf("order %d: expected the curve to start at 0, 0, got %d, %d", order, px, py)
+		}
+		for d := uint64(0); d < n; d++ {
+			x, y := HilbertD2XY(order, d)
+			if got := HilbertXY2D(order, x, y); got != d {
+				t.Fatalf("order %d: %d decodes to %d, %d, which encodes to %d", order, d, x, y, got)
+			}
+			if d > 0 && absDiff(x, px)+absDiff(y, py) != 1 {
+				t.Fatalf("order %d: step %d jumps from %d, %d to %d, %d", order, d, px, py, x, y)
+			}
+			px, py = x, y
+		}
+		if px != uint32(1)<<uint(order)-1 || py != 0 {
+			t.Fatalf("order %d: expected the curve to end at the max x, 0, got %d, %d", order, px, py)
+		}
+	}
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// TestHilbertRoundTrip tests random cells and positions at every order,
+// up to the full 64-bit range
+func TestHilbertRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for order := 1; order <= MaxHilbertOrder; order++ {
+		mask := uint32(1)<<uint(order) - 1
+		if order == 32 {
+			mask = ^uint32(0)
+		}
+		for i := 0; i < 2000; i++ {
+			x, y := rng.Uint32(), rng.Uint32()
+			gx, gy := HilbertD2XY(order, HilbertXY2D(order, x, y))
+			if gx != x&mask || gy != y&mask {
+				t.Fatalf("order %d: %d, %d round-trips to %d, %d", order, x, y, gx, gy)
+			}
+			d := rng.Uint64() >> uint(64-2*order)
+			dx, dy := HilbertD2XY(order, d)
+			if got := HilbertXY2D(order, dx, dy); got != d {
+				t.Fatalf("order %d: %d round-trips to %d", order, d, got)
+			}
+			if last := uint64(1)<<uint(2*order) - 1; d != last { // 1<<64 wraps to 0, so last is right at order 32 too
+				x1, y1 := HilbertD2XY(order, d)
+				x2, y2 := HilbertD2XY(order, d+1)
+				if absDiff(x1, x2)+absDiff(y1, y2) != 1 {
+					t.Fatalf("order %d: step %d jumps from %d, %d to %d, %d", order, d+1, x1, y1, x2, y2)
+				}
+			}
+		}
+	}
+}
+
+// TestHilbertFromPath tests that an index's keys, converted from quadrant
+// paths, are the Hilbert indexes of the cells the points fall in
+func TestHilbertFromPath(t *testing.T) {
+	const levels = 10
+	bounds := Bounds{X: 0, Y: 0, Width: 1 << levels, Height: 1 << levels}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		x, y := rng.Uint32()&(1<<levels-1), rng.Uint32()&(1<<levels-1)
+		p := Point{X: float64(x) + rng.Float64(), Y: float64(y) + rng.Float64()}
+		if got, want := hilbertFromPath(bounds.quadrantPath(p, levels), levels), HilbertXY2D(levels, x, y); got != want {
+			t.Fatalf("%v: expected %d, got %d", p, want, got)
+		}
+	}
+}
+
+// TestHilbertIntervals tests that the runs cover exactly the positions of
+// the cells in random rectangles, ascending and merged
+func TestHilbertIntervals(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for order := 1; order <= 6; order++ {
+		side := uint32(1) << uint(order)
+		for i := 0; i < 300; i++ {
+			minX, minY := rng.Uint32()%side, rng.Uint32()%side
+			maxX, maxY := minX+rng.Uint32()%(side-minX), minY+rng.Uint32()%(side-minY)
+			runs := HilbertIntervals(order, minX, minY, maxX, maxY)
+			covered := make(map[uint64]bool)
+			for j, r := range runs {
+				if r.First > r.Last || (j > 0 && r.First <= runs[j-1].Last+1) {
+					t.Fatalf("order %d: runs %v are not ascending and merged", order, runs)
+				}
+				for d := r.First; d <= r.Last; d++ {
+					covered[d] = true
+				}
+			}
+			for d := uint64(0); d < uint64(side)*uint64(side); d++ {
+				x, y := HilbertD2XY(order, d)
+				if inside := x >= minX && x <= maxX && y >= minY && y <= maxY; inside != covered[d] {
+					t.Fatalf("order %d, %d..%d x %d..%d: position %d at %d, %d covered %v", order, minX, maxX, minY, maxY, d, x, y, covered[d])
+				}
+			}
+		}
+	}
+	if got := HilbertIntervals(MaxHilbertOrder, 0, 0, ^uint32(0), ^uint32(0)); len(got) != 1 || got[0] != (HilbertInterval{0, ^uint64(0)}) {
+		t.Errorf("expected the whole grid to be one run, got %v", got)
+	}
+	if got := HilbertIntervals(4, 5, 0, 4, 3); got != nil {
+		t.Errorf("expected no runs for an empty rectangle, got %v", got)
+	}
+}
+
+func TestHilbertOrderPanics(t *testing.T) {
+	for _, order := range []int{0, MaxHilbertOrder + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected order %d to panic", order)
+				}
+			}()
+			HilbertXY2D(order, 0, 0)
+		}()
+	}
+}
@@ -0,0 +1,226 @@
+package spatial
+
+import (
+	"sync/atomic"
+)
+
+// DefaultHookBuffer is how many events may wait for the hook goroutine before
+// new ones are dropped, unless WithHookBuffer says otherwise.
+const DefaultHookBuffer = 1024
+
+// OnInsert registers fn to be called with every point added to the tree.
+// Hooks run on a separate goroutine after the mutation has completed, in the
+// order the mutations happened, so a slow hook never stalls writers. If hooks
+// fall more than the hook buffer behind, further events are dropped and
+// counted in DroppedEvents.
+func OnInsert(fn func(Point)) Option {
+	return func(qt *QuadTree) {
+		qt.hookFns.insert = fn
+	}
+}
+
+// OnRemove registers fn to be called with every point removed from the
+// tree, including by expiry and Clear.
+func OnRemove(fn func(Point)) Option {
+	return func(qt *QuadTree) {
+		qt.hookFns.remove = fn
+	}
+}
+
+// OnMove registers fn to be called when a stored point is moved or replaced
+// in place by Update, UpdateByID, Move, MoveEntry or an Upsert of an existing
+// id. Moves fire only OnMove, never OnRemove and OnInsert.
+func OnMove(fn func(from, to Point)) Option {
+	return func(qt *QuadTree) {
+		qt.hookFns.move = fn
+	}
+}
+
+// OnSubdivide registers fn to be called with the bounds of every node that splits
+func OnSubdivide(fn func(Bounds)) Option {
+	return func(qt *QuadTree) {
+		qt.hookFns.subdivide = fn
+	}
+}
+
+// OnMerge registers fn to be called with the bounds of every node whose
+// children are merged back into it.
+func OnMerge(fn func(Bounds)) Option {
+	return func(qt *QuadTree) {
+		qt.hookFns.merge = fn
+	}
+}
+
+// WithHookBuffer sets how many events may be queued for the hooks
+func WithHookBuffer(n int) Option {
+	return func(qt *QuadTree) {
+		qt.hookBuffer = n
+	}
+}
+
+type hookFuncs struct {
+	insert    func(Point)
+	remove    func(Point)
+	move      func(from, to Point)
+	subdivide func(Bounds)
+	merge     func(Bounds)
+}
+
+func (f hookFuncs) any() bool {
+	return f.insert != nil || f.remove != nil || f.move != nil || f.subdivide != nil || f.merge != nil
+}
+
+type eventKind uint8
+
+const (
+	insertEvent eventKind = iota
+	removeEvent
+	moveEvent
+	subdivideEvent
+	mergeEvent
+)
+
+type event struct {
+	kind   eventKind
+	point  Point
+	to     Point
+	bounds Bounds
+}
+
+// hookQueue carries events from writers to the hook goroutine. Events are
+// only emitted under the tree's write lock, which also guards closed.
+type hookQueue struct {
+	fns     hookFuncs
+	events  chan event
+	done    chan struct{}
+	closed  bool
+	dropped atomic.Uint64
+}
+
+func newHookQueue(fns hookFuncs, buffer int) *hookQueue {
+	h := &hookQueue{
+		fns:    fns,
+		events: make(chan event, buffer),
+		done:   make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+func (h *hookQueue) run() {
+	defer close(h.done)
+	for e := range h.events {
+		switch e.kind {
+		case insertEvent:
+			h.fns.insert(e.point)
+		case removeEvent:
+			h.fns.remove(e.point)
+		case moveEvent:
+			h.fns.move(e.point, e.to)
+		case subdivideEvent:
+			h.fns.subdivide(e.bounds)
+		case mergeEvent:
+			h.fns.merge(e.bounds)
+		}
+	}
+}
+
+// wants reports whether a hook is registered for kind; safe on a nil queue
+func (h *hookQueue) wants(kind eventKind) bool {
+	if h == nil || h.closed {
+		return false
+	}
+	switch kind {
+	case insertEvent:
+		return h.fns.insert != nil
+	case removeEvent:
+		return h.fns.remove != nil
+	case moveEvent:
+		return h.fns.move != nil
+	case subdivideEvent:
+		return h.fns.subdivide != nil
+	default:
+		return h.fns.merge != nil
+	}
+}
+
+// emit queues e without blocking, counting it as dropped if the buffer is full
+func (h *hookQueue) emit(e event) {
+	if !h.wants(e.kind) {
+		return
+	}
+	select {
+	case h.events <- e:
+	default:
+		h.dropped.Add(1)
+	}
+}
+
+// observing reports whether a hook, watcher, the data-key index, the audit
+// sink, the query cache or the existence filter wants point events of kind
+func (qt *QuadTree) observing(kind eventKind) bool {
+	return len(qt.watchers) > 0 || qt.wal != nil || qt.dataKey != nil || qt.audit != nil || qt.qcache != nil || qt.filter != nil || qt.hooks.wants(kind)
+}
+
+// inserted, removed and moved report a completed point mutation to the
+// version counter, data-key index, query cache, existence filter, audit
+// sink, write-ahead log, hooks and watchers. Callers must hold the write lock.
+func (qt *QuadTree) inserted(p Point) {
+	if p.loc != nil {
+		p.loc.x, p.loc.y = p.X, p.Y
+	}
+	qt.changed()
+	qt.index(p)
+	qt.qcache.invalidate(p)
+	qt.filter.addPoint(p)
+	qt.audit.emit(qt, AuditInsert, nil, &p)
+	qt.wal.log(walInsert, Point{}, p)
+	qt.hooks.emit(event{kind: insertEvent, point: p})
+	qt.notifyWatchers(ChangeEvent{Kind: PointAdded, Point: p})
+}
+
+func (qt *QuadTree) removed(p Point) {
+	qt.changed()
+	qt.unindex(p)
+	qt.qcache.invalidate(p)
+	qt.filter.removePoint(p)
+	qt.audit.emit(qt, AuditRemove, &p, nil)
+	qt.wal.log(walRemove, Point{}, p)
+	qt.hooks.emit(event{kind: removeEvent, point: p})
+	qt.notifyWatchers(ChangeEvent{Kind: PointRemoved, Point: p})
+}
+
+func (qt *QuadTree) moved(from, to Point) {
+	if to.loc != nil {
+		to.loc.x, to.loc.y = to.X, to.Y
+	}
+	qt.changed()
+	qt.reindex(from, to)
+	qt.qcache.invalidate(from)
+	qt.qcache.invalidate(to)
+	qt.filter.removePoint(from)
+	qt.filter.addPoint(to)
+	qt.audit.emit(qt, AuditMove, &from, &to)
+	qt.wal.log(walMove, from, to)
+	qt.hooks.emit(event{kind: moveEvent, point: from, to: to})
+	qt.notifyWatchers(ChangeEvent{Kind: PointMoved, Point: to, From: from})
+}
+
+// close stops accepting events. Callers must hold the tree's write lock; the
+// hook goroutine exits once it has run the events already queued.
+func (h *hookQueue) close() {
+	if h == nil || h.closed {
+		return
+	}
+	h.closed = true
+	close(h.events)
+}
+
+// DroppedEvents returns how many hook events were dropped because the hook
+// buffer was full.
+func (qt *QuadTree) DroppedEvents() uint64 {
+	if qt.hooks == nil {
+		return 0
+	}
+	return qt.hooks.dropped.Load()
+}
@@ -0,0 +1,105 @@
+package spatial
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type hookLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *hookLog) add(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fmt.Sprintf(format, args...))
+}
+
+func (l *hookLog) options() []Option {
+	return []Option{
+		OnInsert(func(p Point) { l.add("insert %v", p.Data) }),
+		OnRemove(func(p Point) { l.add("remove %v", p.Data) }),
+		OnMove(func(from, to Point) { l.add("move %v %v,%v->%v,%v", to.Data, from.X, from.Y, to.X, to.Y) }),
+		OnSubdivide(func(b Bounds) { l.add("subdivide %v", b) }),
+		OnMerge(func(b Bounds) { l.add("merge %v", b) }),
+	}
+}
+
+// TestHooksFireInOrder tests the events for a sequence of mutations
+func TestHooksFireInOrder(t *testing.T) {
+	log := &hookLog{}
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, append(log.options(), WithCapacity(1))...)
+
+	qt.Insert(Point{X: 10, Y: 10, Data: "a"})
+	qt.Insert(Point{X: 90, Y: 90, Data: "b"})
+	qt.Update(Point{X: 90, Y: 90}, Point{X: 80, Y: 80, Data: "b"})
+	qt.InsertWithID("c", Point{X: 10, Y: 90, Data: "c"})
+	qt.Move("c", 60, 10)
+	qt.Remove(Point{X: 80, Y: 80})
+	qt.RemoveByID("c")
+	qt.Remove(Point{X: 1, Y: 1})
+	qt.Close()
+
+	want := []string{
+		"insert a",
+		"subdivide {0 0 100 100}",
+		"insert b",
+		"move b 90,90->80,80",
+		"insert c",
+		"move c 10,90->60,10",
+		"remove b",
+		"merge {0 0 100 100}",
+		"remove c",
+	}
+	if fmt.Sprint(log.events) != fmt.Sprint(want) {
+		t.Errorf("Expected events\n%v\ngot\n%v", want, log.events)
+	}
+
+	// Hooks stop after Close
+	qt.Insert(Point{X: 5, Y: 5, Data: "late"})
+	if len(log.events) != len(want) {
+		t.Errorf("Hook fired after Close: %v", log.events[len(want):])
+	}
+}
+
+// TestHooksBatchAndClear tests that InsertAll and Clear report every point
+func TestHooksBatchAndClear(t *testing.T) {
+	var mu sync.Mutex
+	inserted, removed := 0, 0
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+		OnInsert(func(Point) { mu.Lock(); inserted++; mu.Unlock() }),
+		OnRemove(func(Point) { mu.Lock(); removed++; mu.Unlock() }))
+
+	batch := make([]Point, 50)
+	for i := range batch {
+		batch[i] = Point{X: float64(i * 2), Y: float64(i)}
+	}
+	qt.InsertAll(batch)
+	qt.Clear()
+	qt.Close()
+
+	if inserted != 50 || removed != 50 {
+		t.Errorf("Expected 50 inserts and 50 removes, got %d and %d", inserted, removed)
+	}
+}
+
+// TestHooksSlowHookDropsEvents tests that a stuck hook drops events instead of blocking writers
+func TestHooksSlowHookDropsEvents(t *testing.T) {
+	release := make(chan struct{})
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+		OnInsert(func(Point) { <-release }), WithHookBuffer(4))
+
+	for i := 0; i < 100; i++ {
+		if !qt.Insert(Point{X: float64(i), Y: float64(i)}) {
+			t.Fatalf("Insert %d failed", i)
+		}
+	}
+	// Four events are buffered, plus one if the hook goroutine already picked it up
+	if got := qt.DroppedEvents(); got != 95 && got != 96 {
+		t.Errorf("Expected 95 or 96 dropped events, got %d", got)
+	}
+	close(release)
+	qt.Close()
+}
@@ -0,0 +1,310 @@
+package spatial
+
+// location records which leaf currently holds an ID-keyed or handle-tracked
+// point. insert keeps leaf up to date whenever the point is placed, including
+// during SubDivide; leaf is nil once the point has been removed.
+type location struct {
+	id   string
+	leaf *Node
+	x, y float64 // Last position, so a write can find the subtree to lock before reading leaf
+}
+
+// dropLoc forgets a removed point's location so stale IDs and handles miss
+func (qt *QuadTree) dropLoc(loc *location) {
+	if qt.ids[loc.id] == loc {
+		delete(qt.ids, loc.id)
+	}
+	loc.leaf = nil
+}
+
+// InsertWithID inserts p under a stable id. IDs are unique: inserting an id
+// that is already present returns ErrDuplicateID and leaves the tree
+// unchanged, use UpdateByID to move an existing point.
+func (qt *QuadTree) InsertWithID(id string, p Point) error {
+	if err := qt.insertShared(p, &location{id: id}); err != errExclusive {
+		return err
+	}
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if _, exists := qt.ids[id]; exists {
+		return ErrDuplicateID
+	}
+	if err := qt.admit(p); err != nil {
+		return err
+	}
+	qt.insertID(id, p)
+	return nil
+}
+
+// insertID stores p under a new id. Callers must hold the write lock and have
+// checked that p is accepted.
+func (qt *QuadTree) insertID(id string, p Point) {
+	if qt.ids == nil {
+		qt.ids = make(map[string]*location)
+	}
+	loc := &location{id: id}
+	qt.nextSeq++
+	p.seq = qt.nextSeq
+	p.loc = loc
+	p.expires = 0
+	qt.Root.insert(p)
+	qt.ids[id] = loc
+	qt.size++
+	qt.inserted(p)
+}
+
+// Upsert stores p under id, inserting it if the id is new and replacing the
+// existing point (position and Data) otherwise. Both cases happen under one
+// lock, so readers never see the id missing mid-replace. created reports
+// whether the id was new. An out-of-bounds p returns ErrOutOfBounds and
+// leaves any existing point where it was, as does an implausible jump
+// under WithJumpFilter. Under WithTrack, a stored p is observed in the
+// track.
+func (qt *QuadTree) Upsert(id string, p Point) (created bool, err error) {
+	if qt.jumps != nil {
+		// Only a reading the tree could store is judged, and only one it did
+		// store is recorded, so the filter measures speed from stored points
+		if err := qt.fits(p); err != nil {
+			return false, err
+		}
+		obs, jumpErr := qt.jumps.judge(id, p, qt.clock())
+		if jumpErr != nil {
+			return false, jumpErr
+		}
+		defer func() {
+			if err == nil {
+				qt.jumps.accept(id, obs)
+			}
+		}()
+	}
+	if qt.track != nil {
+		// Deferred first, so it runs once the write lock is released
+		defer func() {
+			if err == nil {
+				qt.track.Observe(id, p, qt.clock())
+			}
+		}()
+	}
+	if created, err := qt.upsertShared(id, p); err != errExclusive {
+		return created, err
+	}
+	qt.Lock.Lock()
+	defer qt.unlock()
+	return qt.upsert(id, p)
+}
+
+// upsert stores p under id. Callers must hold the write lock.
+func (qt *QuadTree) upsert(id string, p Point) (created bool, err error) {
+	if !qt.own() {
+		return false, ErrReadOnly
+	}
+	if err := qt.place(p); err != nil {
+		return false, err
+	}
+	loc, exists := qt.ids[id]
+	if !exists {
+		if qt.full() {
+			return false, ErrTreeFull
+		}
+		qt.insertID(id, p)
+		return true, nil
+	}
+	old, _ := loc.point()
+	p.seq = old.seq
+	p.loc = loc
+	qt.relocate(old, p)
+	return false, nil
+}
+
+// RemoveByID removes the point stored under id, touching only the leaf that holds it
+func (qt *QuadTree) RemoveByID(id string) bool {
+	_, ok := qt.TakeByID(id)
+	return ok
+}
+
+// TakeByID removes the point stored under id and returns it including its Data
+func (qt *QuadTree) TakeByID(id string) (Point, bool) {
+	if removed, err := qt.takeByIDShared(id); err != errExclusive {
+		return removed, err == nil
+	}
+	qt.Lock.Lock()
+	defer qt.unlock()
+	loc, ok := qt.ids[id]
+	if !ok || !qt.own() {
+		return Point{}, false
+	}
+	return qt.takeLoc(loc), true
+}
+
+// takeLoc removes the tracked point at loc. Callers must hold the write lock
+// and have called own.
+func (qt *QuadTree) takeLoc(loc *location) Point {
+	qt.ownLoc(loc)
+	removed := loc.leaf.removeLoc(loc, nil)
+	qt.dropLoc(loc)
+	qt.size--
+	qt.removed(removed)
+	return removed
+}
+
+// UpdateByID moves the point stored under id to newP, replacing its Data
+func (qt *QuadTree) UpdateByID(id string, newP Point) error {
+	if err := qt.relocateShared(id, newP, false); err != errExclusive {
+		return err
+	}
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.own() {
+		return ErrReadOnly
+	}
+	loc, ok := qt.ids[id]
+	if !ok {
+		return ErrNotFound
+	}
+	return qt.replaceLoc(loc, newP)
+}
+
+// replaceLoc moves the tracked point at loc to newP, replacing its Data.
+// Callers must hold the write lock and have called own.
+func (qt *QuadTree) replaceLoc(loc *location, newP Point) error {
+	if err := qt.place(newP); err != nil {
+		return err
+	}
+	old, _ := loc.point()
+	newP.seq = old.seq
+	newP.loc = loc
+	qt.relocate(old, newP)
+	return nil
+}
+
+// relocate replaces the tracked point from with p, rewriting it in place when
+// it stays in the same leaf. Callers must hold the write lock.
+func (qt *QuadTree) relocate(from, p Point) {
+	qt.moved(from, p)
+	qt.ownLoc(p.loc)
+	qt.Root.relocate(p, nil)
+}
+
+// relocate moves the tracked point p, already carrying its location, to its
+// new position inside n. stop is passed on to afterRemove.
+func (n *Node) relocate(p Point, stop *Node) {
+	leaf := p.loc.leaf
+	if n.leafFor(p) == leaf {
+		for i := range leaf.Points {
+			if leaf.Points[i].loc == p.loc {
+				leaf.setPoint(i, p)
+				leaf.touchWrite()
+				return
+			}
+		}
+	}
+	leaf.removeLoc(p.loc, stop)
+	n.insert(p)
+}
+
+// leafFor returns the leaf that point routes to below n
+func (n *Node) leafFor(point Point) *Node {
+	for n.Children[0] != nil {
+		n = n.Children[n.quadrant(point)]
+	}
+	return n
+}
+
+// Move relocates the point stored under id to (newX, newY), carrying its
+// existing Data along. Unknown ids return ErrNotFound; an out-of-bounds
+// destination returns ErrOutOfBounds and leaves the point where it was.
+func (qt *QuadTree) Move(id string, newX, newY float64) error {
+	if err := qt.relocateShared(id, Point{X: newX, Y: newY}, true); err != errExclusive {
+		return err
+	}
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.own() {
+		return ErrReadOnly
+	}
+	loc, ok := qt.ids[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := qt.place(Point{X: newX, Y: newY}); err != nil {
+		return err
+	}
+	from, _ := loc.point()
+	p := from
+	p.X = newX
+	p.Y = newY
+	qt.relocate(from, p)
+	return nil
+}
+
+// GetByID returns the point stored under id
+func (qt *QuadTree) GetByID(id string) (Point, bool) {
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	loc, q, ok := qt.lockLoc(id, false)
+	if !ok {
+		return Point{}, false
+	}
+	defer qt.quads[q].RUnlock()
+	return loc.point()
+}
+
+// point returns the tracked point from its leaf. Callers must hold the lock.
+func (loc *location) point() (Point, bool) {
+	if loc.leaf == nil {
+		return Point{}, false
+	}
+	for _, p := range loc.leaf.Points {
+		if p.loc == loc {
+			return p, true
+		}
+	}
+	return Point{}, false
+}
+
+// removeLoc drops the point owned by loc from this leaf and returns it. stop
+// is passed on to afterRemove.
+func (n *Node) removeLoc(loc *location, stop *Node) Point {
+	for i, p := range n.Points {
+		if p.loc == loc {
+			n.deletePoint(i)
+			n.touchWrite()
+			n.afterRemove(stop)
+			return p
+		}
+	}
+	return Point{}
+}
+
+// ID returns the id p was stored under when the tree returned it or passed
+// it to a hook, for points inserted with one
+func (p Point) ID() (string, bool) {
+	if p.loc == nil {
+		return "", false
+	}
+	return p.loc.id, true
+}
+
+// PointWithID is a point and the id it is stored under
+type PointWithID struct {
+	Point Point
+	ID    string
+}
+
+// SearchIDs returns the points in area that are stored under an id, with
+// their ids, using the tree's edge semantics. Points inserted without one
+// are left out.
+func (qt *QuadTree) SearchIDs(area Bounds) []PointWithID {
+	qt.rlockAll()
+	defer qt.runlockAll()
+
+	points := make([]Point, 0)
+	qt.searchLive(area, qt.edges, &points)
+	results := make([]PointWithID, 0, len(points))
+	for _, p := range points {
+		if id, ok := qt.pointID(p); ok {
+			results = append(results, PointWithID{Point: p, ID: id})
+		}
+	}
+	return results
+}
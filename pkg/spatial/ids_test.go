@@ -0,0 +1,301 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestInsertWithIDAndRemoveByID tests the basic ID lifecycle
+func TestInsertWithIDAndRemoveByID(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	if err := qt.InsertWithID("driver-1", Point{X: 10, Y: 10, Data: "d1"}); err != nil {
+		t.Fatalf("InsertWithID failed: %v", err)
+	}
+
+	p, ok := qt.GetByID("driver-1")
+	if !ok || p.Data != "d1" {
+		t.Fatalf("GetByID returned %v, %v", p, ok)
+	}
+
+	if !qt.RemoveByID("driver-1") {
+		t.Error("RemoveByID failed")
+	}
+	if qt.RemoveByID("driver-1") {
+		t.Error("RemoveByID should fail for an already removed ID")
+	}
+	if len(qt.Search(qt.Root.Bounds)) != 0 {
+		t.Error("Tree should be empty after RemoveByID")
+	}
+}
+
+// TestInsertWithIDDuplicate tests that reusing an ID is rejected
+func TestInsertWithIDDuplicate(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	qt.InsertWithID("a", Point{X: 10, Y: 10})
+	err := qt.InsertWithID("a", Point{X: 20, Y: 20})
+
+	if !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("Expected ErrDuplicateID, got %v", err)
+	}
+	if len(qt.Search(qt.Root.Bounds)) != 1 {
+		t.Error("Duplicate insert should leave the tree unchanged")
+	}
+}
+
+// TestInsertWithIDOutOfBounds tests inserting an ID-keyed point outside the tree
+func TestInsertWithIDOutOfBounds(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	err := qt.InsertWithID("a", Point{X: 200, Y: 20})
+	if !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("Expected ErrOutOfBounds, got %v", err)
+	}
+	if _, ok := qt.GetByID("a"); ok {
+		t.Error("Rejected point should not be indexed")
+	}
+}
+
+// TestRemoveByIDAfterSubdivision tests that the leaf index follows points as nodes split
+func TestRemoveByIDAfterSubdivision(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+
+	for i := 0; i < 40; i++ {
+		id := fmt.Sprintf("d%d", i)
+		if err := qt.InsertWithID(id, Point{X: float64(i*37%100) + 0.5, Y: float64(i*11%100) + 0.5, Data: id}); err != nil {
+			t.Fatalf("InsertWithID %s failed: %v", id, err)
+		}
+	}
+
+	for i := 0; i < 40; i += 2 {
+		if !qt.RemoveByID(fmt.Sprintf("d%d", i)) {
+			t.Errorf("RemoveByID d%d failed", i)
+		}
+	}
+
+	results := qt.Search(qt.Root.Bounds)
+	if len(results) != 20 {
+		t.Fatalf("Expected 20 points, got %d", len(results))
+	}
+	for _, r := range results {
+		var n int
+		fmt.Sscanf(r.Data.(string), "d%d", &n)
+		if n%2 == 0 {
+			t.Errorf("Point %v should have been removed", r.Data)
+		}
+	}
+}
+
+// TestUpdateByID tests moving a point by ID
+func TestUpdateByID(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+
+	qt.InsertWithID("a", Point{X: 10, Y: 10, Data: "a"})
+	qt.InsertWithID("b", Point{X: 90, Y: 90, Data: "b"})
+
+	if err := qt.UpdateByID("a", Point{X: 80, Y: 80, Data: "a2"}); err != nil {
+		t.Fatalf("UpdateByID failed: %v", err)
+	}
+
+	results := qt.Search(Bounds{X: 75, Y: 75, Width: 10, Height: 10})
+	if len(results) != 1 || results[0].Data != "a2" {
+		t.Errorf("Expected moved point at new position, got %v", results)
+	}
+	if len(qt.Search(Bounds{X: 0, Y: 0, Width: 20, Height: 20})) != 0 {
+		t.Error("Old position should be empty")
+	}
+
+	if err := qt.UpdateByID("missing", Point{X: 1, Y: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := qt.UpdateByID("a", Point{X: 500, Y: 1}); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("Expected ErrOutOfBounds, got %v", err)
+	}
+	if p, _ := qt.GetByID("a"); p.X != 80 {
+		t.Error("Failed update should leave the point in place")
+	}
+
+	if !qt.RemoveByID("a") {
+		t.Error("RemoveByID after UpdateByID failed")
+	}
+}
+
+// TestMovePreservesData tests that Move carries the stored Data along
+func TestMovePreservesData(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+	meta := map[string]string{"vehicle": "van-7"}
+
+	qt.InsertWithID("d", Point{X: 10, Y: 10, Data: meta})
+	qt.InsertWithID("other", Point{X: 90, Y: 90})
+
+	// One move inside the same leaf, one across the tree
+	for _, dest := range []Point{{X: 12, Y: 12}, {X: 75, Y: 20}} {
+		if err := qt.Move("d", dest.X, dest.Y); err != nil {
+			t.Fatalf("Move to %v failed: %v", dest, err)
+		}
+		p, ok := qt.GetByID("d")
+		if !ok || p.X != dest.X || p.Y != dest.Y {
+			t.Fatalf("Expected point at %v, got %v", dest, p)
+		}
+		if got, _ := p.Data.(map[string]string); got["vehicle"] != "van-7" {
+			t.Errorf("Data not preserved after move to %v: %v", dest, p.Data)
+		}
+	}
+	if len(qt.Search(Bounds{X: 0, Y: 0, Width: 20, Height: 20})) != 0 {
+		t.Error("Old position should be empty")
+	}
+}
+
+// TestMoveErrors tests the typed errors returned by Move
+func TestMoveErrors(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+	qt.InsertWithID("d", Point{X: 10, Y: 10, Data: "d"})
+
+	if err := qt.Move("missing", 1, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := qt.Move("d", 101, 1); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("Expected ErrOutOfBounds, got %v", err)
+	}
+	if p, ok := qt.GetByID("d"); !ok || p.X != 10 || p.Y != 10 || p.Data != "d" {
+		t.Errorf("Failed move should leave the point in place, got %v", p)
+	}
+}
+
+// TestUpsert tests the create-then-replace lifecycle
+func TestUpsert(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+
+	created, err := qt.Upsert("d", Point{X: 10, Y: 10, Data: "v1"})
+	if err != nil || !created {
+		t.Fatalf("First Upsert should create, got created=%v err=%v", created, err)
+	}
+	created, err = qt.Upsert("d", Point{X: 70, Y: 30, Data: "v2"})
+	if err != nil || created {
+		t.Fatalf("Second Upsert should replace, got created=%v err=%v", created, err)
+	}
+
+	p, _ := qt.GetByID("d")
+	if p.X != 70 || p.Y != 30 || p.Data != "v2" {
+		t.Errorf("Expected replaced point, got %v", p)
+	}
+	if qt.Size() != 1 || len(qt.Search(qt.Root.Bounds)) != 1 {
+		t.Errorf("Expected exactly one stored point, got %d", qt.Size())
+	}
+
+	// Out of bounds keeps the old position
+	created, err = qt.Upsert("d", Point{X: 170, Y: 30, Data: "v3"})
+	if !errors.Is(err, ErrOutOfBounds) || created {
+		t.Errorf("Expected ErrOutOfBounds, got created=%v err=%v", created, err)
+	}
+	if p, _ := qt.GetByID("d"); p.X != 70 || p.Data != "v2" {
+		t.Errorf("Failed Upsert should leave the point in place, got %v", p)
+	}
+	if _, err := qt.Upsert("new", Point{X: -1, Y: 0}); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("Expected ErrOutOfBounds for a new id, got %v", err)
+	}
+	if _, ok := qt.GetByID("new"); ok {
+		t.Error("Rejected Upsert should not create the id")
+	}
+}
+
+// TestUpsertNeverMissing tests that concurrent readers always see an upserted driver
+func TestUpsertNeverMissing(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(2))
+	qt.Upsert("d", Point{X: 1, Y: 1})
+	for i := 0; i < 50; i++ {
+		qt.Insert(Point{X: float64(i * 20), Y: float64(i * 20)})
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, ok := qt.GetByID("d"); !ok {
+				t.Error("Driver missing during Upsert")
+				return
+			}
+		}
+	}()
+	for i := 0; i < 500; i++ {
+		qt.Upsert("d", Point{X: float64(i % 1000), Y: float64((i * 7) % 1000)})
+	}
+	close(done)
+	wg.Wait()
+}
+
+// TestCoordinateRemoveDropsID tests that removing an ID-keyed point by coordinates keeps the index consistent
+func TestCoordinateRemoveDropsID(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	qt.InsertWithID("a", Point{X: 10, Y: 10})
+	if !qt.Remove(Point{X: 10, Y: 10}) {
+		t.Fatal("Remove failed")
+	}
+	if qt.RemoveByID("a") {
+		t.Error("ID should be gone after coordinate removal")
+	}
+	if err := qt.InsertWithID("a", Point{X: 20, Y: 20}); err != nil {
+		t.Errorf("ID should be reusable after removal, got %v", err)
+	}
+}
+
+// TestSearchIDs tests that only points stored under live ids are returned
+func TestSearchIDs(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+	qt.InsertWithID("a", Point{X: 10, Y: 10, Data: "van"})
+	qt.InsertWithID("b", Point{X: 20, Y: 20})
+	qt.InsertWithID("c", Point{X: 80, Y: 80})
+	qt.Insert(Point{X: 15, Y: 15})
+	qt.RemoveByID("b")
+
+	got := qt.SearchIDs(Bounds{X: 0, Y: 0, Width: 50, Height: 50})
+	if len(got) != 1 || got[0].ID != "a" || got[0].Point.Data != "van" {
+		t.Errorf("Expected only a, got %v", got)
+	}
+	if id, ok := got[0].Point.ID(); !ok || id != "a" {
+		t.Errorf("Expected the point to carry its id, got %q", id)
+	}
+	if _, ok := qt.Search(Bounds{X: 14, Y: 14, Width: 2, Height: 2})[0].ID(); ok {
+		t.Error("Expected no id for a point inserted without one")
+	}
+}
+
+// TestIDConcurrentUpdateRemove tests concurrent ID operations
+func TestIDConcurrentUpdateRemove(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4))
+
+	for i := 0; i < 100; i++ {
+		qt.InsertWithID(fmt.Sprintf("d%d", i), Point{X: float64(i * 10), Y: float64(i * 10)})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("d%d", i)
+			qt.UpdateByID(id, Point{X: float64(999 - i*10), Y: float64(i * 5)})
+			if i%2 == 0 {
+				qt.RemoveByID(id)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(qt.Search(qt.Root.Bounds)) != 50 {
+		t.Errorf("Expected 50 points, got %d", len(qt.Search(qt.Root.Bounds)))
+	}
+
+	checkValid(t, qt)
+}
@@ -0,0 +1,141 @@
+package spatial
+
+import "container/heap"
+
+// SpatialIndex is the read-write surface a dispatch layer needs from a
+// point index, so QuadTree can be swapped for another backend. Every
+// implementation must behave as described here, which
+// spatialtest.Conformance checks:
+//
+//   - Insert stores p as a new record, even if an equal point is already
+//     stored, and reports false for NaN or infinite coordinates or a point
+//     the index can't hold, such as one outside its bounds.
+//   - Remove deletes the record p refers to: exactly that record if p was
+//     returned by one of the index's queries, otherwise the earliest
+//     inserted record at p's coordinates. It reports whether one was found.
+//   - Update moves the record oldPoint refers to, picked as Remove picks
+//     it, onto newPoint, which keeps its place in insertion order. It
+//     reports false, changing nothing, if no record matches or newPoint
+//     can't be stored.
+//   - Search returns the points inside area, edges included.
+//   - SearchRadius returns the points at a planar distance of at most
+//     radius from center, and none for a negative radius.
+//   - KNearest returns the k points closest to target by planar distance,
+//     nearest first, ties in insertion order.
+//   - Count returns how many points are stored.
+//   - ForEach calls fn for every point until fn returns false. fn must not
+//     modify the index.
+//
+// Queries return points in no particular order unless stated, as a fresh
+// slice the caller may keep, and an empty slice rather than nil when
+// nothing matches. Implementations are safe for concurrent use. An index
+// set up to measure differently, such as a QuadTree built
+// WithGeoCoordinates or WithMetric, satisfies the interface but measures
+// SearchRadius and KNearest its own way.
+type SpatialIndex interface {
+	SpatialReader
+	Insert(p Point) bool
+	Remove(p Point) bool
+	Update(oldPoint, newPoint Point) bool
+}
+
+// SpatialReader is the read-only subset of SpatialIndex, for indexes built
+// once and then only queried. The methods mean what SpatialIndex says.
+type SpatialReader interface {
+	Search(area Bounds) []Point
+	SearchRadius(center Point, radius float64) []Point
+	KNearest(target Point, k int) []Point
+	Count() int
+	ForEach(fn func(Point) bool)
+}
+
+var (
+	_ SpatialIndex  = (*QuadTree)(nil)
+	_ SpatialReader = (*FrozenTree)(nil)
+)
+
+// Count returns how many points are stored, as Size does
+func (qt *QuadTree) Count() int {
+	return qt.Size()
+}
+
+// ForEach calls fn for every point until fn returns false. It walks a
+// Snapshot, so unlike other implementations' fn, this one may modify the
+// tree, without affecting the walk.
+func (qt *QuadTree) ForEach(fn func(Point) bool) {
+	snap := qt.Snapshot()
+	if snap.Root == nil {
+		return
+	}
+	snap.forEachIn(snap.Root.Bounds, InclusiveEdges, fn)
+}
+
+// ForEachIn calls fn for every unexpired point in area, as Search would find
+// them, until fn returns false. Like ForEach it walks a Snapshot one leaf at
+// a time, so exports can stream a large area without collecting it first.
+func (qt *QuadTree) ForEachIn(area Bounds, fn func(Point) bool) {
+	snap := qt.Snapshot()
+	if snap.Root == nil {
+		return
+	}
+	snap.forEachIn(area, snap.edges, fn)
+}
+
+func (qt *QuadTree) forEachIn(area Bounds, edges Edges, fn func(Point) bool) {
+	var points []Point
+	qt.Root.walkIntersecting(area, func(n *Node) bool {
+		if n.Children[0] != nil {
+			return true
+		}
+		points = points[:0]
+		n.searchEdges(area, edges, &points)
+		qt.dropExpired(&points, 0)
+		for _, p := range points {
+			if !fn(p) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// nearestHeap is a max-heap of points by distance, then insertion order
+type nearestHeap []PointWithDistance
+
+// farther reports whether a ranks after b
+func farther(a, b PointWithDistance) bool {
+	return a.Distance > b.Distance || (a.Distance == b.Distance && a.Point.seq > b.Point.seq)
+}
+
+func (h nearestHeap) Len() int            { return len(h) }
+func (h nearestHeap) Less(i, j int) bool  { return farther(h[i], h[j]) }
+func (h nearestHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nearestHeap) Push(x interface{}) { *h = append(*h, x.(PointWithDistance)) }
+func (h *nearestHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// offer adds pd if the heap holds fewer than k points, or else replaces the
+// farthest with pd if pd ranks before it
+func (h *nearestHeap) offer(pd PointWithDistance, k int) {
+	if len(*h) < k {
+		heap.Push(h, pd)
+		return
+	}
+	if farther((*h)[0], pd) {
+		(*h)[0] = pd
+		heap.Fix(h, 0)
+	}
+}
+
+// points empties the heap into a slice, nearest first
+func (h *nearestHeap) points() []Point {
+	results := make([]Point, len(*h))
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(h).(PointWithDistance).Point
+	}
+	return results
+}
@@ -0,0 +1,78 @@
+package spatial_test
+
+import (
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/spatialtest"
+)
+
+// TestQuadTreeConformance runs the SpatialIndex suite over trees configured
+// to take each of their read and write paths
+func TestQuadTreeConformance(t *testing.T) {
+	for name, opts := range map[string][]spatial.Option{
+		"Default":       nil,
+		"Capacity1":     {spatial.WithCapacity(1)},
+		"LockFreeReads": {spatial.WithLockFreeReads()},
+		"Arena":         {spatial.WithArena(64)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			spatialtest.Conformance(t, func(b spatial.Bounds) spatial.SpatialIndex {
+				qt, err := spatial.NewQuadTree(b, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return qt
+			})
+		})
+	}
+}
+
+// TestForEachWrites tests that ForEach's fn may write to the tree
+func TestForEachWrites(t *testing.T) {
+	qt, err := spatial.NewQuadTree(spatial.Bounds{X: 0, Y: 0, Width: 100, Height: 100}, spatial.WithCapacity(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		qt.Insert(spatial.Point{X: float64(i), Y: float64(i)})
+	}
+	visited := 0
+	qt.ForEach(func(p spatial.Point) bool {
+		visited++
+		qt.Remove(p)
+		qt.Insert(spatial.Point{X: p.X + 50, Y: p.Y})
+		return true
+	})
+	if visited != 20 || qt.Count() != 20 {
+		t.Errorf("expected 20 visited and 20 stored, got %d and %d", visited, qt.Count())
+	}
+}
+
+// TestForEachIn tests that ForEachIn visits what Search finds, under the
+// tree's edges, and stops when fn returns false
+func TestForEachIn(t *testing.T) {
+	qt, _ := spatial.NewQuadTree(spatial.Bounds{X: 0, Y: 0, Width: 100, Height: 100}, spatial.WithCapacity(2), spatial.WithSearchEdges(spatial.HalfOpenEdges))
+	for x := 0; x < 100; x += 10 {
+		for y := 0; y < 100; y += 10 {
+			qt.Insert(spatial.Point{X: float64(x), Y: float64(y)})
+		}
+	}
+	area := spatial.Bounds{X: 10, Y: 10, Width: 30, Height: 30}
+	seen := 0
+	qt.ForEachIn(area, func(p spatial.Point) bool {
+		if !area.ContainsWith(p, spatial.HalfOpenEdges) {
+			t.Errorf("visited %v outside the area", p)
+		}
+		seen++
+		return true
+	})
+	if want := len(qt.Search(area)); seen != want || want != 9 {
+		t.Errorf("expected 9 points as Search finds, visited %d of %d", seen, want)
+	}
+	seen = 0
+	qt.ForEachIn(area, func(spatial.Point) bool { seen++; return false })
+	if seen != 1 {
+		t.Errorf("expected the walk to stop after 1, got %d", seen)
+	}
+}
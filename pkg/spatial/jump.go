@@ -0,0 +1,228 @@
+package spatial
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultJumpConfirmations is how many readings must follow an implausible
+// jump before it is believed, when JumpFilterConfig.Confirmations is 0
+const DefaultJumpConfirmations = 2
+
+// JumpFilterConfig configures NewJumpFilter
+type JumpFilterConfig struct {
+	// MaxSpeed is the fastest an ID may plausibly move, in meters per second
+	// under Geo and X/Y units per second otherwise. It is required.
+	MaxSpeed float64
+	Geo      bool // X and Y are longitude and latitude, measured by haversine in meters
+	// Tolerance is a distance any update may move regardless of speed, to
+	// allow for GPS noise between readings close together in time
+	Tolerance float64
+	// Confirmations is how many readings, each plausible from the one
+	// before, must follow a jump for it to be accepted as a genuine move,
+	// such as a ferry crossing or a tow. DefaultJumpConfirmations if 0.
+	Confirmations int
+	// OnReject is called with every rejected update, on the caller's
+	// goroutine, to count or log upstream data quality. It must not call
+	// back into the filter.
+	OnReject func(*JumpError)
+	// TTL is how long after its last accepted update an ID is forgotten,
+	// judged by the times passed to Check. Zero keeps IDs until Forget.
+	TTL time.Duration
+}
+
+// JumpError is returned for an update that would move an ID faster than
+// the filter's MaxSpeed. It matches ErrImplausibleJump.
+type JumpError struct {
+	ID       string
+	From, To Point   // The last accepted position and the rejected one
+	Speed    float64 // The speed the update implies
+	Pending  int     // Readings near To so far, this one included
+	Needed   int     // Readings near To needed to accept the jump
+}
+
+func (e *JumpError) Error() string {
+	return fmt.Sprintf("spatial: %s jumped from (%v, %v) to (%v, %v) at %.4g per second, %d of %d readings there",
+		e.ID, e.From.X, e.From.Y, e.To.X, e.To.Y, e.Speed, e.Pending, e.Needed)
+}
+
+func (e *JumpError) Unwrap() error {
+	return ErrImplausibleJump
+}
+
+// JumpFilter rejects location updates that teleport an ID further than it
+// could have travelled since its last accepted one, unless further readings
+// agree with the new location. Use it standalone through Check, or have a
+// tree's Upsert consult it through WithJumpFilter. It is safe for
+// concurrent use.
+type JumpFilter struct {
+	cfg      JumpFilterConfig
+	rejected atomic.Uint64
+
+	mu        sync.Mutex // Guards ids, latest and lastSweep
+	ids       map[string]*jumpState
+	latest    time.Time
+	lastSweep time.Time
+}
+
+type jumpState struct {
+	last    observation
+	pending []observation // Readings since a jump, each plausible from the one before
+}
+
+// NewJumpFilter returns a filter with no IDs, or ErrInvalidJumpFilter if
+// cfg.MaxSpeed is not positive or cfg.Tolerance is negative
+func NewJumpFilter(cfg JumpFilterConfig) (*JumpFilter, error) {
+	if !(cfg.MaxSpeed > 0) || !(cfg.Tolerance >= 0) {
+		return nil, ErrInvalidJumpFilter
+	}
+	if cfg.Confirmations <= 0 {
+		cfg.Confirmations = DefaultJumpConfirmations
+	}
+	return &JumpFilter{cfg: cfg, ids: make(map[string]*jumpState)}, nil
+}
+
+// Check decides whether id may move to p at t, and if so records p as its
+// latest position. An ID's first update is always accepted. An update
+// implying more than MaxSpeed from the last accepted one returns a
+// *JumpError and is buffered; once Confirmations more readings follow it,
+// each plausible from the one before, the last of them is accepted. A
+// plausible update in between discards the buffered ones as the bogus
+// readings they were. A p with a NaN or infinite coordinate returns
+// ErrInvalidPoint and leaves what the filter knows of id alone.
+func (f *JumpFilter) Check(id string, p Point, t time.Time) error {
+	obs, err := f.judge(id, p, t)
+	if err != nil {
+		return err
+	}
+	f.accept(id, obs)
+	return nil
+}
+
+// judge is Check without recording an accepted update, so a caller can
+// accept it once it has been stored; rejections are recorded as Check
+// records them
+func (f *JumpFilter) judge(id string, p Point, t time.Time) (observation, error) {
+	if !validCoordinates(p) {
+		return observation{}, ErrInvalidPoint
+	}
+	obs, jump := f.check(id, observation{p: Point{X: p.X, Y: p.Y}, t: t})
+	if jump != nil {
+		f.rejected.Add(1)
+		if f.cfg.OnReject != nil {
+			f.cfg.OnReject(jump)
+		}
+		return obs, jump
+	}
+	return obs, nil
+}
+
+func (f *JumpFilter) check(id string, obs observation) (observation, *JumpError) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if obs.t.After(f.latest) {
+		f.latest = obs.t
+	}
+	f.sweep()
+
+	st := f.ids[id]
+	if st == nil {
+		return obs, nil
+	}
+	speed, ok := f.plausible(st.last, obs)
+	if ok {
+		return obs, nil
+	}
+	if n := len(st.pending); n > 0 {
+		if _, near := f.plausible(st.pending[n-1], obs); !near {
+			st.pending = st.pending[:0] // A different jump; start counting again
+		}
+	}
+	if len(st.pending) >= f.cfg.Confirmations {
+		return obs, nil
+	}
+	st.pending = append(st.pending, obs)
+	return obs, &JumpError{ID: id, From: st.last.p, To: obs.p, Speed: speed, Pending: len(st.pending), Needed: f.cfg.Confirmations + 1}
+}
+
+// accept records obs as id's latest position, discarding any buffered jump
+func (f *JumpFilter) accept(id string, obs observation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if st := f.ids[id]; st != nil {
+		st.last, st.pending = obs, st.pending[:0]
+		return
+	}
+	f.ids[id] = &jumpState{last: obs}
+}
+
+// plausible returns the speed moving from a to b implies, and whether it
+// is within MaxSpeed or the move within Tolerance. A reading older than a
+// is measured as if simultaneous with it.
+func (f *JumpFilter) plausible(a, b observation) (float64, bool) {
+	var d float64
+	if f.cfg.Geo {
+		d = haversine(a.p, b.p)
+	} else {
+		d = Distance(a.p, b.p)
+	}
+	if d <= f.cfg.Tolerance {
+		return 0, true
+	}
+	dt := b.t.Sub(a.t).Seconds()
+	if dt <= 0 {
+		return math.Inf(1), false
+	}
+	return d / dt, d/dt <= f.cfg.MaxSpeed
+}
+
+// Rejected returns how many updates Check has rejected
+func (f *JumpFilter) Rejected() uint64 {
+	return f.rejected.Load()
+}
+
+// Forget drops what the filter knows of id, so its next update is accepted
+// as a first. It reports whether id was known.
+func (f *JumpFilter) Forget(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.ids[id]
+	delete(f.ids, id)
+	return ok
+}
+
+// Len returns the number of IDs the filter knows
+func (f *JumpFilter) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.ids)
+}
+
+// sweep forgets IDs not accepted within the TTL of the latest time seen, at
+// most once per TTL. Callers must hold mu.
+func (f *JumpFilter) sweep() {
+	if f.cfg.TTL <= 0 || f.latest.Sub(f.lastSweep) < f.cfg.TTL {
+		return
+	}
+	f.lastSweep = f.latest
+	cutoff := f.latest.Add(-f.cfg.TTL)
+	for id, st := range f.ids {
+		if st.last.t.Before(cutoff) {
+			delete(f.ids, id)
+		}
+	}
+}
+
+// WithJumpFilter has Upsert pass every update through f, timed by the
+// tree's clock, and return its *JumpError rather than store an implausible
+// one. Other writes, such as UpdateByID and Move, are not checked. An
+// update the tree rejects, say as out of bounds, is not shown to f, and one
+// f accepts only becomes its latest position for the ID once stored.
+func WithJumpFilter(f *JumpFilter) Option {
+	return func(qt *QuadTree) {
+		qt.jumps = f
+	}
+}
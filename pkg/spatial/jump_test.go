@@ -0,0 +1,184 @@
+package spatial
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestJumpFilterTeleport tests that a single bogus reading far away is
+// rejected and reported, and the driver's next real reading accepted
+func TestJumpFilterTeleport(t *testing.T) {
+	var reported []*JumpError
+	f, err := NewJumpFilter(JumpFilterConfig{MaxSpeed: 50, Geo: true, OnReject: func(e *JumpError) { reported = append(reported, e) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	// Berlin, moving east about 11m a second
+	for i := 0; i < 5; i++ {
+		if err := f.Check("d1", Point{X: 13.4 + float64(i)*0.00016, Y: 52.52}, at(i)); err != nil {
+			t.Fatalf("Expected plausible update %d accepted, got %v", i, err)
+		}
+	}
+	err = f.Check("d1", Point{X: 13.4, Y: 49.8}, at(5)) // 300km south
+	var jump *JumpError
+	if !errors.Is(err, ErrImplausibleJump) || !errors.As(err, &jump) {
+		t.Fatalf("Expected a JumpError, got %v", err)
+	}
+	if jump.ID != "d1" || jump.Speed < 250000 || jump.Pending != 1 || jump.Needed != 3 || jump.From.Y != 52.52 {
+		t.Errorf("Unexpected JumpError %+v", jump)
+	}
+	if err := f.Check("d1", Point{X: 13.4 + 6*0.00016, Y: 52.52}, at(6)); err != nil {
+		t.Errorf("Expected the driver's next real reading accepted, got %v", err)
+	}
+	// The bogus reading was discarded, so a second one starts counting again
+	if err := f.Check("d1", Point{X: 13.4, Y: 49.8}, at(7)); !errors.As(err, &jump) || jump.Pending != 1 {
+		t.Errorf("Expected a fresh rejection, got %v", err)
+	}
+	if f.Rejected() != 2 || len(reported) != 2 {
+		t.Errorf("Expected 2 rejections counted and reported, got %d and %d", f.Rejected(), len(reported))
+	}
+	// Simultaneous readings may still move within the tolerance
+	if err := f.Check("d2", Point{X: 0}, at(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Check("d2", Point{X: 0.0001}, at(0)); !errors.Is(err, ErrImplausibleJump) {
+		t.Errorf("Expected a move with no time elapsed rejected, got %v", err)
+	}
+	g, _ := NewJumpFilter(JumpFilterConfig{MaxSpeed: 50, Geo: true, Tolerance: 20})
+	g.Check("d2", Point{X: 0}, at(0))
+	if err := g.Check("d2", Point{X: 0.0001}, at(0)); err != nil {
+		t.Errorf("Expected an 11m move within the tolerance accepted, got %v", err)
+	}
+}
+
+// TestJumpFilterFerry tests that a jump followed by enough readings that
+// agree with it is accepted
+func TestJumpFilterFerry(t *testing.T) {
+	f, _ := NewJumpFilter(JumpFilterConfig{MaxSpeed: 30, Confirmations: 2})
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	f.Check("d1", Point{X: 0, Y: 0}, at(0))
+	// Off the ferry 10km away, having sent nothing on board for a minute
+	for i, want := range []bool{false, false, true, true} {
+		err := f.Check("d1", Point{X: 10000 + float64(i)*20, Y: 0}, at(60+i))
+		if (err == nil) != want {
+			t.Errorf("Reading %d after the crossing: expected accepted %v, got %v", i, want, err)
+		}
+	}
+	// A jump elsewhere between readings restarts the count
+	f.Check("d2", Point{X: 0, Y: 0}, at(0))
+	for i, p := range []Point{{X: 5000}, {X: 5010}, {X: -5000}, {X: -5010}} {
+		if err := f.Check("d2", p, at(1+i)); err == nil {
+			t.Errorf("Reading %d: expected rejected, split between two jumps", i)
+		}
+	}
+	if _, err := NewJumpFilter(JumpFilterConfig{}); !errors.Is(err, ErrInvalidJumpFilter) {
+		t.Errorf("Expected ErrInvalidJumpFilter without MaxSpeed, got %v", err)
+	}
+}
+
+// TestUpsertJumpFilter tests that Upsert consults the tree's filter, so a
+// teleported driver doesn't win KNearest where it never was
+func TestUpsertJumpFilter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	f, _ := NewJumpFilter(JumpFilterConfig{MaxSpeed: 50, Geo: true, TTL: time.Hour})
+	qt, err := NewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180},
+		WithGeoCoordinates(), WithJumpFilter(f), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := qt.Upsert("d1", Point{X: 13.4, Y: 52.52}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(5 * time.Second)
+	if _, err := qt.Upsert("d1", Point{X: 13.4, Y: 49.8}); !errors.Is(err, ErrImplausibleJump) {
+		t.Fatalf("Expected ErrImplausibleJump, got %v", err)
+	}
+	if p, _ := qt.GetByID("d1"); p.Y != 52.52 {
+		t.Errorf("Expected d1 left in Berlin, got %v", p)
+	}
+	if near := qt.KNearestGeo(GeoPoint{Lat: 49.8, Lon: 13.4}, 1); len(near) != 1 || near[0].Y != 52.52 {
+		t.Errorf("Expected d1 found only where it was, got %v", near)
+	}
+	now = now.Add(5 * time.Second)
+	if _, err := qt.Upsert("d1", Point{X: 13.4005, Y: 52.52}); err != nil {
+		t.Errorf("Expected a plausible move accepted, got %v", err)
+	}
+
+	// Idle IDs are forgotten from the filter
+	qt.Upsert("d2", Point{X: 0, Y: 0})
+	now = now.Add(2 * time.Hour)
+	qt.Upsert("d2", Point{X: 10, Y: 10})
+	if f.Len() != 1 {
+		t.Errorf("Expected d1 forgotten after the TTL, leaving 1, got %d", f.Len())
+	}
+}
+
+// TestJumpFilterInvalidPoint tests that a non-finite reading is refused
+// without becoming the position later readings are measured from
+func TestJumpFilterInvalidPoint(t *testing.T) {
+	f, _ := NewJumpFilter(JumpFilterConfig{MaxSpeed: 30})
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, p := range []Point{{X: math.NaN(), Y: 5}, {X: 5, Y: math.Inf(-1)}} {
+		if err := f.Check("d1", p, start); !errors.Is(err, ErrInvalidPoint) {
+			t.Errorf("%v: expected ErrInvalidPoint, got %v", p, err)
+		}
+	}
+	if f.Len() != 0 || f.Rejected() != 0 {
+		t.Fatalf("expected no state or rejections, got %d IDs and %d rejections", f.Len(), f.Rejected())
+	}
+	for i := 0; i < 3; i++ {
+		if err := f.Check("d1", Point{X: float64(i), Y: 5}, start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("reading %d: expected accepted, got %v", i, err)
+		}
+	}
+	if err := f.Check("d1", Point{X: math.NaN(), Y: 5}, start.Add(3*time.Second)); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("expected ErrInvalidPoint, got %v", err)
+	}
+	if err := f.Check("d1", Point{X: 3, Y: 5}, start.Add(4*time.Second)); err != nil {
+		t.Errorf("expected the next real reading accepted, got %v", err)
+	}
+}
+
+// TestUpsertJumpFilterRejectedReadings tests that readings the tree
+// refuses don't become the filter's last position for the ID
+func TestUpsertJumpFilterRejectedReadings(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	f, _ := NewJumpFilter(JumpFilterConfig{MaxSpeed: 30})
+	qt, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000},
+		WithJumpFilter(f), WithMaxPoints(1), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		p    Point
+		want error
+	}{
+		{Point{X: math.NaN(), Y: 5}, ErrInvalidPoint},
+		{Point{X: 5000, Y: 5}, ErrOutOfBounds},
+		{Point{X: 5, Y: 5}, nil},
+		{Point{X: math.NaN(), Y: 5}, ErrInvalidPoint},
+		{Point{X: -900, Y: 5}, ErrOutOfBounds}, // Implausible too, but never judged
+		{Point{X: 20, Y: 5}, nil},
+		{Point{X: 40, Y: 5}, nil},
+	} {
+		now = now.Add(time.Second)
+		if _, err := qt.Upsert("d", tc.p); !errors.Is(err, tc.want) {
+			t.Fatalf("%v: expected %v, got %v", tc.p, tc.want, err)
+		}
+	}
+	if f.Rejected() != 0 {
+		t.Errorf("expected no jumps, got %d", f.Rejected())
+	}
+	// Accepted by the filter as a first reading, but the tree is full
+	if _, err := qt.Upsert("e", Point{X: 500, Y: 500}); !errors.Is(err, ErrTreeFull) {
+		t.Fatalf("expected ErrTreeFull, got %v", err)
+	}
+	if f.Len() != 1 {
+		t.Errorf("expected only d known to the filter, got %d IDs", f.Len())
+	}
+}
@@ -0,0 +1,480 @@
+package spatial
+
+import (
+	"math"
+	"sync"
+)
+
+// kdLeaf is the size of range below which a KDTree stops splitting and
+// scans its points
+const kdLeaf = 8
+
+// KDTree is a balanced 2-d tree over a fixed set of points, for indexes
+// built once and queried many times, such as the road points route
+// snapping matches against. Each level splits its points at the median of
+// whichever axis they spread further along, so unlike a QuadTree its depth
+// is log n however the points cluster. Distances are planar. It is safe for
+// concurrent use.
+//
+// It has no dynamic updates. By default Insert, Remove and Update report
+// false; WithRebuildOnMutation has them change the point set and leave the
+// tree to be rebuilt, in O(n log n), by the next query.
+type KDTree struct {
+	rebuild bool
+
+	mu      sync.RWMutex // Guards everything below
+	records []Point      // Insertion order; the source of truth
+	nextSeq uint64
+	stale   bool // records changed since the tree was built
+
+	// The tree is implicit in its order: a range's splitting point sits at
+	// its middle, axis[mid] says which coordinate it splits, and ranges of
+	// kdLeaf points or fewer are not split
+	order  []int32 // Indices into records, in tree order
+	xs, ys []float64
+	axis   []uint8
+}
+
+var _ SpatialIndex = (*KDTree)(nil)
+
+// KDOption configures BuildKDTree
+type KDOption func(*KDTree)
+
+// WithRebuildOnMutation lets Insert, Remove and Update change a KDTree.
+// Each marks it stale and the next query rebuilds it, so a burst of writes
+// costs one rebuild; interleaving writes with queries rebuilds every time.
+func WithRebuildOnMutation() KDOption {
+	return func(t *KDTree) {
+		t.rebuild = true
+	}
+}
+
+// BuildKDTree builds a tree over points, ranking equally distant points in
+// their order here. Points with NaN or infinite coordinates are left out and
+// reported in a *RejectedPointsError alongside the tree holding the rest.
+func BuildKDTree(points []Point, opts ...KDOption) (*KDTree, error) {
+	t := &KDTree{records: make([]Point, 0, len(points))}
+	for _, opt := range opts {
+		opt(t)
+	}
+	var rejected []Point
+	for _, p := range points {
+		if !validCoordinates(p) {
+			rejected = append(rejected, p)
+			continue
+		}
+		t.add(p)
+	}
+	t.build()
+	if len(rejected) > 0 {
+		return t, &RejectedPointsError{Points: rejected}
+	}
+	return t, nil
+}
+
+// add appends p as a new record. Callers must hold the write lock or own t.
+func (t *KDTree) add(p Point) {
+	t.nextSeq++
+	p.seq = t.nextSeq
+	p.loc = nil
+	p.expires = 0
+	t.records = append(t.records, p)
+}
+
+// build lays the tree out over records. Callers must hold the write lock or
+// own t.
+func (t *KDTree) build() {
+	n := len(t.records)
+	t.order = make([]int32, n)
+	for i := range t.order {
+		t.order[i] = int32(i)
+	}
+	t.axis = make([]uint8, n)
+	t.split(0, n)
+	t.xs = make([]float64, n)
+	t.ys = make([]float64, n)
+	for i, r := range t.order {
+		t.xs[i], t.ys[i] = t.records[r].X, t.records[r].Y
+	}
+	t.stale = false
+}
+
+// split puts the median of order[lo:hi] along its wider axis at the middle,
+// with points before it on that axis to its left and after it to its right,
+// then splits both halves
+func (t *KDTree) split(lo, hi int) {
+	if hi-lo <= kdLeaf {
+		return
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, r := range t.order[lo:hi] {
+		p := t.records[r]
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	var axis uint8
+	if maxY-minY > maxX-minX {
+		axis = 1
+	}
+	mid := (lo + hi) / 2
+	t.selectNth(t.order[lo:hi], mid-lo, axis)
+	t.axis[mid] = axis
+	t.split(lo, mid)
+	t.split(mid+1, hi)
+}
+
+// before orders records by one coordinate, then by insertion order, so
+// that every record has a distinct place
+func (t *KDTree) before(a, b int32, axis uint8) bool {
+	pa, pb := t.records[a], t.records[b]
+	ca, cb := pa.X, pb.X
+	if axis == 1 {
+		ca, cb = pa.Y, pb.Y
+	}
+	return ca < cb || (ca == cb && pa.seq < pb.seq)
+}
+
+// selectNth reorders order so that order[n] is where sorting would put it,
+// with nothing after it before it and nothing before it after it
+func (t *KDTree) selectNth(order []int32, n int, axis uint8) {
+	lo, hi := 0, len(order)-1
+	for lo < hi {
+		// Median of three, moved to hi as the pivot
+		mid := (lo + hi) / 2
+		if t.before(order[mid], order[lo], axis) {
+			order[mid], order[lo] = order[lo], order[mid]
+		}
+		if t.before(order[hi], order[lo], axis) {
+			order[hi], order[lo] = order[lo], order[hi]
+		}
+		if t.before(order[mid], order[hi], axis) {
+			order[mid], order[hi] = order[hi], order[mid]
+		}
+		pivot := order[hi]
+		store := lo
+		for i := lo; i < hi; i++ {
+			if t.before(order[i], pivot, axis) {
+				order[i], order[store] = order[store], order[i]
+				store++
+			}
+		}
+		order[store], order[hi] = order[hi], order[store]
+		switch {
+		case n < store:
+			hi = store - 1
+		case n > store:
+			lo = store + 1
+		default:
+			return
+		}
+	}
+}
+
+// rlock takes the read lock on a tree that is up to date, rebuilding it
+// first if it is stale
+func (t *KDTree) rlock() {
+	t.mu.RLock()
+	for t.stale {
+		t.mu.RUnlock()
+		t.mu.Lock()
+		if t.stale {
+			t.build()
+		}
+		t.mu.Unlock()
+		t.mu.RLock()
+	}
+}
+
+// coord returns the coordinate the tree at i splits on
+func (t *KDTree) coord(i int, axis uint8) float64 {
+	if axis == 1 {
+		return t.ys[i]
+	}
+	return t.xs[i]
+}
+
+// visit calls fn with the tree position of every point in [lo, hi) that
+// may lie inside the box from min to max, pruning ranges that can't.
+func (t *KDTree) visit(lo, hi int, minX, minY, maxX, maxY float64, fn func(i int)) {
+	for hi-lo > kdLeaf {
+		mid := (lo + hi) / 2
+		axis := t.axis[mid]
+		c := t.coord(mid, axis)
+		lower, upper := minX, maxX
+		if axis == 1 {
+			lower, upper = minY, maxY
+		}
+		goLeft, goRight := lower <= c, upper >= c
+		if goLeft && goRight {
+			fn(mid)
+			t.visit(lo, mid, minX, minY, maxX, maxY, fn)
+			lo = mid + 1
+		} else if goLeft {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	for i := lo; i < hi; i++ {
+		fn(i)
+	}
+}
+
+// Search returns every point inside area, edges included
+func (t *KDTree) Search(area Bounds) []Point {
+	results := make([]Point, 0)
+	if area.Validate() != nil {
+		return results
+	}
+	t.rlock()
+	defer t.mu.RUnlock()
+	t.visit(0, len(t.order), area.X, area.Y, area.X+area.Width, area.Y+area.Height, func(i int) {
+		if area.containsXY(t.xs[i], t.ys[i], InclusiveEdges) {
+			results = append(results, t.records[t.order[i]])
+		}
+	})
+	return results
+}
+
+// SearchRadius returns every point within radius of center
+func (t *KDTree) SearchRadius(center Point, radius float64) []Point {
+	results := make([]Point, 0)
+	if !(radius >= 0) || !validCoordinates(center) {
+		return results
+	}
+	r2 := radius * radius
+	t.rlock()
+	defer t.mu.RUnlock()
+	t.visit(0, len(t.order), center.X-radius, center.Y-radius, center.X+radius, center.Y+radius, func(i int) {
+		dx, dy := t.xs[i]-center.X, t.ys[i]-center.Y
+		if withinSquared(dx*dx+dy*dy, radius, r2) {
+			results = append(results, t.records[t.order[i]])
+		}
+	})
+	return results
+}
+
+// KNearest returns the k points closest to target, nearest first, with ties
+// ranked by insertion order
+func (t *KDTree) KNearest(target Point, k int) []Point {
+	if k <= 0 || !validCoordinates(target) {
+		return make([]Point, 0)
+	}
+	t.rlock()
+	defer t.mu.RUnlock()
+	h := kdHeap{k: min(k, len(t.order))}
+	h.items = make([]kdItem, 0, h.k)
+	t.nearest(0, len(t.order), target, &h)
+	results := make([]Point, len(h.items))
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = t.records[h.pop().r]
+	}
+	return results
+}
+
+// Nearest returns the point closest to target, ranked like KNearest, and
+// false if the tree is empty
+func (t *KDTree) Nearest(target Point) (Point, bool) {
+	found := t.KNearest(target, 1)
+	if len(found) == 0 {
+		return Point{}, false
+	}
+	return found[0], true
+}
+
+// nearest offers the points of [lo, hi) to h, descending into the side of
+// each split target is on first, and into the other only if a point there
+// could still rank among the k nearest. Callers must hold the read lock.
+func (t *KDTree) nearest(lo, hi int, target Point, h *kdHeap) {
+	for hi-lo > kdLeaf {
+		mid := (lo + hi) / 2
+		diff := target.X - t.xs[mid]
+		if t.axis[mid] == 1 {
+			diff = target.Y - t.ys[mid]
+		}
+		nearLo, nearHi, farLo, farHi := lo, mid, mid+1, hi
+		if diff > 0 {
+			nearLo, nearHi, farLo, farHi = mid+1, hi, lo, mid
+		}
+		t.nearest(nearLo, nearHi, target, h)
+		t.offer(mid, target, h)
+		// Ties are kept, so equally distant points still rank by insertion
+		// order
+		if h.full() && diff*diff > h.items[0].d {
+			return
+		}
+		lo, hi = farLo, farHi
+	}
+	for i := lo; i < hi; i++ {
+		t.offer(i, target, h)
+	}
+}
+
+func (t *KDTree) offer(i int, target Point, h *kdHeap) {
+	dx, dy := t.xs[i]-target.X, t.ys[i]-target.Y
+	h.offer(kdItem{d: dx*dx + dy*dy, r: t.order[i]})
+}
+
+// kdItem is a record at squared distance d. Records are in insertion
+// order, so r ranks ties as seq would.
+type kdItem struct {
+	d float64
+	r int32
+}
+
+// kdHeap keeps the k nearest items seen, farthest at the root. It is
+// nearestHeap without the interface calls or the copying of whole Points,
+// which cost more than the search itself in a tree this shallow.
+type kdHeap struct {
+	items []kdItem
+	k     int
+}
+
+func (a kdItem) after(b kdItem) bool {
+	return a.d > b.d || (a.d == b.d && a.r > b.r)
+}
+
+func (h *kdHeap) full() bool {
+	return len(h.items) == h.k
+}
+
+func (h *kdHeap) offer(it kdItem) {
+	if !h.full() {
+		h.items = append(h.items, it)
+		for i := len(h.items) - 1; i > 0; {
+			parent := (i - 1) / 2
+			if !h.items[i].after(h.items[parent]) {
+				break
+			}
+			h.items[i], h.items[parent] = h.items[parent], h.items[i]
+			i = parent
+		}
+		return
+	}
+	if h.k == 0 || !h.items[0].after(it) {
+		return
+	}
+	h.items[0] = it
+	h.down()
+}
+
+// pop removes and returns the farthest item
+func (h *kdHeap) pop() kdItem {
+	top := h.items[0]
+	last := len(h.items) - 1
+	h.items[0] = h.items[last]
+	h.items = h.items[:last]
+	h.down()
+	return top
+}
+
+// down restores the heap from the root
+func (h *kdHeap) down() {
+	n := len(h.items)
+	for i := 0; ; {
+		largest, l, r := i, 2*i+1, 2*i+2
+		if l < n && h.items[l].after(h.items[largest]) {
+			largest = l
+		}
+		if r < n && h.items[r].after(h.items[largest]) {
+			largest = r
+		}
+		if largest == i {
+			return
+		}
+		h.items[i], h.items[largest] = h.items[largest], h.items[i]
+		i = largest
+	}
+}
+
+// Count returns how many points the tree holds
+func (t *KDTree) Count() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.records)
+}
+
+// ForEach calls fn for every point, in insertion order, until fn returns
+// false. It holds a read lock throughout, so fn must not modify the tree.
+func (t *KDTree) ForEach(fn func(Point) bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, p := range t.records {
+		if !fn(p) {
+			return
+		}
+	}
+}
+
+// Insert adds p to the point set under WithRebuildOnMutation, reporting
+// false without it or if p's coordinates are NaN or infinite
+func (t *KDTree) Insert(p Point) bool {
+	if !t.rebuild || !validCoordinates(p) {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(p)
+	t.stale = true
+	return true
+}
+
+// find returns the index in records of the record p refers to, matched as
+// QuadTree matches it, or -1. Callers must hold the lock.
+func (t *KDTree) find(p Point) int {
+	match := -1
+	for i, r := range t.records {
+		if r.X != p.X || r.Y != p.Y {
+			continue
+		}
+		if p.seq != 0 {
+			if r.seq == p.seq {
+				return i
+			}
+			continue
+		}
+		if match == -1 || r.seq < t.records[match].seq {
+			match = i
+		}
+	}
+	return match
+}
+
+// Remove deletes the record p refers to under WithRebuildOnMutation,
+// reporting whether it was found
+func (t *KDTree) Remove(p Point) bool {
+	if !t.rebuild {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := t.find(p)
+	if i == -1 {
+		return false
+	}
+	t.records = append(t.records[:i], t.records[i+1:]...)
+	t.stale = true
+	return true
+}
+
+// Update moves the record oldPoint refers to onto newPoint under
+// WithRebuildOnMutation. It reports false, changing nothing, without it,
+// if no record matches, or if newPoint's coordinates are NaN or infinite.
+func (t *KDTree) Update(oldPoint, newPoint Point) bool {
+	if !t.rebuild || !validCoordinates(newPoint) {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := t.find(oldPoint)
+	if i == -1 {
+		return false
+	}
+	newPoint.seq = t.records[i].seq
+	newPoint.loc = nil
+	newPoint.expires = 0
+	t.records[i] = newPoint
+	t.stale = true
+	return true
+}
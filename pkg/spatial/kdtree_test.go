@@ -0,0 +1,104 @@
+package spatial_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/spatialtest"
+)
+
+func TestKDTreeReadConformance(t *testing.T) {
+	spatialtest.ReadConformance(t, func(points []spatial.Point) spatial.SpatialReader {
+		kd, err := spatial.BuildKDTree(points)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kd
+	})
+}
+
+// TestFrozenTreeReadConformance holds FrozenTree to the same queries, as
+// the baseline a KDTree replaces
+func TestFrozenTreeReadConformance(t *testing.T) {
+	spatialtest.ReadConformance(t, func(points []spatial.Point) spatial.SpatialReader {
+		qt, err := spatial.BuildQuadTree(spatialtest.ConformanceBounds, 8, points)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return qt.Freeze()
+	})
+}
+
+func TestKDTreeRebuildConformance(t *testing.T) {
+	spatialtest.Conformance(t, func(spatial.Bounds) spatial.SpatialIndex {
+		kd, _ := spatial.BuildKDTree(nil, spatial.WithRebuildOnMutation())
+		return kd
+	})
+}
+
+func TestKDTreeReadOnly(t *testing.T) {
+	kd, err := spatial.BuildKDTree([]spatial.Point{{X: 1, Y: 1}, {X: math.NaN(), Y: 2}, {X: 3, Y: 3}})
+	var rejected *spatial.RejectedPointsError
+	if !errors.As(err, &rejected) || len(rejected.Points) != 1 || !errors.Is(err, spatial.ErrInvalidPoint) {
+		t.Fatalf("expected the NaN point rejected, got %v", err)
+	}
+	if kd.Count() != 2 {
+		t.Fatalf("expected 2 points, got %d", kd.Count())
+	}
+	if kd.Insert(spatial.Point{X: 2, Y: 2}) || kd.Remove(spatial.Point{X: 1, Y: 1}) || kd.Update(spatial.Point{X: 1, Y: 1}, spatial.Point{X: 2, Y: 2}) {
+		t.Error("expected writes to fail without WithRebuildOnMutation")
+	}
+	if p, ok := kd.Nearest(spatial.Point{X: 2.9, Y: 2.9}); !ok || p.X != 3 {
+		t.Errorf("expected 3, 3, got %v", p)
+	}
+}
+
+// TestKDTreeRebuild tests that queries see writes made since the last one
+func TestKDTreeRebuild(t *testing.T) {
+	kd, _ := spatial.BuildKDTree(spatialtest.Uniform(1, spatialtest.ConformanceBounds, 1000), spatial.WithRebuildOnMutation())
+	target := spatial.Point{X: 1234, Y: 1234}
+	for i := 0; i < 5; i++ {
+		kd.Insert(spatial.Point{X: 1234 + float64(i), Y: 1234, Data: i})
+	}
+	found := kd.KNearest(target, 2)
+	if len(found) != 2 || found[0].Data != 0 || found[1].Data != 1 {
+		t.Fatalf("expected the inserted points, got %v", found)
+	}
+	if !kd.Remove(found[0]) || !kd.Update(found[1], spatial.Point{X: 0, Y: 0, Data: 1}) {
+		t.Fatal("expected the writes to succeed")
+	}
+	if p, _ := kd.Nearest(target); p.Data != 2 {
+		t.Errorf("expected 2 nearest after the writes, got %v", p)
+	}
+}
+
+// The static benchmarks query each structure built over the same points,
+// centred on stored points as the distribution benchmarks are
+func BenchmarkStaticKNearest(b *testing.B) {
+	for _, k := range []int{1, 10} {
+		b.Run(fmt.Sprintf("k%d", k), func(b *testing.B) {
+			forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+				kd, _ := spatial.BuildKDTree(points)
+				qt := newDistTree(b, points)
+				for name, ix := range map[string]spatial.SpatialReader{"KDTree": kd, "QuadTree": qt, "FrozenTree": qt.Freeze()} {
+					b.Run(name, func(b *testing.B) {
+						for i := 0; i < b.N; i++ {
+							ix.KNearest(points[(i*7919)%len(points)], k)
+						}
+					})
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkBuildKDTree(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		for i := 0; i < b.N; i++ {
+			spatial.BuildKDTree(points)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package spatial
+
+// Every change to a leaf's Points goes through the helpers below, so leaves
+// under WithArena take their storage from the arena and new leaves reserve
+// room up front.
+
+// maxReserve bounds the room a new leaf reserves up front, so huge
+// capacities grow by append instead
+const maxReserve = 64
+
+// addPoint appends p to the leaf
+func (n *Node) addPoint(p Point) {
+	if cap(n.Points) == 0 {
+		n.reserve(max(1, min(n.Capacity, maxReserve)))
+	} else if n.arena != nil && len(n.Points) == cap(n.Points) {
+		// Move up a size class rather than let append allocate on the heap
+		n.grow(2 * len(n.Points))
+	}
+	n.Points = append(n.Points, p)
+}
+
+// reserve gives an empty leaf room for c points, taken from the arena if
+// it has one
+func (n *Node) reserve(c int) {
+	if cap(n.Points) >= c {
+		return
+	}
+	if n.arena != nil {
+		n.useArray(n.arena.alloc(c))
+		return
+	}
+	n.Points = make([]Point, 0, c)
+}
+
+// setPoint replaces the leaf's i'th point
+func (n *Node) setPoint(i int, p Point) {
+	n.Points[i] = p
+}
+
+// deletePoint removes the leaf's i'th point by moving the last one into its
+// place, as order doesn't matter
+func (n *Node) deletePoint(i int) {
+	last := len(n.Points) - 1
+	n.Points[i] = n.Points[last]
+	n.Points[last] = Point{}
+	n.Points = n.Points[:last]
+}
+
+// setPoints makes points the leaf's points
+func (n *Node) setPoints(points []Point) {
+	n.Points = points
+}
+
+// resetPoints empties the leaf, keeping its backing array but dropping the
+// references held by the old points
+func (n *Node) resetPoints() {
+	clear(n.Points)
+	n.Points = n.Points[:0]
+}
@@ -0,0 +1,169 @@
+package spatial
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Length is a distance on the ground, stored in meters. Building one with
+// Meters or Kilometers keeps the unit next to the number, and the tree
+// converts it to its own coordinates. It is called Length because Distance
+// measures between points.
+type Length float64
+
+// Meters returns a Length of v meters
+func Meters(v float64) Length {
+	return Length(v)
+}
+
+// Kilometers returns a Length of v kilometers
+func Kilometers(v float64) Length {
+	return Length(v * 1000)
+}
+
+// Miles returns a Length of v international miles
+func Miles(v float64) Length {
+	return Length(v * metersPerMile)
+}
+
+const metersPerMile = 1609.344
+
+// Meters returns l in meters
+func (l Length) Meters() float64 {
+	return float64(l)
+}
+
+// Kilometers returns l in kilometers
+func (l Length) Kilometers() float64 {
+	return float64(l) / 1000
+}
+
+// String formats l in meters below a kilometer and kilometers from there,
+// such as "500m" or "3.2km", which ParseLength reads back
+func (l Length) String() string {
+	if math.Abs(float64(l)) < 1000 {
+		return strconv.FormatFloat(float64(l), 'f', -1, 64) + "m"
+	}
+	return strconv.FormatFloat(l.Kilometers(), 'f', -1, 64) + "km"
+}
+
+// ParseLength reads a length written as a number and a unit, "m", "km" or
+// "mi" in any case, such as "500m", "3.2km" or "2 mi". A unit is required.
+// It returns an error wrapping ErrInvalidLength for anything else, or for a
+// negative, NaN or infinite length.
+func ParseLength(s string) (Length, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	i := strings.LastIndexFunc(s, func(r rune) bool { return r < 'a' || r > 'z' }) + 1
+	number, unit := strings.TrimSpace(s[:i]), s[i:]
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil || !finite(v) || v < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLength, s)
+	}
+	switch unit {
+	case "m":
+		return Meters(v), nil
+	case "km":
+		return Kilometers(v), nil
+	case "mi":
+		return Miles(v), nil
+	}
+	return 0, fmt.Errorf("%w: %q has no unit of m, km or mi", ErrInvalidLength, s)
+}
+
+// MarshalText formats l as String does, for JSON and other text encodings
+func (l Length) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText reads a length as ParseLength does
+func (l *Length) UnmarshalText(text []byte) error {
+	v, err := ParseLength(string(text))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// WithUnit sets the length one unit of X and Y stands for in a planar tree,
+// which SearchWithin and KNearestWithin convert lengths by. It is a meter if
+// unset. Trees built WithGeoCoordinates or WithProjection already know
+// their scale and ignore it.
+func WithUnit(l Length) Option {
+	return func(qt *QuadTree) {
+		qt.unit = l
+	}
+}
+
+// radius converts l, measured around center, into the units the tree's
+// metric measures in: meters for Haversine, projected units at center's
+// scale under WithProjection, degrees of latitude for any other metric on
+// geographic coordinates, and WithUnit's units otherwise
+func (qt *QuadTree) radius(center Point, l Length) float64 {
+	m := qt.distanceMetric()
+	switch {
+	case m == Haversine{}:
+		return l.Meters()
+	case qt.proj != nil:
+		// The projected length of a short step north of center
+		const step = 1.0
+		g := qt.proj.Unproject(center)
+		g.Lat = math.Min(g.Lat+step/EarthRadiusMeters*180/math.Pi, 90)
+		return l.Meters() * Distance(center, qt.proj.Project(g)) / step
+	case qt.geo:
+		return l.Meters() / EarthRadiusMeters * 180 / math.Pi
+	case qt.unit > 0:
+		return l.Meters() / qt.unit.Meters()
+	}
+	return l.Meters()
+}
+
+// SearchWithin returns every point within l of center under the tree's
+// metric, converting l to the tree's units as radius describes. Under a
+// projection the scale is taken at center, exact for the conformal
+// WebMercator close to it and approximate further away.
+func (qt *QuadTree) SearchWithin(center Point, l Length) []Point {
+	if view := qt.view(); view != nil {
+		return view.searchRadius(center, view.radius(center, l))
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.searchRadius(center, qt.radius(center, l))
+}
+
+// KNearestWithin returns up to k points nearest target, nearest first with
+// ties in insertion order as KNearest ranks them, leaving out any further
+// than l. l is converted as SearchWithin converts it.
+func (qt *QuadTree) KNearestWithin(target Point, k int, l Length) []Point {
+	if k <= 0 {
+		return make([]Point, 0)
+	}
+	if view := qt.view(); view != nil {
+		return view.kNearestWithin(target, k, l)
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.kNearestWithin(target, k, l)
+}
+
+// kNearestWithin is KNearestWithin for callers that hold the lock
+func (qt *QuadTree) kNearestWithin(target Point, k int, l Length) []Point {
+	m := qt.distanceMetric()
+	found := qt.searchRadius(target, qt.radius(target, l))
+	ranked := make([]PointWithDistance, len(found))
+	for i, p := range found {
+		ranked[i] = PointWithDistance{Point: p, Distance: m.Distance(target, p)}
+	}
+	slices.SortFunc(ranked, func(a, b PointWithDistance) int {
+		return cmp.Or(cmp.Compare(a.Distance, b.Distance), cmp.Compare(a.Point.seq, b.Point.seq))
+	})
+	results := make([]Point, min(k, len(ranked)))
+	for i := range results {
+		results[i] = ranked[i].Point
+	}
+	return results
+}
@@ -0,0 +1,102 @@
+package spatial
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// TestLengthParseAndFormat tests units, formatting, parsing and JSON
+func TestLengthParseAndFormat(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Length
+		out  string
+	}{
+		{"500m", Meters(500), "500m"},
+		{"3.2km", Kilometers(3.2), "3.2km"},
+		{" 3.2 KM ", Kilometers(3.2), "3.2km"},
+		{"0.5km", Meters(500), "500m"},
+		{"2mi", Meters(3218.688), "3.218688km"},
+		{"1e3m", Kilometers(1), "1km"},
+		{"0m", 0, "0m"},
+	} {
+		got, err := ParseLength(tc.in)
+		if err != nil || math.Abs(got.Meters()-tc.want.Meters()) > 1e-9 {
+			t.Errorf("ParseLength(%q): expected %v, got %v, %v", tc.in, tc.want, got, err)
+			continue
+		}
+		if got.String() != tc.out {
+			t.Errorf("%q: expected %s, got %s", tc.in, tc.out, got)
+		}
+	}
+	for _, in := range []string{"", "500", "km", "5 furlongs", "-3km", "NaNm", "infkm", "3..2km"} {
+		if _, err := ParseLength(in); !errors.Is(err, ErrInvalidLength) {
+			t.Errorf("ParseLength(%q): expected ErrInvalidLength, got %v", in, err)
+		}
+	}
+
+	var cfg struct {
+		Radius Length `json:"radius"`
+	}
+	if err := json.Unmarshal([]byte(`{"radius": "1.5km"}`), &cfg); err != nil || cfg.Radius != Kilometers(1.5) {
+		t.Errorf("Expected 1.5km from JSON, got %v, %v", cfg.Radius, err)
+	}
+	if b, _ := json.Marshal(cfg); string(b) != `{"radius":"1.5km"}` {
+		t.Errorf("Expected the length written as text, got %s", b)
+	}
+	if err := json.Unmarshal([]byte(`{"radius": "1.5"}`), &cfg); !errors.Is(err, ErrInvalidLength) {
+		t.Errorf("Expected a bare number rejected, got %v", err)
+	}
+}
+
+// TestSearchWithinUnits tests that lengths are converted to each kind of
+// tree's coordinates
+func TestSearchWithinUnits(t *testing.T) {
+	data := func(points []Point) []any {
+		var out []any
+		for _, p := range points {
+			out = append(out, p.Data)
+		}
+		slices.SortFunc(out, func(a, b any) int { return a.(int) - b.(int) })
+		return out
+	}
+
+	// Geographic: meters along the ground
+	geo := newGeoTree()
+	for i := 0; i < 10; i++ {
+		geo.Insert(Point{X: 10 + float64(i)*0.01, Y: 60, Data: i}) // ~556m apart at 60N
+	}
+	if got := data(geo.SearchWithin(Point{X: 10, Y: 60}, Kilometers(2))); !slices.Equal(got, []any{0, 1, 2, 3}) {
+		t.Errorf("Geo: expected points 0 to 3 within 2km, got %v", got)
+	}
+
+	// Planar in kilometers
+	planar := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithUnit(Kilometers(1)))
+	for i := 0; i < 10; i++ {
+		planar.Insert(Point{X: float64(i), Y: 0, Data: i})
+	}
+	if got := data(planar.SearchWithin(Point{}, Meters(2500))); !slices.Equal(got, []any{0, 1, 2}) {
+		t.Errorf("Planar km: expected points 0 to 2 within 2500m, got %v", got)
+	}
+	if got := planar.KNearestWithin(Point{X: 5}, 3, Kilometers(10)); len(got) != 3 || got[0].Data != 5 || got[1].Data != 4 || got[2].Data != 6 {
+		t.Errorf("Expected 5, 4, 6 nearest, got %v", got)
+	}
+	if got := planar.KNearestWithin(Point{X: 5}, 5, Meters(1500)); len(got) != 3 {
+		t.Errorf("Expected only 3 points within 1.5km, got %v", got)
+	}
+
+	// Web Mercator stretches distances by 1/cos(lat), twice at 60N
+	merc := mustNewQuadTree(Bounds{X: -2.1e7, Y: -2.1e7, Width: 4.2e7, Height: 4.2e7}, WithProjection(WebMercator{}))
+	center := GeoPoint{Lat: 60, Lon: 10}
+	for i := 0; i < 10; i++ {
+		p := GeoPoint{Lat: 60, Lon: 10 + float64(i)*0.01}.ToPoint(WebMercator{})
+		p.Data = i
+		merc.Insert(p)
+	}
+	if got := data(merc.SearchWithin(center.ToPoint(WebMercator{}), Kilometers(2))); !slices.Equal(got, []any{0, 1, 2, 3}) {
+		t.Errorf("Mercator: expected points 0 to 3 within 2km, got %v", got)
+	}
+}
@@ -0,0 +1,59 @@
+package spatial
+
+import "math"
+
+// LocalProjection is a flat frame in meters east and north of an origin,
+// such as the center of the city a tree serves. It is the Equirectangular
+// projection, so it can be given to WithProjection to keep a tree's math
+// planar while SearchGeo, SearchRadiusGeo, KNearestGeo and UpsertGeo take
+// latitude and longitude.
+//
+// Distances on the plane between points within d meters of the origin are
+// within a relative ErrorBound(d) of their great-circle distance: about
+// tan|lat|·d/R, R being EarthRadiusMeters, because east-west scale is taken
+// at the origin's latitude. That is 0.5% across a 50 km city at 52°N
+// (d = 25 km), 1% across 100 km, no more than 0.03% across 100 km on the
+// equator, and it grows quickly towards the poles.
+type LocalProjection struct {
+	Equirectangular
+}
+
+// NewLocalProjection returns the local frame centered on origin, which must
+// not be a pole
+func NewLocalProjection(origin GeoPoint) LocalProjection {
+	return LocalProjection{Equirectangular{Origin: origin}}
+}
+
+// ToLocal returns g in meters east and north of the origin
+func (l LocalProjection) ToLocal(g GeoPoint) Point {
+	return l.Project(g)
+}
+
+// ToGeo returns the position p meters east and north of the origin
+func (l LocalProjection) ToGeo(p Point) GeoPoint {
+	return l.Unproject(p)
+}
+
+// ErrorBound returns the largest relative error in a planar distance
+// between points within d meters of the origin, compared with the
+// great-circle distance: tan|lat|·d/R + (d/R)²
+func (l LocalProjection) ErrorBound(d float64) float64 {
+	r := d / EarthRadiusMeters
+	return math.Abs(math.Tan(l.Origin.Lat*math.Pi/180))*r + r*r
+}
+
+// UpsertGeo is Upsert for a position given in latitude and longitude,
+// projected through the tree's WithProjection, or LonLat without one
+func (qt *QuadTree) UpsertGeo(id string, g GeoPoint) (created bool, err error) {
+	return qt.Upsert(id, g.ToPoint(qt.proj))
+}
+
+// GetByIDGeo is GetByID returning the point's position in latitude and
+// longitude, unprojected through the tree's WithProjection
+func (qt *QuadTree) GetByIDGeo(id string) (GeoPoint, bool) {
+	p, ok := qt.GetByID(id)
+	if !ok {
+		return GeoPoint{}, false
+	}
+	return FromPoint(p, qt.proj), true
+}
@@ -0,0 +1,114 @@
+package spatial
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// randomNear returns a position up to d meters from origin, uniform over
+// the disc
+func randomNear(rng *rand.Rand, origin GeoPoint, d float64) GeoPoint {
+	return Destination(origin, rng.Float64()*360, d*math.Sqrt(rng.Float64()))
+}
+
+// TestLocalProjectionAccuracy tests round trips and planar distances
+// against great-circle ones across a 100 km extent at several latitudes
+func TestLocalProjectionAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const extent = 50000 // From the origin, so 100 km across
+	for _, origin := range []GeoPoint{
+		{Lat: 0.3, Lon: 32.6},     // Kampala
+		{Lat: 40.42, Lon: -3.7},   // Madrid
+		{Lat: 52.52, Lon: 13.405}, // Berlin
+		{Lat: 59.91, Lon: 10.75},  // Oslo
+		{Lat: -17.7, Lon: 179.9},  // Fiji, across the antimeridian
+	} {
+		l := NewLocalProjection(origin)
+		if p := l.ToLocal(origin); p.X != 0 || p.Y != 0 {
+			t.Errorf("%v: expected the origin at 0, 0, got %v", origin, p)
+		}
+		var worst float64
+		for i := 0; i < 5000; i++ {
+			a, b := randomNear(rng, origin, extent), randomNear(rng, origin, extent)
+			back := l.ToGeo(l.ToLocal(a))
+			if math.Abs(back.Lat-a.Lat) > 1e-9 || math.Abs(math.Remainder(back.Lon-a.Lon, 360)) > 1e-9 {
+				t.Fatalf("%v: %v came back as %v", origin, a, back)
+			}
+			h := HaversineDistance(a, b)
+			if h < 1 {
+				continue
+			}
+			worst = math.Max(worst, math.Abs(Distance(l.ToLocal(a), l.ToLocal(b))-h)/h)
+		}
+		bound := l.ErrorBound(extent)
+		t.Logf("%v: worst relative error %.4f%%, bound %.4f%%", origin, worst*100, bound*100)
+		if worst > bound {
+			t.Errorf("%v: expected distance errors within %v, got %v", origin, bound, worst)
+		}
+	}
+	if b := NewLocalProjection(GeoPoint{Lat: 52}).ErrorBound(25000); b < 0.004 || b > 0.006 {
+		t.Errorf("Expected about 0.5%% across a 50 km city at 52°N, got %v", b)
+	}
+}
+
+// TestLocalProjectionTree tests a tree kept in meters around Berlin and
+// queried in latitude and longitude, against great-circle brute force
+func TestLocalProjectionTree(t *testing.T) {
+	origin := GeoPoint{Lat: 52.52, Lon: 13.405}
+	l := NewLocalProjection(origin)
+	qt, err := NewQuadTree(Bounds{X: -60000, Y: -60000, Width: 120000, Height: 120000}, WithProjection(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(2))
+	drivers := make(map[string]GeoPoint)
+	for i := 0; i < 2000; i++ {
+		id := string(rune('a'+i%26)) + string(rune('A'+i/26%26)) + string(rune('0'+i/676))
+		drivers[id] = randomNear(rng, origin, 50000)
+		if _, err := qt.UpsertGeo(id, drivers[id]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g, ok := qt.GetByIDGeo("aA0"); !ok || math.Abs(g.Lat-drivers["aA0"].Lat) > 1e-9 {
+		t.Errorf("Expected aA0 back at %v, got %v", drivers["aA0"], g)
+	}
+	p, _ := qt.GetByID("aA0")
+	if want := l.ToLocal(drivers["aA0"]); p.X != want.X || p.Y != want.Y {
+		t.Errorf("Expected aA0 stored in meters, got %v", p)
+	}
+
+	bound := l.ErrorBound(50000)
+	for q := 0; q < 20; q++ {
+		center, meters := randomNear(rng, origin, 40000), 1000+rng.Float64()*5000
+		found := make(map[string]bool)
+		for _, p := range qt.SearchRadiusGeo(center, meters) {
+			id, _ := p.ID()
+			found[id] = true
+		}
+		for id, g := range drivers {
+			d := HaversineDistance(center, g)
+			// Points this close to the edge may fall either side of it
+			if math.Abs(d-meters) <= 2*bound*meters {
+				continue
+			}
+			if found[id] != (d < meters) {
+				t.Fatalf("SearchRadiusGeo(%v, %.0f): %s at %.1fm found %v", center, meters, id, d, found[id])
+			}
+		}
+
+		nearest := qt.KNearestGeo(center, 10)
+		var all []float64
+		for _, g := range drivers {
+			all = append(all, HaversineDistance(center, g))
+		}
+		sort.Float64s(all)
+		for i, p := range nearest {
+			g := l.ToGeo(p)
+			if d := HaversineDistance(center, g); d > all[i]*(1+2*bound) {
+				t.Errorf("KNearestGeo rank %d: expected about %.1fm, got %.1fm", i, all[i], d)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package spatial
+
+// WithLockFreeReads makes Search, SearchWith, KNearest, KNearestGeo,
+// SearchRadiusGeo, Size and Version read without taking any lock. The tree
+// publishes an immutable snapshot of itself at the end of every write that
+// changes the stored points, and those readers load it atomically and walk it
+// unsynchronized. A write sees its own result, since it is published before
+// the write lock is released.
+//
+// Writes always take the root lock exclusively in this mode and copy the
+// path from the root to every node they modify, as they do after Snapshot:
+// one node per level plus the leaf's points. On a 100k-point tree an Insert
+// allocates about 2.4KB in 12 allocations and takes 5x as long, against
+// 280 bytes in 2 without the option (BenchmarkInsertLockFree). The published
+// version keeps the nodes it shares alive until the next write replaces it,
+// so at most one extra path is retained at rest, however many writes came
+// before (TestLockFreeReadsHeapStabilizes).
+func WithLockFreeReads() Option {
+	return func(qt *QuadTree) {
+		qt.lockFree = true
+	}
+}
+
+// view returns the published snapshot for lock-free readers, or nil when the
+// tree reads under its locks
+func (qt *QuadTree) view() *QuadTree {
+	return qt.current.Load()
+}
+
+// unlock releases the write lock, after logging the write to the write-ahead
+// log. With lock-free reads it first publishes the tree if the write changed
+// it, so the nodes readers see are never written again.
+func (qt *QuadTree) unlock() {
+	qt.wal.commit(qt.version)
+	if qt.lockFree && qt.view().version != qt.version {
+		qt.current.Store(qt.capture())
+	}
+	qt.Lock.Unlock()
+}
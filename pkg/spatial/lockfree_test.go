@@ -0,0 +1,201 @@
+package spatial
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLockFreeReadsSeeWrites tests that lock-free readers see every write as soon as it returns
+func TestLockFreeReadsSeeWrites(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithLockFreeReads(), WithCapacity(2))
+	all := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+
+	if got := qt.Search(all); len(got) != 0 {
+		t.Fatalf("empty tree: Search returned %d points", len(got))
+	}
+	for i := 0; i < 10; i++ {
+		qt.Insert(Point{X: float64(i * 10), Y: float64(i * 10)})
+		if got := qt.Search(all); len(got) != i+1 {
+			t.Fatalf("after insert %d: Search returned %d points, want %d", i, len(got), i+1)
+		}
+	}
+	if qt.Size() != 10 || qt.Version() != 10 {
+		t.Errorf("Size, Version = %d, %d, want 10, 10", qt.Size(), qt.Version())
+	}
+
+	qt.Remove(Point{X: 0, Y: 0})
+	if got := qt.KNearest(Point{X: 0, Y: 0}, 1); len(got) != 1 || got[0].X != 10 {
+		t.Errorf("KNearest after remove = %v, want (10,10)", got)
+	}
+	if err := qt.Move("missing", 1, 1); err == nil {
+		t.Error("Move of a missing id succeeded")
+	}
+	if qt.Version() != 11 {
+		t.Errorf("failed write changed the version to %d", qt.Version())
+	}
+
+	qt.Clear()
+	if qt.Size() != 0 || len(qt.Search(all)) != 0 {
+		t.Error("Clear not visible to readers")
+	}
+	checkValid(t, qt)
+}
+
+// TestLockFreeReadsTakeNoLock tests that lock-free reads complete while a writer holds every lock
+func TestLockFreeReadsTakeNoLock(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithLockFreeReads())
+	qt.Insert(Point{X: 10, Y: 10})
+	geo := mustNewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180}, WithLockFreeReads(), WithGeoCoordinates())
+	geo.Insert(Point{X: 13.4, Y: 52.5})
+	area := Bounds{X: 0, Y: 0, Width: 50, Height: 50}
+
+	reads := map[string]func() int{
+		"Search":          func() int { return len(qt.Search(area)) },
+		"SearchWith":      func() int { return len(qt.SearchWith(area, HalfOpenEdges)) },
+		"KNearest":        func() int { return len(qt.KNearest(Point{X: 0, Y: 0}, 1)) },
+		"Size":            func() int { return qt.Size() },
+		"Version":         func() int { return int(qt.Version()) },
+		"KNearestGeo":     func() int { return len(geo.KNearest(Point{X: 13, Y: 52}, 1)) },
+		"SearchRadiusGeo": func() int { return len(geo.SearchRadiusGeo(GeoPoint{Lat: 52.5, Lon: 13.4}, 1000)) },
+	}
+
+	qt.Lock.Lock()
+	defer qt.Lock.Unlock()
+	geo.Lock.Lock()
+	defer geo.Lock.Unlock()
+	for name, read := range reads {
+		done := make(chan int)
+		go func() { done <- read() }()
+		select {
+		case got := <-done:
+			if got != 1 {
+				t.Errorf("%s = %d, want 1", name, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s blocked behind the writer", name)
+		}
+	}
+}
+
+// TestLockFreeReadsStress tests that readers only ever see whole versions while writers move and swap points
+func TestLockFreeReadsStress(t *testing.T) {
+	const n = 500
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithLockFreeReads(), WithCapacity(4))
+	all := Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+	for i := 0; i < n; i++ {
+		qt.InsertWithID(fmt.Sprintf("p%d", i), Point{X: float64(i % 1000), Y: float64(i * 7 % 1000)})
+	}
+
+	stop := make(chan struct{})
+	var writers, readers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(seed int64) {
+			defer writers.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < 2000; i++ {
+				id := fmt.Sprintf("p%d", rng.Intn(n))
+				switch i % 3 {
+				case 0:
+					qt.Move(id, rng.Float64()*1000, rng.Float64()*1000)
+				case 1:
+					qt.Upsert(id, Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+				default:
+					// Take and put back in one batch, so no version is missing the point
+					p, _ := qt.GetByID(id)
+					qt.Apply([]Op{{Kind: OpRemove, Point: p}, {Kind: OpUpsert, ID: id, Point: p}})
+				}
+			}
+		}(int64(w))
+	}
+
+	errs := make(chan string, 8)
+	for r := 0; r < 8; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if got := len(qt.Search(all)); got != n {
+					errs <- fmt.Sprintf("Search returned %d points, want %d", got, n)
+					return
+				}
+				if got := len(qt.KNearest(Point{X: 500, Y: 500}, 10)); got != 10 {
+					errs <- fmt.Sprintf("KNearest returned %d points, want 10", got)
+					return
+				}
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if qt.Size() != n {
+		t.Errorf("Size = %d, want %d", qt.Size(), n)
+	}
+	checkValid(t, qt)
+}
+
+// benchmarkInsertLarge inserts into a tree already holding 100k points
+func benchmarkInsertLarge(b *testing.B, opts ...Option) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, opts...)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		qt.Insert(Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt.Insert(Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+}
+
+func BenchmarkInsertLocked(b *testing.B)   { benchmarkInsertLarge(b) }
+func BenchmarkInsertLockFree(b *testing.B) { benchmarkInsertLarge(b, WithLockFreeReads()) }
+
+// BenchmarkMixedReadersLockFree is BenchmarkMixedReadersRWMutex with lock-free reads
+func BenchmarkMixedReadersLockFree(b *testing.B) {
+	benchmarkMixed(b, nil, WithLockFreeReads())
+}
+
+// TestLockFreeReadsHeapStabilizes tests that single writes, each publishing
+// a new version, leave no more than the published version's paths behind
+func TestLockFreeReadsHeapStabilizes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("churns versions")
+	}
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithLockFreeReads(), WithCapacity(8))
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]string, 5000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("v%d", i)
+		qt.InsertWithID(ids[i], Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+
+	churn := func(writes int) {
+		for i := 0; i < writes; i++ {
+			qt.Move(ids[rng.Intn(len(ids))], rng.Float64()*1000, rng.Float64()*1000)
+		}
+	}
+	churn(20000)
+	base := liveHeap()
+	churn(100000)
+	after := liveHeap()
+	runtime.KeepAlive(qt)
+	if after > base+base/2 {
+		t.Errorf("Heap grew from %d to %d bytes under single-write churn", base, after)
+	}
+}
@@ -0,0 +1,355 @@
+package spatial
+
+import "errors"
+
+// Lock is the root lock. Holding it exclusively excludes every other
+// operation, which is what writes that restructure the upper tree do: growing,
+// splitting, merging or copying the root, as well as batches, bulk loads, TTL
+// inserts and every write while a snapshot, version history or lock-free
+// readers are pending.
+//
+// Writes that stay inside one of the root's four subtrees instead hold Lock
+// shared together with quads[q] for their subtree, so they run alongside
+// writes and reads in the other quadrants. They never replace the root or its
+// child pointers, so under a shared Lock those are fixed; a removal or move
+// that would merge the root goes exclusive instead. The tree-wide state they
+// update (size, sequence numbers, Root.count, the ID and data-key indexes, the
+// version and the event fan-out) is guarded by meta.
+//
+// Locks are always taken in the order Lock, quads in index order, meta.
+
+// errExclusive is returned by the quadrant write paths when the write has to
+// take the root lock exclusively instead
+var errExclusive = errors.New("spatial: write needs the root lock")
+
+// sharable reports whether writes may run under a shared root lock at all.
+// Callers must hold Lock.
+func (qt *QuadTree) sharable() bool {
+	return !qt.readOnly && !qt.lockFree && qt.keepVersions == 0 && qt.Root.gen == qt.gen && qt.Root.Children[0] != nil
+}
+
+// owned reports whether the root's child in quadrant q can be written without
+// copying it, which would replace the root's child pointer
+func (qt *QuadTree) owned(q int) bool {
+	return qt.Root.Children[q].gen == qt.gen
+}
+
+// quadrantFor returns the root quadrant a write of points can lock on its own,
+// or false if the write must take the root lock exclusively. Callers must hold Lock.
+func (qt *QuadTree) quadrantFor(points ...Point) (int, bool) {
+	if !qt.sharable() {
+		return 0, false
+	}
+	q := -1
+	for _, p := range points {
+		if !validCoordinates(p) || !qt.Root.Bounds.Contains(p) {
+			return 0, false
+		}
+		if pq := qt.Root.quadrant(p); q == -1 {
+			q = pq
+		} else if pq != q {
+			return 0, false
+		}
+	}
+	return q, qt.owned(q)
+}
+
+// publish runs report, which passes a finished quadrant write on to the
+// version counter, indexes and listeners, under meta as one version
+func (qt *QuadTree) publish(report func()) {
+	qt.meta.Lock()
+	qt.versionOpen = false
+	report()
+	qt.wal.commit(qt.version)
+	qt.meta.Unlock()
+}
+
+// rlockAll takes every read lock, for reads that walk the whole tree
+func (qt *QuadTree) rlockAll() {
+	qt.Lock.RLock()
+	for i := range qt.quads {
+		qt.quads[i].RLock()
+	}
+	qt.meta.RLock()
+}
+
+func (qt *QuadTree) runlockAll() {
+	qt.meta.RUnlock()
+	for i := range qt.quads {
+		qt.quads[i].RUnlock()
+	}
+	qt.Lock.RUnlock()
+}
+
+// rlockArea read-locks the root quadrants a search of area descends into,
+// using the same test as searchEdges, and returns which ones it locked
+func (qt *QuadTree) rlockArea(area Bounds) (held uint8) {
+	qt.Lock.RLock()
+	root := qt.Root
+	if root.Children[0] == nil || !root.Bounds.Intersects(area) {
+		return 0
+	}
+	for i, c := range root.Children {
+		if c.Bounds.Intersects(area) {
+			qt.quads[i].RLock()
+			held |= 1 << i
+		}
+	}
+	return held
+}
+
+func (qt *QuadTree) runlockArea(held uint8) {
+	for i := range qt.quads {
+		if held&(1<<i) != 0 {
+			qt.quads[i].RUnlock()
+		}
+	}
+	qt.Lock.RUnlock()
+}
+
+// rlockMeta read-locks only the tree-wide state
+func (qt *QuadTree) rlockMeta() {
+	qt.Lock.RLock()
+	qt.meta.RLock()
+}
+
+func (qt *QuadTree) runlockMeta() {
+	qt.meta.RUnlock()
+	qt.Lock.RUnlock()
+}
+
+// lockLoc locks the root quadrant holding the point stored under id, for
+// writing if write is set, and returns its location. The quadrant comes from
+// the position recorded under meta and is checked again once locked, since
+// the id may have been removed and stored elsewhere in between. Callers must
+// hold Lock shared.
+func (qt *QuadTree) lockLoc(id string, write bool) (loc *location, q int, ok bool) {
+	for {
+		qt.meta.RLock()
+		loc, ok = qt.ids[id]
+		if ok {
+			q = qt.Root.quadrant(Point{X: loc.x, Y: loc.y})
+		}
+		qt.meta.RUnlock()
+		if !ok {
+			return nil, 0, false
+		}
+
+		qt.lockQuad(q, write)
+		qt.meta.RLock()
+		same := qt.ids[id] == loc && qt.Root.quadrant(Point{X: loc.x, Y: loc.y}) == q
+		qt.meta.RUnlock()
+		if same {
+			return loc, q, true
+		}
+		qt.unlockQuad(q, write)
+	}
+}
+
+func (qt *QuadTree) lockQuad(q int, write bool) {
+	if write {
+		qt.quads[q].Lock()
+	} else {
+		qt.quads[q].RLock()
+	}
+}
+
+func (qt *QuadTree) unlockQuad(q int, write bool) {
+	if write {
+		qt.quads[q].Unlock()
+	} else {
+		qt.quads[q].RUnlock()
+	}
+}
+
+// insertShared is InsertE, or InsertWithID when loc is set, for a point
+// inside one root quadrant
+func (qt *QuadTree) insertShared(point Point, loc *location) error {
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	q, ok := qt.quadrantFor(point)
+	if !ok {
+		return errExclusive
+	}
+	qt.quads[q].Lock()
+	defer qt.quads[q].Unlock()
+
+	// Claim the sequence number, slot and id before placing the point
+	qt.meta.Lock()
+	if loc != nil {
+		if _, exists := qt.ids[loc.id]; exists {
+			qt.meta.Unlock()
+			return ErrDuplicateID
+		}
+	}
+	if qt.full() {
+		qt.meta.Unlock()
+		return ErrTreeFull
+	}
+	qt.nextSeq++
+	point.seq = qt.nextSeq
+	point.loc = loc
+	point.expires = 0
+	if loc != nil {
+		if qt.ids == nil {
+			qt.ids = make(map[string]*location)
+		}
+		loc.x, loc.y = point.X, point.Y
+		qt.ids[loc.id] = loc
+	}
+	qt.size++
+	qt.Root.count++
+	qt.meta.Unlock()
+
+	qt.Root.Children[q].insert(point)
+	qt.publish(func() { qt.inserted(point) })
+	return nil
+}
+
+// takeShared is take for a point inside one root quadrant
+func (qt *QuadTree) takeShared(point Point) (Point, error) {
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	q, ok := qt.quadrantFor(point)
+	if !ok || qt.matchEps > 0 {
+		return Point{}, errExclusive
+	}
+	qt.quads[q].Lock()
+	defer qt.quads[q].Unlock()
+
+	if !qt.reserveRemoval() {
+		return Point{}, errExclusive
+	}
+	removed, ok := qt.Root.Children[q].remove(point, qt.Root)
+	if !ok {
+		qt.meta.Lock()
+		qt.Root.count++
+		qt.meta.Unlock()
+		return Point{}, ErrNotFound
+	}
+	qt.publish(func() { qt.removedBelow(removed) })
+	return removed, nil
+}
+
+// reserveRemoval takes one point off Root.count ahead of a removal below the
+// root, or reports false if the root would then have to merge. Reserving
+// rather than checking keeps concurrent removals from all missing the merge.
+func (qt *QuadTree) reserveRemoval() bool {
+	qt.meta.Lock()
+	defer qt.meta.Unlock()
+	if !qt.Root.splits(qt.Root.count - 1) {
+		return false
+	}
+	qt.Root.count--
+	return true
+}
+
+// rootStays reports whether the root stays subdivided with one point fewer,
+// so a move below it can't merge the root midway as an exclusive move would
+func (qt *QuadTree) rootStays() bool {
+	qt.meta.RLock()
+	defer qt.meta.RUnlock()
+	return qt.Root.splits(qt.Root.count - 1)
+}
+
+// removedBelow updates the tree-wide state for a point removed below the
+// root, whose Root.count was already reserved
+func (qt *QuadTree) removedBelow(removed Point) {
+	if removed.loc != nil {
+		qt.dropLoc(removed.loc)
+	}
+	qt.size--
+	qt.removed(removed)
+}
+
+// updateShared is update for two points inside the same root quadrant
+func (qt *QuadTree) updateShared(oldPoint, newPoint Point) error {
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	q, ok := qt.quadrantFor(oldPoint, newPoint)
+	if !ok || qt.matchEps > 0 {
+		return errExclusive
+	}
+	qt.quads[q].Lock()
+	defer qt.quads[q].Unlock()
+	if !qt.rootStays() {
+		return errExclusive
+	}
+
+	from, to, ok := qt.Root.Children[q].move(oldPoint, newPoint, qt.Root)
+	if !ok {
+		return ErrNotFound
+	}
+	qt.publish(func() { qt.moved(from, to) })
+	return nil
+}
+
+// takeByIDShared is TakeByID for a point inside one root quadrant
+func (qt *QuadTree) takeByIDShared(id string) (Point, error) {
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	if !qt.sharable() {
+		return Point{}, errExclusive
+	}
+	loc, q, ok := qt.lockLoc(id, true)
+	if !ok {
+		return Point{}, ErrNotFound
+	}
+	defer qt.quads[q].Unlock()
+	if !qt.owned(q) || !qt.reserveRemoval() {
+		return Point{}, errExclusive
+	}
+
+	qt.ownLoc(loc)
+	removed := loc.leaf.removeLoc(loc, qt.Root)
+	qt.publish(func() { qt.removedBelow(removed) })
+	return removed, nil
+}
+
+// relocateShared moves the point stored under id to newP when both positions
+// are in the same root quadrant, keeping its Data if keepData is set
+func (qt *QuadTree) relocateShared(id string, newP Point, keepData bool) error {
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	if !qt.sharable() || !validCoordinates(newP) || !qt.Root.Bounds.Contains(newP) {
+		return errExclusive
+	}
+	loc, q, ok := qt.lockLoc(id, true)
+	if !ok {
+		return ErrNotFound
+	}
+	defer qt.quads[q].Unlock()
+	if qt.Root.quadrant(newP) != q || !qt.owned(q) || !qt.rootStays() {
+		return errExclusive
+	}
+
+	from, _ := loc.point()
+	p := newP
+	if keepData {
+		p = from
+		p.X, p.Y = newP.X, newP.Y
+	}
+	p.seq = from.seq
+	p.loc = loc
+	qt.ownLoc(loc)
+	qt.Root.Children[q].relocate(p, qt.Root)
+	qt.publish(func() { qt.moved(from, p) })
+	return nil
+}
+
+// upsertShared is upsert for a point that stays inside one root quadrant
+func (qt *QuadTree) upsertShared(id string, p Point) (created bool, err error) {
+	err = qt.relocateShared(id, p, false)
+	if err != ErrNotFound {
+		return false, err
+	}
+	switch err = qt.insertShared(p, &location{id: id}); err {
+	case nil:
+		return true, nil
+	case ErrDuplicateID:
+		// Stored by someone else since the lookup
+		return false, errExclusive
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,297 @@
+package spatial
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestReadsShareTheLock tests that every read method runs while another reader holds the lock
+func TestReadsShareTheLock(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithDataKey(func(p Point) (string, bool) { return "k", true }))
+	qt.InsertWithID("a", Point{X: 10, Y: 10})
+	qt.Insert(Point{X: 20, Y: 20})
+	area := Bounds{X: 0, Y: 0, Width: 50, Height: 50}
+
+	reads := map[string]func(){
+		"Search":     func() { qt.Search(area) },
+		"SearchWith": func() { qt.SearchWith(area, HalfOpenEdges) },
+		"KNearest":   func() { qt.KNearest(Point{X: 0, Y: 0}, 2) },
+		"Size":       func() { qt.Size() },
+		"GetByID":    func() { qt.GetByID("a") },
+		"FindByKey":  func() { qt.FindByKey("k") },
+		"Stats":      func() { qt.Stats() },
+		"Freeze":     func() { qt.Freeze() },
+		"Version":    func() { qt.Version() },
+		"Validate":   func() { qt.Validate() },
+	}
+
+	qt.Lock.RLock()
+	defer qt.Lock.RUnlock()
+	for name, read := range reads {
+		done := make(chan struct{})
+		go func() {
+			read()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s blocked behind another reader", name)
+		}
+	}
+}
+
+// benchmarkMixed runs 15 KNearest readers against 1 Upsert writer, serializing
+// every call through exclusive when it is non-nil, and reports reads and writes per second
+func benchmarkMixed(b *testing.B, exclusive *sync.Mutex, opts ...Option) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, append([]Option{WithCapacity(8)}, opts...)...)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		qt.Insert(Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+	call := func(fn func()) {
+		if exclusive != nil {
+			exclusive.Lock()
+			defer exclusive.Unlock()
+		}
+		fn()
+	}
+
+	const readers = 15
+	var reads, writes atomic.Int64
+	stop := make(chan struct{})
+	var writer sync.WaitGroup
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		rng := rand.New(rand.NewSource(2))
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			call(func() {
+				qt.Upsert("courier", Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+			})
+			writes.Add(1)
+		}
+	}()
+
+	b.ResetTimer()
+	start := time.Now()
+	writes.Store(0)
+	var wg sync.WaitGroup
+	per := b.N/readers + 1
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < per; i++ {
+				target := Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+				call(func() { qt.KNearest(target, 8) })
+				reads.Add(1)
+			}
+		}(int64(r + 10))
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	b.StopTimer()
+	close(stop)
+	writer.Wait()
+	b.ReportMetric(float64(reads.Load())/elapsed.Seconds(), "reads/s")
+	b.ReportMetric(float64(writes.Load())/elapsed.Seconds(), "writes/s")
+}
+
+func BenchmarkMixedReadersRWMutex(b *testing.B) {
+	benchmarkMixed(b, nil)
+}
+
+func BenchmarkMixedReadersExclusive(b *testing.B) {
+	benchmarkMixed(b, new(sync.Mutex))
+}
+
+// TestQuadrantWritesDontContend tests that work in one root quadrant proceeds while another is held
+func TestQuadrantWritesDontContend(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+	for i := 0; i < 4; i++ {
+		qt.InsertWithID(fmt.Sprintf("nw%d", i), Point{X: float64(5 + i), Y: 5})
+		qt.InsertWithID(fmt.Sprintf("se%d", i), Point{X: float64(80 + i), Y: 80})
+	}
+	se := Bounds{X: 55, Y: 55, Width: 45, Height: 45}
+
+	// Stand in for a long write downtown, holding what a quadrant write holds;
+	// anything falling back to the exclusive root lock would wait too
+	qt.Lock.RLock()
+	qt.quads[0].Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		qt.Insert(Point{X: 70, Y: 70})
+		qt.UpdateByID("se0", Point{X: 90, Y: 95})
+		qt.Move("se1", 60, 60)
+		qt.Upsert("se2", Point{X: 99, Y: 99})
+		qt.Upsert("se-new", Point{X: 75, Y: 75})
+		qt.Update(Point{X: 70, Y: 70}, Point{X: 71, Y: 71})
+		qt.Remove(Point{X: 71, Y: 71})
+		qt.RemoveByID("se3")
+		qt.GetByID("se0")
+		qt.Search(se)
+		qt.Size()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Writes in the south-east quadrant waited for the north-west one")
+	}
+
+	blocked := make(chan struct{})
+	go func() {
+		qt.UpdateByID("nw0", Point{X: 6, Y: 6})
+		close(blocked)
+	}()
+	select {
+	case <-blocked:
+		t.Error("A write in a held quadrant should wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	qt.quads[0].Unlock()
+	qt.Lock.RUnlock()
+	<-blocked
+
+	if p, ok := qt.GetByID("se0"); !ok || p.X != 90 || p.Y != 95 {
+		t.Errorf("Expected se0 at (90, 95), got %v", p)
+	}
+	if got := len(qt.Search(se)); got != 4 {
+		t.Errorf("Expected 4 points in the south-east, got %d", got)
+	}
+	checkValid(t, qt)
+}
+
+// TestQuadrantWritesStress tests mixed quadrant and root-level writes against every reader
+func TestQuadrantWritesStress(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4),
+		WithDataKey(func(p Point) (string, bool) {
+			s, ok := p.Data.(string)
+			return s, ok
+		}))
+	events, cancel := qt.Watch(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000})
+	defer cancel()
+	go func() {
+		for range events {
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			pos := func() Point {
+				return Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Data: fmt.Sprint(g)}
+			}
+			for i := 0; i < 300; i++ {
+				id := fmt.Sprintf("g%d-%d", g, rng.Intn(20))
+				switch rng.Intn(9) {
+				case 0:
+					qt.Insert(pos())
+				case 1:
+					qt.Upsert(id, pos())
+				case 2:
+					if p, ok := qt.GetByID(id); ok {
+						// Nudge within the quadrant, or jump across it
+						qt.Move(id, p.X+rng.Float64()-0.5, p.Y)
+					}
+				case 3:
+					qt.UpdateByID(id, pos())
+				case 4:
+					qt.RemoveByID(id)
+				case 5:
+					for _, p := range qt.Search(Bounds{X: rng.Float64() * 900, Y: rng.Float64() * 900, Width: 100, Height: 100}) {
+						if p.loc == nil {
+							qt.Remove(p)
+						}
+					}
+				case 6:
+					qt.KNearest(pos(), 3)
+					qt.FindByKey(fmt.Sprint(g))
+				case 7:
+					qt.Apply([]Op{{Kind: OpInsert, Point: pos()}, {Kind: OpUpsert, ID: id, Point: pos()}})
+				case 8:
+					if i%50 == 0 {
+						qt.Snapshot()
+						qt.Stats()
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	checkValid(t, qt)
+	all := qt.Search(qt.Root.Bounds)
+	if qt.Size() != len(all) {
+		t.Errorf("Size() = %d, Search found %d", qt.Size(), len(all))
+	}
+	indexed := 0
+	for g := 0; g < 8; g++ {
+		indexed += len(qt.FindByKey(fmt.Sprint(g)))
+	}
+	if indexed != len(all) {
+		t.Errorf("Data-key index holds %d points, tree holds %d", indexed, len(all))
+	}
+}
+
+// benchmarkQuadrantWrites runs 4 writers moving their own couriers with
+// UpdateByID, all inside the north-west quadrant or one writer per quadrant
+func benchmarkQuadrantWrites(b *testing.B, spread bool) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		qt.Insert(Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+	origin := func(w int) (float64, float64) {
+		if !spread {
+			return 0, 0
+		}
+		return float64(w&1) * 500, float64(w>>1) * 500
+	}
+	const writers, couriers = 4, 256
+	for w := 0; w < writers; w++ {
+		x, y := origin(w)
+		for c := 0; c < couriers; c++ {
+			qt.InsertWithID(fmt.Sprintf("w%d-%d", w, c), Point{X: x + rng.Float64()*500, Y: y + rng.Float64()*500})
+		}
+	}
+
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	per := b.N/writers + 1
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			x, y := origin(w)
+			for i := 0; i < per; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i%couriers)
+				qt.UpdateByID(id, Point{X: x + rng.Float64()*500, Y: y + rng.Float64()*500})
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func BenchmarkQuadrantWritesOneQuadrant(b *testing.B) {
+	benchmarkQuadrantWrites(b, false)
+}
+
+func BenchmarkQuadrantWritesSpread(b *testing.B) {
+	benchmarkQuadrantWrites(b, true)
+}
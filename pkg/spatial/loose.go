@@ -0,0 +1,462 @@
+package spatial
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+)
+
+// DefaultLooseness is the factor WithLooseness sets when it is not given
+const DefaultLooseness = 2
+
+// LooseObject is a circle stored in a LooseQuadTree, with the Data it was
+// inserted with
+type LooseObject struct {
+	Center Point
+	Radius float64
+	Data   interface{}
+	seq    uint64
+}
+
+// LooseQuadTree stores circles, such as delivery zones and drivers' service
+// areas, each in exactly one node. A node's loose bounds are its bounds grown
+// on every side by (looseness-1)/2 of its size, and a circle is pushed down
+// to the deepest node that holds its center and whose loose bounds hold all
+// of it; circles too large for any child of the root stay at the root.
+// Queries prune by loose bounds, so they find every circle they touch however
+// far it reaches past the node holding its center. Distances are planar. It
+// is safe for concurrent use.
+type LooseQuadTree struct {
+	looseness float64
+	capacity  int
+	maxDepth  int
+
+	mu      sync.RWMutex // Guards everything below
+	root    *looseNode
+	size    int
+	nextSeq uint64
+}
+
+type looseNode struct {
+	bounds   Bounds
+	loose    rect    // Holds every circle at or below the node
+	reach    float64 // The largest radius the node may hold; unbounded at the root
+	depth    int
+	objects  []LooseObject
+	children *[4]*looseNode // SW, SE, NW, NE; nil in a leaf
+	total    int            // Circles at or below the node
+}
+
+// LooseOption configures a LooseQuadTree built with NewLooseQuadTree
+type LooseOption func(*LooseQuadTree)
+
+// WithLooseness sets how far nodes' loose bounds reach: a factor of 2, the
+// default, makes them twice the size of their nodes. Larger factors push
+// circles deeper but make queries test more of them.
+func WithLooseness(factor float64) LooseOption {
+	return func(t *LooseQuadTree) {
+		t.looseness = factor
+	}
+}
+
+// WithLooseCapacity sets how many circles a leaf holds before it splits
+// (DefaultCapacity if unset)
+func WithLooseCapacity(n int) LooseOption {
+	return func(t *LooseQuadTree) {
+		t.capacity = n
+	}
+}
+
+// WithLooseMaxDepth caps how deep the tree may subdivide (DefaultMaxDepth if
+// unset)
+func WithLooseMaxDepth(depth int) LooseOption {
+	return func(t *LooseQuadTree) {
+		t.maxDepth = depth
+	}
+}
+
+// NewLooseQuadTree builds a tree for circles centered in bounds. Bounds must
+// be valid and have a positive area, or it returns ErrInvalidBounds; it
+// returns ErrInvalidLooseness, ErrInvalidCapacity or ErrInvalidMaxDepth for
+// options out of range.
+func NewLooseQuadTree(bounds Bounds, opts ...LooseOption) (*LooseQuadTree, error) {
+	if err := bounds.Validate(); err != nil {
+		return nil, err
+	}
+	if bounds.Width == 0 || bounds.Height == 0 {
+		return nil, ErrInvalidBounds
+	}
+	t := &LooseQuadTree{looseness: DefaultLooseness, capacity: DefaultCapacity, maxDepth: DefaultMaxDepth}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if !(t.looseness >= 1) || math.IsInf(t.looseness, 1) {
+		return nil, ErrInvalidLooseness
+	}
+	if t.capacity <= 0 {
+		return nil, ErrInvalidCapacity
+	}
+	if t.maxDepth < 0 {
+		return nil, ErrInvalidMaxDepth
+	}
+	inf := math.Inf(1)
+	t.root = &looseNode{bounds: bounds, loose: rect{-inf, -inf, inf, inf}, reach: inf}
+	return t, nil
+}
+
+// newChild returns an empty node over b at depth
+func (t *LooseQuadTree) newChild(b Bounds, depth int) *looseNode {
+	mx, my := (t.looseness-1)/2*b.Width, (t.looseness-1)/2*b.Height
+	// Widened a hair so rounding in a circle's extent can't take it out
+	slack := 1e-9 * (max(math.Abs(b.X), math.Abs(b.Y)) + b.Width + b.Height + mx + my)
+	return &looseNode{
+		bounds: b,
+		loose:  rect{b.X - mx - slack, b.Y - my - slack, b.X + b.Width + mx + slack, b.Y + b.Height + my + slack},
+		reach:  min(mx, my),
+		depth:  depth,
+	}
+}
+
+// quadrant returns the index among n's children of the one holding p
+func (n *looseNode) quadrant(p Point) int {
+	q := 0
+	if p.X >= n.bounds.X+n.bounds.Width/2 {
+		q |= 1
+	}
+	if p.Y >= n.bounds.Y+n.bounds.Height/2 {
+		q |= 2
+	}
+	return q
+}
+
+// next returns the child of n that o belongs in, or nil if it belongs in n
+func (n *looseNode) next(o LooseObject) *looseNode {
+	if n.children == nil {
+		return nil
+	}
+	if c := n.children[n.quadrant(o.Center)]; o.Radius <= c.reach {
+		return c
+	}
+	return nil
+}
+
+func (t *LooseQuadTree) admits(center Point, radius float64) bool {
+	return validCoordinates(center) && radius >= 0 && !math.IsInf(radius, 1) && t.root.bounds.Contains(center)
+}
+
+// Insert stores a circle holding data, reporting false if center is NaN,
+// infinite or outside the tree's bounds, or radius is negative, NaN or
+// infinite
+func (t *LooseQuadTree) Insert(center Point, radius float64, data interface{}) bool {
+	if !t.admits(center, radius) {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextSeq++
+	t.insert(t.root, LooseObject{Center: Point{X: center.X, Y: center.Y}, Radius: radius, Data: data, seq: t.nextSeq})
+	t.size++
+	return true
+}
+
+// insert stores o at or below n, splitting the leaf it lands in if that
+// overfills it. Callers must hold the write lock.
+func (t *LooseQuadTree) insert(n *looseNode, o LooseObject) {
+	n.total++
+	for c := n.next(o); c != nil; c = n.next(o) {
+		n = c
+		n.total++
+	}
+	n.objects = append(n.objects, o)
+	if n.children == nil && len(n.objects) > t.capacity && n.depth < t.maxDepth {
+		t.split(n)
+	}
+}
+
+// split gives n children and pushes down the circles that fit in them
+func (t *LooseQuadTree) split(n *looseNode) {
+	b := n.bounds
+	w, h := b.Width/2, b.Height/2
+	n.children = &[4]*looseNode{
+		t.newChild(Bounds{X: b.X, Y: b.Y, Width: w, Height: h}, n.depth+1),
+		t.newChild(Bounds{X: b.X + w, Y: b.Y, Width: w, Height: h}, n.depth+1),
+		t.newChild(Bounds{X: b.X, Y: b.Y + h, Width: w, Height: h}, n.depth+1),
+		t.newChild(Bounds{X: b.X + w, Y: b.Y + h, Width: w, Height: h}, n.depth+1),
+	}
+	kept := n.objects[:0]
+	for _, o := range n.objects {
+		if c := n.next(o); c != nil {
+			t.insert(c, o)
+		} else {
+			kept = append(kept, o)
+		}
+	}
+	clear(n.objects[len(kept):])
+	n.objects = kept
+}
+
+// find returns the nodes from the root down to the one o belongs in, and
+// the position there of the circle o refers to: the one with o's seq if it
+// carries one, otherwise the earliest with o's center and radius. Callers
+// must hold mu.
+func (t *LooseQuadTree) find(o LooseObject) ([]*looseNode, int) {
+	if !t.admits(o.Center, o.Radius) {
+		return nil, -1
+	}
+	path := []*looseNode{t.root}
+	for c := t.root.next(o); c != nil; c = c.next(o) {
+		path = append(path, c)
+	}
+	match := -1
+	objects := path[len(path)-1].objects
+	for i, s := range objects {
+		if s.Center.X != o.Center.X || s.Center.Y != o.Center.Y || s.Radius != o.Radius {
+			continue
+		}
+		if o.seq != 0 {
+			if s.seq == o.seq {
+				return path, i
+			}
+			continue
+		}
+		if match == -1 || s.seq < objects[match].seq {
+			match = i
+		}
+	}
+	return path, match
+}
+
+// remove takes out the circle at i in the last node of path, and merges
+// back into their parent the children of the highest node on path left
+// with no more circles than a leaf holds. Callers must hold the write lock.
+func (t *LooseQuadTree) remove(path []*looseNode, i int) LooseObject {
+	n := path[len(path)-1]
+	o := n.objects[i]
+	n.objects = slices.Delete(n.objects, i, i+1)
+	for _, p := range path {
+		p.total--
+	}
+	for _, p := range path {
+		if p.children != nil && p.total <= t.capacity {
+			p.objects = p.collect(p.objects[:len(p.objects):len(p.objects)])
+			p.children = nil
+			break
+		}
+	}
+	t.size--
+	return o
+}
+
+// collect appends the circles below n to objects
+func (n *looseNode) collect(objects []LooseObject) []LooseObject {
+	if n.children == nil {
+		return objects
+	}
+	for _, c := range n.children {
+		objects = append(objects, c.objects...)
+		objects = c.collect(objects)
+	}
+	return objects
+}
+
+// Remove deletes the circle o refers to, matched by its seq if it came from
+// a query and otherwise by its center and radius, earliest first. It reports
+// whether one was found.
+func (t *LooseQuadTree) Remove(o LooseObject) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	path, i := t.find(o)
+	if i == -1 {
+		return false
+	}
+	t.remove(path, i)
+	return true
+}
+
+// Update moves and resizes the circle old refers to, matched as Remove
+// matches it, keeping its Data. It reports false, changing nothing, if no
+// circle matches or the new circle can't be stored.
+func (t *LooseQuadTree) Update(old LooseObject, center Point, radius float64) bool {
+	if !t.admits(center, radius) {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	path, i := t.find(old)
+	if i == -1 {
+		return false
+	}
+	o := t.remove(path, i)
+	o.Center, o.Radius = Point{X: center.X, Y: center.Y}, radius
+	t.insert(t.root, o)
+	t.size++
+	return true
+}
+
+// Count returns how many circles are stored
+func (t *LooseQuadTree) Count() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.size
+}
+
+// ForEach calls fn for every circle, in no particular order, until fn
+// returns false. It holds a read lock throughout, so fn must not modify the
+// tree.
+func (t *LooseQuadTree) ForEach(fn func(LooseObject) bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.root.visit(func(rect) bool { return true }, fn)
+}
+
+// visit calls fn for the circles of n and of every node below it whose loose
+// bounds pass prune, until fn returns false, which it reports
+func (n *looseNode) visit(prune func(rect) bool, fn func(LooseObject) bool) bool {
+	for _, o := range n.objects {
+		if !fn(o) {
+			return false
+		}
+	}
+	if n.children == nil {
+		return true
+	}
+	for _, c := range n.children {
+		if c.total > 0 && prune(c.loose) && !c.visit(prune, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns the circles that touch area, edges included, in insertion
+// order
+func (t *LooseQuadTree) Search(area Bounds) []LooseObject {
+	results := make([]LooseObject, 0)
+	if area.Validate() != nil {
+		return results
+	}
+	r := rectOf(area)
+	t.mu.RLock()
+	t.root.visit(r.intersects, func(o LooseObject) bool {
+		dx := max(r.minX-o.Center.X, 0, o.Center.X-r.maxX)
+		dy := max(r.minY-o.Center.Y, 0, o.Center.Y-r.maxY)
+		if withinSquared(dx*dx+dy*dy, o.Radius, o.Radius*o.Radius) {
+			results = append(results, o)
+		}
+		return true
+	})
+	t.mu.RUnlock()
+	sortLooseObjects(results)
+	return results
+}
+
+// SearchRadius returns the circles that touch the circle of radius around
+// center, in insertion order
+func (t *LooseQuadTree) SearchRadius(center Point, radius float64) []LooseObject {
+	results := make([]LooseObject, 0)
+	if !(radius >= 0) || !validCoordinates(center) {
+		return results
+	}
+	r := rect{center.X - radius, center.Y - radius, center.X + radius, center.Y + radius}
+	t.mu.RLock()
+	t.root.visit(r.intersects, func(o LooseObject) bool {
+		reach := radius + o.Radius
+		if withinSquared(DistanceSquared(center, o.Center), reach, reach*reach) {
+			results = append(results, o)
+		}
+		return true
+	})
+	t.mu.RUnlock()
+	sortLooseObjects(results)
+	return results
+}
+
+func sortLooseObjects(objects []LooseObject) {
+	slices.SortFunc(objects, func(a, b LooseObject) int {
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		}
+		return 0
+	})
+}
+
+// looseNearest is a circle and how far it is from a query, zero if the
+// query lies inside it
+type looseNearest struct {
+	gap float64
+	o   LooseObject
+}
+
+func (a looseNearest) before(b looseNearest) bool {
+	return a.gap < b.gap || (a.gap == b.gap && a.o.seq < b.o.seq)
+}
+
+// KNearest returns the k circles whose edges are closest to target, nearest
+// first, counting every circle holding target as at distance zero and
+// ranking ties by insertion order
+func (t *LooseQuadTree) KNearest(target Point, k int) []LooseObject {
+	if k <= 0 || !validCoordinates(target) {
+		return make([]LooseObject, 0)
+	}
+	t.mu.RLock()
+	nearest := make([]looseNearest, 0, min(k, t.size))
+	t.root.nearest(target, k, &nearest)
+	t.mu.RUnlock()
+	results := make([]LooseObject, len(nearest))
+	for i, c := range nearest {
+		results[i] = c.o
+	}
+	return results
+}
+
+// nearest merges the circles at and below n into nearest, kept sorted and
+// no longer than k, visiting children closest first and skipping those
+// whose loose bounds are farther than the k-th nearest so far
+func (n *looseNode) nearest(target Point, k int, nearest *[]looseNearest) {
+	for _, o := range n.objects {
+		c := looseNearest{gap: max(math.Sqrt(DistanceSquared(target, o.Center))-o.Radius, 0), o: o}
+		if len(*nearest) == k && !c.before((*nearest)[k-1]) {
+			continue
+		}
+		i, _ := slices.BinarySearchFunc(*nearest, c, func(a, b looseNearest) int {
+			if a.before(b) {
+				return -1
+			}
+			return 1
+		})
+		if len(*nearest) == k {
+			*nearest = (*nearest)[:k-1]
+		}
+		*nearest = slices.Insert(*nearest, i, c)
+	}
+	if n.children == nil {
+		return
+	}
+	var order [4]looseChild
+	for i, c := range n.children {
+		b := c.loose
+		dx := max(b.minX-target.X, 0, target.X-b.maxX)
+		dy := max(b.minY-target.Y, 0, target.Y-b.maxY)
+		order[i] = looseChild{gap: math.Sqrt(dx*dx + dy*dy), n: c}
+	}
+	slices.SortFunc(order[:], func(a, b looseChild) int {
+		return cmp.Compare(a.gap, b.gap)
+	})
+	for _, o := range order {
+		// A circle as far as the k-th nearest may still rank before it
+		if o.n.total == 0 || (len(*nearest) == k && o.gap > (*nearest)[k-1].gap) {
+			continue
+		}
+		o.n.nearest(target, k, nearest)
+	}
+}
+
+// looseChild is a child node and how far its loose bounds are from a query
+type looseChild struct {
+	gap float64
+	n   *looseNode
+}
@@ -0,0 +1,226 @@
+package spatial_test
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// randomCircle returns a circle centered in 0..1000, some on the bounds' or
+// the first splits' lines, with radii from zero to far past the bounds
+func randomCircle(rng *rand.Rand) (spatial.Point, float64) {
+	c := spatial.Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+	lines := []float64{0, 250, 500, 750, 1000}
+	if rng.Intn(4) == 0 {
+		c.X = lines[rng.Intn(len(lines))]
+	}
+	if rng.Intn(4) == 0 {
+		c.Y = lines[rng.Intn(len(lines))]
+	}
+	switch rng.Intn(6) {
+	case 0:
+		return c, 0
+	case 1:
+		return c, rng.Float64() * 1500
+	case 2:
+		return c, rng.Float64() * 100
+	}
+	return c, rng.Float64() * 8
+}
+
+// touchesRect is the test Search makes: the circle reaches the nearest
+// point of b
+func touchesRect(o spatial.LooseObject, b spatial.Bounds) bool {
+	dx := max(b.X-o.Center.X, 0, o.Center.X-(b.X+b.Width))
+	dy := max(b.Y-o.Center.Y, 0, o.Center.Y-(b.Y+b.Height))
+	return math.Sqrt(dx*dx+dy*dy) <= o.Radius
+}
+
+func gap(o spatial.LooseObject, p spatial.Point) float64 {
+	return max(math.Sqrt(spatial.DistanceSquared(o.Center, p))-o.Radius, 0)
+}
+
+// TestLooseQuadTreeMatchesBruteForce tests that queries find exactly the
+// circles a scan of every one finds, at several loosenesses and through
+// inserts, removals and updates, with query rectangles and circles set to
+// just touch stored circles
+func TestLooseQuadTreeMatchesBruteForce(t *testing.T) {
+	for _, looseness := range []float64{1, 1.5, 2, 3} {
+		for _, capacity := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%v/%d", looseness, capacity), func(t *testing.T) {
+				rng := rand.New(rand.NewSource(int64(looseness*10) + int64(capacity)))
+				lt, err := spatial.NewLooseQuadTree(spatialBounds1000, spatial.WithLooseness(looseness), spatial.WithLooseCapacity(capacity))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var live []spatial.LooseObject // Indexed by Data until removals
+				for i := 0; i < 2000; i++ {
+					c, r := randomCircle(rng)
+					if !lt.Insert(c, r, i) {
+						t.Fatalf("expected to insert %v, %v", c, r)
+					}
+					live = append(live, spatial.LooseObject{Center: c, Radius: r, Data: i})
+				}
+				checkLoose(t, rng, lt, live)
+
+				// Query results carry what Remove and Update need to match them
+				all := lt.Search(spatialBounds1000)
+				for i := 0; i < 1200; i++ {
+					j := rng.Intn(len(all))
+					id := all[j].Data.(int)
+					if i%2 == 0 {
+						if !lt.Remove(all[j]) {
+							t.Fatalf("expected to remove %v", all[j])
+						}
+						live[id].Data = -1
+					} else {
+						c, r := randomCircle(rng)
+						if !lt.Update(all[j], c, r) {
+							t.Fatalf("expected to update %v", all[j])
+						}
+						live[id].Center, live[id].Radius = c, r
+					}
+					all = slices.Delete(all, j, j+1)
+				}
+				live = slices.DeleteFunc(live, func(o spatial.LooseObject) bool { return o.Data == -1 })
+				checkLoose(t, rng, lt, live)
+			})
+		}
+	}
+}
+
+var spatialBounds1000 = spatial.Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+
+func looseIDs(objects []spatial.LooseObject) []int {
+	ids := make([]int, 0, len(objects))
+	for _, o := range objects {
+		ids = append(ids, o.Data.(int))
+	}
+	return ids
+}
+
+func checkLoose(t *testing.T, rng *rand.Rand, lt *spatial.LooseQuadTree, live []spatial.LooseObject) {
+	t.Helper()
+	if lt.Count() != len(live) {
+		t.Fatalf("expected %d circles, got %d", len(live), lt.Count())
+	}
+	for i := 0; i < 250; i++ {
+		area := spatial.Bounds{X: rng.Float64()*1200 - 100, Y: rng.Float64()*1200 - 100, Width: rng.Float64() * 60, Height: rng.Float64() * 60}
+		center := spatial.Point{X: rng.Float64()*1200 - 100, Y: rng.Float64()*1200 - 100}
+		radius := rng.Float64() * 30
+		if i%2 == 0 {
+			// Just touching a stored circle, to its right and above it
+			o := live[rng.Intn(len(live))]
+			area.X, area.Y = o.Center.X+o.Radius, o.Center.Y
+			center = spatial.Point{X: o.Center.X, Y: o.Center.Y + o.Radius + radius}
+		}
+		var wantArea, wantRadius []int
+		for _, o := range live {
+			if touchesRect(o, area) {
+				wantArea = append(wantArea, o.Data.(int))
+			}
+			if math.Sqrt(spatial.DistanceSquared(o.Center, center)) <= o.Radius+radius {
+				wantRadius = append(wantRadius, o.Data.(int))
+			}
+		}
+		if got := looseIDs(lt.Search(area)); !slices.Equal(got, wantArea) {
+			t.Fatalf("Search %+v: expected %v, got %v", area, wantArea, got)
+		}
+		if got := looseIDs(lt.SearchRadius(center, radius)); !slices.Equal(got, wantRadius) {
+			t.Fatalf("SearchRadius %v, %v: expected %v, got %v", center, radius, wantRadius, got)
+		}
+
+		k := 1 + rng.Intn(8)
+		type ranked struct {
+			gap float64
+			id  int
+		}
+		ranks := make([]ranked, len(live))
+		for j, o := range live {
+			ranks[j] = ranked{gap(o, center), o.Data.(int)}
+		}
+		slices.SortStableFunc(ranks, func(a, b ranked) int { return cmp.Compare(a.gap, b.gap) })
+		var want []int
+		for _, r := range ranks[:min(k, len(ranks))] {
+			want = append(want, r.id)
+		}
+		if got := looseIDs(lt.KNearest(center, k)); !slices.Equal(got, want) {
+			t.Fatalf("KNearest %v, %d: expected %v, got %v", center, k, want, got)
+		}
+	}
+}
+
+func TestLooseQuadTreeRemove(t *testing.T) {
+	lt, _ := spatial.NewLooseQuadTree(spatialBounds1000, spatial.WithLooseCapacity(1))
+	c := spatial.Point{X: 10, Y: 10}
+	for i := 0; i < 3; i++ {
+		lt.Insert(c, 5, i)
+	}
+	if !lt.Remove(spatial.LooseObject{Center: c, Radius: 5}) {
+		t.Fatal("expected to remove by center and radius")
+	}
+	found := lt.SearchRadius(c, 0)
+	if len(found) != 2 || found[0].Data != 1 {
+		t.Fatalf("expected the earliest removed, got %v", found)
+	}
+	if lt.Remove(spatial.LooseObject{Center: c, Radius: 6}) {
+		t.Error("expected no match for another radius")
+	}
+	if !lt.Update(found[1], spatial.Point{X: 900, Y: 900}, 1) || !lt.Remove(found[0]) || lt.Remove(found[0]) {
+		t.Fatal("expected each query result to be updated or removed once")
+	}
+	if got := lt.KNearest(c, 5); len(got) != 1 || got[0].Data != 2 || got[0].Center.X != 900 {
+		t.Errorf("expected the updated circle to keep its data, got %v", got)
+	}
+}
+
+func TestLooseQuadTreeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		opt  spatial.LooseOption
+		want error
+	}{
+		{spatial.WithLooseness(0.5), spatial.ErrInvalidLooseness},
+		{spatial.WithLooseness(math.NaN()), spatial.ErrInvalidLooseness},
+		{spatial.WithLooseness(math.Inf(1)), spatial.ErrInvalidLooseness},
+		{spatial.WithLooseCapacity(0), spatial.ErrInvalidCapacity},
+		{spatial.WithLooseMaxDepth(-1), spatial.ErrInvalidMaxDepth},
+	} {
+		if _, err := spatial.NewLooseQuadTree(spatialBounds1000, tc.opt); !errors.Is(err, tc.want) {
+			t.Errorf("expected %v, got %v", tc.want, err)
+		}
+	}
+	if _, err := spatial.NewLooseQuadTree(spatial.Bounds{Width: 1}); !errors.Is(err, spatial.ErrInvalidBounds) {
+		t.Errorf("expected ErrInvalidBounds, got %v", err)
+	}
+	lt, _ := spatial.NewLooseQuadTree(spatialBounds1000)
+	for _, r := range []float64{-1, math.NaN(), math.Inf(1)} {
+		if lt.Insert(spatial.Point{X: 1, Y: 1}, r, nil) {
+			t.Errorf("expected radius %v to be refused", r)
+		}
+	}
+	if lt.Insert(spatial.Point{X: -1, Y: 1}, 1, nil) || lt.Count() != 0 {
+		t.Error("expected a center outside the bounds to be refused")
+	}
+}
+
+// BenchmarkLooseQuadTreeSearch queries 100,000 circles of up to 60 across,
+// centred on each distribution's points
+func BenchmarkLooseQuadTreeSearch(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		lt, _ := spatial.NewLooseQuadTree(distBounds, spatial.WithLooseCapacity(10))
+		for i, p := range points {
+			lt.Insert(p, float64(i%31), i)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p := points[(i*7919)%len(points)]
+			lt.Search(spatial.Bounds{X: p.X - 50, Y: p.Y - 50, Width: 100, Height: 100})
+		}
+	})
+}
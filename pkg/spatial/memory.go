@@ -0,0 +1,92 @@
+package spatial
+
+import "reflect"
+
+// MemStats estimates the heap a tree uses, from its shape and the sizes of
+// its structs. Backing arrays are counted by capacity and maps by their
+// slot tables. What Data points to is not counted, and neither are nodes
+// only reachable from snapshots or retained versions.
+type MemStats struct {
+	Nodes       int
+	LeafLen     int   // Points stored in leaves
+	LeafCap     int   // Room in the leaves' backing arrays
+	NodeBytes   int64 // Node structs
+	PointBytes  int64 // Leaf point arrays
+	IDBytes     int64 // ID index: map, locations and ID strings
+	KeyBytes    int64 // Data-key index: map, key strings and point lists
+	FilterBytes int64 // Existence filter counters
+	ArenaBytes  int64 // Arena room not used by any leaf
+	TotalBytes  int64
+}
+
+var (
+	nodeBytes     = int64(reflect.TypeOf(Node{}).Size())
+	pointBytes    = int64(reflect.TypeOf(Point{}).Size())
+	locationBytes = int64(reflect.TypeOf(location{}).Size())
+	stringBytes   = int64(reflect.TypeOf("").Size())
+	sliceBytes    = int64(reflect.TypeOf([]Point(nil)).Size())
+	pointerBytes  = int64(reflect.TypeOf((*location)(nil)).Size())
+)
+
+// MemoryStats walks the tree once and estimates its memory use. It only
+// takes the read lock, so it can run alongside searches.
+func (qt *QuadTree) MemoryStats() MemStats {
+	qt.rlockAll()
+	defer qt.runlockAll()
+	var m MemStats
+	qt.Root.collectMemory(&m)
+	qt.indexMemory(&m)
+	return m
+}
+
+func (n *Node) collectMemory(m *MemStats) {
+	m.addNode(n)
+	if n.Children[0] != nil {
+		for i := 0; i < 4; i++ {
+			n.Children[i].collectMemory(m)
+		}
+	}
+}
+
+// addNode counts n itself and its leaf arrays
+func (m *MemStats) addNode(n *Node) {
+	m.Nodes++
+	m.LeafLen += len(n.Points)
+	m.LeafCap += cap(n.Points)
+	points := int64(cap(n.Points)) * pointBytes
+	m.NodeBytes += nodeBytes
+	m.PointBytes += points
+	m.TotalBytes += nodeBytes + points
+}
+
+// indexMemory adds the ID and data-key indexes, the existence filter and the
+// arena's spare room to m. Callers must hold the lock.
+func (qt *QuadTree) indexMemory(m *MemStats) {
+	if len(qt.ids) > 0 {
+		m.IDBytes = mapBytes(len(qt.ids), stringBytes+pointerBytes)
+		for id := range qt.ids {
+			m.IDBytes += locationBytes + int64(len(id))
+		}
+	}
+	if len(qt.byKey) > 0 {
+		m.KeyBytes = mapBytes(len(qt.byKey), stringBytes+sliceBytes)
+		for key, points := range qt.byKey {
+			m.KeyBytes += int64(len(key)) + int64(cap(points))*pointBytes
+		}
+	}
+	if qt.filter != nil {
+		m.FilterBytes = int64(cap(qt.filter.counters))
+	}
+	m.ArenaBytes = qt.Root.arena.spareBytes()
+	m.TotalBytes += m.IDBytes + m.KeyBytes + m.FilterBytes + m.ArenaBytes
+}
+
+// mapBytes estimates a map of n entries of entry bytes each: slots come in
+// powers of two, are kept at most 7/8 full and carry a control byte apiece
+func mapBytes(n int, entry int64) int64 {
+	slots := int64(8)
+	for slots*7/8 < int64(n) {
+		slots *= 2
+	}
+	return slots * (entry + 1)
+}
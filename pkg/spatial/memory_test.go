@@ -0,0 +1,64 @@
+package spatial
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// TestMemoryStatsCounts tests the counted parts of the estimate on a small tree
+func TestMemoryStatsCounts(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithDataKey(orderKey))
+	qt.Insert(Point{X: 10, Y: 10})
+	qt.InsertWithID("a", Point{X: 90, Y: 10, Data: order{ID: "1"}})
+	qt.InsertWithID("b", Point{X: 10, Y: 90, Data: order{ID: "2"}})
+
+	m := qt.MemoryStats()
+	if m.Nodes != 5 || m.LeafLen != 3 || m.LeafCap < 3 {
+		t.Errorf("Nodes, LeafLen, LeafCap = %d, %d, %d, want 5, 3, >=3", m.Nodes, m.LeafLen, m.LeafCap)
+	}
+	if m.NodeBytes != 5*nodeBytes || m.IDBytes == 0 || m.KeyBytes == 0 {
+		t.Errorf("Unexpected byte counts: %+v", m)
+	}
+	if m.TotalBytes != m.NodeBytes+m.PointBytes+m.IDBytes+m.KeyBytes {
+		t.Errorf("TotalBytes %d is not the sum of its parts: %+v", m.TotalBytes, m)
+	}
+	if s := qt.Stats(); s.Memory != m {
+		t.Errorf("Stats().Memory = %+v, want %+v", s.Memory, m)
+	}
+}
+
+// TestMemoryStatsMatchesHeap tests the estimate against the heap growth of building a large tree
+func TestMemoryStatsMatchesHeap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a 200k point tree")
+	}
+	const n = 200000
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("courier-%d", i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8))
+	for i := 0; i < n; i++ {
+		qt.InsertWithID(ids[i], Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	// The ID strings were allocated before the tree, so they are not part of the heap growth
+	estimate := qt.MemoryStats().TotalBytes
+	for _, id := range ids {
+		estimate -= int64(len(id))
+	}
+	grown := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	if ratio := float64(estimate) / float64(grown); ratio < 0.75 || ratio > 1.25 {
+		t.Errorf("Estimated %d bytes, heap grew by %d (ratio %.2f)", estimate, grown, ratio)
+	}
+	runtime.KeepAlive(qt)
+}
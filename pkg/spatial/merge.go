@@ -0,0 +1,47 @@
+package spatial
+
+// afterRemove runs on a leaf that has just lost a point. It fixes the subtree
+// counts up to the root and collapses the highest ancestor whose subtree now
+// fits in a single leaf. Merging only when the split policy would not split
+// the merged leaf means a merge never fights a later insert. A non-nil stop
+// ends the walk below it, leaving stop and its ancestors to the caller.
+func (n *Node) afterRemove(stop *Node) {
+	n.count--
+	var top *Node
+	for a := n.parent; a != nil && a != stop; a = a.parent {
+		a.count--
+		if !a.splits(a.count) {
+			top = a
+		}
+	}
+	if top != nil {
+		top.collapse()
+	}
+}
+
+// collapse pulls every point in the subtree up into n and drops its children
+func (n *Node) collapse() {
+	n.reserve(max(min(n.Capacity, maxReserve), n.count))
+	points := n.Points
+	n.collectPoints(&points)
+	n.releaseChildren()
+	n.Children = [4]*Node{}
+	n.setPoints(points)
+	for _, p := range points {
+		if p.loc != nil {
+			p.loc.leaf = n
+		}
+	}
+	n.hooks.emit(event{kind: mergeEvent, bounds: n.Bounds})
+}
+
+// collectPoints appends every point stored in the subtree to dst
+func (n *Node) collectPoints(dst *[]Point) {
+	if n.Children[0] != nil {
+		for i := 0; i < 4; i++ {
+			n.Children[i].collectPoints(dst)
+		}
+		return
+	}
+	*dst = append(*dst, n.Points...)
+}
@@ -0,0 +1,127 @@
+package spatial
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestRemoveCollapsesUnderfullSubtree tests that the tree shallows out after most points are removed
+func TestRemoveCollapsesUnderfullSubtree(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(8))
+
+	points := make([]Point, 0)
+	for i := 0; i < 200; i++ {
+		p := Point{X: float64(i*37%100) + 0.5, Y: float64(i*53%100) + 0.5, Data: i}
+		points = append(points, p)
+		qt.Insert(p)
+	}
+	before := qt.Stats()
+	if before.MaxDepth < 2 {
+		t.Fatalf("Test setup: expected a subdivided tree, got depth %d", before.MaxDepth)
+	}
+
+	for _, p := range points[:195] {
+		if !qt.Remove(p) {
+			t.Fatalf("Failed to remove %v", p.Data)
+		}
+	}
+
+	after := qt.Stats()
+	if after.Nodes != 1 || after.MaxDepth != 0 {
+		t.Errorf("Expected the tree to collapse to a single leaf, got %d nodes at depth %d", after.Nodes, after.MaxDepth)
+	}
+	if after.Points != 5 || len(qt.Root.Points) != 5 {
+		t.Errorf("Expected 5 points in the root leaf, got %d", len(qt.Root.Points))
+	}
+}
+
+// TestMergeKeepsQueryResults tests that Search and KNearest agree with a never-merged reference
+func TestMergeKeepsQueryResults(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	merged := mustNewQuadTree(bounds, WithCapacity(4))
+	rng := rand.New(rand.NewSource(3))
+
+	kept := make([]Point, 0)
+	for i := 0; i < 300; i++ {
+		p := Point{X: rng.Float64() * 100, Y: rng.Float64() * 100, Data: i}
+		merged.Insert(p)
+		if i%10 == 0 {
+			kept = append(kept, p)
+		}
+	}
+	for _, p := range merged.Search(bounds) {
+		if p.Data.(int)%10 != 0 {
+			merged.Remove(p)
+		}
+	}
+
+	reference := mustNewQuadTree(bounds, WithCapacity(1000))
+	for _, p := range kept {
+		reference.Insert(p)
+	}
+
+	for i := 0; i < 50; i++ {
+		area := Bounds{X: rng.Float64() * 80, Y: rng.Float64() * 80, Width: 20, Height: 20}
+		if a, b := len(merged.Search(area)), len(reference.Search(area)); a != b {
+			t.Fatalf("Search %v: merged tree found %d, reference found %d", area, a, b)
+		}
+
+		target := Point{X: rng.Float64() * 100, Y: rng.Float64() * 100}
+		got := merged.KNearest(target, 3)
+		want := reference.KNearest(target, 3)
+		for j := range want {
+			if got[j].Data != want[j].Data {
+				t.Fatalf("KNearest %v: result %d is %v, expected %v", target, j, got[j].Data, want[j].Data)
+			}
+		}
+	}
+}
+
+// TestMergeKeepsHandlesAndIDs tests that tracked points stay reachable after their leaf is merged away
+func TestMergeKeepsHandlesAndIDs(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+
+	e := qt.InsertEntry(Point{X: 10, Y: 10, Data: "entry"})
+	qt.InsertWithID("id", Point{X: 90, Y: 90, Data: "id"})
+	extra := make([]Point, 0)
+	for i := 0; i < 10; i++ {
+		p := Point{X: float64(20 + i*5), Y: float64(20 + i*5), Data: fmt.Sprint(i)}
+		extra = append(extra, p)
+		qt.Insert(p)
+	}
+	for _, p := range extra {
+		qt.Remove(p)
+	}
+
+	if qt.Root.Children[0] != nil {
+		t.Fatal("Expected the root to collapse")
+	}
+	if p, ok := e.Point(); !ok || p.Data != "entry" {
+		t.Errorf("Handle lost its point after merge: %v %v", p, ok)
+	}
+	if err := qt.UpdateByID("id", Point{X: 50, Y: 50, Data: "moved"}); err != nil {
+		t.Errorf("UpdateByID after merge failed: %v", err)
+	}
+	if !qt.RemoveEntry(e) || !qt.RemoveByID("id") {
+		t.Error("Tracked points should be removable after merge")
+	}
+	if qt.Size() != 0 {
+		t.Errorf("Expected empty tree, got %d", qt.Size())
+	}
+}
+
+// TestMergeLeavesDepthCappedLeafAlone tests that overfull leaves at the depth cap are not merged upward
+func TestMergeLeavesDepthCappedLeafAlone(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithMaxDepth(3))
+
+	for i := 0; i < 6; i++ {
+		qt.Insert(Point{X: 1, Y: 1, Data: i})
+	}
+	qt.Remove(Point{X: 1, Y: 1})
+
+	s := qt.Stats()
+	if s.Points != 5 || s.MaxDepth != 3 {
+		t.Errorf("Expected 5 points still in the depth-capped leaf, got %+v", s)
+	}
+}
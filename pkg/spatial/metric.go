@@ -0,0 +1,256 @@
+package spatial
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Metric measures distance for KNearest, Nearest, SearchRadius and Farthest.
+// MinDistToBounds must never exceed Distance from p to any point of b, or
+// pruning will silently drop points that belong in the results.
+type Metric interface {
+	Distance(a, b Point) float64
+	MinDistToBounds(p Point, b Bounds) float64
+}
+
+// Euclidean is straight-line planar distance. It is the default.
+type Euclidean struct{}
+
+func (Euclidean) Distance(a, b Point) float64 {
+	return Distance(a, b)
+}
+
+func (Euclidean) MinDistToBounds(p Point, b Bounds) float64 {
+	return math.Sqrt(minDistanceSquared(b, p))
+}
+
+// Manhattan is L1 (taxicab) distance, for street grids aligned with the axes
+type Manhattan struct{}
+
+func (Manhattan) Distance(a, b Point) float64 {
+	return math.Abs(a.X-b.X) + math.Abs(a.Y-b.Y)
+}
+
+func (Manhattan) MinDistToBounds(p Point, b Bounds) float64 {
+	dx := math.Max(0, math.Max(b.X-p.X, p.X-(b.X+b.Width)))
+	dy := math.Max(0, math.Max(b.Y-p.Y, p.Y-(b.Y+b.Height)))
+	return dx + dy
+}
+
+// Haversine is great-circle distance in meters, with X as longitude and Y as
+// latitude in degrees
+type Haversine struct{}
+
+func (Haversine) Distance(a, b Point) float64 {
+	return haversine(a, b)
+}
+
+// MinDistToBounds finds the nearest point of b on the sphere. For any
+// latitude, distance grows with the longitude gap, so the nearest point lies
+// on p's own meridian when b spans it and on b's nearer meridian edge
+// otherwise. Along that meridian distance has one minimum, at the foot of
+// the perpendicular from p, so only the foot and the two corners are tried.
+// The result is shaved slightly so rounding can't lift it above a point's
+// own distance.
+func (Haversine) MinDistToBounds(p Point, b Bounds) float64 {
+	if b.Contains(p) {
+		return 0
+	}
+	lon := p.X
+	if p.X < b.X || p.X > b.X+b.Width {
+		west, east := lonGap(p.X, b.X), lonGap(p.X, b.X+b.Width)
+		lon = b.X
+		if east < west {
+			lon = b.X + b.Width
+		}
+	}
+
+	d := math.Min(haversine(p, Point{X: lon, Y: b.Y}), haversine(p, Point{X: lon, Y: b.Y + b.Height}))
+	lat, dLon := p.Y*math.Pi/180, lonGap(p.X, lon)*math.Pi/180
+	foot := math.Atan2(math.Sin(lat), math.Cos(lat)*math.Cos(dLon)) * 180 / math.Pi
+	if foot > b.Y && foot < b.Y+b.Height {
+		d = math.Min(d, haversine(p, Point{X: lon, Y: foot}))
+	}
+	return d * (1 - 1e-9)
+}
+
+// lonGap is the angle in degrees between two longitudes, going the short way
+// round
+func lonGap(a, b float64) float64 {
+	gap := math.Mod(math.Abs(a-b), 360)
+	return math.Min(gap, 360-gap)
+}
+
+// WithMetric ranks KNearest, Nearest and Farthest and bounds SearchRadius by
+// m instead of Euclidean distance. Without it a tree built with
+// WithGeoCoordinates measures with Haversine.
+func WithMetric(m Metric) Option {
+	return func(qt *QuadTree) {
+		qt.metric = m
+	}
+}
+
+// distanceMetric returns the metric queries measure with
+func (qt *QuadTree) distanceMetric() Metric {
+	switch {
+	case qt.metric != nil:
+		return qt.metric
+	case qt.geo:
+		return Haversine{}
+	}
+	return Euclidean{}
+}
+
+// Nearest returns the point closest to target, ranked like KNearest, and
+// false if the tree holds no live point
+func (qt *QuadTree) Nearest(target Point) (Point, bool) {
+	found := qt.KNearest(target, 1)
+	if len(found) == 0 {
+		return Point{}, false
+	}
+	return found[0], true
+}
+
+// SearchRadius returns every point within radius of center under the tree's
+// metric, in no particular order
+func (qt *QuadTree) SearchRadius(center Point, radius float64) []Point {
+	if view := qt.view(); view != nil {
+		return view.searchRadius(center, radius)
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.searchRadius(center, radius)
+}
+
+// searchRadius is SearchRadius for callers that hold the lock
+func (qt *QuadTree) searchRadius(center Point, radius float64) []Point {
+	results := make([]Point, 0)
+	if qt.Root == nil || !(radius >= 0) {
+		return results
+	}
+	qt.Root.withinMetric(center, radius, qt.distanceMetric(), &results)
+	qt.dropExpired(&results, 0)
+	return results
+}
+
+// withinMetric appends the points of n's subtree within radius of center,
+// skipping subtrees whose bounds are already too far
+func (n *Node) withinMetric(center Point, radius float64, m Metric, results *[]Point) {
+	if m.MinDistToBounds(center, n.Bounds) > radius {
+		return
+	}
+	if n.Children[0] != nil {
+		for _, c := range n.Children {
+			c.withinMetric(center, radius, m, results)
+		}
+		return
+	}
+	for _, p := range n.Points {
+		if m.Distance(center, p) <= radius {
+			*results = append(*results, p)
+		}
+	}
+}
+
+// Farthest returns the point furthest from target under the tree's metric,
+// the earliest inserted on ties, and false if the tree holds no live point.
+// A Metric only bounds distances from below, so every point is visited.
+func (qt *QuadTree) Farthest(target Point) (Point, bool) {
+	if view := qt.view(); view != nil {
+		return view.farthest(target)
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.farthest(target)
+}
+
+// farthest is Farthest for callers that hold the lock
+func (qt *QuadTree) farthest(target Point) (Point, bool) {
+	if qt.Root == nil {
+		return Point{}, false
+	}
+	all := make([]Point, 0, qt.Root.count)
+	qt.Root.collectPoints(&all)
+	qt.dropExpired(&all, 0)
+
+	m := qt.distanceMetric()
+	best, bestDist := -1, 0.0
+	for i, p := range all {
+		d := m.Distance(target, p)
+		if best == -1 || d > bestDist || (d == bestDist && p.seq < all[best].seq) {
+			best, bestDist = i, d
+		}
+	}
+	if best == -1 {
+		return Point{}, false
+	}
+	return all[best], true
+}
+
+// kNearestMetric appends the k points nearest target under m to dst. It
+// visits nodes nearest first and stops once no node left can hold anything
+// closer than the current kth point. Callers must hold the lock.
+func (qt *QuadTree) kNearestMetric(target Point, k int, m Metric, dst []Point) []Point {
+	if qt.Root == nil {
+		return dst
+	}
+	now := int64(0)
+	if qt.expiring {
+		now = qt.clock().UnixNano()
+	}
+
+	best := make([]PointWithDistance, 0, k+1)
+	queue := metricQueue{{node: qt.Root, dist: m.MinDistToBounds(target, qt.Root.Bounds)}}
+	for queue.Len() > 0 {
+		item := heap.Pop(&queue).(metricNode)
+		// Ties are kept, so equally distant points still rank by insertion order
+		if len(best) == k && item.dist > best[k-1].Distance {
+			break
+		}
+		n := item.node
+		if n.Children[0] != nil {
+			for _, c := range n.Children {
+				heap.Push(&queue, metricNode{node: c, dist: m.MinDistToBounds(target, c.Bounds)})
+			}
+			continue
+		}
+		for _, p := range n.Points {
+			if now != 0 && expired(p, now) {
+				continue
+			}
+			d := m.Distance(target, p)
+			if len(best) == k && d > best[k-1].Distance {
+				continue
+			}
+			best = append(best, PointWithDistance{Point: p, Distance: d})
+			sortByDistance(best)
+			if len(best) > k {
+				best = best[:k]
+			}
+		}
+	}
+
+	for _, pd := range best {
+		dst = append(dst, pd.Point)
+	}
+	return dst
+}
+
+type metricNode struct {
+	node *Node
+	dist float64
+}
+
+// metricQueue is a min-heap of nodes by distance under a Metric
+type metricQueue []metricNode
+
+func (q metricQueue) Len() int            { return len(q) }
+func (q metricQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q metricQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *metricQueue) Push(x interface{}) { *q = append(*q, x.(metricNode)) }
+func (q *metricQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,164 @@
+package spatial
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// metricCases pairs each metric with coordinates it makes sense for
+var metricCases = []struct {
+	name   string
+	metric Metric
+	bounds Bounds
+}{
+	{"Euclidean", Euclidean{}, Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}},
+	{"Manhattan", Manhattan{}, Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}},
+	{"Haversine", Haversine{}, Bounds{X: -180, Y: -90, Width: 360, Height: 180}},
+}
+
+// randomIn returns a point inside b, on a coarse grid so distances tie often
+func randomIn(rng *rand.Rand, b Bounds) Point {
+	return Point{X: b.X + float64(rng.Intn(200))*b.Width/200, Y: b.Y + float64(rng.Intn(200))*b.Height/200}
+}
+
+// TestMinDistToBoundsIsLowerBound tests that no point of a box is closer than
+// the metric's bound for it
+func TestMinDistToBoundsIsLowerBound(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range metricCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 2000; i++ {
+				corner := Point{X: tc.bounds.X + rng.Float64()*tc.bounds.Width, Y: tc.bounds.Y + rng.Float64()*tc.bounds.Height}
+				box := Bounds{
+					X:      corner.X,
+					Y:      corner.Y,
+					Width:  rng.Float64() * (tc.bounds.X + tc.bounds.Width - corner.X),
+					Height: rng.Float64() * (tc.bounds.Y + tc.bounds.Height - corner.Y),
+				}
+				p := Point{X: tc.bounds.X + rng.Float64()*tc.bounds.Width, Y: tc.bounds.Y + rng.Float64()*tc.bounds.Height}
+				bound := tc.metric.MinDistToBounds(p, box)
+				for j := 0; j < 50; j++ {
+					q := Point{X: box.X + rng.Float64()*box.Width, Y: box.Y + rng.Float64()*box.Height}
+					if d := tc.metric.Distance(p, q); d < bound {
+						t.Fatalf("MinDistToBounds(%v, %v) = %v, but %v is at %v", p, box, bound, q, d)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestMetricQueriesMatchBruteForce tests KNearest, Nearest, SearchRadius and
+// Farthest on the tree and its frozen copy against a scan of every point
+func TestMetricQueriesMatchBruteForce(t *testing.T) {
+	for _, tc := range metricCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(2))
+			qt := mustNewQuadTree(tc.bounds, WithCapacity(4), WithMetric(tc.metric))
+			var all []Point
+			for i := 0; i < 400; i++ {
+				p := randomIn(rng, tc.bounds)
+				p.Data = i
+				qt.Insert(p)
+				all = append(all, p)
+			}
+			frozen := qt.Freeze()
+
+			for i := 0; i < 100; i++ {
+				target := randomIn(rng, tc.bounds)
+				byDist := append([]Point(nil), all...)
+				sort.SliceStable(byDist, func(a, b int) bool {
+					return tc.metric.Distance(target, byDist[a]) < tc.metric.Distance(target, byDist[b])
+				})
+
+				k := 1 + rng.Intn(20)
+				checkSameOrder(t, "KNearest", qt.KNearest(target, k), byDist[:k])
+				checkSameOrder(t, "FrozenTree.KNearest", frozen.KNearest(target, k), byDist[:k])
+				if got, ok := qt.Nearest(target); !ok || got.Data != byDist[0].Data {
+					t.Fatalf("Nearest(%v) = %v, %v, want %v", target, got, ok, byDist[0])
+				}
+
+				// Earliest inserted on ties: the first of the stable sort's last group
+				wantFar := byDist[len(byDist)-1]
+				for j := len(byDist) - 2; j >= 0 && tc.metric.Distance(target, byDist[j]) == tc.metric.Distance(target, wantFar); j-- {
+					wantFar = byDist[j]
+				}
+				if got, ok := qt.Farthest(target); !ok || got.Data != wantFar.Data {
+					t.Fatalf("Farthest(%v) = %v, %v, want %v", target, got, ok, wantFar)
+				}
+
+				radius := tc.metric.Distance(target, byDist[rng.Intn(len(byDist))])
+				var want []Point
+				for _, p := range byDist {
+					if tc.metric.Distance(target, p) <= radius {
+						want = append(want, p)
+					}
+				}
+				checkSameSet(t, "SearchRadius", qt.SearchRadius(target, radius), want)
+				checkSameSet(t, "FrozenTree.SearchRadius", frozen.SearchRadius(target, radius), want)
+			}
+		})
+	}
+}
+
+func checkSameOrder(t *testing.T, name string, got, want []Point) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s returned %d points, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Data != want[i].Data {
+			t.Fatalf("%s[%d] = %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func checkSameSet(t *testing.T, name string, got, want []Point) {
+	t.Helper()
+	seen := make(map[interface{}]bool, len(got))
+	for _, p := range got {
+		seen[p.Data] = true
+	}
+	if len(got) != len(want) || len(seen) != len(want) {
+		t.Fatalf("%s returned %d points, want %d", name, len(got), len(want))
+	}
+	for _, p := range want {
+		if !seen[p.Data] {
+			t.Fatalf("%s is missing %v", name, p)
+		}
+	}
+}
+
+// TestMetricDefaults tests that trees without WithMetric keep their ranking
+// and that geographic trees measure SearchRadius and Farthest in meters
+func TestMetricDefaults(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.Insert(Point{X: 10, Y: 10})
+	qt.Insert(Point{X: 13, Y: 14})
+	if got := qt.SearchRadius(Point{X: 10, Y: 10}, 5); len(got) != 2 {
+		t.Errorf("Expected the Euclidean radius 5 to reach both points, got %v", got)
+	}
+	if got, _ := qt.Farthest(Point{X: 10, Y: 10}); got.X != 13 {
+		t.Errorf("Expected Farthest to be (13, 14), got %v", got)
+	}
+
+	geo := mustNewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180}, WithGeoCoordinates())
+	paris, london := Point{X: 2.3522, Y: 48.8566}, Point{X: -0.1276, Y: 51.5072}
+	geo.Insert(paris)
+	geo.Insert(london)
+	if got := geo.SearchRadius(paris, 300000); len(got) != 1 {
+		t.Errorf("Expected only Paris within 300 km of Paris, got %v", got)
+	}
+	if got := geo.SearchRadius(paris, 400000); len(got) != 2 {
+		t.Errorf("Expected London within 400 km of Paris, got %v", got)
+	}
+
+	empty := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithMetric(Manhattan{}))
+	if _, ok := empty.Nearest(Point{}); ok {
+		t.Error("Expected Nearest on an empty tree to report false")
+	}
+	if _, ok := empty.Farthest(Point{}); ok {
+		t.Error("Expected Farthest on an empty tree to report false")
+	}
+}
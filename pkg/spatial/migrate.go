@@ -0,0 +1,55 @@
+package spatial
+
+import "fmt"
+
+// A migration upgrades an encoding in one format to the next as Load reads
+// it: header adjusts the decoded header, and record rewrites each record
+// body, after the content hash has seen it as written. Either may be nil.
+// migrations[v] upgrades format v to v+1, so an encoding in any older
+// format reaches encodingVersion through the chain. Changing the encoding
+// means bumping encodingVersion and adding the step from the format before.
+type migration struct {
+	header func(h *encodingHeader)
+	record func(b []byte) ([]byte, error)
+}
+
+var migrations = map[uint16]migration{
+	// Format 2 added the content hash after the records
+	1: {header: func(h *encodingHeader) { h.unhashed = true }},
+}
+
+// migrate upgrades h, read from an encoding in format h.version, and returns
+// the record rewrites to apply, oldest first. It returns an error wrapping
+// ErrCorruptEncoding if a step is missing.
+func migrate(h *encodingHeader) ([]func([]byte) ([]byte, error), error) {
+	var steps []func([]byte) ([]byte, error)
+	for v := h.version; v < encodingVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return nil, corrupt("no migration from format %d", v)
+		}
+		if m.header != nil {
+			m.header(h)
+		}
+		if m.record != nil {
+			steps = append(steps, m.record)
+		}
+	}
+	return steps, nil
+}
+
+// FormatError reports input written by a newer version of the package, in a
+// format this one can't read. It matches ErrNewerFormat.
+type FormatError struct {
+	Kind      string // What was being read, such as "tree encoding"
+	Format    uint16 // Format of the input
+	Supported uint16 // Newest format this version reads
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("spatial: %s format %d is newer than this version reads (up to %d)", e.Kind, e.Format, e.Supported)
+}
+
+func (e *FormatError) Unwrap() error {
+	return ErrNewerFormat
+}
@@ -0,0 +1,102 @@
+package spatial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadFormat1Fixture tests that a snapshot file written in format 1,
+// before compression and content hashes, still loads
+func TestLoadFormat1Fixture(t *testing.T) {
+	const fixture = "testdata/drivers-v1.snap"
+	qt, err := LoadFromFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qt.Size() != 6 || qt.Version() != 6 || !qt.geo {
+		t.Fatalf("Expected 6 geo points at version 6, got %d at %d", qt.Size(), qt.Version())
+	}
+	if p, ok := qt.GetByID("van2"); !ok || p.X != 72.8777 || p.Data != "Mumbai" {
+		t.Errorf("Expected van2 in Mumbai, got %+v", p)
+	}
+	if got := qt.Search(Bounds{X: 2, Y: 48, Width: 1, Height: 1}); len(got) != 2 {
+		t.Errorf("Expected both depots, got %v", got)
+	}
+	f, _ := os.Open(fixture)
+	defer f.Close()
+	if err := VerifySnapshot(f); err != nil {
+		t.Errorf("Expected the fixture to verify, got %v", err)
+	}
+
+	// A saved copy is upgraded to the current format
+	path := filepath.Join(t.TempDir(), "drivers.snap")
+	qt.SaveToFile(path)
+	upgraded, err := LoadFromFile(path)
+	if err != nil || upgraded.ContentHash() != qt.ContentHash() {
+		t.Errorf("Expected the upgraded copy to match, got %v", err)
+	}
+}
+
+// TestMigrationRewritesRecords tests that a registered step sees every record
+func TestMigrationRewritesRecords(t *testing.T) {
+	saved := migrations[1]
+	defer func() { migrations[1] = saved }()
+	m := saved
+	m.record = func(b []byte) ([]byte, error) {
+		p, id, hasID, err := parseRecord(b, GobCodec{})
+		if err != nil {
+			return nil, err
+		}
+		return appendRecord(nil, p, strings.ToUpper(id), hasID, GobCodec{})
+	}
+	migrations[1] = m
+
+	qt, err := LoadFromFile("testdata/drivers-v1.snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := qt.GetByID("VAN1"); !ok || qt.Size() != 6 {
+		t.Errorf("Expected migrated ids, got %d points", qt.Size())
+	}
+}
+
+// TestNewerFormat tests that input from a newer version is refused with a
+// FormatError rather than misread
+func TestNewerFormat(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.InsertWithID("a", Point{X: 1, Y: 1})
+	var buf bytes.Buffer
+	qt.WriteTo(&buf)
+	encoding := buf.Bytes()
+	binary.LittleEndian.PutUint16(encoding[len(encodingMagic):], encodingVersion+1)
+
+	var formatErr *FormatError
+	if _, err := Load(bytes.NewReader(encoding)); !errors.As(err, &formatErr) || formatErr.Format != encodingVersion+1 {
+		t.Errorf("Expected a FormatError from Load, got %v", err)
+	}
+	if err := VerifySnapshot(bytes.NewReader(encoding)); !errors.Is(err, ErrNewerFormat) {
+		t.Errorf("Expected a FormatError from VerifySnapshot, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "drivers.snap")
+	header := binary.LittleEndian.AppendUint16([]byte(fileMagic), fileVersion+1)
+	header = binary.LittleEndian.AppendUint64(header, 1)
+	header = binary.LittleEndian.AppendUint32(append(header, 0), crc32.ChecksumIEEE(header))
+	os.WriteFile(path, header, 0o644)
+	if _, err := LoadFromFile(path); !errors.Is(err, ErrNewerFormat) {
+		t.Errorf("Expected a FormatError from LoadFromFile, got %v", err)
+	}
+
+	var delta bytes.Buffer
+	qt.SaveDelta(&delta, qt.Version())
+	binary.LittleEndian.PutUint16(delta.Bytes()[len(deltaMagic):], deltaVersion+1)
+	if err := qt.ApplyDelta(&delta); !errors.Is(err, ErrNewerFormat) {
+		t.Errorf("Expected a FormatError from ApplyDelta, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package spatial
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of f, where mmap isn't available
+func mapFile(f *os.File, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(f, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func unmapFile([]byte) error {
+	return nil
+}
@@ -0,0 +1,254 @@
+package spatial
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Searcher is the read-only query surface shared by QuadTree, FrozenTree,
+// ReadView and MortonIndex, so callers can swap one for another
+type Searcher interface {
+	Search(area Bounds) []Point
+	KNearest(target Point, k int) []Point
+}
+
+var (
+	_ Searcher = (*QuadTree)(nil)
+	_ Searcher = (*FrozenTree)(nil)
+	_ Searcher = ReadView{}
+	_ Searcher = (*MortonIndex)(nil)
+)
+
+// mortonLevels is how many quadrant digits a Morton code holds, which
+// divides the bounds into a 2^24 x 2^24 grid of cells
+const mortonLevels = 24
+
+// mortonScan is the range length below which a query filters points one by
+// one instead of splitting the cell further
+const mortonScan = 32
+
+// MortonIndex is a static linear quadtree: every point is keyed by the
+// Z-order (Morton) code of the cell it falls in, and the points are kept in
+// one slice sorted by that code. A quadtree cell at any level is then a
+// contiguous range of the slice, found by binary search, so queries need no
+// nodes at all. The quadrant digits are the ones QuadTree routes by, so the
+// cells line up with a QuadTree's nodes over the same bounds.
+//
+// WithHilbertCurve orders the points along a Hilbert curve instead, over
+// the same cells.
+//
+// It is immutable and has no lock, so any number of goroutines may query it.
+// Distances are planar.
+type MortonIndex struct {
+	bounds  Bounds
+	hilbert bool
+	codes   []uint64 // Sorted; codes[i] is the code of points[i]
+	points  []Point
+}
+
+// MortonOption configures a MortonIndex built with NewMortonIndex
+type MortonOption func(*MortonIndex)
+
+// WithHilbertCurve keys points by the Hilbert index of their cell rather
+// than its Morton code. Every cell is still one range of the slice, but
+// consecutive cells always touch, where Z-order jumps across the bounds at
+// quadrant boundaries, so the points a query reads sit closer together.
+func WithHilbertCurve() MortonOption {
+	return func(m *MortonIndex) {
+		m.hilbert = true
+	}
+}
+
+// NewMortonIndex builds an index of points covering bounds. Bounds must be
+// valid and have a positive area. Points outside bounds or with invalid
+// coordinates are reported in a *RejectedPointsError; the returned index
+// still holds every other point. Ties in KNearest rank by input order.
+func NewMortonIndex(bounds Bounds, points []Point, opts ...MortonOption) (*MortonIndex, error) {
+	if err := bounds.Validate(); err != nil {
+		return nil, err
+	}
+	if bounds.Width == 0 || bounds.Height == 0 {
+		return nil, ErrInvalidBounds
+	}
+	m := &MortonIndex{bounds: bounds}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	keys := make([]bulkKey, 0, len(points))
+	var rejected []Point
+	for i, p := range points {
+		if !validCoordinates(p) || !bounds.Contains(p) {
+			rejected = append(rejected, p)
+			continue
+		}
+		code := bounds.quadrantPath(p, mortonLevels)
+		if m.hilbert {
+			code = hilbertFromPath(code, mortonLevels)
+		}
+		keys = append(keys, bulkKey{code: code, idx: int32(i)})
+	}
+	radixSortKeys(keys, 2*mortonLevels)
+
+	m.codes = make([]uint64, len(keys))
+	m.points = make([]Point, len(keys))
+	load := &bulkLoad{points: points, assignSeq: true}
+	for i, k := range keys {
+		m.codes[i] = k.code
+		m.points[i] = load.point(k.idx)
+	}
+	if len(rejected) > 0 {
+		return m, &RejectedPointsError{Points: rejected}
+	}
+	return m, nil
+}
+
+// Bounds returns the area covered by the index
+func (m *MortonIndex) Bounds() Bounds {
+	return m.bounds
+}
+
+// Count returns how many points the index holds
+func (m *MortonIndex) Count() int {
+	return len(m.points)
+}
+
+// mortonCell is a quadtree cell and the range of points inside it
+type mortonCell struct {
+	bounds Bounds
+	prefix uint64 // Digits along the curve from the root down to the cell
+	state  uint8  // The Hilbert curve's orientation in the cell
+	level  int
+	lo, hi int
+}
+
+// children splits c into its four quadrants, in curve order, with the same
+// arithmetic as quadrantPath
+func (m *MortonIndex) children(c mortonCell) [4]mortonCell {
+	w := c.bounds.Width / 2
+	h := c.bounds.Height / 2
+	shift := uint(2 * (mortonLevels - c.level - 1))
+	var out [4]mortonCell
+	lo := c.lo
+	for digit := uint64(0); digit < 4; digit++ {
+		hi := c.hi
+		if digit < 3 {
+			next := (c.prefix<<2 | (digit + 1)) << shift
+			hi = lo + sort.Search(c.hi-lo, func(i int) bool { return m.codes[lo+i] >= next })
+		}
+		q, state := digit, c.state
+		if m.hilbert {
+			q, state = hilbertQuadrant(c.state, digit)
+		}
+		b := Bounds{X: c.bounds.X, Y: c.bounds.Y, Width: w, Height: h}
+		if q&1 != 0 {
+			b.X += w
+		}
+		if q&2 != 0 {
+			b.Y += h
+		}
+		out[digit] = mortonCell{bounds: b, prefix: c.prefix<<2 | digit, state: state, level: c.level + 1, lo: lo, hi: hi}
+		lo = hi
+	}
+	return out
+}
+
+// root returns the cell covering the whole index
+func (m *MortonIndex) root() mortonCell {
+	return mortonCell{bounds: m.bounds, hi: len(m.points)}
+}
+
+// Search returns every point inside area, edges included, in the index's
+// order
+func (m *MortonIndex) Search(area Bounds) []Point {
+	results := make([]Point, 0)
+	m.search(m.root(), area, &results)
+	return results
+}
+
+// search decomposes area into the Morton ranges of the cells it covers,
+// filtering point by point only in cells it partly overlaps
+func (m *MortonIndex) search(c mortonCell, area Bounds, results *[]Point) {
+	if c.lo == c.hi || !c.bounds.Intersects(area) {
+		return
+	}
+	if area.containsBounds(c.bounds) {
+		*results = append(*results, m.points[c.lo:c.hi]...)
+		return
+	}
+	if c.hi-c.lo <= mortonScan || c.level == mortonLevels {
+		for _, p := range m.points[c.lo:c.hi] {
+			if area.Contains(p) {
+				*results = append(*results, p)
+			}
+		}
+		return
+	}
+	for _, child := range m.children(c) {
+		m.search(child, area, results)
+	}
+}
+
+// KNearest returns the k points closest to target, nearest first, with ties
+// ranked by input order. It visits cells nearest first and stops once no
+// cell left can hold anything closer than the current kth point. Distances
+// are compared squared throughout.
+func (m *MortonIndex) KNearest(target Point, k int) []Point {
+	if k <= 0 || len(m.points) == 0 {
+		return make([]Point, 0)
+	}
+	best := make([]PointWithDistance, 0, k+1)
+	root := m.root()
+	queue := cellQueue{{cell: root, dist: minDistanceSquared(root.bounds, target)}}
+	for queue.Len() > 0 {
+		item := heap.Pop(&queue).(cellDistance)
+		if len(best) == k && item.dist > best[k-1].Distance {
+			break
+		}
+		c := item.cell
+		if c.hi-c.lo > mortonScan && c.level < mortonLevels {
+			for _, child := range m.children(c) {
+				if child.lo < child.hi {
+					heap.Push(&queue, cellDistance{cell: child, dist: minDistanceSquared(child.bounds, target)})
+				}
+			}
+			continue
+		}
+		for _, p := range m.points[c.lo:c.hi] {
+			d := DistanceSquared(target, p)
+			if len(best) == k && d > best[k-1].Distance {
+				continue
+			}
+			best = append(best, PointWithDistance{Point: p, Distance: d})
+			sortByDistance(best)
+			if len(best) > k {
+				best = best[:k]
+			}
+		}
+	}
+
+	results := make([]Point, len(best))
+	for i, pd := range best {
+		results[i] = pd.Point
+	}
+	return results
+}
+
+type cellDistance struct {
+	cell mortonCell
+	dist float64
+}
+
+// cellQueue is a min-heap of cells by distance
+type cellQueue []cellDistance
+
+func (q cellQueue) Len() int            { return len(q) }
+func (q cellQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q cellQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *cellQueue) Push(x interface{}) { *q = append(*q, x.(cellDistance)) }
+func (q *cellQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,203 @@
+package spatial
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// randomPoints returns n points on a coarse grid, so ties and co-located points occur, with Data set to their index
+func randomPoints(n int, seed int64) []Point {
+	rng := rand.New(rand.NewSource(seed))
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = Point{X: float64(rng.Intn(1000)), Y: float64(rng.Intn(1000)), Data: i}
+	}
+	return points
+}
+
+func mustNewMortonIndex(bounds Bounds, points []Point, opts ...MortonOption) *MortonIndex {
+	m, err := NewMortonIndex(bounds, points, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// TestMortonIndexMatchesQuadTree tests that Search and KNearest agree with a QuadTree holding the same points
+func TestMortonIndexMatchesQuadTree(t *testing.T) {
+	t.Run("Morton", func(t *testing.T) { testMortonIndexMatchesQuadTree(t) })
+	t.Run("Hilbert", func(t *testing.T) { testMortonIndexMatchesQuadTree(t, WithHilbertCurve()) })
+}
+
+func testMortonIndexMatchesQuadTree(t *testing.T, opts ...MortonOption) {
+	bounds := Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+	points := randomPoints(5000, 1)
+	qt := mustNewQuadTree(bounds, WithCapacity(8))
+	for _, p := range points {
+		qt.Insert(p)
+	}
+	m := mustNewMortonIndex(bounds, points, opts...)
+	if m.Count() != len(points) || m.Bounds() != bounds {
+		t.Fatalf("Count, Bounds = %d, %v", m.Count(), m.Bounds())
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		area := Bounds{X: rng.Float64()*1100 - 50, Y: rng.Float64()*1100 - 50, Width: rng.Float64() * 300, Height: rng.Float64() * 300}
+		if got, want := dataOf(m.Search(area)), dataOf(qt.Search(area)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Search(%+v) found %d points, QuadTree found %d", area, len(got), len(want))
+		}
+
+		target := Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+		k := 1 + rng.Intn(50)
+		got, want := m.KNearest(target, k), bruteKNearest(qt, target, k)
+		if len(got) != len(want) {
+			t.Fatalf("KNearest(%v, %d) returned %d points, want %d", target, k, len(got), len(want))
+		}
+		for j := range got {
+			if got[j].Data != want[j].Data {
+				t.Fatalf("KNearest(%v, %d)[%d] = %v, want %v", target, k, j, got[j], want[j])
+			}
+		}
+	}
+}
+
+// TestMortonIndexEdgesAndRejects tests points on the outer edges and the points NewMortonIndex rejects
+func TestMortonIndexEdgesAndRejects(t *testing.T) {
+	bounds := Bounds{X: -10, Y: -10, Width: 20, Height: 20}
+	points := []Point{
+		{X: -10, Y: -10, Data: 0},
+		{X: 10, Y: 10, Data: 1},
+		{X: 0, Y: 0, Data: 2},
+		{X: 11, Y: 0, Data: 3},
+		{X: 1, Y: math.NaN(), Data: 4},
+	}
+	m, err := NewMortonIndex(bounds, points)
+	var rejected *RejectedPointsError
+	if !errors.As(err, &rejected) || len(rejected.Points) != 2 {
+		t.Fatalf("Expected 2 rejected points, got %v", err)
+	}
+	if !errors.Is(err, ErrOutOfBounds) || !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("Expected the error to match ErrOutOfBounds and ErrInvalidPoint, got %v", err)
+	}
+	if got := dataOf(m.Search(bounds)); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Errorf("Search of the whole index = %v, want [0 1 2]", got)
+	}
+	if got := m.KNearest(Point{X: 10, Y: 10}, 1); len(got) != 1 || got[0].Data != 1 {
+		t.Errorf("KNearest at the max corner = %v", got)
+	}
+	if got := m.KNearest(Point{}, 0); len(got) != 0 {
+		t.Errorf("KNearest with k=0 returned %v", got)
+	}
+
+	if _, err := NewMortonIndex(Bounds{Width: 0, Height: 10}, nil); !errors.Is(err, ErrInvalidBounds) {
+		t.Errorf("Expected ErrInvalidBounds for a zero-area index, got %v", err)
+	}
+}
+
+var (
+	millionOnce   sync.Once
+	millionPoints []Point
+)
+
+// benchmarkMillion returns 1M uniform points, generated once per test binary
+func benchmarkMillion() []Point {
+	millionOnce.Do(func() {
+		rng := rand.New(rand.NewSource(1))
+		millionPoints = make([]Point, 1000000)
+		for i := range millionPoints {
+			millionPoints[i] = Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Data: i}
+		}
+	})
+	return millionPoints
+}
+
+var millionBounds = Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+
+func BenchmarkBuildMillionQuadTree(b *testing.B) {
+	points := benchmarkMillion()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildQuadTree(millionBounds, 8, points)
+	}
+}
+
+func BenchmarkBuildMillionFrozen(b *testing.B) {
+	points := benchmarkMillion()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt, _ := BuildQuadTree(millionBounds, 8, points)
+		qt.Freeze()
+	}
+}
+
+func BenchmarkBuildMillionMorton(b *testing.B) {
+	points := benchmarkMillion()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewMortonIndex(millionBounds, points)
+	}
+}
+
+// benchmarkMillionQueries runs 10x10 Searches, or KNearest for k=10, at random spots of s
+func benchmarkMillionQueries(b *testing.B, s Searcher, knn bool) {
+	rng := rand.New(rand.NewSource(2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x, y := rng.Float64()*990, rng.Float64()*990
+		if knn {
+			s.KNearest(Point{X: x, Y: y}, 10)
+		} else {
+			s.Search(Bounds{X: x, Y: y, Width: 10, Height: 10})
+		}
+	}
+}
+
+func millionQuadTree() *QuadTree {
+	qt, _ := BuildQuadTree(millionBounds, 8, benchmarkMillion())
+	return qt
+}
+
+func BenchmarkSearchMillionQuadTree(b *testing.B) {
+	benchmarkMillionQueries(b, millionQuadTree(), false)
+}
+
+func BenchmarkSearchMillionFrozen(b *testing.B) {
+	benchmarkMillionQueries(b, millionQuadTree().Freeze(), false)
+}
+
+func BenchmarkSearchMillionMorton(b *testing.B) {
+	benchmarkMillionQueries(b, mustNewMortonIndex(millionBounds, benchmarkMillion()), false)
+}
+
+func BenchmarkKNearestMillionQuadTree(b *testing.B) {
+	benchmarkMillionQueries(b, millionQuadTree(), true)
+}
+
+func BenchmarkKNearestMillionFrozen(b *testing.B) {
+	benchmarkMillionQueries(b, millionQuadTree().Freeze(), true)
+}
+
+func BenchmarkKNearestMillionMorton(b *testing.B) {
+	benchmarkMillionQueries(b, mustNewMortonIndex(millionBounds, benchmarkMillion()), true)
+}
+
+func BenchmarkBuildMillionHilbert(b *testing.B) {
+	points := benchmarkMillion()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mustNewMortonIndex(millionBounds, points, WithHilbertCurve())
+	}
+}
+
+func BenchmarkSearchMillionHilbert(b *testing.B) {
+	benchmarkMillionQueries(b, mustNewMortonIndex(millionBounds, benchmarkMillion(), WithHilbertCurve()), false)
+}
+
+func BenchmarkKNearestMillionHilbert(b *testing.B) {
+	benchmarkMillionQueries(b, mustNewMortonIndex(millionBounds, benchmarkMillion(), WithHilbertCurve()), true)
+}
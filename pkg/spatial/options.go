@@ -0,0 +1,132 @@
+package spatial
+
+import "math"
+
+// DefaultCapacity is the leaf capacity used when WithCapacity is not given
+const DefaultCapacity = 4
+
+// Option configures a QuadTree built with NewQuadTree
+type Option func(*QuadTree)
+
+// NewQuadTree builds a tree covering bounds. Bounds must be valid and have a
+// positive area, and the configured capacity and max depth must be positive.
+func NewQuadTree(bounds Bounds, opts ...Option) (*QuadTree, error) {
+	if err := bounds.Validate(); err != nil {
+		return nil, err
+	}
+	if bounds.Width == 0 || bounds.Height == 0 {
+		return nil, ErrInvalidBounds
+	}
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   bounds,
+			Capacity: DefaultCapacity,
+			pool:     newNodePool(),
+		},
+	}
+	for _, opt := range opts {
+		opt(qt)
+	}
+	if qt.Root.Capacity <= 0 {
+		return nil, ErrInvalidCapacity
+	}
+	if qt.Root.adaptive {
+		if qt.adaptMin <= 0 || qt.adaptMax < qt.adaptMin {
+			return nil, ErrInvalidCapacity
+		}
+		qt.Root.Capacity = min(max(qt.Root.Capacity, qt.adaptMin), qt.adaptMax)
+	}
+	if qt.Root.MaxDepth < 0 {
+		return nil, ErrInvalidMaxDepth
+	}
+	if !(qt.matchEps >= 0) || math.IsInf(qt.matchEps, 1) {
+		return nil, ErrInvalidEpsilon
+	}
+	if qt.qcache != nil && qt.qcache.max <= 0 {
+		return nil, ErrInvalidCacheSize
+	}
+	if qt.Root.arena != nil && qt.Root.arena.block < 0 {
+		return nil, ErrInvalidArena
+	}
+	if qt.filter != nil {
+		if qt.filterExpected <= 0 || !(qt.filterRate > 0 && qt.filterRate < 1) {
+			return nil, ErrInvalidFilter
+		}
+		qt.filter = newExistenceFilter(qt.filterExpected, qt.filterRate)
+	}
+	// The log is the last thing that can fail, so it opens before any
+	// goroutine starts
+	if qt.walConfig != nil {
+		w, err := newWAL(*qt.walConfig, qt.dataCodec())
+		if err != nil {
+			return nil, err
+		}
+		qt.wal = w
+	}
+	if qt.sweepEvery > 0 {
+		qt.startSweep()
+	}
+	if qt.hookFns.any() {
+		if qt.hookBuffer <= 0 {
+			qt.hookBuffer = DefaultHookBuffer
+		}
+		qt.hooks = newHookQueue(qt.hookFns, qt.hookBuffer)
+		qt.Root.hooks = qt.hooks
+	}
+	if qt.auditSink != nil {
+		if qt.auditBuffer <= 0 {
+			qt.auditBuffer = DefaultAuditBuffer
+		}
+		qt.audit = newAuditQueue(qt.auditSink, qt.auditBuffer)
+	}
+	if qt.lockFree {
+		qt.current.Store(qt.snapshot())
+	}
+	return qt, nil
+}
+
+// Close stops the tree's background goroutines: the expiry sweep, and the hook
+// and audit dispatchers after they have delivered what is already queued. It also
+// closes every Watch channel and syncs and closes the write-ahead log. Mutations after Close no longer fire hooks. It
+// is safe to call more than once.
+func (qt *QuadTree) Close() {
+	qt.closeOnce.Do(func() {
+		if qt.stopSweep != nil {
+			close(qt.stopSweep)
+		}
+		qt.Lock.Lock()
+		qt.hooks.close()
+		qt.audit.close()
+		qt.closeWatchers()
+		qt.wal.close()
+		qt.Lock.Unlock()
+		if qt.hooks != nil {
+			<-qt.hooks.done
+		}
+		if qt.audit != nil {
+			<-qt.audit.done
+		}
+	})
+}
+
+// WithCapacity sets how many points a leaf holds before it subdivides
+func WithCapacity(capacity int) Option {
+	return func(qt *QuadTree) {
+		qt.Root.Capacity = capacity
+	}
+}
+
+// WithMaxPoints limits how many points the tree stores; inserts beyond it
+// fail with ErrTreeFull. Zero means no limit.
+func WithMaxPoints(n int) Option {
+	return func(qt *QuadTree) {
+		qt.maxPoints = n
+	}
+}
+
+// WithMaxDepth caps how deep the tree may subdivide (DefaultMaxDepth if unset)
+func WithMaxDepth(depth int) Option {
+	return func(qt *QuadTree) {
+		qt.Root.MaxDepth = depth
+	}
+}
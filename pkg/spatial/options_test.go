@@ -0,0 +1,65 @@
+package spatial
+
+import (
+	"errors"
+	"testing"
+)
+
+// mustNewQuadTree builds a tree for tests, panicking on invalid configuration
+func mustNewQuadTree(bounds Bounds, opts ...Option) *QuadTree {
+	qt, err := NewQuadTree(bounds, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return qt
+}
+
+// TestNewQuadTreeDefaults tests the configuration of a tree built without options
+func TestNewQuadTreeDefaults(t *testing.T) {
+	qt, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	if err != nil {
+		t.Fatalf("NewQuadTree failed: %v", err)
+	}
+	if qt.Root.Capacity != DefaultCapacity || qt.Root.maxDepth() != DefaultMaxDepth {
+		t.Errorf("Expected default capacity and depth, got %d and %d", qt.Root.Capacity, qt.Root.maxDepth())
+	}
+	if qt.autoExpand || qt.geo {
+		t.Error("Auto-expansion and geo mode should be off by default")
+	}
+
+	qt, err = NewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180},
+		WithCapacity(8), WithMaxDepth(6), WithAutoExpand(), WithGeoCoordinates())
+	if err != nil {
+		t.Fatalf("NewQuadTree with options failed: %v", err)
+	}
+	if qt.Root.Capacity != 8 || qt.Root.MaxDepth != 6 || !qt.autoExpand || !qt.geo {
+		t.Errorf("Options not applied: %+v", qt)
+	}
+}
+
+// TestNewQuadTreeRejectsInvalidConfig tests the constructor's validation
+func TestNewQuadTreeRejectsInvalidConfig(t *testing.T) {
+	valid := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	tests := []struct {
+		name   string
+		bounds Bounds
+		opts   []Option
+		err    error
+	}{
+		{"zero capacity", valid, []Option{WithCapacity(0)}, ErrInvalidCapacity},
+		{"negative capacity", valid, []Option{WithCapacity(-3)}, ErrInvalidCapacity},
+		{"negative max depth", valid, []Option{WithMaxDepth(-1)}, ErrInvalidMaxDepth},
+		{"zero width", Bounds{X: 0, Y: 0, Width: 0, Height: 100}, nil, ErrInvalidBounds},
+		{"zero height", Bounds{X: 0, Y: 0, Width: 100, Height: 0}, nil, ErrInvalidBounds},
+		{"negative width", Bounds{X: 0, Y: 0, Width: -10, Height: 100}, nil, ErrInvalidBounds},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt, err := NewQuadTree(tt.bounds, tt.opts...)
+			if !errors.Is(err, tt.err) || qt != nil {
+				t.Errorf("Expected %v and a nil tree, got %v, %v", tt.err, qt, err)
+			}
+		})
+	}
+}
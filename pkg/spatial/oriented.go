@@ -0,0 +1,60 @@
+package spatial
+
+import "math"
+
+// SearchOriented returns every point inside a width x height rectangle centred
+// on center and rotated counter-clockwise by angleRad. Edges follow the
+// tree's WithSearchEdges setting, taken in the rectangle's own frame, so an
+// unrotated query returns exactly what Search does.
+func (qt *QuadTree) SearchOriented(center Point, width, height, angleRad float64) []Point {
+	qt.rlockAll()
+	defer qt.runlockAll()
+	results := make([]Point, 0)
+	if qt.Root == nil || width < 0 || height < 0 {
+		return results
+	}
+
+	sin, cos := math.Sincos(angleRad)
+	halfW := width / 2
+	halfH := height / 2
+
+	// Prune with the axis-aligned box that encloses the rotated rectangle
+	extX := math.Abs(halfW*cos) + math.Abs(halfH*sin)
+	extY := math.Abs(halfW*sin) + math.Abs(halfH*cos)
+	aabb := Bounds{
+		X:      center.X - extX,
+		Y:      center.Y - extY,
+		Width:  extX * 2,
+		Height: extY * 2,
+	}
+	candidates := make([]Point, 0)
+
+	// An unrotated rectangle is its own bounding box, so every candidate is a hit
+	if sin == 0 && cos == 1 {
+		qt.searchLive(aabb, qt.edges, &candidates)
+		return candidates
+	}
+
+	// Otherwise the box only prunes, and inOrientedRect decides the edges
+	qt.searchLive(aabb, InclusiveEdges, &candidates)
+	for _, p := range candidates {
+		if inOrientedRect(p, center, halfW, halfH, sin, cos, qt.edges) {
+			results = append(results, p)
+		}
+	}
+	return results
+}
+
+// inOrientedRect rotates p into the rectangle's local frame (by -angle) and
+// checks it against the half extents. Under HalfOpenEdges the local max
+// edges are excluded.
+func inOrientedRect(p, center Point, halfW, halfH, sin, cos float64, edges Edges) bool {
+	dx := p.X - center.X
+	dy := p.Y - center.Y
+	localX := dx*cos + dy*sin
+	localY := -dx*sin + dy*cos
+	if edges == HalfOpenEdges {
+		return localX >= -halfW && localX < halfW && localY >= -halfH && localY < halfH
+	}
+	return math.Abs(localX) <= halfW && math.Abs(localY) <= halfH
+}
@@ -0,0 +1,142 @@
+package spatial
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestSearchOrientedZeroAngleMatchesSearch checks that an unrotated query is identical to Search
+func TestSearchOrientedZeroAngleMatchesSearch(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 1000, Height: 1000},
+			Capacity: 4,
+		},
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	for i := 0; i < 2000; i++ {
+		qt.Insert(Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Data: i})
+	}
+
+	for i := 0; i < 200; i++ {
+		center := Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+		w := rng.Float64() * 300
+		h := rng.Float64() * 300
+
+		expected := qt.Search(Bounds{X: center.X - w/2, Y: center.Y - h/2, Width: w, Height: h})
+		got := qt.SearchOriented(center, w, h, 0)
+
+		if len(got) != len(expected) {
+			t.Fatalf("query %d: expected %d results, got %d", i, len(expected), len(got))
+		}
+		for j := range expected {
+			if got[j] != expected[j] {
+				t.Fatalf("query %d: result %d differs: expected %v, got %v", i, j, expected[j], got[j])
+			}
+		}
+	}
+}
+
+// TestSearchOrientedZeroAngleHalfOpen tests that an unrotated query follows
+// the tree's half-open edges as Search does, with points on the edges
+func TestSearchOrientedZeroAngleHalfOpen(t *testing.T) {
+	qt, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithSearchEdges(HalfOpenEdges))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x <= 100; x += 5 {
+		for y := 0; y <= 100; y += 5 {
+			qt.Insert(Point{X: float64(x), Y: float64(y)})
+		}
+	}
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 200; i++ {
+		w, h := float64(5*rng.Intn(10)), float64(5*rng.Intn(10))
+		center := Point{X: float64(5*rng.Intn(20)) + w/2, Y: float64(5*rng.Intn(20)) + h/2}
+		expected := qt.Search(Bounds{X: center.X - w/2, Y: center.Y - h/2, Width: w, Height: h})
+		got := qt.SearchOriented(center, w, h, 0)
+		if len(got) != len(expected) {
+			t.Fatalf("query %d: expected %d results, got %d", i, len(expected), len(got))
+		}
+		for j := range expected {
+			if got[j] != expected[j] {
+				t.Fatalf("query %d: result %d differs: expected %v, got %v", i, j, expected[j], got[j])
+			}
+		}
+	}
+}
+
+// TestSearchOrientedDiagonalCorridor tests a thin corridor rotated 45 degrees
+func TestSearchOrientedDiagonalCorridor(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 4,
+		},
+	}
+
+	// Points on the diagonal y = x fall inside, off-diagonal corners do not
+	onDiagonal := []Point{
+		{X: 40, Y: 40, Data: "a"},
+		{X: 50, Y: 50, Data: "b"},
+		{X: 60, Y: 60, Data: "c"},
+	}
+	offDiagonal := []Point{
+		{X: 40, Y: 60, Data: "x"},
+		{X: 60, Y: 40, Data: "y"},
+	}
+	for _, p := range append(onDiagonal, offDiagonal...) {
+		qt.Insert(p)
+	}
+
+	results := qt.SearchOriented(Point{X: 50, Y: 50}, 40, 4, math.Pi/4)
+
+	if len(results) != len(onDiagonal) {
+		t.Fatalf("Expected %d results, got %d", len(onDiagonal), len(results))
+	}
+	for _, r := range results {
+		if r.Data == "x" || r.Data == "y" {
+			t.Errorf("Point %v should be outside the rotated corridor", r.Data)
+		}
+	}
+}
+
+// TestSearchOrientedRightAngle checks that a 90 degree rotation swaps width and height
+func TestSearchOrientedRightAngle(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 4,
+		},
+	}
+
+	qt.Insert(Point{X: 50, Y: 30, Data: "vertical"})
+	qt.Insert(Point{X: 70, Y: 50, Data: "horizontal"})
+
+	results := qt.SearchOriented(Point{X: 50, Y: 50}, 50, 10, math.Pi/2)
+
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].Data != "vertical" {
+		t.Errorf("Expected vertical point, got %v", results[0].Data)
+	}
+}
+
+// TestSearchOrientedEmptyTree tests querying a tree with no points
+func TestSearchOrientedEmptyTree(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 4,
+		},
+	}
+
+	results := qt.SearchOriented(Point{X: 50, Y: 50}, 20, 20, 1)
+
+	if results == nil || len(results) != 0 {
+		t.Errorf("Expected empty non-nil result, got %v", results)
+	}
+}
@@ -0,0 +1,101 @@
+package spatial
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// parallelLevels is how many levels below the root SearchParallel splits
+// into independent subtrees before handing them to workers
+const parallelLevels = 3
+
+// SearchParallel is Search with the subtree traversals spread over up to
+// workers goroutines. The results hold the same points as Search but may be
+// in a different order. When workers <= 1, or area only reaches a single
+// subtree, it searches serially without starting any goroutines.
+func (qt *QuadTree) SearchParallel(area Bounds, workers int) []Point {
+	if view := qt.view(); view != nil {
+		return view.searchParallel(area, qt.edges, workers)
+	}
+	held := qt.rlockArea(area)
+	defer qt.runlockArea(held)
+	return qt.searchParallel(area, qt.edges, workers)
+}
+
+// searchParallel is SearchParallel for callers that hold the lock
+func (qt *QuadTree) searchParallel(area Bounds, edges Edges, workers int) []Point {
+	results := make([]Point, 0)
+	var tasks []*Node
+	if workers > 1 {
+		tasks = qt.Root.frontier(area, workers*4)
+	}
+	if len(tasks) < 2 {
+		qt.searchLive(area, edges, &results)
+		return results
+	}
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	// Workers claim subtrees in order and keep each one's points separately,
+	// so the concatenation needs no locking
+	found := make([][]Point, len(tasks))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(tasks) {
+					return
+				}
+				tasks[i].searchEdges(area, edges, &found[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, f := range found {
+		total += len(f)
+	}
+	results = make([]Point, 0, total)
+	for _, f := range found {
+		results = append(results, f...)
+	}
+	qt.dropExpired(&results, 0)
+	return results
+}
+
+// frontier returns the subtrees of n that area reaches, splitting internal
+// nodes level by level until there are at least want of them or
+// parallelLevels have been split. Subtrees area misses are left out.
+func (n *Node) frontier(area Bounds, want int) []*Node {
+	if !n.Bounds.Intersects(area) {
+		return nil
+	}
+	nodes := []*Node{n}
+	for level := 0; level < parallelLevels && len(nodes) < want; level++ {
+		split := false
+		next := make([]*Node, 0, len(nodes)*4)
+		for _, c := range nodes {
+			if c.Children[0] == nil {
+				next = append(next, c)
+				continue
+			}
+			split = true
+			for _, gc := range c.Children {
+				if gc.Bounds.Intersects(area) {
+					next = append(next, gc)
+				}
+			}
+		}
+		nodes = next
+		if !split {
+			break
+		}
+	}
+	return nodes
+}
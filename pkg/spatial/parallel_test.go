@@ -0,0 +1,88 @@
+package spatial
+
+import (
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// dataOf returns the Data ints of points, sorted, so result sets compare as multisets
+func dataOf(points []Point) []int {
+	ids := make([]int, len(points))
+	for i, p := range points {
+		ids[i] = p.Data.(int)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// TestSearchParallelMatchesSearch tests that SearchParallel returns the same multiset as Search for random areas
+func TestSearchParallelMatchesSearch(t *testing.T) {
+	qt := newRandomTree(20000, 1)
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 300; i++ {
+		area := Bounds{
+			X:      rng.Float64()*1100 - 50,
+			Y:      rng.Float64()*1100 - 50,
+			Width:  rng.Float64() * 600,
+			Height: rng.Float64() * 600,
+		}
+		workers := rng.Intn(9)
+		want := dataOf(qt.Search(area))
+		if got := dataOf(qt.SearchParallel(area, workers)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("area %+v, %d workers: %d points, Search found %d", area, workers, len(got), len(want))
+		}
+	}
+	checkValid(t, qt)
+}
+
+// TestSearchParallelEdgesAndExpiry tests that SearchParallel honours the tree's edges and hides expired points
+func TestSearchParallelEdgesAndExpiry(t *testing.T) {
+	clock := newFakeClock()
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1), WithClock(clock.Now), WithSearchEdges(HalfOpenEdges))
+	for i := 0; i < 100; i++ {
+		qt.Insert(Point{X: float64(i), Y: float64(i), Data: i})
+	}
+	qt.InsertWithTTL(Point{X: 5.5, Y: 5.5, Data: 100}, time.Minute)
+	clock.Advance(time.Hour)
+
+	all := Bounds{X: 0, Y: 0, Width: 99, Height: 99}
+	got := dataOf(qt.SearchParallel(all, 4))
+	if len(got) != 99 || got[98] != 98 {
+		t.Errorf("SearchParallel returned %d points ending at %v, want 0..98", len(got), got[len(got)-1])
+	}
+}
+
+// TestSearchParallelSerialFallback tests that areas reaching one subtree or none are not split
+func TestSearchParallelSerialFallback(t *testing.T) {
+	qt := newRandomTree(1000, 3)
+	leaf := Bounds{X: 1, Y: 1, Width: 0.5, Height: 0.5}
+	if got := qt.Root.frontier(leaf, 16); len(got) > 1 {
+		t.Errorf("area inside one leaf split into %d subtrees", len(got))
+	}
+	if got := qt.Root.frontier(Bounds{X: 2000, Y: 2000, Width: 1, Height: 1}, 16); len(got) != 0 {
+		t.Errorf("area outside the tree split into %d subtrees", len(got))
+	}
+	if got := qt.SearchParallel(qt.Root.Bounds, 1); len(got) != 1000 {
+		t.Errorf("one worker returned %d points, want 1000", len(got))
+	}
+}
+
+func BenchmarkSearchCitySerial(b *testing.B) {
+	qt := newRandomTree(200000, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt.Search(qt.Root.Bounds)
+	}
+}
+
+func BenchmarkSearchCityParallel(b *testing.B) {
+	qt := newRandomTree(200000, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qt.SearchParallel(qt.Root.Bounds, runtime.GOMAXPROCS(0))
+	}
+}
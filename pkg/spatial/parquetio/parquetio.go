@@ -0,0 +1,583 @@
+// Package parquetio exports a tree's points as Parquet files for analytics.
+// It writes the format itself, uncompressed with plain encoding, so it needs
+// no Parquet library.
+package parquetio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// DefaultRowGroup is the number of rows Export puts in a row group when
+// Schema.RowGroupRows is 0
+const DefaultRowGroup = 64 * 1024
+
+// ErrInvalidSchema is matched by a *SchemaError for a column Export can't
+// write
+var ErrInvalidSchema = errors.New("parquetio: invalid schema")
+
+// Type is the column type of a Field
+type Type uint8
+
+const (
+	// String is a UTF-8 column. Strings are written as they are and any
+	// other value as JSON, so nested values survive the export.
+	String Type = iota
+	// Int64 holds integers, and floats with no fractional part
+	Int64
+	// Double holds any number
+	Double
+	// Bool holds booleans
+	Bool
+)
+
+// Field selects a Data field to export as a column
+type Field struct {
+	Name string // Column name
+	// Key is the field of Data to export: a key of a map[string]any, or of
+	// the JSON object any other Data marshals to. "" exports Data itself.
+	Key  string
+	Type Type
+}
+
+// Schema selects the Data columns Export writes
+type Schema struct {
+	Fields []Field
+	// Timestamp returns the time of a point, typically read from its Data,
+	// for the timestamp column. The tree keeps no time per point, so rows
+	// it returns false for, and every row when it is nil, are null.
+	Timestamp    func(spatial.Point) (time.Time, bool)
+	RowGroupRows int // Rows per row group, DefaultRowGroup if 0
+}
+
+// SchemaError reports a Schema Export can't write. It matches
+// ErrInvalidSchema.
+type SchemaError struct {
+	Field  string
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("parquetio: column %q: %s", e.Field, e.Reason)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrInvalidSchema
+}
+
+// Export writes the unexpired points of qt in area as a Parquet file with
+// the columns x and y (doubles), id (string, null for points stored without
+// one), timestamp (milliseconds since the epoch, from schema.Timestamp) and
+// the Data fields in schema. A field a point's Data lacks, or holds a value
+// of the wrong type for, is null. It walks a Snapshot through ForEachIn,
+// buffering one row group at a time.
+func Export(w io.Writer, qt *spatial.QuadTree, area spatial.Bounds, schema Schema) error {
+	columns, err := schema.columns()
+	if err != nil {
+		return err
+	}
+	groupRows := schema.RowGroupRows
+	if groupRows <= 0 {
+		groupRows = DefaultRowGroup
+	}
+
+	bw := bufio.NewWriter(w)
+	out := &countingWriter{w: bw}
+	if _, err := io.WriteString(out, magic); err != nil {
+		return err
+	}
+	var groups []rowGroup
+	rows := 0
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		group := rowGroup{rows: int64(rows)}
+		for _, c := range columns {
+			chunk, err := c.flush(out)
+			if err != nil {
+				return err
+			}
+			group.chunks = append(group.chunks, chunk)
+			group.bytes += chunk.size
+		}
+		groups = append(groups, group)
+		rows = 0
+		return nil
+	}
+
+	qt.ForEachIn(area, func(p spatial.Point) bool {
+		columns[0].addDouble(p.X)
+		columns[1].addDouble(p.Y)
+		if id, ok := p.ID(); ok {
+			columns[2].addBytes([]byte(id))
+		} else {
+			columns[2].addNull()
+		}
+		if t, ok := timestamp(schema.Timestamp, p); ok {
+			columns[3].addInt64(t.UnixMilli())
+		} else {
+			columns[3].addNull()
+		}
+		fields := dataFields(p.Data)
+		for i, f := range schema.Fields {
+			columns[4+i].add(f.Type, fields(f.Key))
+		}
+		if rows++; rows == groupRows {
+			err = flush()
+		}
+		return err == nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	meta := footer(columns, groups)
+	meta = binary.LittleEndian.AppendUint32(meta, uint32(len(meta)))
+	meta = append(meta, magic...)
+	if _, err := out.Write(meta); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func timestamp(fn func(spatial.Point) (time.Time, bool), p spatial.Point) (time.Time, bool) {
+	if fn == nil {
+		return time.Time{}, false
+	}
+	return fn(p)
+}
+
+// countingWriter counts the bytes written through it, for chunk offsets
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// columns returns the writers for the fixed columns followed by schema's
+func (s Schema) columns() ([]*column, error) {
+	columns := []*column{
+		{name: "x", physical: typeDouble, converted: -1},
+		{name: "y", physical: typeDouble, converted: -1},
+		{name: "id", physical: typeByteArray, converted: convertedUTF8, optional: true},
+		{name: "timestamp", physical: typeInt64, converted: convertedTimestampMillis, optional: true},
+	}
+	names := map[string]bool{"x": true, "y": true, "id": true, "timestamp": true}
+	for _, f := range s.Fields {
+		if f.Name == "" || names[f.Name] {
+			return nil, &SchemaError{Field: f.Name, Reason: "empty or duplicate name"}
+		}
+		names[f.Name] = true
+		c := &column{name: f.Name, converted: -1, optional: true}
+		switch f.Type {
+		case String:
+			c.physical, c.converted = typeByteArray, convertedUTF8
+		case Int64:
+			c.physical = typeInt64
+		case Double:
+			c.physical = typeDouble
+		case Bool:
+			c.physical = typeBoolean
+		default:
+			return nil, &SchemaError{Field: f.Name, Reason: fmt.Sprintf("unknown type %d", f.Type)}
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// dataFields returns a lookup of the fields of data. Data other than
+// a map[string]any is marshalled to JSON once, and only if a field needs it.
+func dataFields(data any) func(key string) any {
+	object, isMap := data.(map[string]any)
+	decoded := isMap
+	return func(key string) any {
+		if key == "" {
+			return data
+		}
+		if !decoded {
+			decoded = true
+			if b, err := json.Marshal(data); err == nil {
+				d := json.NewDecoder(bytes.NewReader(b))
+				d.UseNumber()
+				d.Decode(&object)
+			}
+		}
+		return object[key]
+	}
+}
+
+// column buffers one column of a row group
+type column struct {
+	name      string
+	physical  int32
+	converted int32 // -1 for none
+	optional  bool
+
+	present []bool // Per row, for optional columns
+	values  []byte // Plain-encoded present values, except booleans
+	bools   []bool
+	rows    int
+}
+
+func (c *column) addNull() {
+	c.present = append(c.present, false)
+	c.rows++
+}
+
+func (c *column) added() {
+	if c.optional {
+		c.present = append(c.present, true)
+	}
+	c.rows++
+}
+
+func (c *column) addDouble(v float64) {
+	c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(v))
+	c.added()
+}
+
+func (c *column) addInt64(v int64) {
+	c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+	c.added()
+}
+
+func (c *column) addBytes(b []byte) {
+	c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(b)))
+	c.values = append(c.values, b...)
+	c.added()
+}
+
+func (c *column) addBool(v bool) {
+	c.bools = append(c.bools, v)
+	c.added()
+}
+
+// add converts v to the column's type, adding a null if it doesn't fit
+func (c *column) add(t Type, v any) {
+	if v == nil {
+		c.addNull()
+		return
+	}
+	switch t {
+	case String:
+		if s, ok := v.(string); ok {
+			c.addBytes([]byte(s))
+		} else if b, err := json.Marshal(v); err == nil {
+			c.addBytes(b)
+		} else {
+			c.addBytes([]byte(fmt.Sprint(v)))
+		}
+	case Int64:
+		if i, ok := intValue(v); ok {
+			c.addInt64(i)
+		} else {
+			c.addNull()
+		}
+	case Double:
+		if f, ok := floatValue(v); ok {
+			c.addDouble(f)
+		} else {
+			c.addNull()
+		}
+	case Bool:
+		if b, ok := v.(bool); ok {
+			c.addBool(b)
+		} else {
+			c.addNull()
+		}
+	}
+}
+
+func intValue(v any) (int64, bool) {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		f, err := n.Float64()
+		v = f
+		if err != nil {
+			return 0, false
+		}
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint()), rv.Uint() <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		return int64(f), f == math.Trunc(f) && math.Abs(f) < 1<<63
+	}
+	return 0, false
+}
+
+func floatValue(v any) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// chunk locates a written column chunk
+type chunk struct {
+	offset, size int64
+	values       int64
+}
+
+type rowGroup struct {
+	chunks []chunk
+	bytes  int64
+	rows   int64
+}
+
+// flush writes the buffered column as one data page and resets it
+func (c *column) flush(out *countingWriter) (chunk, error) {
+	var page []byte
+	if c.optional {
+		defs := levels(c.present)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(defs)))
+		page = append(page, defs...)
+	}
+	if c.physical == typeBoolean {
+		packed := make([]byte, (len(c.bools)+7)/8)
+		for i, b := range c.bools {
+			if b {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		page = append(page, packed...)
+	} else {
+		page = append(page, c.values...)
+	}
+
+	t := &thriftWriter{}
+	t.structure(func() {
+		t.i32(1, pageData)
+		t.i32(2, int32(len(page)))
+		t.i32(3, int32(len(page)))
+		t.structField(5, func() {
+			t.i32(1, int32(c.rows))
+			t.i32(2, encodingPlain)
+			t.i32(3, encodingRLE)
+			t.i32(4, encodingRLE)
+		})
+	})
+	ch := chunk{offset: out.n, size: int64(len(t.b) + len(page)), values: int64(c.rows)}
+	if _, err := out.Write(t.b); err != nil {
+		return ch, err
+	}
+	if _, err := out.Write(page); err != nil {
+		return ch, err
+	}
+	c.present, c.values, c.bools, c.rows = c.present[:0], c.values[:0], c.bools[:0], 0
+	return ch, nil
+}
+
+// levels encodes definition levels of bit width 1 as RLE runs
+func levels(present []bool) []byte {
+	var b []byte
+	for i := 0; i < len(present); {
+		j := i
+		for j < len(present) && present[j] == present[i] {
+			j++
+		}
+		b = binary.AppendUvarint(b, uint64(j-i)<<1)
+		if present[i] {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		i = j
+	}
+	return b
+}
+
+// footer encodes the file metadata
+func footer(columns []*column, groups []rowGroup) []byte {
+	var rows int64
+	for _, g := range groups {
+		rows += g.rows
+	}
+	t := &thriftWriter{}
+	t.structure(func() {
+		t.i32(1, 1)
+		t.structList(2, len(columns)+1, func(i int) {
+			if i == 0 {
+				t.binary(4, "schema")
+				t.i32(5, int32(len(columns)))
+				return
+			}
+			c := columns[i-1]
+			t.i32(1, c.physical)
+			repetition := int32(repetitionRequired)
+			if c.optional {
+				repetition = repetitionOptional
+			}
+			t.i32(3, repetition)
+			t.binary(4, c.name)
+			if c.converted >= 0 {
+				t.i32(6, c.converted)
+			}
+		})
+		t.i64(3, rows)
+		t.structList(4, len(groups), func(i int) {
+			g := groups[i]
+			t.structList(1, len(g.chunks), func(j int) {
+				chunk, c := g.chunks[j], columns[j]
+				t.i64(2, chunk.offset)
+				t.structField(3, func() {
+					t.i32(1, c.physical)
+					t.list(2, thriftI32, 2)
+					t.appendI32(encodingPlain)
+					t.appendI32(encodingRLE)
+					t.list(3, thriftBinary, 1)
+					t.appendBinary(c.name)
+					t.i32(4, codecUncompressed)
+					t.i64(5, chunk.values)
+					t.i64(6, chunk.size)
+					t.i64(7, chunk.size)
+					t.i64(9, chunk.offset)
+				})
+			})
+			t.i64(2, g.bytes)
+			t.i64(3, g.rows)
+		})
+		t.binary(6, "Distributed-Delivery-Routing-Engine parquetio")
+	})
+	return t.b
+}
+
+// Parquet format constants
+const (
+	magic = "PAR1"
+
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	encodingPlain     = 0
+	encodingRLE       = 3
+	codecUncompressed = 0
+	pageData          = 0
+)
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift compact protocol structures Parquet
+// metadata is made of
+type thriftWriter struct {
+	b    []byte
+	last []int16 // The last field id written, per open struct
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.b = append(t.b, byte(delta)<<4|typ)
+	} else {
+		t.b = append(t.b, typ)
+		t.b = binary.AppendVarint(t.b, int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.appendI32(v)
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.b = binary.AppendVarint(t.b, v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.appendBinary(s)
+}
+
+func (t *thriftWriter) appendI32(v int32) {
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+func (t *thriftWriter) appendBinary(s string) {
+	t.b = binary.AppendUvarint(t.b, uint64(len(s)))
+	t.b = append(t.b, s...)
+}
+
+// list starts a list field of n elements, to be appended next
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.b = append(t.b, byte(n)<<4|elem)
+	} else {
+		t.b = append(t.b, 0xf0|elem)
+		t.b = binary.AppendUvarint(t.b, uint64(n))
+	}
+}
+
+// structure writes a struct whose fields fn writes
+func (t *thriftWriter) structure(fn func()) {
+	t.last = append(t.last, 0)
+	fn()
+	t.b = append(t.b, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) structField(id int16, fn func()) {
+	t.field(id, thriftStruct)
+	t.structure(fn)
+}
+
+func (t *thriftWriter) structList(id int16, n int, fn func(i int)) {
+	t.list(id, thriftStruct, n)
+	for i := 0; i < n; i++ {
+		t.structure(func() { fn(i) })
+	}
+}
@@ -0,0 +1,154 @@
+package parquetio_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/parquetio"
+)
+
+// readParquet reads file back with parquet-go, into one map per row from
+// column name to value, nulls as nil, and returns the number of row groups
+func readParquet(t *testing.T, file []byte) ([]map[string]any, int) {
+	t.Helper()
+	f, err := parquet.OpenFile(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("Opening the export: %v", err)
+	}
+	names := f.Schema().Columns()
+	var rows []map[string]any
+	for _, group := range f.RowGroups() {
+		reader := group.Rows()
+		buf := make([]parquet.Row, 7)
+		for {
+			n, err := reader.ReadRows(buf)
+			for _, r := range buf[:n] {
+				row := make(map[string]any)
+				for _, v := range r {
+					name := names[v.Column()][0]
+					switch {
+					case v.IsNull():
+						row[name] = nil
+					case v.Kind() == parquet.Double:
+						row[name] = v.Double()
+					case v.Kind() == parquet.Int64:
+						row[name] = v.Int64()
+					case v.Kind() == parquet.ByteArray:
+						row[name] = string(v.ByteArray())
+					case v.Kind() == parquet.Boolean:
+						row[name] = v.Boolean()
+					default:
+						t.Fatalf("Column %s: unexpected kind %v", name, v.Kind())
+					}
+				}
+				rows = append(rows, row)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Reading rows: %v", err)
+			}
+		}
+		reader.Close()
+	}
+	if int64(len(rows)) != f.NumRows() {
+		t.Fatalf("Footer counts %d rows, read %d", f.NumRows(), len(rows))
+	}
+	return rows, len(f.RowGroups())
+}
+
+type van struct {
+	Name  string         `json:"name"`
+	Stops int            `json:"stops"`
+	Tags  map[string]int `json:"tags"`
+}
+
+// TestExport tests the columns a Parquet reader reads back: typed and JSON
+// Data fields, per-point timestamps, nulls and row groups
+func TestExport(t *testing.T) {
+	qt, err := spatial.NewQuadTree(spatial.Bounds{X: 0, Y: 0, Width: 100, Height: 100}, spatial.WithCapacity(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.UnixMilli(1700000000123)
+	for i := 0; i < 25; i++ {
+		qt.Insert(spatial.Point{X: float64(i), Y: 1, Data: map[string]any{
+			"name": fmt.Sprint("stop ", i), "stops": i, "load": 0.5, "ok": i%2 == 0, "at": start.Add(time.Duration(i) * time.Second),
+		}})
+	}
+	qt.InsertWithID("van", spatial.Point{X: 50, Y: 50, Data: van{Name: "van", Stops: 3, Tags: map[string]int{"cold": 1}}})
+	qt.InsertWithID("bare", spatial.Point{X: 60, Y: 60, Data: 7})
+	qt.Insert(spatial.Point{X: 99, Y: 99}) // Outside the area
+
+	schema := parquetio.Schema{
+		Fields: []parquetio.Field{
+			{Name: "name", Key: "name", Type: parquetio.String},
+			{Name: "stops", Key: "stops", Type: parquetio.Int64},
+			{Name: "load", Key: "load", Type: parquetio.Double},
+			{Name: "ok", Key: "ok", Type: parquetio.Bool},
+			{Name: "tags", Key: "tags", Type: parquetio.String},
+			{Name: "data", Type: parquetio.String},
+		},
+		Timestamp: func(p spatial.Point) (time.Time, bool) {
+			m, ok := p.Data.(map[string]any)
+			if !ok {
+				return time.Time{}, false
+			}
+			at, ok := m["at"].(time.Time)
+			return at, ok
+		},
+		RowGroupRows: 10,
+	}
+	var buf bytes.Buffer
+	if err := parquetio.Export(&buf, qt, spatial.Bounds{X: 0, Y: 0, Width: 90, Height: 90}, schema); err != nil {
+		t.Fatal(err)
+	}
+	rows, groups := readParquet(t, buf.Bytes())
+	if len(rows) != 27 || groups != 3 {
+		t.Fatalf("Expected 27 rows in 3 row groups, got %d in %d", len(rows), groups)
+	}
+
+	byXY := make(map[string]map[string]any)
+	for _, row := range rows {
+		if len(row) != 10 {
+			t.Fatalf("Expected 10 columns, got %v", row)
+		}
+		byXY[fmt.Sprint(row["x"], ",", row["y"])] = row
+	}
+	for key, want := range map[string]map[string]any{
+		"3,1": {"id": nil, "name": "stop 3", "stops": int64(3), "load": 0.5, "ok": false, "tags": nil,
+			"timestamp": start.Add(3 * time.Second).UnixMilli()},
+		"50,50": {"id": "van", "name": "van", "stops": int64(3), "load": nil, "ok": nil, "tags": `{"cold":1}`,
+			"data": `{"name":"van","stops":3,"tags":{"cold":1}}`, "timestamp": nil},
+		"60,60": {"id": "bare", "name": nil, "stops": nil, "data": "7", "timestamp": nil},
+	} {
+		for name, v := range want {
+			if got := byXY[key][name]; got != v {
+				t.Errorf("Row %s, column %s: expected %v, got %v", key, name, v, got)
+			}
+		}
+	}
+
+	// Without a Timestamp func the column is null throughout
+	buf.Reset()
+	if err := parquetio.Export(&buf, qt, spatial.Bounds{X: 0, Y: 0, Width: 10, Height: 10}, parquetio.Schema{}); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ = readParquet(t, buf.Bytes())
+	if len(rows) != 11 || rows[0]["timestamp"] != nil {
+		t.Errorf("Expected 11 rows with null timestamps, got %d, first %v", len(rows), rows[0])
+	}
+
+	schema.Fields = append(schema.Fields, parquetio.Field{Name: "x"})
+	if err := parquetio.Export(&buf, qt, qt.Root.Bounds, schema); !errors.Is(err, parquetio.ErrInvalidSchema) {
+		t.Errorf("Expected a duplicate column rejected, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package pgio loads trees from PostGIS queries and writes their points back
+// to PostGIS tables. It works through database/sql, so any Postgres driver
+// does: lib/pq, or pgx through its stdlib package, whose OpenDBFromPool
+// turns a pgxpool.Pool into a *sql.DB.
+package pgio
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/wkt"
+)
+
+const (
+	// FetchSize is the number of rows LoadFromQuery fetches from its cursor,
+	// and stores in the tree, at a time
+	FetchSize = 4096
+	// ExportBatch is the number of rows ExportToTable upserts per statement
+	ExportBatch = 500
+)
+
+// loadCursor is the name of the cursor LoadFromQuery reads through
+const loadCursor = "pgio_load"
+
+// DB starts the transactions pgio works in. *sql.DB and *sql.Conn
+// implement it.
+type DB interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Mapper turns the columns a row has after its key and geometry into the
+// point's Data. The values are as the driver returns them.
+type Mapper func(columns []any) (any, error)
+
+// RowError reports a row LoadFromQuery could not store, by its primary key
+type RowError struct {
+	Key string
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("pgio: row %s: %v", e.Key, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// LoadError lists the rows LoadFromQuery skipped. It matches the errors of
+// its rows with errors.Is, such as wkt.ErrSyntax or spatial.ErrOutOfBounds.
+type LoadError struct {
+	Rows []RowError
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("pgio: %d rows skipped, the first %v", len(e.Rows), e.Rows[0])
+}
+
+func (e *LoadError) Unwrap() []error {
+	errs := make([]error, len(e.Rows))
+	for i, row := range e.Rows {
+		errs[i] = row
+	}
+	return errs
+}
+
+// LoadFromQuery builds a tree covering bounds from the rows query returns,
+// storing each point under its row's primary key. The first column must be
+// the key, the second the geometry as WKB or EWKB, such as
+// ST_AsBinary(geom), and must be a point; mapper turns any further columns
+// into Data, and nil leaves Data nil.
+//
+// The rows are read through a server-side cursor in a read-only
+// transaction, FetchSize at a time, so the result is never held in memory
+// whole. Rows whose geometry can't be parsed, that mapper rejects or that
+// fall outside bounds are skipped and reported in a *LoadError, returned
+// with the tree holding every other row. Any other error, including ctx
+// being cancelled, returns a nil tree.
+func LoadFromQuery(ctx context.Context, db DB, query string, mapper Mapper, bounds spatial.Bounds, opts ...spatial.Option) (*spatial.QuadTree, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DECLARE "+loadCursor+" NO SCROLL CURSOR FOR "+query); err != nil {
+		return nil, err
+	}
+	qt, err := spatial.NewQuadTree(bounds, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []RowError
+	fetch := "FETCH FORWARD " + strconv.Itoa(FetchSize) + " FROM " + loadCursor
+	for {
+		ops, keys, rejected, err := fetchRows(ctx, tx, fetch, mapper)
+		if err != nil {
+			qt.Close()
+			return nil, err
+		}
+		skipped = append(skipped, rejected...)
+		for i, err := range qt.Apply(ops) {
+			if err != nil {
+				skipped = append(skipped, RowError{Key: keys[i], Err: err})
+			}
+		}
+		if len(keys)+len(rejected) < FetchSize {
+			break
+		}
+	}
+	if len(skipped) > 0 {
+		return qt, &LoadError{Rows: skipped}
+	}
+	return qt, nil
+}
+
+// fetchRows fetches the next rows from the cursor as upserts, with their
+// keys, and the rows that could not be turned into points
+func fetchRows(ctx context.Context, tx *sql.Tx, fetch string, mapper Mapper) (ops []spatial.Op, keys []string, rejected []RowError, err error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(columns) < 2 {
+		return nil, nil, nil, fmt.Errorf("pgio: query returns %d columns, expected a key and a geometry", len(columns))
+	}
+
+	var key string
+	var geom []byte
+	values := make([]any, len(columns)-2)
+	dest := append([]any{&key, &geom}, make([]any, len(values))...)
+	for i := range values {
+		dest[i+2] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, nil, err
+		}
+		p, err := decodeGeometry(geom)
+		if err == nil && mapper != nil {
+			p.Data, err = mapper(values)
+		}
+		if err != nil {
+			rejected = append(rejected, RowError{Key: key, Err: err})
+			continue
+		}
+		ops = append(ops, spatial.Op{Kind: spatial.OpUpsert, ID: key, Point: p})
+		keys = append(keys, key)
+	}
+	return ops, keys, rejected, rows.Err()
+}
+
+// decodeGeometry parses a point from WKB, or from the hex text some drivers
+// return geometry columns as
+func decodeGeometry(geom []byte) (spatial.Point, error) {
+	if geom == nil {
+		return spatial.Point{}, errors.New("no geometry")
+	}
+	if len(geom) >= 2 && (string(geom[:2]) == "00" || string(geom[:2]) == "01") {
+		if b, err := hex.DecodeString(string(geom)); err == nil {
+			geom = b
+		}
+	}
+	return wkt.DecodePoint(geom)
+}
+
+// Table describes the table ExportToTable writes to
+type Table struct {
+	Name     string // Table name, optionally schema-qualified
+	Key      string // Primary key column, "id" if empty
+	Geometry string // Geometry column, "geom" if empty
+	SRID     int    // SRID of the written geometries, none if 0
+	// Columns are further columns to write, with values from Values
+	Columns []string
+	// Values returns a point's values for Columns, in order. It must be set
+	// if Columns is.
+	Values func(spatial.Point) ([]any, error)
+}
+
+// ExportToTable upserts the points in area that are stored under an id
+// into table, keyed by id, and returns how many it wrote. Existing rows
+// have their geometry and Columns replaced, so the key column needs a
+// unique constraint. Rows are written ExportBatch per statement in one
+// transaction: on an error, including ctx being cancelled, nothing is
+// written.
+func ExportToTable(ctx context.Context, db DB, qt *spatial.QuadTree, table Table, area spatial.Bounds) (int, error) {
+	points := qt.SearchIDs(area)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var full string
+	args := make([]any, 0, ExportBatch*(2+len(table.Columns)))
+	for start := 0; start < len(points); start += ExportBatch {
+		batch := points[start:min(start+ExportBatch, len(points))]
+		args = args[:0]
+		for _, p := range batch {
+			args = append(args, p.ID, wkt.EncodePoint(p.Point))
+			if len(table.Columns) == 0 {
+				continue
+			}
+			values, err := table.Values(p.Point)
+			if err != nil {
+				return 0, fmt.Errorf("pgio: values of %s: %w", p.ID, err)
+			}
+			if len(values) != len(table.Columns) {
+				return 0, fmt.Errorf("pgio: %d values of %s for %d columns", len(values), p.ID, len(table.Columns))
+			}
+			args = append(args, values...)
+		}
+		query := full
+		if len(batch) < ExportBatch || full == "" {
+			query = table.upsert(len(batch))
+			if len(batch) == ExportBatch {
+				full = query
+			}
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(points), nil
+}
+
+// upsert returns the statement upserting n rows
+func (t Table) upsert(n int) string {
+	key, geom := cmp.Or(t.Key, "id"), cmp.Or(t.Geometry, "geom")
+	columns := append([]string{quoteIdent(key), quoteIdent(geom)}, make([]string, len(t.Columns))...)
+	for i, c := range t.Columns {
+		columns[i+2] = quoteIdent(c)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", quoteIdent(t.Name), strings.Join(columns, ", "))
+	param := 0
+	for row := 0; row < n; row++ {
+		if row > 0 {
+			b.WriteString(", ")
+		}
+		param += 2
+		if t.SRID != 0 {
+			fmt.Fprintf(&b, "($%d, ST_GeomFromWKB($%d, %d)", param-1, param, t.SRID)
+		} else {
+			fmt.Fprintf(&b, "($%d, ST_GeomFromWKB($%d)", param-1, param)
+		}
+		for range t.Columns {
+			param++
+			fmt.Fprintf(&b, ", $%d", param)
+		}
+		b.WriteByte(')')
+	}
+	fmt.Fprintf(&b, " ON CONFLICT (%s) DO UPDATE SET ", quoteIdent(key))
+	for i, c := range columns[1:] {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s = EXCLUDED.%s", c, c)
+	}
+	return b.String()
+}
+
+// quoteIdent quotes a possibly schema-qualified identifier
+func quoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
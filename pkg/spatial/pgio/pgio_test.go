@@ -0,0 +1,219 @@
+package pgio
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/wkt"
+)
+
+// fakeDB is a database/sql driver that serves rows to one cursor and
+// records what is executed against it
+type fakeDB struct {
+	mu        sync.Mutex
+	columns   []string
+	rows      [][]driver.Value
+	pos       int
+	fetches   int
+	onFetch   func()
+	execs     []string
+	args      [][]driver.Value
+	committed bool
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("fake: no prepare") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return c, nil }
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) { return c, nil }
+
+func (c *fakeConn) Rollback() error { return nil }
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.committed = true
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	c.db.execs = append(c.db.execs, query)
+	c.db.args = append(c.db.args, values)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	fields := strings.Fields(query)
+	if len(fields) != 5 || fields[0] != "FETCH" || fields[4] != loadCursor {
+		return nil, errors.New("fake: unexpected query " + query)
+	}
+	n, _ := strconv.Atoi(fields[2])
+	end := min(c.db.pos+n, len(c.db.rows))
+	rows := &fakeRows{columns: c.db.columns, rows: c.db.rows[c.db.pos:end]}
+	c.db.pos = end
+	c.db.fetches++
+	if c.db.onFetch != nil {
+		c.db.onFetch()
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var bounds = spatial.Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+
+// depotRows returns n rows of key, WKB and name, every third geometry as hex
+// text the way some drivers return geometry columns
+func depotRows(n int) [][]driver.Value {
+	rows := make([][]driver.Value, n)
+	for i := range rows {
+		var geom driver.Value = wkt.EncodePoint(spatial.Point{X: float64(i % 100), Y: float64(i / 100 % 100)})
+		if i%3 == 0 {
+			geom = hex.EncodeToString(geom.([]byte))
+		}
+		rows[i] = []driver.Value{int64(i), geom, "depot " + strconv.Itoa(i)}
+	}
+	return rows
+}
+
+func nameMapper(columns []any) (any, error) {
+	return columns[0], nil
+}
+
+// TestLoadFromQuery tests that rows are read through the cursor a fetch at
+// a time and stored under their keys, with bad rows reported by key
+func TestLoadFromQuery(t *testing.T) {
+	rows := depotRows(2*FetchSize + 10)
+	rows[5][1] = []byte{1, 2, 3}
+	rows[6][1] = nil
+	rows[7][1] = wkt.EncodePoint(spatial.Point{X: 500, Y: 500})
+	fake := &fakeDB{columns: []string{"id", "geom", "name"}, rows: rows}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+
+	qt, err := LoadFromQuery(context.Background(), db, "SELECT id, ST_AsBinary(geom), name FROM depots", nameMapper, bounds)
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || len(loadErr.Rows) != 3 {
+		t.Fatalf("Expected 3 rows skipped, got %v", err)
+	}
+	if !errors.Is(err, wkt.ErrSyntax) || !errors.Is(err, spatial.ErrOutOfBounds) {
+		t.Errorf("Expected the row errors to match, got %v", err)
+	}
+	for i, key := range []string{"5", "6", "7"} {
+		if loadErr.Rows[i].Key != key {
+			t.Errorf("Expected row %s reported, got %s", key, loadErr.Rows[i].Key)
+		}
+	}
+	if qt.Size() != len(rows)-3 {
+		t.Errorf("Expected %d points, got %d", len(rows)-3, qt.Size())
+	}
+	if p, ok := qt.GetByID("4"); !ok || p.X != 4 || p.Data != "depot 4" {
+		t.Errorf("Expected depot 4 at (4, 0), got %v", p)
+	}
+	if p, ok := qt.GetByID("3"); !ok || p.X != 3 {
+		t.Errorf("Expected the hex geometry of depot 3 parsed, got %v", p)
+	}
+	if fake.fetches != 3 || !strings.HasPrefix(fake.execs[0], "DECLARE "+loadCursor+" NO SCROLL CURSOR FOR SELECT") {
+		t.Errorf("Expected 3 fetches from a cursor, got %d after %q", fake.fetches, fake.execs)
+	}
+}
+
+// TestLoadFromQueryCancel tests that cancelling ctx stops the load between
+// fetches
+func TestLoadFromQueryCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fake := &fakeDB{columns: []string{"id", "geom"}, rows: depotRows(3 * FetchSize), onFetch: cancel}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+
+	qt, err := LoadFromQuery(ctx, db, "SELECT id, geom FROM depots", nil, bounds)
+	if qt != nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled load, got %v", err)
+	}
+	if fake.fetches != 1 {
+		t.Errorf("Expected no fetch after cancelling, got %d", fake.fetches)
+	}
+}
+
+// TestExportToTable tests batched upserts of the points stored under ids
+func TestExportToTable(t *testing.T) {
+	qt, _ := spatial.NewQuadTree(bounds)
+	for i := 0; i < ExportBatch+2; i++ {
+		qt.InsertWithID(strconv.Itoa(i), spatial.Point{X: float64(i % 100), Y: 1, Data: i})
+	}
+	qt.Insert(spatial.Point{X: 1, Y: 1})
+	fake := &fakeDB{}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+
+	table := Table{
+		Name:    "fleet.depots",
+		SRID:    4326,
+		Columns: []string{"capacity"},
+		Values:  func(p spatial.Point) ([]any, error) { return []any{p.Data}, nil },
+	}
+	n, err := ExportToTable(context.Background(), db, qt, table, bounds)
+	if err != nil || n != ExportBatch+2 {
+		t.Fatalf("Expected %d rows written, got %d, %v", ExportBatch+2, n, err)
+	}
+	if len(fake.execs) != 2 || !fake.committed {
+		t.Fatalf("Expected 2 statements committed, got %d", len(fake.execs))
+	}
+	want := `INSERT INTO "fleet"."depots" ("id", "geom", "capacity") VALUES ($1, ST_GeomFromWKB($2, 4326), $3), ($4, ST_GeomFromWKB($5, 4326), $6) ` +
+		`ON CONFLICT ("id") DO UPDATE SET "geom" = EXCLUDED."geom", "capacity" = EXCLUDED."capacity"`
+	if fake.execs[1] != want {
+		t.Errorf("Unexpected statement:\n%s\nwant\n%s", fake.execs[1], want)
+	}
+	if len(fake.args[0]) != 3*ExportBatch || len(fake.args[1]) != 6 {
+		t.Errorf("Expected 3 arguments per row, got %d and %d", len(fake.args[0]), len(fake.args[1]))
+	}
+
+	fake.execs = nil
+	table.Values = func(p spatial.Point) ([]any, error) {
+		if p.Data == 7 {
+			return nil, errors.New("no capacity")
+		}
+		return []any{p.Data}, nil
+	}
+	fake.committed = false
+	if _, err := ExportToTable(context.Background(), db, qt, table, bounds); err == nil || !strings.Contains(err.Error(), "values of 7") || fake.committed {
+		t.Errorf("Expected the failing key reported and nothing committed, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package spatial
+
+import "math"
+
+// PolygonContains reports whether p is inside the polygon made of rings, the
+// outer ring first and then any holes, or on its boundary, a hole's
+// included. Rings may be given in either direction and closed or not. Points
+// off the boundary are counted by even-odd ray casting over every ring, with
+// the side of each edge decided by the sign of a cross product rather than a
+// division, so a point is never put on both sides of a shared edge.
+func PolygonContains(rings [][]Point, p Point) bool {
+	inside := false
+	for _, ring := range rings {
+		for i, a := range ring {
+			b := ring[(i+1)%len(ring)]
+			cross := (b.X-a.X)*(p.Y-a.Y) - (p.X-a.X)*(b.Y-a.Y)
+			if cross == 0 && onSegment(a, b, p) {
+				return true
+			}
+			// The half-open test counts a vertex on the ray once, for the
+			// edge running above it, and a horizontal edge never
+			if (a.Y > p.Y) != (b.Y > p.Y) && (cross > 0) == (b.Y > a.Y) {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// onSegment reports whether p, collinear with a and b, lies between them
+func onSegment(a, b, p Point) bool {
+	return p.X >= math.Min(a.X, b.X) && p.X <= math.Max(a.X, b.X) &&
+		p.Y >= math.Min(a.Y, b.Y) && p.Y <= math.Max(a.Y, b.Y)
+}
+
+// SearchPolygon returns every point inside the polygon made of rings, or on
+// its boundary, as PolygonContains decides. The outer ring's bounding box
+// prunes the tree and the polygon filters what it finds; the tree's
+// WithSearchEdges setting doesn't apply, since the boundary is always part
+// of the polygon.
+func (qt *QuadTree) SearchPolygon(rings [][]Point) []Point {
+	if len(rings) == 0 || len(rings[0]) == 0 {
+		return make([]Point, 0)
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, v := range rings[0] {
+		minX, maxX = math.Min(minX, v.X), math.Max(maxX, v.X)
+		minY, maxY = math.Min(minY, v.Y), math.Max(maxY, v.Y)
+	}
+	results := qt.SearchWith(Bounds{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}, InclusiveEdges)
+	kept := results[:0]
+	for _, p := range results {
+		if PolygonContains(rings, p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
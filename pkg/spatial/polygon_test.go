@@ -0,0 +1,94 @@
+package spatial
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestPolygonContains tests a concave polygon with a hole, given open and
+// closed, including points on its vertices and edges
+func TestPolygonContains(t *testing.T) {
+	// An L with a square hole in its foot
+	open := [][]Point{
+		{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 10}, {X: 0, Y: 10}},
+		{{X: 6, Y: 1}, {X: 8, Y: 1}, {X: 8, Y: 3}, {X: 6, Y: 3}},
+	}
+	closed := [][]Point{append(open[0], open[0][0]), append(open[1], open[1][0])}
+	for _, tc := range []struct {
+		p    Point
+		want bool
+	}{
+		{Point{X: 1, Y: 1}, true},
+		{Point{X: 2, Y: 8}, true},
+		{Point{X: 7, Y: 7}, false}, // In the bounding box only
+		{Point{X: 7, Y: 2}, false}, // In the hole
+		{Point{X: 0, Y: 0}, true},  // Vertex
+		{Point{X: 4, Y: 4}, true},  // Reflex vertex
+		{Point{X: 10, Y: 2}, true}, // Edge
+		{Point{X: 4, Y: 7}, true},  // Edge
+		{Point{X: 7, Y: 10}, false},
+		{Point{X: 7, Y: 1}, true}, // Edge of the hole
+		{Point{X: 8, Y: 3}, true}, // Vertex of the hole
+		{Point{X: 2, Y: 4}, true}, // Ray through a vertex
+		{Point{X: -1, Y: 4}, false},
+	} {
+		for name, rings := range map[string][][]Point{"open": open, "closed": closed} {
+			if got := PolygonContains(rings, tc.p); got != tc.want {
+				t.Errorf("%s: PolygonContains(%v, %v): expected %v, got %v", name, tc.p.X, tc.p.Y, tc.want, got)
+			}
+		}
+	}
+	if PolygonContains(nil, Point{}) {
+		t.Error("Expected no rings to contain nothing")
+	}
+}
+
+// TestSearchPolygon tests that SearchPolygon returns exactly the points
+// PolygonContains accepts, boundary included, whatever the tree's edges
+func TestSearchPolygon(t *testing.T) {
+	rings := [][]Point{
+		{{X: 10, Y: 10}, {X: 60, Y: 10}, {X: 60, Y: 40}, {X: 40, Y: 40}, {X: 40, Y: 60}, {X: 10, Y: 60}},
+		{{X: 20, Y: 20}, {X: 30, Y: 20}, {X: 30, Y: 30}, {X: 20, Y: 30}},
+	}
+	for _, edges := range []Edges{InclusiveEdges, HalfOpenEdges} {
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4), WithSearchEdges(edges))
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 2000; i++ {
+			qt.Insert(Point{X: rng.Float64() * 100, Y: rng.Float64() * 100})
+		}
+		// Whole coordinates put points on every edge and vertex
+		for x := 0; x <= 70; x += 5 {
+			for y := 0; y <= 70; y += 5 {
+				qt.Insert(Point{X: float64(x), Y: float64(y)})
+			}
+		}
+
+		want := 0
+		for _, p := range qt.Search(qt.Root.Bounds) {
+			if PolygonContains(rings, p) {
+				want++
+			}
+		}
+		got := qt.SearchPolygon(rings)
+		if len(got) != want {
+			t.Errorf("Edges %d: expected %d points, got %d", edges, want, len(got))
+		}
+		for _, p := range got {
+			if !PolygonContains(rings, p) {
+				t.Errorf("Edges %d: returned %v outside the polygon", edges, p)
+			}
+		}
+		corner := 0
+		for _, p := range got {
+			if p.X == 60 && p.Y == 10 || p.X == 10 && p.Y == 60 {
+				corner++
+			}
+		}
+		if corner != 2 {
+			t.Errorf("Edges %d: expected the vertices on the bounding box's max edges, got %d", edges, corner)
+		}
+	}
+	if got := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1, Height: 1}).SearchPolygon(nil); got == nil || len(got) != 0 {
+		t.Errorf("Expected an empty result for no rings, got %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package spatial
+
+import "sync"
+
+func newNodePool() *sync.Pool {
+	return &sync.Pool{New: func() interface{} { return new(Node) }}
+}
+
+// newChild returns a child of n covering bounds, recycled from the tree's
+// pool when it has one. Settings are inherited from n.
+func (n *Node) newChild(bounds Bounds) *Node {
+	var c *Node
+	if n.pool != nil {
+		c = n.pool.Get().(*Node)
+	} else {
+		c = new(Node)
+	}
+	c.Bounds = bounds
+	c.Capacity = n.Capacity
+	c.Depth = n.Depth + 1
+	c.MaxDepth = n.MaxDepth
+	c.parent = n
+	c.pool = n.pool
+	c.arena = n.arena
+	c.gen = n.gen
+	c.hooks = n.hooks
+	c.policy = n.policy
+	c.adaptive = n.adaptive
+	c.touches = newTouches(n.adaptive)
+	return c
+}
+
+// release hands every node in the subtree back to the pool. Callers must
+// already have moved out any points they want to keep and must drop their
+// references to the subtree. Each node is zeroed so no stale points, children
+// or parent links survive into its next use; only the emptied leaf slice's
+// capacity is kept, unless it goes back to the arena. Nodes still shared with a snapshot are left alone.
+func (n *Node) release() {
+	if n.pool == nil {
+		return
+	}
+	n.releaseChildren()
+	n.resetPoints()
+	if n.arena != nil {
+		n.dropArray()
+	}
+	points := n.Points
+	pool := n.pool
+	*n = Node{Points: points}
+	pool.Put(n)
+}
+
+// releaseChildren releases the children of n that n's generation owns
+func (n *Node) releaseChildren() {
+	for _, c := range n.Children {
+		if c != nil && c.gen == n.gen {
+			c.release()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package spatial
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestReleaseZeroesNodes tests that released nodes carry no stale state
+func TestReleaseZeroesNodes(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+	for i := 0; i < 20; i++ {
+		qt.Insert(Point{X: float64(i * 5), Y: float64(i * 3), Data: i})
+	}
+
+	child := qt.Root.Children[0]
+	var subtree []*Node
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		subtree = append(subtree, n)
+		if n.Children[0] != nil {
+			for _, c := range n.Children {
+				walk(c)
+			}
+		}
+	}
+	walk(child)
+	child.release()
+
+	for _, n := range subtree {
+		if n.Children[0] != nil || len(n.Points) != 0 || n.parent != nil || n.pool != nil || n.count != 0 {
+			t.Errorf("Released node not zeroed: %+v", n)
+		}
+		for _, p := range n.Points[:cap(n.Points)] {
+			if p.Data != nil {
+				t.Errorf("Released node still references point data %v", p.Data)
+			}
+		}
+	}
+}
+
+// TestPooledTreeMatchesUnpooled tests that split/merge churn gives the same results with recycling
+func TestPooledTreeMatchesUnpooled(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	pooled := mustNewQuadTree(bounds, WithCapacity(2))
+	plain := &QuadTree{Root: &Node{Bounds: bounds, Capacity: 2}}
+	rng := rand.New(rand.NewSource(5))
+
+	current := make([]Point, 100)
+	for i := range current {
+		current[i] = Point{X: rng.Float64() * 10, Y: rng.Float64() * 10, Data: i}
+		pooled.Insert(current[i])
+		plain.Insert(current[i])
+	}
+
+	for round := 0; round < 20; round++ {
+		for i := range current {
+			// Points hop between two corners so subtrees split and merge every round
+			offset := 0.0
+			if round%2 == 0 {
+				offset = 90
+			}
+			next := Point{X: offset + rng.Float64()*10, Y: offset + rng.Float64()*10, Data: i}
+			pooled.Update(current[i], next)
+			plain.Update(current[i], next)
+			current[i] = next
+		}
+
+		for q := 0; q < 10; q++ {
+			area := Bounds{X: rng.Float64() * 90, Y: rng.Float64() * 90, Width: 10, Height: 10}
+			if a, b := len(pooled.Search(area)), len(plain.Search(area)); a != b {
+				t.Fatalf("Round %d: pooled tree found %d in %v, unpooled found %d", round, a, area, b)
+			}
+		}
+	}
+	if pooled.Size() != 100 || len(pooled.Search(bounds)) != 100 {
+		t.Errorf("Expected 100 points in pooled tree, got %d", len(pooled.Search(bounds)))
+	}
+}
+
+func benchmarkUpdateChurn(b *testing.B, qt *QuadTree) {
+	// Two clusters with the points bouncing between them force repeated split/merge cycles
+	current := make([]Point, 64)
+	for i := range current {
+		current[i] = Point{X: float64(i % 8), Y: float64(i / 8)}
+		qt.Insert(current[i])
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i % len(current)
+		next := current[j]
+		if next.X < 5000 {
+			next.X += 9000
+		} else {
+			next.X -= 9000
+		}
+		qt.Update(current[j], next)
+		current[j] = next
+	}
+}
+
+func BenchmarkUpdateChurnPooled(b *testing.B) {
+	benchmarkUpdateChurn(b, mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, WithCapacity(4)))
+}
+
+func BenchmarkUpdateChurnUnpooled(b *testing.B) {
+	benchmarkUpdateChurn(b, &QuadTree{Root: &Node{Bounds: Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, Capacity: 4}})
+}
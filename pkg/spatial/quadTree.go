@@ -3,11 +3,17 @@ package spatial
 import (
 	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Point struct {
 	X, Y float64
 	Data interface{}
+	seq  uint64    // Insertion sequence assigned by QuadTree.Insert, 0 if never inserted through the tree
+	loc  *location // Back-reference for points inserted with an ID, kept pointing at the owning leaf
+
+	expires int64 // Unix nanoseconds at which the point expires, 0 if it never does
 }
 type Bounds struct {
 	X      float64 // Top-Left X coordinate
@@ -16,16 +22,90 @@ type Bounds struct {
 	Height float64
 }
 
+// DefaultMaxDepth is used when a node's MaxDepth is left at zero
+const DefaultMaxDepth = 16
+
 type Node struct {
 	Bounds   Bounds //The X,Y height, width of the box
 	Points   []Point
 	Capacity int
+	Depth    int // Root is depth 0
+	MaxDepth int // Leaves at this depth grow past Capacity instead of splitting
 	Children [4]*Node
+	parent   *Node
+	count    int         // Points stored in this subtree
+	pool     *sync.Pool  // Recycles nodes for trees built with NewQuadTree, nil otherwise
+	arena    *arena      // Leaf storage set via WithArena, nil to use the heap
+	gen      uint64      // Generation that owns the node; older nodes are shared with a snapshot
+	hooks    *hookQueue  // Receives subdivide and merge events, nil without hooks
+	policy   SplitPolicy // nil means CapacityPolicy
+	adaptive bool        // Count touches for WithAdaptiveCapacity
+	touches  *touches    // Set when adaptive, see adaptive.go
 }
 
 type QuadTree struct {
-	Root *Node
-	Lock sync.RWMutex
+	Root    *Node
+	Lock    sync.RWMutex    // Root lock, see locking.go
+	quads   [4]sync.RWMutex // One per root quadrant
+	meta    sync.RWMutex    // Tree-wide state during quadrant writes
+	geo     bool            // X/Y are lon/lat degrees, set via WithGeoCoordinates
+	metric  Metric          // Set via WithMetric, nil for the default ranking
+	proj    Projection      // Set via WithProjection, LonLat if nil
+	unit    Length          // Length of a planar unit, set via WithUnit, a meter if 0
+	jumps   *JumpFilter     // Consulted by Upsert, set via WithJumpFilter
+	track   *Track          // Fed by Upsert and read by KNearestPredicted, set via WithTrack
+	nextSeq uint64          // Last sequence number handed out to an inserted point
+	ids     map[string]*location
+	size    int // Points stored through QuadTree methods
+
+	autoExpand bool // Grow the root instead of rejecting out-of-bounds points
+
+	now        func() time.Time // Clock for TTL expiry, time.Now if nil
+	expiring   bool             // Set once a point with a TTL has been inserted
+	sweepEvery time.Duration    // Interval of the background expiry sweep, 0 if disabled
+	stopSweep  chan struct{}
+	closeOnce  sync.Once
+
+	gen      uint64 // Bumped by Snapshot; nodes from earlier generations are copied before writing
+	readOnly bool   // Set on snapshots
+
+	maxPoints int     // Insert limit set via WithMaxPoints, 0 for no limit
+	matchEps  float64 // Coordinate tolerance for Remove and Update, set via WithMatchEpsilon
+	edges     Edges   // Edge semantics used by Search, set via WithSearchEdges
+
+	codec     DataCodec      // Encodes Data for WriteTo and Load, GobCodec if nil
+	lastSaved atomic.Uint64  // Version written by the last SaveToFile, see file.go
+	walConfig *WALConfig     // Set by WithWAL, see wal.go
+	wal       *writeAheadLog // Log of every write, nil without WithWAL
+
+	qcache *queryCache // Set via WithQueryCache, nil without a cache
+
+	filter         *existenceFilter // Set via WithExistenceFilter, nil without a filter
+	filterExpected int
+	filterRate     float64
+
+	adaptMin, adaptMax int // Capacity range set via WithAdaptiveCapacity, 0 when fixed
+
+	version      uint64         // Bumped once by every write that changes the stored points
+	versionOpen  bool           // The write in progress has already bumped version
+	batch        bool           // Apply is running, so its ops share one version
+	keepVersions int            // Past versions retained for At, set via WithVersionHistory
+	history      []versionEntry // Retained versions, oldest first
+
+	lockFree bool                     // Set via WithLockFreeReads
+	current  atomic.Pointer[QuadTree] // Version published to lock-free readers
+
+	dataKey func(Point) (string, bool) // Key extractor set via WithDataKey, nil without an index
+	byKey   map[string][]Point         // Points per data key, in insertion order
+
+	hookFns    hookFuncs // Registered via OnInsert and friends
+	hookBuffer int
+	hooks      *hookQueue // nil unless a hook is registered
+	watchers   []*watcher
+
+	auditSink   Sink // Set via WithAuditSink
+	auditBuffer int
+	audit       *auditQueue // nil without an audit sink
 }
 
 // PointWithDistance is a helper struct for sorting points by distance
@@ -56,34 +136,45 @@ func (n *Node) SubDivide() {
 	w := n.Bounds.Width / 2
 	h := n.Bounds.Height / 2
 	//NW Child
-	n.Children[0] = &Node{
-		Bounds:   Bounds{X: x, Y: y, Width: w, Height: h},
-		Capacity: n.Capacity,
-	}
+	n.Children[0] = n.newChild(Bounds{X: x, Y: y, Width: w, Height: h})
 	//NE Child
-	n.Children[1] = &Node{
-		Bounds:   Bounds{X: x + w, Y: y, Width: w, Height: h},
-		Capacity: n.Capacity,
-	}
+	n.Children[1] = n.newChild(Bounds{X: x + w, Y: y, Width: w, Height: h})
 	//SW Child
-	n.Children[2] = &Node{
-		Bounds:   Bounds{X: x, Y: y + h, Width: w, Height: h},
-		Capacity: n.Capacity,
-	}
+	n.Children[2] = n.newChild(Bounds{X: x, Y: y + h, Width: w, Height: h})
 	//SE Child
-	n.Children[3] = &Node{
-		Bounds:   Bounds{X: x + w, Y: y + h, Width: w, Height: h},
-		Capacity: n.Capacity,
-	}
+	n.Children[3] = n.newChild(Bounds{X: x + w, Y: y + h, Width: w, Height: h})
 	for _, p := range n.Points {
-		for i := 0; i < 4; i++ {
-			if n.Children[i].InsertNode(p) {
-				break
-			}
-		}
+		n.Children[n.quadrant(p)].insert(p)
+	}
+	// Moving the points down is not a write to the children
+	for _, c := range n.Children {
+		c.touches.forgetWrites()
+	}
+	n.dropArray()
+	n.hooks.emit(event{kind: subdivideEvent, bounds: n.Bounds})
+
+}
+
+func (n *Node) maxDepth() int {
+	if n.MaxDepth <= 0 {
+		return DefaultMaxDepth
 	}
-	n.Points = nil
+	return n.MaxDepth
+}
 
+// quadrant is the single routing rule shared by every mutation: children are
+// half-open (inclusive min edge, exclusive max edge), so a point on a split
+// line belongs to the east/south child. Only the root's outer edges, checked
+// with Contains, stay inclusive.
+func (n *Node) quadrant(point Point) int {
+	i := 0
+	if point.X >= n.Bounds.X+n.Bounds.Width/2 {
+		i |= 1
+	}
+	if point.Y >= n.Bounds.Y+n.Bounds.Height/2 {
+		i |= 2
+	}
+	return i
 }
 
 // Internal Function for Inserting a Node
@@ -91,43 +182,51 @@ func (n *Node) InsertNode(point Point) bool {
 	if n.Bounds.Contains(point) == false {
 		return false
 	}
-	if n.Children[0] != nil {
-		for i := 0; i < 4; i++ {
-			if n.Children[i].InsertNode(point) {
-				return true
+	n.insert(point)
+	return true
+}
+
+// insert places a point already known to be inside n, routing by quadrant
+func (n *Node) insert(point Point) {
+	n.count++
+	if n.Children[0] == nil {
+		// Leaf with spare capacity keeps the point, otherwise split once and route below.
+		// At the depth cap the leaf overflows instead, so co-located points can't recurse forever.
+		if !n.splits(len(n.Points) + 1) {
+			n.addPoint(point)
+			n.touchWrite()
+			if point.loc != nil {
+				point.loc.leaf = n
 			}
+			return
 		}
-		return false
-	}
-	if len(n.Points) < n.Capacity && n.Children[0] == nil {
-		n.Points = append(n.Points, point)
-		return true
-	}
-	if n.Children[0] == nil {
 		n.SubDivide()
 	}
-	for i := 0; i < 4; i++ {
-		if n.Children[i].InsertNode(point) {
-			return true
-		}
-	}
-	return false
+	// The point is routed into exactly one child
+	n.writableChild(n.quadrant(point)).insert(point)
 }
 
 // Internal Function for Searching within the Tree
 func (n *Node) SearchTree(searchArea Bounds, resultPoints *[]Point) {
+	n.searchEdges(searchArea, InclusiveEdges, resultPoints)
+}
 
+func (n *Node) searchEdges(searchArea Bounds, edges Edges, resultPoints *[]Point) {
 	if n == nil || !n.Bounds.Intersects(searchArea) {
 		return
 	}
+	if searchArea.containsBounds(n.Bounds) {
+		reserve(resultPoints, n.count)
+	}
 	if n.Children[0] != nil {
 		for i := 0; i < 4; i++ {
-			n.Children[i].SearchTree(searchArea, resultPoints)
+			n.Children[i].searchEdges(searchArea, edges, resultPoints)
 		}
 		return
 	}
+	n.touchRead()
 	for _, p := range n.Points {
-		if searchArea.Contains(p) {
+		if searchArea.containsXY(p.X, p.Y, edges) {
 			*resultPoints = append(*resultPoints, p)
 		}
 	}
@@ -137,47 +236,177 @@ func (n *Node) RemoveNode(point Point) bool {
 	if !n.Bounds.Contains(point) {
 		return false
 	}
-	if n.Children[0] != nil { //If Node isnt a leaf node
-		for i := 0; i < 4; i++ {
-			if n.Children[i].RemoveNode(point) {
-				return true
+	_, ok := n.remove(point, nil)
+	return ok
+}
+
+// match returns the index in this leaf of the record point refers to, or -1
+func (n *Node) match(point Point) int {
+	match := -1
+	for i, exist := range n.Points {
+		if exist.X != point.X || exist.Y != point.Y {
+			continue
+		}
+		if point.seq != 0 {
+			if exist.seq == point.seq {
+				return i
 			}
+			continue
 		}
-		return false
-	}
-	for i, exist := range n.Points {
-		if exist.X == point.X && exist.Y == point.Y { //Switching the found value to the last, and slicing it, as order doesnt matter
-			n.Points[i] = n.Points[len(n.Points)-1]
-			n.Points = n.Points[:len(n.Points)-1]
-			return true
+		if match == -1 || exist.seq < n.Points[match].seq {
+			match = i
 		}
 	}
-	return false
+	return match
+}
+
+// remove follows the same quadrant routing as insert down to the owning leaf.
+// A point carrying a sequence number (i.e. one returned by a query) removes
+// exactly that record; otherwise the earliest inserted point at those
+// coordinates is removed. stop is passed on to afterRemove.
+func (n *Node) remove(point Point, stop *Node) (Point, bool) {
+	if n.Children[0] != nil { //If Node isnt a leaf node
+		return n.writableChild(n.quadrant(point)).remove(point, stop)
+	}
+	match := n.match(point)
+	if match == -1 {
+		return Point{}, false
+	}
+	removed := n.Points[match]
+	n.deletePoint(match)
+	n.touchWrite()
+	n.afterRemove(stop)
+	return removed, true
 
 }
 
+// Update moves the record oldPoint refers to onto newPoint. It reports
+// whether the update happened; UpdateE returns the reason it did not.
 func (qt *QuadTree) Update(oldPoint, newPoint Point) bool {
+	return qt.UpdateE(oldPoint, newPoint) == nil
+}
+
+// UpdateE is Update returning ErrInvalidPoint or ErrOutOfBounds for a newPoint
+// that can't be stored, ErrNotFound if oldPoint is not in the tree, or
+// ErrReadOnly on a snapshot.
+func (qt *QuadTree) UpdateE(oldPoint, newPoint Point) error {
+	if err := qt.updateShared(oldPoint, newPoint); err != errExclusive {
+		return err
+	}
 	qt.Lock.Lock()
-	defer qt.Lock.Unlock()
+	defer qt.unlock()
+	return qt.update(oldPoint, newPoint)
+}
+
+// update moves oldPoint to newPoint. Callers must hold the write lock.
+func (qt *QuadTree) update(oldPoint, newPoint Point) error {
+	if !qt.own() {
+		return ErrReadOnly
+	}
 	// Validate new point is within bounds before removing old point
-	if !qt.Root.Bounds.Contains(newPoint) {
-		return false
+	if err := qt.place(newPoint); err != nil {
+		return err
+	}
+	oldPoint, ok := qt.resolve(oldPoint)
+	if !ok || !qt.Root.Bounds.Contains(oldPoint) {
+		return ErrNotFound
 	}
-	if qt.Root.RemoveNode(oldPoint) {
-		if qt.Root.InsertNode(newPoint) {
-			return true
+	from, to, ok := qt.Root.move(oldPoint, newPoint, nil)
+	if !ok {
+		return ErrNotFound
+	}
+	qt.moved(from, to)
+	return nil
+}
+
+// move moves the record oldPoint refers to onto newPoint, both inside n, and
+// returns the record before and after; the moved record keeps its identity.
+// stop is passed on to afterRemove.
+func (n *Node) move(oldPoint, newPoint Point, stop *Node) (from, to Point, ok bool) {
+	// Walk both positions down together; if they never part ways the point
+	// stays in the same leaf and can be rewritten in place.
+	leaf := n
+	for leaf.Children[0] != nil {
+		q := leaf.quadrant(oldPoint)
+		if leaf.quadrant(newPoint) != q {
+			leaf = nil
+			break
 		}
-		//re-insert old point if new insert failed
-		qt.Root.InsertNode(oldPoint)
-		return false
+		leaf = leaf.writableChild(q)
 	}
-	return false
+	if leaf != nil {
+		i := leaf.match(oldPoint)
+		if i == -1 {
+			return Point{}, Point{}, false
+		}
+		from = leaf.Points[i]
+		newPoint.seq = from.seq
+		newPoint.loc = from.loc
+		newPoint.expires = from.expires
+		leaf.setPoint(i, newPoint)
+		leaf.touchWrite()
+		return from, newPoint, true
+	}
+
+	from, ok = n.remove(oldPoint, stop)
+	if !ok {
+		return Point{}, Point{}, false
+	}
+	newPoint.seq = from.seq
+	newPoint.loc = from.loc
+	newPoint.expires = from.expires
+	n.insert(newPoint)
+	return from, newPoint, true
 }
 
+// Remove deletes the record point refers to and reports whether it was found
 func (qt *QuadTree) Remove(point Point) bool {
+	return qt.RemoveE(point) == nil
+}
+
+// RemoveE is Remove returning ErrNotFound if no record matches point, or
+// ErrReadOnly on a snapshot.
+func (qt *QuadTree) RemoveE(point Point) error {
+	_, err := qt.takeAny(point)
+	return err
+}
+
+// Take removes the record point refers to, like Remove, and returns the
+// stored point including its Data.
+func (qt *QuadTree) Take(point Point) (Point, bool) {
+	removed, err := qt.takeAny(point)
+	return removed, err == nil
+}
+
+// takeAny is take under whichever lock the point allows
+func (qt *QuadTree) takeAny(point Point) (Point, error) {
+	if removed, err := qt.takeShared(point); err != errExclusive {
+		return removed, err
+	}
 	qt.Lock.Lock()
-	defer qt.Lock.Unlock()
-	return qt.Root.RemoveNode(point)
+	defer qt.unlock()
+	return qt.take(point)
+}
+
+// take removes and returns the record point refers to. Callers must hold the write lock.
+func (qt *QuadTree) take(point Point) (Point, error) {
+	if !qt.own() {
+		return Point{}, ErrReadOnly
+	}
+	point, ok := qt.resolve(point)
+	if !ok || !qt.Root.Bounds.Contains(point) {
+		return Point{}, ErrNotFound
+	}
+	removed, ok := qt.Root.remove(point, nil)
+	if !ok {
+		return Point{}, ErrNotFound
+	}
+	if removed.loc != nil {
+		qt.dropLoc(removed.loc)
+	}
+	qt.size--
+	qt.removed(removed)
+	return removed, nil
 }
 
 func (qt *QuadTree) Insert(point Point) bool {
@@ -185,28 +414,81 @@ func (qt *QuadTree) Insert(point Point) bool {
 		Public Accessible API for Inserting New Points into the QuadTree,
 		(Much Less Contention in Comparison to the Read Operations)
 	*/
+	return qt.InsertE(point) == nil
+}
+
+// InsertE is Insert returning ErrInvalidPoint for NaN or infinite coordinates,
+// ErrOutOfBounds outside the root, ErrTreeFull once WithMaxPoints is reached,
+// or ErrReadOnly on a snapshot.
+func (qt *QuadTree) InsertE(point Point) error {
+	if err := qt.insertShared(point, nil); err != errExclusive {
+		return err
+	}
 	qt.Lock.Lock()
-	defer qt.Lock.Unlock()
-	res := qt.Root.InsertNode(point)
-	return res
+	defer qt.unlock()
+	return qt.insertPoint(point)
+}
 
+// insertPoint stores point as a new record. Callers must hold the write lock.
+func (qt *QuadTree) insertPoint(point Point) error {
+	if err := qt.admit(point); err != nil {
+		return err
+	}
+	qt.nextSeq++
+	point.seq = qt.nextSeq
+	point.loc = nil
+	point.expires = 0
+	qt.Root.insert(point)
+	qt.size++
+	qt.inserted(point)
+	return nil
+}
+
+// Size returns how many points are stored. Points placed by calling Node
+// methods on Root directly are not counted.
+func (qt *QuadTree) Size() int {
+	if view := qt.view(); view != nil {
+		return view.size
+	}
+	qt.rlockMeta()
+	defer qt.runlockMeta()
+	return qt.size
 }
 
+// Search returns every point inside area, using the tree's edge semantics.
+// Like every query returning []Point, it returns an empty slice, never nil,
+// when nothing matches, and the slice is freshly allocated, so the caller may
+// modify it without touching the tree.
 func (qt *QuadTree) Search(area Bounds) []Point {
-	/*
-		Public Accessible API to search within the QuadTree
-	*/
-	qt.Lock.RLock()
-	defer qt.Lock.RUnlock()
-	results := make([]Point, 0)
-	qt.Root.SearchTree(area, &results)
-	return results
+	return qt.SearchWith(area, qt.edges)
 }
 
 func Distance(p1, p2 Point) float64 {
+	return math.Sqrt(DistanceSquared(p1, p2))
+}
+
+// DistanceSquared returns the squared planar distance between two points. It
+// ranks points the same way as Distance without the square root, so ordering
+// and pruning should compare it rather than Distance. Like Distance it
+// overflows to +Inf once the coordinate gap passes about 1e154.
+func DistanceSquared(p1, p2 Point) float64 {
 	dx := p2.X - p1.X
 	dy := p2.Y - p1.Y
-	return math.Sqrt(dx*dx + dy*dy)
+	return dx*dx + dy*dy
+}
+
+// withinSquared reports whether a point at squared distance d2 lies within
+// radius, whose square is r2, exactly as math.Sqrt(d2) <= radius would. Only
+// near the boundary, where rounding in r2 could flip the answer, or when r2
+// has underflowed, is the square root taken.
+func withinSquared(d2, radius, r2 float64) bool {
+	switch {
+	case d2 < r2*(1-1e-12):
+		return true
+	case d2 > r2*(1+1e-12) && r2 >= 0x1p-1022:
+		return false
+	}
+	return math.Sqrt(d2) <= radius
 }
 
 func sortByDistance(points []PointWithDistance) {
@@ -214,7 +496,9 @@ func sortByDistance(points []PointWithDistance) {
 		key := points[i]
 		j := i - 1
 
-		for j >= 0 && points[j].Distance > key.Distance {
+		// Equal distances fall back to insertion order so co-located points rank deterministically
+		for j >= 0 && (points[j].Distance > key.Distance ||
+			(points[j].Distance == key.Distance && points[j].Point.seq > key.Point.seq)) {
 			points[j+1] = points[j]
 			j--
 		}
@@ -222,16 +506,33 @@ func sortByDistance(points []PointWithDistance) {
 	}
 }
 
+// KNearest returns the k points closest to target, nearest first, with ties
+// ranked by insertion order. It returns an empty slice, never nil, when k <= 0
+// or the tree is empty.
 func (qt *QuadTree) KNearest(target Point, k int) []Point {
 	if k <= 0 {
 		return make([]Point, 0)
 	}
+	if qt.geo && qt.metric == nil {
+		return qt.kNearestGeoPoint(target, k)
+	}
+	if view := qt.view(); view != nil {
+		return view.kNearestAppend(target, k, make([]Point, 0))
+	}
 
-	qt.Lock.RLock()
-	defer qt.Lock.RUnlock()
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.kNearestAppend(target, k, make([]Point, 0))
+}
 
+// kNearestAppend appends the result of KNearest to dst, using dst's spare
+// capacity for the candidates. Callers must hold the lock.
+func (qt *QuadTree) kNearestAppend(target Point, k int, dst []Point) []Point {
+	if qt.metric != nil {
+		return qt.kNearestMetric(target, k, qt.metric, dst)
+	}
 	if qt.Root == nil {
-		return make([]Point, 0)
+		return dst
 	}
 
 	maxPoints := k * 10
@@ -243,7 +544,7 @@ func (qt *QuadTree) KNearest(target Point, k int) []Point {
 	initialRadius := 10.0
 	searchRadius := initialRadius
 	maxRadius := math.Max(qt.Root.Bounds.Width, qt.Root.Bounds.Height) * 2
-	var results []Point
+	start := len(dst)
 
 	for searchRadius <= maxRadius {
 
@@ -254,45 +555,64 @@ func (qt *QuadTree) KNearest(target Point, k int) []Point {
 			Height: searchRadius * 2,
 		}
 
-		results = make([]Point, 0)
-		qt.Root.SearchTree(searchBounds, &results)
+		dst = dst[:start]
+		qt.searchLive(searchBounds, InclusiveEdges, &dst)
+		found := len(dst) - start
 
-		if len(results) >= k {
+		if found >= k {
 			break
 		}
 
 		if searchRadius >= maxRadius {
 			break
 		}
-		if len(results) > maxPoints {
+		if found > maxPoints {
 			break
 		}
 
 		searchRadius *= 2
 	}
 
+	results := dst[start:]
 	if len(results) == 0 {
-		return make([]Point, 0)
+		return dst
 	}
 
-	pointsWithDist := make([]PointWithDistance, len(results))
-	for i, p := range results {
-		pointsWithDist[i] = PointWithDistance{
-			Point:    p,
-			Distance: Distance(target, p),
+	// Ranking by squared distance gives the same order without a sqrt per point
+	scratch := distancePool.Get().(*[]PointWithDistance)
+	pointsWithDist := (*scratch)[:0]
+	rank := func() {
+		pointsWithDist = pointsWithDist[:0]
+		for _, p := range results {
+			pointsWithDist = append(pointsWithDist, PointWithDistance{
+				Point:    p,
+				Distance: DistanceSquared(target, p),
+			})
 		}
+		sortByDistance(pointsWithDist)
 	}
-
-	sortByDistance(pointsWithDist)
-
-	if len(pointsWithDist) > k {
-		pointsWithDist = pointsWithDist[:k]
+	rank()
+
+	// The box holds k points, but those in its corners can be further away
+	// than points just outside its sides; widening it to the k-th distance
+	// takes in every point that could be nearer
+	if len(results) >= k {
+		if kth := math.Sqrt(pointsWithDist[k-1].Distance); kth > searchRadius {
+			kth = math.Nextafter(kth, math.Inf(1))
+			dst = dst[:start]
+			qt.searchLive(Bounds{X: target.X - kth, Y: target.Y - kth, Width: kth * 2, Height: kth * 2}, InclusiveEdges, &dst)
+			results = dst[start:]
+			rank()
+		}
 	}
 
-	finalResults := make([]Point, len(pointsWithDist))
-	for i, pd := range pointsWithDist {
-		finalResults[i] = pd.Point
+	n := min(k, len(pointsWithDist))
+	for i, pd := range pointsWithDist[:n] {
+		results[i] = pd.Point
 	}
+	clear(pointsWithDist)
+	*scratch = pointsWithDist[:0]
+	distancePool.Put(scratch)
 
-	return finalResults
+	return dst[:start+n]
 }
@@ -3,7 +3,10 @@ package spatial
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -350,6 +353,243 @@ func TestQuadTreeInsertOutOfBounds(t *testing.T) {
 	}
 }
 
+// TestQuadTreeInsertAtCapacityNoDuplicates tests that filling a leaf exactly stores each point once without splitting
+func TestQuadTreeInsertAtCapacityNoDuplicates(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 4,
+		},
+	}
+
+	for i := 0; i < 4; i++ {
+		qt.Insert(Point{X: float64(10 + i*20), Y: 50, Data: i})
+
+		results := qt.Search(qt.Root.Bounds)
+		if len(results) != i+1 {
+			t.Fatalf("After %d inserts, Search returned %d points", i+1, len(results))
+		}
+	}
+
+	if qt.Root.Children[0] != nil {
+		t.Error("Root should not subdivide while within capacity")
+	}
+}
+
+// TestQuadTreeInsertSinglePointNoSubdivide tests that a single point never spawns children
+func TestQuadTreeInsertSinglePointNoSubdivide(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 1,
+		},
+	}
+
+	qt.Insert(Point{X: 25, Y: 25, Data: "only"})
+
+	if qt.Root.Children[0] != nil {
+		t.Error("A node holding a single point should not subdivide")
+	}
+}
+
+// TestQuadTreeInsertPastCapacityNoDuplicates tests that splitting never duplicates points
+func TestQuadTreeInsertPastCapacityNoDuplicates(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 2,
+		},
+	}
+
+	inserted := 0
+	for i := 0; i < 50; i++ {
+		if qt.Insert(Point{X: float64(i*7%100) + 0.5, Y: float64(i*13%100) + 0.5, Data: i}) {
+			inserted++
+		}
+
+		results := qt.Search(qt.Root.Bounds)
+		if len(results) > inserted {
+			t.Fatalf("Search returned %d points but only %d were inserted", len(results), inserted)
+		}
+	}
+
+	results := qt.Search(qt.Root.Bounds)
+	if len(results) != inserted {
+		t.Errorf("Expected %d points, got %d", inserted, len(results))
+	}
+
+	seen := make(map[interface{}]bool)
+	for _, r := range results {
+		if seen[r.Data] {
+			t.Errorf("Point %v stored more than once", r.Data)
+		}
+		seen[r.Data] = true
+	}
+}
+
+// TestQuadTreeInsertRepeatedCoordinate tests inserting the same coordinate repeatedly
+func TestQuadTreeInsertRepeatedCoordinate(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 4,
+		},
+	}
+
+	for i := 0; i < 4; i++ {
+		qt.Insert(Point{X: 30, Y: 30, Data: i})
+	}
+
+	results := qt.Search(qt.Root.Bounds)
+	if len(results) != 4 {
+		t.Errorf("Expected 4 points, got %d", len(results))
+	}
+}
+
+// TestQuadTreeSplitLinePointsStoredOnce tests points exactly on the split lines of a subdivided node
+func TestQuadTreeSplitLinePointsStoredOnce(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 1,
+		},
+	}
+
+	// Force a split, then add points on the vertical, horizontal and centre split lines
+	qt.Insert(Point{X: 10, Y: 10, Data: "seed1"})
+	qt.Insert(Point{X: 90, Y: 90, Data: "seed2"})
+	if qt.Root.Children[0] == nil {
+		t.Fatal("Tree should be subdivided")
+	}
+
+	onLines := []Point{
+		{X: 50, Y: 20, Data: "vertical"},
+		{X: 20, Y: 50, Data: "horizontal"},
+		{X: 50, Y: 50, Data: "centre"},
+		{X: 100, Y: 50, Data: "outer-edge"},
+	}
+	for _, p := range onLines {
+		if !qt.Insert(p) {
+			t.Fatalf("Failed to insert %v", p.Data)
+		}
+	}
+
+	stored := 0
+	for _, child := range qt.Root.Children {
+		var inChild []Point
+		child.SearchTree(qt.Root.Bounds, &inChild)
+		for _, p := range inChild {
+			for _, l := range onLines {
+				if p.Data == l.Data {
+					stored++
+				}
+			}
+		}
+	}
+	if stored != len(onLines) {
+		t.Errorf("Expected split-line points stored %d times in total, got %d", len(onLines), stored)
+	}
+
+	results := qt.Search(qt.Root.Bounds)
+	if len(results) != 6 {
+		t.Errorf("Expected 6 points, got %d", len(results))
+	}
+
+	for _, p := range onLines {
+		if !qt.Remove(p) {
+			t.Errorf("Failed to remove split-line point %v", p.Data)
+		}
+	}
+
+	results = qt.Search(qt.Root.Bounds)
+	if len(results) != 2 {
+		t.Errorf("Expected 2 points after removal, got %d", len(results))
+	}
+}
+
+// TestQuadTreeSplitLineUpdate tests moving points onto and off a split line
+func TestQuadTreeSplitLineUpdate(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 1,
+		},
+	}
+
+	qt.Insert(Point{X: 10, Y: 10, Data: "seed"})
+	p := Point{X: 30, Y: 30, Data: "mover"}
+	qt.Insert(p)
+
+	onLine := Point{X: 50, Y: 30, Data: "mover"}
+	if !qt.Update(p, onLine) {
+		t.Fatal("Failed to move point onto split line")
+	}
+
+	results := qt.Search(Bounds{X: 50, Y: 0, Width: 0, Height: 100})
+	if len(results) != 1 {
+		t.Errorf("Expected 1 point on the split line, got %d", len(results))
+	}
+
+	back := Point{X: 70, Y: 70, Data: "mover"}
+	if !qt.Update(onLine, back) {
+		t.Error("Failed to move point off split line")
+	}
+	if len(qt.Search(qt.Root.Bounds)) != 2 {
+		t.Error("Expected 2 points after updates")
+	}
+}
+
+// TestQuadTreeIdenticalPointsCapacityOne tests that co-located points stop splitting at the depth cap
+func TestQuadTreeIdenticalPointsCapacityOne(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 1,
+		},
+	}
+
+	for i := 0; i < 50; i++ {
+		if !qt.Insert(Point{X: 42, Y: 42, Data: i}) {
+			t.Fatalf("Failed to insert point %d", i)
+		}
+	}
+
+	results := qt.Search(qt.Root.Bounds)
+	if len(results) != 50 {
+		t.Errorf("Expected 50 points, got %d", len(results))
+	}
+}
+
+// TestQuadTreeMaxDepthRespected tests that no node is created below MaxDepth and children track depth
+func TestQuadTreeMaxDepthRespected(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1), WithMaxDepth(3))
+
+	for i := 0; i < 20; i++ {
+		qt.Insert(Point{X: 1, Y: 1, Data: i})
+	}
+
+	var walk func(n *Node, depth int)
+	walk = func(n *Node, depth int) {
+		if n.Depth != depth {
+			t.Errorf("Node depth %d, expected %d", n.Depth, depth)
+		}
+		if n.Depth > 3 {
+			t.Errorf("Node at depth %d exceeds MaxDepth 3", n.Depth)
+		}
+		if n.Children[0] == nil {
+			return
+		}
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+	}
+	walk(qt.Root, 0)
+
+	if len(qt.Search(qt.Root.Bounds)) != 20 {
+		t.Error("Expected all 20 points to be stored")
+	}
+}
+
 // TestQuadTreeSearchBasic tests basic search functionality
 func TestQuadTreeSearchBasic(t *testing.T) {
 	qt := &QuadTree{
@@ -507,6 +747,8 @@ func TestQuadTreeConcurrentInsert(t *testing.T) {
 	if len(results) != expectedPoints {
 		t.Errorf("Expected %d points, got %d", expectedPoints, len(results))
 	}
+
+	checkValid(t, qt)
 }
 
 // TestQuadTreeConcurrentSearchInsert tests concurrent search and insert operations
@@ -536,12 +778,17 @@ func TestQuadTreeConcurrentSearchInsert(t *testing.T) {
 			defer wg.Done()
 			for i := 0; i < 20; i++ {
 				searchArea := Bounds{X: float64(readerID * 100), Y: float64(readerID * 100), Width: 200, Height: 200}
-				_ = qt.Search(searchArea)
+				for _, p := range qt.Search(searchArea) {
+					if !searchArea.Contains(p) {
+						t.Errorf("Search(%v) returned %v outside the area", searchArea, p)
+					}
+				}
 			}
 		}(r)
 	}
 
-	// Writer goroutines
+	// Writer goroutines; writers 3 and 4 start past the bounds and are rejected
+	var inserted atomic.Int64
 	for w := 0; w < numWriters; w++ {
 		wg.Add(1)
 		go func(writerID int) {
@@ -549,12 +796,24 @@ func TestQuadTreeConcurrentSearchInsert(t *testing.T) {
 			for i := 0; i < 20; i++ {
 				x := float64(writerID*200+i) + 500
 				y := float64(writerID*200+i) + 500
-				qt.Insert(Point{X: x, Y: y, Data: fmt.Sprintf("w%d_p%d", writerID, i)})
+				if qt.Insert(Point{X: x, Y: y, Data: fmt.Sprintf("w%d_p%d", writerID, i)}) {
+					inserted.Add(1)
+				}
 			}
 		}(w)
 	}
 
 	wg.Wait()
+
+	if got := inserted.Load(); got != 60 {
+		t.Errorf("Expected 60 in-bounds inserts to succeed, got %d", got)
+	}
+	results := qt.Search(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000})
+	if want := 100 + int(inserted.Load()); len(results) != want {
+		t.Errorf("Expected %d points, got %d", want, len(results))
+	}
+
+	checkValid(t, qt)
 }
 
 // TestQuadTreeSearchWithData tests that point data is preserved
@@ -1019,6 +1278,97 @@ func TestQuadTreeUpdateAfterSubdivision(t *testing.T) {
 	}
 }
 
+// TestQuadTreeUpdateWithinLeafInPlace tests that a small move leaves the tree structure untouched
+func TestQuadTreeUpdateWithinLeafInPlace(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 2,
+		},
+	}
+
+	points := []Point{
+		{X: 10, Y: 10, Data: "a"},
+		{X: 20, Y: 20, Data: "b"},
+		{X: 80, Y: 80, Data: "c"},
+	}
+	for _, p := range points {
+		qt.Insert(p)
+	}
+	leaf := qt.Root.Children[0]
+	before := qt.Stats()
+
+	if !qt.Update(points[0], Point{X: 12, Y: 11, Data: "a2"}) {
+		t.Fatal("Update failed")
+	}
+
+	if qt.Root.Children[0] != leaf || qt.Stats().Nodes != before.Nodes {
+		t.Error("An in-leaf move should not change the tree structure")
+	}
+	results := qt.Search(Bounds{X: 11, Y: 10, Width: 2, Height: 2})
+	if len(results) != 1 || results[0].Data != "a2" {
+		t.Errorf("Expected updated point at new position, got %v", results)
+	}
+	if len(qt.Search(Bounds{X: 9, Y: 9, Width: 2, Height: 2})) != 0 {
+		t.Error("Old position should be empty")
+	}
+	if !qt.Remove(results[0]) {
+		t.Error("Updated point should be removable")
+	}
+}
+
+// TestQuadTreeUpdateAcrossSplitLine tests moving a point from just inside a leaf onto the shared edge
+func TestQuadTreeUpdateAcrossSplitLine(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 1,
+		},
+	}
+
+	qt.Insert(Point{X: 90, Y: 90, Data: "anchor"})
+	p := Point{X: 49.999, Y: 10, Data: "mover"}
+	qt.Insert(p)
+
+	// x = 50 is the west edge of the NE child, so the point has to change leaves
+	moved := Point{X: 50, Y: 10, Data: "mover"}
+	if !qt.Update(p, moved) {
+		t.Fatal("Update across the split line failed")
+	}
+
+	var inNE []Point
+	qt.Root.Children[1].SearchTree(qt.Root.Bounds, &inNE)
+	if len(inNE) != 1 || inNE[0].Data != "mover" {
+		t.Errorf("Expected mover in the NE child, got %v", inNE)
+	}
+	var inNW []Point
+	qt.Root.Children[0].SearchTree(qt.Root.Bounds, &inNW)
+	if len(inNW) != 0 {
+		t.Errorf("NW child should be empty, got %v", inNW)
+	}
+	if !qt.Remove(moved) {
+		t.Error("Point on the split line should be removable")
+	}
+}
+
+// TestQuadTreeUpdateInPlaceMissingPoint tests that the in-leaf path still fails for unknown points
+func TestQuadTreeUpdateInPlaceMissingPoint(t *testing.T) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 4,
+		},
+	}
+	qt.Insert(Point{X: 10, Y: 10})
+
+	if qt.Update(Point{X: 11, Y: 11}, Point{X: 12, Y: 12}) {
+		t.Error("Update of a missing point should fail")
+	}
+	if len(qt.Search(Bounds{X: 11.5, Y: 11.5, Width: 1, Height: 1})) != 0 {
+		t.Error("Failed update must not insert the new point")
+	}
+}
+
 // TestQuadTreeRemoveFromDifferentQuadrants tests removing points from various quadrants
 func TestQuadTreeRemoveFromDifferentQuadrants(t *testing.T) {
 	qt := &QuadTree{
@@ -1088,6 +1438,121 @@ func TestQuadTreeRemoveSameName(t *testing.T) {
 	}
 }
 
+// newColocatedTree builds a tree whose single leaf holds 10 points at (50, 50) with Data 0..9
+func newColocatedTree() *QuadTree {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+			Capacity: 16,
+		},
+	}
+	for i := 0; i < 10; i++ {
+		qt.Insert(Point{X: 50, Y: 50, Data: i})
+	}
+	return qt
+}
+
+// TestQuadTreeColocatedRemoveByCoordinate tests that a coordinate-only Remove takes the earliest insert
+func TestQuadTreeColocatedRemoveByCoordinate(t *testing.T) {
+	qt := newColocatedTree()
+
+	for want := 0; want < 3; want++ {
+		if !qt.Remove(Point{X: 50, Y: 50}) {
+			t.Fatalf("Remove %d failed", want)
+		}
+		results := qt.Search(qt.Root.Bounds)
+		if len(results) != 9-want {
+			t.Fatalf("Expected %d points after removal, got %d", 9-want, len(results))
+		}
+		for _, r := range results {
+			if r.Data.(int) <= want {
+				t.Errorf("Point %v should have been removed first", r.Data)
+			}
+		}
+	}
+}
+
+// TestQuadTreeColocatedRemoveQueriedPoint tests that removing a queried point removes exactly that record
+func TestQuadTreeColocatedRemoveQueriedPoint(t *testing.T) {
+	qt := newColocatedTree()
+
+	var target Point
+	for _, r := range qt.Search(qt.Root.Bounds) {
+		if r.Data == 7 {
+			target = r
+		}
+	}
+
+	if !qt.Remove(target) {
+		t.Fatal("Failed to remove queried point")
+	}
+	if qt.Remove(target) {
+		t.Error("Removing the same record twice should fail")
+	}
+
+	results := qt.Search(qt.Root.Bounds)
+	if len(results) != 9 {
+		t.Fatalf("Expected 9 points, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Data == 7 {
+			t.Error("Point 7 should have been removed")
+		}
+	}
+}
+
+// TestQuadTreeColocatedUpdateMovesRightRecord tests that Update moves the queried record only
+func TestQuadTreeColocatedUpdateMovesRightRecord(t *testing.T) {
+	qt := newColocatedTree()
+
+	var target Point
+	for _, r := range qt.Search(qt.Root.Bounds) {
+		if r.Data == 4 {
+			target = r
+		}
+	}
+
+	if !qt.Update(target, Point{X: 10, Y: 10, Data: 4}) {
+		t.Fatal("Update failed")
+	}
+
+	moved := qt.Search(Bounds{X: 5, Y: 5, Width: 10, Height: 10})
+	if len(moved) != 1 || moved[0].Data != 4 {
+		t.Fatalf("Expected point 4 at the new position, got %v", moved)
+	}
+	for _, r := range qt.Search(Bounds{X: 45, Y: 45, Width: 10, Height: 10}) {
+		if r.Data == 4 {
+			t.Error("Point 4 should no longer be at the old position")
+		}
+	}
+
+	// The moved record keeps its identity and can be moved again
+	if !qt.Update(moved[0], Point{X: 20, Y: 20, Data: 4}) {
+		t.Error("Second update of the moved record failed")
+	}
+}
+
+// TestQuadTreeColocatedKNearest tests that co-located points each count toward k in insertion order
+func TestQuadTreeColocatedKNearest(t *testing.T) {
+	qt := newColocatedTree()
+	qt.Insert(Point{X: 60, Y: 60, Data: "far"})
+
+	results := qt.KNearest(Point{X: 50, Y: 50}, 4)
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	for i, r := range results {
+		if r.Data != i {
+			t.Errorf("Result %d: expected Data %d, got %v", i, i, r.Data)
+		}
+	}
+
+	results = qt.KNearest(Point{X: 50, Y: 50}, 11)
+	if len(results) != 11 || results[10].Data != "far" {
+		t.Errorf("Expected all 10 co-located points before the far point, got %v", results)
+	}
+}
+
 // TestQuadTreeUpdatePreservesDataInSearchArea tests update preserves point data
 func TestQuadTreeUpdatePreservesDataInSearchArea(t *testing.T) {
 	qt := &QuadTree{
@@ -1172,6 +1637,8 @@ func TestQuadTreeConcurrentUpdateRemove(t *testing.T) {
 	}
 
 	wg.Wait()
+
+	checkValid(t, qt)
 }
 
 // BenchmarkRemove benchmarks removal performance
@@ -1200,6 +1667,38 @@ func BenchmarkRemove(b *testing.B) {
 }
 
 // BenchmarkUpdate benchmarks update performance
+func BenchmarkUpdateSmallDisplacement(b *testing.B) {
+	qt := &QuadTree{
+		Root: &Node{
+			Bounds:   Bounds{X: 0, Y: 0, Width: 10000, Height: 10000},
+			Capacity: 10,
+		},
+	}
+
+	// Pre-populate
+	points := make([]Point, 10000)
+	for i := range points {
+		x := float64(i%100)*100 + 10
+		y := float64((i/100)%100)*100 + 10
+		points[i] = Point{X: x, Y: y, Data: nil}
+		qt.Insert(points[i])
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Drivers creep a few units back and forth, staying in their leaf
+		j := i % len(points)
+		next := points[j]
+		if (i/len(points))%2 == 0 {
+			next.X += 3
+		} else {
+			next.X -= 3
+		}
+		qt.Update(points[j], next)
+		points[j] = next
+	}
+}
+
 func BenchmarkUpdate(b *testing.B) {
 	qt := &QuadTree{
 		Root: &Node{
@@ -1247,6 +1746,75 @@ func TestQuadTreeEmptySearch(t *testing.T) {
 	}
 }
 
+// TestQueriesReturnEmptyNotNil tests that every query returning []Point
+// gives an empty slice rather than nil when nothing matches
+func TestQueriesReturnEmptyNotNil(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	qt := mustNewQuadTree(bounds, WithCapacity(1), WithDataKey(func(p Point) (string, bool) { return "k", true }))
+	qt.Insert(Point{X: 10, Y: 10})
+	qt.Insert(Point{X: 20, Y: 20})
+	miss := Bounds{X: 80, Y: 80, Width: 5, Height: 5}
+	far := Point{X: 90, Y: 90}
+	empty := mustNewQuadTree(bounds)
+	geo := mustNewQuadTree(Bounds{X: -180, Y: -90, Width: 360, Height: 180}, WithGeoCoordinates())
+	frozen, emptyFrozen := qt.Freeze(), empty.Freeze()
+	morton := mustNewMortonIndex(bounds, nil)
+	onlyA, onlyB := Diff(qt, qt)
+	view, _ := qt.At(qt.Version())
+
+	results := map[string][]Point{
+		"Search":                  qt.Search(miss),
+		"SearchWith":              qt.SearchWith(miss, HalfOpenEdges),
+		"SearchParallel":          qt.SearchParallel(miss, 4),
+		"SearchOriented":          qt.SearchOriented(far, 5, 5, 0.5),
+		"SearchRadius":            qt.SearchRadius(far, 1),
+		"SearchRadiusGeo":         geo.SearchRadiusGeo(GeoPoint{}, 1000),
+		"KNearest empty":          empty.KNearest(far, 3),
+		"KNearestGeo empty":       geo.KNearestGeo(GeoPoint{}, 3),
+		"FindByKey":               qt.FindByKey("missing"),
+		"ReadView.Search":         view.Search(miss),
+		"FrozenTree.Search":       frozen.Search(miss),
+		"FrozenTree.SearchRadius": frozen.SearchRadius(far, 1),
+		"FrozenTree.KNearest":     emptyFrozen.KNearest(far, 3),
+		"MortonIndex.Search":      morton.Search(miss),
+		"MortonIndex.KNearest":    morton.KNearest(far, 3),
+		"Diff onlyA":              onlyA,
+		"Diff onlyB":              onlyB,
+	}
+	for name, got := range results {
+		if got == nil || len(got) != 0 {
+			t.Errorf("%s: expected a non-nil empty slice, got %#v", name, got)
+		}
+	}
+}
+
+// TestSearchResultsDoNotAliasTree tests that modifying query results leaves
+// the stored points untouched
+func TestSearchResultsDoNotAliasTree(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+	qt := mustNewQuadTree(bounds, WithCapacity(8))
+	for i := 0; i < 4; i++ {
+		qt.Insert(Point{X: float64(i), Y: float64(i), Data: i})
+	}
+	frozen := qt.Freeze()
+
+	for name, search := range map[string]func() []Point{
+		"QuadTree":   func() []Point { return qt.Search(bounds) },
+		"FrozenTree": func() []Point { return frozen.Search(bounds) },
+	} {
+		got := search()
+		for i := range got {
+			got[i].X, got[i].Data = -1, "changed"
+		}
+		_ = append(got[:1], Point{X: -2})
+		for _, p := range search() {
+			if p.X < 0 || p.Data == "changed" {
+				t.Errorf("%s: modifying results changed the stored point to %v", name, p)
+			}
+		}
+	}
+}
+
 // TestBoundsIntersectsSymmetry tests that intersection is symmetric
 func TestBoundsIntersectsSymmetry(t *testing.T) {
 	bounds1 := Bounds{X: 0, Y: 0, Width: 10, Height: 10}
@@ -1359,6 +1927,80 @@ func TestDistanceSymmetry(t *testing.T) {
 	}
 }
 
+// TestDistanceSquared tests that DistanceSquared matches Distance squared and
+// overflows the same way at huge coordinates
+func TestDistanceSquared(t *testing.T) {
+	p1 := Point{X: -1, Y: 2}
+	p2 := Point{X: 2, Y: 6}
+	if got := DistanceSquared(p1, p2); got != 25 {
+		t.Errorf("DistanceSquared() = %v, want 25", got)
+	}
+
+	huge := Point{X: math.MaxFloat64 / 4, Y: math.MaxFloat64 / 4}
+	if d2, d := DistanceSquared(Point{}, huge), Distance(Point{}, huge); !math.IsInf(d2, 1) || !math.IsInf(d, 1) {
+		t.Errorf("Expected both distances to overflow to +Inf, got %v and %v", d2, d)
+	}
+}
+
+// TestWithinSquaredMatchesSqrt tests the squared radius check against the
+// sqrt comparison it replaces, including boundary, overflow and underflow cases
+func TestWithinSquaredMatchesSqrt(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	radii := []float64{0, 0.1, 1, 3, 1e-200, 1e200, math.MaxFloat64}
+	for i := 0; i < 1000; i++ {
+		radii = append(radii, rng.Float64()*100)
+	}
+	for _, r := range radii {
+		r2 := r * r
+		for _, d2 := range []float64{0, r2, math.Nextafter(r2, 0), math.Nextafter(r2, math.Inf(1)), r2 / 2, r2 * 2, 1e-320, math.Inf(1)} {
+			if got, want := withinSquared(d2, r, r2), math.Sqrt(d2) <= r; got != want {
+				t.Errorf("withinSquared(%v, %v, %v) = %v, want %v", d2, r, r2, got, want)
+			}
+		}
+	}
+}
+
+// TestKNearestMatchesBruteForce tests that ranking by squared distance keeps
+// the order Distance gives, ties broken by insertion order
+func TestKNearestMatchesBruteForce(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+	rng := rand.New(rand.NewSource(7))
+	var all []Point
+	for i := 0; i < 500; i++ {
+		// Integer coordinates give plenty of equal distances
+		p := Point{X: float64(rng.Intn(100)), Y: float64(rng.Intn(100)), Data: i}
+		qt.Insert(p)
+		all = append(all, p)
+	}
+	frozen := qt.Freeze()
+	for i := 0; i < 50; i++ {
+		target := Point{X: float64(rng.Intn(100)), Y: float64(rng.Intn(100))}
+		want := append([]Point(nil), all...)
+		sort.SliceStable(want, func(a, b int) bool { return Distance(target, want[a]) < Distance(target, want[b]) })
+		want = want[:10]
+		for name, got := range map[string][]Point{"QuadTree": qt.KNearest(target, 10), "FrozenTree": frozen.KNearest(target, 10)} {
+			for j := range want {
+				if got[j].Data != want[j].Data {
+					t.Fatalf("%s.KNearest(%v)[%d] = %v, want %v", name, target, j, got[j], want[j])
+				}
+			}
+		}
+	}
+}
+
+// TestKNearestBoxCorners tests that points in the corners of the search box
+// don't shadow nearer ones just outside its sides
+func TestKNearestBoxCorners(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: -100, Y: -100, Width: 200, Height: 200})
+	for i := 0; i < 3; i++ {
+		qt.Insert(Point{X: 9, Y: 9, Data: "corner"}) // 12.7 away, inside the first box
+	}
+	qt.Insert(Point{X: 11, Y: 0, Data: "side"}) // 11 away, just outside it
+	if got := qt.KNearest(Point{}, 1); len(got) != 1 || got[0].Data != "side" {
+		t.Errorf("expected the point on the side, got %v", got)
+	}
+}
+
 // TestKNearestBasic tests basic k-nearest neighbor search
 func TestKNearestBasic(t *testing.T) {
 	qt := &QuadTree{
@@ -1415,6 +2057,9 @@ func TestKNearestZeroK(t *testing.T) {
 	if len(result) != 0 {
 		t.Errorf("Expected 0 results for k=0, got %d", len(result))
 	}
+	if result == nil {
+		t.Error("KNearest should return empty slice, not nil")
+	}
 }
 
 // TestKNearestNegativeK tests with negative k
@@ -1433,6 +2078,9 @@ func TestKNearestNegativeK(t *testing.T) {
 	if len(result) != 0 {
 		t.Errorf("Expected 0 results for negative k, got %d", len(result))
 	}
+	if result == nil {
+		t.Error("KNearest should return empty slice, not nil")
+	}
 }
 
 // TestKNearestEmptyTree tests k-nearest on empty tree
@@ -1449,6 +2097,9 @@ func TestKNearestEmptyTree(t *testing.T) {
 	if len(result) != 0 {
 		t.Errorf("Expected 0 results from empty tree, got %d", len(result))
 	}
+	if result == nil {
+		t.Error("KNearest should return empty slice, not nil")
+	}
 }
 
 // TestKNearestMoreThanAvailable tests when k > available points
@@ -1734,6 +2385,8 @@ func TestKNearestConcurrent(t *testing.T) {
 	}
 
 	wg.Wait()
+
+	checkValid(t, qt)
 }
 
 // BenchmarkKNearest benchmarks k-nearest neighbor search
@@ -1785,3 +2438,95 @@ func BenchmarkKNearestSmallK(b *testing.B) {
 		_ = qt.KNearest(target, 3)
 	}
 }
+
+// TestQuadTreeSizeRandomWorkload checks Size against a full Search after a randomized mixed workload
+func TestQuadTreeSizeRandomWorkload(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(3))
+	rng := rand.New(rand.NewSource(99))
+
+	randomPoint := func() Point {
+		// Some points land outside the bounds and must be rejected without counting
+		return Point{X: rng.Float64()*120 - 10, Y: rng.Float64()*120 - 10}
+	}
+
+	var live []Point
+	var entries []*Entry
+	nextID := 0
+	for i := 0; i < 3000; i++ {
+		switch rng.Intn(9) {
+		case 0, 1:
+			p := randomPoint()
+			if qt.Insert(p) {
+				live = append(live, p)
+			}
+		case 2:
+			if len(live) > 0 {
+				j := rng.Intn(len(live))
+				qt.Remove(live[j])
+				live = append(live[:j], live[j+1:]...)
+			} else {
+				qt.Remove(randomPoint())
+			}
+		case 3:
+			if len(live) > 0 {
+				j := rng.Intn(len(live))
+				np := randomPoint()
+				if qt.Update(live[j], np) {
+					live[j] = np
+				}
+			}
+		case 4:
+			qt.InsertWithID(fmt.Sprintf("id%d", nextID), randomPoint())
+			nextID++
+		case 5:
+			qt.RemoveByID(fmt.Sprintf("id%d", rng.Intn(nextID+1)))
+		case 6:
+			if e := qt.InsertEntry(randomPoint()); e != nil {
+				entries = append(entries, e)
+			}
+		case 7:
+			if len(entries) > 0 {
+				qt.RemoveEntry(entries[rng.Intn(len(entries))])
+			}
+		case 8:
+			batch := []Point{randomPoint(), randomPoint(), randomPoint()}
+			qt.InsertAll(batch)
+		}
+
+		if got, want := qt.Size(), len(qt.Search(qt.Root.Bounds)); got != want {
+			t.Fatalf("Step %d: Size() = %d, full Search found %d", i, got, want)
+		}
+	}
+
+	qt.Clear()
+	if qt.Size() != 0 {
+		t.Errorf("Expected Size 0 after Clear, got %d", qt.Size())
+	}
+}
+
+// TestQuadTreeSizeConcurrent checks Size stays exact under concurrent inserts and removes
+func TestQuadTreeSizeConcurrent(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				p := Point{X: float64(g*100 + i%100), Y: float64(i), Data: g}
+				qt.Insert(p)
+				if i%4 == 0 {
+					qt.Remove(p)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if got, want := qt.Size(), len(qt.Search(qt.Root.Bounds)); got != want || got != 8*150 {
+		t.Errorf("Size() = %d, Search found %d, expected %d", got, want, 8*150)
+	}
+
+	checkValid(t, qt)
+}
@@ -0,0 +1,170 @@
+package spatial
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithQueryCache caches the results of Search and SearchAppend for up to
+// maxEntries areas, evicting the least recently used. Entries are keyed by
+// the exact area and edge semantics. Every insert, removal or move that
+// touches a cached area, at either end of a move, drops the entry before
+// the write's lock is released, so a stale result is never served; Clear
+// and Compact drop every entry. Entries holding points with a TTL lapse when
+// the first of them expires. A hit returns the points in the order they were
+// cached, which a write elsewhere in a shared leaf may have changed for a
+// fresh search; Search promises no order either way. Each write checks every entry, so keep
+// maxEntries to the handful of areas queried over and over. Lock-free reads,
+// snapshots and read views bypass the cache.
+func WithQueryCache(maxEntries int) Option {
+	return func(qt *QuadTree) {
+		qt.qcache = &queryCache{max: maxEntries, entries: make(map[cacheKey]*list.Element)}
+	}
+}
+
+// QueryCacheStats counts the query cache's activity since the tree was built
+type QueryCacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Invalidations uint64 // Entries dropped by writes
+	Evictions     uint64 // Entries dropped to stay within maxEntries
+	Entries       int    // Entries currently cached
+}
+
+// QueryCacheStats returns the query cache's counters, all zero without
+// WithQueryCache
+func (qt *QuadTree) QueryCacheStats() QueryCacheStats {
+	c := qt.qcache
+	if c == nil {
+		return QueryCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+type cacheKey struct {
+	area  Bounds
+	edges Edges
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	points  []Point
+	expires int64 // Earliest expiry among points, 0 if none expire
+}
+
+// queryCache is an LRU of search results. Its own mutex guards it, since
+// readers holding only read locks fill it concurrently.
+type queryCache struct {
+	mu      sync.Mutex
+	max     int
+	lru     list.List // Front is the most recently used
+	entries map[cacheKey]*list.Element
+	stats   QueryCacheStats
+}
+
+// get appends the cached result for key to dst, reporting false on a miss.
+// now is the current time for trees with expiring points, otherwise 0.
+func (c *queryCache) get(key cacheKey, now int64, dst *[]Point) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok {
+		e := el.Value.(*cacheEntry)
+		if e.expires != 0 && now >= e.expires {
+			c.drop(el)
+			ok = false
+		} else {
+			c.lru.MoveToFront(el)
+			*dst = append(*dst, e.points...)
+		}
+	}
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	return ok
+}
+
+// put caches a copy of points as the result for key. Callers must still hold
+// the read locks the search ran under, so no write can slip in between.
+func (c *queryCache) put(key cacheKey, points []Point) {
+	e := &cacheEntry{key: key, points: append(make([]Point, 0, len(points)), points...)}
+	for _, p := range points {
+		if p.expires != 0 && (e.expires == 0 || p.expires < e.expires) {
+			e.expires = p.expires
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.max {
+		c.drop(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// invalidate drops every entry whose area holds p. Callers hold the write
+// lock of the region p is in.
+func (c *queryCache) invalidate(p Point) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if key := el.Value.(*cacheEntry).key; key.area.ContainsWith(p, key.edges) {
+			c.drop(el)
+			c.stats.Invalidations++
+		}
+		el = next
+	}
+}
+
+// purge drops every entry
+func (c *queryCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Invalidations += uint64(c.lru.Len())
+	c.lru.Init()
+	clear(c.entries)
+}
+
+func (c *queryCache) drop(el *list.Element) {
+	delete(c.entries, el.Value.(*cacheEntry).key)
+	c.lru.Remove(el)
+}
+
+// searchCached is searchLive through the query cache. Callers must hold the
+// read locks for area.
+func (qt *QuadTree) searchCached(area Bounds, edges Edges, results *[]Point) {
+	if qt.qcache == nil {
+		qt.searchLive(area, edges, results)
+		return
+	}
+	key := cacheKey{area: area, edges: edges}
+	now := int64(0)
+	if qt.expiring {
+		now = qt.clock().UnixNano()
+	}
+	if qt.qcache.get(key, now, results) {
+		return
+	}
+	start := len(*results)
+	qt.searchLive(area, edges, results)
+	qt.qcache.put(key, (*results)[start:])
+}
@@ -0,0 +1,197 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestQueryCacheHitsAndMisses tests that a repeated Search is served from the
+// cache with the same result, which the caller may modify freely
+func TestQueryCacheHitsAndMisses(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithQueryCache(8))
+	for i := 0; i < 20; i++ {
+		qt.Insert(Point{X: float64(i * 5), Y: float64(i * 5), Data: i})
+	}
+	zone := Bounds{X: 10, Y: 10, Width: 30, Height: 30}
+
+	first := qt.Search(zone)
+	second := qt.Search(zone)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("Cached result %v differs from %v", second, first)
+	}
+	second[0].Data = "changed"
+	if got := qt.Search(zone); got[0].Data == "changed" {
+		t.Error("Modifying a cached result changed the cache")
+	}
+	buf := qt.SearchAppend(zone, make([]Point, 0, 16))
+	if !reflect.DeepEqual(buf, first) {
+		t.Errorf("SearchAppend from the cache = %v, want %v", buf, first)
+	}
+
+	stats := qt.QueryCacheStats()
+	if stats.Misses != 1 || stats.Hits != 3 || stats.Entries != 1 {
+		t.Errorf("Expected 1 miss, 3 hits and 1 entry, got %+v", stats)
+	}
+}
+
+// TestQueryCacheInvalidation tests that every kind of write touching a cached
+// area, and only those, drops the entry
+func TestQueryCacheInvalidation(t *testing.T) {
+	zone := Bounds{X: 10, Y: 10, Width: 20, Height: 20}
+	inside, outside := Point{X: 15, Y: 15, Data: "in"}, Point{X: 80, Y: 80, Data: "out"}
+
+	writes := []struct {
+		name    string
+		touches bool
+		write   func(qt *QuadTree)
+	}{
+		{"Insert inside", true, func(qt *QuadTree) { qt.Insert(Point{X: 20, Y: 20}) }},
+		{"Insert outside", false, func(qt *QuadTree) { qt.Insert(Point{X: 90, Y: 90}) }},
+		{"Remove inside", true, func(qt *QuadTree) { qt.Remove(inside) }},
+		{"Remove outside", false, func(qt *QuadTree) { qt.Remove(outside) }},
+		{"Update out of the area", true, func(qt *QuadTree) { qt.Update(inside, Point{X: 70, Y: 70, Data: "in"}) }},
+		{"Update into the area", true, func(qt *QuadTree) { qt.Update(outside, Point{X: 25, Y: 25, Data: "out"}) }},
+		{"Update within the area", true, func(qt *QuadTree) { qt.Update(inside, Point{X: 16, Y: 16, Data: "in"}) }},
+		{"Update outside", false, func(qt *QuadTree) { qt.Update(outside, Point{X: 81, Y: 81, Data: "out"}) }},
+		{"Move by ID out of the area", true, func(qt *QuadTree) { qt.Move("tracked", 60, 60) }},
+		{"InsertAll", true, func(qt *QuadTree) { qt.InsertAll([]Point{{X: 11, Y: 11}, {X: 95, Y: 5}}) }},
+		{"Apply", true, func(qt *QuadTree) { qt.Apply([]Op{{Kind: OpInsert, Point: Point{X: 12, Y: 12}}}) }},
+		{"Clear", true, func(qt *QuadTree) { qt.Clear() }},
+	}
+	for _, w := range writes {
+		t.Run(w.name, func(t *testing.T) {
+			qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithQueryCache(8))
+			qt.Insert(inside)
+			qt.Insert(outside)
+			qt.InsertWithID("tracked", Point{X: 22, Y: 22})
+			for i := 0; i < 10; i++ {
+				qt.Insert(Point{X: float64(40 + i*5), Y: float64(5 + i)})
+			}
+
+			qt.Search(zone)
+			w.write(qt)
+			got := qt.Search(zone)
+			want := make([]Point, 0)
+			qt.Root.searchEdges(zone, qt.edges, &want)
+			if !samePoints(got, want) {
+				t.Fatalf("Search after the write = %v, want %v", got, want)
+			}
+			if hit := qt.QueryCacheStats().Hits == 1; hit == w.touches {
+				t.Errorf("Expected a cache hit after the write to be %v, got %v", !w.touches, hit)
+			}
+		})
+	}
+}
+
+// TestQueryCacheMatchesUncached replays random writes and zone queries
+// against a cached tree and an uncached twin
+func TestQueryCacheMatchesUncached(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+	cached := mustNewQuadTree(bounds, WithCapacity(4), WithQueryCache(4))
+	plain := mustNewQuadTree(bounds, WithCapacity(4))
+	zones := []Bounds{
+		{X: 100, Y: 100, Width: 200, Height: 200},
+		{X: 450, Y: 450, Width: 100, Height: 100},
+		{X: 0, Y: 500, Width: 500, Height: 500},
+		{X: 700, Y: 0, Width: 50, Height: 900},
+		{X: 200, Y: 200, Width: 600, Height: 10},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("v%d", i)
+		p := Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+		cached.InsertWithID(id, p)
+		plain.InsertWithID(id, p)
+	}
+
+	for step := 0; step < 5000; step++ {
+		if rng.Intn(3) == 0 {
+			id := fmt.Sprintf("v%d", rng.Intn(200))
+			x, y := rng.Float64()*1000, rng.Float64()*1000
+			cached.Move(id, x, y)
+			plain.Move(id, x, y)
+		}
+		zone := zones[rng.Intn(len(zones))]
+		if got, want := cached.Search(zone), plain.Search(zone); !samePoints(got, want) {
+			t.Fatalf("Step %d: cached Search(%v) = %v, want %v", step, zone, got, want)
+		}
+	}
+	if stats := cached.QueryCacheStats(); stats.Hits == 0 || stats.Evictions == 0 {
+		t.Errorf("Expected both hits and evictions, got %+v", stats)
+	}
+}
+
+// samePoints compares points by what callers see, ignoring the tree-specific
+// bookkeeping that differs between twins. Search order is unspecified, and a
+// write elsewhere in a shared leaf can reorder a fresh search, so both sides
+// are sorted by insertion order first.
+func samePoints(a, b []Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].seq < a[j].seq })
+	sort.Slice(b, func(i, j int) bool { return b[i].seq < b[j].seq })
+	for i := range a {
+		if a[i].X != b[i].X || a[i].Y != b[i].Y || a[i].Data != b[i].Data || a[i].seq != b[i].seq {
+			return false
+		}
+	}
+	return true
+}
+
+// TestQueryCacheBoundAndExpiry tests the LRU bound and that entries lapse
+// with the points they hold
+func TestQueryCacheBoundAndExpiry(t *testing.T) {
+	clock := newFakeClock()
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithQueryCache(2), WithClock(clock.Now))
+	qt.InsertWithTTL(Point{X: 5, Y: 5}, time.Minute)
+	a, b, c := Bounds{X: 0, Y: 0, Width: 10, Height: 10}, Bounds{X: 0, Y: 0, Width: 20, Height: 20}, Bounds{X: 50, Y: 50, Width: 10, Height: 10}
+
+	qt.Search(a)
+	qt.Search(b)
+	qt.Search(a) // a is now the most recently used
+	qt.Search(c) // evicts b
+	if stats := qt.QueryCacheStats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("Expected 2 entries after 1 eviction, got %+v", stats)
+	}
+	qt.Search(a)
+	if hits := qt.QueryCacheStats().Hits; hits != 2 {
+		t.Errorf("Expected a to stay cached, got %d hits", hits)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if got := qt.Search(a); len(got) != 0 {
+		t.Errorf("Expected the cached point to expire, got %v", got)
+	}
+
+	if _, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 1, Height: 1}, WithQueryCache(0)); !errors.Is(err, ErrInvalidCacheSize) {
+		t.Errorf("Expected ErrInvalidCacheSize, got %v", err)
+	}
+}
+
+func BenchmarkSearchZoneCached(b *testing.B)   { benchmarkSearchZone(b, WithQueryCache(16)) }
+func BenchmarkSearchZoneUncached(b *testing.B) { benchmarkSearchZone(b) }
+
+// benchmarkSearchZone queries a few fixed zones, as a dispatch tick does
+func benchmarkSearchZone(b *testing.B, opts ...Option) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}, append(opts, WithCapacity(10))...)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		qt.Insert(Point{X: rng.Float64() * 10000, Y: rng.Float64() * 10000})
+	}
+	zones := []Bounds{
+		{X: 1000, Y: 1000, Width: 500, Height: 500},
+		{X: 5000, Y: 2000, Width: 800, Height: 300},
+		{X: 7000, Y: 7000, Width: 400, Height: 400},
+	}
+	buf := make([]Point, 0, 4096)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = qt.SearchAppend(zones[i%len(zones)], buf[:0])
+	}
+}
@@ -0,0 +1,431 @@
+// Package redisstore mirrors a tree's points to Redis as they change, so a
+// standby can rebuild the tree from Redis rather than from the last
+// snapshot. Points are kept in a hash, keyed by the id they are stored
+// under, and optionally in a geo set for other Redis clients to query.
+// Points inserted without an id are not mirrored.
+//
+// The package talks to Redis through go-redis's redis.Cmdable, so a
+// *redis.Client, *redis.ClusterClient, *redis.Ring or redis.UniversalClient
+// all work.
+package redisstore
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+const (
+	// DefaultPrefix is the key prefix used when Config.Prefix is empty
+	DefaultPrefix = "spatial"
+	// DefaultQueue is how many mutations may wait to be mirrored before new
+	// ones are dropped, unless Config.Queue says otherwise
+	DefaultQueue = 4096
+	// DefaultBatch is how many commands are pipelined per round trip, and how
+	// many hash entries are scanned at a time, unless Config.Batch says
+	// otherwise
+	DefaultBatch = 256
+)
+
+// Config configures a Mirror, RestoreFromRedis and Reconcile, which must
+// agree on Prefix and Codec
+type Config struct {
+	Prefix string            // Key prefix, DefaultPrefix if empty; points live in <prefix>:points
+	Geo    bool              // Also keep a geo set, <prefix>:geo; X and Y must be longitude and latitude
+	Codec  spatial.DataCodec // Encodes Data, spatial.GobCodec if nil
+	Queue  int               // Mutations queued for mirroring, DefaultQueue if 0
+	Batch  int               // Commands per pipeline, DefaultBatch if 0
+	// Timeout bounds each pipeline the Mirror sends, 5 seconds if 0
+	Timeout time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Prefix == "" {
+		c.Prefix = DefaultPrefix
+	}
+	if c.Codec == nil {
+		c.Codec = spatial.GobCodec{}
+	}
+	if c.Queue <= 0 {
+		c.Queue = DefaultQueue
+	}
+	if c.Batch <= 0 {
+		c.Batch = DefaultBatch
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	return c
+}
+
+func (c Config) pointsKey() string { return c.Prefix + ":points" }
+func (c Config) geoKey() string    { return c.Prefix + ":geo" }
+
+// Mirror writes a tree's mutations to Redis on a separate goroutine,
+// pipelining whatever has queued up since the last round trip. Register it
+// with the tree through Options. When more than the queue's worth of
+// mutations are waiting, new ones are dropped rather than stalling the
+// tree's hooks; Dropped counts them, and Reconcile finds what they left
+// out of date.
+type Mirror struct {
+	client redis.Cmdable
+	cfg    Config
+	queue  chan mutation
+	done   chan struct{}
+
+	mu     sync.Mutex // Guards closed and err
+	closed bool
+	err    error
+
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// mutation is a point to write under id, or a removal of id
+type mutation struct {
+	id     string
+	remove bool
+	point  spatial.Point
+}
+
+// NewMirror starts a mirror writing to client
+func NewMirror(client redis.Cmdable, cfg Config) *Mirror {
+	cfg = cfg.withDefaults()
+	m := &Mirror{
+		client: client,
+		cfg:    cfg,
+		queue:  make(chan mutation, cfg.Queue),
+		done:   make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// Options returns the hooks that feed the mirror, to pass to
+// spatial.NewQuadTree or Load. They take the tree's OnInsert, OnRemove and
+// OnMove hooks. Hook events the tree itself drops, counted in its
+// DroppedEvents, never reach the mirror.
+func (m *Mirror) Options() []spatial.Option {
+	return []spatial.Option{
+		spatial.OnInsert(func(p spatial.Point) { m.enqueue(p, false) }),
+		spatial.OnRemove(func(p spatial.Point) { m.enqueue(p, true) }),
+		spatial.OnMove(func(_, to spatial.Point) { m.enqueue(to, false) }),
+	}
+}
+
+func (m *Mirror) enqueue(p spatial.Point, remove bool) {
+	id, ok := p.ID()
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	select {
+	case m.queue <- mutation{id: id, remove: remove, point: p}:
+	default:
+		m.dropped.Add(1)
+	}
+}
+
+// command queues one Redis command of a mirrored mutation on a pipeline
+type command func(ctx context.Context, pipe redis.Pipeliner)
+
+func (m *Mirror) run() {
+	defer close(m.done)
+	cmds := make([]command, 0, m.cfg.Batch)
+	for mut := range m.queue {
+		cmds = m.append(cmds[:0], mut)
+	drain:
+		for len(cmds) < m.cfg.Batch {
+			select {
+			case next, ok := <-m.queue:
+				if !ok {
+					break drain
+				}
+				cmds = m.append(cmds, next)
+			default:
+				break drain
+			}
+		}
+		if len(cmds) == 0 {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+		_, err := m.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, cmd := range cmds {
+				cmd(ctx, pipe)
+			}
+			return nil
+		})
+		cancel()
+		if err != nil {
+			m.fail(err)
+		}
+	}
+}
+
+// fail records a lost pipeline or mutation
+func (m *Mirror) fail(err error) {
+	m.failed.Add(1)
+	m.mu.Lock()
+	m.err = err
+	m.mu.Unlock()
+}
+
+// append adds the commands that mirror mut to cmds
+func (m *Mirror) append(cmds []command, mut mutation) []command {
+	points, geo := m.cfg.pointsKey(), m.cfg.geoKey()
+	if mut.remove {
+		cmds = append(cmds, func(ctx context.Context, pipe redis.Pipeliner) { pipe.HDel(ctx, points, mut.id) })
+		if m.cfg.Geo {
+			cmds = append(cmds, func(ctx context.Context, pipe redis.Pipeliner) { pipe.ZRem(ctx, geo, mut.id) })
+		}
+		return cmds
+	}
+	value, err := encodePoint(mut.point, m.cfg.Codec)
+	if err != nil {
+		m.fail(fmt.Errorf("redisstore: encoding %s: %w", mut.id, err))
+		return cmds
+	}
+	cmds = append(cmds, func(ctx context.Context, pipe redis.Pipeliner) { pipe.HSet(ctx, points, mut.id, value) })
+	if m.cfg.Geo {
+		location := &redis.GeoLocation{Name: mut.id, Longitude: mut.point.X, Latitude: mut.point.Y}
+		cmds = append(cmds, func(ctx context.Context, pipe redis.Pipeliner) { pipe.GeoAdd(ctx, geo, location) })
+	}
+	return cmds
+}
+
+// Close mirrors the mutations already queued and stops the mirror. Close
+// the tree first, so that its hooks have delivered everything.
+func (m *Mirror) Close() error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.mu.Unlock()
+	<-m.done
+	return m.Err()
+}
+
+// Dropped returns how many mutations were dropped because the queue was full
+func (m *Mirror) Dropped() uint64 {
+	return m.dropped.Load()
+}
+
+// Failed returns how many pipelines or point encodings failed; their
+// mutations are lost, as if dropped
+func (m *Mirror) Failed() uint64 {
+	return m.failed.Load()
+}
+
+// Err returns the most recent failure, if any
+func (m *Mirror) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Reconcile compares Redis with qt using the mirror's configuration; see
+// the package-level Reconcile
+func (m *Mirror) Reconcile(ctx context.Context, qt *spatial.QuadTree) (Report, error) {
+	return Reconcile(ctx, m.client, qt, m.cfg)
+}
+
+// RecordError reports a mirrored point RestoreFromRedis could not load
+type RecordError struct {
+	ID  string
+	Err error
+}
+
+func (e RecordError) Error() string {
+	return fmt.Sprintf("redisstore: point %s: %v", e.ID, e.Err)
+}
+
+func (e RecordError) Unwrap() error {
+	return e.Err
+}
+
+// RestoreError lists the points RestoreFromRedis skipped
+type RestoreError struct {
+	Points []RecordError
+}
+
+func (e *RestoreError) Error() string {
+	return fmt.Sprintf("redisstore: %d points skipped, the first %v", len(e.Points), e.Points[0])
+}
+
+func (e *RestoreError) Unwrap() []error {
+	errs := make([]error, len(e.Points))
+	for i, p := range e.Points {
+		errs[i] = p
+	}
+	return errs
+}
+
+// RestoreFromRedis stores every mirrored point in qt under its id,
+// replacing a point already stored under it, and returns how many it
+// stored. The hash is scanned Batch entries at a time, each page applied
+// to qt in one batch. Points that can't be decoded or stored are skipped
+// and reported in a *RestoreError.
+func RestoreFromRedis(ctx context.Context, client redis.Cmdable, qt *spatial.QuadTree, cfg Config) (int, error) {
+	cfg = cfg.withDefaults()
+	var skipped []RecordError
+	restored := 0
+	err := scanPoints(ctx, client, cfg, func(page []record) {
+		ops := make([]spatial.Op, 0, len(page))
+		ids := make([]string, 0, len(page))
+		for _, r := range page {
+			p, err := decodePoint(r.value, cfg.Codec)
+			if err != nil {
+				skipped = append(skipped, RecordError{ID: r.id, Err: err})
+				continue
+			}
+			ops = append(ops, spatial.Op{Kind: spatial.OpUpsert, ID: r.id, Point: p})
+			ids = append(ids, r.id)
+		}
+		for i, err := range qt.Apply(ops) {
+			if err != nil {
+				skipped = append(skipped, RecordError{ID: ids[i], Err: err})
+			} else {
+				restored++
+			}
+		}
+	})
+	if err != nil {
+		return restored, err
+	}
+	if len(skipped) > 0 {
+		return restored, &RestoreError{Points: skipped}
+	}
+	return restored, nil
+}
+
+// Report lists the ids on which Redis and a tree disagree
+type Report struct {
+	Missing []string // Stored in the tree, not in Redis
+	Extra   []string // In Redis, not stored in the tree
+	Stale   []string // In both, with a different position or Data
+}
+
+// Clean reports whether Redis and the tree agree
+func (r Report) Clean() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Stale) == 0
+}
+
+// Reconcile compares the mirrored points with the points qt stores under
+// ids, comparing Data in its encoded form. The tree is read once, up
+// front, and Redis is scanned afterwards, so mutations made meanwhile, or
+// still queued in a Mirror, show up as discrepancies; compare again before
+// acting on one. Reconcile reports only and changes neither side.
+func Reconcile(ctx context.Context, client redis.Cmdable, qt *spatial.QuadTree, cfg Config) (Report, error) {
+	cfg = cfg.withDefaults()
+	var report Report
+	live := make(map[string][]byte)
+	for _, p := range qt.SearchIDs(qt.Root.Bounds) {
+		value, err := encodePoint(p.Point, cfg.Codec)
+		if err != nil {
+			return report, fmt.Errorf("redisstore: encoding %s: %w", p.ID, err)
+		}
+		live[p.ID] = value
+	}
+	err := scanPoints(ctx, client, cfg, func(page []record) {
+		for _, r := range page {
+			value, ok := live[r.id]
+			switch {
+			case !ok:
+				report.Extra = append(report.Extra, r.id)
+			case string(value) != r.value:
+				report.Stale = append(report.Stale, r.id)
+			}
+			delete(live, r.id)
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+	for id := range live {
+		report.Missing = append(report.Missing, id)
+	}
+	return report, nil
+}
+
+// record is one entry of the points hash
+type record struct {
+	id, value string
+}
+
+// scanPoints runs HSCAN over the points hash, passing each page to visit.
+// An entry may be passed more than once if the hash changes during the scan.
+func scanPoints(ctx context.Context, client redis.Cmdable, cfg Config, visit func([]record)) error {
+	var cursor uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries, next, err := client.HScan(ctx, cfg.pointsKey(), cursor, "", int64(cfg.Batch)).Result()
+		if err != nil {
+			return err
+		}
+		if len(entries)%2 != 0 {
+			return fmt.Errorf("redisstore: HSCAN returned %d entries, not field and value pairs", len(entries))
+		}
+		records := make([]record, 0, len(entries)/2)
+		for i := 0; i < len(entries); i += 2 {
+			records = append(records, record{id: entries[i], value: entries[i+1]})
+		}
+		visit(records)
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// A point is stored as a flags byte, X and Y (little-endian float64s), then
+// its encoded Data if the flags say it has any
+const hasData = 1 << 0
+
+func encodePoint(p spatial.Point, codec spatial.DataCodec) ([]byte, error) {
+	b := make([]byte, 17, 32)
+	binary.LittleEndian.PutUint64(b[1:], math.Float64bits(p.X))
+	binary.LittleEndian.PutUint64(b[9:], math.Float64bits(p.Y))
+	if p.Data == nil {
+		return b, nil
+	}
+	b[0] = hasData
+	data, err := codec.EncodeData(p.Data)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, data...), nil
+}
+
+func decodePoint(value string, codec spatial.DataCodec) (spatial.Point, error) {
+	if len(value) < 17 || value[0]&^hasData != 0 {
+		return spatial.Point{}, errors.New("malformed value")
+	}
+	b := []byte(value)
+	p := spatial.Point{
+		X: math.Float64frombits(binary.LittleEndian.Uint64(b[1:])),
+		Y: math.Float64frombits(binary.LittleEndian.Uint64(b[9:])),
+	}
+	if b[0]&hasData != 0 {
+		var err error
+		if p.Data, err = codec.DecodeData(b[17:]); err != nil {
+			return spatial.Point{}, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,179 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// pipelineHook counts the pipelines a client sends and, if gate is set,
+// holds each one until gate yields
+type pipelineHook struct {
+	pipelines atomic.Int64
+	gate      chan struct{}
+}
+
+func (h *pipelineHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *pipelineHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook { return next }
+
+func (h *pipelineHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if h.gate != nil {
+			<-h.gate
+		}
+		h.pipelines.Add(1)
+		return next(ctx, cmds)
+	}
+}
+
+// newRedis starts a miniredis server for the test and a client for it
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client, *pipelineHook) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	hook := &pipelineHook{}
+	client.AddHook(hook)
+	return server, client, hook
+}
+
+var bounds = spatial.Bounds{X: -180, Y: -90, Width: 360, Height: 180}
+
+// TestMirrorRestore tests that every kind of mutation reaches Redis and that
+// a standby restored from it matches the tree
+func TestMirrorRestore(t *testing.T) {
+	server, client, hook := newRedis(t)
+	cfg := Config{Prefix: "fleet", Geo: true, Batch: 8}
+	m := NewMirror(client, cfg)
+	qt, err := spatial.NewQuadTree(bounds, append(m.Options(), spatial.WithCapacity(4))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		qt.InsertWithID("van"+strconv.Itoa(i), spatial.Point{X: float64(i), Y: float64(i % 80), Data: i})
+	}
+	qt.Insert(spatial.Point{X: 1, Y: 1, Data: "no id"})
+	qt.Move("van1", 50, 50)
+	qt.UpdateByID("van2", spatial.Point{X: 2, Y: 2, Data: "updated"})
+	qt.Upsert("van3", spatial.Point{X: 3, Y: 3, Data: "upserted"})
+	qt.RemoveByID("van4")
+	qt.Close()
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Dropped() != 0 || hook.pipelines.Load() >= 105 {
+		t.Errorf("Expected batched pipelines and nothing dropped, got %d pipelines, %d dropped", hook.pipelines.Load(), m.Dropped())
+	}
+	points, _ := server.HKeys("fleet:points")
+	geo, _ := server.ZMembers("fleet:geo")
+	if len(points) != 99 || len(geo) != 99 {
+		t.Fatalf("Expected 99 mirrored points, got %d and %d", len(points), len(geo))
+	}
+	pos, err := client.GeoPos(context.Background(), "fleet:geo", "van1").Result()
+	if err != nil || pos[0] == nil || math.Abs(pos[0].Longitude-50) > 1e-4 || math.Abs(pos[0].Latitude-50) > 1e-4 {
+		t.Errorf("Expected the move mirrored to the geo set, got %v, %v", pos, err)
+	}
+	if report, err := Reconcile(context.Background(), client, qt, cfg); err != nil || !report.Clean() {
+		t.Errorf("Expected Redis to match the tree, got %+v, %v", report, err)
+	}
+
+	standby, _ := spatial.NewQuadTree(bounds)
+	n, err := RestoreFromRedis(context.Background(), client, standby, cfg)
+	if err != nil || n != 99 {
+		t.Fatalf("Expected 99 points restored, got %d, %v", n, err)
+	}
+	for id, want := range map[string]spatial.Point{
+		"van0": {X: 0, Y: 0, Data: 0},
+		"van1": {X: 50, Y: 50, Data: 1},
+		"van2": {X: 2, Y: 2, Data: "updated"},
+		"van3": {X: 3, Y: 3, Data: "upserted"},
+	} {
+		if p, ok := standby.GetByID(id); !ok || p.X != want.X || p.Y != want.Y || p.Data != want.Data {
+			t.Errorf("%s: expected %v, got %v", id, want, p)
+		}
+	}
+	if _, ok := standby.GetByID("van4"); ok {
+		t.Error("Expected the removed point to stay removed")
+	}
+}
+
+// TestMirrorBoundedQueue tests that a stalled Redis drops mutations rather
+// than blocking the tree, and that Reconcile finds them
+func TestMirrorBoundedQueue(t *testing.T) {
+	_, client, hook := newRedis(t)
+	hook.gate = make(chan struct{})
+	m := NewMirror(client, Config{Queue: 2, Batch: 1})
+	qt, _ := spatial.NewQuadTree(bounds, m.Options()...)
+	for i := 0; i < 50; i++ {
+		qt.InsertWithID(strconv.Itoa(i), spatial.Point{X: float64(i), Y: 0})
+	}
+	qt.Close()
+	close(hook.gate)
+	m.Close()
+	if m.Dropped() == 0 {
+		t.Fatal("Expected mutations to be dropped")
+	}
+
+	report, err := m.Reconcile(context.Background(), qt)
+	if err != nil || len(report.Missing) != int(m.Dropped()) {
+		t.Errorf("Expected the %d dropped points missing, got %d, %v", m.Dropped(), len(report.Missing), err)
+	}
+}
+
+// TestReconcile tests each kind of discrepancy, and restoring damaged values
+func TestReconcile(t *testing.T) {
+	server, client, _ := newRedis(t)
+	m := NewMirror(client, Config{})
+	qt, _ := spatial.NewQuadTree(bounds, m.Options()...)
+	qt.InsertWithID("a", spatial.Point{X: 1, Y: 1})
+	qt.InsertWithID("b", spatial.Point{X: 2, Y: 2, Data: "b"})
+	qt.InsertWithID("c", spatial.Point{X: 3, Y: 3})
+	qt.Close()
+	m.Close()
+
+	key := DefaultPrefix + ":points"
+	server.HDel(key, "a")
+	server.HSet(key, "b", server.HGet(key, "c"))
+	server.HSet(key, "ghost", "bad")
+	report, err := Reconcile(context.Background(), client, qt, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Report{Missing: []string{"a"}, Extra: []string{"ghost"}, Stale: []string{"b"}}
+	if fmt.Sprint(report) != fmt.Sprint(want) || report.Clean() {
+		t.Errorf("Expected %+v, got %+v", want, report)
+	}
+
+	standby, _ := spatial.NewQuadTree(bounds)
+	n, err := RestoreFromRedis(context.Background(), client, standby, Config{})
+	var restoreErr *RestoreError
+	if n != 2 || !errors.As(err, &restoreErr) || restoreErr.Points[0].ID != "ghost" {
+		t.Errorf("Expected the damaged point reported by id, got %d, %v", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RestoreFromRedis(ctx, client, standby, Config{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled restore, got %v", err)
+	}
+
+	// A failed round trip is recorded rather than lost
+	server.Close()
+	m = NewMirror(client, Config{})
+	qt, _ = spatial.NewQuadTree(bounds, m.Options()...)
+	qt.InsertWithID("a", spatial.Point{X: 1, Y: 1})
+	qt.Close()
+	if err := m.Close(); err == nil {
+		t.Error("Expected the failed pipeline reported by Close")
+	}
+}
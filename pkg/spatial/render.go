@@ -0,0 +1,149 @@
+package spatial
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+)
+
+// DefaultRenderSize is the width in pixels RenderSVG uses when RenderOpts
+// gives neither a width nor a height
+const DefaultRenderSize = 800
+
+// RenderOpts configures RenderSVG
+type RenderOpts struct {
+	// Width and Height are the image size in pixels. If one is 0 it follows
+	// from the other and the root's aspect ratio; if both are, the image is
+	// DefaultRenderSize wide.
+	Width, Height int
+	// PointRadius is the pixel radius of each point, 2 if 0
+	PointRadius float64
+	// Color returns the fill color of a point, any SVG color; black if nil
+	Color func(Point) string
+	// Query, if set, is drawn as a rectangle, and the points Search returns
+	// for it are outlined
+	Query *Bounds
+	// Center and Radius, if Radius is positive, are drawn as a circle, and
+	// the points SearchRadius returns for them are outlined. The circle is
+	// drawn with the tree's units, so under a non-Euclidean metric it only
+	// approximates the area searched.
+	Center Point
+	Radius float64
+}
+
+// RenderSVG draws the tree as an SVG image: the root bounds, the cell of
+// every other node with thinner strokes the deeper it is, and the stored
+// points, with the query in opts and its results highlighted. The root's
+// bounds fill the image, with Y increasing upwards as on a map. Elements
+// carry the classes root, cell, point, query and hit, for styling.
+func (qt *QuadTree) RenderSVG(w io.Writer, opts RenderOpts) error {
+	qt.rlockAll()
+	defer qt.runlockAll()
+
+	b := qt.Root.Bounds
+	width, height := float64(opts.Width), float64(opts.Height)
+	switch {
+	case width <= 0 && height <= 0:
+		width = DefaultRenderSize
+		height = width * b.Height / b.Width
+	case width <= 0:
+		width = height * b.Width / b.Height
+	case height <= 0:
+		height = width * b.Height / b.Width
+	}
+	r := &svgRenderer{
+		bounds: b,
+		sx:     width / b.Width,
+		sy:     height / b.Height,
+	}
+	radius := opts.PointRadius
+	if radius <= 0 {
+		radius = 2
+	}
+
+	hits := make(map[uint64]bool)
+	var found []Point
+	if opts.Query != nil {
+		qt.searchLive(*opts.Query, qt.edges, &found)
+	}
+	if opts.Radius > 0 {
+		found = append(found, qt.searchRadius(opts.Center, opts.Radius)...)
+	}
+	for _, p := range found {
+		hits[p.seq] = true
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+		px(width), px(height), px(width), px(height))
+	r.rect(bw, "root", b, `fill="white" stroke="black" stroke-width="2"`)
+	qt.Root.walkIntersecting(b, func(n *Node) bool {
+		if n.Depth > 0 {
+			r.rect(bw, "cell", n.Bounds, fmt.Sprintf(`fill="none" stroke="grey" stroke-width="%s"`, px(1/float64(n.Depth))))
+		}
+		return true
+	})
+
+	points := make([]Point, 0, qt.Root.count)
+	qt.Root.collectPoints(&points)
+	qt.dropExpired(&points, 0)
+	for _, p := range points {
+		color := "black"
+		if opts.Color != nil {
+			color = html.EscapeString(opts.Color(p))
+		}
+		class, extra := "point", ""
+		if hits[p.seq] {
+			class, extra = "hit", ` stroke="red" stroke-width="1.5"`
+		}
+		x, y := r.point(p.X, p.Y)
+		fmt.Fprintf(bw, `<circle class="%s" cx="%s" cy="%s" r="%s" fill="%s"%s/>`+"\n", class, px(x), px(y), px(radius), color, extra)
+	}
+
+	query := `fill="red" fill-opacity="0.1" stroke="red" stroke-dasharray="4"`
+	if opts.Query != nil {
+		r.rect(bw, "query", *opts.Query, query)
+	}
+	if opts.Radius > 0 {
+		// An ellipse, since the axes may be scaled differently
+		x, y := r.point(opts.Center.X, opts.Center.Y)
+		fmt.Fprintf(bw, `<ellipse class="query" cx="%s" cy="%s" rx="%s" ry="%s" %s/>`+"\n",
+			px(x), px(y), px(opts.Radius*r.sx), px(opts.Radius*r.sy), query)
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// svgRenderer maps tree coordinates to pixels, flipping Y so that it grows
+// upwards
+type svgRenderer struct {
+	bounds Bounds
+	sx, sy float64
+}
+
+func (r *svgRenderer) point(x, y float64) (float64, float64) {
+	return (x - r.bounds.X) * r.sx, (r.bounds.Y + r.bounds.Height - y) * r.sy
+}
+
+func (r *svgRenderer) rect(w *bufio.Writer, class string, b Bounds, attrs string) {
+	// The top left corner on screen is the cell's minimum X and maximum Y
+	x, y := r.point(b.X, b.Y+b.Height)
+	fmt.Fprintf(w, `<rect class="%s" x="%s" y="%s" width="%s" height="%s" %s/>`+"\n",
+		class, px(x), px(y), px(b.Width*r.sx), px(b.Height*r.sy), attrs)
+}
+
+// px formats a pixel value to two decimals, dropping trailing zeros
+func px(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package spatial
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestRenderSVG tests element counts, the viewBox, query highlighting and
+// the Y flip
+func TestRenderSVG(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: -50, Y: 0, Width: 200, Height: 100}, WithCapacity(2))
+	for _, p := range []Point{{X: -50, Y: 0}, {X: 10, Y: 10}, {X: 20, Y: 20}, {X: 100, Y: 90}, {X: 140, Y: 50}} {
+		qt.Insert(p)
+	}
+
+	var buf bytes.Buffer
+	query := Bounds{X: 0, Y: 0, Width: 30, Height: 30}
+	err := qt.RenderSVG(&buf, RenderOpts{
+		Width: 400,
+		Color: func(p Point) string {
+			if p.X < 0 {
+				return "blue"
+			}
+			return `"green"`
+		},
+		Query:  &query,
+		Center: Point{X: 100, Y: 90},
+		Radius: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="400" height="200" viewBox="0 0 400 200">`) ||
+		!strings.HasSuffix(svg, "</svg>\n") {
+		t.Fatalf("Unexpected document:\n%s", svg)
+	}
+	for class, want := range map[string]int{
+		"root":  1,
+		"cell":  qt.Stats().Nodes - 1,
+		"point": 2,
+		"hit":   3,
+		"query": 2,
+	} {
+		if got := strings.Count(svg, `class="`+class+`"`); got != want {
+			t.Errorf("Expected %d elements of class %s, got %d", want, class, got)
+		}
+	}
+	// (-50, 0) is the bottom left corner of the root, so the bottom left of the image
+	if !strings.Contains(svg, `cx="0" cy="200" r="2" fill="blue"/>`) {
+		t.Errorf("Expected the origin point at the bottom left:\n%s", svg)
+	}
+	if !strings.Contains(svg, `fill="&#34;green&#34;"`) {
+		t.Error("Expected colors to be escaped")
+	}
+	if !strings.Contains(svg, `<rect class="query" x="100" y="140" width="60" height="60"`) {
+		t.Errorf("Expected the query rectangle flipped and scaled:\n%s", svg)
+	}
+
+	buf.Reset()
+	qt.RenderSVG(&buf, RenderOpts{Height: 50})
+	if !strings.Contains(buf.String(), `viewBox="0 0 100 50"`) {
+		t.Errorf("Expected the width to follow the aspect ratio, got %s", strings.SplitN(buf.String(), "\n", 2)[0])
+	}
+}
@@ -0,0 +1,246 @@
+// Package revgeo names positions after the nearest labeled landmark or the
+// zone containing them, for showing drivers as "about 300 m from X"
+package revgeo
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/geofence"
+)
+
+// Label is a named place, and in results how far it is from the query
+type Label struct {
+	Name  string
+	Point spatial.Point // The landmark, or the centroid of a zone
+	Zone  bool          // Named by one of Config.Zones
+	// Distance is set in results: from the query point to Point, in meters
+	// under Config.Geo and planar units otherwise, or 0 for a zone the
+	// query point is in
+	Distance float64
+}
+
+// Config configures New and FromTree
+type Config struct {
+	// Geo takes X and Y as longitude and latitude and measures distances in
+	// meters along the Earth's surface
+	Geo bool
+	// Zones are polygons looked up exactly: a point inside one is labeled
+	// with the zone's ID at distance 0, and a point outside every zone may
+	// still be nearest its centroid
+	Zones []geofence.Fence
+}
+
+// Index answers reverse geocoding queries over a fixed set of labels. It is
+// read-only and safe for concurrent use.
+type Index struct {
+	geo    bool
+	labels []Label // Landmarks in the order given, then zone centroids
+	tree   *spatial.QuadTree
+	zones  *geofence.Index
+}
+
+// New indexes labels, which it keeps a copy of, and cfg.Zones. Names need
+// not be unique. It fails with a *spatial.RejectedPointsError if a label's
+// coordinates are NaN, infinite, or off the globe under Geo.
+func New(labels []Label, cfg Config) (*Index, error) {
+	idx := &Index{geo: cfg.Geo, labels: make([]Label, 0, len(labels)+len(cfg.Zones))}
+	for _, l := range labels {
+		idx.labels = append(idx.labels, Label{Name: l.Name, Point: spatial.Point{X: l.Point.X, Y: l.Point.Y}})
+	}
+	for _, z := range cfg.Zones {
+		if c, _, ok := centroid(z); ok {
+			idx.labels = append(idx.labels, Label{Name: z.ID, Point: c, Zone: true})
+		}
+	}
+	idx.zones = geofence.NewIndex(cfg.Zones)
+
+	points := make([]spatial.Point, len(idx.labels))
+	for i, l := range idx.labels {
+		points[i] = spatial.Point{X: l.Point.X, Y: l.Point.Y, Data: i}
+	}
+	var opts []spatial.Option
+	if cfg.Geo {
+		opts = append(opts, spatial.WithGeoCoordinates())
+	}
+	tree, err := spatial.BuildQuadTree(extent(points, cfg.Geo), 16, points, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("revgeo: %w", err)
+	}
+	idx.tree = tree
+	return idx, nil
+}
+
+// FromTree indexes the points of ix that have IDs, each labeled by its ID,
+// such as a QuadTree spatial.LoadCSV filled with CSVSpec.ID set to a name
+// column. Points without IDs are skipped.
+func FromTree(ix spatial.SpatialIndex, cfg Config) (*Index, error) {
+	var labels []Label
+	ix.ForEach(func(p spatial.Point) bool {
+		if name, ok := p.ID(); ok {
+			labels = append(labels, Label{Name: name, Point: p})
+		}
+		return true
+	})
+	return New(labels, cfg)
+}
+
+// extent returns bounds holding every point: the globe under geo, otherwise
+// their bounding box with a margin
+func extent(points []spatial.Point, geo bool) spatial.Bounds {
+	if geo {
+		return spatial.Bounds{X: -180, Y: -90, Width: 360, Height: 180}
+	}
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) {
+			continue // Left for BuildQuadTree to reject
+		}
+		minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+		maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+	}
+	if minX > maxX {
+		return spatial.Bounds{X: 0, Y: 0, Width: 1, Height: 1}
+	}
+	margin := math.Max(math.Max(maxX-minX, maxY-minY)*0.01, 1)
+	return spatial.Bounds{X: minX - margin, Y: minY - margin, Width: maxX - minX + 2*margin, Height: maxY - minY + 2*margin}
+}
+
+// centroid returns the area centroid of z and its area, holes subtracted
+func centroid(z geofence.Fence) (spatial.Point, float64, bool) {
+	outer := z.Outer()
+	if len(outer) == 0 {
+		return spatial.Point{}, 0, false
+	}
+	// Offset from the first vertex, so coordinates far from the origin keep
+	// their precision
+	o := outer[0]
+	var area, cx, cy float64
+	ring := func(r []spatial.Point, sign float64) {
+		a, x, y := ringMoments(r, o)
+		if (a < 0) == (sign > 0) {
+			a, x, y = -a, -x, -y
+		}
+		area, cx, cy = area+a, cx+x, cy+y
+	}
+	ring(outer, 1)
+	for _, h := range z.Holes() {
+		ring(h, -1)
+	}
+	if area == 0 {
+		return spatial.Point{}, 0, false
+	}
+	return spatial.Point{X: o.X + cx/area, Y: o.Y + cy/area}, area, true
+}
+
+// ringMoments returns the signed area of r, offset by o, and its first
+// moments: the area times the centroid's X and Y
+func ringMoments(r []spatial.Point, o spatial.Point) (area, mx, my float64) {
+	for i := range r {
+		a, b := r[i], r[(i+1)%len(r)]
+		ax, ay, bx, by := a.X-o.X, a.Y-o.Y, b.X-o.X, b.Y-o.Y
+		cross := ax*by - bx*ay
+		area += cross / 2
+		mx += (ax + bx) * cross / 6
+		my += (ay + by) * cross / 6
+	}
+	return area, mx, my
+}
+
+// Len returns the number of labels, zone centroids included
+func (idx *Index) Len() int {
+	return len(idx.labels)
+}
+
+// Nearest returns the label for p and its distance: the zone containing p,
+// the smallest if zones overlap, or else the nearest landmark or zone
+// centroid. Ties go to the lower name, then to the label given first. It
+// reports false if there are no labels.
+func (idx *Index) Nearest(p spatial.Point) (Label, float64, bool) {
+	if zones := idx.containing(p); len(zones) > 0 {
+		return zones[0], 0, true
+	}
+	nearest := idx.tree.KNearest(p, 1)
+	if len(nearest) == 0 {
+		return Label{}, 0, false
+	}
+	// Collect everything as near, widened a hair for the tree's own
+	// rounding, to break ties by name rather than by whichever the tree met
+	// first
+	found := idx.within(p, idx.distance(p, nearest[0])*(1+1e-9))
+	return found[0], found[0].Distance, true
+}
+
+// Within returns the labels within radius of p, in meters under Geo, nearest
+// first with ties broken as Nearest breaks them. Zones containing p come
+// first, at distance 0, wherever their centroids are.
+func (idx *Index) Within(p spatial.Point, radius float64) []Label {
+	zones := idx.containing(p)
+	if radius < 0 {
+		return zones
+	}
+	found := idx.within(p, radius)
+	found = slices.DeleteFunc(found, func(l Label) bool {
+		return l.Zone && slices.ContainsFunc(zones, func(z Label) bool { return z.Point == l.Point && z.Name == l.Name })
+	})
+	return append(zones, found...)
+}
+
+// within returns the labels whose points are within radius of p, sorted
+// by distance, then name, then position in the Index
+func (idx *Index) within(p spatial.Point, radius float64) []Label {
+	var found []ranked
+	for _, q := range idx.tree.SearchRadius(p, radius) {
+		i := q.Data.(int)
+		l := idx.labels[i]
+		l.Distance = idx.distance(p, q)
+		found = append(found, ranked{l, l.Distance, i})
+	}
+	return sortRanked(found)
+}
+
+// containing returns labels for the zones containing p, at distance 0,
+// smallest zone first, then by name, then in the order given
+func (idx *Index) containing(p spatial.Point) []Label {
+	var found []ranked
+	for i, z := range idx.zones.Containing(p) {
+		c, area, _ := centroid(z)
+		found = append(found, ranked{Label{Name: z.ID, Point: c, Zone: true}, area, i})
+	}
+	return sortRanked(found)
+}
+
+// ranked is a Label with the keys results are sorted by ahead of its name
+type ranked struct {
+	Label
+	key   float64
+	order int
+}
+
+func sortRanked(found []ranked) []Label {
+	slices.SortFunc(found, func(a, b ranked) int {
+		if c := cmp.Compare(a.key, b.key); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.order, b.order)
+	})
+	labels := make([]Label, len(found))
+	for i, r := range found {
+		labels[i] = r.Label
+	}
+	return labels
+}
+
+// distance measures from p to q as the Index does
+func (idx *Index) distance(p, q spatial.Point) float64 {
+	if idx.geo {
+		return spatial.HaversineDistance(spatial.FromPoint(p, nil), spatial.FromPoint(q, nil))
+	}
+	return spatial.Distance(p, q)
+}
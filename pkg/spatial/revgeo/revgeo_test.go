@@ -0,0 +1,197 @@
+package revgeo
+
+import (
+	"cmp"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/geofence"
+)
+
+func mustIndex(t testing.TB, labels []Label, cfg Config) *Index {
+	t.Helper()
+	idx, err := New(labels, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+// TestNearestMatchesScan tests Nearest and Within against a linear scan
+// over labels on a coarse grid, so ties are common
+func TestNearestMatchesScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	labels := make([]Label, 300)
+	for i := range labels {
+		labels[i] = Label{
+			Name:  string(rune('a' + rng.Intn(5))),
+			Point: spatial.Point{X: float64(rng.Intn(40)), Y: float64(rng.Intn(40))},
+		}
+	}
+	idx := mustIndex(t, labels, Config{})
+
+	// scan returns the labels within radius of p in the order Within
+	// promises
+	scan := func(p spatial.Point, radius float64) []Label {
+		type hit struct {
+			l Label
+			i int
+		}
+		var hits []hit
+		for i, l := range labels {
+			if d := spatial.Distance(p, l.Point); d <= radius {
+				l.Distance = d
+				hits = append(hits, hit{l, i})
+			}
+		}
+		slices.SortFunc(hits, func(a, b hit) int {
+			return cmp.Or(cmp.Compare(a.l.Distance, b.l.Distance), cmp.Compare(a.l.Name, b.l.Name), cmp.Compare(a.i, b.i))
+		})
+		found := make([]Label, len(hits))
+		for i, h := range hits {
+			found[i] = h.l
+		}
+		return found
+	}
+
+	for i := 0; i < 500; i++ {
+		p := spatial.Point{X: float64(rng.Intn(80)) / 2, Y: float64(rng.Intn(80)) / 2}
+		got, d, ok := idx.Nearest(p)
+		want := scan(p, math.Inf(1))[0]
+		if !ok || got != want || d != want.Distance {
+			t.Fatalf("%v: expected %+v, got %+v at %v", p, want, got, d)
+		}
+		radius := rng.Float64() * 6
+		if got, want := idx.Within(p, radius), scan(p, radius); !slices.Equal(got, want) {
+			t.Fatalf("%v within %v: expected %v, got %v", p, radius, want, got)
+		}
+	}
+}
+
+// TestNearestTies tests that equidistant labels resolve by name, then by
+// the order given
+func TestNearestTies(t *testing.T) {
+	idx := mustIndex(t, []Label{
+		{Name: "north", Point: spatial.Point{X: 0, Y: 10}},
+		{Name: "east", Point: spatial.Point{X: 10, Y: 0}},
+		{Name: "depot", Point: spatial.Point{X: 0, Y: -10}},
+		{Name: "depot", Point: spatial.Point{X: -10, Y: 0}},
+	}, Config{})
+	for i := 0; i < 10; i++ {
+		got, d, ok := idx.Nearest(spatial.Point{})
+		if !ok || got.Name != "depot" || got.Point.Y != -10 || d != 10 {
+			t.Fatalf("expected the first depot at 10, got %+v at %v", got, d)
+		}
+	}
+	var names []string
+	for _, l := range idx.Within(spatial.Point{}, 10) {
+		names = append(names, l.Name)
+	}
+	if want := []string{"depot", "depot", "east", "north"}; !slices.Equal(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+
+	empty := mustIndex(t, nil, Config{})
+	if _, _, ok := empty.Nearest(spatial.Point{}); ok {
+		t.Error("expected nothing from an empty index")
+	}
+}
+
+// TestGeo tests distances in meters
+func TestGeo(t *testing.T) {
+	brandenburg := spatial.GeoPoint{Lat: 52.5163, Lon: 13.3777}
+	alexanderplatz := spatial.GeoPoint{Lat: 52.5219, Lon: 13.4132}
+	idx := mustIndex(t, []Label{
+		{Name: "Brandenburg Gate", Point: brandenburg.ToPoint(nil)},
+		{Name: "Alexanderplatz", Point: alexanderplatz.ToPoint(nil)},
+	}, Config{Geo: true})
+
+	near := spatial.Destination(brandenburg, 90, 300)
+	got, d, ok := idx.Nearest(near.ToPoint(nil))
+	if !ok || got.Name != "Brandenburg Gate" || math.Abs(d-300) > 0.01 {
+		t.Fatalf("expected Brandenburg Gate about 300 m away, got %+v at %v", got, d)
+	}
+	if within := idx.Within(near.ToPoint(nil), 1000); len(within) != 1 {
+		t.Errorf("expected one label within 1 km, got %v", within)
+	}
+	if within := idx.Within(near.ToPoint(nil), 3000); len(within) != 2 || within[1].Name != "Alexanderplatz" {
+		t.Errorf("expected both labels within 3 km, got %v", within)
+	}
+}
+
+// TestZones tests exact lookups in zones, the smallest of nested ones
+// winning, and falling back to centroids outside them
+func TestZones(t *testing.T) {
+	square := func(id string, x, y, size float64) geofence.Fence {
+		f, err := geofence.New(id, []spatial.Point{{X: x, Y: y}, {X: x + size, Y: y}, {X: x + size, Y: y + size}, {X: x, Y: y + size}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	idx := mustIndex(t, []Label{{Name: "station", Point: spatial.Point{X: 150, Y: 0}}}, Config{
+		Zones: []geofence.Fence{square("district", 0, 0, 100), square("market", 10, 10, 20)},
+	})
+	if idx.Len() != 3 {
+		t.Fatalf("expected a label and two zone centroids, got %d", idx.Len())
+	}
+
+	for _, tc := range []struct {
+		p    spatial.Point
+		name string
+		d    float64
+	}{
+		{spatial.Point{X: 90, Y: 90}, "district", 0},
+		{spatial.Point{X: 20, Y: 20}, "market", 0},
+		{spatial.Point{X: 30, Y: 10}, "market", 0}, // On its boundary
+		{spatial.Point{X: 50, Y: 120}, "district", 70},
+		{spatial.Point{X: 150, Y: 10}, "station", 10},
+	} {
+		got, d, ok := idx.Nearest(tc.p)
+		if !ok || got.Name != tc.name || math.Abs(d-tc.d) > 1e-9 || got.Distance != d || got.Zone != (tc.name != "station") {
+			t.Errorf("%v: expected %s at %v, got %+v at %v", tc.p, tc.name, tc.d, got, d)
+		}
+	}
+
+	// Zones containing the point come first, then the rest by distance, the
+	// containing zones' centroids not repeated
+	var names []string
+	for _, l := range idx.Within(spatial.Point{X: 20, Y: 20}, 200) {
+		names = append(names, l.Name)
+	}
+	if want := []string{"market", "district", "station"}; !slices.Equal(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+	if c, _, _ := centroid(square("c", 10, 10, 20)); c.X != 20 || c.Y != 20 {
+		t.Errorf("expected the centroid at 20, 20, got %v", c)
+	}
+}
+
+// TestFromTree tests labeling points loaded from CSV by their IDs
+func TestFromTree(t *testing.T) {
+	qt, err := spatial.NewQuadTree(spatial.Bounds{X: -180, Y: -90, Width: 360, Height: 180}, spatial.WithGeoCoordinates())
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv := "name,lon,lat\nHauptbahnhof,13.3695,52.5251\nRotes Rathaus,13.4087,52.5186\n"
+	spec := spatial.CSVSpec{X: spatial.CSVName("lon"), Y: spatial.CSVName("lat"), ID: spatial.CSVName("name"), Header: true}
+	if n, errs := spatial.LoadCSV(strings.NewReader(csv), spec, qt); n != 2 || errs != nil {
+		t.Fatalf("expected 2 rows, got %d and %v", n, errs)
+	}
+	qt.Insert(spatial.Point{X: 13.4, Y: 52.52}) // Unnamed, so skipped
+
+	idx, err := FromTree(qt, Config{Geo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 2 {
+		t.Fatalf("expected 2 labels, got %d", idx.Len())
+	}
+	if got, _, _ := idx.Nearest(spatial.Point{X: 13.41, Y: 52.519}); got.Name != "Rotes Rathaus" {
+		t.Errorf("expected Rotes Rathaus, got %+v", got)
+	}
+}
@@ -0,0 +1,504 @@
+package spatial
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+)
+
+// DefaultRTreeCapacity is a node capacity that suits most RTrees
+const DefaultRTreeCapacity = 16
+
+// RTree indexes rectangles, such as the bounding boxes of zones and road
+// edges, which a point index can only hold by a representative point and so
+// misses near their edges. Each node holds up to a fixed number of entries or
+// children under the box bounding them, and a node that overflows is split
+// by Guttman's quadratic method. Rectangles may overlap and may have no
+// area. It is safe for concurrent use.
+type RTree struct {
+	maxEntries, minEntries int
+
+	mu      sync.RWMutex // Guards everything below
+	root    *rnode
+	size    int
+	nextSeq uint64
+}
+
+// RTreeEntry is a handle to a rectangle in an RTree, returned by Insert and
+// by queries. A handle whose rectangle has been removed is inert.
+type RTreeEntry struct {
+	bounds Bounds
+	rect   rect
+	data   any
+	seq    uint64
+	tree   *RTree // nil once removed; guarded by the tree's lock
+}
+
+// Bounds returns the entry's rectangle
+func (e *RTreeEntry) Bounds() Bounds {
+	return e.bounds
+}
+
+// Data returns the value the entry was inserted with
+func (e *RTreeEntry) Data() any {
+	return e.data
+}
+
+// rect is a box by its corners. Nodes keep their boxes this way, not as
+// Bounds, so that a box grown to hold another holds it exactly.
+type rect struct {
+	minX, minY, maxX, maxY float64
+}
+
+// rectOf returns b's corners, with its far edges placed where Bounds methods
+// place them
+func rectOf(b Bounds) rect {
+	return rect{b.X, b.Y, b.X + b.Width, b.Y + b.Height}
+}
+
+func (r rect) area() float64 {
+	return (r.maxX - r.minX) * (r.maxY - r.minY)
+}
+
+func (r rect) union(o rect) rect {
+	return rect{min(r.minX, o.minX), min(r.minY, o.minY), max(r.maxX, o.maxX), max(r.maxY, o.maxY)}
+}
+
+// intersects reports whether r and o share any point, edges included
+func (r rect) intersects(o rect) bool {
+	return r.minX <= o.maxX && o.minX <= r.maxX && r.minY <= o.maxY && o.minY <= r.maxY
+}
+
+func (r rect) contains(o rect) bool {
+	return r.minX <= o.minX && r.minY <= o.minY && o.maxX <= r.maxX && o.maxY <= r.maxY
+}
+
+// rnode is an RTree node: a leaf of entries or a branch of children, every
+// leaf at the same depth
+type rnode struct {
+	box      rect
+	leaf     bool
+	children []*rnode
+	entries  []*RTreeEntry
+}
+
+func (n *rnode) len() int {
+	if n.leaf {
+		return len(n.entries)
+	}
+	return len(n.children)
+}
+
+// rects returns the boxes of n's entries or children, in order
+func (n *rnode) rects() []rect {
+	rects := make([]rect, 0, n.len())
+	for _, e := range n.entries {
+		rects = append(rects, e.rect)
+	}
+	for _, c := range n.children {
+		rects = append(rects, c.box)
+	}
+	return rects
+}
+
+// cover returns the box bounding n's entries or children, or the zero rect
+// if it has none
+func (n *rnode) cover() rect {
+	var box rect
+	for i, e := range n.entries {
+		if i == 0 {
+			box = e.rect
+		}
+		box = box.union(e.rect)
+	}
+	for i, c := range n.children {
+		if i == 0 {
+			box = c.box
+		}
+		box = box.union(c.box)
+	}
+	return box
+}
+
+// NewRTree returns an empty tree whose nodes hold up to maxEntries entries
+// or children, and all but the root at least 40% of that. It returns
+// ErrInvalidCapacity if maxEntries is less than 4.
+func NewRTree(maxEntries int) (*RTree, error) {
+	if maxEntries < 4 {
+		return nil, ErrInvalidCapacity
+	}
+	return &RTree{
+		maxEntries: maxEntries,
+		minEntries: max(2, maxEntries*2/5),
+		root:       &rnode{leaf: true},
+	}, nil
+}
+
+// Count returns how many rectangles the tree holds
+func (t *RTree) Count() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.size
+}
+
+// Insert adds the rectangle b holding data and returns its handle. It
+// returns ErrInvalidBounds if b has a NaN, infinite or negative field.
+func (t *RTree) Insert(b Bounds, data any) (*RTreeEntry, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return t.insertRect(b, rectOf(b), data), nil
+}
+
+// insertRect adds an entry for b indexed by r, which must hold b
+func (t *RTree) insertRect(b Bounds, r rect, data any) *RTreeEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextSeq++
+	e := &RTreeEntry{bounds: b, rect: r, data: data, seq: t.nextSeq, tree: t}
+	t.insert(e)
+	t.size++
+	return e
+}
+
+// insert puts e in the leaf whose box it grows least, splitting nodes that
+// overflow on the way back up. Callers must hold the write lock.
+func (t *RTree) insert(e *RTreeEntry) {
+	path := []*rnode{t.root}
+	for n := t.root; !n.leaf; {
+		n = n.choose(e.rect)
+		path = append(path, n)
+	}
+	leaf := path[len(path)-1]
+	leaf.entries = append(leaf.entries, e)
+
+	var sibling *rnode // Split off the node below, for its parent to take
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		if sibling != nil {
+			n.children = append(n.children, sibling)
+			sibling = nil
+		}
+		if n.len() > t.maxEntries {
+			sibling = t.split(n)
+		} else {
+			n.box = n.cover()
+		}
+	}
+	if sibling != nil {
+		root := &rnode{children: []*rnode{t.root, sibling}}
+		root.box = root.cover()
+		t.root = root
+	}
+}
+
+// choose returns the child of n whose box r grows least, then the smallest
+func (n *rnode) choose(r rect) *rnode {
+	var best *rnode
+	bestGrowth, bestArea := math.Inf(1), math.Inf(1)
+	for _, c := range n.children {
+		area := c.box.area()
+		growth := c.box.union(r).area() - area
+		if growth < bestGrowth || (growth == bestGrowth && area < bestArea) {
+			best, bestGrowth, bestArea = c, growth, area
+		}
+	}
+	return best
+}
+
+// split moves some of n's entries or children into a new sibling, which it
+// returns
+func (t *RTree) split(n *rnode) *rnode {
+	a, b := quadraticSplit(n.rects(), t.minEntries)
+	sibling := &rnode{leaf: n.leaf}
+	if n.leaf {
+		n.entries, sibling.entries = pick(n.entries, a), pick(n.entries, b)
+	} else {
+		n.children, sibling.children = pick(n.children, a), pick(n.children, b)
+	}
+	n.box, sibling.box = n.cover(), sibling.cover()
+	return sibling
+}
+
+func pick[T any](items []T, indices []int) []T {
+	picked := make([]T, 0, len(indices))
+	for _, i := range indices {
+		picked = append(picked, items[i])
+	}
+	return picked
+}
+
+// quadraticSplit divides rects into two groups of at least minFill each. It
+// seeds them with the pair that would waste the most area boxed together,
+// then adds the rest one at a time, taking next whichever rect prefers one
+// group most strongly and giving it to the group it grows least.
+func quadraticSplit(rects []rect, minFill int) (a, b []int) {
+	seedA, seedB, worst := 0, 1, math.Inf(-1)
+	for i := range rects {
+		for j := i + 1; j < len(rects); j++ {
+			waste := rects[i].union(rects[j]).area() - rects[i].area() - rects[j].area()
+			if waste > worst {
+				seedA, seedB, worst = i, j, waste
+			}
+		}
+	}
+	a, b = []int{seedA}, []int{seedB}
+	boxA, boxB := rects[seedA], rects[seedB]
+	rest := make([]int, 0, len(rects)-2)
+	for i := range rects {
+		if i != seedA && i != seedB {
+			rest = append(rest, i)
+		}
+	}
+	for len(rest) > 0 {
+		// A group that needs every rect left to reach minFill takes them
+		if len(a)+len(rest) == minFill {
+			return append(a, rest...), b
+		}
+		if len(b)+len(rest) == minFill {
+			return a, append(b, rest...)
+		}
+		next, strongest := 0, math.Inf(-1)
+		var growA, growB float64
+		for k, i := range rest {
+			ga := boxA.union(rects[i]).area() - boxA.area()
+			gb := boxB.union(rects[i]).area() - boxB.area()
+			if d := math.Abs(ga - gb); d > strongest {
+				next, strongest, growA, growB = k, d, ga, gb
+			}
+		}
+		i := rest[next]
+		rest = slices.Delete(rest, next, next+1)
+		areaA, areaB := boxA.area(), boxB.area()
+		if growA < growB || (growA == growB && (areaA < areaB || (areaA == areaB && len(a) <= len(b)))) {
+			a, boxA = append(a, i), boxA.union(rects[i])
+		} else {
+			b, boxB = append(b, i), boxB.union(rects[i])
+		}
+	}
+	return a, b
+}
+
+// Remove deletes the handle's rectangle. It returns false if the handle is
+// nil, belongs to another tree, or was already removed.
+func (t *RTree) Remove(e *RTreeEntry) bool {
+	if e == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e.tree != t {
+		return false
+	}
+	path := t.root.find(e, nil)
+	leaf := path[len(path)-1]
+	leaf.entries = slices.DeleteFunc(leaf.entries, func(o *RTreeEntry) bool { return o == e })
+	e.tree = nil
+	t.size--
+	t.condense(path)
+	return true
+}
+
+// find returns the nodes from n down to the leaf holding e, appended to
+// path, or nil if e is not below n
+func (n *rnode) find(e *RTreeEntry, path []*rnode) []*rnode {
+	path = append(path, n)
+	if n.leaf {
+		if slices.Contains(n.entries, e) {
+			return path
+		}
+		return nil
+	}
+	for _, c := range n.children {
+		if c.box.contains(e.rect) {
+			if found := c.find(e, path); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// condense walks path up from the leaf a removal left, dropping nodes left
+// with fewer than minEntries and reinserting their entries, and shrinks the
+// boxes above. Callers must hold the write lock.
+func (t *RTree) condense(path []*rnode) {
+	var orphans []*RTreeEntry
+	for i := len(path) - 1; i > 0; i-- {
+		n, parent := path[i], path[i-1]
+		if n.len() < t.minEntries {
+			parent.children = slices.DeleteFunc(parent.children, func(c *rnode) bool { return c == n })
+			orphans = n.appendEntries(orphans)
+		} else {
+			n.box = n.cover()
+		}
+	}
+	for !t.root.leaf && len(t.root.children) == 1 {
+		t.root = t.root.children[0]
+	}
+	if !t.root.leaf && len(t.root.children) == 0 {
+		t.root = &rnode{leaf: true}
+	}
+	t.root.box = t.root.cover()
+	for _, e := range orphans {
+		t.insert(e)
+	}
+}
+
+// appendEntries appends every entry below n to entries
+func (n *rnode) appendEntries(entries []*RTreeEntry) []*RTreeEntry {
+	entries = append(entries, n.entries...)
+	for _, c := range n.children {
+		entries = c.appendEntries(entries)
+	}
+	return entries
+}
+
+// SearchIntersecting returns the entries whose rectangles share any point
+// with area, edges included, in insertion order
+func (t *RTree) SearchIntersecting(area Bounds) []*RTreeEntry {
+	if area.Validate() != nil {
+		return make([]*RTreeEntry, 0)
+	}
+	r := rectOf(area)
+	return t.search(func(box rect) bool { return box.intersects(r) })
+}
+
+// SearchContaining returns the entries whose rectangles hold p, edges
+// included, in insertion order
+func (t *RTree) SearchContaining(p Point) []*RTreeEntry {
+	if !validCoordinates(p) {
+		return make([]*RTreeEntry, 0)
+	}
+	r := rect{p.X, p.Y, p.X, p.Y}
+	return t.search(func(box rect) bool { return box.contains(r) })
+}
+
+// search returns the entries whose rectangles match, in insertion order,
+// descending only into nodes whose boxes match. match must hold for a box
+// if it holds for any box inside it.
+func (t *RTree) search(match func(rect) bool) []*RTreeEntry {
+	results := make([]*RTreeEntry, 0)
+	t.mu.RLock()
+	t.root.search(match, &results)
+	t.mu.RUnlock()
+	slices.SortFunc(results, func(a, b *RTreeEntry) int {
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		}
+		return 0
+	})
+	return results
+}
+
+func (n *rnode) search(match func(rect) bool, results *[]*RTreeEntry) {
+	for _, e := range n.entries {
+		if match(e.rect) {
+			*results = append(*results, e)
+		}
+	}
+	for _, c := range n.children {
+		if match(c.box) {
+			c.search(match, results)
+		}
+	}
+}
+
+// rankedEntry is an entry and its distance from a query
+type rankedEntry struct {
+	e    *RTreeEntry
+	dist float64
+}
+
+func (a rankedEntry) before(b rankedEntry) bool {
+	return a.dist < b.dist || (a.dist == b.dist && a.e.seq < b.e.seq)
+}
+
+// nearestSearch collects the k entries nearest p, no farther than maxDist
+type nearestSearch struct {
+	p       Point
+	k       int
+	maxDist float64
+	dist    func(*RTreeEntry) float64
+	ranked  []rankedEntry // Nearest first, then earliest inserted
+}
+
+// nearest returns up to k entries with the least dist from p, none farther
+// than maxDist, nearest first and then earliest inserted. dist must be no
+// less than the distance from p to the entry's rect.
+func (t *RTree) nearest(p Point, k int, maxDist float64, dist func(*RTreeEntry) float64) []rankedEntry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	s := &nearestSearch{p: p, k: k, maxDist: maxDist, dist: dist, ranked: make([]rankedEntry, 0, min(k, t.size))}
+	s.visit(t.root)
+	return s.ranked
+}
+
+// bound returns how far an entry may be and still be ranked, shaded a
+// little so rounding in dist can't prune an entry as near as the last
+func (s *nearestSearch) bound() float64 {
+	if len(s.ranked) < s.k {
+		return s.maxDist
+	}
+	return s.ranked[s.k-1].dist
+}
+
+func (s *nearestSearch) pruned(gap float64) bool {
+	return gap*(1-1e-12) > s.bound()
+}
+
+func (s *nearestSearch) visit(n *rnode) {
+	for _, e := range n.entries {
+		if s.pruned(e.rect.distance(s.p)) {
+			continue
+		}
+		r := rankedEntry{e: e, dist: s.dist(e)}
+		if r.dist > s.maxDist || (len(s.ranked) == s.k && !r.before(s.ranked[s.k-1])) {
+			continue
+		}
+		i, _ := slices.BinarySearchFunc(s.ranked, r, func(a, b rankedEntry) int {
+			if a.before(b) {
+				return -1
+			}
+			return 1
+		})
+		if len(s.ranked) == s.k {
+			s.ranked = s.ranked[:s.k-1]
+		}
+		s.ranked = slices.Insert(s.ranked, i, r)
+	}
+	if len(n.children) == 0 {
+		return
+	}
+	var buf [DefaultRTreeCapacity + 1]rnodeGap
+	order := buf[:0]
+	for _, c := range n.children {
+		order = append(order, rnodeGap{c.box.distance(s.p), c})
+	}
+	slices.SortFunc(order, func(a, b rnodeGap) int {
+		return cmp.Compare(a.gap, b.gap)
+	})
+	for _, o := range order {
+		if s.pruned(o.gap) {
+			return
+		}
+		s.visit(o.n)
+	}
+}
+
+// rnodeGap is a node and how far its box is from a query
+type rnodeGap struct {
+	gap float64
+	n   *rnode
+}
+
+// distance returns how far p is from r, zero inside it
+func (r rect) distance(p Point) float64 {
+	dx := max(r.minX-p.X, 0, p.X-r.maxX)
+	dy := max(r.minY-p.Y, 0, p.Y-r.maxY)
+	return math.Sqrt(dx*dx + dy*dy)
+}
@@ -0,0 +1,207 @@
+package spatial_test
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+type rtreeRect struct {
+	entry *spatial.RTreeEntry
+	id    int
+}
+
+// randomRect returns a rectangle in 0..1000, some of them lines or points
+func randomRect(rng *rand.Rand) spatial.Bounds {
+	b := spatial.Bounds{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+	switch rng.Intn(5) {
+	case 0:
+	case 1:
+		b.Width = rng.Float64() * 40
+	default:
+		b.Width, b.Height = rng.Float64()*40, rng.Float64()*40
+	}
+	return b
+}
+
+// TestRTreeMatchesBruteForce tests queries against a scan of every live
+// rectangle, through rounds of inserts and removals, with areas and points
+// on rectangles' edges and corners as well as at random
+func TestRTreeMatchesBruteForce(t *testing.T) {
+	for _, capacity := range []int{4, spatial.DefaultRTreeCapacity} {
+		rng := rand.New(rand.NewSource(int64(capacity)))
+		rt, err := spatial.NewRTree(capacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var live []rtreeRect
+		nextID := 0
+		check := func() {
+			t.Helper()
+			if rt.Count() != len(live) {
+				t.Fatalf("capacity %d: expected %d rectangles, got %d", capacity, len(live), rt.Count())
+			}
+			for i := 0; i < 300; i++ {
+				area := randomRect(rng)
+				var p spatial.Point
+				if i%2 == 0 && len(live) > 0 {
+					// An edge or corner of a stored rectangle
+					b := live[rng.Intn(len(live))].entry.Bounds()
+					area.X = b.X + b.Width
+					p = spatial.Point{X: b.X + b.Width, Y: b.Y + b.Height*float64(rng.Intn(2))}
+				} else {
+					p = spatial.Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+				}
+				var wantArea, wantPoint []int
+				for _, r := range live {
+					if r.entry.Bounds().Intersects(area) {
+						wantArea = append(wantArea, r.id)
+					}
+					if r.entry.Bounds().Contains(p) {
+						wantPoint = append(wantPoint, r.id)
+					}
+				}
+				if got := rtreeIDs(rt.SearchIntersecting(area)); !slices.Equal(got, wantArea) {
+					t.Fatalf("capacity %d: intersecting %+v: expected %v, got %v", capacity, area, wantArea, got)
+				}
+				if got := rtreeIDs(rt.SearchContaining(p)); !slices.Equal(got, wantPoint) {
+					t.Fatalf("capacity %d: containing %v: expected %v, got %v", capacity, p, wantPoint, got)
+				}
+			}
+		}
+		insert := func(n int) {
+			for i := 0; i < n; i++ {
+				b := randomRect(rng)
+				if i%50 == 0 && len(live) > 0 {
+					b = live[rng.Intn(len(live))].entry.Bounds() // A duplicate
+				}
+				e, err := rt.Insert(b, nextID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				live = append(live, rtreeRect{entry: e, id: nextID})
+				nextID++
+			}
+		}
+		remove := func(n int) {
+			for i := 0; i < n && len(live) > 0; i++ {
+				j := rng.Intn(len(live))
+				if !rt.Remove(live[j].entry) {
+					t.Fatalf("capacity %d: expected to remove %d", capacity, live[j].id)
+				}
+				live = slices.Delete(live, j, j+1)
+			}
+		}
+
+		check()
+		insert(2000)
+		check()
+		remove(1500)
+		check()
+		insert(1000)
+		remove(300)
+		check()
+		remove(len(live))
+		check()
+		insert(10)
+		check()
+	}
+}
+
+func rtreeIDs(entries []*spatial.RTreeEntry) []int {
+	var ids []int
+	for _, e := range entries {
+		ids = append(ids, e.Data().(int))
+	}
+	return ids
+}
+
+func TestRTreeRemove(t *testing.T) {
+	rt, _ := spatial.NewRTree(4)
+	other, _ := spatial.NewRTree(4)
+	e, _ := rt.Insert(spatial.Bounds{X: 1, Y: 1, Width: 2, Height: 2}, "a")
+	foreign, _ := other.Insert(spatial.Bounds{X: 1, Y: 1, Width: 2, Height: 2}, "b")
+	if rt.Remove(nil) || rt.Remove(foreign) {
+		t.Error("expected nil and foreign handles to be refused")
+	}
+	if !rt.Remove(e) || rt.Remove(e) {
+		t.Error("expected the handle to remove its rectangle once")
+	}
+	if rt.Count() != 0 || len(rt.SearchContaining(spatial.Point{X: 2, Y: 2})) != 0 {
+		t.Error("expected an empty tree")
+	}
+	if e.Bounds().Width != 2 || e.Data() != "a" {
+		t.Errorf("expected a removed handle to keep its rectangle, got %v %v", e.Bounds(), e.Data())
+	}
+}
+
+func TestRTreeErrors(t *testing.T) {
+	if _, err := spatial.NewRTree(3); !errors.Is(err, spatial.ErrInvalidCapacity) {
+		t.Errorf("expected ErrInvalidCapacity, got %v", err)
+	}
+	rt, _ := spatial.NewRTree(spatial.DefaultRTreeCapacity)
+	if _, err := rt.Insert(spatial.Bounds{Width: -1}, nil); !errors.Is(err, spatial.ErrInvalidBounds) {
+		t.Errorf("expected ErrInvalidBounds, got %v", err)
+	}
+	if got := rt.SearchIntersecting(spatial.Bounds{Width: -1}); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty result for invalid bounds, got %v", got)
+	}
+}
+
+// The RTree benchmarks index 100,000 rectangles up to 60 on a side,
+// centred on each distribution's points
+func newDistRTree(b *testing.B, points []spatial.Point) *spatial.RTree {
+	rt, _ := spatial.NewRTree(spatial.DefaultRTreeCapacity)
+	for i, p := range points {
+		w, h := float64(i%61), float64(i*7%61)
+		if _, err := rt.Insert(spatial.Bounds{X: p.X - w/2, Y: p.Y - h/2, Width: w, Height: h}, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return rt
+}
+
+func BenchmarkRTreeInsert(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		for i := 0; i < b.N; i++ {
+			newDistRTree(b, points)
+		}
+	})
+}
+
+func BenchmarkRTreeSearchIntersecting(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		rt := newDistRTree(b, points)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p := points[(i*7919)%len(points)]
+			rt.SearchIntersecting(spatial.Bounds{X: p.X - 50, Y: p.Y - 50, Width: 100, Height: 100})
+		}
+	})
+}
+
+func BenchmarkRTreeSearchContaining(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		rt := newDistRTree(b, points)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			rt.SearchContaining(points[(i*7919)%len(points)])
+		}
+	})
+}
+
+func BenchmarkRTreeRemove(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		rt := newDistRTree(b, points)
+		entries := rt.SearchIntersecting(distBounds)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			e := entries[(i*7919)%len(entries)]
+			rt.Remove(e)
+			entries[(i*7919)%len(entries)], _ = rt.Insert(e.Bounds(), e.Data())
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package spatial
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Segment is a straight piece of road from A to B, with Data for the edge
+// it belongs to. A and B may be the same point.
+type Segment struct {
+	A, B Point
+	Data interface{}
+}
+
+// ProjectToSegment returns the point on the segment from a to b closest to
+// p, and the distance from p to it. Past either end that is the endpoint,
+// returned exactly; a zero-length segment is its one point.
+func ProjectToSegment(p, a, b Point) (Point, float64) {
+	closest, dist, _ := project(p, a, b)
+	return closest, dist
+}
+
+// project is ProjectToSegment that also returns how far along the segment
+// the closest point is, from 0 at a to 1 at b; 0 for a zero-length segment
+func project(p, a, b Point) (Point, float64, float64) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length2 := dx*dx + dy*dy
+	closest, t := a, 0.0
+	if length2 > 0 {
+		t = min(max(((p.X-a.X)*dx+(p.Y-a.Y)*dy)/length2, 0), 1)
+		switch t {
+		case 1:
+			closest = b
+		case 0:
+		default:
+			closest = Point{X: a.X + t*dx, Y: a.Y + t*dy}
+		}
+	}
+	closest = Point{X: closest.X, Y: closest.Y}
+	return closest, math.Hypot(p.X-closest.X, p.Y-closest.Y), t
+}
+
+// SnapCandidate is a segment a point might be snapped to: how far the point
+// is from it, the closest point on it, and how far along it that point is,
+// from 0 at A to 1 at B
+type SnapCandidate struct {
+	Segment  Segment
+	Distance float64
+	Point    Point
+	Fraction float64
+}
+
+// SegmentIndex finds the road segments nearest a point, for snapping GPS
+// fixes to roads. It indexes each segment's bounding box in an RTree, so a
+// long segment is found from anywhere along it, not only near its ends.
+// Distances are planar. It is safe for concurrent use.
+type SegmentIndex struct {
+	tree *RTree
+}
+
+// NewSegmentIndex returns an empty index
+func NewSegmentIndex() *SegmentIndex {
+	tree, _ := NewRTree(DefaultRTreeCapacity)
+	return &SegmentIndex{tree: tree}
+}
+
+// Insert adds s, returning ErrInvalidPoint if an endpoint is NaN or
+// infinite
+func (ix *SegmentIndex) Insert(s Segment) error {
+	if !validCoordinates(s.A) || !validCoordinates(s.B) {
+		return ErrInvalidPoint
+	}
+	s.A, s.B = Point{X: s.A.X, Y: s.A.Y}, Point{X: s.B.X, Y: s.B.Y}
+	r := rect{min(s.A.X, s.B.X), min(s.A.Y, s.B.Y), max(s.A.X, s.B.X), max(s.A.Y, s.B.Y)}
+	ix.tree.insertRect(Bounds{X: r.minX, Y: r.minY, Width: r.maxX - r.minX, Height: r.maxY - r.minY}, r, &s)
+	return nil
+}
+
+// Count returns how many segments are indexed
+func (ix *SegmentIndex) Count() int {
+	return ix.tree.Count()
+}
+
+// NearestSegment returns the segment closest to p, the distance to it, and
+// the point on it closest to p. Among equally close segments it returns the
+// first inserted. It returns false if the index is empty or p is NaN or
+// infinite.
+func (ix *SegmentIndex) NearestSegment(p Point) (Segment, float64, Point, bool) {
+	if !validCoordinates(p) {
+		return Segment{}, 0, Point{}, false
+	}
+	found := ix.SnapCandidates(p, 1, math.Inf(1))
+	if len(found) == 0 {
+		return Segment{}, 0, Point{}, false
+	}
+	return found[0].Segment, found[0].Distance, found[0].Point, true
+}
+
+// SnapCandidates returns the n segments closest to p, no farther than
+// maxDist, for map matching to choose among. They are nearest first, and
+// then in insertion order.
+func (ix *SegmentIndex) SnapCandidates(p Point, n int, maxDist float64) []SnapCandidate {
+	candidates := make([]SnapCandidate, 0)
+	if n <= 0 || !(maxDist >= 0) || !validCoordinates(p) {
+		return candidates
+	}
+	ranked := ix.tree.nearest(p, n, maxDist, func(e *RTreeEntry) float64 {
+		s := e.data.(*Segment)
+		_, d, _ := project(p, s.A, s.B)
+		return d
+	})
+	for _, r := range ranked {
+		s := r.e.data.(*Segment)
+		closest, d, t := project(p, s.A, s.B)
+		candidates = append(candidates, SnapCandidate{Segment: *s, Distance: d, Point: closest, Fraction: t})
+	}
+	return candidates
+}
+
+// SegmentsWithin returns the segments within radius of p, nearest first and
+// then in insertion order
+func (ix *SegmentIndex) SegmentsWithin(p Point, radius float64) []SnapCandidate {
+	matches := make([]SnapCandidate, 0)
+	if !(radius >= 0) || !validCoordinates(p) {
+		return matches
+	}
+	// Widened a hair so rounding in the box can't drop a segment at exactly
+	// radius
+	reach := radius + 1e-9*(radius+math.Abs(p.X)+math.Abs(p.Y))
+	r := rect{p.X - reach, p.Y - reach, p.X + reach, p.Y + reach}
+	for _, e := range ix.tree.search(r.intersects) {
+		s := e.data.(*Segment)
+		if closest, d, t := project(p, s.A, s.B); d <= radius {
+			matches = append(matches, SnapCandidate{Segment: *s, Distance: d, Point: closest, Fraction: t})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b SnapCandidate) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	return matches
+}
@@ -0,0 +1,229 @@
+package spatial_test
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+func TestProjectToSegment(t *testing.T) {
+	a, b := spatial.Point{X: 0, Y: 0}, spatial.Point{X: 10, Y: 0}
+	for _, tc := range []struct {
+		p, a, b, want spatial.Point
+		dist          float64
+	}{
+		{spatial.Point{X: 4, Y: 3}, a, b, spatial.Point{X: 4, Y: 0}, 3},
+		{spatial.Point{X: -3, Y: 4}, a, b, a, 5},
+		{spatial.Point{X: 13, Y: -4}, a, b, b, 5},
+		{spatial.Point{X: 10, Y: 0}, a, b, b, 0},
+		{spatial.Point{X: 3, Y: 4}, a, a, a, 5}, // Zero length
+		{spatial.Point{X: 0.1, Y: 0.7}, spatial.Point{X: 0.1, Y: 0.2}, spatial.Point{X: 0.3, Y: 0.2}, spatial.Point{X: 0.1, Y: 0.2}, 0.5},
+	} {
+		got, dist := spatial.ProjectToSegment(tc.p, tc.a, tc.b)
+		if got != tc.want || math.Abs(dist-tc.dist) > 1e-12 {
+			t.Errorf("%v onto %v-%v: expected %v at %v, got %v at %v", tc.p, tc.a, tc.b, tc.want, tc.dist, got, dist)
+		}
+	}
+	// Projections on a diagonal lie on it, between its ends
+	p, _ := spatial.ProjectToSegment(spatial.Point{X: 0, Y: 10}, a, spatial.Point{X: 10, Y: 10})
+	if p.X != 5 || p.Y != 5 {
+		t.Errorf("expected 5, 5, got %v", p)
+	}
+}
+
+// TestSegmentIndexMatchesBruteForce tests both queries against projecting
+// onto every segment, among long, short and zero-length segments with
+// duplicates, from points at random and on segments' ends
+func TestSegmentIndexMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ix := spatial.NewSegmentIndex()
+	var segments []spatial.Segment
+	for i := 0; i < 3000; i++ {
+		a := spatial.Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}
+		b := a
+		switch i % 3 {
+		case 0:
+			b = spatial.Point{X: a.X + rng.NormFloat64()*200, Y: a.Y + rng.NormFloat64()*200}
+		case 1:
+			b = spatial.Point{X: a.X + rng.NormFloat64()*5, Y: a.Y + rng.NormFloat64()*5}
+		}
+		s := spatial.Segment{A: a, B: b, Data: i}
+		if i%100 == 99 {
+			s = spatial.Segment{A: segments[i-50].B, B: segments[i-50].A, Data: i} // Reversed duplicate
+		}
+		if err := ix.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+		segments = append(segments, s)
+	}
+	if ix.Count() != len(segments) {
+		t.Fatalf("expected %d segments, got %d", len(segments), ix.Count())
+	}
+	for i := 0; i < 2000; i++ {
+		p := spatial.Point{X: rng.Float64()*1200 - 100, Y: rng.Float64()*1200 - 100}
+		if i%2 == 0 {
+			p = segments[rng.Intn(len(segments))].B
+		}
+		radius := rng.Float64() * 40
+
+		var want []spatial.SnapCandidate
+		best := -1
+		bestDist := math.Inf(1)
+		for j, s := range segments {
+			closest, d := spatial.ProjectToSegment(p, s.A, s.B)
+			if d < bestDist {
+				best, bestDist = j, d
+			}
+			if d <= radius {
+				want = append(want, spatial.SnapCandidate{Segment: s, Distance: d, Point: closest})
+			}
+		}
+		slices.SortStableFunc(want, func(a, b spatial.SnapCandidate) int { return cmp.Compare(a.Distance, b.Distance) })
+
+		s, d, closest, ok := ix.NearestSegment(p)
+		if !ok || s.Data != best || d != bestDist {
+			t.Fatalf("nearest %v: expected %d at %v, got %v at %v", p, best, bestDist, s.Data, d)
+		}
+		if wantClosest, _ := spatial.ProjectToSegment(p, s.A, s.B); closest != wantClosest {
+			t.Fatalf("nearest %v: expected closest point %v, got %v", p, wantClosest, closest)
+		}
+		got := ix.SegmentsWithin(p, radius)
+		if !slices.EqualFunc(got, want, func(a, b spatial.SnapCandidate) bool {
+			return a.Segment.Data == b.Segment.Data && a.Distance == b.Distance && a.Point == b.Point
+		}) {
+			t.Fatalf("within %v of %v: expected %v, got %v", radius, p, want, got)
+		}
+	}
+}
+
+func TestSegmentIndexEmpty(t *testing.T) {
+	ix := spatial.NewSegmentIndex()
+	if _, _, _, ok := ix.NearestSegment(spatial.Point{}); ok {
+		t.Error("expected nothing from an empty index")
+	}
+	if err := ix.Insert(spatial.Segment{B: spatial.Point{X: math.NaN()}}); !errors.Is(err, spatial.ErrInvalidPoint) {
+		t.Errorf("expected ErrInvalidPoint, got %v", err)
+	}
+	ix.Insert(spatial.Segment{A: spatial.Point{X: 1, Y: 1}, B: spatial.Point{X: 1, Y: 1}})
+	if _, _, _, ok := ix.NearestSegment(spatial.Point{X: math.Inf(1)}); ok {
+		t.Error("expected nothing for an infinite point")
+	}
+	if got := ix.SegmentsWithin(spatial.Point{X: 1, Y: 2}, 1); len(got) != 1 || got[0].Distance != 1 {
+		t.Errorf("expected the zero-length segment at distance 1, got %v", got)
+	}
+}
+
+// BenchmarkNearestSegment indexes 100,000 segments up to 60 long, starting
+// at each distribution's points
+func BenchmarkNearestSegment(b *testing.B) {
+	forEachDistribution(b, 100000, func(b *testing.B, points []spatial.Point) {
+		ix := spatial.NewSegmentIndex()
+		for i, p := range points {
+			angle := float64(i) * 2.39996
+			length := float64(i % 61)
+			ix.Insert(spatial.Segment{A: p, B: spatial.Point{X: p.X + length*math.Cos(angle), Y: p.Y + length*math.Sin(angle)}, Data: i})
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p := points[(i*7919)%len(points)]
+			ix.NearestSegment(spatial.Point{X: p.X + 7, Y: p.Y - 3})
+		}
+	})
+}
+
+// roadGrid returns the segments of a street grid n intersections on a side,
+// spacing apart: each row's segments west to east, then each column's
+// south to north
+func roadGrid(n int, spacing float64) []spatial.Segment {
+	var segments []spatial.Segment
+	for j := 0; j < n; j++ {
+		for i := 0; i+1 < n; i++ {
+			a := spatial.Point{X: float64(i) * spacing, Y: float64(j) * spacing}
+			segments = append(segments, spatial.Segment{A: a, B: spatial.Point{X: a.X + spacing, Y: a.Y}, Data: len(segments)})
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j+1 < n; j++ {
+			a := spatial.Point{X: float64(i) * spacing, Y: float64(j) * spacing}
+			segments = append(segments, spatial.Segment{A: a, B: spatial.Point{X: a.X, Y: a.Y + spacing}, Data: len(segments)})
+		}
+	}
+	return segments
+}
+
+// TestSnapCandidatesMatchesBruteForce tests candidates on a street grid
+// against projecting onto every street, from points at random, at
+// intersections, where four streets tie, and midway between streets
+func TestSnapCandidatesMatchesBruteForce(t *testing.T) {
+	segments := roadGrid(30, 100)
+	ix := spatial.NewSegmentIndex()
+	for _, s := range segments {
+		ix.Insert(s)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		p := spatial.Point{X: rng.Float64()*3100 - 100, Y: rng.Float64()*3100 - 100}
+		switch i % 4 {
+		case 0:
+			p = spatial.Point{X: float64(rng.Intn(30)) * 100, Y: float64(rng.Intn(30)) * 100}
+		case 1:
+			p = spatial.Point{X: float64(rng.Intn(30))*100 + 50, Y: float64(rng.Intn(30))*100 + 50}
+		}
+		n := 1 + rng.Intn(6)
+		maxDist := []float64{30, 100, math.Inf(1)}[rng.Intn(3)]
+
+		var want []spatial.SnapCandidate
+		for _, s := range segments {
+			if closest, d := spatial.ProjectToSegment(p, s.A, s.B); d <= maxDist {
+				want = append(want, spatial.SnapCandidate{Segment: s, Distance: d, Point: closest})
+			}
+		}
+		slices.SortStableFunc(want, func(a, b spatial.SnapCandidate) int { return cmp.Compare(a.Distance, b.Distance) })
+		want = want[:min(n, len(want))]
+
+		got := ix.SnapCandidates(p, n, maxDist)
+		if !slices.EqualFunc(got, want, func(a, b spatial.SnapCandidate) bool {
+			return a.Segment.Data == b.Segment.Data && a.Distance == b.Distance && a.Point == b.Point
+		}) {
+			t.Fatalf("%d within %v of %v: expected %v, got %v", n, maxDist, p, want, got)
+		}
+		for _, c := range got {
+			s := c.Segment
+			along := spatial.Point{X: s.A.X + c.Fraction*(s.B.X-s.A.X), Y: s.A.Y + c.Fraction*(s.B.Y-s.A.Y)}
+			if c.Fraction < 0 || c.Fraction > 1 || spatial.Distance(along, c.Point) > 1e-9 {
+				t.Fatalf("%v: fraction %v doesn't place %v on %v", p, c.Fraction, c.Point, s)
+			}
+		}
+	}
+	for _, tc := range []struct {
+		n       int
+		maxDist float64
+	}{{0, 10}, {1, -1}, {1, math.NaN()}} {
+		if got := ix.SnapCandidates(spatial.Point{}, tc.n, tc.maxDist); got == nil || len(got) != 0 {
+			t.Errorf("n %d, max %v: expected no candidates, got %v", tc.n, tc.maxDist, got)
+		}
+	}
+}
+
+// BenchmarkSnapCandidates snaps to a city-sized grid of about 500,000
+// streets 80 apart
+func BenchmarkSnapCandidates(b *testing.B) {
+	ix := spatial.NewSegmentIndex()
+	for _, s := range roadGrid(500, 80) {
+		ix.Insert(s)
+	}
+	rng := rand.New(rand.NewSource(1))
+	probes := make([]spatial.Point, 4096)
+	for i := range probes {
+		probes[i] = spatial.Point{X: rng.Float64() * 40000, Y: rng.Float64() * 40000}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ix.SnapCandidates(probes[i%len(probes)], 5, 50)
+	}
+}
@@ -0,0 +1,231 @@
+package spatial
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// SmoothingMode picks the filter a Smoother runs
+type SmoothingMode uint8
+
+const (
+	// SmoothEMA is an exponential moving average of position, weighted by
+	// the time since the last update. It is cheap and lags behind a moving
+	// driver by about its time constant.
+	SmoothEMA SmoothingMode = iota
+	// SmoothKalman is a constant-velocity Kalman filter on each axis, which
+	// follows a moving driver without the average's lag
+	SmoothKalman
+)
+
+// Defaults for the SmootherConfig fields left at zero, suited to positions
+// in meters
+const (
+	DefaultSmoothingTimeConstant = 3 * time.Second
+	DefaultProcessNoise          = 1.0 // Meters per second squared
+	DefaultMeasurementNoise      = 5.0 // Meters
+)
+
+// SmootherConfig configures NewSmoother
+type SmootherConfig struct {
+	Mode SmoothingMode
+	// Geo filters X and Y as longitude and latitude, in meters on a local
+	// projection, so noise and Innovation are in meters
+	Geo bool
+	// TimeConstant is how far back SmoothEMA mostly looks, the time
+	// after which an observation's weight has fallen to 1/e.
+	// DefaultSmoothingTimeConstant if 0.
+	TimeConstant time.Duration
+	// ProcessNoise is the standard deviation of SmoothKalman's unmodelled
+	// acceleration, DefaultProcessNoise if 0. Raising it follows turns and
+	// braking more closely.
+	ProcessNoise float64
+	// MeasurementNoise is the standard deviation of a raw position for
+	// SmoothKalman, DefaultMeasurementNoise if 0
+	MeasurementNoise float64
+	// Gap is how long an ID may go without updates before its filter starts
+	// over from the next raw position. An ID past it is also forgotten, as
+	// Track forgets IDs past its TTL. Zero never resets.
+	Gap time.Duration
+}
+
+// Smoother filters GPS jitter out of each ID's positions before they are
+// stored: pass each raw position through Smooth and Upsert what it returns.
+// It is safe for concurrent use.
+type Smoother struct {
+	cfg SmootherConfig
+
+	mu        sync.Mutex // Guards ids, latest and lastSweep
+	ids       map[string]*smoothState
+	latest    time.Time
+	lastSweep time.Time
+}
+
+// smoothState is one ID's filter. Positions are in the filter's frame: the
+// tree's units, or meters on proj under Geo.
+type smoothState struct {
+	proj       Equirectangular
+	t          time.Time
+	x, y       kalmanAxis // Position, and for SmoothKalman velocity
+	innovation float64
+}
+
+// kalmanAxis is the position and velocity along one axis with their
+// covariance. SmoothEMA uses only pos.
+type kalmanAxis struct {
+	pos, vel float64
+	p        [2][2]float64
+}
+
+// NewSmoother returns a Smoother with no IDs
+func NewSmoother(cfg SmootherConfig) *Smoother {
+	if cfg.TimeConstant <= 0 {
+		cfg.TimeConstant = DefaultSmoothingTimeConstant
+	}
+	if cfg.ProcessNoise <= 0 {
+		cfg.ProcessNoise = DefaultProcessNoise
+	}
+	if cfg.MeasurementNoise <= 0 {
+		cfg.MeasurementNoise = DefaultMeasurementNoise
+	}
+	return &Smoother{cfg: cfg, ids: make(map[string]*smoothState)}
+}
+
+// Smooth folds raw, observed at t, into id's filter and returns the position
+// to store, with raw's Data. The first position for an ID, or the first
+// after a Gap, is returned as it is. Updates may come at any interval; one
+// older than the last is folded in as if it were simultaneous with it. A
+// raw with a NaN or infinite coordinate is returned as it is, for Upsert to
+// refuse, and left out of the filter.
+func (s *Smoother) Smooth(id string, raw Point, t time.Time) Point {
+	if !validCoordinates(raw) {
+		return raw
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.latest) {
+		s.latest = t
+	}
+	s.sweep()
+
+	st := s.ids[id]
+	if st == nil || (s.cfg.Gap > 0 && t.Sub(st.t) > s.cfg.Gap) {
+		st = s.reset(raw, t)
+		s.ids[id] = st
+		return raw
+	}
+	dt := math.Max(t.Sub(st.t).Seconds(), 0)
+	if t.After(st.t) {
+		st.t = t
+	}
+	z := s.toFrame(st, raw)
+	if s.cfg.Mode == SmoothKalman {
+		q, r := s.cfg.ProcessNoise*s.cfg.ProcessNoise, s.cfg.MeasurementNoise*s.cfg.MeasurementNoise
+		st.x.kalman(z.X, dt, q, r)
+		st.y.kalman(z.Y, dt, q, r)
+	} else {
+		alpha := 1 - math.Exp(-dt/s.cfg.TimeConstant.Seconds())
+		st.x.pos += alpha * (z.X - st.x.pos)
+		st.y.pos += alpha * (z.Y - st.y.pos)
+	}
+	filtered := s.fromFrame(st, Point{X: st.x.pos, Y: st.y.pos})
+	filtered.Data = raw.Data
+	st.innovation = math.Hypot(z.X-st.x.pos, z.Y-st.y.pos)
+	return filtered
+}
+
+// reset starts a filter at raw
+func (s *Smoother) reset(raw Point, t time.Time) *smoothState {
+	st := &smoothState{t: t}
+	if s.cfg.Geo {
+		st.proj = Equirectangular{Origin: FromPoint(raw, nil)}
+	}
+	z := s.toFrame(st, raw)
+	// The velocity is unknown until the second update, so its variance
+	// starts large enough for that update to set it
+	r := s.cfg.MeasurementNoise * s.cfg.MeasurementNoise
+	p := [2][2]float64{{r, 0}, {0, 1e4 * r}}
+	st.x = kalmanAxis{pos: z.X, p: p}
+	st.y = kalmanAxis{pos: z.Y, p: p}
+	return st
+}
+
+func (s *Smoother) toFrame(st *smoothState, p Point) Point {
+	if !s.cfg.Geo {
+		return p
+	}
+	return st.proj.Project(FromPoint(p, nil))
+}
+
+func (s *Smoother) fromFrame(st *smoothState, p Point) Point {
+	if !s.cfg.Geo {
+		return p
+	}
+	return st.proj.Unproject(p).ToPoint(nil)
+}
+
+// kalman predicts the axis dt seconds ahead under white-noise acceleration
+// of variance q, then corrects it by the measurement z of variance r
+func (a *kalmanAxis) kalman(z, dt, q, r float64) {
+	p := a.p
+	a.pos += a.vel * dt
+	dt2 := dt * dt
+	p00 := p[0][0] + dt*(p[1][0]+p[0][1]) + dt2*p[1][1] + q*dt2*dt2/4
+	p01 := p[0][1] + dt*p[1][1] + q*dt2*dt/2
+	p10 := p[1][0] + dt*p[1][1] + q*dt2*dt/2
+	p11 := p[1][1] + q*dt2
+
+	innovation := z - a.pos
+	k0, k1 := p00/(p00+r), p10/(p00+r)
+	a.pos += k0 * innovation
+	a.vel += k1 * innovation
+	a.p = [2][2]float64{
+		{(1 - k0) * p00, (1 - k0) * p01},
+		{p10 - k1*p00, p11 - k1*p01},
+	}
+}
+
+// Innovation returns the distance between id's last raw position and the
+// position Smooth returned for it, in the tree's units or meters under Geo.
+// A large one flags a reading worth logging. It reports false for an ID
+// the Smoother doesn't know.
+func (s *Smoother) Innovation(id string) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.ids[id]
+	if st == nil {
+		return 0, false
+	}
+	return st.innovation, true
+}
+
+// Forget drops id's filter, reporting whether it had one
+func (s *Smoother) Forget(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.ids[id]
+	delete(s.ids, id)
+	return ok
+}
+
+// Len returns the number of IDs with a filter
+func (s *Smoother) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.ids)
+}
+
+// sweep forgets IDs whose last update is more than Gap before the latest,
+// at most once per Gap. Callers must hold mu.
+func (s *Smoother) sweep() {
+	if s.cfg.Gap <= 0 || s.latest.Sub(s.lastSweep) < s.cfg.Gap {
+		return
+	}
+	s.lastSweep = s.latest
+	for id, st := range s.ids {
+		if s.latest.Sub(st.t) > s.cfg.Gap {
+			delete(s.ids, id)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package spatial
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// noisyTrack drives a loop of straight legs and gradual turns at speed
+// meters per second, reporting every half second to second and a half
+// with 5m of GPS noise on each axis. It returns the true and the reported
+// positions and their times.
+func noisyTrack(rng *rand.Rand, n int, speed float64) (truth, raw []Point, times []time.Time) {
+	t := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	var pos Point
+	heading := 0.0
+	for i := 0; i < n; i++ {
+		dt := time.Duration(500+rng.Intn(1000)) * time.Millisecond
+		t = t.Add(dt)
+		if i%60 >= 45 { // A quarter turn over 15 updates
+			heading += math.Pi / 30
+		}
+		pos.X += speed * dt.Seconds() * math.Sin(heading)
+		pos.Y += speed * dt.Seconds() * math.Cos(heading)
+		truth = append(truth, pos)
+		raw = append(raw, Point{X: pos.X + rng.NormFloat64()*5, Y: pos.Y + rng.NormFloat64()*5})
+		times = append(times, t)
+	}
+	return truth, raw, times
+}
+
+func rmsError(truth, got []Point) float64 {
+	var sum float64
+	for i := range truth {
+		sum += DistanceSquared(truth[i], got[i])
+	}
+	return math.Sqrt(sum / float64(len(truth)))
+}
+
+// TestSmootherReducesNoise compares the RMS error of each mode's output on
+// noisy tracks with the raw positions'. The average lags a moving driver,
+// so it is only asked to help at walking pace.
+func TestSmootherReducesNoise(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   SmootherConfig
+		speed float64
+		max   float64 // Largest acceptable fraction of the raw error
+	}{
+		{"ema parked", SmootherConfig{Mode: SmoothEMA}, 0, 0.5},
+		{"ema walking", SmootherConfig{Mode: SmoothEMA, TimeConstant: 2 * time.Second}, 1.5, 0.75},
+		{"kalman parked", SmootherConfig{Mode: SmoothKalman}, 0, 0.75},
+		{"kalman walking", SmootherConfig{Mode: SmoothKalman}, 1.5, 0.75},
+		{"kalman driving", SmootherConfig{Mode: SmoothKalman}, 10, 0.75},
+	} {
+		truth, raw, times := noisyTrack(rand.New(rand.NewSource(1)), 400, tc.speed)
+		s := NewSmoother(tc.cfg)
+		filtered := make([]Point, len(raw))
+		for i := range raw {
+			filtered[i] = s.Smooth("d1", raw[i], times[i])
+		}
+		rawRMS, got := rmsError(truth, raw), rmsError(truth, filtered)
+		t.Logf("%s: raw RMS %.2fm, filtered %.2fm", tc.name, rawRMS, got)
+		if got > tc.max*rawRMS {
+			t.Errorf("%s: expected RMS error under %.0f%% of raw %.2fm, got %.2fm", tc.name, tc.max*100, rawRMS, got)
+		}
+	}
+}
+
+// TestSmootherGap tests that an ID's filter starts over after a gap, and
+// that the innovation measures raw against filtered
+func TestSmootherGap(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := NewSmoother(SmootherConfig{Mode: SmoothKalman, Gap: time.Minute})
+	if _, ok := s.Innovation("d1"); ok {
+		t.Error("Expected no innovation for an unknown ID")
+	}
+	first := Point{X: 10, Y: 10, Data: "d1"}
+	if got := s.Smooth("d1", first, start); got != first {
+		t.Errorf("Expected the first position returned as is, got %v", got)
+	}
+	for i := 1; i <= 10; i++ {
+		s.Smooth("d1", Point{X: 10, Y: 10}, start.Add(time.Duration(i)*time.Second))
+	}
+	// A single 100m jump is mostly smoothed away, and flagged
+	jump := Point{X: 110, Y: 10, Data: "d1"}
+	got := s.Smooth("d1", jump, start.Add(11*time.Second))
+	if got.X > 60 || got.Data != "d1" {
+		t.Errorf("Expected the jump mostly filtered out with Data kept, got %v", got)
+	}
+	if inn, ok := s.Innovation("d1"); !ok || math.Abs(inn-Distance(jump, got)) > 1e-9 || inn < 50 {
+		t.Errorf("Expected an innovation of %v, got %v, %v", Distance(jump, got), inn, ok)
+	}
+
+	// After an hour offline the driver is wherever it says it is
+	back := Point{X: 5000, Y: 5000}
+	if got := s.Smooth("d1", back, start.Add(time.Hour)); got != back {
+		t.Errorf("Expected the filter reset after the gap, got %v", got)
+	}
+	if inn, _ := s.Innovation("d1"); inn != 0 {
+		t.Errorf("Expected no innovation after a reset, got %v", inn)
+	}
+
+	// Idle IDs are forgotten
+	for i := 0; i < 20; i++ {
+		s.Smooth(string(rune('a'+i)), Point{}, start.Add(time.Hour))
+	}
+	s.Smooth("d1", back, start.Add(2*time.Hour+time.Second))
+	if s.Len() != 1 {
+		t.Errorf("Expected only d1 left, got %d", s.Len())
+	}
+	if !s.Forget("d1") || s.Len() != 0 {
+		t.Error("Expected Forget to drop d1")
+	}
+}
+
+// TestSmootherNonFinite tests that a NaN or infinite reading is passed
+// through without disturbing the filter, in both modes
+func TestSmootherNonFinite(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, mode := range []SmoothingMode{SmoothEMA, SmoothKalman} {
+		s := NewSmoother(SmootherConfig{Mode: mode})
+		if got := s.Smooth("d1", Point{X: math.NaN(), Y: 1}, start); !math.IsNaN(got.X) || s.Len() != 0 {
+			t.Errorf("mode %d: expected a first NaN reading passed through and not tracked, got %v", mode, got)
+		}
+		for i := 1; i <= 5; i++ {
+			s.Smooth("d1", Point{X: 10, Y: 10}, start.Add(time.Duration(i)*time.Second))
+		}
+		for _, raw := range []Point{{X: math.NaN(), Y: 10}, {X: 10, Y: math.Inf(1)}} {
+			if got := s.Smooth("d1", raw, start.Add(6*time.Second)); validCoordinates(got) {
+				t.Errorf("mode %d: expected %v passed through, got %v", mode, raw, got)
+			}
+		}
+		got := s.Smooth("d1", Point{X: 10, Y: 10}, start.Add(7*time.Second))
+		if math.Abs(got.X-10) > 1e-6 || math.Abs(got.Y-10) > 1e-6 {
+			t.Errorf("mode %d: expected the filter to carry on at 10, 10, got %v", mode, got)
+		}
+	}
+}
+
+// TestSmootherGeo tests that geographic positions are filtered in meters,
+// so the default noise suits them
+func TestSmootherGeo(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	truth, raw, times := noisyTrack(rng, 300, 10)
+	origin := GeoPoint{Lat: 59.9, Lon: 10.75}
+	proj := Equirectangular{Origin: origin}
+	for i := range truth {
+		truth[i] = proj.Unproject(truth[i]).ToPoint(nil)
+		raw[i] = proj.Unproject(raw[i]).ToPoint(nil)
+	}
+	s := NewSmoother(SmootherConfig{Mode: SmoothKalman, Geo: true})
+	var rawSum, filteredSum float64
+	for i := range raw {
+		f := s.Smooth("d1", raw[i], times[i])
+		rawSum += math.Pow(haversine(truth[i], raw[i]), 2)
+		filteredSum += math.Pow(haversine(truth[i], f), 2)
+		if inn, _ := s.Innovation("d1"); inn > 40 {
+			t.Fatalf("Expected innovations of a few meters, got %v at %d", inn, i)
+		}
+	}
+	if filteredSum > 0.5*rawSum {
+		t.Errorf("Expected filtering to at least halve the squared error, raw %.0f, filtered %.0f", rawSum, filteredSum)
+	}
+}
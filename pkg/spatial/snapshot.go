@@ -0,0 +1,109 @@
+package spatial
+
+// Snapshot returns a read-only view of the tree as it is now. Taking one is
+// O(1): the snapshot shares every node with the live tree, and the live tree
+// copies a node (and the path above it) the first time it writes to it after
+// the snapshot, so the snapshot never changes. Nodes no longer reachable from
+// the live tree are freed once the snapshot is garbage collected.
+//
+// Mutating methods on the snapshot fail (returning false, ErrReadOnly or doing
+// nothing), and it has no ID index, so GetByID always misses. Writes that
+// bypass the QuadTree methods, such as Root.InsertNode, are not copied and
+// must not be mixed with snapshots.
+func (qt *QuadTree) Snapshot() *QuadTree {
+	qt.Lock.Lock()
+	defer qt.Lock.Unlock()
+	return qt.snapshot()
+}
+
+// snapshot is Snapshot for callers that hold the write lock
+func (qt *QuadTree) snapshot() *QuadTree {
+	snap := &QuadTree{
+		Root:     qt.Root,
+		geo:      qt.geo,
+		metric:   qt.metric,
+		proj:     qt.proj,
+		unit:     qt.unit,
+		track:    qt.track,
+		nextSeq:  qt.nextSeq,
+		size:     qt.size,
+		now:      qt.now,
+		expiring: qt.expiring,
+		gen:      qt.gen,
+		readOnly: true,
+		edges:    qt.edges,
+		codec:    qt.codec,
+		version:  qt.version,
+	}
+	// Every existing node now belongs to an older generation and is shared
+	qt.gen++
+	return snap
+}
+
+// own makes the root writable for the current generation, reporting false on
+// a read-only snapshot. Mutating methods call it first, under the write lock,
+// which also starts a new version and records the one being replaced.
+func (qt *QuadTree) own() bool {
+	if qt.readOnly {
+		return false
+	}
+	if !qt.batch {
+		qt.beginVersion()
+	}
+	if qt.Root.gen != qt.gen {
+		qt.Root = qt.Root.clone(qt.gen)
+		qt.Root.parent = nil
+	}
+	return true
+}
+
+// clone copies n into generation gen. Children stay shared until they are
+// written to themselves, but link back to the copy, so the node it replaces
+// isn't kept reachable from the live tree once no snapshot holds it; tracked
+// points are repointed at the copy.
+func (n *Node) clone(gen uint64) *Node {
+	c := new(Node)
+	*c = *n
+	c.gen = gen
+	c.touches = n.touches.copy()
+	for _, child := range c.Children {
+		if child != nil {
+			child.parent = c
+		}
+	}
+	if n.Points != nil {
+		c.Points = make([]Point, len(n.Points), cap(n.Points))
+		copy(c.Points, n.Points)
+	}
+	for _, p := range c.Points {
+		if p.loc != nil {
+			p.loc.leaf = c
+		}
+	}
+	return c
+}
+
+// writableChild returns n's child in quadrant q, copying it first if it is
+// shared with a snapshot. n itself must already be writable.
+func (n *Node) writableChild(q int) *Node {
+	c := n.Children[q]
+	if c.gen != n.gen {
+		c = c.clone(n.gen)
+		c.parent = n
+		n.Children[q] = c
+	}
+	return c
+}
+
+// ownLoc makes the path to a tracked point writable, so loc.leaf can be
+// modified in place. Callers must have called own.
+func (qt *QuadTree) ownLoc(loc *location) {
+	if loc.leaf.gen == qt.gen {
+		return
+	}
+	p, _ := loc.point()
+	n := qt.Root
+	for n.Children[0] != nil {
+		n = n.writableChild(n.quadrant(p))
+	}
+}
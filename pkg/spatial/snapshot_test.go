@@ -0,0 +1,192 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// TestSnapshotUnchangedByMutations tests that a snapshot's results survive heavy churn on the live tree
+func TestSnapshotUnchangedByMutations(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4), WithAutoExpand())
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 500; i++ {
+		qt.InsertWithID(fmt.Sprintf("d%d", i), Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Data: i})
+		qt.Insert(Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Data: -i})
+	}
+
+	snap := qt.Snapshot()
+	queries := []Bounds{snap.Root.Bounds, {X: 100, Y: 100, Width: 300, Height: 200}, {X: 700, Y: 0, Width: 50, Height: 1000}}
+	var before [][]Point
+	for _, q := range queries {
+		before = append(before, snap.Search(q))
+	}
+	statsBefore := snap.Stats()
+	nearestBefore := snap.KNearest(Point{X: 500, Y: 500}, 20)
+
+	for i := 0; i < 500; i++ {
+		id := fmt.Sprintf("d%d", i)
+		switch i % 4 {
+		case 0:
+			qt.RemoveByID(id)
+		case 1:
+			qt.Move(id, rng.Float64()*1000, rng.Float64()*1000)
+		case 2:
+			qt.UpdateByID(id, Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000, Data: "moved"})
+		case 3:
+			qt.Insert(Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+		}
+	}
+	for _, p := range qt.Search(Bounds{X: 0, Y: 0, Width: 500, Height: 500}) {
+		qt.Remove(p)
+	}
+	qt.Insert(Point{X: -3000, Y: 2500})
+	qt.Compact()
+
+	for i, q := range queries {
+		if got := snap.Search(q); !reflect.DeepEqual(got, before[i]) {
+			t.Errorf("Query %d changed: %d points before, %d after", i, len(before[i]), len(got))
+		}
+	}
+	if got := snap.Stats(); !reflect.DeepEqual(got, statsBefore) {
+		t.Errorf("Snapshot shape changed: %v, was %v", got, statsBefore)
+	}
+	if got := snap.KNearest(Point{X: 500, Y: 500}, 20); !reflect.DeepEqual(got, nearestBefore) {
+		t.Error("Snapshot KNearest changed")
+	}
+
+	qt.Clear()
+	if got := snap.Search(snap.Root.Bounds); !reflect.DeepEqual(got, before[0]) {
+		t.Error("Clear on the live tree changed the snapshot")
+	}
+}
+
+// TestSnapshotLiveTreeStaysCorrect tests that path copying leaves the live tree consistent
+func TestSnapshotLiveTreeStaysCorrect(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+	for i := 0; i < 50; i++ {
+		qt.InsertWithID(fmt.Sprintf("d%d", i), Point{X: float64(i*37%100) + 0.5, Y: float64(i*11%100) + 0.5})
+	}
+
+	for round := 0; round < 3; round++ {
+		qt.Snapshot()
+		for i := round; i < 50; i += 3 {
+			id := fmt.Sprintf("d%d", i)
+			if err := qt.Move(id, float64(i*13%100)+0.25, float64(i*7%100)+0.25); err != nil {
+				t.Fatalf("Move %s failed: %v", id, err)
+			}
+			if p, ok := qt.GetByID(id); !ok || p.X != float64(i*13%100)+0.25 {
+				t.Fatalf("GetByID %s after move returned %v, %v", id, p, ok)
+			}
+		}
+	}
+	for i := 0; i < 50; i++ {
+		if !qt.RemoveByID(fmt.Sprintf("d%d", i)) {
+			t.Errorf("RemoveByID d%d failed", i)
+		}
+	}
+	if qt.Size() != 0 || len(qt.Search(qt.Root.Bounds)) != 0 || qt.Root.count != 0 {
+		t.Errorf("Expected an empty tree, got size %d", qt.Size())
+	}
+}
+
+// liveHeap returns the heap still in use after a full collection
+func liveHeap() uint64 {
+	runtime.GC()
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// TestSnapshotHeapStabilizes tests that the paths copied after each snapshot
+// are reclaimed along with it when single writes are interleaved with
+// snapshots, rather than kept reachable through shared children
+func TestSnapshotHeapStabilizes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("churns snapshots")
+	}
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8))
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]string, 5000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("v%d", i)
+		qt.InsertWithID(ids[i], Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+
+	churn := func(writes int) {
+		for i := 0; i < writes; i++ {
+			if i%10 == 0 {
+				qt.Snapshot()
+			}
+			qt.Move(ids[rng.Intn(len(ids))], rng.Float64()*1000, rng.Float64()*1000)
+		}
+	}
+	churn(20000)
+	base := liveHeap()
+	churn(100000)
+	if after := liveHeap(); after > base+base/2 {
+		t.Errorf("Heap grew from %d to %d bytes while interleaving snapshots and writes", base, after)
+	}
+	if errs := qt.Validate(); len(errs) != 0 {
+		t.Errorf("Tree invalid after churn: %v", errs)
+	}
+}
+
+// TestSnapshotIsReadOnly tests that mutating a snapshot fails without touching it
+func TestSnapshotIsReadOnly(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.InsertWithID("a", Point{X: 10, Y: 10})
+	snap := qt.Snapshot()
+
+	if snap.Insert(Point{X: 20, Y: 20}) || snap.Remove(Point{X: 10, Y: 10}) {
+		t.Error("Snapshot should reject Insert and Remove")
+	}
+	if err := snap.InsertWithID("b", Point{X: 20, Y: 20}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	if err := snap.Move("a", 50, 50); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	snap.Clear()
+	if snap.Size() != 1 || len(snap.Search(snap.Root.Bounds)) != 1 {
+		t.Error("Snapshot contents changed")
+	}
+}
+
+// TestSnapshotConcurrentReads tests reading a snapshot while the live tree is written
+func TestSnapshotConcurrentReads(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4))
+	for i := 0; i < 1000; i++ {
+		qt.Insert(Point{X: float64(i % 1000), Y: float64(i * 7 % 1000)})
+	}
+	snap := qt.Snapshot()
+	want := len(snap.Search(snap.Root.Bounds))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			qt.Remove(Point{X: float64(i % 1000), Y: float64(i * 7 % 1000)})
+			qt.Insert(Point{X: float64(i * 3 % 1000), Y: float64(i % 1000)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if got := len(snap.Search(snap.Root.Bounds)); got != want {
+				t.Errorf("Snapshot returned %d points, expected %d", got, want)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	checkValid(t, qt)
+	checkValid(t, snap)
+}
@@ -0,0 +1,371 @@
+package spatialtest
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// ConformanceBounds is the area Conformance asks newIndex to cover
+var ConformanceBounds = spatial.Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+
+// Conformance runs the behavior spatial.SpatialIndex documents against
+// indexes from newIndex, which must return an empty index with planar
+// distances holding at least ConformanceBounds. Each subtest gets a fresh
+// index. Use it from a test to prove a new backend equivalent to QuadTree:
+//
+//	func TestQuadTreeConformance(t *testing.T) {
+//		spatialtest.Conformance(t, func(b spatial.Bounds) spatial.SpatialIndex {
+//			qt, _ := spatial.NewQuadTree(b)
+//			return qt
+//		})
+//	}
+//
+// Points are tagged with an int in Data, which indexes must hand back
+// unchanged.
+func Conformance(t *testing.T, newIndex func(bounds spatial.Bounds) spatial.SpatialIndex) {
+	t.Run("Empty", func(t *testing.T) {
+		ix := newIndex(ConformanceBounds)
+		if n := ix.Count(); n != 0 {
+			t.Errorf("Count: expected 0, got %d", n)
+		}
+		for name, got := range map[string][]spatial.Point{
+			"Search":       ix.Search(ConformanceBounds),
+			"SearchRadius": ix.SearchRadius(spatial.Point{X: 500, Y: 500}, 1000),
+			"KNearest":     ix.KNearest(spatial.Point{X: 500, Y: 500}, 3),
+		} {
+			if got == nil || len(got) != 0 {
+				t.Errorf("%s: expected an empty slice, got %#v", name, got)
+			}
+		}
+		ix.ForEach(func(p spatial.Point) bool {
+			t.Errorf("ForEach: visited %v", p)
+			return true
+		})
+		if ix.Remove(spatial.Point{X: 1, Y: 1}) || ix.Update(spatial.Point{X: 1, Y: 1}, spatial.Point{X: 2, Y: 2}) {
+			t.Error("expected Remove and Update to find nothing")
+		}
+	})
+
+	t.Run("InvalidPoints", func(t *testing.T) {
+		ix := newIndex(ConformanceBounds)
+		for _, p := range []spatial.Point{
+			{X: math.NaN(), Y: 1},
+			{X: 1, Y: math.Inf(1)},
+			{X: math.Inf(-1), Y: math.NaN()},
+		} {
+			if ix.Insert(p) {
+				t.Errorf("Insert(%v): expected false", p)
+			}
+		}
+		good := spatial.Point{X: 10, Y: 10, Data: 1}
+		if !ix.Insert(good) {
+			t.Fatal("Insert: expected a point inside the bounds to be stored")
+		}
+		if ix.Update(good, spatial.Point{X: math.NaN(), Y: 10, Data: 1}) {
+			t.Error("Update to NaN: expected false")
+		}
+		if got := ix.Search(ConformanceBounds); len(got) != 1 || got[0].X != 10 || got[0].Y != 10 {
+			t.Errorf("expected a failed Update to change nothing, got %v", got)
+		}
+		if n := ix.Count(); n != 1 {
+			t.Errorf("Count: expected 1, got %d", n)
+		}
+	})
+
+	t.Run("Edges", func(t *testing.T) {
+		ix := newIndex(ConformanceBounds)
+		corners := []spatial.Point{{X: 0, Y: 0, Data: 0}, {X: 1000, Y: 1000, Data: 1}, {X: 100, Y: 200, Data: 2}}
+		for _, p := range corners {
+			if !ix.Insert(p) {
+				t.Fatalf("Insert(%v): expected true", p)
+			}
+		}
+		if got := tags(ix.Search(spatial.Bounds{X: 100, Y: 100, Width: 100, Height: 100})); !slices.Equal(got, []int{2}) {
+			t.Errorf("Search: expected a point on the area's edge, got %v", got)
+		}
+		if got := sorted(tags(ix.Search(ConformanceBounds))); !slices.Equal(got, []int{0, 1, 2}) {
+			t.Errorf("Search: expected the corners, got %v", got)
+		}
+		if got := tags(ix.SearchRadius(spatial.Point{X: 100, Y: 100}, 100)); !slices.Equal(got, []int{2}) {
+			t.Errorf("SearchRadius: expected a point at exactly the radius, got %v", got)
+		}
+		if got := ix.SearchRadius(spatial.Point{X: 100, Y: 200}, -1); got == nil || len(got) != 0 {
+			t.Errorf("SearchRadius: expected nothing for a negative radius, got %#v", got)
+		}
+		if got := ix.KNearest(spatial.Point{}, 0); got == nil || len(got) != 0 {
+			t.Errorf("KNearest: expected nothing for k = 0, got %#v", got)
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		ix := newIndex(ConformanceBounds)
+		for i := 0; i < 4; i++ {
+			ix.Insert(spatial.Point{X: 50, Y: 50, Data: i})
+		}
+		if got := tags(ix.KNearest(spatial.Point{X: 50, Y: 50}, 4)); !slices.Equal(got, []int{0, 1, 2, 3}) {
+			t.Errorf("KNearest: expected ties in insertion order, got %v", got)
+		}
+		// A bare point removes the earliest; a returned one removes itself
+		if !ix.Remove(spatial.Point{X: 50, Y: 50}) {
+			t.Fatal("Remove: expected true")
+		}
+		var third spatial.Point
+		for _, p := range ix.Search(ConformanceBounds) {
+			if p.Data == 2 {
+				third = p
+			}
+		}
+		if !ix.Remove(third) {
+			t.Fatal("Remove: expected true for a returned point")
+		}
+		if got := sorted(tags(ix.Search(ConformanceBounds))); !slices.Equal(got, []int{1, 3}) {
+			t.Errorf("expected 1 and 3 left, got %v", got)
+		}
+		// Update keeps a record's place in insertion order
+		ix.Insert(spatial.Point{X: 60, Y: 60, Data: 4})
+		if !ix.Update(spatial.Point{X: 50, Y: 50}, spatial.Point{X: 60, Y: 60, Data: 1}) {
+			t.Fatal("Update: expected true")
+		}
+		if got := tags(ix.KNearest(spatial.Point{X: 60, Y: 60}, 3)); !slices.Equal(got, []int{1, 4, 3}) {
+			t.Errorf("KNearest after Update: expected [1 4 3], got %v", got)
+		}
+	})
+
+	t.Run("ForEachStops", func(t *testing.T) {
+		ix := newIndex(ConformanceBounds)
+		for i := 0; i < 100; i++ {
+			ix.Insert(spatial.Point{X: float64(i * 10), Y: float64(i * 10), Data: i})
+		}
+		visited := 0
+		ix.ForEach(func(spatial.Point) bool {
+			visited++
+			return visited < 10
+		})
+		if visited != 10 {
+			t.Errorf("expected ForEach to stop after 10, visited %d", visited)
+		}
+	})
+
+	t.Run("Model", func(t *testing.T) {
+		conformModel(t, newIndex(ConformanceBounds), 1)
+	})
+}
+
+// ReadConformance is Conformance for a read-only spatial.SpatialReader,
+// built by build over points tagged as Conformance tags them. It checks an
+// empty index and then queries over random point sets, many coincident,
+// against a brute-force scan, with ties ranked in the order build was given
+// the points.
+func ReadConformance(t *testing.T, build func(points []spatial.Point) spatial.SpatialReader) {
+	t.Run("Empty", func(t *testing.T) {
+		ix := build(nil)
+		if n := ix.Count(); n != 0 {
+			t.Errorf("Count: expected 0, got %d", n)
+		}
+		for name, got := range map[string][]spatial.Point{
+			"Search":       ix.Search(ConformanceBounds),
+			"SearchRadius": ix.SearchRadius(spatial.Point{X: 500, Y: 500}, 1000),
+			"KNearest":     ix.KNearest(spatial.Point{X: 500, Y: 500}, 3),
+		} {
+			if got == nil || len(got) != 0 {
+				t.Errorf("%s: expected an empty slice, got %#v", name, got)
+			}
+		}
+	})
+
+	for _, n := range []int{1, 7, 100, 5000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(int64(n)))
+			points := make([]spatial.Point, n)
+			model := make([]record, n)
+			for i := range points {
+				points[i] = spatial.Point{X: float64(rng.Intn(2001)) / 2, Y: float64(rng.Intn(2001)) / 2, Data: i}
+				if rng.Intn(4) == 0 {
+					points[i].X, points[i].Y = float64(rng.Intn(5)), float64(rng.Intn(5))
+				}
+				model[i] = record{points[i], i}
+			}
+			ix := build(points)
+			conformCheck(t, ix, model, rng, 0)
+			conformCheck(t, ix, model, rng, 1)
+		})
+	}
+}
+
+// record is a point the model holds, order being its place in insertion
+// order
+type record struct {
+	p     spatial.Point
+	order int
+}
+
+// conformModel runs random operations on ix and a list of records side by
+// side, comparing every query against a scan of the list. Coordinates are
+// on a half-unit grid, so coincident points are common and distances are
+// exact.
+func conformModel(t *testing.T, ix spatial.SpatialIndex, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	coord := func() float64 { return float64(rng.Intn(2001)) / 2 }
+	point := func(tag int) spatial.Point {
+		if rng.Intn(4) == 0 { // Crowd some points together
+			return spatial.Point{X: float64(rng.Intn(5)), Y: float64(rng.Intn(5)), Data: tag}
+		}
+		return spatial.Point{X: coord(), Y: coord(), Data: tag}
+	}
+	var model []record
+	next := 0
+
+	// earliest returns the position in model of the record a bare point at
+	// p's coordinates refers to, or -1
+	earliest := func(p spatial.Point) int {
+		best := -1
+		for i, r := range model {
+			if r.p.X == p.X && r.p.Y == p.Y && (best == -1 || r.order < model[best].order) {
+				best = i
+			}
+		}
+		return best
+	}
+	// returned picks a stored point as the index returns it, and its
+	// position in model
+	returned := func() (spatial.Point, int) {
+		r := model[rng.Intn(len(model))]
+		for _, p := range ix.Search(spatial.Bounds{X: r.p.X, Y: r.p.Y}) {
+			if p.Data == r.p.Data {
+				return p, slices.IndexFunc(model, func(m record) bool { return m.p.Data == r.p.Data })
+			}
+		}
+		t.Fatalf("Search: record %v missing", r.p)
+		return spatial.Point{}, -1
+	}
+
+	for step := 0; step < 3000; step++ {
+		switch op := rng.Intn(10); {
+		case op < 4 || len(model) == 0:
+			p := point(next)
+			if !ix.Insert(p) {
+				t.Fatalf("step %d: Insert(%v): expected true", step, p)
+			}
+			model = append(model, record{p, next})
+			next++
+		case op < 6:
+			var p spatial.Point
+			var i int
+			if rng.Intn(2) == 0 {
+				p, i = returned()
+			} else {
+				p = point(-1)
+				i = earliest(p)
+			}
+			if got := ix.Remove(p); got != (i >= 0) {
+				t.Fatalf("step %d: Remove(%v): expected %v", step, p, i >= 0)
+			}
+			if i >= 0 {
+				model = slices.Delete(model, i, i+1)
+			}
+		default:
+			var p spatial.Point
+			var i int
+			if rng.Intn(2) == 0 {
+				p, i = returned()
+			} else {
+				p = point(-1)
+				i = earliest(p)
+			}
+			tag := -1
+			if i >= 0 {
+				tag = model[i].p.Data.(int)
+			}
+			to := point(tag)
+			if got := ix.Update(p, to); got != (i >= 0) {
+				t.Fatalf("step %d: Update(%v, %v): expected %v", step, p, to, i >= 0)
+			}
+			if i >= 0 {
+				model[i].p = to
+			}
+		}
+		if step%50 == 0 {
+			conformCheck(t, ix, model, rng, step)
+		}
+	}
+	conformCheck(t, ix, model, rng, -1)
+}
+
+// conformCheck compares ix's queries with scans of model
+func conformCheck(t *testing.T, ix spatial.SpatialReader, model []record, rng *rand.Rand, step int) {
+	t.Helper()
+	if n := ix.Count(); n != len(model) {
+		t.Fatalf("step %d: Count: expected %d, got %d", step, len(model), n)
+	}
+	all := make([]int, len(model))
+	for i, r := range model {
+		all[i] = r.p.Data.(int)
+	}
+	var visited []int
+	ix.ForEach(func(p spatial.Point) bool {
+		visited = append(visited, p.Data.(int))
+		return true
+	})
+	if !slices.Equal(sorted(visited), sorted(all)) {
+		t.Fatalf("step %d: ForEach: expected %v, got %v", step, sorted(all), sorted(visited))
+	}
+
+	for q := 0; q < 20; q++ {
+		center := spatial.Point{X: float64(rng.Intn(2001)) / 2, Y: float64(rng.Intn(2001)) / 2}
+		area := spatial.Bounds{X: center.X - 100, Y: center.Y - 50, Width: float64(rng.Intn(400)), Height: float64(rng.Intn(400))}
+		var want []int
+		for _, r := range model {
+			if area.Contains(r.p) {
+				want = append(want, r.p.Data.(int))
+			}
+		}
+		if got := sorted(tags(ix.Search(area))); !slices.Equal(got, sorted(want)) {
+			t.Fatalf("step %d: Search(%v): expected %v, got %v", step, area, sorted(want), got)
+		}
+
+		radius := float64(rng.Intn(300))
+		want = want[:0]
+		for _, r := range model {
+			if spatial.Distance(center, r.p) <= radius {
+				want = append(want, r.p.Data.(int))
+			}
+		}
+		if got := sorted(tags(ix.SearchRadius(center, radius))); !slices.Equal(got, sorted(want)) {
+			t.Fatalf("step %d: SearchRadius(%v, %v): expected %v, got %v", step, center, radius, sorted(want), got)
+		}
+
+		k := 1 + rng.Intn(12)
+		byDistance := slices.Clone(model)
+		slices.SortFunc(byDistance, func(a, b record) int {
+			return cmp.Or(cmp.Compare(spatial.DistanceSquared(center, a.p), spatial.DistanceSquared(center, b.p)), cmp.Compare(a.order, b.order))
+		})
+		want = want[:0]
+		for _, r := range byDistance[:min(k, len(byDistance))] {
+			want = append(want, r.p.Data.(int))
+		}
+		if got := tags(ix.KNearest(center, k)); !slices.Equal(got, want) {
+			t.Fatalf("step %d: KNearest(%v, %d): expected %v, got %v", step, center, k, want, got)
+		}
+	}
+}
+
+// tags returns the Data of each point, in order
+func tags(points []spatial.Point) []int {
+	out := make([]int, len(points))
+	for i, p := range points {
+		out[i], _ = p.Data.(int)
+	}
+	return out
+}
+
+func sorted(s []int) []int {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
@@ -0,0 +1,190 @@
+// Package spatialtest generates point sets for testing and benchmarking
+// spatial trees. Real delivery data is nothing like a grid: it clusters
+// around depots and city cores and thins out towards the suburbs, which is
+// what unbalances a quadtree. Every generator takes a seed and returns the
+// same points for the same arguments.
+package spatialtest
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// Grid spreads n points over bounds in rows of ceil(sqrt(n)), the layout the
+// original benchmarks used. It is the balanced baseline the other
+// distributions are compared against.
+func Grid(bounds spatial.Bounds, n int) []spatial.Point {
+	side := int(math.Ceil(math.Sqrt(float64(n))))
+	points := make([]spatial.Point, n)
+	for i := range points {
+		points[i] = spatial.Point{
+			X: bounds.X + (float64(i%side)+0.5)*bounds.Width/float64(side),
+			Y: bounds.Y + (float64(i/side)+0.5)*bounds.Height/float64(side),
+		}
+	}
+	return points
+}
+
+// Uniform scatters n points uniformly over bounds
+func Uniform(seed int64, bounds spatial.Bounds, n int) []spatial.Point {
+	rng := rand.New(rand.NewSource(seed))
+	points := make([]spatial.Point, n)
+	for i := range points {
+		points[i] = uniformIn(rng, bounds)
+	}
+	return points
+}
+
+// Clusters draws n points from k Gaussian clusters with standard deviation
+// spread, in coordinate units, around centers placed uniformly in bounds.
+// Points are shared evenly between clusters. Draws that land outside bounds
+// are redrawn, so every point can be inserted.
+func Clusters(seed int64, bounds spatial.Bounds, n, k int, spread float64) []spatial.Point {
+	rng := rand.New(rand.NewSource(seed))
+	centers := make([]spatial.Point, max(k, 1))
+	for i := range centers {
+		centers[i] = uniformIn(rng, bounds)
+	}
+	points := make([]spatial.Point, n)
+	for i := range points {
+		c := centers[i%len(centers)]
+		points[i] = redraw(rng, bounds, func() spatial.Point {
+			return spatial.Point{X: c.X + rng.NormFloat64()*spread, Y: c.Y + rng.NormFloat64()*spread}
+		})
+	}
+	return points
+}
+
+// PowerLaw draws n points around the center of bounds with density falling
+// off as r^-exponent, like a city core giving way to suburbs. exponent must
+// be in [0, 2): 0 is uniform over the inscribed disc and values near 2 pack
+// almost everything into the core.
+func PowerLaw(seed int64, bounds spatial.Bounds, n int, exponent float64) []spatial.Point {
+	rng := rand.New(rand.NewSource(seed))
+	cx, cy := bounds.X+bounds.Width/2, bounds.Y+bounds.Height/2
+	radius := math.Min(bounds.Width, bounds.Height) / 2
+	points := make([]spatial.Point, n)
+	for i := range points {
+		points[i] = redraw(rng, bounds, func() spatial.Point {
+			// In 2D the share of points within r grows as r^(2-exponent)
+			r := radius * math.Pow(rng.Float64(), 1/(2-exponent))
+			theta := rng.Float64() * 2 * math.Pi
+			return spatial.Point{X: cx + r*math.Cos(theta), Y: cy + r*math.Sin(theta)}
+		})
+	}
+	return points
+}
+
+// Move is one step of a Trace: vehicle Vehicle drives to X, Y
+type Move struct {
+	Vehicle int
+	X, Y    float64
+}
+
+// Trace is a fleet of vehicles and the moves they make, in order
+type Trace struct {
+	Start []spatial.Point // Where each vehicle starts
+	Moves []Move
+}
+
+// RouteTrace starts vehicles uniformly in bounds and drives them for steps
+// moves in total, taking turns. Each move covers about stepSize in a heading
+// that drifts a little every step, so vehicles follow smooth routes and
+// mostly stay in their leaf, like GPS pings. Vehicles bounce off the edges.
+// Each start point's Data is its vehicle index, so Update can tell vehicles
+// at the same spot apart.
+func RouteTrace(seed int64, bounds spatial.Bounds, vehicles, steps int, stepSize float64) Trace {
+	rng := rand.New(rand.NewSource(seed))
+	trace := Trace{Start: make([]spatial.Point, vehicles), Moves: make([]Move, 0, steps)}
+	pos := make([]spatial.Point, vehicles)
+	heading := make([]float64, vehicles)
+	for i := range pos {
+		pos[i] = uniformIn(rng, bounds)
+		pos[i].Data = i
+		heading[i] = rng.Float64() * 2 * math.Pi
+	}
+	copy(trace.Start, pos)
+	if vehicles == 0 {
+		return trace
+	}
+
+	for s := 0; s < steps; s++ {
+		v := s % vehicles
+		heading[v] += rng.NormFloat64() * 0.3
+		dist := stepSize * (0.5 + rng.Float64())
+		x := pos[v].X + dist*math.Cos(heading[v])
+		y := pos[v].Y + dist*math.Sin(heading[v])
+		if x < bounds.X || x >= bounds.X+bounds.Width {
+			x = bounce(x, bounds.X, bounds.X+bounds.Width)
+			heading[v] = math.Pi - heading[v]
+		}
+		if y < bounds.Y || y >= bounds.Y+bounds.Height {
+			y = bounce(y, bounds.Y, bounds.Y+bounds.Height)
+			heading[v] = -heading[v]
+		}
+		pos[v].X, pos[v].Y = x, y
+		trace.Moves = append(trace.Moves, Move{Vehicle: v, X: x, Y: y})
+	}
+	return trace
+}
+
+// Fill inserts points into qt one at a time and returns how many were stored
+func Fill(qt *spatial.QuadTree, points []spatial.Point) int {
+	stored := 0
+	for _, p := range points {
+		if qt.Insert(p) {
+			stored++
+		}
+	}
+	return stored
+}
+
+// Build bulk-loads points into a new tree with BuildQuadTree
+func Build(bounds spatial.Bounds, capacity int, points []spatial.Point, opts ...spatial.Option) (*spatial.QuadTree, error) {
+	return spatial.BuildQuadTree(bounds, capacity, points, opts...)
+}
+
+// Play inserts the trace's vehicles into qt and applies moves [from, to) with
+// Update, returning the vehicles' final positions. Pass the positions back in
+// as pos to continue from where a previous call stopped; nil starts afresh.
+func (t Trace) Play(qt *spatial.QuadTree, pos []spatial.Point, from, to int) []spatial.Point {
+	if pos == nil {
+		pos = append([]spatial.Point(nil), t.Start...)
+		Fill(qt, pos)
+	}
+	for _, m := range t.Moves[from:to] {
+		next := pos[m.Vehicle]
+		next.X, next.Y = m.X, m.Y
+		if qt.Update(pos[m.Vehicle], next) {
+			pos[m.Vehicle] = next
+		}
+	}
+	return pos
+}
+
+func uniformIn(rng *rand.Rand, b spatial.Bounds) spatial.Point {
+	return spatial.Point{X: b.X + rng.Float64()*b.Width, Y: b.Y + rng.Float64()*b.Height}
+}
+
+// redraw calls draw until it returns a point inside b, falling back to a
+// uniform point if draw keeps missing
+func redraw(rng *rand.Rand, b spatial.Bounds, draw func() spatial.Point) spatial.Point {
+	for try := 0; try < 100; try++ {
+		if p := draw(); b.Contains(p) {
+			return p
+		}
+	}
+	return uniformIn(rng, b)
+}
+
+// bounce reflects v, which has just left [lo, hi), back inside
+func bounce(v, lo, hi float64) float64 {
+	if v < lo {
+		v = 2*lo - v
+	} else {
+		v = 2*hi - v
+	}
+	return math.Min(math.Max(v, lo), math.Nextafter(hi, lo))
+}
@@ -0,0 +1,132 @@
+package spatialtest
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+var testBounds = spatial.Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}
+
+// TestGeneratorsAreSeeded tests that every generator repeats itself for a
+// seed and differs for another
+func TestGeneratorsAreSeeded(t *testing.T) {
+	gens := map[string]func(seed int64) []spatial.Point{
+		"Uniform":  func(seed int64) []spatial.Point { return Uniform(seed, testBounds, 500) },
+		"Clusters": func(seed int64) []spatial.Point { return Clusters(seed, testBounds, 500, 5, 20) },
+		"PowerLaw": func(seed int64) []spatial.Point { return PowerLaw(seed, testBounds, 500, 1.5) },
+		"RouteTrace": func(seed int64) []spatial.Point {
+			tr := RouteTrace(seed, testBounds, 10, 500, 5)
+			pts := append([]spatial.Point(nil), tr.Start...)
+			for _, m := range tr.Moves {
+				pts = append(pts, spatial.Point{X: m.X, Y: m.Y})
+			}
+			return pts
+		},
+	}
+	for name, gen := range gens {
+		a, b, c := gen(1), gen(1), gen(2)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: same seed produced different points", name)
+		}
+		if reflect.DeepEqual(a, c) {
+			t.Errorf("%s: different seeds produced the same points", name)
+		}
+		for _, p := range a {
+			if !testBounds.Contains(p) {
+				t.Errorf("%s: %v lies outside %v", name, p, testBounds)
+				break
+			}
+		}
+	}
+}
+
+// share returns the fraction of points within r of center
+func share(points []spatial.Point, center spatial.Point, r float64) float64 {
+	in := 0
+	for _, p := range points {
+		if spatial.Distance(p, center) <= r {
+			in++
+		}
+	}
+	return float64(in) / float64(len(points))
+}
+
+// TestDistributionsAreSkewed tests that the clustered and power-law sets are
+// far denser in their cores than a uniform set
+func TestDistributionsAreSkewed(t *testing.T) {
+	center := spatial.Point{X: 500, Y: 500}
+	uniform := share(Uniform(1, testBounds, 5000), center, 100)
+
+	if got := share(PowerLaw(1, testBounds, 5000, 1.5), center, 100); got < 5*uniform {
+		t.Errorf("Expected the power-law core to hold far more than the uniform %.3f, got %.3f", uniform, got)
+	}
+
+	pts := Clusters(1, testBounds, 5000, 1, 20)
+	var cx, cy float64
+	for _, p := range pts {
+		cx += p.X / float64(len(pts))
+		cy += p.Y / float64(len(pts))
+	}
+	if got := share(pts, spatial.Point{X: cx, Y: cy}, 60); got < 0.95 {
+		t.Errorf("Expected a cluster with spread 20 to keep 95%% of points within 60, got %.3f", got)
+	}
+
+	if got := len(Grid(testBounds, 1000)); got != 1000 {
+		t.Errorf("Expected Grid to return 1000 points, got %d", got)
+	}
+}
+
+// TestRouteTracePlay tests that a trace moves vehicles in small steps and
+// that Play leaves every vehicle at its final position in the tree
+func TestRouteTracePlay(t *testing.T) {
+	tr := RouteTrace(3, testBounds, 20, 2000, 5)
+	last := append([]spatial.Point(nil), tr.Start...)
+	for _, m := range tr.Moves {
+		if d := math.Hypot(m.X-last[m.Vehicle].X, m.Y-last[m.Vehicle].Y); d > 7.5+1e-9 {
+			t.Fatalf("Vehicle %d jumped %v in one move", m.Vehicle, d)
+		}
+		last[m.Vehicle].X, last[m.Vehicle].Y = m.X, m.Y
+	}
+
+	qt, err := spatial.NewQuadTree(testBounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := tr.Play(qt, nil, 0, 1000)
+	pos = tr.Play(qt, pos, 1000, len(tr.Moves))
+	if qt.Size() != 20 {
+		t.Errorf("Expected 20 vehicles in the tree, got %d", qt.Size())
+	}
+	for i, p := range pos {
+		if p.X != last[i].X || p.Y != last[i].Y {
+			t.Errorf("Vehicle %d ended at %v, want %v", i, p, last[i])
+		}
+		if len(qt.Search(spatial.Bounds{X: p.X, Y: p.Y, Width: 0, Height: 0})) == 0 {
+			t.Errorf("Vehicle %d is not stored at %v", i, p)
+		}
+	}
+}
+
+// TestStressReportsSeed tests that Stress passes on a sound tree and that a
+// failure names the seed. A tree that does not start empty disagrees with
+// the model at the first check.
+func TestStressReportsSeed(t *testing.T) {
+	cfg := StressConfig{Seed: 42, Goroutines: 4, Rounds: 3, OpsPerRound: 200}
+	qt, _ := spatial.NewQuadTree(testBounds, spatial.WithCapacity(2))
+	if err := Stress(qt, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	qt, _ = spatial.NewQuadTree(testBounds)
+	qt.Insert(spatial.Point{X: 1, Y: 1})
+	err := Stress(qt, cfg)
+	var stressErr *StressError
+	if !errors.As(err, &stressErr) || stressErr.Seed != 42 || !strings.Contains(err.Error(), "seed 42") {
+		t.Errorf("Expected a StressError naming seed 42, got %v", err)
+	}
+}
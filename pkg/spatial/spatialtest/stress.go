@@ -0,0 +1,406 @@
+package spatialtest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// StressMix weighs the operations a stress run picks from. Insert, Remove
+// and Update each go either through IDs (InsertWithID or Upsert, RemoveByID,
+// Move) or through coordinates (Insert, Remove, Update).
+type StressMix struct {
+	Insert, Remove, Update int
+	Search, KNearest       int
+	Snapshot               int
+}
+
+// DefaultStressMix is write heavy with a steady stream of reads
+var DefaultStressMix = StressMix{Insert: 30, Remove: 20, Update: 30, Search: 10, KNearest: 8, Snapshot: 2}
+
+// StressConfig configures Stress. Zero fields take the defaults noted.
+type StressConfig struct {
+	Seed        int64
+	Goroutines  int // 8
+	Rounds      int // 20; the tree is checked after every round
+	OpsPerRound int // 500 per goroutine
+	IDs         int // 64 ids per goroutine
+	Mix         StressMix
+}
+
+// Stress runs randomized operations on qt from many goroutines and, each
+// time they have all stopped, compares the tree's contents and Size against
+// a reference model and runs Validate. qt must be empty and should not be
+// touched by anything else during the run.
+//
+// Each goroutine owns its ids and a set of x columns for the points it
+// places by coordinates, so the model knows exactly what its writes did even
+// though the tree sees them interleaved with everyone else's. The seed fixes
+// every goroutine's operations; the interleaving is up to the scheduler.
+// Errors carry the seed, so a failure can be rerun.
+func Stress(qt *spatial.QuadTree, cfg StressConfig) error {
+	cfg = cfg.withDefaults()
+	model := &stressModel{ids: make(map[string]spatial.Point), anon: make(map[[2]float64]int)}
+	bounds := qt.Root.Bounds
+	for round := 0; round < cfg.Rounds; round++ {
+		var wg sync.WaitGroup
+		var once sync.Once
+		var failed error
+		stop := make(chan struct{})
+		for g := 0; g < cfg.Goroutines; g++ {
+			w := &stressWorker{
+				qt:     qt,
+				cfg:    cfg,
+				model:  model,
+				g:      g,
+				rng:    rand.New(rand.NewSource(cfg.Seed + int64(round*cfg.Goroutines+g))),
+				bounds: bounds,
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := w.run(stop); err != nil {
+					once.Do(func() {
+						failed = err
+						close(stop)
+					})
+				}
+			}()
+		}
+		wg.Wait()
+		if failed == nil {
+			failed = model.check(qt)
+		}
+		if failed != nil {
+			return &StressError{Seed: cfg.Seed, Round: round, Err: failed}
+		}
+	}
+	return nil
+}
+
+// StressError reports the run that found a problem
+type StressError struct {
+	Seed  int64
+	Round int
+	Err   error
+}
+
+func (e *StressError) Error() string {
+	return fmt.Sprintf("spatialtest: stress seed %d, round %d: %v", e.Seed, e.Round, e.Err)
+}
+
+func (e *StressError) Unwrap() error { return e.Err }
+
+func (cfg StressConfig) withDefaults() StressConfig {
+	if cfg.Goroutines <= 0 {
+		cfg.Goroutines = 8
+	}
+	if cfg.Rounds <= 0 {
+		cfg.Rounds = 20
+	}
+	if cfg.OpsPerRound <= 0 {
+		cfg.OpsPerRound = 500
+	}
+	if cfg.IDs <= 0 {
+		cfg.IDs = 64
+	}
+	if cfg.Mix == (StressMix{}) {
+		cfg.Mix = DefaultStressMix
+	}
+	return cfg
+}
+
+// stressModel is what the tree should hold: points stored by id, and a count
+// of the anonymous points at each coordinate
+type stressModel struct {
+	mu   sync.Mutex
+	ids  map[string]spatial.Point
+	anon map[[2]float64]int
+}
+
+func (m *stressModel) setID(id string, p spatial.Point) {
+	m.mu.Lock()
+	m.ids[id] = p
+	m.mu.Unlock()
+}
+
+func (m *stressModel) dropID(id string) {
+	m.mu.Lock()
+	delete(m.ids, id)
+	m.mu.Unlock()
+}
+
+func (m *stressModel) addAnon(x, y float64, delta int) {
+	m.mu.Lock()
+	key := [2]float64{x, y}
+	if m.anon[key] += delta; m.anon[key] == 0 {
+		delete(m.anon, key)
+	}
+	m.mu.Unlock()
+}
+
+// check compares the quiescent tree with the model
+func (m *stressModel) check(qt *spatial.QuadTree) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if errs := qt.Validate(); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	want := len(m.ids)
+	for _, n := range m.anon {
+		want += n
+	}
+	if got := qt.Size(); got != want {
+		return fmt.Errorf("Size() = %d, model holds %d", got, want)
+	}
+
+	ids := make(map[string]bool, len(m.ids))
+	anon := make(map[[2]float64]int, len(m.anon))
+	for _, p := range qt.Search(qt.Root.Bounds) {
+		id, ok := p.Data.(string)
+		if !ok {
+			anon[[2]float64{p.X, p.Y}]++
+			continue
+		}
+		if ids[id] {
+			return fmt.Errorf("id %s stored twice", id)
+		}
+		ids[id] = true
+		if want, ok := m.ids[id]; !ok || want.X != p.X || want.Y != p.Y {
+			return fmt.Errorf("id %s stored at (%v, %v), model has %v (present %v)", id, p.X, p.Y, want, ok)
+		}
+	}
+	if len(ids) != len(m.ids) {
+		return fmt.Errorf("tree holds %d ids, model %d", len(ids), len(m.ids))
+	}
+	if len(anon) != len(m.anon) {
+		return fmt.Errorf("tree holds anonymous points at %d coordinates, model at %d", len(anon), len(m.anon))
+	}
+	for key, n := range m.anon {
+		if anon[key] != n {
+			return fmt.Errorf("tree holds %d anonymous points at %v, model %d", anon[key], key, n)
+		}
+	}
+	return nil
+}
+
+// stressColumns is how many x columns each goroutine gets for anonymous points
+const stressColumns = 1 << 12
+
+// stressWorker is one goroutine of a stress run. Only it touches its ids and
+// its anonymous points, so it can keep them without locking.
+type stressWorker struct {
+	qt     *spatial.QuadTree
+	cfg    StressConfig
+	model  *stressModel
+	g      int
+	rng    *rand.Rand
+	bounds spatial.Bounds
+	anon   []spatial.Point // Anonymous points this worker has stored
+}
+
+func (w *stressWorker) run(stop <-chan struct{}) error {
+	// Pick up the anonymous points stored in earlier rounds
+	w.model.mu.Lock()
+	for key, n := range w.model.anon {
+		if w.owns(key[0]) {
+			for i := 0; i < n; i++ {
+				w.anon = append(w.anon, spatial.Point{X: key[0], Y: key[1]})
+			}
+		}
+	}
+	w.model.mu.Unlock()
+	sort.Slice(w.anon, func(i, j int) bool {
+		return w.anon[i].X < w.anon[j].X || w.anon[i].X == w.anon[j].X && w.anon[i].Y < w.anon[j].Y
+	})
+
+	mix := w.cfg.Mix
+	total := mix.Insert + mix.Remove + mix.Update + mix.Search + mix.KNearest + mix.Snapshot
+	for i := 0; i < w.cfg.OpsPerRound; i++ {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		var err error
+		switch pick := w.rng.Intn(total); {
+		case pick < mix.Insert:
+			err = w.insert()
+		case pick < mix.Insert+mix.Remove:
+			err = w.remove()
+		case pick < mix.Insert+mix.Remove+mix.Update:
+			err = w.update()
+		case pick < mix.Insert+mix.Remove+mix.Update+mix.Search:
+			err = w.search()
+		case pick < total-mix.Snapshot:
+			err = w.kNearest()
+		default:
+			err = w.snapshot()
+		}
+		if err != nil {
+			return fmt.Errorf("goroutine %d, op %d: %w", w.g, i, err)
+		}
+	}
+	return nil
+}
+
+func (w *stressWorker) id() string {
+	return fmt.Sprintf("g%d-%d", w.g, w.rng.Intn(w.cfg.IDs))
+}
+
+// anonPoint returns a point in one of the worker's own x columns
+func (w *stressWorker) anonPoint() spatial.Point {
+	col := w.rng.Intn(stressColumns)*w.cfg.Goroutines + w.g
+	return spatial.Point{
+		X: w.bounds.X + (float64(col)+0.5)*w.bounds.Width/float64(stressColumns*w.cfg.Goroutines),
+		Y: w.bounds.Y + w.rng.Float64()*w.bounds.Height,
+	}
+}
+
+// owns reports whether x is one of the worker's columns
+func (w *stressWorker) owns(x float64) bool {
+	col := int((x - w.bounds.X) / w.bounds.Width * float64(stressColumns*w.cfg.Goroutines))
+	return col%w.cfg.Goroutines == w.g
+}
+
+func (w *stressWorker) anyPoint() spatial.Point {
+	return spatial.Point{X: w.bounds.X + w.rng.Float64()*w.bounds.Width, Y: w.bounds.Y + w.rng.Float64()*w.bounds.Height}
+}
+
+func (w *stressWorker) insert() error {
+	if w.rng.Intn(2) == 0 {
+		p := w.anonPoint()
+		if !w.qt.Insert(p) {
+			return fmt.Errorf("Insert(%v) failed", p)
+		}
+		w.anon = append(w.anon, p)
+		w.model.addAnon(p.X, p.Y, 1)
+		return nil
+	}
+	id, p := w.id(), w.anyPoint()
+	p.Data = id
+	if w.rng.Intn(2) == 0 {
+		if _, err := w.qt.Upsert(id, p); err != nil {
+			return fmt.Errorf("Upsert(%s): %w", id, err)
+		}
+	} else if err := w.qt.InsertWithID(id, p); err == spatial.ErrDuplicateID {
+		return w.checkID(id)
+	} else if err != nil {
+		return fmt.Errorf("InsertWithID(%s): %w", id, err)
+	}
+	w.model.setID(id, p)
+	return nil
+}
+
+func (w *stressWorker) remove() error {
+	if w.rng.Intn(2) == 0 {
+		if len(w.anon) == 0 {
+			return nil
+		}
+		i := w.rng.Intn(len(w.anon))
+		p := w.anon[i]
+		if !w.qt.Remove(p) {
+			return fmt.Errorf("Remove(%v) found nothing", p)
+		}
+		w.anon[i] = w.anon[len(w.anon)-1]
+		w.anon = w.anon[:len(w.anon)-1]
+		w.model.addAnon(p.X, p.Y, -1)
+		return nil
+	}
+	id := w.id()
+	removed := w.qt.RemoveByID(id)
+	w.model.mu.Lock()
+	_, present := w.model.ids[id]
+	w.model.mu.Unlock()
+	if removed != present {
+		return fmt.Errorf("RemoveByID(%s) = %v, model has it %v", id, removed, present)
+	}
+	w.model.dropID(id)
+	return nil
+}
+
+func (w *stressWorker) update() error {
+	if w.rng.Intn(2) == 0 {
+		if len(w.anon) == 0 {
+			return nil
+		}
+		i := w.rng.Intn(len(w.anon))
+		from, to := w.anon[i], w.anonPoint()
+		if !w.qt.Update(from, to) {
+			return fmt.Errorf("Update(%v, %v) failed", from, to)
+		}
+		w.anon[i] = to
+		w.model.addAnon(from.X, from.Y, -1)
+		w.model.addAnon(to.X, to.Y, 1)
+		return nil
+	}
+	id, to := w.id(), w.anyPoint()
+	w.model.mu.Lock()
+	p, present := w.model.ids[id]
+	w.model.mu.Unlock()
+	err := w.qt.Move(id, to.X, to.Y)
+	if present != (err == nil) {
+		return fmt.Errorf("Move(%s) = %v, model has it %v", id, err, present)
+	}
+	if present {
+		p.X, p.Y = to.X, to.Y
+		w.model.setID(id, p)
+	}
+	return w.checkID(id)
+}
+
+// checkID compares one of the worker's ids with the model. No other
+// goroutine writes it, so it must match even mid-round.
+func (w *stressWorker) checkID(id string) error {
+	w.model.mu.Lock()
+	want, present := w.model.ids[id]
+	w.model.mu.Unlock()
+	got, ok := w.qt.GetByID(id)
+	if ok != present || ok && (got.X != want.X || got.Y != want.Y) {
+		return fmt.Errorf("GetByID(%s) = %v, %v, model has %v, %v", id, got, ok, want, present)
+	}
+	return nil
+}
+
+func (w *stressWorker) search() error {
+	c := w.anyPoint()
+	size := w.rng.Float64() * w.bounds.Width / 4
+	area := spatial.Bounds{X: c.X - size/2, Y: c.Y - size/2, Width: size, Height: size}
+	for _, p := range w.qt.Search(area) {
+		if !area.Contains(p) {
+			return fmt.Errorf("Search(%v) returned %v outside the area", area, p)
+		}
+	}
+	return nil
+}
+
+func (w *stressWorker) kNearest() error {
+	target, k := w.anyPoint(), 1+w.rng.Intn(16)
+	got := w.qt.KNearest(target, k)
+	if len(got) > k {
+		return fmt.Errorf("KNearest(%v, %d) returned %d points", target, k, len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if spatial.Distance(got[i-1], target) > spatial.Distance(got[i], target) {
+			return fmt.Errorf("KNearest(%v, %d) returned points out of order", target, k)
+		}
+	}
+	return nil
+}
+
+// snapshot checks that a snapshot is internally consistent while writers
+// carry on
+func (w *stressWorker) snapshot() error {
+	snap := w.qt.Snapshot()
+	if got, want := len(snap.Search(snap.Root.Bounds)), snap.Size(); got != want {
+		return fmt.Errorf("snapshot holds %d points but reports Size %d", got, want)
+	}
+	if errs := snap.Validate(); len(errs) > 0 {
+		return fmt.Errorf("snapshot: %w", errors.Join(errs...))
+	}
+	return nil
+}
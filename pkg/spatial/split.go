@@ -0,0 +1,70 @@
+package spatial
+
+// SplitPolicy decides when a leaf subdivides. ShouldSplit is asked whether
+// node, a leaf, should split when it would hold count points. It is also used
+// in reverse on removal: a subdivided node whose count would not split is
+// merged back into one leaf, so node may have children and no Points of its
+// own. Whatever the policy says, nodes at the tree's MaxDepth never split, so
+// co-located points can't recurse forever.
+type SplitPolicy interface {
+	ShouldSplit(node *Node, count int) bool
+}
+
+// CapacityPolicy splits a leaf once it would hold more than node.Capacity
+// points. It is the default.
+type CapacityPolicy struct{}
+
+func (CapacityPolicy) ShouldSplit(node *Node, count int) bool {
+	return count > node.Capacity
+}
+
+// MaxDepthPolicy stops Policy (CapacityPolicy if nil) from splitting nodes
+// at or below MaxDepth.
+type MaxDepthPolicy struct {
+	MaxDepth int
+	Policy   SplitPolicy
+}
+
+func (p MaxDepthPolicy) ShouldSplit(node *Node, count int) bool {
+	return node.Depth < p.MaxDepth && orCapacity(p.Policy).ShouldSplit(node, count)
+}
+
+// MinCellSizePolicy stops Policy (CapacityPolicy if nil) from splitting a
+// node whose children would be narrower or shorter than MinSize, in the
+// tree's coordinate units.
+type MinCellSizePolicy struct {
+	MinSize float64
+	Policy  SplitPolicy
+}
+
+func (p MinCellSizePolicy) ShouldSplit(node *Node, count int) bool {
+	if node.Bounds.Width/2 < p.MinSize || node.Bounds.Height/2 < p.MinSize {
+		return false
+	}
+	return orCapacity(p.Policy).ShouldSplit(node, count)
+}
+
+func orCapacity(p SplitPolicy) SplitPolicy {
+	if p == nil {
+		return CapacityPolicy{}
+	}
+	return p
+}
+
+// WithSplitPolicy replaces the default CapacityPolicy
+func WithSplitPolicy(p SplitPolicy) Option {
+	return func(qt *QuadTree) {
+		qt.Root.policy = p
+	}
+}
+
+// splits reports whether n should subdivide (or stay subdivided) holding count points
+func (n *Node) splits(count int) bool {
+	if n.Depth >= n.maxDepth() {
+		return false
+	}
+	if n.policy == nil {
+		return count > n.Capacity
+	}
+	return n.policy.ShouldSplit(n, count)
+}
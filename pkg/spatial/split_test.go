@@ -0,0 +1,112 @@
+package spatial
+
+import (
+	"testing"
+)
+
+// TestCapacityPolicyMatchesDefault tests that the explicit default policy builds the same tree
+func TestCapacityPolicyMatchesDefault(t *testing.T) {
+	bounds := Bounds{X: 0, Y: 0, Width: 10000, Height: 10000}
+	a := mustNewQuadTree(bounds, WithCapacity(4))
+	b := mustNewQuadTree(bounds, WithCapacity(4), WithSplitPolicy(CapacityPolicy{}))
+	for _, p := range benchmarkPoints(2000) {
+		a.Insert(p)
+		b.Insert(p)
+	}
+
+	if a.Stats().String() != b.Stats().String() {
+		t.Errorf("Expected identical shapes:\n%v\n%v", a.Stats(), b.Stats())
+	}
+}
+
+// TestMinCellSizePolicy tests that cells stop splitting at the minimum size
+func TestMinCellSizePolicy(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1024, Height: 1024},
+		WithCapacity(2), WithSplitPolicy(MinCellSizePolicy{MinSize: 64}))
+
+	// A dense cluster that capacity alone would split far below 64 units
+	for i := 0; i < 200; i++ {
+		qt.Insert(Point{X: 100 + float64(i%20), Y: 100 + float64(i/20)})
+	}
+	qt.Insert(Point{X: 900, Y: 900})
+
+	var smallest float64 = 1024
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Bounds.Width < smallest {
+			smallest = n.Bounds.Width
+		}
+		if n.Children[0] != nil {
+			for _, c := range n.Children {
+				walk(c)
+			}
+		}
+	}
+	walk(qt.Root)
+	if smallest != 64 {
+		t.Errorf("Expected the smallest cell to be 64 wide, got %v", smallest)
+	}
+	if stats := qt.Stats(); stats.MaxLeafPoints != 200 {
+		t.Errorf("Expected the cluster to stay in one leaf, got %v", stats)
+	}
+
+	// Removing the cluster merges back down to a single leaf
+	for i := 0; i < 200; i++ {
+		qt.Remove(Point{X: 100 + float64(i%20), Y: 100 + float64(i/20)})
+	}
+	if qt.Root.Children[0] != nil || len(qt.Root.Points) != 1 {
+		t.Errorf("Expected a single merged leaf, got %v", qt.Stats())
+	}
+}
+
+// TestMaxDepthPolicy tests expressing the depth cap as a policy
+func TestMaxDepthPolicy(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10000, Height: 10000},
+		WithCapacity(1), WithSplitPolicy(MaxDepthPolicy{MaxDepth: 3}))
+	qt.InsertAll(benchmarkPoints(1000))
+
+	if stats := qt.Stats(); stats.MaxDepth != 3 || stats.Points != 1000 {
+		t.Errorf("Expected depth 3 holding 1000 points, got %v", stats)
+	}
+}
+
+// separablePolicy splits only when the leaf holds more than one distinct position
+type separablePolicy struct{}
+
+func (separablePolicy) ShouldSplit(node *Node, count int) bool {
+	if count <= node.Capacity {
+		return false
+	}
+	if len(node.Points) == 0 {
+		// Asked about a subdivided node on removal
+		return true
+	}
+	for _, p := range node.Points[1:] {
+		if p.X != node.Points[0].X || p.Y != node.Points[0].Y {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCustomSplitPolicy tests that co-located points never force a split under a custom policy
+func TestCustomSplitPolicy(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+		WithCapacity(1), WithSplitPolicy(separablePolicy{}))
+	for i := 0; i < 10; i++ {
+		qt.Insert(Point{X: 30, Y: 30, Data: i})
+	}
+	if qt.Root.Children[0] != nil {
+		t.Fatal("Co-located points should not split the root")
+	}
+
+	qt.Insert(Point{X: 70, Y: 70})
+	qt.Insert(Point{X: 71, Y: 71})
+	if qt.Root.Children[0] == nil || len(qt.Search(qt.Root.Bounds)) != 12 {
+		t.Errorf("Separable points should split, got %v", qt.Stats())
+	}
+	qt.Remove(Point{X: 71, Y: 71})
+	if len(qt.Search(qt.Root.Bounds)) != 11 {
+		t.Error("Remove under a custom policy failed")
+	}
+}
@@ -0,0 +1,103 @@
+package spatial
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TreeStats describes the shape of a tree at one point in time
+type TreeStats struct {
+	Nodes          int
+	Leaves         int
+	Points         int
+	MaxDepth       int         // Depth of the deepest leaf
+	DeepestLeaf    Bounds      // Bounds of the first leaf found at MaxDepth
+	MinLeafPoints  int         // Fewest points held by any leaf
+	MaxLeafPoints  int         // Most points held by any leaf
+	LeafHistogram  map[int]int // Points per leaf -> number of leaves holding that many
+	OverfullLeaves int         // Leaves past Capacity because of the depth cap or split policy
+	Memory         MemStats    // Estimated heap use, as reported by MemoryStats
+}
+
+// MeanLeafPoints is the average number of points per leaf
+func (s TreeStats) MeanLeafPoints() float64 {
+	if s.Leaves == 0 {
+		return 0
+	}
+	return float64(s.Points) / float64(s.Leaves)
+}
+
+func (s TreeStats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "nodes=%d leaves=%d points=%d max_depth=%d overfull_leaves=%d\n",
+		s.Nodes, s.Leaves, s.Points, s.MaxDepth, s.OverfullLeaves)
+	fmt.Fprintf(&b, "points per leaf: min=%d max=%d mean=%.2f\n",
+		s.MinLeafPoints, s.MaxLeafPoints, s.MeanLeafPoints())
+	fmt.Fprintf(&b, "deepest leaf: x=%g y=%g w=%g h=%g\n",
+		s.DeepestLeaf.X, s.DeepestLeaf.Y, s.DeepestLeaf.Width, s.DeepestLeaf.Height)
+	fmt.Fprintf(&b, "memory: ~%d bytes (nodes=%d points=%d ids=%d keys=%d)\n",
+		s.Memory.TotalBytes, s.Memory.NodeBytes, s.Memory.PointBytes, s.Memory.IDBytes, s.Memory.KeyBytes)
+
+	sizes := make([]int, 0, len(s.LeafHistogram))
+	for size := range s.LeafHistogram {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	for _, size := range sizes {
+		fmt.Fprintf(&b, "  %d points: %d leaves\n", size, s.LeafHistogram[size])
+	}
+	return b.String()
+}
+
+// Stats walks the tree once and reports its structure. It only takes the read
+// lock, so it can run alongside searches.
+func (qt *QuadTree) Stats() TreeStats {
+	qt.rlockAll()
+	defer qt.runlockAll()
+	if qt.Root == nil {
+		return TreeStats{LeafHistogram: make(map[int]int)}
+	}
+	stats := qt.Root.stats()
+	qt.indexMemory(&stats.Memory)
+	return stats
+}
+
+// stats computes TreeStats for the subtree. Callers must hold the lock.
+func (n *Node) stats() TreeStats {
+	stats := TreeStats{
+		LeafHistogram: make(map[int]int),
+		MaxDepth:      -1,
+	}
+	n.collectStats(&stats)
+	return stats
+}
+
+func (n *Node) collectStats(s *TreeStats) {
+	s.Nodes++
+	s.Memory.addNode(n)
+	if n.Children[0] != nil {
+		for i := 0; i < 4; i++ {
+			n.Children[i].collectStats(s)
+		}
+		return
+	}
+
+	count := len(n.Points)
+	if s.Leaves == 0 || count < s.MinLeafPoints {
+		s.MinLeafPoints = count
+	}
+	if count > s.MaxLeafPoints {
+		s.MaxLeafPoints = count
+	}
+	s.Leaves++
+	s.Points += count
+	s.LeafHistogram[count]++
+	if count > n.Capacity {
+		s.OverfullLeaves++
+	}
+	if n.Depth > s.MaxDepth {
+		s.MaxDepth = n.Depth
+		s.DeepestLeaf = n.Bounds
+	}
+}
@@ -0,0 +1,99 @@
+package spatial
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestStatsEmptyTree tests statistics for a tree with only a root
+func TestStatsEmptyTree(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+
+	s := qt.Stats()
+	if s.Nodes != 1 || s.Leaves != 1 || s.Points != 0 || s.MaxDepth != 0 {
+		t.Errorf("Unexpected stats for empty tree: %+v", s)
+	}
+	if s.LeafHistogram[0] != 1 {
+		t.Errorf("Expected one empty leaf in histogram, got %v", s.LeafHistogram)
+	}
+}
+
+// TestStatsAfterSubdivision tests counts after a single split
+func TestStatsAfterSubdivision(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+	qt.Insert(Point{X: 10, Y: 10})
+	qt.Insert(Point{X: 90, Y: 10})
+	qt.Insert(Point{X: 10, Y: 90})
+
+	s := qt.Stats()
+	if s.Nodes != 5 || s.Leaves != 4 || s.Points != 3 {
+		t.Errorf("Expected 5 nodes, 4 leaves, 3 points, got %+v", s)
+	}
+	if s.MaxDepth != 1 || s.MinLeafPoints != 0 || s.MaxLeafPoints != 1 {
+		t.Errorf("Unexpected depth or leaf sizes: %+v", s)
+	}
+	if s.LeafHistogram[1] != 3 || s.LeafHistogram[0] != 1 {
+		t.Errorf("Unexpected histogram: %v", s.LeafHistogram)
+	}
+	if s.MeanLeafPoints() != 0.75 {
+		t.Errorf("Expected mean 0.75, got %v", s.MeanLeafPoints())
+	}
+}
+
+// TestStatsOverfullLeavesAtDepthCap tests that clustered points are reported as overfull at the cap
+func TestStatsOverfullLeavesAtDepthCap(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1), WithMaxDepth(4))
+	for i := 0; i < 10; i++ {
+		qt.Insert(Point{X: 1, Y: 1, Data: i})
+	}
+
+	s := qt.Stats()
+	if s.MaxDepth != 4 {
+		t.Errorf("Expected max depth 4, got %d", s.MaxDepth)
+	}
+	if s.OverfullLeaves != 1 || s.MaxLeafPoints != 10 {
+		t.Errorf("Expected one overfull leaf holding 10 points, got %+v", s)
+	}
+	if !s.DeepestLeaf.Contains(Point{X: 1, Y: 1}) || s.DeepestLeaf.Width != 100.0/16 {
+		t.Errorf("Deepest leaf should be the clustered cell, got %+v", s.DeepestLeaf)
+	}
+	if !strings.Contains(s.String(), "overfull_leaves=1") {
+		t.Errorf("String() missing overfull count:\n%s", s.String())
+	}
+}
+
+// TestStatsConcurrentWithReads tests calling Stats alongside searches and inserts
+func TestStatsConcurrentWithReads(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(4))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(3)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				qt.Insert(Point{X: float64(g*250 + i), Y: float64(i * 9)})
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				qt.Search(Bounds{X: 0, Y: 0, Width: 500, Height: 500})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				qt.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s := qt.Stats(); s.Points != 400 {
+		t.Errorf("Expected 400 points, got %d", s.Points)
+	}
+
+	checkValid(t, qt)
+}
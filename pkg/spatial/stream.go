@@ -0,0 +1,204 @@
+package spatial
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// A point stream is a header, records as WriteTo writes them, and a trailer:
+//
+//	magic "SPQS", stream format version (uint16)
+//	records: length (uvarint), body
+//	trailer: 0 (uvarint, no record is empty), record count (uvarint),
+//	CRC-32 (IEEE) of everything before it (uint32)
+const (
+	streamMagic   = "SPQS"
+	streamVersion = 1
+	// streamBatch is how many streamed points IngestStream inserts per write
+	streamBatch = 4096
+)
+
+// StreamPoints writes the unexpired points in area, with their ids, Data and
+// expiry, to w as it walks the tree, one leaf at a time, rather than
+// collecting them first. It walks a Snapshot, so the stream is consistent
+// and writers are never held up by a slow w. Data is encoded by the tree's
+// DataCodec. The stream ends with a trailer carrying the record count and a
+// checksum, so IngestStream can tell a cut-short stream from a complete one.
+func (qt *QuadTree) StreamPoints(w io.Writer, area Bounds) error {
+	snap := qt.Snapshot()
+	codec := snap.dataCodec()
+
+	bw := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(bw, crc)
+	buf := append([]byte(streamMagic), 0, 0)
+	binary.LittleEndian.PutUint16(buf[len(streamMagic):], streamVersion)
+	if _, err := out.Write(buf); err != nil {
+		return err
+	}
+
+	var count uint64
+	var points []Point
+	var record []byte
+	var err error
+	snap.Root.walkIntersecting(area, func(n *Node) bool {
+		if n.Children[0] != nil {
+			return true
+		}
+		points = points[:0]
+		n.searchEdges(area, snap.edges, &points)
+		snap.dropExpired(&points, 0)
+		for _, p := range points {
+			var id string
+			if p.loc != nil {
+				id = p.loc.id
+			}
+			if record, err = appendRecord(record[:0], p, id, id != "", codec); err != nil {
+				err = fmt.Errorf("spatial: encoding Data of point %d: %w", count, err)
+				return false
+			}
+			buf = binary.AppendUvarint(buf[:0], uint64(len(record)))
+			if _, err = out.Write(buf); err != nil {
+				return false
+			}
+			if _, err = out.Write(record); err != nil {
+				return false
+			}
+			count++
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	buf = binary.AppendUvarint(buf[:0], 0)
+	buf = binary.AppendUvarint(buf, count)
+	if _, err := out.Write(buf); err != nil {
+		return err
+	}
+	if _, err := bw.Write(binary.LittleEndian.AppendUint32(buf[:0], crc.Sum32())); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// IngestStream inserts the points StreamPoints wrote to r, a batch at a
+// time as they arrive, and returns how many it inserted. Points keep their
+// ids, Data and expiry. A stream that is cut short, or whose trailer doesn't
+// match what arrived, returns an error wrapping ErrCorruptEncoding; the
+// batches before the damage stay inserted. Points the tree rejects, such as
+// ones outside its bounds or with an id it already holds, are skipped, and
+// the error reports how many there were and why the first was rejected.
+func (qt *QuadTree) IngestStream(r io.Reader) (int, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := &decoder{r: br, crc: crc32.NewIEEE()}
+	in := &ingest{qt: qt}
+
+	magic := d.bytes(len(streamMagic), "magic")
+	if d.err == nil && string(magic) != streamMagic {
+		return 0, corrupt("not a point stream")
+	}
+	if version := binary.LittleEndian.Uint16(d.bytes(2, "version")); d.err == nil && version > streamVersion {
+		return 0, &FormatError{Kind: "point stream", Format: version, Supported: streamVersion}
+	} else if d.err == nil && version != streamVersion {
+		return 0, corrupt("unsupported stream version %d", version)
+	}
+
+	codec := qt.dataCodec()
+	var count uint64
+	var record []byte
+	for d.err == nil {
+		n := d.uvarint("record length")
+		if d.err != nil || n == 0 {
+			break
+		}
+		if n > maxRecordBytes {
+			d.err = corrupt("record %d claims %d bytes", count, n)
+			break
+		}
+		record = d.into(record[:0], int(n), "record")
+		if d.err != nil {
+			break
+		}
+		p, id, hasID, err := parseRecord(record, codec)
+		if err != nil {
+			d.err = corrupt("record %d: %v", count, err)
+			break
+		}
+		count++
+		in.add(p, id, hasID)
+	}
+	if d.err == nil {
+		if sent := d.uvarint("record count"); d.err == nil && sent != count {
+			d.err = corrupt("trailer counts %d records, received %d", sent, count)
+		}
+	}
+	if d.err == nil {
+		want := d.crc.Sum32()
+		if got := binary.LittleEndian.Uint32(d.raw(4, "checksum")); d.err == nil && got != want {
+			d.err = corrupt("checksum mismatch")
+		}
+	}
+	in.flush()
+	if d.err != nil {
+		return in.inserted, d.err
+	}
+	if in.rejected > 0 {
+		return in.inserted, fmt.Errorf("spatial: %d streamed points rejected, the first: %w", in.rejected, in.firstErr)
+	}
+	return in.inserted, nil
+}
+
+// ingest collects streamed points into batches for IngestStream
+type ingest struct {
+	qt       *QuadTree
+	points   []Point
+	ids      []string
+	hasIDs   []bool
+	inserted int
+	rejected int
+	firstErr error
+}
+
+func (in *ingest) add(p Point, id string, hasID bool) {
+	in.points = append(in.points, p)
+	in.ids = append(in.ids, id)
+	in.hasIDs = append(in.hasIDs, hasID)
+	if len(in.points) == streamBatch {
+		in.flush()
+	}
+}
+
+// flush inserts the pending batch as one version
+func (in *ingest) flush() {
+	if len(in.points) == 0 {
+		return
+	}
+	qt := in.qt
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.readOnly {
+		qt.beginVersion()
+	}
+	qt.batch = true
+	defer func() { qt.batch = false }()
+	for i, p := range in.points {
+		if err := qt.insertRecord(p, in.ids[i], in.hasIDs[i]); err != nil {
+			if in.rejected == 0 {
+				in.firstErr = err
+			}
+			in.rejected++
+			continue
+		}
+		in.inserted++
+	}
+	clear(in.points)
+	in.points, in.ids, in.hasIDs = in.points[:0], in.ids[:0], in.hasIDs[:0]
+}
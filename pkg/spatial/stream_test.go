@@ -0,0 +1,92 @@
+package spatial
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+)
+
+// TestStreamPoints tests piping one tree into another, with ids, Data and
+// an area filter, while the source keeps taking writes
+func TestStreamPoints(t *testing.T) {
+	src := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+	for i := 0; i < 10000; i++ {
+		p := Point{X: float64(i%100) + 0.5, Y: float64(i/100) + 0.5, Data: fmt.Sprint(i)}
+		if i%3 == 0 {
+			src.InsertWithID(fmt.Sprint("d", i), p)
+		} else {
+			src.Insert(p)
+		}
+	}
+	area := Bounds{X: 0, Y: 0, Width: 50, Height: 100}
+	want := walContents(src)
+	want = slices.DeleteFunc(want, func(s string) bool {
+		var x float64
+		fmt.Sscanf(s, "%g,", &x)
+		return x >= 50
+	})
+
+	dst := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := src.StreamPoints(pw, area)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	// The writer blocks on the pipe, so once the header arrives the stream
+	// has its snapshot, and later writes aren't part of it
+	head := make([]byte, len(streamMagic)+2)
+	if _, err := io.ReadFull(pr, head); err != nil {
+		t.Fatal(err)
+	}
+	src.Insert(Point{X: 1, Y: 1, Data: "late"})
+
+	n, err := dst.IngestStream(io.MultiReader(bytes.NewReader(head), pr))
+	if err != nil || <-done != nil {
+		t.Fatalf("IngestStream: %v", err)
+	}
+	if n != len(want) || !slices.Equal(walContents(dst), want) {
+		t.Errorf("Expected %d points, got %d", len(want), n)
+	}
+	if p, ok := dst.GetByID("d3"); !ok || p.Data != "3" {
+		t.Errorf("Expected ids to carry over, got %+v", p)
+	}
+}
+
+// TestIngestStreamDamaged tests that a cut-short or altered stream is
+// reported, and that rejected points are counted
+func TestIngestStreamDamaged(t *testing.T) {
+	src := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	src.InsertWithID("a", Point{X: 1, Y: 1})
+	src.Insert(Point{X: 2, Y: 2, Data: "parcel"})
+	src.Insert(Point{X: 90, Y: 90})
+	var buf bytes.Buffer
+	if err := src.StreamPoints(&buf, src.Root.Bounds); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	for n := 0; n < len(data); n++ {
+		dst := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+		if _, err := dst.IngestStream(bytes.NewReader(data[:n])); !errors.Is(err, ErrCorruptEncoding) {
+			t.Fatalf("Truncated to %d bytes: expected ErrCorruptEncoding, got %v", n, err)
+		}
+	}
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)-6] ^= 1
+	dst := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	if _, err := dst.IngestStream(bytes.NewReader(flipped)); !errors.Is(err, ErrCorruptEncoding) {
+		t.Errorf("Expected a damaged stream to fail, got %v", err)
+	}
+
+	small := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 10, Height: 10})
+	small.InsertWithID("a", Point{X: 5, Y: 5})
+	n, err := small.IngestStream(bytes.NewReader(data))
+	if n != 1 || !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("Expected 1 point in and the duplicate id reported first, got %d and %v", n, err)
+	}
+}
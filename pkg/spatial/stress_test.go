@@ -0,0 +1,50 @@
+package spatial_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial/spatialtest"
+)
+
+var stressSeed = flag.Int64("stress.seed", 0, "seed for TestStress, random if 0")
+
+// TestStress runs the spatialtest stress harness against every locking mode
+// and storage option. Failures print the seed; rerun with -stress.seed to
+// replay the same operations.
+func TestStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs concurrent writers for several seconds")
+	}
+	seed := *stressSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("stress seed %d", seed)
+
+	configs := map[string][]spatial.Option{
+		"Default":       nil,
+		"SmallLeaves":   {spatial.WithCapacity(1), spatial.WithMaxDepth(6)},
+		"Adaptive":      {spatial.WithAdaptiveCapacity(1, 16)},
+		"Arena":         {spatial.WithArena(64)},
+		"QueryCache":    {spatial.WithQueryCache(8)},
+		"Filter":        {spatial.WithExistenceFilter(128, 0.05)},
+		"LockFreeReads": {spatial.WithLockFreeReads()},
+		"Versions":      {spatial.WithVersionHistory(4)},
+	}
+	for name, opts := range configs {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			qt, err := spatial.NewQuadTree(spatial.Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, append([]spatial.Option{spatial.WithCapacity(4)}, opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer qt.Close()
+			if err := spatialtest.Stress(qt, spatialtest.StressConfig{Seed: seed, Rounds: 10, OpsPerRound: 300}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
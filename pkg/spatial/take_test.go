@@ -0,0 +1,117 @@
+package spatial
+
+import (
+	"testing"
+)
+
+// TestTakeFromLeaf tests taking a point from an unsplit root
+func TestTakeFromLeaf(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.Insert(Point{X: 50, Y: 50, Data: "test"})
+
+	p, ok := qt.Take(Point{X: 50, Y: 50})
+	if !ok || p.X != 50 || p.Y != 50 || p.Data != "test" {
+		t.Fatalf("Take returned %v, %v", p, ok)
+	}
+	if qt.Size() != 0 || len(qt.Root.Points) != 0 {
+		t.Error("Taken point should be removed")
+	}
+}
+
+// TestTakeAfterSubdivision tests taking points from every quadrant of a split tree
+func TestTakeAfterSubdivision(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+	points := []Point{
+		{X: 10, Y: 10, Data: "NW"},
+		{X: 80, Y: 10, Data: "NE"},
+		{X: 10, Y: 80, Data: "SW"},
+		{X: 80, Y: 80, Data: "SE"},
+	}
+	for _, p := range points {
+		qt.Insert(p)
+	}
+	if qt.Root.Children[0] == nil {
+		t.Fatal("Tree should be subdivided")
+	}
+
+	for _, want := range points {
+		got, ok := qt.Take(Point{X: want.X, Y: want.Y})
+		if !ok || got.Data != want.Data {
+			t.Errorf("Take(%v) returned %v, %v", want.Data, got, ok)
+		}
+	}
+	if qt.Size() != 0 || qt.Root.Children[0] != nil {
+		t.Error("Tree should be empty and merged after taking every point")
+	}
+}
+
+// TestTakeOnSplitLine tests points on the split lines and the root's outer edge
+func TestTakeOnSplitLine(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+	for _, p := range []Point{{X: 50, Y: 50, Data: "centre"}, {X: 50, Y: 0, Data: "top"}, {X: 100, Y: 100, Data: "corner"}} {
+		qt.Insert(p)
+	}
+
+	for _, want := range []Point{{X: 50, Y: 0, Data: "top"}, {X: 100, Y: 100, Data: "corner"}, {X: 50, Y: 50, Data: "centre"}} {
+		if got, ok := qt.Take(Point{X: want.X, Y: want.Y}); !ok || got.Data != want.Data {
+			t.Errorf("Take(%v) returned %v, %v", want.Data, got, ok)
+		}
+	}
+}
+
+// TestTakeNotFound tests the misses that Remove also reports
+func TestTakeNotFound(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.Insert(Point{X: 50, Y: 50})
+
+	for _, p := range []Point{{X: 40, Y: 40}, {X: 150, Y: 150}} {
+		if got, ok := qt.Take(p); ok || got != (Point{}) {
+			t.Errorf("Take(%v) should miss, got %v, %v", p, got, ok)
+		}
+	}
+	if qt.Size() != 1 {
+		t.Error("Misses should leave the tree unchanged")
+	}
+}
+
+// TestTakeColocated tests that Take follows Remove's duplicate rules
+func TestTakeColocated(t *testing.T) {
+	qt := newColocatedTree()
+
+	// By coordinates the earliest inserted record goes first
+	if p, ok := qt.Take(Point{X: 50, Y: 50}); !ok || p.Data != 0 {
+		t.Errorf("Expected the first inserted point, got %v, %v", p, ok)
+	}
+
+	// A queried point takes exactly that record
+	var target Point
+	for _, r := range qt.Search(qt.Root.Bounds) {
+		if r.Data == 7 {
+			target = r
+		}
+	}
+	if p, ok := qt.Take(target); !ok || p.Data != 7 {
+		t.Errorf("Expected point 7, got %v, %v", p, ok)
+	}
+	if _, ok := qt.Take(target); ok {
+		t.Error("Taking the same record twice should fail")
+	}
+}
+
+// TestTakeByID tests removing and returning an ID-keyed point
+func TestTakeByID(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1))
+	qt.InsertWithID("d1", Point{X: 10, Y: 10, Data: "van"})
+	qt.InsertWithID("d2", Point{X: 90, Y: 90})
+
+	p, ok := qt.TakeByID("d1")
+	if !ok || p.X != 10 || p.Y != 10 || p.Data != "van" {
+		t.Fatalf("TakeByID returned %v, %v", p, ok)
+	}
+	if _, ok := qt.TakeByID("d1"); ok {
+		t.Error("TakeByID should miss an already taken id")
+	}
+	if _, ok := qt.GetByID("d1"); ok || qt.Size() != 1 {
+		t.Error("Taken id should be gone from the index")
+	}
+}
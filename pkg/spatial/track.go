@@ -0,0 +1,357 @@
+package spatial
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultTrackHistory is how many observations a Track keeps per ID when
+// TrackConfig.History is 0
+const DefaultTrackHistory = 8
+
+// DefaultMaxExtrapolation is how far past an ID's latest observation a
+// Track predicts its position when TrackConfig.MaxExtrapolation is 0
+const DefaultMaxExtrapolation = 10 * time.Second
+
+// TrackConfig configures NewTrack
+type TrackConfig struct {
+	History int  // Observations kept per ID, DefaultTrackHistory if 0
+	Geo     bool // X and Y are longitude and latitude, measured by haversine in meters
+	// Window is the span of time Bearing and Speed look back over from an
+	// ID's latest observation. It should cover at least two updates. Zero
+	// uses every observation kept.
+	Window time.Duration
+	// NoiseFloor is the displacement over the window, in meters under Geo
+	// and X/Y units otherwise, below which an ID counts as stationary: its
+	// speed is 0 and its bearing unknown
+	NoiseFloor float64
+	// TTL is how long after its latest observation an ID is forgotten,
+	// judged by the times passed to Observe. Zero keeps IDs until Forget.
+	TTL time.Duration
+	// MaxExtrapolation is how far past an ID's latest observation
+	// PredictedPosition dead-reckons before calling the track stale,
+	// DefaultMaxExtrapolation if 0
+	MaxExtrapolation time.Duration
+}
+
+// Track derives which way and how fast IDs are moving from their recent
+// positions. It is safe for concurrent use.
+type Track struct {
+	cfg TrackConfig
+
+	mu        sync.Mutex // Guards ids, latest and lastSweep
+	ids       map[string][]observation
+	latest    time.Time // The latest time observed for any ID
+	lastSweep time.Time
+}
+
+type observation struct {
+	p Point
+	t time.Time
+}
+
+// NewTrack returns an empty Track
+func NewTrack(cfg TrackConfig) *Track {
+	if cfg.History <= 0 {
+		cfg.History = DefaultTrackHistory
+	}
+	if cfg.MaxExtrapolation <= 0 {
+		cfg.MaxExtrapolation = DefaultMaxExtrapolation
+	}
+	return &Track{cfg: cfg, ids: make(map[string][]observation)}
+}
+
+// Observe records that id was at p at t. An observation older than the
+// latest for id is ignored, and one at the same time replaces it.
+func (tr *Track) Observe(id string, p Point, t time.Time) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if t.After(tr.latest) {
+		tr.latest = t
+	}
+	tr.sweep()
+
+	obs := observation{p: Point{X: p.X, Y: p.Y}, t: t}
+	history := tr.ids[id]
+	switch n := len(history); {
+	case n > 0 && t.Before(history[n-1].t):
+		return
+	case n > 0 && t.Equal(history[n-1].t):
+		history[n-1] = obs
+	case n == tr.cfg.History:
+		copy(history, history[1:])
+		history[n-1] = obs
+	default:
+		if history == nil {
+			history = make([]observation, 0, tr.cfg.History)
+		}
+		history = append(history, obs)
+	}
+	tr.ids[id] = history
+}
+
+// Bearing returns the direction id moved over the window, in degrees
+// clockwise from north in [0, 360): the initial great-circle bearing under
+// Geo, or from the +Y axis towards +X otherwise. It reports false if id is
+// unknown, stationary or has fewer than two observations in the window.
+func (tr *Track) Bearing(id string) (float64, bool) {
+	from, to, ok := tr.span(id)
+	if !ok {
+		return 0, false
+	}
+	if d := tr.distance(from.p, to.p); d == 0 || d < tr.cfg.NoiseFloor {
+		return 0, false
+	}
+	if tr.cfg.Geo {
+		return InitialBearing(FromPoint(from.p, nil), FromPoint(to.p, nil)), true
+	}
+	return compass(math.Atan2(to.p.X-from.p.X, to.p.Y-from.p.Y)), true
+}
+
+// Speed returns how fast id moved over the window, in meters per second
+// under Geo and X/Y units per second otherwise: its displacement over the
+// window divided by the time taken, so jitter back and forth doesn't add
+// up. A stationary ID has speed 0. It reports false if id is unknown or
+// has fewer than two observations in the window.
+func (tr *Track) Speed(id string) (float64, bool) {
+	from, to, ok := tr.span(id)
+	if !ok {
+		return 0, false
+	}
+	d := tr.distance(from.p, to.p)
+	if d < tr.cfg.NoiseFloor {
+		return 0, true
+	}
+	return d / to.t.Sub(from.t).Seconds(), true
+}
+
+// PredictedPosition dead-reckons where id is at at, carrying its latest
+// position on at its Speed and Bearing. It reports true for a fresh
+// prediction: at is no more than MaxExtrapolation past the latest
+// observation. Past that it returns the latest position, unmoved, and
+// false; for an unknown ID, the zero Point and false. An ID with no speed
+// or bearing yet, or stationary, is predicted where it was last seen. A
+// time before the latest observation is predicted there too.
+func (tr *Track) PredictedPosition(id string, at time.Time) (Point, bool) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	p, _, fresh := tr.predict(tr.ids[id], at)
+	return p, fresh
+}
+
+// predict dead-reckons history to at, also returning how far it moved the
+// latest position. Callers must hold mu.
+func (tr *Track) predict(history []observation, at time.Time) (p Point, moved float64, fresh bool) {
+	if len(history) == 0 {
+		return Point{}, 0, false
+	}
+	last := history[len(history)-1]
+	dt := at.Sub(last.t)
+	if dt > tr.cfg.MaxExtrapolation {
+		return last.p, 0, false
+	}
+	from, to, ok := tr.window(history)
+	if !ok || dt <= 0 {
+		return last.p, 0, true
+	}
+	d := tr.distance(from.p, to.p)
+	if d == 0 || d < tr.cfg.NoiseFloor {
+		return last.p, 0, true
+	}
+	moved = d / to.t.Sub(from.t).Seconds() * dt.Seconds()
+	if tr.cfg.Geo {
+		g := Destination(FromPoint(last.p, nil), InitialBearing(FromPoint(from.p, nil), FromPoint(to.p, nil)), moved)
+		return g.ToPoint(nil), moved, true
+	}
+	scale := moved / d
+	return Point{X: last.p.X + (to.p.X-from.p.X)*scale, Y: last.p.Y + (to.p.Y-from.p.Y)*scale}, moved, true
+}
+
+// maxMoved returns the furthest predict moves any ID at at. Callers must
+// hold mu.
+func (tr *Track) maxMoved(at time.Time) float64 {
+	var most float64
+	for _, history := range tr.ids {
+		_, moved, _ := tr.predict(history, at)
+		most = math.Max(most, moved)
+	}
+	return most
+}
+
+// span returns the oldest and latest observations of id in the window
+func (tr *Track) span(id string) (from, to observation, ok bool) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.window(tr.ids[id])
+}
+
+// window returns the oldest and latest observations of history in the
+// window. Callers must hold mu.
+func (tr *Track) window(history []observation) (from, to observation, ok bool) {
+	if len(history) < 2 {
+		return observation{}, observation{}, false
+	}
+	to = history[len(history)-1]
+	from = history[0]
+	if tr.cfg.Window > 0 {
+		start := to.t.Add(-tr.cfg.Window)
+		for _, o := range history {
+			if !o.t.Before(start) {
+				from = o
+				break
+			}
+		}
+	}
+	return from, to, to.t.After(from.t)
+}
+
+func (tr *Track) distance(a, b Point) float64 {
+	if tr.cfg.Geo {
+		return haversine(a, b)
+	}
+	return Distance(a, b)
+}
+
+// Forget drops id's observations, reporting whether there were any
+func (tr *Track) Forget(id string) bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	_, ok := tr.ids[id]
+	delete(tr.ids, id)
+	return ok
+}
+
+// Len returns the number of IDs tracked
+func (tr *Track) Len() int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return len(tr.ids)
+}
+
+// sweep forgets IDs not observed within the TTL of the latest time seen,
+// at most once per TTL. Callers must hold mu.
+func (tr *Track) sweep() {
+	if tr.cfg.TTL <= 0 || tr.latest.Sub(tr.lastSweep) < tr.cfg.TTL {
+		return
+	}
+	tr.lastSweep = tr.latest
+	cutoff := tr.latest.Add(-tr.cfg.TTL)
+	for id, history := range tr.ids {
+		if history[len(history)-1].t.Before(cutoff) {
+			delete(tr.ids, id)
+		}
+	}
+}
+
+// InitialBearing returns the direction to set off from from along the great
+// circle to to, in degrees clockwise from north in [0, 360)
+func InitialBearing(from, to GeoPoint) float64 {
+	lat1, lat2 := from.Lat*math.Pi/180, to.Lat*math.Pi/180
+	dLon := (to.Lon - from.Lon) * math.Pi / 180
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return compass(math.Atan2(y, x))
+}
+
+// Destination returns where travelling meters from from along the great
+// circle setting off at bearing, in degrees clockwise from north, arrives
+func Destination(from GeoPoint, bearing, meters float64) GeoPoint {
+	lat1, lon1 := from.Lat*math.Pi/180, from.Lon*math.Pi/180
+	theta, delta := bearing*math.Pi/180, meters/EarthRadiusMeters
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) + math.Cos(lat1)*math.Sin(delta)*math.Cos(theta))
+	lon2 := lon1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(lat1), math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+	return GeoPoint{Lat: lat2 * 180 / math.Pi, Lon: math.Remainder(lon2*180/math.Pi, 360)}
+}
+
+// compass turns an angle in radians clockwise from north into degrees in
+// [0, 360)
+func compass(rad float64) float64 {
+	deg := math.Mod(rad*180/math.Pi+360, 360)
+	if deg >= 360 { // -tiny + 360 rounds up to 360
+		deg = 0
+	}
+	return deg
+}
+
+// WithTrack has Upsert record every point it stores in tr, timed by the
+// tree's clock, for KNearestPredicted to dead-reckon from. tr must measure
+// in the tree's units: Geo for a tree built WithGeoCoordinates.
+func WithTrack(tr *Track) Option {
+	return func(qt *QuadTree) {
+		qt.track = tr
+	}
+}
+
+// KNearestPredicted returns up to k points ranked by how close their
+// predicted positions at at are to target, nearest first with ties in
+// insertion order. Points whose ID has a fresh track in the tree's
+// WithTrack are placed by PredictedPosition; the rest, and every point in a
+// tree without a track, where they are stored. The points are returned as
+// stored. The tree's metric must obey the triangle inequality, as
+// Euclidean and Haversine do. Besides the search, it makes a pass over the
+// track's IDs to bound how far any of them has moved.
+func (qt *QuadTree) KNearestPredicted(target Point, k int, at time.Time) []Point {
+	if qt.track == nil {
+		return qt.KNearest(target, k)
+	}
+	if k <= 0 {
+		return make([]Point, 0)
+	}
+	if view := qt.view(); view != nil {
+		return view.kNearestPredicted(target, k, at)
+	}
+	qt.rlockAll()
+	defer qt.runlockAll()
+	return qt.kNearestPredicted(target, k, at)
+}
+
+// kNearestPredicted is KNearestPredicted for callers that hold the lock
+func (qt *QuadTree) kNearestPredicted(target Point, k int, at time.Time) []Point {
+	var stored []Point
+	if qt.geo && qt.metric == nil {
+		stored = qt.kNearestGeo(target, k)
+	} else {
+		stored = qt.kNearestAppend(target, k, make([]Point, 0))
+	}
+	if len(stored) == 0 {
+		return stored
+	}
+
+	tr := qt.track
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	m := qt.distanceMetric()
+	predicted := func(p Point) float64 {
+		if id, ok := p.ID(); ok {
+			if q, _, fresh := tr.predict(tr.ids[id], at); fresh {
+				return m.Distance(target, q)
+			}
+		}
+		return m.Distance(target, p)
+	}
+	// No point moves more than maxMoved, so anything predicted nearer than
+	// the kth of the stored nearest is stored within that plus maxMoved
+	candidates := stored
+	if len(stored) == k {
+		var reach float64
+		for _, p := range stored {
+			reach = math.Max(reach, predicted(p))
+		}
+		candidates = qt.searchRadius(target, reach+tr.maxMoved(at))
+	}
+	ranked := make([]PointWithDistance, len(candidates))
+	for i, p := range candidates {
+		ranked[i] = PointWithDistance{Point: p, Distance: predicted(p)}
+	}
+	slices.SortFunc(ranked, func(a, b PointWithDistance) int {
+		return cmp.Or(cmp.Compare(a.Distance, b.Distance), cmp.Compare(a.Point.seq, b.Point.seq))
+	})
+	results := make([]Point, min(k, len(ranked)))
+	for i := range results {
+		results[i] = ranked[i].Point
+	}
+	return results
+}
@@ -0,0 +1,248 @@
+package spatial
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestTrackPlanar tests bearing and speed along straight planar legs, the
+// window and out-of-order observations
+func TestTrackPlanar(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	tr := NewTrack(TrackConfig{Window: 20 * time.Second})
+	if _, ok := tr.Speed("d1"); ok {
+		t.Error("Expected no speed for an unknown ID")
+	}
+	tr.Observe("d1", Point{X: 0, Y: 0}, start)
+	if _, ok := tr.Bearing("d1"); ok {
+		t.Error("Expected no bearing from one observation")
+	}
+	// Heading east at 2 units a second, then north at 5
+	for i := 1; i <= 5; i++ {
+		tr.Observe("d1", Point{X: float64(20 * i), Y: 0}, start.Add(time.Duration(10*i)*time.Second))
+	}
+	if b, ok := tr.Bearing("d1"); !ok || math.Abs(b-90) > 1e-9 {
+		t.Errorf("Expected bearing 90, got %v, %v", b, ok)
+	}
+	if s, ok := tr.Speed("d1"); !ok || math.Abs(s-2) > 1e-9 {
+		t.Errorf("Expected speed 2, got %v, %v", s, ok)
+	}
+	for i := 1; i <= 3; i++ {
+		tr.Observe("d1", Point{X: 100, Y: float64(50 * i)}, start.Add(time.Duration(50+10*i)*time.Second))
+	}
+	// The window now holds only the northbound leg
+	if b, ok := tr.Bearing("d1"); !ok || math.Abs(b) > 1e-9 {
+		t.Errorf("Expected bearing 0, got %v, %v", b, ok)
+	}
+	if s, ok := tr.Speed("d1"); !ok || math.Abs(s-5) > 1e-9 {
+		t.Errorf("Expected speed 5, got %v, %v", s, ok)
+	}
+	// A late observation from the past is ignored
+	tr.Observe("d1", Point{X: -500, Y: -500}, start.Add(time.Second))
+	if b, _ := tr.Bearing("d1"); math.Abs(b) > 1e-9 {
+		t.Errorf("Expected the stale observation ignored, got bearing %v", b)
+	}
+	for _, tc := range []struct {
+		dx, dy, want float64
+	}{{1, 1, 45}, {1, -1, 135}, {-1, -1, 225}, {-1, 1, 315}, {-1e-300, 1, 0}} {
+		if got := compass(math.Atan2(tc.dx, tc.dy)); math.Abs(got-tc.want) > 1e-9 || got >= 360 {
+			t.Errorf("Bearing towards %v, %v: expected %v, got %v", tc.dx, tc.dy, tc.want, got)
+		}
+	}
+}
+
+// TestTrackGeoStationary tests that a parked driver's GPS jitter gives speed
+// 0 and no bearing, and that driving gives the great-circle bearing and
+// speed in meters per second
+func TestTrackGeoStationary(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	tr := NewTrack(TrackConfig{Geo: true, Window: time.Minute, NoiseFloor: 15})
+	rng := rand.New(rand.NewSource(1))
+	parked := GeoPoint{Lat: 52.52, Lon: 13.405}
+	for i := 0; i < 8; i++ {
+		// About 5m of jitter in any direction
+		p := Point{X: parked.Lon + rng.NormFloat64()*0.00007, Y: parked.Lat + rng.NormFloat64()*0.00005}
+		tr.Observe("parked", p, start.Add(time.Duration(5*i)*time.Second))
+	}
+	if s, ok := tr.Speed("parked"); !ok || s != 0 {
+		t.Errorf("Expected a parked driver to have speed 0, got %v, %v", s, ok)
+	}
+	if b, ok := tr.Bearing("parked"); ok {
+		t.Errorf("Expected no bearing for a parked driver, got %v", b)
+	}
+
+	// Due east along the parallel at about 10 m/s
+	metersPerDegree := HaversineDistance(parked, GeoPoint{Lat: parked.Lat, Lon: parked.Lon + 1})
+	for i := 0; i < 6; i++ {
+		p := Point{X: parked.Lon + float64(i)*100/metersPerDegree, Y: parked.Lat}
+		tr.Observe("moving", p, start.Add(time.Duration(10*i)*time.Second))
+	}
+	if s, ok := tr.Speed("moving"); !ok || math.Abs(s-10) > 0.01 {
+		t.Errorf("Expected about 10 m/s, got %v, %v", s, ok)
+	}
+	if b, ok := tr.Bearing("moving"); !ok || math.Abs(b-90) > 0.01 {
+		t.Errorf("Expected bearing about 90, got %v, %v", b, ok)
+	}
+	if b := InitialBearing(GeoPoint{Lat: 51.5074, Lon: -0.1278}, GeoPoint{Lat: 40.7128, Lon: -74.006}); math.Abs(b-288.3) > 0.1 {
+		t.Errorf("Expected London to New York to set off at about 288.3, got %v", b)
+	}
+}
+
+// TestTrackEviction tests that history is capped per ID and idle IDs are
+// forgotten
+func TestTrackEviction(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	tr := NewTrack(TrackConfig{History: 3, TTL: time.Minute})
+	for i := 0; i < 10; i++ {
+		tr.Observe("d1", Point{X: float64(i * i), Y: 0}, start.Add(time.Duration(i)*time.Second))
+	}
+	// Only the last three, x = 49, 64 and 81, are kept
+	if s, _ := tr.Speed("d1"); s != 16 {
+		t.Errorf("Expected speed over the last three observations, 16, got %v", s)
+	}
+	for i := 0; i < 50; i++ {
+		tr.Observe(string(rune('a'+i)), Point{}, start)
+	}
+	tr.Observe("d1", Point{X: 100}, start.Add(61*time.Second))
+	tr.Observe("d2", Point{}, start.Add(90*time.Second))
+	if tr.Len() != 2 {
+		t.Errorf("Expected the idle IDs evicted, leaving 2, got %d", tr.Len())
+	}
+	if !tr.Forget("d1") || tr.Forget("d1") || tr.Len() != 1 {
+		t.Error("Expected Forget to drop d1 once")
+	}
+}
+
+// TestPredictedPosition tests dead reckoning against positions withheld
+// from a constant-velocity track reported every 5 seconds
+func TestPredictedPosition(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	for _, tc := range []struct {
+		name  string
+		cfg   TrackConfig
+		truth func(s float64) Point
+		tol   float64 // Largest acceptable error, in meters or units
+		dist  func(a, b Point) float64
+	}{
+		{"planar", TrackConfig{}, func(s float64) Point { return Point{X: 3 * s, Y: 100 - 4*s} }, 1e-9, Distance},
+		// 15 m/s north-east from Oslo, along a great circle
+		{"geo", TrackConfig{Geo: true}, func(s float64) Point {
+			return Destination(GeoPoint{Lat: 59.91, Lon: 10.75}, 45, 15*s).ToPoint(nil)
+		}, 0.01, haversine},
+	} {
+		tr := NewTrack(tc.cfg)
+		if _, ok := tr.PredictedPosition("d1", start); ok {
+			t.Errorf("%s: expected no prediction for an unknown ID", tc.name)
+		}
+		tr.Observe("d1", tc.truth(0), start)
+		if p, ok := tr.PredictedPosition("d1", at(2000)); !ok || p != tc.truth(0) {
+			t.Errorf("%s: expected a single observation predicted where it was, got %v, %v", tc.name, p, ok)
+		}
+		var worst float64
+		for report := 5; report <= 60; report += 5 {
+			tr.Observe("d1", tc.truth(float64(report)), at(report*1000))
+			for ms := 250; ms < 5000; ms += 250 {
+				s := float64(report) + float64(ms)/1000
+				p, ok := tr.PredictedPosition("d1", at(report*1000+ms))
+				if !ok {
+					t.Fatalf("%s: expected a fresh prediction %vs after a report", tc.name, float64(ms)/1000)
+				}
+				worst = math.Max(worst, tc.dist(p, tc.truth(s)))
+			}
+		}
+		t.Logf("%s: worst prediction error %.3g", tc.name, worst)
+		if worst > tc.tol {
+			t.Errorf("%s: expected predictions within %v of the withheld positions, worst %v", tc.name, tc.tol, worst)
+		}
+		// Past the horizon the last report comes back, marked stale
+		if p, ok := tr.PredictedPosition("d1", at(60000+11000)); ok || p != tc.truth(60) {
+			t.Errorf("%s: expected the last report, stale, got %v, %v", tc.name, p, ok)
+		}
+	}
+}
+
+// TestKNearestPredicted tests that ranking by predicted position picks the
+// driver heading towards the target, and matches a brute-force ranking
+func TestKNearestPredicted(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	tr := NewTrack(TrackConfig{})
+	qt, err := NewQuadTree(Bounds{X: -1000, Y: -1000, Width: 2000, Height: 2000},
+		WithTrack(tr), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	velocity := make(map[string]Point)
+	for i := 0; i < 300; i++ {
+		id := fmt.Sprint("d", i)
+		// Kept well clear of the target, which three drivers are placed near
+		velocity[id] = Point{X: rng.NormFloat64() * 3, Y: rng.NormFloat64() * 3}
+		side := float64(1 - 2*rng.Intn(2))
+		qt.Upsert(id, Point{X: side * (300 + rng.Float64()*500), Y: rng.Float64()*1600 - 800})
+	}
+	qt.Upsert("away", Point{X: 50, Y: 0})
+	qt.Upsert("towards", Point{X: 0, Y: 90})
+	qt.InsertWithID("untracked", Point{X: 0, Y: -75}) // No track, so ranked where it is
+	for step := 0; step < 3; step++ {
+		now = now.Add(5 * time.Second)
+		for id, v := range velocity {
+			p, _ := qt.GetByID(id)
+			qt.Upsert(id, Point{X: p.X + 5*v.X, Y: p.Y + 5*v.Y})
+		}
+		p, _ := qt.GetByID("away")
+		qt.Upsert("away", Point{X: p.X + 100, Y: 0})
+		p, _ = qt.GetByID("towards")
+		qt.Upsert("towards", Point{X: 0, Y: p.Y - 25})
+	}
+	// Stored, towards is 15 out, away 350 and untracked 75. Four seconds
+	// on, towards is predicted 5 past the target and away 430 out.
+	target := Point{}
+	later := now.Add(4 * time.Second)
+	ids := func(points []Point) []string {
+		var out []string
+		for _, p := range points {
+			id, _ := p.ID()
+			out = append(out, id)
+		}
+		return out
+	}
+	got := ids(qt.KNearestPredicted(target, 2, later))
+	if len(got) != 2 || got[0] != "towards" || got[1] != "untracked" {
+		t.Errorf("Expected towards, predicted 5 past the target, then untracked, got %v", got)
+	}
+
+	// A brute-force ranking of every point by predicted distance
+	var all []PointWithDistance
+	for _, p := range qt.Search(qt.Root.Bounds) {
+		all = append(all, PointWithDistance{Point: p, Distance: Distance(target, mustPredict(tr, p, later))})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Distance < all[j].Distance })
+	for _, k := range []int{1, 5, 20, 400} {
+		got := qt.KNearestPredicted(target, k, later)
+		want := all[:min(k, len(all))]
+		if len(got) != len(want) {
+			t.Fatalf("k=%d: expected %d points, got %d", k, len(want), len(got))
+		}
+		for i := range got {
+			if d := Distance(target, mustPredict(tr, got[i], later)); math.Abs(d-want[i].Distance) > 1e-9 {
+				t.Errorf("k=%d: rank %d expected distance %v, got %v", k, i, want[i].Distance, d)
+			}
+		}
+	}
+	if got := qt.KNearestPredicted(target, 2, later.Add(time.Minute)); fmt.Sprint(ids(got)) != fmt.Sprint(ids(qt.KNearest(target, 2))) {
+		t.Errorf("Expected stale tracks ranked where stored, got %v", ids(got))
+	}
+}
+
+func mustPredict(tr *Track, p Point, at time.Time) Point {
+	id, _ := p.ID()
+	if q, fresh := tr.PredictedPosition(id, at); fresh {
+		return q
+	}
+	return p
+}
@@ -0,0 +1,126 @@
+package spatial
+
+import "time"
+
+// WithClock replaces time.Now as the clock used to decide when points expire
+func WithClock(now func() time.Time) Option {
+	return func(qt *QuadTree) {
+		qt.now = now
+	}
+}
+
+// WithExpirySweep starts a goroutine that removes expired points every
+// interval until Close is called.
+func WithExpirySweep(interval time.Duration) Option {
+	return func(qt *QuadTree) {
+		qt.sweepEvery = interval
+	}
+}
+
+func (qt *QuadTree) clock() time.Time {
+	if qt.now == nil {
+		return time.Now()
+	}
+	return qt.now()
+}
+
+// expired reports whether p has passed its expiry at now
+func expired(p Point, now int64) bool {
+	return p.expires != 0 && now >= p.expires
+}
+
+// InsertWithTTL inserts a point that stops showing up in queries once ttl has
+// elapsed. Expired points still count towards Size until RemoveExpired or the
+// background sweep removes them. Moving the point with Update keeps its expiry.
+func (qt *QuadTree) InsertWithTTL(point Point, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if qt.admit(point) != nil {
+		return false
+	}
+	qt.nextSeq++
+	point.seq = qt.nextSeq
+	point.loc = nil
+	point.expires = qt.clock().Add(ttl).UnixNano()
+	qt.Root.insert(point)
+	qt.size++
+	qt.expiring = true
+	qt.inserted(point)
+	return true
+}
+
+// searchLive collects the points in area that have not expired. Callers must hold the lock.
+func (qt *QuadTree) searchLive(area Bounds, edges Edges, results *[]Point) {
+	start := len(*results)
+	qt.Root.searchEdges(area, edges, results)
+	qt.dropExpired(results, start)
+}
+
+// dropExpired filters expired points out of (*results)[start:]
+func (qt *QuadTree) dropExpired(results *[]Point, start int) {
+	if !qt.expiring {
+		return
+	}
+	now := qt.clock().UnixNano()
+	live := (*results)[:start]
+	for _, p := range (*results)[start:] {
+		if !expired(p, now) {
+			live = append(live, p)
+		}
+	}
+	*results = live
+}
+
+// RemoveExpired removes every expired point and returns how many were removed
+func (qt *QuadTree) RemoveExpired() int {
+	qt.Lock.Lock()
+	defer qt.unlock()
+	if !qt.expiring || !qt.own() {
+		return 0
+	}
+
+	// Collect first, since removals can collapse the nodes being walked
+	now := qt.clock().UnixNano()
+	var dead []Point
+	qt.Root.collectExpired(now, &dead)
+	for _, p := range dead {
+		if removed, ok := qt.Root.remove(p, nil); ok {
+			qt.size--
+			qt.removed(removed)
+		}
+	}
+	return len(dead)
+}
+
+func (n *Node) collectExpired(now int64, dst *[]Point) {
+	if n.Children[0] != nil {
+		for _, child := range n.Children {
+			child.collectExpired(now, dst)
+		}
+		return
+	}
+	for _, p := range n.Points {
+		if expired(p, now) {
+			*dst = append(*dst, p)
+		}
+	}
+}
+
+func (qt *QuadTree) startSweep() {
+	qt.stopSweep = make(chan struct{})
+	ticker := time.NewTicker(qt.sweepEvery)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				qt.RemoveExpired()
+			case <-qt.stopSweep:
+				return
+			}
+		}
+	}()
+}
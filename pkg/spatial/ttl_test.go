@@ -0,0 +1,118 @@
+package spatial
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestTTLHidesExpiredPoints tests that queries skip expired points before any sweep
+func TestTTLHidesExpiredPoints(t *testing.T) {
+	clock := newFakeClock()
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithClock(clock.Now))
+
+	qt.InsertWithTTL(Point{X: 10, Y: 10, Data: "stale"}, time.Minute)
+	qt.InsertWithTTL(Point{X: 12, Y: 12, Data: "fresh"}, time.Hour)
+	qt.Insert(Point{X: 50, Y: 50, Data: "forever"})
+	qt.Insert(Point{X: 90, Y: 90, Data: "far"})
+
+	if got := qt.Search(qt.Root.Bounds); len(got) != 4 {
+		t.Fatalf("Expected 4 live points, got %d", len(got))
+	}
+
+	clock.Advance(2 * time.Minute)
+	for _, p := range qt.Search(qt.Root.Bounds) {
+		if p.Data == "stale" {
+			t.Error("Expired point returned by Search")
+		}
+	}
+
+	// The expired point is the nearest, so KNearest must look past it
+	results := qt.KNearest(Point{X: 10, Y: 10}, 2)
+	if len(results) != 2 || results[0].Data != "fresh" || results[1].Data != "forever" {
+		t.Errorf("Expected fresh then forever, got %v", results)
+	}
+	if qt.Size() != 4 {
+		t.Errorf("Expired points should count until removed, got %d", qt.Size())
+	}
+
+	if n := qt.RemoveExpired(); n != 1 {
+		t.Errorf("Expected 1 expired point removed, got %d", n)
+	}
+	if qt.Size() != 3 || len(qt.Search(qt.Root.Bounds)) != 3 {
+		t.Errorf("Expected 3 points after RemoveExpired, got %d", qt.Size())
+	}
+}
+
+// TestInsertWithTTLRejectsNonPositive tests that a TTL must be positive
+func TestInsertWithTTLRejectsNonPositive(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	if qt.InsertWithTTL(Point{X: 1, Y: 1}, 0) || qt.InsertWithTTL(Point{X: 1, Y: 1}, -time.Second) {
+		t.Error("InsertWithTTL should reject a non-positive ttl")
+	}
+	if qt.InsertWithTTL(Point{X: 200, Y: 1}, time.Second) {
+		t.Error("InsertWithTTL should reject out-of-bounds points")
+	}
+}
+
+// TestTTLReinsertDropsExpiry tests that re-inserting a returned point does not carry its TTL
+func TestTTLReinsertDropsExpiry(t *testing.T) {
+	clock := newFakeClock()
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithClock(clock.Now))
+
+	qt.InsertWithTTL(Point{X: 10, Y: 10, Data: "a"}, time.Minute)
+	p := qt.Search(qt.Root.Bounds)[0]
+	qt.Remove(p)
+	qt.Insert(p)
+
+	clock.Advance(time.Hour)
+	if len(qt.Search(qt.Root.Bounds)) != 1 {
+		t.Error("A point inserted with Insert should never expire")
+	}
+}
+
+// TestExpirySweep tests that the background sweep removes expired points and stops on Close
+func TestExpirySweep(t *testing.T) {
+	clock := newFakeClock()
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+		WithClock(clock.Now), WithExpirySweep(time.Millisecond))
+	defer qt.Close()
+
+	for i := 0; i < 20; i++ {
+		qt.InsertWithTTL(Point{X: float64(i * 5), Y: 50}, time.Minute)
+	}
+	qt.Insert(Point{X: 50, Y: 10})
+	clock.Advance(time.Hour)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for qt.Size() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Sweep did not remove expired points, size %d", qt.Size())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	qt.Close()
+	qt.Close()
+}
@@ -0,0 +1,181 @@
+package spatial
+
+import (
+	"fmt"
+	"math"
+)
+
+// finite reports whether v is neither NaN nor infinite
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// validCoordinates reports whether both of p's coordinates are finite. A NaN
+// coordinate never compares true, so such a point could be stored but never
+// found or removed again.
+func validCoordinates(p Point) bool {
+	return finite(p.X) && finite(p.Y)
+}
+
+// Validate returns ErrInvalidBounds if any field of b is NaN or infinite, or
+// if the width or height is negative.
+func (b Bounds) Validate() error {
+	if !finite(b.X) || !finite(b.Y) || !finite(b.Width) || !finite(b.Height) {
+		return ErrInvalidBounds
+	}
+	if b.Width < 0 || b.Height < 0 {
+		return ErrInvalidBounds
+	}
+	return nil
+}
+
+// NewBounds builds a Bounds and validates it
+func NewBounds(x, y, width, height float64) (Bounds, error) {
+	b := Bounds{X: x, Y: y, Width: width, Height: height}
+	if err := b.Validate(); err != nil {
+		return Bounds{}, err
+	}
+	return b, nil
+}
+
+// Validate walks the whole tree and reports every broken structural
+// invariant, wrapping ErrCorruptTree, or nil if the tree is sound. It checks
+// that points sit inside the node holding them and route to it, that no node
+// holds both points and children, that children tile their parent, that
+// subtree counts and Size match the points stored, that depths stay within
+// MaxDepth, and that the ID index only points at live points. It takes the
+// read lock and is O(points × depth), so it is meant for tests and
+// diagnostics rather than hot paths.
+func (qt *QuadTree) Validate() []error {
+	qt.rlockAll()
+	defer qt.runlockAll()
+	v := &validator{qt: qt, leaves: make(map[*Node]bool)}
+	if qt.Root == nil {
+		v.fail("tree has no root")
+		return v.errs
+	}
+	if qt.Root.parent != nil && qt.Root.parent.gen == qt.Root.gen {
+		v.fail("root %v has a parent", qt.Root.Bounds)
+	}
+	if stored := v.node(qt.Root); stored != qt.size {
+		v.fail("Size is %d but the tree stores %d points", qt.size, stored)
+	}
+	for id, loc := range qt.ids {
+		switch {
+		case loc.id != id:
+			v.fail("ID %q indexes the location of %q", id, loc.id)
+		case loc.leaf == nil:
+			v.fail("ID %q points at a removed point", id)
+		case !v.leaves[loc.leaf]:
+			v.fail("ID %q points at node %v, which is not a leaf of the tree", id, loc.leaf.Bounds)
+		default:
+			if _, ok := loc.point(); !ok {
+				v.fail("ID %q points at leaf %v, which does not hold its point", id, loc.leaf.Bounds)
+			}
+		}
+	}
+	return v.errs
+}
+
+type validator struct {
+	qt     *QuadTree
+	errs   []error
+	path   []validateStep // Ancestors of the node being checked, root first
+	leaves map[*Node]bool // Leaves reached from the root
+}
+
+type validateStep struct {
+	node     *Node
+	quadrant int // Child of node the walk descended into
+}
+
+func (v *validator) fail(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%w: %s", ErrCorruptTree, fmt.Sprintf(format, args...)))
+}
+
+// node checks n and its subtree, returning how many points it stores
+func (v *validator) node(n *Node) int {
+	if n.Depth > n.maxDepth() {
+		v.fail("node %v at depth %d exceeds max depth %d", n.Bounds, n.Depth, n.maxDepth())
+	}
+	if n.Children[0] == nil && n.Children[1] == nil && n.Children[2] == nil && n.Children[3] == nil {
+		v.leaves[n] = true
+		for _, p := range n.Points {
+			v.point(n, p)
+		}
+		if n.count != len(n.Points) {
+			v.fail("leaf %v counts %d points but holds %d", n.Bounds, n.count, len(n.Points))
+		}
+		return len(n.Points)
+	}
+
+	if len(n.Points) > 0 {
+		v.fail("node %v holds %d points as well as children", n.Bounds, len(n.Points))
+	}
+	b := n.Bounds
+	w, h := b.Width/2, b.Height/2
+	want := [4]Bounds{
+		{X: b.X, Y: b.Y, Width: w, Height: h},
+		{X: b.X + w, Y: b.Y, Width: w, Height: h},
+		{X: b.X, Y: b.Y + h, Width: w, Height: h},
+		{X: b.X + w, Y: b.Y + h, Width: w, Height: h},
+	}
+	// A root grown by auto-expansion keeps the old root's bounds as a child,
+	// which can differ from the halved parent by rounding
+	tol := 1e-9 * (math.Abs(b.X) + math.Abs(b.Y) + b.Width + b.Height)
+	stored := len(n.Points)
+	for i, c := range n.Children {
+		if c == nil {
+			v.fail("node %v is missing child %d", n.Bounds, i)
+			continue
+		}
+		if !c.Bounds.near(want[i], tol) {
+			v.fail("child %d of node %v has bounds %v, want %v", i, n.Bounds, c.Bounds, want[i])
+		}
+		if c.Depth != n.Depth+1 {
+			v.fail("child %d of node %v is at depth %d, want %d", i, n.Bounds, c.Depth, n.Depth+1)
+		}
+		// A snapshot's children link back to whichever copy of their parent the live tree holds
+		if !v.qt.readOnly && c.parent != n {
+			v.fail("child %d of node %v does not link back to it", i, n.Bounds)
+		}
+		v.path = append(v.path, validateStep{node: n, quadrant: i})
+		stored += v.node(c)
+		v.path = v.path[:len(v.path)-1]
+	}
+	if n.count != stored {
+		v.fail("node %v counts %d points but its subtree holds %d", n.Bounds, n.count, stored)
+	}
+	return stored
+}
+
+// point checks that p, stored in leaf, lies in its bounds, routes to it from
+// every ancestor and is tracked consistently
+func (v *validator) point(leaf *Node, p Point) {
+	if !leaf.Bounds.Contains(p) {
+		v.fail("leaf %v holds point (%g, %g) outside its bounds", leaf.Bounds, p.X, p.Y)
+	}
+	for _, step := range v.path {
+		if q := step.node.quadrant(p); q != step.quadrant {
+			v.fail("point (%g, %g) is stored under child %d of node %v but routes to child %d",
+				p.X, p.Y, step.quadrant, step.node.Bounds, q)
+			break
+		}
+	}
+	// A snapshot shares its points' locations with the live tree, which keeps them current
+	if p.loc == nil || v.qt.readOnly {
+		return
+	}
+	if p.loc.leaf != leaf {
+		v.fail("tracked point (%g, %g) in leaf %v records a different leaf", p.X, p.Y, leaf.Bounds)
+	}
+	if p.loc.id != "" && v.qt.ids[p.loc.id] != p.loc {
+		v.fail("point (%g, %g) with ID %q is missing from the ID index", p.X, p.Y, p.loc.id)
+	}
+}
+
+// near reports whether every field of b is within tol of other
+func (b Bounds) near(other Bounds, tol float64) bool {
+	return math.Abs(b.X-other.X) <= tol && math.Abs(b.Y-other.Y) <= tol &&
+		math.Abs(b.Width-other.Width) <= tol && math.Abs(b.Height-other.Height) <= tol
+}
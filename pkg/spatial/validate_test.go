@@ -0,0 +1,195 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+var invalidPoints = []Point{
+	{X: math.NaN(), Y: 10},
+	{X: 10, Y: math.NaN()},
+	{X: math.Inf(1), Y: 10},
+	{X: 10, Y: math.Inf(-1)},
+}
+
+// TestInsertRejectsInvalidCoordinates tests that the bool APIs refuse NaN and Inf
+func TestInsertRejectsInvalidCoordinates(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4), WithAutoExpand())
+	qt.Insert(Point{X: 10, Y: 10, Data: "ok"})
+
+	for _, p := range invalidPoints {
+		if qt.Insert(p) {
+			t.Errorf("Insert(%v) should fail", p)
+		}
+		if qt.Update(Point{X: 10, Y: 10}, p) {
+			t.Errorf("Update to %v should fail", p)
+		}
+	}
+	if qt.Size() != 1 || len(qt.Search(Bounds{X: 0, Y: 0, Width: 20, Height: 20})) != 1 {
+		t.Error("Rejected points should leave the tree unchanged")
+	}
+	if qt.Root.Bounds.Width != 100 {
+		t.Errorf("Invalid points should not expand the root, got %v", qt.Root.Bounds)
+	}
+
+	inserted, rejected := qt.InsertAll(append([]Point{{X: 50, Y: 50}}, invalidPoints...))
+	if inserted != 1 || len(rejected) != len(invalidPoints) {
+		t.Errorf("Expected 1 inserted and %d rejected, got %d and %d", len(invalidPoints), inserted, len(rejected))
+	}
+}
+
+// TestIDAPIsReturnErrInvalidCoordinate tests that the error-returning APIs carry the reason
+func TestIDAPIsReturnErrInvalidCoordinate(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(4))
+	qt.InsertWithID("d", Point{X: 10, Y: 10})
+
+	for _, p := range invalidPoints {
+		if err := qt.InsertWithID("new", p); !errors.Is(err, ErrInvalidCoordinate) {
+			t.Errorf("InsertWithID(%v): expected ErrInvalidCoordinate, got %v", p, err)
+		}
+		if _, err := qt.Upsert("d", p); !errors.Is(err, ErrInvalidCoordinate) {
+			t.Errorf("Upsert(%v): expected ErrInvalidCoordinate, got %v", p, err)
+		}
+		if err := qt.UpdateByID("d", p); !errors.Is(err, ErrInvalidCoordinate) {
+			t.Errorf("UpdateByID(%v): expected ErrInvalidCoordinate, got %v", p, err)
+		}
+		if err := qt.Move("d", p.X, p.Y); !errors.Is(err, ErrInvalidCoordinate) {
+			t.Errorf("Move(%v): expected ErrInvalidCoordinate, got %v", p, err)
+		}
+	}
+	if p, ok := qt.GetByID("d"); !ok || p.X != 10 || p.Y != 10 {
+		t.Errorf("Rejected updates should leave the point in place, got %v", p)
+	}
+	if _, ok := qt.GetByID("new"); ok {
+		t.Error("Rejected insert should not index the id")
+	}
+}
+
+// TestNewBounds tests bounds validation
+func TestNewBounds(t *testing.T) {
+	if _, err := NewBounds(0, 0, 100, 50); err != nil {
+		t.Errorf("Valid bounds rejected: %v", err)
+	}
+	if _, err := NewBounds(5, 5, 0, 0); err != nil {
+		t.Errorf("Zero-size bounds should be valid, got %v", err)
+	}
+
+	invalid := [][4]float64{
+		{math.NaN(), 0, 10, 10},
+		{0, math.Inf(1), 10, 10},
+		{0, 0, math.Inf(1), 10},
+		{0, 0, 10, math.NaN()},
+		{0, 0, -1, 10},
+		{0, 0, 10, -1},
+	}
+	for _, c := range invalid {
+		if _, err := NewBounds(c[0], c[1], c[2], c[3]); !errors.Is(err, ErrInvalidBounds) {
+			t.Errorf("NewBounds%v: expected ErrInvalidBounds, got %v", c, err)
+		}
+	}
+}
+
+// checkValid fails the test with every invariant qt.Validate reports
+func checkValid(t *testing.T, qt *QuadTree) {
+	t.Helper()
+	for _, err := range qt.Validate() {
+		t.Error(err)
+	}
+}
+
+// TestValidateSoundTree tests that a tree shaped by every kind of mutation validates cleanly
+func TestValidateSoundTree(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithAutoExpand())
+	checkValid(t, qt)
+
+	for i := 0; i < 200; i++ {
+		qt.Insert(Point{X: float64(i % 100), Y: float64(i * 7 % 100)})
+	}
+	for i := 0; i < 20; i++ {
+		qt.InsertWithID(fmt.Sprintf("id%d", i), Point{X: float64(i * 5), Y: 50})
+	}
+	snap := qt.Snapshot()
+	for i := 0; i < 100; i += 3 {
+		qt.Remove(Point{X: float64(i % 100), Y: float64(i * 7 % 100)})
+	}
+	for i := 0; i < 20; i += 2 {
+		qt.UpdateByID(fmt.Sprintf("id%d", i), Point{X: float64(i), Y: 99})
+	}
+	qt.RemoveByID("id1")
+	qt.Insert(Point{X: -150, Y: 250})
+
+	checkValid(t, qt)
+	checkValid(t, snap)
+}
+
+// TestValidateReportsEveryViolation tests that each kind of corruption is reported, all in one call
+func TestValidateReportsEveryViolation(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(1), WithMaxDepth(3))
+	qt.Insert(Point{X: 10, Y: 10})
+	qt.Insert(Point{X: 90, Y: 90})
+	qt.InsertWithID("a", Point{X: 60, Y: 10})
+	if errs := qt.Validate(); len(errs) != 0 {
+		t.Fatalf("Expected a sound tree before corrupting it, got %v", errs)
+	}
+
+	nw, ne, se := qt.Root.Children[0], qt.Root.Children[1], qt.Root.Children[3]
+	// Point outside its leaf, which also routes to the wrong quadrant
+	nw.Points = append(nw.Points, Point{X: 80, Y: 80})
+	// Points alongside children
+	qt.Root.Points = []Point{{X: 1, Y: 1}}
+	// Child not tiling its parent
+	se.Bounds.Width = 10
+	// Depth past MaxDepth
+	ne.Depth = 5
+	// ID entry pointing at a removed point
+	qt.ids["ghost"] = &location{id: "ghost"}
+
+	errs := qt.Validate()
+	for _, err := range errs {
+		if !errors.Is(err, ErrCorruptTree) {
+			t.Errorf("Expected ErrCorruptTree, got %v", err)
+		}
+	}
+	wants := []string{
+		"outside its bounds",
+		"routes to child",
+		"as well as children",
+		"has bounds",
+		"exceeds max depth",
+		"counts",
+		"Size is",
+		`"ghost" points at a removed point`,
+	}
+	for _, want := range wants {
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected a violation mentioning %q, got %v", want, errs)
+		}
+	}
+}
+
+// TestValidateIDIndex tests that stale ID entries and unindexed IDs are reported
+func TestValidateIDIndex(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	qt.InsertWithID("a", Point{X: 10, Y: 10})
+	qt.InsertWithID("b", Point{X: 20, Y: 20})
+
+	// Leaf no longer holds the point
+	loc := qt.ids["a"]
+	qt.ids["a"] = &location{id: "a", leaf: loc.leaf}
+	// Point still in the tree but dropped from the index
+	delete(qt.ids, "b")
+
+	errs := qt.Validate()
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 violations, got %d: %v", len(errs), errs)
+	}
+}
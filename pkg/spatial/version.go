@@ -0,0 +1,155 @@
+package spatial
+
+import "fmt"
+
+// WithVersionHistory retains the last n versions of the tree for At. Every
+// write then starts by taking an O(1) snapshot of the version it replaces,
+// so writes copy the nodes they touch; nodes of evicted versions are freed
+// once no ReadView refers to them. Zero keeps no history.
+func WithVersionHistory(n int) Option {
+	return func(qt *QuadTree) {
+		qt.keepVersions = n
+	}
+}
+
+// versionEntry is a retained version and the snapshot holding it
+type versionEntry struct {
+	version uint64
+	tree    *QuadTree
+}
+
+// Version returns the current version. It starts at 0 and goes up by one for
+// every write that changes the stored points; an Apply batch counts once.
+func (qt *QuadTree) Version() uint64 {
+	if view := qt.view(); view != nil {
+		return view.version
+	}
+	qt.rlockMeta()
+	defer qt.runlockMeta()
+	return qt.version
+}
+
+// beginVersion starts a write: the version it may create has not been bumped
+// yet, and the version being replaced is recorded if history is kept.
+// Callers must hold the write lock.
+func (qt *QuadTree) beginVersion() {
+	qt.versionOpen = false
+	if qt.keepVersions > 0 {
+		qt.capture()
+	}
+}
+
+// changed bumps the version, once per write. Callers must hold the write lock.
+func (qt *QuadTree) changed() {
+	if !qt.versionOpen {
+		qt.version++
+		qt.versionOpen = true
+	}
+}
+
+// capture returns a snapshot of the current version, recording it in the
+// history if history is kept. Callers must hold the write lock.
+func (qt *QuadTree) capture() *QuadTree {
+	if n := len(qt.history); n > 0 && qt.history[n-1].version == qt.version {
+		return qt.history[n-1].tree
+	}
+	snap := qt.snapshot()
+	if qt.keepVersions > 0 {
+		if len(qt.history) == qt.keepVersions {
+			// Drop the oldest so its nodes can be collected
+			qt.history[0] = versionEntry{}
+			qt.history = qt.history[1:]
+		}
+		qt.history = append(qt.history, versionEntry{version: qt.version, tree: snap})
+	}
+	return snap
+}
+
+// At returns a read-only view of the tree as it was at version. The current
+// version is always available; older ones only while retained by
+// WithVersionHistory. Versions that are evicted or not reached yet return a
+// *VersionError.
+func (qt *QuadTree) At(version uint64) (ReadView, error) {
+	// Retained versions are served under the read lock
+	qt.rlockMeta()
+	view, ok := qt.retained(version)
+	qt.runlockMeta()
+	if ok {
+		return view, nil
+	}
+
+	// capture may take a snapshot, which needs the write lock
+	qt.Lock.Lock()
+	defer qt.Lock.Unlock()
+	// The tree may have moved on while the lock was released
+	if view, ok := qt.retained(version); ok {
+		return view, nil
+	}
+	if version == qt.version {
+		return ReadView{tree: qt.capture()}, nil
+	}
+	oldest := qt.version
+	if len(qt.history) > 0 {
+		oldest = qt.history[0].version
+	}
+	return ReadView{}, &VersionError{Version: version, Oldest: oldest, Latest: qt.version}
+}
+
+// retained returns a view of version if it is already held. Callers must hold the lock.
+func (qt *QuadTree) retained(version uint64) (ReadView, bool) {
+	if qt.readOnly && version == qt.version {
+		return ReadView{tree: qt}, true
+	}
+	for _, h := range qt.history {
+		if h.version == version {
+			return ReadView{tree: h.tree}, true
+		}
+	}
+	return ReadView{}, false
+}
+
+// VersionError reports a version At can't serve, along with the range it can.
+// It matches ErrVersionEvicted for versions older than Oldest and ErrNotFound
+// for versions newer than Latest.
+type VersionError struct {
+	Version uint64
+	Oldest  uint64 // Oldest retained version
+	Latest  uint64 // Current version
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("spatial: version %d unavailable, retained versions are %d to %d", e.Version, e.Oldest, e.Latest)
+}
+
+func (e *VersionError) Unwrap() error {
+	if e.Version > e.Latest {
+		return ErrNotFound
+	}
+	return ErrVersionEvicted
+}
+
+// ReadView is a read-only view of the tree pinned to one version. It stays
+// valid and unchanged however the tree is written afterwards.
+type ReadView struct {
+	tree *QuadTree
+}
+
+// Version returns the version the view is pinned to
+func (v ReadView) Version() uint64 {
+	return v.tree.version
+}
+
+// Search returns the points in area as of the view's version
+func (v ReadView) Search(area Bounds) []Point {
+	return v.tree.Search(area)
+}
+
+// KNearest returns the k points nearest target as of the view's version
+func (v ReadView) KNearest(target Point, k int) []Point {
+	return v.tree.KNearest(target, k)
+}
+
+// Count returns how many points the tree held at the view's version
+func (v ReadView) Count() int {
+	return v.tree.Size()
+}
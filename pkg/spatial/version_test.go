@@ -0,0 +1,170 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// TestVersionCounting tests that only writes that change points bump the version, once each
+func TestVersionCounting(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+	if qt.Version() != 0 {
+		t.Errorf("Expected version 0, got %d", qt.Version())
+	}
+	qt.Insert(Point{X: 1, Y: 1})
+	qt.Insert(Point{X: 200, Y: 1})
+	qt.Remove(Point{X: 50, Y: 50})
+	if qt.Version() != 1 {
+		t.Errorf("Expected failed writes to leave version 1, got %d", qt.Version())
+	}
+
+	qt.Apply([]Op{
+		{Kind: OpInsert, Point: Point{X: 2, Y: 2}},
+		{Kind: OpInsert, Point: Point{X: 3, Y: 3}},
+		{Kind: OpRemove, Point: Point{X: 1, Y: 1}},
+	})
+	if qt.Version() != 2 {
+		t.Errorf("Expected a batch to count as one version, got %d", qt.Version())
+	}
+	qt.InsertAll([]Point{{X: 4, Y: 4}, {X: 5, Y: 5}})
+	qt.Clear()
+	if qt.Version() != 4 {
+		t.Errorf("Expected version 4, got %d", qt.Version())
+	}
+}
+
+// TestAtPinsVersions tests that views keep showing their version while the tree changes
+func TestAtPinsVersions(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2), WithVersionHistory(3))
+	for i := 0; i < 5; i++ {
+		qt.Insert(Point{X: float64(i * 10), Y: float64(i * 10)})
+	}
+
+	view, err := qt.At(3)
+	if err != nil {
+		t.Fatalf("At(3): %v", err)
+	}
+	current, err := qt.At(5)
+	if err != nil {
+		t.Fatalf("At(5): %v", err)
+	}
+	qt.Remove(Point{X: 0, Y: 0})
+	qt.Update(Point{X: 10, Y: 10}, Point{X: 90, Y: 90})
+
+	if view.Version() != 3 || view.Count() != 3 {
+		t.Errorf("Expected version 3 with 3 points, got version %d with %d", view.Version(), view.Count())
+	}
+	if got := view.KNearest(Point{X: 0, Y: 0}, 1); len(got) != 1 || got[0].X != 0 {
+		t.Errorf("Expected the removed point to still be nearest in the view, got %v", got)
+	}
+	if got := current.Search(Bounds{X: 5, Y: 5, Width: 10, Height: 10}); len(got) != 1 {
+		t.Errorf("Expected the moved point at its old position, got %v", got)
+	}
+	if qt.Size() != 4 || len(qt.Search(Bounds{X: 5, Y: 5, Width: 10, Height: 10})) != 0 {
+		t.Error("Live tree should reflect the writes")
+	}
+	checkValid(t, qt)
+}
+
+// TestAtUnavailableVersions tests the errors for evicted and future versions
+func TestAtUnavailableVersions(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithVersionHistory(2))
+	for i := 0; i < 5; i++ {
+		qt.Insert(Point{X: float64(i), Y: float64(i)})
+	}
+
+	_, err := qt.At(1)
+	var verr *VersionError
+	if !errors.As(err, &verr) || !errors.Is(err, ErrVersionEvicted) {
+		t.Fatalf("Expected an evicted *VersionError, got %v", err)
+	}
+	if verr.Oldest != 3 || verr.Latest != 5 {
+		t.Errorf("Expected retained range 3 to 5, got %d to %d", verr.Oldest, verr.Latest)
+	}
+	if _, err := qt.At(3); err != nil {
+		t.Errorf("At(3) should still be retained: %v", err)
+	}
+	if _, err := qt.At(9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a future version, got %v", err)
+	}
+
+	// Without history only the current version is available
+	plain := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	plain.Insert(Point{X: 1, Y: 1})
+	if view, err := plain.At(1); err != nil || view.Count() != 1 {
+		t.Errorf("Expected the current version without history, got %v", err)
+	}
+	if _, err := plain.At(0); !errors.Is(err, ErrVersionEvicted) {
+		t.Errorf("Expected ErrVersionEvicted without history, got %v", err)
+	}
+}
+
+// TestVersionHistoryHeapStabilizes tests that evicted versions are reclaimed
+func TestVersionHistoryHeapStabilizes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("churns versions")
+	}
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8), WithVersionHistory(16))
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]string, 5000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("v%d", i)
+		qt.Upsert(ids[i], Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+
+	churn := func(rounds int) {
+		for r := 0; r < rounds; r++ {
+			batch := make([]Op, 200)
+			for i := range batch {
+				batch[i] = Op{Kind: OpUpsert, ID: ids[rng.Intn(len(ids))], Point: Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000}}
+			}
+			qt.Apply(batch)
+			if view, err := qt.At(qt.Version()); err == nil {
+				view.Count()
+			}
+		}
+	}
+	churn(100)
+	base := liveHeap()
+	churn(500)
+	after := liveHeap()
+	runtime.KeepAlive(qt)
+	if after > base+base/2 {
+		t.Errorf("Heap grew from %d to %d bytes while churning versions", base, after)
+	}
+}
+
+// TestVersionHistoryHeapStabilizesSingleWrites tests that evicted versions are
+// reclaimed when every write is its own version, so each one copies a path
+func TestVersionHistoryHeapStabilizesSingleWrites(t *testing.T) {
+	if testing.Short() {
+		t.Skip("churns versions")
+	}
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000}, WithCapacity(8), WithVersionHistory(4))
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]string, 5000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("v%d", i)
+		qt.Upsert(ids[i], Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+	}
+
+	churn := func(writes int) {
+		for i := 0; i < writes; i++ {
+			qt.UpdateByID(ids[rng.Intn(len(ids))], Point{X: rng.Float64() * 1000, Y: rng.Float64() * 1000})
+			if view, err := qt.At(qt.Version()); err == nil {
+				view.Count()
+			}
+		}
+	}
+	churn(20000)
+	base := liveHeap()
+	churn(100000)
+	after := liveHeap()
+	runtime.KeepAlive(qt)
+	if after > base+base/2 {
+		t.Errorf("Heap grew from %d to %d bytes while churning single-write versions", base, after)
+	}
+}
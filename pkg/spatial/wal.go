@@ -0,0 +1,549 @@
+package spatial
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWALSegmentBytes is the size at which a log segment is closed and a
+// new one started, unless WALConfig.SegmentBytes says otherwise.
+const DefaultWALSegmentBytes = 64 << 20
+
+// WALSync chooses when the write-ahead log is flushed to stable storage
+type WALSync uint8
+
+const (
+	// WALSyncEveryWrite syncs after every write, before the write returns
+	WALSyncEveryWrite WALSync = iota
+	// WALSyncEveryN syncs after every WALConfig.SyncEvery writes
+	WALSyncEveryN
+	// WALSyncInterval syncs every WALConfig.SyncInterval from a background
+	// goroutine, if anything was written since the last sync
+	WALSyncInterval
+)
+
+// WALConfig configures WithWAL
+type WALConfig struct {
+	Dir          string        // Directory holding the segments, created if missing
+	SegmentBytes int64         // Segment size limit, DefaultWALSegmentBytes if 0
+	Sync         WALSync       // When to sync, after every write by default
+	SyncEvery    int           // Writes per sync under WALSyncEveryN
+	SyncInterval time.Duration // Time between syncs under WALSyncInterval
+}
+
+// WithWAL appends every mutation to a write-ahead log in cfg.Dir, so that
+// Recover can rebuild the tree from a snapshot and the log after a crash.
+// Each write is one log frame carrying the tree Version it produced and
+// every point it inserted, removed or moved, with its ID, coordinates and
+// Data (encoded by the tree's DataCodec). A frame is written before the
+// write returns, and synced according to cfg.Sync. Segments are named by the
+// first version they hold; SaveToFile starts a new one and deletes those the
+// snapshot before it covers. Close syncs and closes the log.
+//
+// Points loaded by Load or LoadFromFile are not logged: they are already in
+// the snapshot. Once a frame can't be written, logging stops and WALErr
+// reports why. cfg.Dir should hold one tree's log only.
+func WithWAL(cfg WALConfig) Option {
+	return func(qt *QuadTree) {
+		qt.walConfig = &cfg
+	}
+}
+
+// WALErr returns the error that stopped the write-ahead log, or nil while it
+// is healthy or if the tree has none. Writes after it are not logged.
+func (qt *QuadTree) WALErr() error {
+	if qt.wal == nil {
+		return nil
+	}
+	qt.wal.mu.Lock()
+	defer qt.wal.mu.Unlock()
+	return qt.wal.err
+}
+
+// A segment is a header followed by frames:
+//
+//	magic "SPQW", segment format version (uint16)
+//
+// and each frame is
+//
+//	body length (uint32), CRC-32 (IEEE) of the body (uint32), body
+//
+// where the body is the version (uvarint), the record count (uvarint), then
+// for each record an op byte, the previous coordinates for walMove (two
+// float64s), and a WriteTo record of the point (uvarint length, body). A
+// frame cut short by a crash fails its checksum and is ignored.
+const (
+	walMagic      = "SPQW"
+	walVersion    = 1
+	walHeaderSize = len(walMagic) + 2
+	walSuffix     = ".wal"
+	maxFrameBytes = 1 << 30
+)
+
+// Record ops
+const (
+	walInsert byte = iota + 1
+	walRemove
+	walMove
+)
+
+// writeAheadLog is the open log. Records of the write in progress collect in
+// pending, under the tree's write lock or meta, and go out as one frame when
+// the write finishes; mu covers everything else.
+type writeAheadLog struct {
+	cfg     WALConfig
+	codec   DataCodec
+	pending []byte
+	records int
+	record  []byte
+
+	mu       sync.Mutex
+	f        *os.File
+	size     int64
+	last     uint64 // Version of the last frame written
+	unsynced int    // Frames written since the last sync
+	frame    []byte
+	err      error
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newWAL validates cfg and creates its directory. No segment is opened until
+// the first frame, so it can be named after that frame's version.
+func newWAL(cfg WALConfig, codec DataCodec) (*writeAheadLog, error) {
+	if cfg.SegmentBytes == 0 {
+		cfg.SegmentBytes = DefaultWALSegmentBytes
+	}
+	switch {
+	case cfg.Dir == "", cfg.SegmentBytes < 0:
+		return nil, ErrInvalidWAL
+	case cfg.Sync == WALSyncEveryN && cfg.SyncEvery <= 0:
+		return nil, ErrInvalidWAL
+	case cfg.Sync == WALSyncInterval && cfg.SyncInterval <= 0:
+		return nil, ErrInvalidWAL
+	case cfg.Sync > WALSyncInterval:
+		return nil, ErrInvalidWAL
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &writeAheadLog{cfg: cfg, codec: codec}
+	if cfg.Sync == WALSyncInterval {
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.syncLoop()
+	}
+	return w, nil
+}
+
+func (w *writeAheadLog) syncLoop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			w.sync()
+			w.mu.Unlock()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// log adds one record to the pending frame
+func (w *writeAheadLog) log(op byte, from, p Point) {
+	if w == nil {
+		return
+	}
+	start := len(w.pending)
+	w.pending = append(w.pending, op)
+	if op == walMove {
+		w.pending = binary.LittleEndian.AppendUint64(w.pending, math.Float64bits(from.X))
+		w.pending = binary.LittleEndian.AppendUint64(w.pending, math.Float64bits(from.Y))
+	}
+	var id string
+	if p.loc != nil {
+		id = p.loc.id
+	}
+	record, err := appendRecord(w.record[:0], p, id, id != "", w.codec)
+	w.record = record
+	if err != nil {
+		w.pending = w.pending[:start]
+		w.mu.Lock()
+		w.fail(fmt.Errorf("spatial: WAL: encoding Data: %w", err))
+		w.mu.Unlock()
+		return
+	}
+	w.pending = binary.AppendUvarint(w.pending, uint64(len(record)))
+	w.pending = append(w.pending, record...)
+	w.records++
+}
+
+// commit writes the pending records as the frame for version. Callers hold
+// the tree's write lock, or meta for quadrant writes, so frames go out in
+// version order.
+func (w *writeAheadLog) commit(version uint64) {
+	if w == nil || w.records == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	defer func() {
+		w.pending = w.pending[:0]
+		w.records = 0
+	}()
+	if w.err != nil {
+		return
+	}
+
+	frame := append(w.frame[:0], make([]byte, 8)...)
+	frame = binary.AppendUvarint(frame, version)
+	frame = binary.AppendUvarint(frame, uint64(w.records))
+	frame = append(frame, w.pending...)
+	binary.LittleEndian.PutUint32(frame, uint32(len(frame)-8))
+	binary.LittleEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(frame[8:]))
+	w.frame = frame
+
+	if w.f != nil && w.size+int64(len(frame)) > w.cfg.SegmentBytes && w.size > int64(walHeaderSize) {
+		w.rotate()
+	}
+	if w.f == nil && !w.open(version) {
+		return
+	}
+	if _, err := w.f.Write(frame); err != nil {
+		w.fail(err)
+		return
+	}
+	w.size += int64(len(frame))
+	w.last = version
+	w.unsynced++
+	if w.cfg.Sync == WALSyncEveryWrite || w.cfg.Sync == WALSyncEveryN && w.unsynced >= w.cfg.SyncEvery {
+		w.sync()
+	}
+}
+
+// open starts the segment whose first frame is version
+func (w *writeAheadLog) open(version uint64) bool {
+	path := filepath.Join(w.cfg.Dir, segmentName(version))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		w.fail(err)
+		return false
+	}
+	header := append([]byte(walMagic), 0, 0)
+	binary.LittleEndian.PutUint16(header[len(walMagic):], walVersion)
+	if _, err := f.Write(header); err != nil {
+		f.Close()
+		w.fail(err)
+		return false
+	}
+	syncDir(w.cfg.Dir)
+	w.f = f
+	w.size = int64(len(header))
+	return true
+}
+
+// sync flushes written frames to stable storage. Callers must hold mu.
+func (w *writeAheadLog) sync() {
+	if w.f == nil || w.unsynced == 0 || w.err != nil {
+		return
+	}
+	if err := w.f.Sync(); err != nil {
+		w.fail(err)
+		return
+	}
+	w.unsynced = 0
+}
+
+// rotate syncs and closes the current segment. Callers must hold mu.
+func (w *writeAheadLog) rotate() {
+	w.sync()
+	if err := w.f.Close(); err != nil {
+		w.fail(err)
+	}
+	w.f = nil
+}
+
+// fail stops logging, keeping the first error. Callers must hold mu.
+func (w *writeAheadLog) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// checkpoint is called once a snapshot of version is on disk, prev being
+// the version of the snapshot it replaced. It rotates the segment the
+// snapshot covers and deletes the segments that only hold versions up to
+// prev: LoadFromFile may fall back to that snapshot, so the frames after
+// it are kept.
+func (w *writeAheadLog) checkpoint(version, prev uint64) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f != nil && w.last <= version {
+		w.rotate()
+	}
+	segs, err := listSegments(w.cfg.Dir)
+	if err != nil {
+		return
+	}
+	// A segment ends where the next one starts, so the last is never removed
+	for i := 0; i+1 < len(segs) && segs[i+1]-1 <= prev; i++ {
+		os.Remove(filepath.Join(w.cfg.Dir, segmentName(segs[i])))
+	}
+}
+
+// close syncs and closes the log and stops the sync goroutine. Callers must
+// hold the tree's write lock.
+func (w *writeAheadLog) close() {
+	if w == nil {
+		return
+	}
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f != nil {
+		w.rotate()
+	}
+	w.fail(errors.New("spatial: WAL closed"))
+}
+
+func segmentName(version uint64) string {
+	return fmt.Sprintf("%020d%s", version, walSuffix)
+}
+
+// listSegments returns the first versions of the segments in dir, in order
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), walSuffix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if v, err := strconv.ParseUint(name, 10, 64); err == nil {
+			segs = append(segs, v)
+		}
+	}
+	slices.Sort(segs)
+	return segs, nil
+}
+
+// Recover rebuilds a tree after a crash: it loads the snapshot at
+// snapshotPath with LoadFromFile, applying opts, then replays every frame in
+// the log at walDir with a version past the snapshot's, each as one write
+// that restores the version the frame records. Frames the snapshot already
+// holds are skipped, so recovering twice, or from a newer snapshot, applies
+// nothing twice. A frame cut short by a crash, and any batch it was part of,
+// is dropped and cut from the log. A log missing versions after the
+// snapshot, or a frame that doesn't apply, fails with an error wrapping
+// ErrCorruptEncoding. Points without an ID are matched by coordinates, so of
+// several at the same position, replay may remove or move a different one.
+//
+// Pass WithWAL in opts to keep logging to walDir afterwards.
+func Recover(snapshotPath, walDir string, opts ...Option) (*QuadTree, error) {
+	qt, err := LoadFromFile(snapshotPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := qt.replayWAL(walDir); err != nil {
+		qt.Close()
+		return nil, err
+	}
+	return qt, nil
+}
+
+// replayWAL applies the frames in dir past the tree's version, without
+// logging them again
+func (qt *QuadTree) replayWAL(dir string) error {
+	w := qt.wal
+	qt.wal = nil
+	defer func() { qt.wal = w }()
+
+	segs, err := listSegments(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i, first := range segs {
+		if i+1 < len(segs) && segs[i+1]-1 <= qt.version {
+			continue
+		}
+		path := filepath.Join(dir, segmentName(first))
+		end, err := qt.replaySegment(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if end >= 0 && i == len(segs)-1 {
+			// The crash cut the last segment short; drop the torn frame so
+			// new frames don't follow it
+			if end <= int64(walHeaderSize) {
+				err = os.Remove(path)
+			} else {
+				err = os.Truncate(path, end)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replaySegment applies the frames in one segment, returning the offset at
+// which a torn frame starts, or -1 if it ends cleanly
+func (qt *QuadTree) replaySegment(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil
+	}
+	if string(header[:len(walMagic)]) != walMagic {
+		return 0, corrupt("not a WAL segment")
+	}
+	if v := binary.LittleEndian.Uint16(header[len(walMagic):]); v > walVersion {
+		return 0, &FormatError{Kind: "WAL segment", Format: v, Supported: walVersion}
+	} else if v != walVersion {
+		return 0, corrupt("unsupported WAL version %d", v)
+	}
+
+	offset := int64(walHeaderSize)
+	prefix := make([]byte, 8)
+	var body []byte
+	for {
+		if _, err := io.ReadFull(r, prefix); err == io.EOF {
+			return -1, nil
+		} else if err != nil {
+			return offset, nil
+		}
+		n := binary.LittleEndian.Uint32(prefix)
+		if n > maxFrameBytes {
+			return offset, nil
+		}
+		body = slices.Grow(body[:0], int(n))[:n]
+		if _, err := io.ReadFull(r, body); err != nil || crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(prefix[4:]) {
+			return offset, nil
+		}
+		if err := qt.replayFrame(body); err != nil {
+			return 0, fmt.Errorf("frame at offset %d: %w", offset, err)
+		}
+		offset += 8 + int64(n)
+	}
+}
+
+// replayFrame applies one frame body as a single write, unless the tree
+// already holds its version
+func (qt *QuadTree) replayFrame(b []byte) error {
+	version, size := binary.Uvarint(b)
+	if size <= 0 {
+		return corrupt("bad version")
+	}
+	b = b[size:]
+	if version <= qt.version {
+		return nil
+	}
+	if version != qt.version+1 {
+		return corrupt("log skips from version %d to %d", qt.version, version)
+	}
+	count, size := binary.Uvarint(b)
+	if size <= 0 {
+		return corrupt("bad record count")
+	}
+	b = b[size:]
+
+	qt.Lock.Lock()
+	defer qt.unlock()
+	qt.beginVersion()
+	qt.batch = true
+	defer func() { qt.batch = false }()
+	codec := qt.dataCodec()
+	for i := uint64(0); i < count; i++ {
+		if len(b) == 0 {
+			return corrupt("version %d: record %d missing", version, i)
+		}
+		op := b[0]
+		b = b[1:]
+		var from Point
+		if op == walMove {
+			if len(b) < 16 {
+				return corrupt("version %d: record %d too short", version, i)
+			}
+			from.X = math.Float64frombits(binary.LittleEndian.Uint64(b))
+			from.Y = math.Float64frombits(binary.LittleEndian.Uint64(b[8:]))
+			b = b[16:]
+		}
+		n, size := binary.Uvarint(b)
+		if size <= 0 || n > uint64(len(b)-size) {
+			return corrupt("version %d: record %d overruns the frame", version, i)
+		}
+		p, id, hasID, err := parseRecord(b[size:size+int(n)], codec)
+		if err != nil {
+			return corrupt("version %d: record %d: %v", version, i, err)
+		}
+		b = b[size+int(n):]
+		if err := qt.replayRecord(op, from, p, id, hasID); err != nil {
+			return corrupt("version %d: record %d: %v", version, i, err)
+		}
+	}
+	if len(b) != 0 {
+		return corrupt("version %d: %d stray bytes", version, len(b))
+	}
+	qt.version = version
+	return nil
+}
+
+// replayRecord applies one logged mutation. Callers must hold the write lock.
+func (qt *QuadTree) replayRecord(op byte, from, p Point, id string, hasID bool) error {
+	if !qt.own() {
+		return ErrReadOnly
+	}
+	loc := qt.ids[id]
+	if hasID && loc == nil && op != walInsert {
+		return ErrNotFound
+	}
+	switch {
+	case op == walInsert:
+		return qt.insertRecord(p, id, hasID)
+	case op == walRemove && hasID:
+		qt.takeLoc(loc)
+		return nil
+	case op == walRemove:
+		_, err := qt.take(Point{X: p.X, Y: p.Y})
+		return err
+	case op == walMove && hasID:
+		return qt.replaceLoc(loc, p)
+	case op == walMove:
+		return qt.update(from, p)
+	}
+	return fmt.Errorf("unknown op %d", op)
+}
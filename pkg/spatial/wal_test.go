@@ -0,0 +1,220 @@
+package spatial
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// walContents lists a tree's points, with ids, in a form that ignores
+// sequence numbers
+func walContents(qt *QuadTree) []string {
+	var out []string
+	for _, p := range inOrder(qt) {
+		id, _ := qt.pointID(p)
+		out = append(out, fmt.Sprintf("%v,%v %v %q", p.X, p.Y, p.Data, id))
+	}
+	slices.Sort(out)
+	return out
+}
+
+// walTree returns a tree logging to a fresh directory, saved once while empty
+func walTree(t *testing.T, opts ...Option) (qt *QuadTree, snap, dir string) {
+	t.Helper()
+	base := t.TempDir()
+	snap, dir = filepath.Join(base, "tree.snap"), filepath.Join(base, "wal")
+	qt = mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100},
+		append([]Option{WithCapacity(2), WithWAL(WALConfig{Dir: dir})}, opts...)...)
+	if err := qt.SaveToFile(snap); err != nil {
+		t.Fatal(err)
+	}
+	return qt, snap, dir
+}
+
+// TestWALRecover tests that every kind of write survives a crash, including
+// quadrant writes and batches, and that logging continues after Recover
+func TestWALRecover(t *testing.T) {
+	qt, snap, dir := walTree(t)
+	qt.InsertWithID("a", Point{X: 1, Y: 1, Data: "van"})
+	qt.InsertWithID("b", Point{X: 60, Y: 60})
+	qt.Insert(Point{X: 10, Y: 10, Data: "parcel"})
+	qt.Insert(Point{X: 20, Y: 20})
+	qt.Move("a", 2, 2)
+	qt.UpdateByID("b", Point{X: 70, Y: 70, Data: "bike"})
+	qt.Upsert("c", Point{X: 30, Y: 30})
+	qt.Upsert("c", Point{X: 31, Y: 31})
+	qt.Update(Point{X: 10, Y: 10}, Point{X: 90, Y: 5, Data: "parcel"})
+	qt.Remove(Point{X: 20, Y: 20})
+	qt.InsertAll([]Point{{X: 40, Y: 40}, {X: 41, Y: 41}, {X: 500, Y: 500}})
+	qt.Apply([]Op{{Kind: OpInsert, Point: Point{X: 50, Y: 50}}, {Kind: OpRemove, Point: Point{X: 40, Y: 40}}})
+	qt.RemoveByID("b")
+	want, version := walContents(qt), qt.Version()
+	if err := qt.WALErr(); err != nil {
+		t.Fatal(err)
+	}
+	// No Close: the process dies with the segment open
+
+	got, err := Recover(snap, dir, WithWAL(WALConfig{Dir: dir}))
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if !slices.Equal(walContents(got), want) || got.Version() != version {
+		t.Fatalf("Expected %v at version %d, got %v at %d", want, version, walContents(got), got.Version())
+	}
+	if err := got.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	got.InsertWithID("d", Point{X: 3, Y: 3})
+	got.Close()
+	again, err := Recover(snap, dir)
+	if err != nil {
+		t.Fatalf("Recover after restart: %v", err)
+	}
+	if _, ok := again.GetByID("d"); !ok || again.Version() != version+1 {
+		t.Errorf("Expected the write after recovery at version %d, got %d", version+1, again.Version())
+	}
+}
+
+// TestWALCrashMidBatch tests that a batch whose frame was cut short by a
+// crash is dropped whole, and that the torn frame is cut from the log
+func TestWALCrashMidBatch(t *testing.T) {
+	qt, snap, dir := walTree(t)
+	qt.InsertWithID("a", Point{X: 1, Y: 1})
+	want, version := walContents(qt), qt.Version()
+	qt.InsertAll([]Point{{X: 10, Y: 10}, {X: 20, Y: 20}, {X: 30, Y: 30}})
+
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(segs) != 1 {
+		t.Fatalf("Expected one segment, got %v", segs)
+	}
+	info, _ := os.Stat(segs[0])
+	// The crash lands partway through writing the batch's frame
+	if err := os.Truncate(segs[0], info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Recover(snap, dir, WithWAL(WALConfig{Dir: dir}))
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if !slices.Equal(walContents(got), want) || got.Version() != version {
+		t.Fatalf("Expected %v at version %d, got %v at %d", want, version, walContents(got), got.Version())
+	}
+	got.Insert(Point{X: 40, Y: 40})
+	got.Close()
+	again, err := Recover(snap, dir)
+	if err != nil {
+		t.Fatalf("Recover after the torn frame: %v", err)
+	}
+	if again.Size() != 2 || again.Version() != version+1 {
+		t.Errorf("Expected 2 points at version %d, got %d at %d", version+1, again.Size(), again.Version())
+	}
+}
+
+// TestWALIdempotent tests that frames the snapshot already holds are skipped,
+// and that a frame replayed onto a tree that has it changes nothing
+func TestWALIdempotent(t *testing.T) {
+	qt, snap, dir := walTree(t)
+	qt.InsertWithID("a", Point{X: 1, Y: 1})
+	qt.Insert(Point{X: 2, Y: 2})
+	// The log still holds both frames, which the new snapshot covers
+	if err := qt.SaveToFile(snap); err != nil {
+		t.Fatal(err)
+	}
+	qt.RemoveByID("a")
+	want, version := walContents(qt), qt.Version()
+
+	for i := 0; i < 2; i++ {
+		got, err := Recover(snap, dir)
+		if err != nil {
+			t.Fatalf("Recover %d: %v", i, err)
+		}
+		if !slices.Equal(walContents(got), want) || got.Version() != version {
+			t.Fatalf("Recover %d: expected %v at version %d, got %v at %d", i, want, version, walContents(got), got.Version())
+		}
+		if err := got.replayWAL(dir); err != nil || got.Version() != version || got.Size() != 1 {
+			t.Errorf("Replaying again: expected no change, got %v, %d points at %d", err, got.Size(), got.Version())
+		}
+	}
+}
+
+// TestWALSegments tests rotation at the size limit, pruning after snapshots,
+// the sync policies, and config validation
+func TestWALSegments(t *testing.T) {
+	for _, cfg := range []WALConfig{
+		{SegmentBytes: 200},
+		{SegmentBytes: 200, Sync: WALSyncEveryN, SyncEvery: 3},
+		{SegmentBytes: 200, Sync: WALSyncInterval, SyncInterval: time.Millisecond},
+	} {
+		base := t.TempDir()
+		snap := filepath.Join(base, "tree.snap")
+		cfg.Dir = filepath.Join(base, "wal")
+		qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithWAL(cfg))
+		qt.SaveToFile(snap)
+		for i := 0; i < 50; i++ {
+			qt.InsertWithID(fmt.Sprint(i), Point{X: float64(i), Y: float64(i)})
+		}
+		segs, _ := listSegments(cfg.Dir)
+		if len(segs) < 5 {
+			t.Fatalf("Sync %d: expected the log to rotate, got %d segments", cfg.Sync, len(segs))
+		}
+		qt.SaveToFile(snap)
+		for i := 0; i < 5; i++ {
+			qt.RemoveByID(fmt.Sprint(i))
+		}
+		qt.SaveToFile(snap)
+		// Segments up to the second snapshot go once the third replaces it
+		if pruned, _ := listSegments(cfg.Dir); len(pruned) > 2 || pruned[0] <= 50 {
+			t.Errorf("Sync %d: expected segments before version 51 pruned, got %v", cfg.Sync, pruned)
+		}
+		qt.RemoveByID("5")
+		want := walContents(qt)
+		qt.Close()
+		got, err := Recover(snap, cfg.Dir)
+		if err != nil || !slices.Equal(walContents(got), want) {
+			t.Errorf("Sync %d: expected %d points back, got %v", cfg.Sync, len(want), err)
+		}
+	}
+
+	for _, cfg := range []WALConfig{
+		{},
+		{Dir: t.TempDir(), SegmentBytes: -1},
+		{Dir: t.TempDir(), Sync: WALSyncEveryN},
+		{Dir: t.TempDir(), Sync: WALSyncInterval},
+		{Dir: t.TempDir(), Sync: 9},
+	} {
+		if _, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 1, Height: 1}, WithWAL(cfg)); !errors.Is(err, ErrInvalidWAL) {
+			t.Errorf("%+v: expected ErrInvalidWAL, got %v", cfg, err)
+		}
+	}
+}
+
+// TestWALOpenFailureStartsNothing tests that a tree whose log can't be opened
+// leaves no sweep, hook or audit goroutine behind
+func TestWALOpenFailureStartsNothing(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := []Option{
+		WithWAL(WALConfig{Dir: filepath.Join(file, "wal")}),
+		WithExpirySweep(time.Millisecond),
+		OnInsert(func(Point) {}),
+		WithAuditSink(NewRingSink(1)),
+	}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		if _, err := NewQuadTree(Bounds{X: 0, Y: 0, Width: 1, Height: 1}, opts...); err == nil {
+			t.Fatal("Expected a WAL directory under a file to fail")
+		}
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected no goroutines left running, went from %d to %d", before, after)
+	}
+}
@@ -0,0 +1,109 @@
+package spatial
+
+import "sync"
+
+// WatchBuffer is the capacity of the channel returned by Watch
+const WatchBuffer = 64
+
+// ChangeKind says what happened to a point in a watched area
+type ChangeKind uint8
+
+const (
+	// PointAdded is sent when a point is inserted into, or moves into, the area
+	PointAdded ChangeKind = iota
+	// PointRemoved is sent when a point is removed from, or moves out of, the area
+	PointRemoved
+	// PointMoved is sent when a point moves from one spot in the area to another
+	PointMoved
+	// Overflow is sent once there is room again after events were dropped
+	// because the channel was full; the watcher should reload the area.
+	Overflow
+)
+
+// ChangeEvent describes one change inside a watched area. Point is the point
+// after the change, or the removed point for PointRemoved. From is the point
+// before the change and is only set for PointMoved.
+type ChangeEvent struct {
+	Kind  ChangeKind
+	Point Point
+	From  Point
+}
+
+type watcher struct {
+	area       Bounds
+	events     chan ChangeEvent
+	overflowed bool
+}
+
+// Watch returns a channel receiving a ChangeEvent for every mutation that
+// touches area, and a cancel func that unregisters the watcher and closes
+// the channel. Events are delivered without blocking the writer: when the
+// channel is full they are dropped, and an Overflow event follows as soon as
+// there is room. A point moving across the area's edge is reported as
+// PointAdded or PointRemoved.
+func (qt *QuadTree) Watch(area Bounds) (<-chan ChangeEvent, func()) {
+	w := &watcher{area: area, events: make(chan ChangeEvent, WatchBuffer)}
+	qt.Lock.Lock()
+	qt.watchers = append(qt.watchers, w)
+	qt.Lock.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			qt.Lock.Lock()
+			defer qt.Lock.Unlock()
+			for i, other := range qt.watchers {
+				if other == w {
+					qt.watchers = append(qt.watchers[:i], qt.watchers[i+1:]...)
+					close(w.events)
+					return
+				}
+			}
+		})
+	}
+	return w.events, cancel
+}
+
+// notifyWatchers delivers e to every watcher whose area it touches. Callers
+// must hold the write lock.
+func (qt *QuadTree) notifyWatchers(e ChangeEvent) {
+	for _, w := range qt.watchers {
+		switch {
+		case e.Kind != PointMoved:
+			if w.area.Contains(e.Point) {
+				w.deliver(e)
+			}
+		case w.area.Contains(e.From) && w.area.Contains(e.Point):
+			w.deliver(e)
+		case w.area.Contains(e.Point):
+			w.deliver(ChangeEvent{Kind: PointAdded, Point: e.Point})
+		case w.area.Contains(e.From):
+			w.deliver(ChangeEvent{Kind: PointRemoved, Point: e.From})
+		}
+	}
+}
+
+func (w *watcher) deliver(e ChangeEvent) {
+	if w.overflowed {
+		select {
+		case w.events <- ChangeEvent{Kind: Overflow}:
+			w.overflowed = false
+		default:
+			return
+		}
+	}
+	select {
+	case w.events <- e:
+	default:
+		w.overflowed = true
+	}
+}
+
+// closeWatchers unregisters every watcher and closes its channel. Callers
+// must hold the write lock.
+func (qt *QuadTree) closeWatchers() {
+	for _, w := range qt.watchers {
+		close(w.events)
+	}
+	qt.watchers = nil
+}
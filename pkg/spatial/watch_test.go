@@ -0,0 +1,109 @@
+package spatial
+
+import (
+	"testing"
+)
+
+func drain(ch <-chan ChangeEvent) []ChangeEvent {
+	var events []ChangeEvent
+	for {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+// TestWatchReportsChangesInArea tests the event kinds for mutations inside and outside the area
+func TestWatchReportsChangesInArea(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100}, WithCapacity(2))
+	ch, cancel := qt.Watch(Bounds{X: 0, Y: 0, Width: 50, Height: 50})
+	defer cancel()
+
+	qt.Insert(Point{X: 10, Y: 10, Data: "in"})
+	qt.Insert(Point{X: 80, Y: 80, Data: "out"})
+	qt.InsertWithID("d", Point{X: 20, Y: 20, Data: "d"})
+	qt.Move("d", 30, 30)                                                   // moved within
+	qt.Move("d", 70, 30)                                                   // leaves
+	qt.Update(Point{X: 80, Y: 80}, Point{X: 40, Y: 40, Data: "out"})       // enters
+	qt.Update(Point{X: 70, Y: 30}, Point{X: 75, Y: 35, Data: "elsewhere"}) // never inside
+	qt.Remove(Point{X: 10, Y: 10})
+
+	want := []struct {
+		kind ChangeKind
+		data interface{}
+		x, y float64
+	}{
+		{PointAdded, "in", 10, 10},
+		{PointAdded, "d", 20, 20},
+		{PointMoved, "d", 30, 30},
+		{PointRemoved, "d", 30, 30},
+		{PointAdded, "out", 40, 40},
+		{PointRemoved, "in", 10, 10},
+	}
+	got := drain(ch)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d events, got %d: %v", len(want), len(got), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		if e.Kind != w.kind || e.Point.Data != w.data || e.Point.X != w.x || e.Point.Y != w.y {
+			t.Errorf("Event %d: expected %v, got %+v", i, w, e)
+		}
+	}
+	if got[2].From.X != 20 || got[2].From.Y != 20 {
+		t.Errorf("Moved event should carry the old position, got %v", got[2].From)
+	}
+}
+
+// TestWatchCancel tests that cancel closes the channel and stops delivery
+func TestWatchCancel(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	ch, cancel := qt.Watch(qt.Root.Bounds)
+	other, cancelOther := qt.Watch(qt.Root.Bounds)
+	defer cancelOther()
+
+	cancel()
+	cancel()
+	qt.Insert(Point{X: 1, Y: 1})
+
+	if _, ok := <-ch; ok {
+		t.Error("Cancelled watch channel should be closed and empty")
+	}
+	if len(drain(other)) != 1 {
+		t.Error("Other watchers should keep receiving events")
+	}
+}
+
+// TestWatchOverflow tests that a full channel drops events and then signals Overflow
+func TestWatchOverflow(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 1000, Height: 1000})
+	ch, cancel := qt.Watch(qt.Root.Bounds)
+	defer cancel()
+
+	for i := 0; i < WatchBuffer+10; i++ {
+		qt.Insert(Point{X: float64(i), Y: 1})
+	}
+	if got := drain(ch); len(got) != WatchBuffer {
+		t.Fatalf("Expected a full buffer of %d events, got %d", WatchBuffer, len(got))
+	}
+
+	qt.Insert(Point{X: 500, Y: 500, Data: "next"})
+	got := drain(ch)
+	if len(got) != 2 || got[0].Kind != Overflow || got[1].Point.Data != "next" {
+		t.Errorf("Expected Overflow then the next event, got %+v", got)
+	}
+}
+
+// TestWatchClosedByClose tests that closing the tree closes its watch channels
+func TestWatchClosedByClose(t *testing.T) {
+	qt := mustNewQuadTree(Bounds{X: 0, Y: 0, Width: 100, Height: 100})
+	ch, cancel := qt.Watch(qt.Root.Bounds)
+	qt.Close()
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Error("Close should close watch channels")
+	}
+}
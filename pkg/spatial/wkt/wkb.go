@@ -0,0 +1,245 @@
+package wkt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+// WKB geometry type codes
+const (
+	wkbPoint      = 1
+	wkbPolygon    = 3
+	wkbMultiPoint = 4
+)
+
+// PostGIS extended WKB flags, set in the high bits of the type code
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// EncodePoint returns p as little-endian WKB
+func EncodePoint(p spatial.Point) []byte {
+	return appendPoint(nil, p)
+}
+
+// EncodeMultiPoint returns mp as little-endian WKB
+func EncodeMultiPoint(mp MultiPoint) []byte {
+	b := appendHeader(nil, wkbMultiPoint)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(mp)))
+	for _, p := range mp {
+		b = appendPoint(b, p)
+	}
+	return b
+}
+
+// EncodePolygon returns poly as little-endian WKB
+func EncodePolygon(poly Polygon) []byte {
+	b := appendHeader(nil, wkbPolygon)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(poly)))
+	for _, ring := range poly {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(ring)))
+		for _, p := range ring {
+			b = appendCoords(b, p)
+		}
+	}
+	return b
+}
+
+// EncodeBounds returns b as a WKB polygon with one counterclockwise ring
+func EncodeBounds(b spatial.Bounds) []byte {
+	return EncodePolygon(boundsPolygon(b))
+}
+
+func appendHeader(b []byte, kind uint32) []byte {
+	b = append(b, 1) // Little-endian
+	return binary.LittleEndian.AppendUint32(b, kind)
+}
+
+func appendPoint(b []byte, p spatial.Point) []byte {
+	return appendCoords(appendHeader(b, wkbPoint), p)
+}
+
+func appendCoords(b []byte, p spatial.Point) []byte {
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(p.X))
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(p.Y))
+}
+
+// Decode reads a WKB point, multipoint or polygon, in either byte order,
+// returning a spatial.Point, MultiPoint or Polygon. PostGIS extended WKB with
+// an SRID is accepted and the SRID ignored. Other geometry types, and Z or M
+// coordinates, return an error wrapping ErrUnsupported; malformed or
+// truncated input one wrapping ErrSyntax.
+func Decode(b []byte) (any, error) {
+	d := &decoder{b: b}
+	geom := d.geometry(0)
+	if d.err == nil && d.pos != len(b) {
+		d.fail("%d bytes after the geometry", len(b)-d.pos)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return geom, nil
+}
+
+// DecodePoint is Decode for input that must be a point
+func DecodePoint(b []byte) (spatial.Point, error) {
+	return decodeAs[spatial.Point](b, "POINT")
+}
+
+// DecodeMultiPoint is Decode for input that must be a multipoint
+func DecodeMultiPoint(b []byte) (MultiPoint, error) {
+	return decodeAs[MultiPoint](b, "MULTIPOINT")
+}
+
+// DecodePolygon is Decode for input that must be a polygon
+func DecodePolygon(b []byte) (Polygon, error) {
+	return decodeAs[Polygon](b, "POLYGON")
+}
+
+func decodeAs[T any](b []byte, kind string) (T, error) {
+	var zero T
+	geom, err := Decode(b)
+	if err != nil {
+		return zero, err
+	}
+	t, ok := geom.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: expected %s, got %T", ErrUnsupported, kind, geom)
+	}
+	return t, nil
+}
+
+// decoder reads WKB. The first error sticks and later reads return zero values.
+type decoder struct {
+	b     []byte
+	pos   int
+	order binary.ByteOrder
+	err   error
+}
+
+func (d *decoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w at byte %d: %s", ErrSyntax, d.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// take returns the next n bytes
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.b)-d.pos {
+		d.fail("truncated")
+		return nil
+	}
+	d.pos += n
+	return d.b[d.pos-n : d.pos]
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.take(4); b != nil {
+		return d.order.Uint32(b)
+	}
+	return 0
+}
+
+// count reads an element count, checking the input has room for that many
+// elements of at least size bytes so corrupt counts can't force huge allocations
+func (d *decoder) count(size int) int {
+	n := d.uint32()
+	if d.err == nil && uint64(n)*uint64(size) > uint64(len(d.b)-d.pos) {
+		d.fail("count %d overruns the input", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) coords() spatial.Point {
+	b := d.take(16)
+	if b == nil {
+		return spatial.Point{}
+	}
+	return spatial.Point{X: math.Float64frombits(d.order.Uint64(b)), Y: math.Float64frombits(d.order.Uint64(b[8:]))}
+}
+
+// header reads a byte order and type code, returning the base type
+func (d *decoder) header() uint32 {
+	switch order := d.take(1); {
+	case order == nil:
+		return 0
+	case order[0] == 0:
+		d.order = binary.BigEndian
+	case order[0] == 1:
+		d.order = binary.LittleEndian
+	default:
+		d.pos--
+		d.fail("byte order %d", order[0])
+		return 0
+	}
+	kind := d.uint32()
+	if d.err != nil {
+		return 0
+	}
+	base := kind &^ (ewkbZ | ewkbM | ewkbSRID)
+	if kind&(ewkbZ|ewkbM) != 0 || base >= 1000 {
+		// EWKB flags or ISO type codes 1000 and up
+		d.err = fmt.Errorf("%w: Z or M coordinates", ErrUnsupported)
+		return 0
+	}
+	if kind&ewkbSRID != 0 {
+		d.uint32()
+	}
+	return base
+}
+
+// geometry reads one geometry. depth is 1 inside a multipoint, whose members
+// must be points.
+func (d *decoder) geometry(depth int) any {
+	kind := d.header()
+	if d.err != nil {
+		return nil
+	}
+	switch {
+	case kind == wkbPoint:
+		p := d.coords()
+		if d.err == nil && math.IsNaN(p.X) && math.IsNaN(p.Y) {
+			d.err = fmt.Errorf("%w: POINT EMPTY has no spatial.Point", ErrUnsupported)
+		}
+		return p
+	case kind == wkbMultiPoint && depth == 0:
+		n := d.count(21) // Each member is a whole WKB point
+		mp := make(MultiPoint, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			p, _ := d.geometry(1).(spatial.Point)
+			mp = append(mp, p)
+		}
+		return mp
+	case kind == wkbPolygon && depth == 0:
+		rings := d.count(4)
+		poly := make(Polygon, 0, rings)
+		for i := 0; i < rings && d.err == nil; i++ {
+			n := d.count(16)
+			ring := make([]spatial.Point, 0, n)
+			for j := 0; j < n && d.err == nil; j++ {
+				ring = append(ring, d.coords())
+			}
+			if d.err == nil {
+				if err := checkRing(ring); err != nil {
+					d.fail("ring %d: %v", len(poly), err)
+				}
+			}
+			poly = append(poly, ring)
+		}
+		return poly
+	case depth > 0:
+		d.fail("multipoint member of type %d", kind)
+	default:
+		d.err = fmt.Errorf("%w: WKB type %d", ErrUnsupported, kind)
+	}
+	return nil
+}
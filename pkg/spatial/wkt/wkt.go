@@ -0,0 +1,364 @@
+// Package wkt reads and writes the geometries the spatial package works with
+// as Well-Known Text and Well-Known Binary, the formats PostGIS and most GIS
+// tools exchange: points, multipoints and polygons. A parsed Polygon can be
+// passed straight to QuadTree.SearchPolygon.
+package wkt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+var (
+	// ErrSyntax is wrapped by errors for malformed WKT or WKB
+	ErrSyntax = errors.New("wkt: syntax error")
+	// ErrUnsupported is wrapped by errors for geometry types, or Z and M
+	// coordinates, that the package does not read
+	ErrUnsupported = errors.New("wkt: unsupported geometry")
+)
+
+// MultiPoint is a set of points
+type MultiPoint []spatial.Point
+
+// Polygon is a list of closed rings, each ending with its first point: the
+// exterior ring, then any holes
+type Polygon [][]spatial.Point
+
+// Bounds returns the smallest bounds holding the exterior ring
+func (poly Polygon) Bounds() spatial.Bounds {
+	if len(poly) == 0 || len(poly[0]) == 0 {
+		return spatial.Bounds{}
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range poly[0] {
+		minX, maxX = min(minX, p.X), max(maxX, p.X)
+		minY, maxY = min(minY, p.Y), max(maxY, p.Y)
+	}
+	return spatial.Bounds{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+// Contains reports whether p is inside the exterior ring and outside every
+// hole, or on any ring, as spatial.PolygonContains decides
+func (poly Polygon) Contains(p spatial.Point) bool {
+	return spatial.PolygonContains(poly, p)
+}
+
+// FormatPoint returns p as POINT(x y)
+func FormatPoint(p spatial.Point) string {
+	var b strings.Builder
+	b.WriteString("POINT(")
+	writeCoords(&b, p)
+	b.WriteByte(')')
+	return b.String()
+}
+
+// FormatMultiPoint returns mp as MULTIPOINT((x y),...), or MULTIPOINT EMPTY
+func FormatMultiPoint(mp MultiPoint) string {
+	if len(mp) == 0 {
+		return "MULTIPOINT EMPTY"
+	}
+	var b strings.Builder
+	b.WriteString("MULTIPOINT(")
+	for i, p := range mp {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		writeCoords(&b, p)
+		b.WriteByte(')')
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// FormatPolygon returns poly as POLYGON((x y,...),...), or POLYGON EMPTY
+func FormatPolygon(poly Polygon) string {
+	if len(poly) == 0 {
+		return "POLYGON EMPTY"
+	}
+	var b strings.Builder
+	b.WriteString("POLYGON(")
+	for i, ring := range poly {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		for j, p := range ring {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			writeCoords(&b, p)
+		}
+		b.WriteByte(')')
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// FormatBounds returns b as a POLYGON with one counterclockwise ring
+func FormatBounds(b spatial.Bounds) string {
+	return FormatPolygon(boundsPolygon(b))
+}
+
+func boundsPolygon(b spatial.Bounds) Polygon {
+	return Polygon{{
+		{X: b.X, Y: b.Y},
+		{X: b.X + b.Width, Y: b.Y},
+		{X: b.X + b.Width, Y: b.Y + b.Height},
+		{X: b.X, Y: b.Y + b.Height},
+		{X: b.X, Y: b.Y},
+	}}
+}
+
+// writeCoords writes "x y" in the shortest form that parses back exactly
+func writeCoords(b *strings.Builder, p spatial.Point) {
+	b.WriteString(strconv.FormatFloat(p.X, 'g', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(p.Y, 'g', -1, 64))
+}
+
+// Parse reads a POINT, MULTIPOINT or POLYGON, returning a spatial.Point,
+// MultiPoint or Polygon. Keywords are case-insensitive, any whitespace may
+// separate tokens, numbers may use exponents, and a PostGIS SRID=n; prefix is
+// ignored. Other geometry types, and Z or M coordinates, return an error
+// wrapping ErrUnsupported; malformed text one wrapping ErrSyntax.
+func Parse(s string) (any, error) {
+	p := &parser{s: s}
+	p.skipSRID()
+	kind := strings.ToUpper(p.word())
+	var geom any
+	switch kind {
+	case "POINT":
+		geom = p.point()
+	case "MULTIPOINT":
+		geom = p.multiPoint()
+	case "POLYGON":
+		geom = p.polygon()
+	case "":
+		p.fail("expected a geometry type")
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, kind)
+	}
+	if p.err == nil && p.skipSpace() < len(p.s) {
+		p.fail("unexpected %q after the geometry", p.s[p.pos:])
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return geom, nil
+}
+
+// ParsePoint is Parse for text that must be a POINT
+func ParsePoint(s string) (spatial.Point, error) {
+	return parseAs[spatial.Point](s, "POINT")
+}
+
+// ParseMultiPoint is Parse for text that must be a MULTIPOINT
+func ParseMultiPoint(s string) (MultiPoint, error) {
+	return parseAs[MultiPoint](s, "MULTIPOINT")
+}
+
+// ParsePolygon is Parse for text that must be a POLYGON
+func ParsePolygon(s string) (Polygon, error) {
+	return parseAs[Polygon](s, "POLYGON")
+}
+
+func parseAs[T any](s, kind string) (T, error) {
+	var zero T
+	geom, err := Parse(s)
+	if err != nil {
+		return zero, err
+	}
+	t, ok := geom.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: expected %s, got %T", ErrUnsupported, kind, geom)
+	}
+	return t, nil
+}
+
+// parser reads WKT. The first error sticks and later reads return zero values.
+type parser struct {
+	s   string
+	pos int
+	err error
+}
+
+func (p *parser) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w at offset %d: %s", ErrSyntax, p.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// skipSpace advances past whitespace and returns the new position
+func (p *parser) skipSpace() int {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos
+}
+
+// skipSRID skips an EWKT SRID=n; prefix
+func (p *parser) skipSRID() {
+	p.skipSpace()
+	if len(p.s)-p.pos >= 5 && strings.EqualFold(p.s[p.pos:p.pos+5], "SRID=") {
+		if end := strings.IndexByte(p.s[p.pos:], ';'); end >= 0 {
+			p.pos += end + 1
+		}
+	}
+}
+
+// word reads a run of letters
+func (p *parser) word() string {
+	start := p.skipSpace()
+	for p.pos < len(p.s) && (p.s[p.pos]|0x20 >= 'a' && p.s[p.pos]|0x20 <= 'z') {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// peek reports whether the next token starts with c, without consuming it
+func (p *parser) peek(c byte) bool {
+	return p.err == nil && p.skipSpace() < len(p.s) && p.s[p.pos] == c
+}
+
+func (p *parser) expect(c byte) {
+	if p.err != nil {
+		return
+	}
+	if !p.peek(c) {
+		p.fail("expected %q", c)
+		return
+	}
+	p.pos++
+}
+
+// open reads the opening parenthesis of a geometry, reporting false for
+// EMPTY. Z, M and ZM markers are rejected.
+func (p *parser) open(kind string) bool {
+	if p.err != nil {
+		return false
+	}
+	switch w := strings.ToUpper(p.word()); w {
+	case "":
+		p.expect('(')
+		return true
+	case "EMPTY":
+		return false
+	case "Z", "M", "ZM":
+		p.err = fmt.Errorf("%w: %s %s", ErrUnsupported, kind, w)
+	default:
+		p.fail("unexpected %q", w)
+	}
+	return false
+}
+
+func (p *parser) number() float64 {
+	if p.err != nil {
+		return 0
+	}
+	start := p.skipSpace()
+	for p.pos < len(p.s) && strings.IndexByte("0123456789+-.eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		p.fail("expected a number")
+		return 0
+	}
+	return v
+}
+
+// coords reads "x y", rejecting a third ordinate
+func (p *parser) coords() spatial.Point {
+	pt := spatial.Point{X: p.number(), Y: p.number()}
+	if p.err == nil && !p.peek(',') && !p.peek(')') {
+		if p.number(); p.err == nil {
+			p.err = fmt.Errorf("%w: coordinates with more than two ordinates", ErrUnsupported)
+		}
+	}
+	return pt
+}
+
+func (p *parser) point() spatial.Point {
+	if !p.open("POINT") {
+		if p.err == nil {
+			p.err = fmt.Errorf("%w: POINT EMPTY has no spatial.Point", ErrUnsupported)
+		}
+		return spatial.Point{}
+	}
+	pt := p.coords()
+	p.expect(')')
+	return pt
+}
+
+func (p *parser) multiPoint() MultiPoint {
+	mp := MultiPoint{}
+	if !p.open("MULTIPOINT") {
+		return mp
+	}
+	for p.err == nil {
+		// Both MULTIPOINT((1 2),(3 4)) and MULTIPOINT(1 2,3 4) are in use
+		if p.peek('(') {
+			p.pos++
+			mp = append(mp, p.coords())
+			p.expect(')')
+		} else {
+			mp = append(mp, p.coords())
+		}
+		if !p.peek(',') {
+			break
+		}
+		p.pos++
+	}
+	p.expect(')')
+	return mp
+}
+
+func (p *parser) polygon() Polygon {
+	poly := Polygon{}
+	if !p.open("POLYGON") {
+		return poly
+	}
+	for p.err == nil {
+		p.expect('(')
+		var ring []spatial.Point
+		for p.err == nil {
+			ring = append(ring, p.coords())
+			if !p.peek(',') {
+				break
+			}
+			p.pos++
+		}
+		p.expect(')')
+		if p.err == nil {
+			if err := checkRing(ring); err != nil {
+				p.fail("ring %d: %v", len(poly), err)
+			}
+		}
+		poly = append(poly, ring)
+		if !p.peek(',') {
+			break
+		}
+		p.pos++
+	}
+	p.expect(')')
+	return poly
+}
+
+// checkRing checks that ring is closed and long enough to enclose an area
+func checkRing(ring []spatial.Point) error {
+	if len(ring) < 4 {
+		return fmt.Errorf("%d points, a ring needs at least 4", len(ring))
+	}
+	if first, last := ring[0], ring[len(ring)-1]; first.X != last.X || first.Y != last.Y {
+		return errors.New("not closed")
+	}
+	return nil
+}
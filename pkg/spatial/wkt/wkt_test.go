@@ -0,0 +1,210 @@
+package wkt
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/Fusion831/Distributed-Delivery-Routing-Engine/pkg/spatial"
+)
+
+var square = Polygon{{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}, {X: 0, Y: 0}}}
+
+// TestParse tests WKT parsing across spellings of the same geometries
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want any
+	}{
+		{"POINT(1 2)", spatial.Point{X: 1, Y: 2}},
+		{"  point ( -1.5e3\t2.5E-2 )\n", spatial.Point{X: -1500, Y: 0.025}},
+		{"SRID=4326;POINT(13.4 52.5)", spatial.Point{X: 13.4, Y: 52.5}},
+		{"MULTIPOINT((1 2),(3 4))", MultiPoint{{X: 1, Y: 2}, {X: 3, Y: 4}}},
+		{"MultiPoint (1 2, 3 4)", MultiPoint{{X: 1, Y: 2}, {X: 3, Y: 4}}},
+		{"MULTIPOINT EMPTY", MultiPoint{}},
+		{"POLYGON((0 0,10 0,10 10,0 10,0 0))", square},
+		{"POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 2 4, 4 4, 2 2))",
+			append(Polygon{square[0]}, []spatial.Point{{X: 2, Y: 2}, {X: 2, Y: 4}, {X: 4, Y: 4}, {X: 2, Y: 2}})},
+		{"POLYGON EMPTY", Polygon{}},
+	} {
+		got, err := Parse(c.text)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Parse(%q) = %v, %v, want %v", c.text, got, err, c.want)
+		}
+	}
+}
+
+// TestParseErrors tests that unsupported types and malformed text are
+// rejected with the matching error
+func TestParseErrors(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want error
+	}{
+		{"LINESTRING(0 0,1 1)", ErrUnsupported},
+		{"POINT Z (1 2 3)", ErrUnsupported},
+		{"POINT(1 2 3)", ErrUnsupported},
+		{"POINT EMPTY", ErrUnsupported},
+		{"", ErrSyntax},
+		{"POINT(1)", ErrSyntax},
+		{"POINT(1 2", ErrSyntax},
+		{"POINT(1 2) x", ErrSyntax},
+		{"POINT(1 1e999)", ErrSyntax},
+		{"POINT(a b)", ErrSyntax},
+		{"POLYGON((0 0,1 0,1 1))", ErrSyntax},
+		{"POLYGON((0 0,1 0,1 1,0 1))", ErrSyntax},
+		{"MULTIPOINT((1 2),)", ErrSyntax},
+	} {
+		if _, err := Parse(c.text); !errors.Is(err, c.want) {
+			t.Errorf("Parse(%q): expected %v, got %v", c.text, c.want, err)
+		}
+	}
+	if _, err := ParsePoint("POLYGON EMPTY"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ParsePoint to reject a polygon, got %v", err)
+	}
+}
+
+// TestFormat tests formatting, and that parsing the text gives back the
+// same values exactly
+func TestFormat(t *testing.T) {
+	if got := FormatPoint(spatial.Point{X: -0.1276, Y: 51.5072}); got != "POINT(-0.1276 51.5072)" {
+		t.Errorf("Got %s", got)
+	}
+	if got := FormatBounds(spatial.Bounds{X: 0, Y: 0, Width: 10, Height: 10}); got != "POLYGON((0 0,10 0,10 10,0 10,0 0))" {
+		t.Errorf("Got %s", got)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		p := spatial.Point{X: (rng.Float64() - 0.5) * math.Pow(10, float64(rng.Intn(40)-20)), Y: rng.NormFloat64()}
+		if got, err := ParsePoint(FormatPoint(p)); err != nil || got != p {
+			t.Fatalf("Point %v came back as %v, %v", p, got, err)
+		}
+		mp := MultiPoint{p, {X: p.Y, Y: p.X}}
+		if got, err := ParseMultiPoint(FormatMultiPoint(mp)); err != nil || !reflect.DeepEqual(got, mp) {
+			t.Fatalf("MultiPoint %v came back as %v, %v", mp, got, err)
+		}
+	}
+}
+
+// TestWKB tests binary round trips, big-endian and PostGIS extended input,
+// and rejection of unsupported and corrupt input
+func TestWKB(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		p := spatial.Point{X: rng.NormFloat64() * 1e6, Y: math.Nextafter(rng.Float64(), 2)}
+		if got, err := DecodePoint(EncodePoint(p)); err != nil || got != p {
+			t.Fatalf("Point %v came back as %v, %v", p, got, err)
+		}
+	}
+	mp := MultiPoint{{X: 1, Y: 2}, {X: -3, Y: 4e-300}}
+	if got, err := DecodeMultiPoint(EncodeMultiPoint(mp)); err != nil || !reflect.DeepEqual(got, mp) {
+		t.Errorf("MultiPoint came back as %v, %v", got, err)
+	}
+	if got, err := DecodePolygon(EncodeBounds(spatial.Bounds{X: 0, Y: 0, Width: 10, Height: 10})); err != nil || !reflect.DeepEqual(got, square) {
+		t.Errorf("Bounds came back as %v, %v", got, err)
+	}
+
+	for _, c := range []struct {
+		hex  string
+		want any
+	}{
+		// Big-endian POINT(1 2)
+		{"00000000013ff00000000000004000000000000000", spatial.Point{X: 1, Y: 2}},
+		// PostGIS EWKB of SRID=4326;POINT(1 2)
+		{"0101000020e6100000000000000000f03f0000000000000040", spatial.Point{X: 1, Y: 2}},
+	} {
+		b, _ := hex.DecodeString(c.hex)
+		if got, err := Decode(b); err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Decode(%s) = %v, %v, want %v", c.hex, got, err, c.want)
+		}
+	}
+
+	unsupported := []string{
+		"010200000000000000", // LINESTRING EMPTY
+		"01010000800000000000000000000000000000000000000000000000000000000000", // EWKB POINT Z
+		"01e9030000000000000000f03f00000000000000400000000000000840",           // ISO POINT Z
+		"0101000000000000000000f87f000000000000f87f",                           // POINT EMPTY
+	}
+	for _, h := range unsupported {
+		b, _ := hex.DecodeString(h)
+		if _, err := Decode(b); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Decode(%s): expected ErrUnsupported, got %v", h, err)
+		}
+	}
+
+	poly := EncodePolygon(square)
+	for n := 0; n < len(poly); n++ {
+		if _, err := Decode(poly[:n]); !errors.Is(err, ErrSyntax) {
+			t.Fatalf("Truncated to %d bytes: expected ErrSyntax, got %v", n, err)
+		}
+	}
+	if _, err := Decode(append(poly, 0)); !errors.Is(err, ErrSyntax) {
+		t.Errorf("Expected trailing bytes to be rejected, got %v", err)
+	}
+	huge := append([]byte(nil), poly[:9]...)
+	huge[5], huge[6], huge[7], huge[8] = 0xff, 0xff, 0xff, 0xff
+	if _, err := Decode(huge); !errors.Is(err, ErrSyntax) {
+		t.Errorf("Expected a huge ring count to be rejected, got %v", err)
+	}
+}
+
+// TestPolygonSearch tests feeding a parsed polygon into a tree query, and
+// that its boundary is part of it
+func TestPolygonSearch(t *testing.T) {
+	poly, err := ParsePolygon("POLYGON((0 0,10 0,0 10,0 0))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	qt, _ := spatial.NewQuadTree(spatial.Bounds{X: 0, Y: 0, Width: 20, Height: 20}, spatial.WithSearchEdges(spatial.HalfOpenEdges))
+	qt.Insert(spatial.Point{X: 2, Y: 2, Data: "in"})
+	qt.Insert(spatial.Point{X: 10, Y: 0, Data: "vertex"})
+	qt.Insert(spatial.Point{X: 5, Y: 5, Data: "hypotenuse"})
+	qt.Insert(spatial.Point{X: 0, Y: 7, Data: "edge"})
+	qt.Insert(spatial.Point{X: 8, Y: 8, Data: "box only"})
+	qt.Insert(spatial.Point{X: 15, Y: 15, Data: "out"})
+
+	var got []any
+	for _, p := range qt.SearchPolygon(poly) {
+		got = append(got, p.Data)
+	}
+	if want := []any{"in", "vertex", "hypotenuse", "edge"}; !sameElements(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	holed, _ := ParsePolygon("POLYGON((0 0,10 0,10 10,0 10,0 0),(4 4,6 4,6 6,4 6,4 4))")
+	for _, tc := range []struct {
+		p    spatial.Point
+		want bool
+	}{
+		{spatial.Point{X: 1, Y: 1}, true},
+		{spatial.Point{X: 5, Y: 5}, false},  // In the hole
+		{spatial.Point{X: 10, Y: 10}, true}, // Vertex
+		{spatial.Point{X: 10, Y: 3}, true},  // Edge
+		{spatial.Point{X: 5, Y: 4}, true},   // Edge of the hole
+		{spatial.Point{X: 6, Y: 6}, true},   // Vertex of the hole
+		{spatial.Point{X: 10.5, Y: 3}, false},
+	} {
+		if got := holed.Contains(tc.p); got != tc.want {
+			t.Errorf("Contains(%v, %v): expected %v, got %v", tc.p.X, tc.p.Y, tc.want, got)
+		}
+	}
+}
+
+// sameElements reports whether a and b hold the same values in any order
+func sameElements(a, b []any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[any]int)
+	for _, v := range a {
+		seen[v]++
+	}
+	for _, v := range b {
+		if seen[v]--; seen[v] < 0 {
+			return false
+		}
+	}
+	return true
+}